@@ -591,6 +591,8 @@ func (a *AndroidMkEntries) fillInEntries(ctx fillInEntriesContext, mod blueprint
 		a.SetBoolIfTrue("LOCAL_ODM_MODULE", Bool(base.commonProperties.Device_specific))
 		a.SetBoolIfTrue("LOCAL_PRODUCT_MODULE", Bool(base.commonProperties.Product_specific))
 		a.SetBoolIfTrue("LOCAL_SYSTEM_EXT_MODULE", Bool(base.commonProperties.System_ext_specific))
+		// The partition the module is installed to, recorded in module-info.json by Make.
+		a.SetString("LOCAL_SOONG_PARTITION", base.PartitionTag(ctx.Config().DeviceConfig()))
 		if base.commonProperties.Owner != nil {
 			a.SetString("LOCAL_MODULE_OWNER", *base.commonProperties.Owner)
 		}
@@ -678,7 +680,10 @@ func AndroidMkSingleton() Singleton {
 	return &androidMkSingleton{}
 }
 
-type androidMkSingleton struct{}
+type androidMkSingleton struct {
+	// The contents of the generated Android-<product_name>.mk file, for tests.
+	contentsForTesting []byte
+}
 
 func (c *androidMkSingleton) GenerateBuildActions(ctx SingletonContext) {
 	// Skip if Soong wasn't invoked from Make.
@@ -692,10 +697,14 @@ func (c *androidMkSingleton) GenerateBuildActions(ctx SingletonContext) {
 		androidMkModulesList = append(androidMkModulesList, module)
 	})
 
-	// Sort the module list by the module names to eliminate random churns, which may erroneously
-	// invoke additional build processes.
+	// Sort the module list by the module names and then by the variant names to eliminate random
+	// churns, which may erroneously invoke additional build processes.
 	sort.SliceStable(androidMkModulesList, func(i, j int) bool {
-		return ctx.ModuleName(androidMkModulesList[i]) < ctx.ModuleName(androidMkModulesList[j])
+		iName, jName := ctx.ModuleName(androidMkModulesList[i]), ctx.ModuleName(androidMkModulesList[j])
+		if iName != jName {
+			return iName < jName
+		}
+		return ctx.ModuleSubDir(androidMkModulesList[i]) < ctx.ModuleSubDir(androidMkModulesList[j])
 	})
 
 	transMk := PathForOutput(ctx, "Android"+String(ctx.Config().productVariables.Make_suffix)+".mk")
@@ -703,10 +712,11 @@ func (c *androidMkSingleton) GenerateBuildActions(ctx SingletonContext) {
 		return
 	}
 
-	err := translateAndroidMk(ctx, absolutePath(transMk.String()), androidMkModulesList)
+	contents, err := translateAndroidMk(ctx, absolutePath(transMk.String()), androidMkModulesList)
 	if err != nil {
 		ctx.Errorf(err.Error())
 	}
+	c.contentsForTesting = contents
 
 	ctx.Build(pctx, BuildParams{
 		Rule:   blueprint.Phony,
@@ -714,17 +724,24 @@ func (c *androidMkSingleton) GenerateBuildActions(ctx SingletonContext) {
 	})
 }
 
-func translateAndroidMk(ctx SingletonContext, absMkFile string, mods []blueprint.Module) error {
+func translateAndroidMk(ctx SingletonContext, absMkFile string, mods []blueprint.Module) ([]byte, error) {
 	buf := &bytes.Buffer{}
 
 	fmt.Fprintln(buf, "LOCAL_MODULE_MAKEFILE := $(lastword $(MAKEFILE_LIST))")
 
 	typeStats := make(map[string]int)
+	// All modules are translated before any of them are written so that the modules required by
+	// each module can be resolved to the names of the variants that should be installed with it.
+	makeNames := make(androidMkMakeNames)
+	var writers []androidMkModuleWriter
 	for _, mod := range mods {
-		err := translateAndroidMkModule(ctx, buf, mod)
+		write, err := translateAndroidMkModule(ctx, mod, makeNames)
 		if err != nil {
 			os.Remove(absMkFile)
-			return err
+			return nil, err
+		}
+		if write != nil {
+			writers = append(writers, androidMkModuleWriter{mod, write})
 		}
 
 		if amod, ok := mod.(Module); ok && ctx.PrimaryModule(amod) == amod {
@@ -732,6 +749,10 @@ func translateAndroidMk(ctx SingletonContext, absMkFile string, mods []blueprint
 		}
 	}
 
+	for _, writer := range writers {
+		writer.writeAndroidMk(ctx, buf, makeNames)
+	}
+
 	keys := []string{}
 	fmt.Fprintln(buf, "\nSTATS.SOONG_MODULE_TYPE :=")
 	for k := range typeStats {
@@ -743,10 +764,113 @@ func translateAndroidMk(ctx SingletonContext, absMkFile string, mods []blueprint
 		fmt.Fprintf(buf, "STATS.SOONG_MODULE_TYPE.%s := %d\n", mod_type, typeStats[mod_type])
 	}
 
-	return pathtools.WriteFileIfChanged(absMkFile, buf.Bytes(), 0666)
+	return buf.Bytes(), pathtools.WriteFileIfChanged(absMkFile, buf.Bytes(), 0666)
+}
+
+// androidMkWriteFunc writes the translated Android.mk content of a module once all modules have
+// been translated.
+type androidMkWriteFunc func(w io.Writer, makeNames androidMkMakeNames)
+
+type androidMkModuleWriter struct {
+	mod   blueprint.Module
+	write androidMkWriteFunc
 }
 
-func translateAndroidMkModule(ctx SingletonContext, w io.Writer, mod blueprint.Module) error {
+func (a androidMkModuleWriter) writeAndroidMk(ctx SingletonContext, w io.Writer, makeNames androidMkMakeNames) {
+	defer func() {
+		if r := recover(); r != nil {
+			panic(fmt.Errorf("%s in writeAndroidMk for module %s variant %s",
+				r, ctx.ModuleName(a.mod), ctx.ModuleSubDir(a.mod)))
+		}
+	}()
+
+	a.write(w, makeNames)
+}
+
+// androidMkMakeName is the name in Make of a device variant of a module.
+type androidMkMakeName struct {
+	os       OsType
+	image    string
+	makeName string
+}
+
+// androidMkMakeNames maps the names of modules to the names in Make of their device variants.
+type androidMkMakeNames map[string][]androidMkMakeName
+
+func (n androidMkMakeNames) add(mod blueprint.Module, entries *AndroidMkEntries) {
+	amod, ok := mod.(Module)
+	if !ok || amod.Os().Class != Device || entries.Disabled || !entries.OutputFile.Valid() {
+		return
+	}
+	makeName := entries.EntryMap["LOCAL_MODULE"]
+	if len(makeName) != 1 {
+		return
+	}
+	name := amod.base().BaseModuleName()
+	n[name] = append(n[name], androidMkMakeName{
+		os:       amod.Os(),
+		image:    amod.base().commonProperties.ImageVariation,
+		makeName: makeName[0],
+	})
+}
+
+// resolve returns the name in Make of the variant of the module called name that should be
+// installed with a module on the given os and image.  A variant on the same image is preferred,
+// otherwise a variant on another image is used so that, e.g., a system module can require a
+// vendor module.  The name is returned unchanged if it is not the name of a module or if the
+// variant is ambiguous.
+func (n androidMkMakeNames) resolve(os OsType, image, name string) string {
+	var sameImage, otherImage []string
+	for _, v := range n[name] {
+		if v.os != os {
+			continue
+		}
+		if v.image == image {
+			sameImage = append(sameImage, v.makeName)
+		} else {
+			otherImage = append(otherImage, v.makeName)
+		}
+	}
+
+	for _, makeNames := range [][]string{sameImage, otherImage} {
+		makeNames = FirstUniqueStrings(makeNames)
+		if len(makeNames) == 0 {
+			continue
+		}
+		if len(makeNames) == 1 {
+			return makeNames[0]
+		}
+		return name
+	}
+	return name
+}
+
+// resolveRequired returns the names in Make of the variants of the required modules that should be
+// installed with mod.
+func (n androidMkMakeNames) resolveRequired(mod blueprint.Module, required []string) []string {
+	amod, ok := mod.(Module)
+	if !ok || amod.Os().Class != Device || len(required) == 0 {
+		return required
+	}
+	ret := make([]string, len(required))
+	for i, name := range required {
+		ret[i] = n.resolve(amod.Os(), amod.base().commonProperties.ImageVariation, name)
+	}
+	return ret
+}
+
+// resolveRequired replaces the names of the modules required by this module with the names in
+// Make of the variants of those modules that should be installed with it.
+func (a *AndroidMkEntries) resolveRequired(mod blueprint.Module, makeNames androidMkMakeNames) {
+	a.Required = makeNames.resolveRequired(mod, a.Required)
+	if required, ok := a.EntryMap["LOCAL_REQUIRED_MODULES"]; ok {
+		a.EntryMap["LOCAL_REQUIRED_MODULES"] = makeNames.resolveRequired(mod, required)
+	}
+}
+
+// translateAndroidMkModule translates a module and returns a function that writes its Android.mk
+// content, or nil if the module is not exported to Make.
+func translateAndroidMkModule(ctx SingletonContext, mod blueprint.Module, makeNames androidMkMakeNames) (androidMkWriteFunc, error) {
 	defer func() {
 		if r := recover(); r != nil {
 			panic(fmt.Errorf("%s in translateAndroidMkModule for module %s variant %s",
@@ -757,14 +881,16 @@ func translateAndroidMkModule(ctx SingletonContext, w io.Writer, mod blueprint.M
 	// Additional cases here require review for correct license propagation to make.
 	switch x := mod.(type) {
 	case AndroidMkDataProvider:
-		return translateAndroidModule(ctx, w, mod, x)
+		return translateAndroidModule(ctx, mod, x, makeNames)
 	case bootstrap.GoBinaryTool:
-		return translateGoBinaryModule(ctx, w, mod, x)
+		return func(w io.Writer, _ androidMkMakeNames) {
+			translateGoBinaryModule(ctx, w, mod, x)
+		}, nil
 	case AndroidMkEntriesProvider:
-		return translateAndroidMkEntriesModule(ctx, w, mod, x)
+		return translateAndroidMkEntriesModule(ctx, mod, x, makeNames)
 	default:
 		// Not exported to make so no make variables to set.
-		return nil
+		return nil, nil
 	}
 }
 
@@ -808,12 +934,12 @@ func (data *AndroidMkData) fillInData(ctx fillInEntriesContext, mod blueprint.Mo
 
 // A support func for the deprecated AndroidMkDataProvider interface. Use AndroidMkEntryProvider
 // instead.
-func translateAndroidModule(ctx SingletonContext, w io.Writer, mod blueprint.Module,
-	provider AndroidMkDataProvider) error {
+func translateAndroidModule(ctx SingletonContext, mod blueprint.Module,
+	provider AndroidMkDataProvider, makeNames androidMkMakeNames) (androidMkWriteFunc, error) {
 
 	amod := mod.(Module).base()
 	if shouldSkipAndroidMkProcessing(amod) {
-		return nil
+		return nil, nil
 	}
 
 	data := provider.AndroidMk()
@@ -822,6 +948,7 @@ func translateAndroidModule(ctx SingletonContext, w io.Writer, mod blueprint.Mod
 	}
 
 	data.fillInData(ctx, mod)
+	makeNames.add(mod, &data.Entries)
 
 	prefix := ""
 	if amod.ArchSpecific() {
@@ -863,15 +990,20 @@ func translateAndroidModule(ctx SingletonContext, w io.Writer, mod blueprint.Mod
 		case "*sysprop.syspropLibrary": // license properties written
 		default:
 			if !ctx.Config().IsEnvFalse("ANDROID_REQUIRE_LICENSES") {
-				return fmt.Errorf("custom make rules not allowed for %q (%q) module %q", ctx.ModuleType(mod), reflect.TypeOf(mod), ctx.ModuleName(mod))
+				return nil, fmt.Errorf("custom make rules not allowed for %q (%q) module %q", ctx.ModuleType(mod), reflect.TypeOf(mod), ctx.ModuleName(mod))
 			}
 		}
-		data.Custom(w, name, prefix, blueprintDir, data)
-	} else {
-		WriteAndroidMkData(w, data)
 	}
 
-	return nil
+	return func(w io.Writer, makeNames androidMkMakeNames) {
+		data.Entries.resolveRequired(mod, makeNames)
+		data.Required = data.Entries.Required
+		if data.Custom != nil {
+			data.Custom(w, name, prefix, blueprintDir, data)
+		} else {
+			WriteAndroidMkData(w, data)
+		}
+	}, nil
 }
 
 // A support func for the deprecated AndroidMkDataProvider interface. Use AndroidMkEntryProvider
@@ -896,19 +1028,27 @@ func WriteAndroidMkData(w io.Writer, data AndroidMkData) {
 	fmt.Fprintln(w, "include "+data.Include)
 }
 
-func translateAndroidMkEntriesModule(ctx SingletonContext, w io.Writer, mod blueprint.Module,
-	provider AndroidMkEntriesProvider) error {
+func translateAndroidMkEntriesModule(ctx SingletonContext, mod blueprint.Module,
+	provider AndroidMkEntriesProvider, makeNames androidMkMakeNames) (androidMkWriteFunc, error) {
 	if shouldSkipAndroidMkProcessing(mod.(Module).base()) {
-		return nil
+		return nil, nil
 	}
 
 	// Any new or special cases here need review to verify correct propagation of license information.
-	for _, entries := range provider.AndroidMkEntries() {
-		entries.fillInEntries(ctx, mod)
-		entries.write(w)
+	entriesList := provider.AndroidMkEntries()
+	for i := range entriesList {
+		entriesList[i].fillInEntries(ctx, mod)
+	}
+	if len(entriesList) > 0 {
+		makeNames.add(mod, &entriesList[0])
 	}
 
-	return nil
+	return func(w io.Writer, makeNames androidMkMakeNames) {
+		for i := range entriesList {
+			entriesList[i].resolveRequired(mod, makeNames)
+			entriesList[i].write(w)
+		}
+	}, nil
 }
 
 func ShouldSkipAndroidMkProcessing(module Module) bool {
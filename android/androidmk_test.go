@@ -777,3 +777,222 @@ func TestGetDistContributions(t *testing.T) {
 		},
 	})
 }
+
+type orderedEntriesModule struct {
+	ModuleBase
+}
+
+func (m *orderedEntriesModule) GenerateAndroidBuildActions(ctx ModuleContext) {}
+
+func (m *orderedEntriesModule) AndroidMkEntries() []AndroidMkEntries {
+	return []AndroidMkEntries{
+		{
+			Class:      "ETC",
+			OutputFile: OptionalPathForPath(PathForTesting(m.Name() + ".out")),
+		},
+	}
+}
+
+func orderedEntriesModuleFactory() Module {
+	module := &orderedEntriesModule{}
+	InitAndroidModule(module)
+	return module
+}
+
+func orderedEntriesArchModuleFactory() Module {
+	module := &orderedEntriesModule{}
+	InitAndroidArchModule(module, HostAndDeviceSupported, MultilibBoth)
+	return module
+}
+
+func TestAndroidMkSingleton_DeterministicOutput(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		// Device modules are not exported on Mac, so this test doesn't work.
+		t.SkipNow()
+	}
+
+	bps := []string{`
+		custom {
+			name: "foo",
+		}
+
+		custom_arch {
+			name: "bar",
+			host_supported: true,
+		}
+
+		custom {
+			name: "baz",
+		}
+	`, `
+		custom {
+			name: "baz",
+		}
+
+		custom_arch {
+			name: "bar",
+			host_supported: true,
+		}
+
+		custom {
+			name: "foo",
+		}
+	`}
+
+	moduleTypes := [][]string{{"custom", "custom_arch"}, {"custom_arch", "custom"}}
+	factories := map[string]ModuleFactory{
+		"custom":      orderedEntriesModuleFactory,
+		"custom_arch": orderedEntriesArchModuleFactory,
+	}
+
+	var contents [][]byte
+	for i, bp := range bps {
+		moduleTypes := moduleTypes[i]
+		result := GroupFixturePreparers(
+			PrepareForTestWithArchMutator,
+			PrepareForTestWithAndroidMk,
+			FixtureRegisterWithContext(func(ctx RegistrationContext) {
+				for _, moduleType := range moduleTypes {
+					ctx.RegisterModuleType(moduleType, factories[moduleType])
+				}
+			}),
+			FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.DeviceProduct = proptools.StringPtr("bar")
+			}),
+			FixtureWithRootAndroidBp(bp),
+		).RunTest(t)
+
+		singleton := result.SingletonForTests("androidmk").Singleton().(*androidMkSingleton)
+		contents = append(contents, singleton.contentsForTesting)
+	}
+
+	if len(contents[0]) == 0 {
+		t.Fatalf("expected androidmk output")
+	}
+	AssertStringEquals(t, "androidmk output", string(contents[0]), string(contents[1]))
+}
+
+type imageEntriesModule struct {
+	ModuleBase
+
+	properties struct {
+		Vendor_available *bool
+	}
+}
+
+func (m *imageEntriesModule) GenerateAndroidBuildActions(ctx ModuleContext) {}
+
+func (m *imageEntriesModule) ImageMutatorBegin(ctx BaseModuleContext) {}
+
+func (m *imageEntriesModule) CoreVariantNeeded(ctx BaseModuleContext) bool {
+	return !m.SocSpecific()
+}
+
+func (m *imageEntriesModule) RamdiskVariantNeeded(ctx BaseModuleContext) bool { return false }
+
+func (m *imageEntriesModule) VendorRamdiskVariantNeeded(ctx BaseModuleContext) bool { return false }
+
+func (m *imageEntriesModule) DebugRamdiskVariantNeeded(ctx BaseModuleContext) bool { return false }
+
+func (m *imageEntriesModule) RecoveryVariantNeeded(ctx BaseModuleContext) bool { return false }
+
+func (m *imageEntriesModule) ExtraImageVariations(ctx BaseModuleContext) []string {
+	if m.SocSpecific() || proptools.Bool(m.properties.Vendor_available) {
+		return []string{"vendor"}
+	}
+	return nil
+}
+
+func (m *imageEntriesModule) SetImageVariation(ctx BaseModuleContext, variation string, module Module) {
+}
+
+func (m *imageEntriesModule) AndroidMkEntries() []AndroidMkEntries {
+	subName := ""
+	if m.ImageVariation().Variation == "vendor" && proptools.Bool(m.properties.Vendor_available) {
+		subName = ".vendor"
+	}
+	return []AndroidMkEntries{
+		{
+			Class:      "ETC",
+			SubName:    subName,
+			OutputFile: OptionalPathForPath(PathForTesting(m.Name() + subName + ".out")),
+		},
+	}
+}
+
+func imageEntriesModuleFactory() Module {
+	module := &imageEntriesModule{}
+	module.AddProperties(&module.properties)
+	InitAndroidArchModule(module, DeviceSupported, MultilibFirst)
+	return module
+}
+
+func TestAndroidMkSingleton_RequiredAcrossImages(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		// Device modules are not exported on Mac, so this test doesn't work.
+		t.SkipNow()
+	}
+
+	bp := `
+		image_module {
+			name: "system_app",
+			required: ["vendor_lib", "vendor_available_lib", "system_lib", "make_module"],
+		}
+
+		image_module {
+			name: "vendor_bin",
+			vendor: true,
+			required: ["vendor_lib", "vendor_available_lib", "system_lib", "make_module"],
+		}
+
+		image_module {
+			name: "vendor_lib",
+			vendor: true,
+		}
+
+		image_module {
+			name: "vendor_available_lib",
+			vendor_available: true,
+		}
+
+		image_module {
+			name: "system_lib",
+		}
+	`
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithAndroidMk,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("image_module", imageEntriesModuleFactory)
+		}),
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.DeviceProduct = proptools.StringPtr("bar")
+		}),
+		FixtureWithRootAndroidBp(bp),
+	).RunTest(t)
+
+	singleton := result.SingletonForTests("androidmk").Singleton().(*androidMkSingleton)
+	contents := string(singleton.contentsForTesting)
+
+	requiredModules := func(makeName string) string {
+		t.Helper()
+		for _, module := range strings.Split(contents, "include $(CLEAR_VARS)") {
+			if !strings.Contains(module, "\nLOCAL_MODULE := "+makeName+"\n") {
+				continue
+			}
+			for _, line := range strings.Split(module, "\n") {
+				if strings.HasPrefix(line, "LOCAL_REQUIRED_MODULES := ") {
+					return strings.TrimPrefix(line, "LOCAL_REQUIRED_MODULES := ")
+				}
+			}
+		}
+		t.Fatalf("no LOCAL_REQUIRED_MODULES found for %q in:\n%s", makeName, contents)
+		return ""
+	}
+
+	AssertStringEquals(t, "system->vendor required modules",
+		"vendor_lib vendor_available_lib system_lib make_module", requiredModules("system_app"))
+	AssertStringEquals(t, "vendor->system required modules",
+		"vendor_lib vendor_available_lib.vendor system_lib make_module", requiredModules("vendor_bin"))
+}
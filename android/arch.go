@@ -441,6 +441,66 @@ func bp2buildArchPathDepsMutator(ctx BottomUpMutatorContext) {
 	addPathDepsForProps(ctx, properties)
 }
 
+// HostLibcModule is implemented by modules that can select the libc they are built against on
+// Linux hosts.  When the build OS is musl, glibc host targets are configured too, and these modules
+// get a variant for each of the libcs they support.  Other modules are only built against the libc
+// of the build OS.
+type HostLibcModule interface {
+	Module
+
+	// HostLibc returns the libc the host variants of the module are built against: "glibc",
+	// "musl", "both", or "" for the libc of the build OS.
+	HostLibc() string
+}
+
+// HostLibcSupports returns true if a module with the given HostLibc value is built against the
+// libc of the given Linux host OS.
+func HostLibcSupports(config Config, libc string, os OsType) bool {
+	switch libc {
+	case "glibc":
+		return os == Linux
+	case "musl":
+		return os == LinuxMusl
+	case "both":
+		return os == Linux || os == LinuxMusl
+	default:
+		return os == config.BuildOS
+	}
+}
+
+// filterHostLibcOsTypes removes the Linux host OS types whose libc the module is not built against
+// from osTypes.
+func filterHostLibcOsTypes(ctx BottomUpMutatorContext, module Module, osTypes []OsType) []OsType {
+	libc := ""
+	if m, ok := module.(HostLibcModule); ok {
+		libc = m.HostLibc()
+		if !InList(libc, []string{"", "glibc", "musl", "both"}) {
+			ctx.PropertyErrorf("host_libc", "must be \"glibc\", \"musl\" or \"both\", not %q", libc)
+			libc = ""
+		}
+	}
+
+	var ret []OsType
+	linuxHost, linuxHostSupported := false, false
+	for _, os := range osTypes {
+		if os == Linux || os == LinuxMusl {
+			linuxHost = true
+			if !HostLibcSupports(ctx.Config(), libc, os) {
+				continue
+			}
+			linuxHostSupported = true
+		}
+		ret = append(ret, os)
+	}
+
+	// Report a libc that has no host targets rather than silently disabling the host variants.
+	if linuxHost && !linuxHostSupported {
+		ctx.PropertyErrorf("host_libc", "%q is not available, the build OS is %s and there are no %s host targets",
+			libc, ctx.Config().BuildOS, libc)
+	}
+	return ret
+}
+
 // osMutator splits an arch-specific module into a variant for each OS that is enabled for the
 // module.  It uses the HostOrDevice value passed to InitAndroidArchModule and the
 // device_supported and host_supported properties to determine which OsTypes are enabled for this
@@ -491,6 +551,8 @@ func osMutator(bpctx blueprint.BottomUpMutatorContext) {
 		}
 	}
 
+	moduleOSList = filterHostLibcOsTypes(mctx, module, moduleOSList)
+
 	// If there are no supported OSes then disable the module.
 	if len(moduleOSList) == 0 {
 		base.Disable()
@@ -517,10 +579,20 @@ func osMutator(bpctx blueprint.BottomUpMutatorContext) {
 
 	// Create the variations, annotate each one with which OS it was created for, and
 	// squash the appropriate OS-specific properties into the top level properties.
+	buildOS := mctx.Config().BuildOS
+	builtForBuildOS := InList(buildOS.String(), osNames)
 	modules := mctx.CreateVariations(osNames...)
 	for i, m := range modules {
 		m.base().commonProperties.CompileOS = moduleOSList[i]
 		m.base().setOSProperties(mctx)
+
+		// A module that is built against both libcs is only exported to Make and installed for
+		// the libc of the build OS, the variant for the other libc is only used by its dependants,
+		// which link it statically.
+		if os := moduleOSList[i]; (os == Linux || os == LinuxMusl) && os != buildOS && builtForBuildOS {
+			m.base().HideFromMake()
+			m.base().SkipInstall()
+		}
 	}
 
 	if createCommonOSVariant {
@@ -1145,164 +1217,146 @@ func (m *ModuleBase) setOSProperties(ctx BottomUpMutatorContext) {
 			continue
 		}
 		for _, archProperties := range m.archProperties[i] {
-			archPropValues := reflect.ValueOf(archProperties).Elem()
+			for _, osProperties := range getOSProperties(ctx, archProperties, os) {
+				mergePropertyStruct(ctx, genProps, osProperties)
+			}
+		}
+	}
+}
 
-			targetProp := archPropValues.FieldByName("Target").Elem()
+// archPropertiesContext is the context used to select the OS and arch specific properties of a
+// module.
+type archPropertiesContext interface {
+	ArchVariantContext
+	Config() Config
+}
 
-			// Handle host-specific properties in the form:
-			// target: {
-			//     host: {
-			//         key: value,
-			//     },
-			// },
-			if os.Class == Host {
-				field := "Host"
-				prefix := "target.host"
-				if hostProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
-					mergePropertyStruct(ctx, genProps, hostProperties)
-				}
-			}
+// Returns the structs corresponding to the properties specific to the given OS in archProperties.
+func getOSProperties(ctx archPropertiesContext, archProperties interface{}, os OsType) []reflect.Value {
+	result := make([]reflect.Value, 0)
+	archPropValues := reflect.ValueOf(archProperties).Elem()
 
-			// Handle target OS generalities of the form:
-			// target: {
-			//     bionic: {
-			//         key: value,
-			//     },
-			// }
-			if os.Linux() {
-				field := "Linux"
-				prefix := "target.linux"
-				if linuxProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
-					mergePropertyStruct(ctx, genProps, linuxProperties)
-				}
-			}
+	targetProp := archPropValues.FieldByName("Target").Elem()
 
-			if os.Linux() && os.Class == Host {
-				field := "Host_linux"
-				prefix := "target.host_linux"
-				if linuxProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
-					mergePropertyStruct(ctx, genProps, linuxProperties)
-				}
-			}
+	// Handle host-specific properties in the form:
+	// target: {
+	//     host: {
+	//         key: value,
+	//     },
+	// },
+	if os.Class == Host {
+		field := "Host"
+		prefix := "target.host"
+		if hostProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
+			result = append(result, hostProperties)
+		}
+	}
 
-			if os.Bionic() {
-				field := "Bionic"
-				prefix := "target.bionic"
-				if bionicProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
-					mergePropertyStruct(ctx, genProps, bionicProperties)
-				}
-			}
+	// Handle target OS generalities of the form:
+	// target: {
+	//     bionic: {
+	//         key: value,
+	//     },
+	// }
+	if os.Linux() {
+		field := "Linux"
+		prefix := "target.linux"
+		if linuxProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
+			result = append(result, linuxProperties)
+		}
+	}
 
-			if os == Linux {
-				field := "Glibc"
-				prefix := "target.glibc"
-				if bionicProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
-					mergePropertyStruct(ctx, genProps, bionicProperties)
-				}
-			}
+	if os.Linux() && os.Class == Host {
+		field := "Host_linux"
+		prefix := "target.host_linux"
+		if linuxProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
+			result = append(result, linuxProperties)
+		}
+	}
 
-			if os == LinuxMusl {
-				field := "Musl"
-				prefix := "target.musl"
-				if bionicProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
-					mergePropertyStruct(ctx, genProps, bionicProperties)
-				}
-			}
+	if os.Bionic() {
+		field := "Bionic"
+		prefix := "target.bionic"
+		if bionicProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
+			result = append(result, bionicProperties)
+		}
+	}
 
-			// Handle target OS properties in the form:
-			// target: {
-			//     linux_glibc: {
-			//         key: value,
-			//     },
-			//     not_windows: {
-			//         key: value,
-			//     },
-			//     android {
-			//         key: value,
-			//     },
-			// },
-			field := os.Field
-			prefix := "target." + os.Name
-			if osProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
-				mergePropertyStruct(ctx, genProps, osProperties)
-			}
+	if os == Linux {
+		field := "Glibc"
+		prefix := "target.glibc"
+		if bionicProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
+			result = append(result, bionicProperties)
+		}
+	}
 
-			if os.Class == Host && os != Windows {
-				field := "Not_windows"
-				prefix := "target.not_windows"
-				if notWindowsProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
-					mergePropertyStruct(ctx, genProps, notWindowsProperties)
-				}
-			}
+	if os == LinuxMusl {
+		field := "Musl"
+		prefix := "target.musl"
+		if bionicProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
+			result = append(result, bionicProperties)
+		}
+	}
 
-			// Handle 64-bit device properties in the form:
-			// target {
-			//     android64 {
-			//         key: value,
-			//     },
-			//     android32 {
-			//         key: value,
-			//     },
-			// },
-			// WARNING: this is probably not what you want to use in your blueprints file, it selects
-			// options for all targets on a device that supports 64-bit binaries, not just the targets
-			// that are being compiled for 64-bit.  Its expected use case is binaries like linker and
-			// debuggerd that need to know when they are a 32-bit process running on a 64-bit device
-			if os.Class == Device {
-				if ctx.Config().Android64() {
-					field := "Android64"
-					prefix := "target.android64"
-					if android64Properties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
-						mergePropertyStruct(ctx, genProps, android64Properties)
-					}
-				} else {
-					field := "Android32"
-					prefix := "target.android32"
-					if android32Properties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
-						mergePropertyStruct(ctx, genProps, android32Properties)
-					}
-				}
-			}
+	// Handle target OS properties in the form:
+	// target: {
+	//     linux_glibc: {
+	//         key: value,
+	//     },
+	//     not_windows: {
+	//         key: value,
+	//     },
+	//     android {
+	//         key: value,
+	//     },
+	// },
+	field := os.Field
+	prefix := "target." + os.Name
+	if osProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
+		result = append(result, osProperties)
+	}
+
+	if os.Class == Host && os != Windows {
+		field := "Not_windows"
+		prefix := "target.not_windows"
+		if notWindowsProperties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
+			result = append(result, notWindowsProperties)
 		}
 	}
-}
 
-// Returns the struct containing the properties specific to the given
-// architecture type. These look like this in Blueprint files:
-// arch: {
-//     arm64: {
-//         key: value,
-//     },
-// },
-// This struct will also contain sub-structs containing to the architecture/CPU
-// variants and features that themselves contain properties specific to those.
-func getArchTypeStruct(ctx ArchVariantContext, archProperties interface{}, archType ArchType) (reflect.Value, bool) {
-	archPropValues := reflect.ValueOf(archProperties).Elem()
-	archProp := archPropValues.FieldByName("Arch").Elem()
-	prefix := "arch." + archType.Name
-	return getChildPropertyStruct(ctx, archProp, archType.Name, prefix)
-}
-
-// Returns the struct containing the properties specific to a given multilib
-// value. These look like this in the Blueprint file:
-// multilib: {
-//     lib32: {
-//         key: value,
-//     },
-// },
-func getMultilibStruct(ctx ArchVariantContext, archProperties interface{}, archType ArchType) (reflect.Value, bool) {
-	archPropValues := reflect.ValueOf(archProperties).Elem()
-	multilibProp := archPropValues.FieldByName("Multilib").Elem()
-	return getChildPropertyStruct(ctx, multilibProp, archType.Multilib, "multilib."+archType.Multilib)
-}
+	// Handle 64-bit device properties in the form:
+	// target {
+	//     android64 {
+	//         key: value,
+	//     },
+	//     android32 {
+	//         key: value,
+	//     },
+	// },
+	// WARNING: this is probably not what you want to use in your blueprints file, it selects
+	// options for all targets on a device that supports 64-bit binaries, not just the targets
+	// that are being compiled for 64-bit.  Its expected use case is binaries like linker and
+	// debuggerd that need to know when they are a 32-bit process running on a 64-bit device
+	if os.Class == Device {
+		if ctx.Config().Android64() {
+			field := "Android64"
+			prefix := "target.android64"
+			if android64Properties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
+				result = append(result, android64Properties)
+			}
+		} else {
+			field := "Android32"
+			prefix := "target.android32"
+			if android32Properties, ok := getChildPropertyStruct(ctx, targetProp, field, prefix); ok {
+				result = append(result, android32Properties)
+			}
+		}
+	}
 
-func GetCompoundTargetField(os OsType, arch ArchType) string {
-	return os.Field + "_" + arch.Name
+	return result
 }
 
-// Returns the structs corresponding to the properties specific to the given
-// architecture and OS in archProperties.
-func getArchProperties(ctx BaseMutatorContext, archProperties interface{}, arch Arch, os OsType, nativeBridgeEnabled bool) []reflect.Value {
+func getArchProperties(ctx archPropertiesContext, archProperties interface{}, arch Arch, os OsType, nativeBridgeEnabled bool) []reflect.Value {
 	result := make([]reflect.Value, 0)
 	archPropValues := reflect.ValueOf(archProperties).Elem()
 
@@ -1491,6 +1545,48 @@ func (m *ModuleBase) setArchProperties(ctx BottomUpMutatorContext) {
 	}
 }
 
+// archVariantPropertiesForTarget returns a copy of propertySet, which must be one of the property
+// structs of the module, with the OS and arch specific properties for os and archType merged in the
+// same way as for a variant of an arch specific module.  It is used by modules that are not split
+// into variants but select their properties according to the target of another module.  Arch
+// variants, cpu variants and arch features are not taken into account.
+func (m *ModuleBase) archVariantPropertiesForTarget(ctx archPropertiesContext, propertySet interface{},
+	os OsType, archType ArchType) interface{} {
+
+	dst := proptools.CloneProperties(reflect.ValueOf(propertySet)).Interface()
+	for i, generalProp := range m.GetProperties() {
+		if generalProp != propertySet || m.archProperties[i] == nil {
+			continue
+		}
+		for _, archProperties := range m.archProperties[i] {
+			for _, osProperties := range getOSProperties(ctx, archProperties, os) {
+				mergePropertyStruct(ctx, dst, osProperties)
+			}
+		}
+		for _, archProperties := range m.archProperties[i] {
+			for _, archProps := range getArchProperties(ctx, archProperties, Arch{ArchType: archType}, os, false) {
+				mergePropertyStruct(ctx, dst, archProps)
+			}
+		}
+	}
+	return dst
+}
+
+// hasArchVariantProperties returns true if any OS or arch specific property of the module is set.
+func (m *ModuleBase) hasArchVariantProperties() bool {
+	for _, archProperties := range m.archProperties {
+		for _, archProperty := range archProperties {
+			root := archProperty.(*archPropRoot)
+			for _, props := range []interface{}{root.Arch, root.Multilib, root.Target} {
+				if !reflect.ValueOf(props).IsNil() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 // determineBuildOS stores the OS and architecture used for host targets used during the build into
 // config based on the runtime OS and architecture determined by Go and the product configuration.
 func determineBuildOS(config *config) {
@@ -1608,6 +1704,15 @@ func decodeTargetProductVariables(config *config) (map[OsType][]Target, error) {
 		addTarget(targetConfig{os: config.BuildOS, archName: *variables.HostSecondaryArch, nativeBridgeEnabled: NativeBridgeDisabled})
 	}
 
+	// When the build OS is musl, glibc host targets are added for the modules that are built
+	// against glibc, see HostLibcModule.
+	if config.BuildOS == LinuxMusl {
+		addTarget(targetConfig{os: Linux, archName: *variables.HostArch, nativeBridgeEnabled: NativeBridgeDisabled})
+		if variables.HostSecondaryArch != nil && *variables.HostSecondaryArch != "" {
+			addTarget(targetConfig{os: Linux, archName: *variables.HostSecondaryArch, nativeBridgeEnabled: NativeBridgeDisabled})
+		}
+	}
+
 	// Optional cross-compiled host targets, generally Windows.
 	if String(variables.CrossHost) != "" {
 		crossHostOs := osByName(*variables.CrossHost)
@@ -16,6 +16,7 @@ package android
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/google/blueprint/proptools"
@@ -29,6 +30,15 @@ func init() {
 type buildinfoPropProperties struct {
 	// Whether this module is directly installable to one of the partitions. Default: true.
 	Installable *bool
+
+	// Extra properties to write after the generated ones, in the form "key=value".  A key may only
+	// contain letters, digits, '.', '_' and '-', and may not be set more than once, or be one of the
+	// generated properties.
+	Props []string
+
+	// Files containing properties that are appended to the generated build.prop.  The build fails
+	// if they set a property that is already set, unless one of the two is optional.
+	Footer_files []string `android:"path"`
 }
 
 type buildinfoPropModule struct {
@@ -37,6 +47,7 @@ type buildinfoPropModule struct {
 	properties buildinfoPropProperties
 
 	outputFilePath OutputPath
+	jsonFilePath   OutputPath
 	installPath    InstallPath
 }
 
@@ -48,102 +59,114 @@ func (p *buildinfoPropModule) installable() bool {
 
 // OutputFileProducer
 func (p *buildinfoPropModule) OutputFiles(tag string) (Paths, error) {
-	if tag != "" {
+	switch tag {
+	case "":
+		return Paths{p.outputFilePath}, nil
+	case ".json":
+		return Paths{p.jsonFilePath}, nil
+	default:
 		return nil, fmt.Errorf("unsupported tag %q", tag)
 	}
-	return Paths{p.outputFilePath}, nil
 }
 
 func (p *buildinfoPropModule) GenerateAndroidBuildActions(ctx ModuleContext) {
 	p.outputFilePath = PathForModuleOut(ctx, p.Name()).OutputPath
-	if !ctx.Config().KatiEnabled() {
-		WriteFileRule(ctx, p.outputFilePath, "# no buildinfo.prop if kati is disabled")
-		return
-	}
+	p.jsonFilePath = PathForModuleOut(ctx, p.Name()+".json").OutputPath
 
-	rule := NewRuleBuilder(pctx, ctx)
-	cmd := rule.Command().Text("(")
+	b := newBuildPropBuilder(ctx)
+
+	b.writeString("# begin build properties")
+	b.writeString("# autogenerated by build/soong/android/buildinfo_prop.go")
+
+	config := ctx.Config()
+
+	buildNumber := buildNumberFromFile(ctx)
+	date := buildDateCommand(config)
+	buildTags := buildVersionTags(config)
 
-	writeString := func(str string) {
-		cmd.Text(`echo "` + str + `" && `)
+	// The ro.build.id will be set dynamically by init, by appending the unique vbmeta digest.
+	if config.BoardUseVbmetaDigestInFingerprint() {
+		b.writeProp("ro.build.legacy.id", config.BuildId())
+	} else {
+		b.writeProp("ro.build.id?", config.BuildId())
 	}
+	b.writeProp("ro.build.display.id?", config.BuildDisplayId())
+	b.writeProp("ro.build.version.incremental", buildNumber)
+	b.writeProp("ro.build.version.sdk", config.PlatformSdkVersion().String())
+	b.writeProp("ro.build.version.preview_sdk", config.PlatformPreviewSdkVersion())
+	b.writeProp("ro.build.version.preview_sdk_fingerprint", config.PlatformPreviewSdkFingerprint())
+	b.writeProp("ro.build.version.codename", config.PlatformSdkCodename())
+	b.writeProp("ro.build.version.all_codenames", strings.Join(config.PlatformVersionActiveCodenames(), ","))
+	b.writeProp("ro.build.version.known_codenames", strings.Join(config.PlatformVersionKnownCodenames(), ","))
+	b.writeProp("ro.build.version.release", config.PlatformVersionLastStable())
+	b.writeProp("ro.build.version.release_or_codename", config.PlatformVersionName())
+	b.writeProp("ro.build.version.release_or_preview_display", config.PlatformDisplayVersion())
+	b.writeProp("ro.build.version.security_patch", config.PlatformSecurityPatch())
+	b.writeProp("ro.build.version.base_os", config.PlatformBaseOS())
+	b.writeProp("ro.build.version.min_supported_target_sdk", config.PlatformMinSupportedTargetSdkVersion())
+	b.writeProp("ro.build.date", "$("+date+")")
+	b.writeProp("ro.build.date.utc", "$("+date+" +%s)")
+	b.writeProp("ro.build.type", config.BuildVariant())
+	b.writeProp("ro.build.user", config.BuildUsername())
+	b.writeProp("ro.build.host", config.BuildHostname())
+	b.writeProp("ro.build.tags", buildTags)
+	b.writeProp("ro.build.flavor", config.BuildFlavor())
 
-	writeString("# begin build properties")
-	writeString("# autogenerated by build/soong/android/buildinfo_prop.go")
+	deviceConfig := ctx.DeviceConfig()
 
-	writeProp := func(key, value string) {
-		if strings.Contains(key, "=") {
-			panic(fmt.Errorf("wrong property key %q: key must not contain '='", key))
-		}
-		writeString(key + "=" + value)
+	// These values are deprecated, use "ro.product.cpu.abilist"
+	// instead (see below).
+	b.writeString("# ro.product.cpu.abi and ro.product.cpu.abi2 are obsolete,")
+	b.writeString("# use ro.product.cpu.abilist instead.")
+	b.writeProp("ro.product.cpu.abi", deviceConfig.CpuAbi())
+	if abi2 := deviceConfig.CpuAbi2(); abi2 != "" {
+		b.writeProp("ro.product.cpu.abi2", abi2)
 	}
+	b.writeProp("ro.product.cpu.abilist", strings.Join(deviceConfig.CpuAbiList(), ","))
+	b.writeProp("ro.product.cpu.abilist32", strings.Join(deviceConfig.CpuAbiList32(), ","))
+	b.writeProp("ro.product.cpu.abilist64", strings.Join(deviceConfig.CpuAbiList64(), ","))
 
-	config := ctx.Config()
+	if locale := config.ProductDefaultLocale(); locale != "" {
+		b.writeProp("ro.product.locale", locale)
+	}
+	b.writeProp("ro.wifi.channels", config.ProductDefaultWifiChannels())
+	b.writeString("# ro.build.product is obsolete; use ro.product.device")
+	b.writeProp("ro.build.product", config.DeviceName())
 
-	writeProp("ro.build.version.sdk", config.PlatformSdkVersion().String())
-	writeProp("ro.build.version.preview_sdk", config.PlatformPreviewSdkVersion())
-	writeProp("ro.build.version.codename", config.PlatformSdkCodename())
-	writeProp("ro.build.version.all_codenames", strings.Join(config.PlatformVersionActiveCodenames(), ","))
-	writeProp("ro.build.version.release", config.PlatformVersionLastStable())
-	writeProp("ro.build.version.release_or_codename", config.PlatformVersionName())
-	writeProp("ro.build.version.security_patch", config.PlatformSecurityPatch())
-	writeProp("ro.build.version.base_os", config.PlatformBaseOS())
-	writeProp("ro.build.version.min_supported_target_sdk", config.PlatformMinSupportedTargetSdkVersion())
-
-	if config.Eng() {
-		writeProp("ro.build.type", "eng")
-	} else if config.Debuggable() {
-		writeProp("ro.build.type", "userdebug")
-	} else {
-		writeProp("ro.build.type", "user")
+	b.writeString("# Do not try to parse description or thumbprint")
+	b.writeProp("ro.build.description?", strings.Join([]string{
+		config.DeviceProduct() + "-" + config.BuildVariant(),
+		config.PlatformVersionName(),
+		config.BuildId(),
+		buildNumber,
+		buildTags,
+	}, " "))
+	if thumbprint := buildThumbprint(config, buildNumber); thumbprint != "" {
+		b.writeProp("ro.build.thumbprint", thumbprint)
 	}
 
-	// Currently, only a few properties are implemented to unblock microdroid use case.
-	// TODO(b/189164487): support below properties as well and replace build/make/tools/buildinfo.sh
-	/*
-		if $BOARD_USE_VBMETA_DIGTEST_IN_FINGERPRINT {
-			writeProp("ro.build.legacy.id", config.BuildID())
+	for _, prop := range p.properties.Props {
+		key, value, ok := splitBuildProp(prop)
+		if !ok {
+			ctx.PropertyErrorf("props", "%q is not of the form key=value", prop)
+		} else if !validBuildPropKey(key) {
+			ctx.PropertyErrorf("props", "property key %q may only contain letters, digits, '.', '_' and '-'", key)
+		} else if b.hasProp(key) {
+			ctx.PropertyErrorf("props", "property %q is set more than once", key)
 		} else {
-			writeProp("ro.build.id", config.BuildId())
-		}
-		writeProp("ro.build.display.id", $BUILD_DISPLAY_ID)
-		writeProp("ro.build.version.incremental", $BUILD_NUMBER)
-		writeProp("ro.build.version.preview_sdk_fingerprint", $PLATFORM_PREVIEW_SDK_FINGERPRINT)
-		writeProp("ro.build.version.known_codenames", $PLATFORM_VERSION_KNOWN_CODENAMES)
-		writeProp("ro.build.version.release_or_preview_display", $PLATFORM_DISPLAY_VERSION)
-		writeProp("ro.build.date", `$DATE`)
-		writeProp("ro.build.date.utc", `$DATE +%s`)
-		writeProp("ro.build.user", $BUILD_USERNAME)
-		writeProp("ro.build.host", $BUILD_HOSTNAME)
-		writeProp("ro.build.tags", $BUILD_VERSION_TAGS)
-		writeProp("ro.build.flavor", $TARGET_BUILD_FLAVOR)
-		// These values are deprecated, use "ro.product.cpu.abilist"
-		// instead (see below).
-		writeString("# ro.product.cpu.abi and ro.product.cpu.abi2 are obsolete,")
-		writeString("# use ro.product.cpu.abilist instead.")
-		writeProp("ro.product.cpu.abi", $TARGET_CPU_ABI)
-		if [ -n "$TARGET_CPU_ABI2" ] {
-			writeProp("ro.product.cpu.abi2", $TARGET_CPU_ABI2)
-		}
-
-		if [ -n "$PRODUCT_DEFAULT_LOCALE" ] {
-			writeProp("ro.product.locale", $PRODUCT_DEFAULT_LOCALE)
-		}
-		writeProp("ro.wifi.channels", $PRODUCT_DEFAULT_WIFI_CHANNELS)
-		writeString("# ro.build.product is obsolete; use ro.product.device")
-		writeProp("ro.build.product", $TARGET_DEVICE)
-
-		writeString("# Do not try to parse description or thumbprint")
-		writeProp("ro.build.description", $PRIVATE_BUILD_DESC)
-		if [ -n "$BUILD_THUMBPRINT" ] {
-			writeProp("ro.build.thumbprint", $BUILD_THUMBPRINT)
+			b.writeProp(key, escapeForEcho(value))
 		}
-	*/
+	}
 
-	writeString("# end build properties")
+	b.writeString("# end build properties")
 
-	cmd.Text("true) > ").Output(p.outputFilePath)
-	rule.Build("build.prop", "generating build.prop")
+	footerFiles := PathsForModuleSrc(ctx, p.properties.Footer_files)
+	b.appendFiles(footerFiles)
+	if len(footerFiles) > 0 {
+		// The properties of the footer files are only known at build time.
+		b.cmd.Validation(checkBuildPropDuplicates(ctx, p.outputFilePath))
+	}
+	b.build(p.outputFilePath, p.jsonFilePath)
 
 	if !p.installable() {
 		p.SkipInstall()
@@ -157,6 +180,130 @@ func (f *buildinfoPropModule) GenerateSingletonBuildActions(ctx SingletonContext
 	// does nothing; buildinfo_prop is a singeton because two buildinfo modules don't make sense.
 }
 
+// buildPropBuilder generates a build.prop file by echoing each of its lines.
+type buildPropBuilder struct {
+	rule *RuleBuilder
+	cmd  *RuleBuilderCommand
+
+	// The keys of the properties written so far, without any trailing '?'.
+	keys map[string]bool
+}
+
+func newBuildPropBuilder(ctx ModuleContext) *buildPropBuilder {
+	rule := NewRuleBuilder(pctx, ctx)
+	return &buildPropBuilder{
+		rule: rule,
+		cmd:  rule.Command().Text("("),
+		keys: make(map[string]bool),
+	}
+}
+
+func (b *buildPropBuilder) writeString(str string) {
+	b.cmd.Text(`echo "` + str + `" && `)
+}
+
+func (b *buildPropBuilder) writeProp(key, value string) {
+	if strings.Contains(key, "=") {
+		panic(fmt.Errorf("wrong property key %q: key must not contain '='", key))
+	}
+	b.keys[strings.TrimSuffix(key, "?")] = true
+	b.writeString(key + "=" + value)
+}
+
+// hasProp returns true if a property with the key has already been written, whether or not
+// either of them is optional.
+func (b *buildPropBuilder) hasProp(key string) bool {
+	return b.keys[strings.TrimSuffix(key, "?")]
+}
+
+// appendFiles appends the contents of the files after the lines written so far.
+func (b *buildPropBuilder) appendFiles(files Paths) {
+	if len(files) > 0 {
+		b.cmd.Text("cat").Inputs(files).Text("&& ")
+	}
+}
+
+// build writes the build.prop file to output, and the same properties as a JSON object to
+// jsonOutput.
+func (b *buildPropBuilder) build(output, jsonOutput WritablePath) {
+	b.cmd.Text("true) > ").Output(output)
+	b.rule.Command().BuiltTool("build_prop_to_json").
+		FlagWithInput("--input ", output).
+		FlagWithOutput("--output ", jsonOutput)
+	b.rule.Build("build.prop", "generating build.prop")
+}
+
+// checkBuildPropDuplicates returns a timestamp file written by a rule that checks that the
+// build.prop file doesn't set a property more than once, unless one of the values is optional.
+func checkBuildPropDuplicates(ctx ModuleContext, buildProp Path) Path {
+	timestamp := PathForModuleOut(ctx, "check_build_prop_duplicates.timestamp")
+	rule := NewRuleBuilder(pctx, ctx)
+	rule.Command().BuiltTool("check_build_prop_duplicates").
+		FlagWithInput("--input ", buildProp).
+		FlagWithOutput("--output ", timestamp)
+	rule.Build("check_build_prop_duplicates", "checking "+buildProp.Base()+" for duplicate properties")
+	return timestamp
+}
+
+// splitBuildProp splits a "key=value" property.  The key must not be empty.
+func splitBuildProp(prop string) (key, value string, ok bool) {
+	i := strings.IndexByte(prop, '=')
+	if i < 0 {
+		return "", "", false
+	}
+	key = strings.TrimSpace(prop[:i])
+	return key, prop[i+1:], key != ""
+}
+
+var buildPropKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+\??$`)
+
+// validBuildPropKey returns true if the key, without the '?' of an optional property, only
+// contains characters that are valid in a system property name.
+func validBuildPropKey(key string) bool {
+	return buildPropKeyRegexp.MatchString(key)
+}
+
+// escapeForEcho escapes a string so that it is written literally inside the double quotes of
+// an echo command.
+func escapeForEcho(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(s)
+}
+
+// buildNumberFromFile returns a shell expression that evaluates to the build number.  The build
+// number file is read without depending on it, so that build.prop isn't regenerated on every
+// incremental build.
+func buildNumberFromFile(ctx PathContext) string {
+	return "$(cat " + ctx.Config().BuildNumberFile(ctx).String() + ")"
+}
+
+// buildDateCommand returns the date command for the time of the current build, which like the
+// build number is read from a file without depending on it.
+func buildDateCommand(config Config) string {
+	return "date -d @$(cat " + config.BuildDateTimeFile() + ")"
+}
+
+func buildVersionTags(config Config) string {
+	return strings.Join(config.BuildVersionTags(), ",")
+}
+
+// buildFingerprint returns the fingerprint of the current build, composed the same way as
+// BUILD_FINGERPRINT in build/make/core/sysprop.mk.
+func buildFingerprint(config Config, buildNumber string) string {
+	return config.ProductBrand() + "/" + config.DeviceProduct() + "/" + config.DeviceName() + ":" +
+		config.PlatformVersionName() + "/" + config.BuildId() + "/" + buildNumber + ":" +
+		config.BuildVariant() + "/" + buildVersionTags(config)
+}
+
+// buildThumbprint returns the thumbprint of the current build, which is only set when the
+// product has OEM thumbprint properties.
+func buildThumbprint(config Config, buildNumber string) string {
+	if len(config.OemThumbprintProperties()) == 0 {
+		return ""
+	}
+	return config.PlatformVersionName() + "/" + config.BuildId() + "/" + buildNumber + ":" +
+		config.BuildVariant() + "/" + buildVersionTags(config)
+}
+
 func (p *buildinfoPropModule) AndroidMkEntries() []AndroidMkEntries {
 	return []AndroidMkEntries{AndroidMkEntries{
 		Class:      "ETC",
@@ -172,8 +319,8 @@ func (p *buildinfoPropModule) AndroidMkEntries() []AndroidMkEntries {
 }
 
 // buildinfo_prop module generates a build.prop file, which contains a set of common
-// system/build.prop properties, such as ro.build.version.*.  Not all properties are implemented;
-// currently this module is only for microdroid.
+// system/build.prop properties, such as ro.build.id and ro.build.version.*.  It is the Soong
+// equivalent of build/make/tools/buildinfo.sh, and doesn't need Kati.
 func buildinfoPropFactory() SingletonModule {
 	module := &buildinfoPropModule{}
 	module.AddProperties(&module.properties)
@@ -0,0 +1,242 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var prepareForBuildinfoPropTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterSingletonModuleType("buildinfo_prop", buildinfoPropFactory)
+	}),
+	FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+		variables.BuildId = stringPtr("TQ1A.221205.011")
+		variables.BuildDisplayId = stringPtr("TQ1A.221205.011 release-keys")
+		variables.BuildVersionTags = []string{"test-keys", "release-keys"}
+		variables.Platform_version_name = stringPtr("13")
+		variables.Platform_version_last_stable = stringPtr("13")
+		variables.Platform_display_version = stringPtr("13")
+		variables.Platform_sdk_version = intPtr(33)
+		variables.Platform_sdk_codename = stringPtr("REL")
+		variables.Platform_version_active_codenames = nil
+		variables.Platform_version_known_codenames = []string{"Base", "Tiramisu"}
+		variables.Platform_preview_sdk_version = stringPtr("0")
+		variables.Platform_preview_sdk_fingerprint = stringPtr("REL")
+		variables.Platform_security_patch = stringPtr("2022-12-05")
+		variables.Platform_min_supported_target_sdk_version = stringPtr("23")
+		variables.DeviceName = stringPtr("generic_arm64")
+		variables.DeviceProduct = stringPtr("aosp_arm64")
+		variables.ProductDefaultWifiChannels = stringPtr("")
+	}),
+	FixtureMergeEnv(map[string]string{
+		"BUILD_DATETIME_FILE": "out/build_date.txt",
+		"BUILD_USERNAME":      "builder",
+		"BUILD_HOSTNAME":      "buildhost",
+	}),
+	FixtureWithRootAndroidBp(`
+		buildinfo_prop {
+			name: "buildinfo.prop",
+		}
+	`),
+)
+
+var buildinfoPropEchoRegexp = regexp.MustCompile(`echo "([^"]*)" && `)
+
+// buildPropLines returns the lines written to the build.prop generated by a module, in order.
+func buildPropLines(t *testing.T, module TestingModule) []string {
+	t.Helper()
+	rule := module.Rule("build.prop")
+	var lines []string
+	for _, match := range buildinfoPropEchoRegexp.FindAllStringSubmatch(rule.RuleParams.Command, -1) {
+		lines = append(lines, match[1])
+	}
+	return lines
+}
+
+// buildinfoPropLines returns the lines written to the build.prop generated by the buildinfo_prop
+// module, in order.
+func buildinfoPropLines(t *testing.T, result *TestResult) []string {
+	t.Helper()
+	return buildPropLines(t, result.ModuleForTests("buildinfo.prop", ""))
+}
+
+func TestBuildinfoProp(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForBuildinfoPropTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.Eng = boolPtr(true)
+			variables.ProductDefaultLocale = stringPtr("en-US")
+		}),
+	).RunTest(t)
+
+	AssertDeepEquals(t, "build.prop", []string{
+		"# begin build properties",
+		"# autogenerated by build/soong/android/buildinfo_prop.go",
+		"ro.build.id?=TQ1A.221205.011",
+		"ro.build.display.id?=TQ1A.221205.011 release-keys",
+		"ro.build.version.incremental=$$(cat out/soong/build_number.txt)",
+		"ro.build.version.sdk=33",
+		"ro.build.version.preview_sdk=0",
+		"ro.build.version.preview_sdk_fingerprint=REL",
+		"ro.build.version.codename=REL",
+		"ro.build.version.all_codenames=",
+		"ro.build.version.known_codenames=Base,Tiramisu",
+		"ro.build.version.release=13",
+		"ro.build.version.release_or_codename=13",
+		"ro.build.version.release_or_preview_display=13",
+		"ro.build.version.security_patch=2022-12-05",
+		"ro.build.version.base_os=",
+		"ro.build.version.min_supported_target_sdk=23",
+		"ro.build.date=$$(date -d @$$(cat out/build_date.txt))",
+		"ro.build.date.utc=$$(date -d @$$(cat out/build_date.txt) +%s)",
+		"ro.build.type=eng",
+		"ro.build.user=builder",
+		"ro.build.host=buildhost",
+		"ro.build.tags=release-keys,test-keys",
+		"ro.build.flavor=aosp_arm64-eng",
+		"# ro.product.cpu.abi and ro.product.cpu.abi2 are obsolete,",
+		"# use ro.product.cpu.abilist instead.",
+		"ro.product.cpu.abi=arm64-v8a",
+		"ro.product.cpu.abilist=arm64-v8a,armeabi-v7a",
+		"ro.product.cpu.abilist32=armeabi-v7a",
+		"ro.product.cpu.abilist64=arm64-v8a",
+		"ro.product.locale=en-US",
+		"ro.wifi.channels=",
+		"# ro.build.product is obsolete; use ro.product.device",
+		"ro.build.product=generic_arm64",
+		"# Do not try to parse description or thumbprint",
+		"ro.build.description?=aosp_arm64-eng 13 TQ1A.221205.011 $$(cat out/soong/build_number.txt) release-keys,test-keys",
+		"# end build properties",
+	}, buildinfoPropLines(t, result))
+}
+
+func TestBuildinfoPropUser(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForBuildinfoPropTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.Eng = boolPtr(false)
+			variables.Debuggable = boolPtr(false)
+			variables.SanitizeDevice = []string{"address"}
+			variables.OemThumbprintProperties = []string{"ro.product.brand"}
+		}),
+	).RunTest(t)
+
+	lines := buildinfoPropLines(t, result)
+	AssertStringListContains(t, "build type", lines, "ro.build.type=user")
+	AssertStringListContains(t, "build flavor", lines, "ro.build.flavor=aosp_arm64-user_asan")
+	AssertStringListContains(t, "build description", lines,
+		"ro.build.description?=aosp_arm64-user 13 TQ1A.221205.011 $$(cat out/soong/build_number.txt) release-keys,test-keys")
+	AssertStringDoesNotContain(t, "locale", strings.Join(lines, "\n"), "ro.product.locale")
+	AssertStringListContains(t, "build thumbprint", lines,
+		"ro.build.thumbprint=13/TQ1A.221205.011/$$(cat out/soong/build_number.txt):user/release-keys,test-keys")
+}
+
+func TestBuildinfoPropVbmetaDigestInFingerprint(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForBuildinfoPropTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.BoardUseVbmetaDigestInFingerprint = boolPtr(true)
+		}),
+	).RunTest(t)
+
+	lines := buildinfoPropLines(t, result)
+	AssertStringListContains(t, "legacy build id", lines, "ro.build.legacy.id=TQ1A.221205.011")
+	AssertStringListDoesNotContain(t, "build id", lines, "ro.build.id?=TQ1A.221205.011")
+}
+
+func TestBuildinfoPropExtraProps(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForBuildinfoPropTest,
+		FixtureAddFile("footer.prop", []byte("ro.carrier.footer=true\n")),
+	).RunTestWithBp(t, `
+		buildinfo_prop {
+			name: "buildinfo.prop",
+			props: [
+				"ro.com.google.clientidbase=android-google",
+				"ro.carrier=\"unknown\"",
+			],
+			footer_files: ["footer.prop"],
+		}
+	`)
+
+	lines := buildinfoPropLines(t, result)
+	AssertDeepEquals(t, "last lines", []string{
+		"ro.com.google.clientidbase=android-google",
+		`ro.carrier=\"unknown\"`,
+		"# end build properties",
+	}, lines[len(lines)-3:])
+
+	rule := result.ModuleForTests("buildinfo.prop", "").Rule("build.prop")
+	AssertStringDoesContain(t, "footer", rule.RuleParams.Command, `&& cat footer.prop && true) >`)
+	AssertPathsRelativeToTopEquals(t, "inputs", []string{"footer.prop"}, rule.Implicits)
+
+	// The properties of the footer files are checked for duplicates by their own rule.
+	check := result.ModuleForTests("buildinfo.prop", "").Rule("check_build_prop_duplicates")
+	AssertPathsRelativeToTopEquals(t, "check inputs",
+		[]string{"out/soong/.intermediates/buildinfo.prop/buildinfo.prop"}, check.Inputs)
+	AssertPathsRelativeToTopEquals(t, "validations",
+		[]string{"out/soong/.intermediates/buildinfo.prop/check_build_prop_duplicates.timestamp"}, rule.Validations)
+}
+
+func TestBuildinfoPropConflictingProp(t *testing.T) {
+	prepareForBuildinfoPropTest.
+		ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+			`props: property "ro.build.type" is set more than once`,
+			`props: property "ro.carrier" is set more than once`,
+			`props: "ro.carrier" is not of the form key=value`,
+			`props: property key "ro.carrier name" may only contain letters, digits, '.', '_' and '-'`,
+			`props: property key "ro.\$\(id\)" may only contain letters, digits, '.', '_' and '-'`,
+		})).
+		RunTestWithBp(t, `
+			buildinfo_prop {
+				name: "buildinfo.prop",
+				props: [
+					"ro.build.type=user",
+					"ro.carrier=unknown",
+					"ro.carrier=wifi-only",
+					"ro.carrier",
+					"ro.carrier name=unknown",
+					"ro.$(id)=unknown",
+				],
+			}
+		`)
+}
+
+func TestBuildinfoPropJson(t *testing.T) {
+	result := prepareForBuildinfoPropTest.RunTest(t)
+
+	// Both outputs are written by the same rule.  That their contents match is checked by
+	// build_prop_to_json_test, which converts a build.prop like the one generated here.
+	module := result.ModuleForTests("buildinfo.prop", "")
+	rule := module.Rule("build.prop")
+	AssertPathRelativeToTopEquals(t, "output", "out/soong/.intermediates/buildinfo.prop/buildinfo.prop", rule.Output)
+	AssertPathsRelativeToTopEquals(t, "implicit outputs",
+		[]string{"out/soong/.intermediates/buildinfo.prop/buildinfo.prop.json"}, rule.ImplicitOutputs)
+
+	producer := module.Module().(OutputFileProducer)
+	jsonFiles, err := producer.OutputFiles(".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertPathsRelativeToTopEquals(t, "json output files",
+		[]string{"out/soong/.intermediates/buildinfo.prop/buildinfo.prop.json"}, jsonFiles)
+
+	_, err = producer.OutputFiles(".txt")
+	AssertErrorMessageEquals(t, "unsupported tag", `unsupported tag ".txt"`, err)
+}
@@ -158,6 +158,8 @@ type config struct {
 	mockBpList string
 
 	runningAsBp2Build              bool
+	collectingModuleDepsGraph      bool
+	queryingModules                bool
 	bp2buildPackageConfig          bp2BuildConversionAllowlist
 	Bp2buildSoongConfigDefinitions soongconfig.Bp2BuildSoongConfigDefinitions
 
@@ -169,6 +171,11 @@ type config struct {
 	// regenerate build.ninja.
 	ninjaFileDepsSet sync.Map
 
+	// The recorder for the product_variables properties used by modules, only set when
+	// SOONG_RECORD_PRODUCT_VARIABLES is set.
+	productVariablesRecorderOnce     sync.Once
+	productVariablesRecorderInstance *productVariablesRecorder
+
 	OncePer
 }
 
@@ -430,6 +437,16 @@ func modifyTestConfigForMusl(config Config) {
 	config.BuildOSCommonTarget = getCommonTargets(config.Targets[config.BuildOS])[0]
 }
 
+// modifyTestConfigForMuslAndGlibc configures a musl build OS, along with the glibc host targets
+// that are used by modules that are built against glibc, see HostLibcModule.
+func modifyTestConfigForMuslAndGlibc(config Config) {
+	modifyTestConfigForMusl(config)
+	config.Targets[Linux] = []Target{
+		{Linux, Arch{ArchType: X86_64}, NativeBridgeDisabled, "", "", false},
+		{Linux, Arch{ArchType: X86}, NativeBridgeDisabled, "", "", false},
+	}
+}
+
 // TestArchConfig returns a Config object suitable for using for tests that
 // need to run the arch mutator.
 func TestArchConfig(buildDir string, env map[string]string, bp string, fs map[string][]byte) Config {
@@ -703,6 +720,18 @@ func (c *config) KatiEnabled() bool {
 	return c.katiEnabled
 }
 
+// SetQueryingModules marks soong_build as only querying the modules, e.g. to write the module graph
+// or the module index, instead of writing the build.ninja file. Checks that only matter to the
+// build itself are skipped then.
+func (c Config) SetQueryingModules() {
+	c.config.queryingModules = true
+}
+
+// QueryingModules returns true if SetQueryingModules was called.
+func (c *config) QueryingModules() bool {
+	return c.queryingModules
+}
+
 func (c *config) BuildId() string {
 	return String(c.productVariables.BuildId)
 }
@@ -718,6 +747,64 @@ func (c *config) BuildNumberFile(ctx PathContext) Path {
 	return PathForOutput(ctx, String(c.productVariables.BuildNumberFile))
 }
 
+// BuildDisplayId returns the user-visible build ID, ro.build.display.id.
+func (c *config) BuildDisplayId() string {
+	return String(c.productVariables.BuildDisplayId)
+}
+
+// BuildVersionTags returns the sorted tags of the current build, e.g.
+// release-keys or test-keys.
+func (c *config) BuildVersionTags() []string {
+	return SortedUniqueStrings(c.productVariables.BuildVersionTags)
+}
+
+// BoardUseVbmetaDigestInFingerprint returns true if init appends the vbmeta
+// digest to the build ID at runtime, in which case the build ID is written as
+// ro.build.legacy.id instead of ro.build.id.
+func (c *config) BoardUseVbmetaDigestInFingerprint() bool {
+	return Bool(c.productVariables.BoardUseVbmetaDigestInFingerprint)
+}
+
+// BuildDateTimeFile returns the path to the text file containing the
+// timestamp of the current build, in seconds since the epoch.
+//
+// As with BuildNumberFile, rules should read from this file without depending
+// on it.
+func (c *config) BuildDateTimeFile() string {
+	return c.Getenv("BUILD_DATETIME_FILE")
+}
+
+// BuildUsername returns the name of the user running the current build.
+func (c *config) BuildUsername() string {
+	return c.Getenv("BUILD_USERNAME")
+}
+
+// BuildHostname returns the name of the host running the current build.
+func (c *config) BuildHostname() string {
+	return c.Getenv("BUILD_HOSTNAME")
+}
+
+// BuildVariant returns the variant of the current build: eng, userdebug or
+// user.
+func (c *config) BuildVariant() string {
+	if c.Eng() {
+		return "eng"
+	} else if c.Debuggable() {
+		return "userdebug"
+	}
+	return "user"
+}
+
+// BuildFlavor returns the flavor of the current build, the product name and
+// the build variant, with an _asan suffix for address sanitized builds.
+func (c *config) BuildFlavor() string {
+	flavor := c.DeviceProduct() + "-" + c.BuildVariant()
+	if InList("address", c.SanitizeDevice()) {
+		flavor += "_asan"
+	}
+	return flavor
+}
+
 // DeviceName returns the name of the current device target.
 // TODO: take an AndroidModuleContext to select the device name for multi-device builds
 func (c *config) DeviceName() string {
@@ -781,6 +868,18 @@ func (c *config) PlatformVersionLastStable() string {
 	return String(c.productVariables.Platform_version_last_stable)
 }
 
+func (c *config) PlatformVersionKnownCodenames() []string {
+	return c.productVariables.Platform_version_known_codenames
+}
+
+func (c *config) PlatformDisplayVersion() string {
+	return String(c.productVariables.Platform_display_version)
+}
+
+func (c *config) PlatformPreviewSdkFingerprint() string {
+	return String(c.productVariables.Platform_preview_sdk_fingerprint)
+}
+
 func (c *config) MinSupportedSdkVersion() ApiLevel {
 	return uncheckedFinalApiLevel(19)
 }
@@ -846,6 +945,32 @@ func (c *config) PlatformVersionActiveCodenames() []string {
 	return c.productVariables.Platform_version_active_codenames
 }
 
+func (c *config) ProductBrand() string {
+	return String(c.productVariables.ProductBrand)
+}
+
+func (c *config) ProductManufacturer() string {
+	return String(c.productVariables.ProductManufacturer)
+}
+
+func (c *config) ProductModel() string {
+	return String(c.productVariables.ProductModel)
+}
+
+func (c *config) ProductDefaultLocale() string {
+	return String(c.productVariables.ProductDefaultLocale)
+}
+
+func (c *config) ProductDefaultWifiChannels() string {
+	return String(c.productVariables.ProductDefaultWifiChannels)
+}
+
+// OemThumbprintProperties returns the OEM properties that are allowed to vary between builds
+// with the same thumbprint.  ro.build.thumbprint is only written when this is not empty.
+func (c *config) OemThumbprintProperties() []string {
+	return c.productVariables.OemThumbprintProperties
+}
+
 func (c *config) ProductAAPTConfig() []string {
 	return c.productVariables.AAPTConfig
 }
@@ -862,6 +987,18 @@ func (c *config) ProductAAPTPrebuiltDPI() []string {
 	return c.productVariables.AAPTPrebuiltDPI
 }
 
+// ProductAppSetAbis returns the architectures whose splits are extracted from the APK sets of
+// android_app_set modules that use the product split filters.
+func (c *config) ProductAppSetAbis() []string {
+	return c.productVariables.AppSetAbis
+}
+
+// ProductAppSetLocales returns the locales whose splits are extracted from the APK sets of
+// android_app_set modules that use the product split filters.
+func (c *config) ProductAppSetLocales() []string {
+	return c.productVariables.AppSetLocales
+}
+
 func (c *config) DefaultAppCertificateDir(ctx PathContext) SourcePath {
 	defaultCert := String(c.productVariables.DefaultAppCertificate)
 	if defaultCert != "" {
@@ -1163,6 +1300,58 @@ func (c *deviceConfig) Arches() []Arch {
 	return arches
 }
 
+// CpuAbi returns the primary ABI of the primary device architecture, TARGET_CPU_ABI in Make.
+func (c *deviceConfig) CpuAbi() string {
+	if abis := c.primaryCpuAbis(); len(abis) > 0 {
+		return abis[0]
+	}
+	return ""
+}
+
+// CpuAbi2 returns the secondary ABI of the primary device architecture, TARGET_CPU_ABI2 in Make,
+// or an empty string if there is none.
+func (c *deviceConfig) CpuAbi2() string {
+	if abis := c.primaryCpuAbis(); len(abis) > 1 {
+		return abis[1]
+	}
+	return ""
+}
+
+func (c *deviceConfig) primaryCpuAbis() []string {
+	for _, target := range c.config.Targets[Android] {
+		if target.NativeBridge == NativeBridgeDisabled {
+			return target.Arch.Abi
+		}
+	}
+	return nil
+}
+
+// CpuAbiList returns all of the ABIs supported by the device, 64-bit ABIs first, as listed in
+// ro.product.cpu.abilist.  ABIs of native bridge architectures are not included.
+func (c *deviceConfig) CpuAbiList() []string {
+	return append(c.CpuAbiList64(), c.CpuAbiList32()...)
+}
+
+// CpuAbiList32 returns the 32-bit ABIs supported by the device.
+func (c *deviceConfig) CpuAbiList32() []string {
+	return c.cpuAbiList("lib32")
+}
+
+// CpuAbiList64 returns the 64-bit ABIs supported by the device.
+func (c *deviceConfig) CpuAbiList64() []string {
+	return c.cpuAbiList("lib64")
+}
+
+func (c *deviceConfig) cpuAbiList(multilib string) []string {
+	var abis []string
+	for _, target := range c.config.Targets[Android] {
+		if target.NativeBridge == NativeBridgeDisabled && target.Arch.ArchType.Multilib == multilib {
+			abis = append(abis, target.Arch.Abi...)
+		}
+	}
+	return abis
+}
+
 func (c *deviceConfig) BinderBitness() string {
 	is32BitBinder := c.config.productVariables.Binder32bit
 	if is32BitBinder != nil && *is32BitBinder {
@@ -1306,6 +1495,14 @@ func (c *deviceConfig) NativeCoverageEnabledForPath(path string) bool {
 	return coverage
 }
 
+// NativeCoverageNeverEnabledForPath returns whether native code coverage must never be used for
+// path, as listed in the NativeCoverageNeverPaths product variable.  Unlike the paths in
+// NativeCoverageExcludePaths, modules in these paths don't get a coverage variant at all, so they
+// also link against the uninstrumented variants of their static libraries.
+func (c *deviceConfig) NativeCoverageNeverEnabledForPath(path string) bool {
+	return HasAnyPrefix(path, c.config.productVariables.NativeCoverageNeverPaths)
+}
+
 func (c *deviceConfig) AfdoAdditionalProfileDirs() []string {
 	return c.config.productVariables.AfdoAdditionalProfileDirs
 }
@@ -1355,6 +1552,25 @@ func (c *deviceConfig) OverridePackageNameFor(name string) string {
 	return name
 }
 
+// OverrideTargetSdkVersionFor returns the targetSdkVersion that PRODUCT_TARGET_SDK_VERSION_OVERRIDES
+// sets for the apps in the given directory. When more than one path prefix matches the directory
+// the longest one wins. It returns an error if an override rule is malformed.
+func (c *deviceConfig) OverrideTargetSdkVersionFor(dir string) (targetSdkVersion string, overridden bool, err error) {
+	longestPrefix := -1
+	for _, o := range c.config.productVariables.TargetSdkVersionOverrides {
+		split := strings.Split(o, ":")
+		if len(split) != 2 || split[0] == "" || split[1] == "" {
+			return "", false, fmt.Errorf("invalid override rule %q in PRODUCT_TARGET_SDK_VERSION_OVERRIDES should be <path_prefix>:<target_sdk_version>", o)
+		}
+		prefix := strings.TrimSuffix(split[0], "/")
+		if (dir == prefix || strings.HasPrefix(dir, prefix+"/")) && len(prefix) > longestPrefix {
+			longestPrefix = len(prefix)
+			targetSdkVersion, overridden = split[1], true
+		}
+	}
+	return targetSdkVersion, overridden, nil
+}
+
 func findOverrideValue(overrides []string, name string, errorMsg string) (newValue string, overridden bool) {
 	if overrides == nil || len(overrides) == 0 {
 		return "", false
@@ -1376,6 +1592,20 @@ func (c *deviceConfig) ApexGlobalMinSdkVersionOverride() string {
 	return String(c.config.productVariables.ApexGlobalMinSdkVersionOverride)
 }
 
+// AaptFlagsForPath returns the aapt2 link flags that the product adds to the apps in the given
+// directory, as the entries of AaptFlagsByPath for the directory and its parents, the most
+// specific one last.
+func (c *config) AaptFlagsForPath(path string) [][]string {
+	var entries [][]string
+	// A parent directory sorts before its subdirectories.
+	for _, dir := range SortedStringKeys(c.productVariables.AaptFlagsByPath) {
+		if path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/") {
+			entries = append(entries, c.productVariables.AaptFlagsByPath[dir])
+		}
+	}
+	return entries
+}
+
 func (c *config) IntegerOverflowDisabledForPath(path string) bool {
 	if len(c.productVariables.IntegerOverflowExcludePaths) == 0 {
 		return false
@@ -1383,6 +1613,14 @@ func (c *config) IntegerOverflowDisabledForPath(path string) bool {
 	return HasAnyPrefix(path, c.productVariables.IntegerOverflowExcludePaths)
 }
 
+func (c *config) IntegerOverflowEnabledForPath(path string) bool {
+	if len(c.productVariables.IntegerOverflowIncludePaths) == 0 {
+		return false
+	}
+	return HasAnyPrefix(path, c.productVariables.IntegerOverflowIncludePaths) &&
+		!c.IntegerOverflowDisabledForPath(path)
+}
+
 func (c *config) CFIDisabledForPath(path string) bool {
 	if len(c.productVariables.CFIExcludePaths) == 0 {
 		return false
@@ -1494,6 +1732,13 @@ func (c *config) MissingUsesLibraries() []string {
 	return c.productVariables.MissingUsesLibraries
 }
 
+// AllowMissingOptionalUsesLibraries returns true if optional <uses-library> dependencies that are
+// not defined in the build should be treated as if they were listed in MissingUsesLibraries,
+// rather than causing an error.
+func (c *config) AllowMissingOptionalUsesLibraries() bool {
+	return Bool(c.productVariables.AllowMissingOptionalUsesLibraries)
+}
+
 func (c *deviceConfig) DeviceArch() string {
 	return String(c.config.productVariables.DeviceArch)
 }
@@ -1685,6 +1930,45 @@ func (c *deviceConfig) BuildBrokenInputDir(name string) bool {
 	return InList(name, c.config.productVariables.BuildBrokenInputDirModules)
 }
 
+// BuildBrokenDuplicateInstallPath returns true if more than one Soong module is allowed to install
+// to the given path, which is relative to the product out directory and so starts with the
+// partition, e.g. "system/etc/foo.xml".
+func (c *deviceConfig) BuildBrokenDuplicateInstallPath(path string) bool {
+	return InList(path, c.config.productVariables.BuildBrokenDuplicateInstallPaths)
+}
+
+// BuildBrokenUninstallableRequiredModule returns true if the given module is allowed to require
+// modules that are not installed for the OS class of its required, host_required or
+// target_required property.
+func (c *deviceConfig) BuildBrokenUninstallableRequiredModule(name string) bool {
+	return InList(name, c.config.productVariables.BuildBrokenUninstallableRequiredModules)
+}
+
+// ProductPackages returns the modules listed in PRODUCT_PACKAGES, which are installed to the
+// partitions of the product together with their dependencies.
+func (c *deviceConfig) ProductPackages() []string {
+	return c.config.productVariables.ProductPackages
+}
+
+// PartitionSizeLimits returns the maximum size in bytes of the partitions that have one, keyed by
+// the partition name, e.g. "system".
+func (c *deviceConfig) PartitionSizeLimits() map[string]int64 {
+	return c.config.productVariables.PartitionSizeLimits
+}
+
+// PartitionSizeReportMarginPercent returns the margin below the size limit of a partition, as a
+// percentage of the limit, within which the sizes of the modules installed to the partition are
+// reported.
+func (c *deviceConfig) PartitionSizeReportMarginPercent() int {
+	return proptools.IntDefault(c.config.productVariables.PartitionSizeReportMarginPercent, 5)
+}
+
+// EnforcePartitionSizeLimits returns true if a partition within the report margin of its size
+// limit fails the build instead of producing a warning.
+func (c *deviceConfig) EnforcePartitionSizeLimits() bool {
+	return Bool(c.config.productVariables.EnforcePartitionSizeLimits)
+}
+
 func (c *deviceConfig) RequiresInsecureExecmemForSwiftshader() bool {
 	return c.config.productVariables.RequiresInsecureExecmemForSwiftshader
 }
@@ -2038,6 +2322,53 @@ func (c *config) ApexBootJars() ConfiguredJarList {
 	return c.productVariables.ApexBootJars
 }
 
+var moduleFamilySelectionKey = NewOnceKey("moduleFamilySelection")
+
+type moduleFamilySelection struct {
+	usePrebuilts map[string]bool
+	err          error
+}
+
+// ModuleFamilyUsesPrebuilts returns whether the product selected the prebuilt modules of the given
+// module family, and whether it selected the source or the prebuilt modules of that family at all.
+func (c *config) ModuleFamilyUsesPrebuilts(family string) (usePrebuilts bool, selected bool, err error) {
+	selection := c.Once(moduleFamilySelectionKey, func() interface{} {
+		usePrebuilts := make(map[string]bool)
+		for _, entry := range c.productVariables.ModuleFamilySelection {
+			i := strings.LastIndex(entry, ":")
+			if i <= 0 {
+				return moduleFamilySelection{err: fmt.Errorf("malformed ModuleFamilySelection entry %q, expected <family>:source or <family>:prebuilt", entry)}
+			}
+			switch mode := entry[i+1:]; mode {
+			case "source":
+				usePrebuilts[entry[:i]] = false
+			case "prebuilt":
+				usePrebuilts[entry[:i]] = true
+			default:
+				return moduleFamilySelection{err: fmt.Errorf("invalid mode %q for module family %q in ModuleFamilySelection, expected source or prebuilt", mode, entry[:i])}
+			}
+		}
+		return moduleFamilySelection{usePrebuilts: usePrebuilts}
+	}).(moduleFamilySelection)
+	if selection.err != nil {
+		return false, false, selection.err
+	}
+	usePrebuilts, selected = selection.usePrebuilts[family]
+	return usePrebuilts, selected, nil
+}
+
+// BootclasspathFragmentExtraContents returns the (apex, jar) pairs that the product adds to the
+// contents of bootclasspath_fragment modules, keyed by the name of the bootclasspath_fragment.
+func (c *config) BootclasspathFragmentExtraContents() map[string]ConfiguredJarList {
+	return c.productVariables.BootclasspathFragmentExtraContents
+}
+
+// AllowBootclasspathFragmentExtraContents returns true if the board allows the product to add jars
+// to the contents of bootclasspath_fragment modules.
+func (c *config) AllowBootclasspathFragmentExtraContents() bool {
+	return Bool(c.productVariables.BoardAllowBootclasspathFragmentExtraContents)
+}
+
 func (c *config) RBEWrapper() string {
 	return c.GetenvWithDefault("RBE_WRAPPER", remoteexec.DefaultWrapperPath)
 }
@@ -2046,3 +2377,45 @@ func (c *config) RBEWrapper() string {
 func (c *config) UseHostMusl() bool {
 	return Bool(c.productVariables.HostMusl)
 }
+
+// GenerateLinkerMapFiles returns true if linker map files should be generated for all native
+// binaries and shared libraries.
+func (c *config) GenerateLinkerMapFiles() bool {
+	return c.productVariables.GenerateLinkerMapFiles
+}
+
+// BinarySizeBaseline returns the path to a checked-in JSON file containing the expected sizes of
+// the modules that generate linker map files, or an empty string if sizes should not be checked.
+func (c *config) BinarySizeBaseline() string {
+	return String(c.productVariables.BinarySizeBaseline)
+}
+
+// BinarySizeMaxGrowthPercent returns the percentage by which a module may grow beyond its size in
+// the BinarySizeBaseline before it is reported.
+func (c *config) BinarySizeMaxGrowthPercent() int {
+	if c.productVariables.BinarySizeMaxGrowthPercent == nil {
+		return 5
+	}
+	return *c.productVariables.BinarySizeMaxGrowthPercent
+}
+
+// BinarySizeCheckWarnOnly returns true if modules that grow beyond BinarySizeMaxGrowthPercent
+// should only be reported as warnings instead of failing the build.
+func (c *config) BinarySizeCheckWarnOnly() bool {
+	return c.productVariables.BinarySizeCheckWarnOnly
+}
+
+// LinuxBionicHostToolTarget returns the linux_bionic Target that host tools should be built for
+// and true if the build has been configured to prefer host tools built against bionic, or false
+// if host tools should always use BuildOSTarget.
+func (c *config) LinuxBionicHostToolTarget() (Target, bool) {
+	if !Bool(c.productVariables.HostBionicTools) {
+		return Target{}, false
+	}
+	for _, target := range c.Targets[LinuxBionic] {
+		if target.Arch.ArchType == c.BuildOSTarget.Arch.ArchType {
+			return target, true
+		}
+	}
+	return Target{}, false
+}
@@ -16,6 +16,7 @@ package android
 
 import (
 	"fmt"
+	"io/ioutil"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -119,6 +120,86 @@ func TestBootJarsMarshaling(t *testing.T) {
 	verifyProductVariableMarshaling(t, v)
 }
 
+func TestBoardVariablesMissingFromConfigFile(t *testing.T) {
+	// soong.variables files written before the board variables were added must keep parsing, and
+	// the accessors must return the defaults.
+	dir := t.TempDir()
+	path := filepath.Join(dir, "soong.variables")
+	err := ioutil.WriteFile(path, []byte(`{"Platform_sdk_version": 33, "Platform_sdk_final": true}`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &config{}
+	if err := loadFromConfigFile(&c.productVariables, path); err != nil {
+		t.Fatalf("Couldn't load product config: %q", err)
+	}
+
+	AssertBoolEquals(t, "BoardUseVbmetaDigestInFingerprint", false, c.BoardUseVbmetaDigestInFingerprint())
+	AssertStringEquals(t, "ProductDefaultLocale", "", c.ProductDefaultLocale())
+	AssertStringEquals(t, "ProductDefaultWifiChannels", "", c.ProductDefaultWifiChannels())
+	AssertIntEquals(t, "BuildVersionTags", 0, len(c.BuildVersionTags()))
+}
+
+func TestDeviceConfigCpuAbis(t *testing.T) {
+	testCases := []struct {
+		name    string
+		targets []Target
+
+		abi, abi2            string
+		abiList32, abiList64 []string
+		abiList              string
+	}{
+		{
+			name: "arm64 and arm",
+			targets: []Target{
+				{Android, Arch{ArchType: Arm64, Abi: []string{"arm64-v8a"}}, NativeBridgeDisabled, "", "", false},
+				{Android, Arch{ArchType: Arm, Abi: []string{"armeabi-v7a", "armeabi"}}, NativeBridgeDisabled, "", "", false},
+			},
+			abi:       "arm64-v8a",
+			abiList32: []string{"armeabi-v7a", "armeabi"},
+			abiList64: []string{"arm64-v8a"},
+			abiList:   "arm64-v8a,armeabi-v7a,armeabi",
+		},
+		{
+			name: "arm only",
+			targets: []Target{
+				{Android, Arch{ArchType: Arm, Abi: []string{"armeabi-v7a", "armeabi"}}, NativeBridgeDisabled, "", "", false},
+			},
+			abi:       "armeabi-v7a",
+			abi2:      "armeabi",
+			abiList32: []string{"armeabi-v7a", "armeabi"},
+			abiList:   "armeabi-v7a,armeabi",
+		},
+		{
+			name: "native bridge",
+			targets: []Target{
+				{Android, Arch{ArchType: X86_64, Abi: []string{"x86_64"}}, NativeBridgeDisabled, "", "", false},
+				{Android, Arch{ArchType: X86, Abi: []string{"x86"}}, NativeBridgeDisabled, "", "", false},
+				{Android, Arch{ArchType: Arm64, Abi: []string{"arm64-v8a"}}, NativeBridgeEnabled, "x86_64", "arm64", false},
+				{Android, Arch{ArchType: Arm, Abi: []string{"armeabi-v7a"}}, NativeBridgeEnabled, "x86", "arm", false},
+			},
+			abi:       "x86_64",
+			abiList32: []string{"x86"},
+			abiList64: []string{"x86_64"},
+			abiList:   "x86_64,x86",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &config{Targets: map[OsType][]Target{Android: tc.targets}}
+			deviceConfig := DeviceConfig{&deviceConfig{config: c}}
+
+			AssertStringEquals(t, "CpuAbi", tc.abi, deviceConfig.CpuAbi())
+			AssertStringEquals(t, "CpuAbi2", tc.abi2, deviceConfig.CpuAbi2())
+			AssertArrayString(t, "CpuAbiList32", tc.abiList32, deviceConfig.CpuAbiList32())
+			AssertArrayString(t, "CpuAbiList64", tc.abiList64, deviceConfig.CpuAbiList64())
+			AssertStringEquals(t, "CpuAbiList", tc.abiList, strings.Join(deviceConfig.CpuAbiList(), ","))
+		})
+	}
+}
+
 func assertStringEquals(t *testing.T, expected, actual string) {
 	if actual != expected {
 		t.Errorf("expected %q found %q", expected, actual)
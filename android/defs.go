@@ -80,6 +80,14 @@ var (
 			Description: "touch $out",
 		})
 
+	// A rule for checking an init.rc file with host_init_verifier.
+	hostInitVerifier = pctx.AndroidStaticRule("hostInitVerifier",
+		blueprint.RuleParams{
+			Command:     "$tool --property-contexts=$propertyContexts $in && touch $out",
+			Description: "host_init_verifier $in",
+		},
+		"tool", "propertyContexts")
+
 	// A symlink rule.
 	Symlink = pctx.AndroidStaticRule("Symlink",
 		blueprint.RuleParams{
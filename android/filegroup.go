@@ -42,6 +42,12 @@ type bazelFilegroupAttributes struct {
 
 // ConvertWithBp2build performs bp2build conversion of filegroup
 func (fg *fileGroup) ConvertWithBp2build(ctx TopDownMutatorContext) {
+	// The target and arch specific srcs are not supported by bp2build yet, so leave the filegroup
+	// unconverted rather than converting only the common srcs.
+	if fg.hasTargetSpecificSrcs() {
+		return
+	}
+
 	srcs := bazel.MakeLabelListAttribute(
 		BazelLabelForModuleSrcExcludes(ctx, fg.properties.Srcs, fg.properties.Exclude_srcs))
 
@@ -78,10 +84,13 @@ func (fg *fileGroup) ConvertWithBp2build(ctx TopDownMutatorContext) {
 }
 
 type fileGroupProperties struct {
-	// srcs lists files that will be included in this filegroup
-	Srcs []string `android:"path"`
+	// srcs lists files that will be included in this filegroup.  The target and arch specific
+	// srcs and exclude_srcs only apply when the filegroup is referenced by a module built for a
+	// matching target.  The filegroup itself is not split into variants, the srcs are selected
+	// according to the os and arch of the module that references the filegroup.
+	Srcs []string `android:"path,arch_variant"`
 
-	Exclude_srcs []string `android:"path"`
+	Exclude_srcs []string `android:"path,arch_variant"`
 
 	// The base path to the files.  May be used by other modules to determine which portion
 	// of the path to use.  For example, when a filegroup is used as data in a cc_test rule,
@@ -94,14 +103,25 @@ type fileGroupProperties struct {
 	Export_to_make_var *string
 }
 
+// fileGroupTargetKey identifies the set of target specific properties that apply to a module.
+type fileGroupTargetKey struct {
+	os       OsType
+	archType ArchType
+}
+
 type fileGroup struct {
 	ModuleBase
 	BazelModuleBase
 	properties fileGroupProperties
 	srcs       Paths
+
+	// targetSrcs contains the srcs for each os and arch type, or nil if the filegroup has no target
+	// specific properties.
+	targetSrcs map[fileGroupTargetKey]Paths
 }
 
 var _ SourceFileProducer = (*fileGroup)(nil)
+var _ TargetSourceFileProducer = (*fileGroup)(nil)
 
 // filegroup contains a list of files that are referenced by other modules
 // properties (such as "srcs") using the syntax ":<name>". filegroup are
@@ -109,6 +129,11 @@ var _ SourceFileProducer = (*fileGroup)(nil)
 func FileGroupFactory() Module {
 	module := &fileGroup{}
 	module.AddProperties(&module.properties)
+	// The arch variant properties of the srcs are added without making the filegroup arch specific,
+	// they are selected for the target of each module that references the filegroup.  This is done
+	// before InitAndroidModule so that the common properties don't get arch variants that would be
+	// ignored.
+	initArchModule(module)
 	InitAndroidModule(module)
 	InitBazelModule(module)
 	return module
@@ -143,10 +168,55 @@ func (fg *fileGroup) maybeGenerateBazelBuildActions(ctx ModuleContext) {
 	fg.srcs = bazelOuts
 }
 
-func (fg *fileGroup) GenerateAndroidBuildActions(ctx ModuleContext) {
-	fg.srcs = PathsForModuleSrcExcludes(ctx, fg.properties.Srcs, fg.properties.Exclude_srcs)
+// hasTargetSpecificSrcs returns true if any of the target or arch specific properties are set.
+func (fg *fileGroup) hasTargetSpecificSrcs() bool {
+	return fg.hasArchVariantProperties()
+}
+
+// fileGroupTargets calls f for each os and arch type, including the common arch type used by
+// modules that are not arch specific, with the properties of the filegroup for that target.
+func (fg *fileGroup) fileGroupTargets(ctx archPropertiesContext, f func(key fileGroupTargetKey, props *fileGroupProperties)) {
+	for _, os := range osTypeList {
+		if os == CommonOS {
+			continue
+		}
+		for _, archType := range append([]ArchType{Common}, osArchTypeMap[os]...) {
+			props := fg.archVariantPropertiesForTarget(ctx, &fg.properties, os, archType)
+			f(fileGroupTargetKey{os, archType}, props.(*fileGroupProperties))
+		}
+	}
+}
+
+// DepsMutator adds the dependencies on the modules referenced by the target and arch specific
+// srcs, which are not seen by the pathDepsMutator as the filegroup is not split into variants.
+func (fg *fileGroup) DepsMutator(ctx BottomUpMutatorContext) {
+	if fg.hasTargetSpecificSrcs() {
+		var props []interface{}
+		fg.fileGroupTargets(ctx, func(key fileGroupTargetKey, p *fileGroupProperties) {
+			props = append(props, p)
+		})
+		addPathDepsForProps(ctx, props)
+	}
+}
+
+func (fg *fileGroup) expandSrcs(ctx ModuleContext, srcs, excludes []string) Paths {
+	paths := PathsForModuleSrcExcludes(ctx, srcs, excludes)
 	if fg.properties.Path != nil {
-		fg.srcs = PathsWithModuleSrcSubDir(ctx, fg.srcs, String(fg.properties.Path))
+		paths = PathsWithModuleSrcSubDir(ctx, paths, String(fg.properties.Path))
+	}
+	return paths
+}
+
+func (fg *fileGroup) GenerateAndroidBuildActions(ctx ModuleContext) {
+	fg.srcs = fg.expandSrcs(ctx, fg.properties.Srcs, fg.properties.Exclude_srcs)
+
+	if fg.hasTargetSpecificSrcs() {
+		// The srcs of every combination of os and arch type are expanded up front as the paths can
+		// only be resolved while the filegroup's own build actions are being generated.
+		fg.targetSrcs = make(map[fileGroupTargetKey]Paths)
+		fg.fileGroupTargets(ctx, func(key fileGroupTargetKey, p *fileGroupProperties) {
+			fg.targetSrcs[key] = fg.expandSrcs(ctx, p.Srcs, p.Exclude_srcs)
+		})
 	}
 
 	fg.maybeGenerateBazelBuildActions(ctx)
@@ -156,6 +226,15 @@ func (fg *fileGroup) Srcs() Paths {
 	return append(Paths{}, fg.srcs...)
 }
 
+// SrcsForTarget returns the srcs for a module built for target, including the srcs from the
+// matching target and arch properties.
+func (fg *fileGroup) SrcsForTarget(target Target) Paths {
+	if srcs, ok := fg.targetSrcs[fileGroupTargetKey{target.Os, target.Arch.ArchType}]; ok {
+		return append(Paths{}, srcs...)
+	}
+	return fg.Srcs()
+}
+
 func (fg *fileGroup) MakeVars(ctx MakeVarsModuleContext) {
 	if makeVar := String(fg.properties.Export_to_make_var); makeVar != "" {
 		ctx.StrictRaw(makeVar, strings.Join(fg.srcs.Strings(), " "))
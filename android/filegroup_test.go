@@ -0,0 +1,142 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type filegroupTargetTestModule struct {
+	ModuleBase
+	props struct {
+		Srcs []string `android:"path"`
+	}
+
+	rels []string
+}
+
+func filegroupTargetTestModuleFactory() Module {
+	module := &filegroupTargetTestModule{}
+	module.AddProperties(&module.props)
+	InitAndroidArchModule(module, HostAndDeviceSupported, MultilibBoth)
+	return module
+}
+
+func (m *filegroupTargetTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	for _, src := range PathsForModuleSrc(ctx, m.props.Srcs) {
+		m.rels = append(m.rels, src.Rel())
+	}
+}
+
+func TestFilegroupTargetSpecificSrcs(t *testing.T) {
+	bp := `
+		filegroup {
+			name: "srcs",
+			srcs: ["common.c", "generic.c"],
+			target: {
+				android: {
+					srcs: ["android.c"],
+				},
+				android_arm64: {
+					srcs: ["android_arm64.c"],
+				},
+				host: {
+					srcs: ["host.c"],
+				},
+				linux_glibc: {
+					srcs: ["linux_glibc.c"],
+				},
+				darwin: {
+					srcs: ["darwin.c"],
+				},
+				windows: {
+					srcs: ["windows.c"],
+				},
+				not_windows: {
+					srcs: ["not_windows.c"],
+				},
+			},
+			arch: {
+				arm64: {
+					srcs: ["arm64.c"],
+					exclude_srcs: ["generic.c"],
+				},
+				x86_64: {
+					srcs: [":gen"],
+				},
+			},
+			multilib: {
+				lib32: {
+					srcs: ["lib32.c"],
+				},
+			},
+		}
+
+		filegroup {
+			name: "gen",
+			srcs: ["gen.c"],
+		}
+
+		test {
+			name: "foo",
+			srcs: [":srcs"],
+		}
+	`
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithFilegroup,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("test", filegroupTargetTestModuleFactory)
+		}),
+		FixtureWithRootAndroidBp(bp),
+	).RunTest(t)
+
+	// The srcs of a module that references the filegroup are selected for the target of the module.
+	foo := func(variant string) []string {
+		return result.ModuleForTests("foo", variant).Module().(*filegroupTargetTestModule).rels
+	}
+	AssertArrayString(t, "android arm64 srcs",
+		[]string{"common.c", "android.c", "arm64.c", "android_arm64.c"},
+		foo("android_arm64_armv8-a"))
+	AssertArrayString(t, "android arm srcs",
+		[]string{"common.c", "generic.c", "android.c", "lib32.c"},
+		foo("android_arm_armv7-a-neon"))
+
+	// The srcs are available for every os, including the ones that are not configured.
+	srcs := result.ModuleForTests("srcs", "").Module().(*fileGroup)
+	srcsForTarget := func(os OsType, archType ArchType) []string {
+		var rels []string
+		for _, src := range srcs.SrcsForTarget(Target{Os: os, Arch: Arch{ArchType: archType}}) {
+			rels = append(rels, src.Rel())
+		}
+		return rels
+	}
+	AssertArrayString(t, "linux_glibc x86_64 srcs",
+		[]string{"common.c", "generic.c", "host.c", "linux_glibc.c", "not_windows.c", "gen.c"},
+		srcsForTarget(Linux, X86_64))
+	AssertArrayString(t, "darwin x86_64 srcs",
+		[]string{"common.c", "generic.c", "host.c", "darwin.c", "not_windows.c", "gen.c"},
+		srcsForTarget(Darwin, X86_64))
+	AssertArrayString(t, "windows x86_64 srcs",
+		[]string{"common.c", "generic.c", "host.c", "windows.c", "gen.c"},
+		srcsForTarget(Windows, X86_64))
+	AssertArrayString(t, "android common srcs",
+		[]string{"common.c", "generic.c", "android.c"},
+		srcsForTarget(Android, Common))
+
+	// The srcs of a module that is not built for a target don't include the target specific ones.
+	AssertArrayString(t, "srcs", []string{"common.c", "generic.c"}, PathsRelativeToTop(srcs.Srcs()))
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/google/blueprint"
+)
+
+// This file implements the module dependency graph export, which answers "why is X in my build"
+// questions by writing the transitive dependencies of a set of root modules, along with the
+// dependency tags and variants, to a JSON file.  It only needs the mutators to run, so it is
+// generated without preparing any build actions or writing the ninja file.
+
+// registerModuleDepsGraphMutator is registered after all other mutators so that it sees the final
+// dependencies of every variant. It is only registered when collecting the module dependency
+// graph.
+func registerModuleDepsGraphMutator(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("module_deps_graph", moduleDepsGraphMutator).Parallel()
+}
+
+// SetCollectingModuleDepsGraph causes the dependencies of every module to be recorded so that
+// WriteModuleDepsGraph can be called after the mutators have run. It must be called before the
+// Context is created, as the mutator that records the dependencies is only registered then.
+func (c Config) SetCollectingModuleDepsGraph() {
+	c.config.collectingModuleDepsGraph = true
+}
+
+type moduleDepsGraphEdge struct {
+	module blueprint.Module
+	tag    blueprint.DependencyTag
+}
+
+// moduleDepsGraph holds the direct dependencies of every module, keyed by the module.
+type moduleDepsGraph struct {
+	lock sync.Mutex
+	deps map[blueprint.Module][]moduleDepsGraphEdge
+}
+
+var moduleDepsGraphKey = NewOnceKey("moduleDepsGraph")
+
+func moduleDepsGraphForConfig(config Config) *moduleDepsGraph {
+	return config.Once(moduleDepsGraphKey, func() interface{} {
+		return &moduleDepsGraph{deps: make(map[blueprint.Module][]moduleDepsGraphEdge)}
+	}).(*moduleDepsGraph)
+}
+
+func moduleDepsGraphMutator(ctx BottomUpMutatorContext) {
+	var deps []moduleDepsGraphEdge
+	ctx.VisitDirectDepsBlueprint(func(dep blueprint.Module) {
+		deps = append(deps, moduleDepsGraphEdge{dep, ctx.OtherModuleDependencyTag(dep)})
+	})
+
+	graph := moduleDepsGraphForConfig(ctx.Config())
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+	graph.deps[ctx.Module()] = deps
+}
+
+// jsonModuleDepsGraphDep is a single dependency of a module in the JSON module dependency graph.
+type jsonModuleDepsGraphDep struct {
+	Name    string
+	Variant string
+	Tag     string
+}
+
+// jsonModuleDepsGraphModule is a single module variant in the JSON module dependency graph.
+type jsonModuleDepsGraphModule struct {
+	Name    string
+	Variant string
+	Type    string
+	Deps    []jsonModuleDepsGraphDep
+}
+
+// dependencyTagName returns a description of a dependency tag that includes its type and fields,
+// e.g. `cc.libraryDependencyTag {Kind:1 Order:1 ...}`.
+func dependencyTagName(tag blueprint.DependencyTag) string {
+	if tag == nil {
+		return ""
+	}
+	return fmt.Sprintf("%T %+v", tag, tag)
+}
+
+// WriteModuleDepsGraph writes all variants of the root modules and their transitive dependencies
+// to w as JSON.  SetCollectingModuleDepsGraph must have been called before the mutators ran.
+func WriteModuleDepsGraph(ctx *Context, roots []string, w io.Writer) error {
+	if !ctx.config.collectingModuleDepsGraph {
+		return fmt.Errorf("the module dependency graph was not collected")
+	}
+	graph := moduleDepsGraphForConfig(ctx.config)
+
+	var queue []blueprint.Module
+	visited := make(map[blueprint.Module]bool)
+	visit := func(module blueprint.Module) {
+		if !visited[module] {
+			visited[module] = true
+			queue = append(queue, module)
+		}
+	}
+
+	found := make(map[string]bool)
+	ctx.VisitAllModules(func(module blueprint.Module) {
+		if name := ctx.ModuleName(module); InList(name, roots) {
+			found[name] = true
+			visit(module)
+		}
+	})
+	for _, root := range roots {
+		if !found[root] {
+			return fmt.Errorf("unknown root module %q", root)
+		}
+	}
+
+	var modules []jsonModuleDepsGraphModule
+	for len(queue) > 0 {
+		module := queue[0]
+		queue = queue[1:]
+
+		jsonModule := jsonModuleDepsGraphModule{
+			Name:    ctx.ModuleName(module),
+			Variant: ctx.ModuleSubDir(module),
+			Type:    ctx.ModuleType(module),
+			Deps:    []jsonModuleDepsGraphDep{},
+		}
+		for _, dep := range graph.deps[module] {
+			jsonModule.Deps = append(jsonModule.Deps, jsonModuleDepsGraphDep{
+				Name:    ctx.ModuleName(dep.module),
+				Variant: ctx.ModuleSubDir(dep.module),
+				Tag:     dependencyTagName(dep.tag),
+			})
+			visit(dep.module)
+		}
+		modules = append(modules, jsonModule)
+	}
+
+	sort.Slice(modules, func(i, j int) bool {
+		if modules[i].Name != modules[j].Name {
+			return modules[i].Name < modules[j].Name
+		}
+		return modules[i].Variant < modules[j].Variant
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(modules)
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+type testGraphModule struct {
+	ModuleBase
+	Properties struct {
+		Static_deps []string
+		Shared_deps []string
+	}
+}
+
+type testGraphDepTag struct {
+	blueprint.BaseDependencyTag
+	name string
+}
+
+func (t *testGraphModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddDependency(ctx.Module(), testGraphDepTag{name: "static"}, t.Properties.Static_deps...)
+	ctx.AddDependency(ctx.Module(), testGraphDepTag{name: "shared"}, t.Properties.Shared_deps...)
+}
+
+func (t *testGraphModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+func testGraphModuleFactory() Module {
+	module := &testGraphModule{}
+	module.AddProperties(&module.Properties)
+	InitAndroidArchModule(module, DeviceSupported, MultilibCommon)
+	return module
+}
+
+var prepareForModuleDepsGraphTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("test_graph_module", testGraphModuleFactory)
+	}),
+	FixtureModifyConfig(func(config Config) {
+		config.collectingModuleDepsGraph = true
+	}),
+	FixtureWithRootAndroidBp(`
+		test_graph_module {
+			name: "a",
+			static_deps: ["b"],
+			shared_deps: ["c"],
+		}
+
+		test_graph_module {
+			name: "b",
+			shared_deps: ["c", "d"],
+		}
+
+		test_graph_module {
+			name: "c",
+		}
+
+		test_graph_module {
+			name: "d",
+		}
+
+		test_graph_module {
+			name: "unrelated",
+			static_deps: ["a"],
+		}
+	`),
+)
+
+func TestWriteModuleDepsGraph(t *testing.T) {
+	result := prepareForModuleDepsGraphTest.RunTest(t)
+
+	buf := &bytes.Buffer{}
+	if err := WriteModuleDepsGraph(result.TestContext.Context, []string{"b", "a"}, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var modules []jsonModuleDepsGraphModule
+	if err := json.Unmarshal(buf.Bytes(), &modules); err != nil {
+		t.Fatal(err)
+	}
+
+	staticTag := "android.testGraphDepTag {BaseDependencyTag:{} name:static}"
+	sharedTag := "android.testGraphDepTag {BaseDependencyTag:{} name:shared}"
+	dep := func(name, tag string) jsonModuleDepsGraphDep {
+		return jsonModuleDepsGraphDep{Name: name, Variant: "android_common", Tag: tag}
+	}
+	module := func(name string, deps ...jsonModuleDepsGraphDep) jsonModuleDepsGraphModule {
+		if deps == nil {
+			deps = []jsonModuleDepsGraphDep{}
+		}
+		return jsonModuleDepsGraphModule{Name: name, Variant: "android_common", Type: "test_graph_module", Deps: deps}
+	}
+
+	AssertDeepEquals(t, "module deps graph", []jsonModuleDepsGraphModule{
+		module("a", dep("b", staticTag), dep("c", sharedTag)),
+		module("b", dep("c", sharedTag), dep("d", sharedTag)),
+		module("c"),
+		module("d"),
+	}, modules)
+}
+
+func TestWriteModuleDepsGraphUnknownRoot(t *testing.T) {
+	result := prepareForModuleDepsGraphTest.RunTest(t)
+
+	err := WriteModuleDepsGraph(result.TestContext.Context, []string{"a", "missing"}, &bytes.Buffer{})
+	AssertErrorMessageEquals(t, "error", `unknown root module "missing"`, err)
+}
+
+func TestWriteModuleDepsGraphNotCollected(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForModuleDepsGraphTest,
+		FixtureModifyConfig(func(config Config) {
+			config.collectingModuleDepsGraph = false
+		}),
+	).RunTest(t)
+
+	err := WriteModuleDepsGraph(result.TestContext.Context, []string{"a"}, &bytes.Buffer{})
+	AssertErrorMessageEquals(t, "error", "the module dependency graph was not collected", err)
+}
+
+func TestModuleDepsGraphMutatorOnlyRegisteredWhenCollecting(t *testing.T) {
+	mutatorNames := func(config Config) []string {
+		return componentsToNames(collateRegisteredMutators(nil, nil, nil, nil, modeMutators(config)))
+	}
+
+	config := TestConfig(t.TempDir(), nil, "", nil)
+	AssertStringListDoesNotContain(t, "mutators", mutatorNames(config), "module_deps_graph")
+
+	config.SetCollectingModuleDepsGraph()
+	AssertStringListContains(t, "mutators", mutatorNames(config), "module_deps_graph")
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	RegisterInstallPathCollisionsBuildComponents(InitRegistrationContext)
+}
+
+func RegisterInstallPathCollisionsBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("install_path_collisions", installPathCollisionsSingletonFactory)
+}
+
+var PrepareForTestWithInstallPathCollisions = FixtureRegisterWithContext(RegisterInstallPathCollisionsBuildComponents)
+
+func installPathCollisionsSingletonFactory() Singleton {
+	return &installPathCollisionsSingleton{}
+}
+
+type installPathCollisionsSingleton struct{}
+
+// installPathUser is a module variant that installs a file to a given path.
+type installPathUser struct {
+	module Module
+	path   InstallPath
+}
+
+// installPathCollisionKey returns the path that is used to report a collision and to look it up in
+// BuildBrokenDuplicateInstallPaths. It is relative to the product out directory for device modules,
+// so it starts with the partition, e.g. "system/etc/foo.xml", and relative to the host out
+// directory prefixed with "host/" for host modules, e.g. "host/bin/foo".
+func installPathCollisionKey(path InstallPath) string {
+	partitionParent := strings.TrimSuffix(path.partitionDir, path.partition)
+	rel, err := filepath.Rel(partitionParent, path.path)
+	if err != nil {
+		return path.path
+	}
+	if strings.HasPrefix(path.partitionDir, "host/") {
+		return filepath.Join("host", rel)
+	}
+	return rel
+}
+
+// GenerateBuildActions reports every path that is installed by more than one module variant, as
+// only one of the install rules can take effect. Such collisions are only caught by ninja when the
+// install rules are created by Soong, and not at all when they are exported to Make.
+func (installPathCollisionsSingleton) GenerateBuildActions(ctx SingletonContext) {
+	users := make(map[string][]installPathUser)
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() {
+			return
+		}
+		for _, path := range module.FilesToInstall() {
+			key := installPathCollisionKey(path)
+			// A module variant may install the same file more than once.
+			if n := len(users[key]); n > 0 && users[key][n-1].module == module {
+				continue
+			}
+			users[key] = append(users[key], installPathUser{module, path})
+		}
+	})
+
+	for _, key := range SortedStringKeys(users) {
+		if len(users[key]) < 2 || ctx.DeviceConfig().BuildBrokenDuplicateInstallPath(key) {
+			continue
+		}
+		var lines []string
+		for _, user := range users[key] {
+			lines = append(lines, fmt.Sprintf("    %s (variant %q) defined in %s",
+				ctx.ModuleName(user.module), ctx.ModuleSubDir(user.module),
+				ctx.BlueprintFile(user.module)))
+		}
+		ctx.Errorf("%s is installed by more than one module:\n%s\n"+
+			"If this is intentional, add %q to BUILD_BROKEN_DUPLICATE_INSTALL_PATHS.",
+			users[key][0].path, strings.Join(lines, "\n"), key)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type installPathCollisionTestModule struct {
+	ModuleBase
+	properties struct {
+		Filename *string
+		Sub_dir  *string
+	}
+}
+
+func installPathCollisionTestModuleFactory() Module {
+	m := &installPathCollisionTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, DeviceSupported, MultilibCommon)
+	return m
+}
+
+func (m *installPathCollisionTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	outputFile := PathForModuleOut(ctx, ctx.ModuleName())
+	ctx.Build(pctx, BuildParams{
+		Rule:   Touch,
+		Output: outputFile,
+	})
+	ctx.InstallFile(PathForModuleInstall(ctx, "etc", String(m.properties.Sub_dir)),
+		String(m.properties.Filename), outputFile)
+}
+
+var prepareForInstallPathCollisionsTest = GroupFixturePreparers(
+	PrepareForTestWithInstallPathCollisions,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("install_test_module", installPathCollisionTestModuleFactory)
+	}),
+)
+
+func TestInstallPathCollisions(t *testing.T) {
+	bp := `
+		install_test_module {
+			name: "foo",
+			filename: "foo.xml",
+		}
+
+		install_test_module {
+			name: "bar",
+			filename: "foo.xml",
+		}
+
+		install_test_module {
+			name: "baz",
+			filename: "foo.xml",
+			sub_dir: "baz",
+		}
+
+		install_test_module {
+			name: "vendor_foo",
+			filename: "foo.xml",
+			vendor: true,
+		}
+	`
+
+	GroupFixturePreparers(
+		prepareForInstallPathCollisionsTest,
+		FixtureWithRootAndroidBp(bp),
+	).ExtendWithErrorHandler(FixtureCustomErrorHandler(func(t *testing.T, result *TestResult) {
+		AssertIntEquals(t, "number of errors", 1, len(result.Errs))
+		message := result.Errs[0].Error()
+		AssertStringDoesContain(t, "collision", message,
+			"target/product/test_device/system/etc/foo.xml is installed by more than one module:")
+		AssertStringDoesContain(t, "collision", message,
+			`foo (variant "android_common") defined in Android.bp`)
+		AssertStringDoesContain(t, "collision", message,
+			`bar (variant "android_common") defined in Android.bp`)
+		AssertStringDoesContain(t, "collision", message,
+			`add "system/etc/foo.xml" to BUILD_BROKEN_DUPLICATE_INSTALL_PATHS`)
+		AssertStringDoesNotContain(t, "collision", message, "baz")
+		AssertStringDoesNotContain(t, "collision", message, "vendor_foo")
+	})).RunTest(t)
+}
+
+func TestInstallPathCollisionsAllowlist(t *testing.T) {
+	bp := `
+		install_test_module {
+			name: "foo",
+			filename: "foo.xml",
+		}
+
+		install_test_module {
+			name: "bar",
+			filename: "foo.xml",
+		}
+
+		install_test_module {
+			name: "vendor_foo",
+			filename: "foo.xml",
+			vendor: true,
+		}
+
+		install_test_module {
+			name: "vendor_bar",
+			filename: "foo.xml",
+			vendor: true,
+		}
+	`
+
+	// The allowlist only covers the collision in the system partition.
+	GroupFixturePreparers(
+		prepareForInstallPathCollisionsTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.BuildBrokenDuplicateInstallPaths = []string{"system/etc/foo.xml"}
+		}),
+		FixtureWithRootAndroidBp(bp),
+	).ExtendWithErrorHandler(FixtureCustomErrorHandler(func(t *testing.T, result *TestResult) {
+		AssertIntEquals(t, "number of errors", 1, len(result.Errs))
+		AssertStringDoesContain(t, "collision", result.Errs[0].Error(),
+			"target/product/test_device/vendor/etc/foo.xml is installed by more than one module:")
+	})).RunTest(t)
+}
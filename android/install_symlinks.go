@@ -0,0 +1,83 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"path/filepath"
+)
+
+func init() {
+	RegisterInstallSymlinkCheckBuildComponents(InitRegistrationContext)
+}
+
+func RegisterInstallSymlinkCheckBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("install_symlink_check", installSymlinkCheckSingletonFactory)
+}
+
+var PrepareForTestWithInstallSymlinkCheck = FixtureRegisterWithContext(RegisterInstallSymlinkCheckBuildComponents)
+
+func installSymlinkCheckSingletonFactory() Singleton {
+	return &installSymlinkCheckSingleton{}
+}
+
+type installSymlinkCheckSingleton struct{}
+
+// installedSymlink is a symlink installed by a module, keyed by its partition and path in the
+// partition.
+type installedSymlink struct {
+	partition, relPath string
+}
+
+// GenerateBuildActions reports an error for every module that installs a file beneath a symlink
+// installed by a module. Such a file, e.g. from a relative_install_path that names the symlink,
+// ends up wherever the symlink points to, which can be outside of the partition.
+// ValidateRelativeInstallPath only rejects the values that escape lexically.
+func (installSymlinkCheckSingleton) GenerateBuildActions(ctx SingletonContext) {
+	symlinks := make(map[installedSymlink]string)
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() {
+			return
+		}
+		for _, spec := range module.PackagingSpecs() {
+			if spec.symlinkTarget != "" {
+				symlinks[installedSymlink{spec.partition, spec.relPathInPackage}] = ctx.ModuleName(module)
+			}
+		}
+	})
+	if len(symlinks) == 0 {
+		return
+	}
+
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() {
+			return
+		}
+		reported := make(map[string]bool)
+		for _, spec := range module.PackagingSpecs() {
+			for dir := filepath.Dir(spec.relPathInPackage); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+				owner, ok := symlinks[installedSymlink{spec.partition, dir}]
+				if !ok {
+					continue
+				}
+				if !reported[dir] {
+					reported[dir] = true
+					ctx.ModuleErrorf(module, "installs %q beneath %q, which is a symlink installed by %q",
+						spec.relPathInPackage, dir, owner)
+				}
+				break
+			}
+		}
+	})
+}
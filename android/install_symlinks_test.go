@@ -0,0 +1,78 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"testing"
+)
+
+type installSymlinkTestModule struct {
+	ModuleBase
+	properties struct {
+		Symlink        *string
+		Symlink_target *string
+		Sub_dir        *string
+	}
+}
+
+func installSymlinkTestModuleFactory() Module {
+	m := &installSymlinkTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidModule(m)
+	return m
+}
+
+func (m *installSymlinkTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	if m.properties.Symlink != nil {
+		ctx.InstallAbsoluteSymlink(PathForModuleInstall(ctx, "bin"), String(m.properties.Symlink),
+			String(m.properties.Symlink_target))
+		return
+	}
+	ctx.InstallFile(PathForModuleInstall(ctx, "bin", String(m.properties.Sub_dir)), ctx.ModuleName(),
+		PathForModuleSrc(ctx, ctx.ModuleName()))
+}
+
+func TestInstallSymlinkCheck(t *testing.T) {
+	bp := `
+		install_symlink_test_module {
+			name: "link",
+			symlink: "vendor_bin",
+			symlink_target: "/vendor/bin",
+		}
+
+		install_symlink_test_module {
+			name: "foo",
+			sub_dir: "%s",
+		}
+	`
+	prepare := GroupFixturePreparers(
+		PrepareForTestWithInstallSymlinkCheck,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("install_symlink_test_module", installSymlinkTestModuleFactory)
+		}),
+		FixtureAddFile("foo", nil),
+	)
+
+	t.Run("beneath symlink", func(t *testing.T) {
+		prepare.ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`installs "bin/vendor_bin/sub/foo" beneath "bin/vendor_bin", which is a symlink installed by "link"`)).
+			RunTestWithBp(t, fmt.Sprintf(bp, "vendor_bin/sub"))
+	})
+
+	t.Run("next to symlink", func(t *testing.T) {
+		prepare.RunTestWithBp(t, fmt.Sprintf(bp, "vendor_bin_real"))
+	})
+}
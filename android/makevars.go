@@ -282,25 +282,30 @@ func (s *makeVarsSingleton) GenerateBuildActions(ctx SingletonContext) {
 		return
 	}
 
-	sort.Slice(vars, func(i, j int) bool {
+	sort.SliceStable(vars, func(i, j int) bool {
 		return vars[i].name < vars[j].name
 	})
-	sort.Slice(phonies, func(i, j int) bool {
+	sort.SliceStable(phonies, func(i, j int) bool {
 		return phonies[i].name < phonies[j].name
 	})
-	lessArr := func(a, b []string) bool {
-		if len(a) == len(b) {
-			for i := range a {
-				if a[i] < b[i] {
-					return true
-				}
+	// compareArr orders shorter lists first, and lists of the same length by their first differing
+	// element.
+	compareArr := func(a, b []string) int {
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return strings.Compare(a[i], b[i])
 			}
-			return false
 		}
-		return len(a) < len(b)
+		return 0
 	}
-	sort.Slice(dists, func(i, j int) bool {
-		return lessArr(dists[i].goals, dists[j].goals) || lessArr(dists[i].paths, dists[j].paths)
+	sort.SliceStable(dists, func(i, j int) bool {
+		if c := compareArr(dists[i].goals, dists[j].goals); c != 0 {
+			return c < 0
+		}
+		return compareArr(dists[i].paths, dists[j].paths) < 0
 	})
 
 	outBytes := s.writeVars(vars)
@@ -452,6 +457,12 @@ func (s *makeVarsSingleton) writeInstalls(installs, symlinks []katiInstall) []by
 		// Write a rule for each install request in the form:
 		//  to: from [ deps ] [ | order only deps ]
 		//       cp -f -d $< $@ [ && chmod +x $@ ]
+		// preceded by the validations of the install, if any, in the form:
+		//  to: .KATI_VALIDATIONS := validations
+		if len(install.validations) > 0 {
+			fmt.Fprintf(buf, "%s: .KATI_VALIDATIONS := %s\n", install.to.String(),
+				strings.Join(install.validations.Strings(), " "))
+		}
 		fmt.Fprintf(buf, "%s: %s", install.to.String(), install.from.String())
 		for _, dep := range install.implicitDeps {
 			fmt.Fprintf(buf, " %s", dep.String())
@@ -17,6 +17,7 @@ package android
 import (
 	"io/ioutil"
 	"runtime"
+	"sync/atomic"
 
 	"github.com/google/blueprint/metrics"
 	"google.golang.org/protobuf/proto"
@@ -35,6 +36,40 @@ func ReadSoongMetrics(config Config) SoongMetrics {
 	return config.Get(soongMetricsOnceKey).(SoongMetrics)
 }
 
+var flagSliceInternStatsOnceKey = NewOnceKey("flag slice intern stats")
+
+// FlagSliceInternStats counts the lookups in the cc flag slice interner so that its hit rate can be
+// reported in the soong_build metrics.
+type FlagSliceInternStats struct {
+	lookups uint64
+	hits    uint64
+}
+
+// Record records a single lookup in the interner, and whether it reused an existing slice.
+func (s *FlagSliceInternStats) Record(hit bool) {
+	atomic.AddUint64(&s.lookups, 1)
+	if hit {
+		atomic.AddUint64(&s.hits, 1)
+	}
+}
+
+// Lookups returns the number of lookups recorded so far.
+func (s *FlagSliceInternStats) Lookups() uint64 {
+	return atomic.LoadUint64(&s.lookups)
+}
+
+// Hits returns the number of lookups recorded so far that reused an existing slice.
+func (s *FlagSliceInternStats) Hits() uint64 {
+	return atomic.LoadUint64(&s.hits)
+}
+
+// GetFlagSliceInternStats returns the FlagSliceInternStats for the given config.
+func GetFlagSliceInternStats(config Config) *FlagSliceInternStats {
+	return config.Once(flagSliceInternStatsOnceKey, func() interface{} {
+		return &FlagSliceInternStats{}
+	}).(*FlagSliceInternStats)
+}
+
 func init() {
 	RegisterSingletonType("soong_metrics", soongMetricsSingletonFactory)
 }
@@ -63,6 +98,10 @@ func collectMetrics(config Config, eventHandler metrics.EventHandler) *soong_met
 	metrics.Modules = proto.Uint32(uint32(soongMetrics.Modules))
 	metrics.Variants = proto.Uint32(uint32(soongMetrics.Variants))
 
+	internStats := GetFlagSliceInternStats(config)
+	metrics.InternedFlagSliceLookups = proto.Uint64(internStats.Lookups())
+	metrics.InternedFlagSliceHits = proto.Uint64(internStats.Hits())
+
 	memStats := runtime.MemStats{}
 	runtime.ReadMemStats(&memStats)
 	metrics.MaxHeapSize = proto.Uint64(memStats.HeapSys)
@@ -24,6 +24,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"text/scanner"
 
 	"android/soong/bazel"
@@ -397,6 +398,12 @@ type ModuleContext interface {
 	// for which IsInstallDepNeeded returns true.
 	InstallFileWithExtraFilesZip(installPath InstallPath, name string, srcPath Path, extraZip Path, deps ...Path) InstallPath
 
+	// InstallAfter adds order-only dependencies on the given installed files to the rules created
+	// by any later calls to InstallFile, InstallExecutable or InstallFileWithExtraFilesZip, so that
+	// this module's files are always installed after them.  The files listed here are not
+	// installed because of this call.
+	InstallAfter(installed ...InstallPath)
+
 	// InstallSymlink creates a rule to create a symlink from src srcPath to name in the installPath
 	// directory.
 	//
@@ -548,6 +555,11 @@ type Module interface {
 	FilesToInstall() InstallPaths
 	PackagingSpecs() []PackagingSpec
 
+	// TransitiveInstallFiles returns the files installed by this module and any transitive
+	// dependencies with dependency tags for which IsInstallDepNeeded() returns true, e.g. the shared
+	// libraries a host tool needs at runtime.
+	TransitiveInstallFiles() InstallPaths
+
 	// TransitivePackagingSpecs returns the PackagingSpecs for this module and any transitive
 	// dependencies with dependency tags for which IsInstallDepNeeded() returns true.
 	TransitivePackagingSpecs() []PackagingSpec
@@ -819,8 +831,11 @@ type commonProperties struct {
 	// init.rc files to be installed if this module is installed
 	Init_rc []string `android:"arch_variant,path"`
 
+	// If true, the init.rc files are not checked with host_init_verifier.
+	Skip_init_rc_check *bool
+
 	// VINTF manifest fragments to be installed if this module is installed
-	Vintf_fragments []string `android:"path"`
+	Vintf_fragments []string `android:"arch_variant,path"`
 
 	// names of other modules to install if this module is installed
 	Required []string `android:"arch_variant"`
@@ -831,6 +846,10 @@ type commonProperties struct {
 	// names of other modules to install on target if this module is installed
 	Target_required []string `android:"arch_variant"`
 
+	// names of other modules whose files must be installed before the files of this module.
+	// This only orders the install rules, it does not cause the other modules to be installed.
+	Install_after []string
+
 	// relative path to a file to include in the list of notices for the device
 	Notice *string `android:"path"`
 
@@ -1926,6 +1945,40 @@ func (m *ModuleBase) computeInstallDeps(ctx ModuleContext) ([]*installPathsDepSe
 	return installDeps, packagingSpecs
 }
 
+type installAfterDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+// Modules listed in install_after are only used to order install rules, they are never part of
+// an apex.
+func (installAfterDependencyTag) ExcludeFromApexContents() {}
+
+var _ ExcludeFromApexContentsTag = InstallAfterDepTag
+
+// InstallAfterDepTag is the dependency tag used for the modules listed in the install_after
+// property.
+var InstallAfterDepTag = installAfterDependencyTag{}
+
+// baseDepsMutator adds dependencies for properties common to all modules.
+func (m *ModuleBase) baseDepsMutator(ctx BottomUpMutatorContext) {
+	installAfter := m.commonProperties.Install_after
+	if InList(ctx.ModuleName(), installAfter) {
+		ctx.PropertyErrorf("install_after", "module %q cannot be installed after itself", ctx.ModuleName())
+		return
+	}
+	ctx.AddFarVariationDependencies(ctx.Target().Variations(), InstallAfterDepTag, installAfter...)
+}
+
+// computeInstallAfterFiles returns the installed files of the modules listed in the
+// install_after property.
+func (m *ModuleBase) computeInstallAfterFiles(ctx ModuleContext) InstallPaths {
+	var installAfter InstallPaths
+	ctx.VisitDirectDepsWithTag(InstallAfterDepTag, func(dep Module) {
+		installAfter = append(installAfter, dep.FilesToInstall()...)
+	})
+	return installAfter
+}
+
 func (m *ModuleBase) FilesToInstall() InstallPaths {
 	return m.installFiles
 }
@@ -1934,6 +1987,10 @@ func (m *ModuleBase) PackagingSpecs() []PackagingSpec {
 	return m.packagingSpecs
 }
 
+func (m *ModuleBase) TransitiveInstallFiles() InstallPaths {
+	return m.installFilesDepSet.ToList()
+}
+
 func (m *ModuleBase) TransitivePackagingSpecs() []PackagingSpec {
 	return m.packagingSpecsDepSet.ToList()
 }
@@ -2039,6 +2096,41 @@ func (m *ModuleBase) TargetRequiredModuleNames() []string {
 	return m.base().commonProperties.Target_required
 }
 
+// checkInitRc creates rules that check the init.rc files of the module with host_init_verifier
+// and returns their outputs.
+func (m *ModuleBase) checkInitRc(ctx ModuleContext) Paths {
+	if len(m.initRcPaths) == 0 || !ctx.Device() || Bool(m.commonProperties.Skip_init_rc_check) {
+		return nil
+	}
+
+	// host_init_verifier is not available in unbundled builds, nor in trees without system/core.
+	if ctx.Config().UnbundledBuild() || !ctx.OtherModuleExists("host_init_verifier") {
+		return nil
+	}
+	propertyContexts := ExistentPathForSource(ctx, "system/sepolicy/private/property_contexts")
+	if !propertyContexts.Valid() {
+		return nil
+	}
+
+	tool := ctx.Config().HostToolPath(ctx, "host_init_verifier")
+	var checks Paths
+	for _, rc := range m.initRcPaths {
+		timestamp := PathForModuleOut(ctx, "init_rc_check", rc.Base()+".timestamp")
+		ctx.Build(pctx, BuildParams{
+			Rule:      hostInitVerifier,
+			Input:     rc,
+			Output:    timestamp,
+			Implicits: Paths{tool, propertyContexts.Path()},
+			Args: map[string]string{
+				"tool":             tool.String(),
+				"propertyContexts": propertyContexts.String(),
+			},
+		})
+		checks = append(checks, timestamp)
+	}
+	return checks
+}
+
 func (m *ModuleBase) InitRc() Paths {
 	return append(Paths{}, m.initRcPaths...)
 }
@@ -2199,6 +2291,8 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 	// files of this module at the end for use by modules that depend on this one.
 	m.installFilesDepSet = newInstallPathsDepSet(nil, dependencyInstallFiles)
 
+	ctx.InstallAfter(m.computeInstallAfterFiles(ctx)...)
+
 	// Temporarily continue to call blueprintCtx.GetMissingDependencies() to maintain the previous behavior of never
 	// reporting missing dependency errors in Blueprint when AllowMissingDependencies == true.
 	// TODO: This will be removed once defaults modules handle missing dependency errors
@@ -2275,18 +2369,25 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 			return
 		}
 
+		// The init.rc files are checked before calling GenerateAndroidBuildActions so that the
+		// checks can be attached to the files installed by the module.
+		m.initRcPaths = PathsForModuleSrc(ctx, m.commonProperties.Init_rc)
+		initRcChecks := m.checkInitRc(ctx)
+		ctx.installValidations = append(ctx.installValidations, initRcChecks...)
+		ctx.checkbuildFiles = append(ctx.checkbuildFiles, initRcChecks...)
+		// The VINTF fragments are resolved before as well, so that modules can validate them.
+		m.vintfFragmentsPaths = PathsForModuleSrc(ctx, m.commonProperties.Vintf_fragments)
+
 		m.module.GenerateAndroidBuildActions(ctx)
 		if ctx.Failed() {
 			return
 		}
 
-		m.initRcPaths = PathsForModuleSrc(ctx, m.commonProperties.Init_rc)
 		rcDir := PathForModuleInstall(ctx, "etc", "init")
 		for _, src := range m.initRcPaths {
 			ctx.PackageFile(rcDir, filepath.Base(src.String()), src)
 		}
 
-		m.vintfFragmentsPaths = PathsForModuleSrc(ctx, m.commonProperties.Vintf_fragments)
 		vintfDir := PathForModuleInstall(ctx, "etc", "vintf", "manifest")
 		for _, src := range m.vintfFragmentsPaths {
 			ctx.PackageFile(vintfDir, filepath.Base(src.String()), src)
@@ -2508,6 +2609,13 @@ type moduleContext struct {
 	katiInstalls []katiInstall
 	katiSymlinks []katiInstall
 
+	// Installed files that must be installed before any file installed by this module.
+	installAfter InstallPaths
+
+	// Files that must be built whenever a file installed by this module is installed, but that
+	// do not cause the file to be reinstalled, e.g. the results of checks.
+	installValidations Paths
+
 	// For tests
 	buildParams []BuildParams
 	ruleParams  map[blueprint.Rule]blueprint.RuleParams
@@ -2520,6 +2628,7 @@ type katiInstall struct {
 	to            InstallPath
 	implicitDeps  Paths
 	orderOnlyDeps Paths
+	validations   Paths
 	executable    bool
 	extraFiles    *extraFilesZip
 
@@ -2685,8 +2794,8 @@ func (m *moduleContext) Build(pctx PackageContext, params BuildParams) {
 	}
 
 	if missingDeps := m.GetMissingDependencies(); len(missingDeps) > 0 {
-		pctx, params = m.ninjaError(params, fmt.Errorf("module %s missing dependencies: %s\n",
-			m.ModuleName(), strings.Join(missingDeps, ", ")))
+		pctx, params = m.ninjaError(params, fmt.Errorf("module %s missing dependencies: %s\n%s",
+			m.ModuleName(), strings.Join(missingDeps, ", "), m.missingDependencyHints(missingDeps)))
 	}
 
 	if m.config.captureBuild {
@@ -2716,6 +2825,75 @@ func (m *moduleContext) GetMissingDependencies() []string {
 	return missingDeps
 }
 
+// missingDependencyHints returns a description of the missing dependencies that name a module that
+// does exist, which means that the dependency failed because the module did not have the requested
+// variant or could not be used for another reason, for example because it was disabled.
+func (m *moduleContext) missingDependencyHints(missingDeps []string) string {
+	var hints strings.Builder
+	for _, dep := range missingDeps {
+		// Blueprint appends the requested variations to the name of dependencies that are
+		// missing a variant, e.g. "libfoo{os:android,link:static}".
+		name, variant := dep, ""
+		if i := strings.IndexByte(dep, '{'); i >= 0 {
+			name, variant = dep[:i], dep[i:]
+		}
+		if !m.OtherModuleExists(name) {
+			continue
+		}
+		if variant != "" {
+			fmt.Fprintf(&hints, "module %s exists but does not have the requested variant %s\n", name, variant)
+		} else {
+			fmt.Fprintf(&hints, "module %s exists but could not be used as a dependency, it may be disabled or not provide the required variant\n", name)
+		}
+		if variants := moduleVariantsForConfig(m.Config()).get(name); len(variants) > 0 {
+			fmt.Fprintf(&hints, "available variants:\n  %s\n", strings.Join(variants, "\n  "))
+		}
+	}
+	return hints.String()
+}
+
+// moduleVariants holds the variants of every module, keyed by module name, so that errors about
+// missing dependencies can list the variants that do exist.  It is only populated when missing
+// dependencies are allowed, as they are otherwise reported by Blueprint, which lists the variants
+// itself.
+type moduleVariants struct {
+	lock     sync.Mutex
+	variants map[string][]string
+}
+
+var moduleVariantsKey = NewOnceKey("moduleVariants")
+
+func moduleVariantsForConfig(config Config) *moduleVariants {
+	return config.Once(moduleVariantsKey, func() interface{} {
+		return &moduleVariants{variants: make(map[string][]string)}
+	}).(*moduleVariants)
+}
+
+// get returns the sorted variants of the named module.
+func (v *moduleVariants) get(name string) []string {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return SortedUniqueStrings(v.variants[name])
+}
+
+// registerModuleVariantsMutator is registered after all other mutators so that it sees the final
+// variants of every module. It is only registered when missing dependencies are allowed.
+func registerModuleVariantsMutator(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("module_variants", moduleVariantsMutator).Parallel()
+}
+
+func moduleVariantsMutator(ctx BottomUpMutatorContext) {
+	// The variant in the same form as the requested variant of a missing dependency, e.g.
+	// "{os:android,link:static}".
+	base := ctx.Module().base()
+	variant := strings.TrimPrefix(base.String(), base.commonProperties.DebugName)
+
+	variants := moduleVariantsForConfig(ctx.Config())
+	variants.lock.Lock()
+	defer variants.lock.Unlock()
+	variants.variants[ctx.ModuleName()] = append(variants.variants[ctx.ModuleName()], variant)
+}
+
 func (b *baseModuleContext) AddMissingDependencies(deps []string) {
 	if deps != nil {
 		missingDeps := &b.Module().base().commonProperties.MissingDeps
@@ -3150,6 +3328,10 @@ func (m *moduleContext) InstallFileWithExtraFilesZip(installPath InstallPath, na
 	})
 }
 
+func (m *moduleContext) InstallAfter(installed ...InstallPath) {
+	m.installAfter = append(m.installAfter, installed...)
+}
+
 func (m *moduleContext) PackageFile(installPath InstallPath, name string, srcPath Path) PackagingSpec {
 	fullInstallPath := installPath.Join(m, name)
 	return m.packageFile(fullInstallPath, srcPath, false)
@@ -3188,6 +3370,10 @@ func (m *moduleContext) installFile(installPath InstallPath, name string, srcPat
 			orderOnlyDeps = deps
 		}
 
+		// Files from install_after only need to be installed first, they must not cause this
+		// module's files to be reinstalled when they change.
+		orderOnlyDeps = append(orderOnlyDeps, m.installAfter.Paths()...)
+
 		if m.Config().KatiEnabled() {
 			// When creating the install rule in Soong but embedding in Make, write the rule to a
 			// makefile instead of directly to the ninja file so that main.mk can add the
@@ -3197,6 +3383,7 @@ func (m *moduleContext) installFile(installPath InstallPath, name string, srcPat
 				to:            fullInstallPath,
 				implicitDeps:  implicitDeps,
 				orderOnlyDeps: orderOnlyDeps,
+				validations:   m.installValidations,
 				executable:    executable,
 				extraFiles:    extraZip,
 			})
@@ -3221,6 +3408,7 @@ func (m *moduleContext) installFile(installPath InstallPath, name string, srcPat
 				Input:       srcPath,
 				Implicits:   implicitDeps,
 				OrderOnly:   orderOnlyDeps,
+				Validations: m.installValidations,
 				Default:     !m.Config().KatiEnabled(),
 				Args: map[string]string{
 					"extraCmds": extraCmds,
@@ -3474,6 +3662,26 @@ type SourceFileProducer interface {
 	Srcs() Paths
 }
 
+// A SourceFileProducer that also implements TargetSourceFileProducer provides different paths
+// depending on the target of the module that references it.  SrcsForTarget is used instead of Srcs
+// when the referencing context has a target.
+type TargetSourceFileProducer interface {
+	SourceFileProducer
+
+	SrcsForTarget(target Target) Paths
+}
+
+// sourceFileProducerSrcs returns the paths provided by a SourceFileProducer to the module of ctx,
+// selecting the target specific paths of a TargetSourceFileProducer when ctx has a target.
+func sourceFileProducerSrcs(ctx interface{}, producer SourceFileProducer) Paths {
+	if targetProducer, ok := producer.(TargetSourceFileProducer); ok {
+		if targetCtx, ok := ctx.(interface{ Target() Target }); ok {
+			return targetProducer.SrcsForTarget(targetCtx.Target())
+		}
+	}
+	return producer.Srcs()
+}
+
 // A module that implements OutputFileProducer can be referenced from any property that is tagged with `android:"path"`
 // using the ":module" syntax or ":module{.tag}" syntax and provides a list of output files to be used as if they were
 // listed in the property.
@@ -3523,7 +3731,7 @@ func outputFilesForModule(ctx PathContext, module blueprint.Module, tag string)
 		if tag != "" {
 			return nil, fmt.Errorf("module %q is a SourceFileProducer, not an OutputFileProducer, and so does not support tag %q", pathContextName(ctx, module), tag)
 		}
-		paths := sourceFileProducer.Srcs()
+		paths := sourceFileProducerSrcs(ctx, sourceFileProducer)
 		if len(paths) == 0 {
 			return nil, fmt.Errorf("failed to get output files from module %q", pathContextName(ctx, module))
 		}
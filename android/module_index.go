@@ -0,0 +1,86 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/google/blueprint"
+)
+
+// This file implements the module index that is written by soong_build when it is run with
+// --module_index_file, which soong_ui does for module queries like `m modules-under <dir>`.
+// It is not written by regular builds, as it requires visiting the outputs of every module.
+
+// moduleIndexEntry describes a single variant of a module in the module index.
+// The format is read by the module queries of soong_ui in build/soong/ui/build/module_query.go.
+type moduleIndexEntry struct {
+	Name    string
+	Type    string
+	Dir     string
+	Variant string `json:",omitempty"`
+
+	// The default output files of the variant.
+	Outputs []string `json:",omitempty"`
+
+	// The files that are installed by the variant.
+	Installs []string `json:",omitempty"`
+}
+
+// WriteModuleIndex writes the directory, outputs and installed files of every enabled module
+// variant to w as JSON.  The build actions of the modules must have been generated.
+func WriteModuleIndex(ctx *Context, w io.Writer) error {
+	var entries []moduleIndexEntry
+	ctx.VisitAllModules(func(m blueprint.Module) {
+		module, ok := m.(Module)
+		if !ok || !module.Enabled() {
+			return
+		}
+
+		entry := moduleIndexEntry{
+			Name:    ctx.ModuleName(module),
+			Type:    ctx.ModuleType(module),
+			Dir:     ctx.ModuleDir(module),
+			Variant: ctx.ModuleSubDir(module),
+		}
+		if producer, ok := module.(OutputFileProducer); ok {
+			// Not every module has a default output, ignore the error for those that don't.
+			if outputs, err := producer.OutputFiles(""); err == nil {
+				entry.Outputs = outputs.Strings()
+			}
+		}
+		entry.Installs = module.FilesToInstall().Strings()
+		entries = append(entries, entry)
+	})
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		if entries[i].Dir != entries[j].Dir {
+			return entries[i].Dir < entries[j].Dir
+		}
+		return entries[i].Variant < entries[j].Variant
+	})
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type moduleIndexTestModule struct {
+	ModuleBase
+	properties struct {
+		Installable *bool
+	}
+
+	outputFile Path
+}
+
+func moduleIndexTestModuleFactory() Module {
+	m := &moduleIndexTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, DeviceSupported, MultilibCommon)
+	return m
+}
+
+func (m *moduleIndexTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	outputFile := PathForModuleOut(ctx, ctx.ModuleName()+".txt")
+	ctx.Build(pctx, BuildParams{
+		Rule:   Touch,
+		Output: outputFile,
+	})
+	m.outputFile = outputFile
+	if BoolDefault(m.properties.Installable, true) {
+		ctx.InstallFile(PathForModuleInstall(ctx, "etc"), ctx.ModuleName()+".txt", outputFile)
+	}
+}
+
+func (m *moduleIndexTestModule) OutputFiles(tag string) (Paths, error) {
+	return Paths{m.outputFile}, nil
+}
+
+func TestModuleIndex(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("index_test_module", moduleIndexTestModuleFactory)
+		}),
+		FixtureAddTextFile("vendor/foo/Android.bp", `
+			index_test_module {
+				name: "foo",
+			}
+
+			index_test_module {
+				name: "disabled",
+				enabled: false,
+			}
+		`),
+		FixtureWithRootAndroidBp(`
+			index_test_module {
+				name: "bar",
+				installable: false,
+			}
+		`),
+	).RunTest(t)
+
+	buf := &bytes.Buffer{}
+	if err := WriteModuleIndex(result.TestContext.Context, buf); err != nil {
+		t.Fatalf("failed to write the module index: %s", err)
+	}
+
+	var entries []moduleIndexEntry
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatalf("failed to parse module_index.json: %s", err)
+	}
+	for i := range entries {
+		entries[i].Outputs = StringPathsRelativeToTop(result.Config.SoongOutDir(), entries[i].Outputs)
+		entries[i].Installs = StringPathsRelativeToTop(result.Config.SoongOutDir(), entries[i].Installs)
+	}
+
+	expected := []moduleIndexEntry{
+		{
+			Name:    "bar",
+			Type:    "index_test_module",
+			Dir:     ".",
+			Variant: "android_common",
+			Outputs: []string{"out/soong/.intermediates/bar/android_common/bar.txt"},
+		},
+		{
+			Name:     "foo",
+			Type:     "index_test_module",
+			Dir:      "vendor/foo",
+			Variant:  "android_common",
+			Outputs:  []string{"out/soong/.intermediates/vendor/foo/foo/android_common/foo.txt"},
+			Installs: []string{"out/soong/target/product/test_device/system/etc/foo.txt"},
+		},
+	}
+	if !reflect.DeepEqual(expected, entries) {
+		t.Errorf("incorrect module index\nexpected: %#v\nactual:   %#v", expected, entries)
+	}
+}
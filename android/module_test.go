@@ -443,6 +443,247 @@ func TestInstall(t *testing.T) {
 	assertOrderOnlys(symlinkRule("foo"))
 }
 
+func TestInstallAfter(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+	bp := `
+		deps {
+			name: "foo",
+			install_after: ["bar"],
+		}
+
+		deps {
+			name: "bar",
+			deps: ["baz"],
+		}
+
+		deps {
+			name: "baz",
+		}
+	`
+
+	result := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+	).RunTestWithBp(t, bp)
+
+	installRule := func(name string, host bool) TestingBuildParams {
+		variant, dir := "android_common", "out/soong/target/product/test_device/system"
+		if host {
+			variant, dir = result.Config.BuildOSCommonTarget.String(), "out/soong/host/linux-x86"
+		}
+		return result.ModuleForTests(name, variant).Output(filepath.Join(dir, name))
+	}
+
+	symlinkRule := func(name string, host bool) TestingBuildParams {
+		variant, dir := "android_common", "out/soong/target/product/test_device/system/symlinks"
+		if host {
+			variant, dir = result.Config.BuildOSCommonTarget.String(), "out/soong/host/linux-x86/symlinks"
+		}
+		return result.ModuleForTests(name, variant).Output(filepath.Join(dir, name))
+	}
+
+	// The install_after modules are order-only dependencies on both host and device, and are not
+	// treated as install dependencies.
+	for _, host := range []bool{false, true} {
+		foo := installRule("foo", host)
+		AssertIntEquals(t, "implicit dependencies", 0, len(foo.Implicits))
+		AssertArrayString(t, "expected orderonly dependencies", Paths{
+			installRule("bar", host).Output,
+			symlinkRule("bar", host).Output,
+		}.Strings(), foo.OrderOnly.Strings())
+	}
+}
+
+func TestInstallAfterCycle(t *testing.T) {
+	t.Run("self", func(t *testing.T) {
+		GroupFixturePreparers(
+			prepareForModuleTests,
+			PrepareForTestWithArchMutator,
+		).ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`module "foo".*: install_after: module "foo" cannot be installed after itself`)).
+			RunTestWithBp(t, `
+				deps {
+					name: "foo",
+					install_after: ["foo"],
+				}
+			`)
+	})
+
+	t.Run("indirect", func(t *testing.T) {
+		GroupFixturePreparers(
+			prepareForModuleTests,
+			PrepareForTestWithArchMutator,
+		).ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`encountered dependency cycle`)).
+			RunTestWithBp(t, `
+				deps {
+					name: "foo",
+					install_after: ["bar"],
+				}
+
+				deps {
+					name: "bar",
+					install_after: ["foo"],
+				}
+			`)
+	})
+}
+
+func TestInitRcCheck(t *testing.T) {
+	bp := `
+		deps {
+			name: "foo",
+			init_rc: ["foo.rc"],
+		}
+
+		deps {
+			name: "bar",
+			init_rc: ["bar.rc"],
+			skip_init_rc_check: true,
+		}
+
+		deps {
+			name: "host_init_verifier",
+		}
+	`
+
+	result := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+		FixtureMergeMockFs(MockFS{
+			"foo.rc": nil,
+			"bar.rc": nil,
+			"system/sepolicy/private/property_contexts": nil,
+		}),
+	).RunTestWithBp(t, bp)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	check := foo.Rule("hostInitVerifier")
+	AssertPathRelativeToTopEquals(t, "input", "foo.rc", check.Input)
+	AssertStringEquals(t, "property contexts", "system/sepolicy/private/property_contexts",
+		check.Args["propertyContexts"])
+
+	// The check is a validation of the install rule rather than a dependency, so that a change in
+	// the result of the check does not cause the module to be reinstalled.
+	install := foo.Output("out/soong/target/product/test_device/system/foo")
+	AssertPathsRelativeToTopEquals(t, "install validations", []string{check.Output.RelativeToTop().String()},
+		install.Validations)
+
+	bar := result.ModuleForTests("bar", "android_common")
+	if bar.MaybeRule("hostInitVerifier").Rule != nil {
+		t.Errorf("expected no hostInitVerifier rule with skip_init_rc_check: true")
+	}
+	AssertPathsRelativeToTopEquals(t, "install validations", nil,
+		bar.Output("out/soong/target/product/test_device/system/bar").Validations)
+}
+
+func TestInitRcCheckWithoutHostInitVerifier(t *testing.T) {
+	bp := `
+		deps {
+			name: "foo",
+			init_rc: ["foo.rc"],
+		}
+	`
+
+	result := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+		FixtureMergeMockFs(MockFS{
+			"foo.rc": nil,
+			"system/sepolicy/private/property_contexts": nil,
+		}),
+	).RunTestWithBp(t, bp)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	if foo.MaybeRule("hostInitVerifier").Rule != nil {
+		t.Errorf("expected no hostInitVerifier rule without a host_init_verifier module")
+	}
+}
+
+func TestConflictingPartitions(t *testing.T) {
+	testCases := []struct {
+		name   string
+		props  string
+		errors []string
+	}{
+		{
+			name:  "vendor and proprietary",
+			props: "vendor: true, proprietary: true",
+		},
+		{
+			name:  "proprietary and product_specific",
+			props: "proprietary: true, product_specific: true",
+			errors: []string{
+				`product_specific: a module cannot be specific to SoC or device and product at the same time.`,
+				`proprietary: conflicting value set here`,
+			},
+		},
+		{
+			name:  "vendor and device_specific",
+			props: "vendor: true, device_specific: true",
+			errors: []string{
+				`device_specific: a module cannot be specific to SoC and device at the same time.`,
+				`vendor: conflicting value set here`,
+			},
+		},
+		{
+			name:  "product_specific and system_ext_specific",
+			props: "product_specific: true, system_ext_specific: true",
+			errors: []string{
+				`product_specific: a module cannot be specific to product and system_ext at the same time.`,
+				`system_ext_specific: conflicting value set here`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prepareForModuleTests.
+				ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern(tc.errors)).
+				RunTestWithBp(t, `
+					deps {
+						name: "foo",
+						`+tc.props+`,
+					}
+				`)
+		})
+	}
+}
+
+func TestPartitionInAndroidMk(t *testing.T) {
+	testCases := []struct {
+		props     string
+		partition string
+	}{
+		{props: "", partition: "system"},
+		{props: "vendor: true, proprietary: true", partition: "vendor"},
+		{props: "device_specific: true", partition: "odm"},
+		{props: "product_specific: true", partition: "product"},
+		{props: "system_ext_specific: true", partition: "system_ext"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.partition, func(t *testing.T) {
+			result := GroupFixturePreparers(
+				prepareForModuleTests,
+				PrepareForTestWithArchMutator,
+			).RunTestWithBp(t, `
+				deps {
+					name: "foo",
+					`+tc.props+`
+				}
+			`)
+
+			foo := result.ModuleForTests("foo", "android_common").Module()
+			entries := AndroidMkEntriesForTest(t, result.TestContext, foo)[0]
+			AssertStringEquals(t, "LOCAL_SOONG_PARTITION", tc.partition,
+				entries.EntryMap["LOCAL_SOONG_PARTITION"][0])
+		})
+	}
+}
+
 func TestInstallKatiEnabled(t *testing.T) {
 	if runtime.GOOS != "linux" {
 		t.Skip("requires linux")
@@ -57,13 +57,34 @@ func RegisterMutatorsForBazelConversion(ctx *Context, preArchMutators []Register
 }
 
 // collateGloballyRegisteredMutators constructs the list of mutators that have been registered
-// with the InitRegistrationContext and will be used at runtime.
-func collateGloballyRegisteredMutators() sortableComponents {
-	return collateRegisteredMutators(preArch, preDeps, postDeps, finalDeps)
+// with the InitRegistrationContext and will be used at runtime with the given config.
+func collateGloballyRegisteredMutators(config Config) sortableComponents {
+	return collateRegisteredMutators(preArch, preDeps, postDeps, finalDeps, modeMutators(config))
+}
+
+// modeMutators returns the mutators that are only needed when soong_build runs in a particular
+// mode. They are registered after all other mutators so that they see the final variants and
+// dependencies of every module.
+func modeMutators(config Config) []RegisterMutatorFunc {
+	var mutators []RegisterMutatorFunc
+	if config.AllowMissingDependencies() {
+		mutators = append(mutators, registerModuleVariantsMutator)
+	}
+	if config.collectingModuleDepsGraph {
+		mutators = append(mutators, registerModuleDepsGraphMutator)
+	}
+	return mutators
+}
+
+// allModeMutators contains the mutators of every mode, in the order that modeMutators returns
+// them.
+var allModeMutators = []RegisterMutatorFunc{
+	registerModuleVariantsMutator,
+	registerModuleDepsGraphMutator,
 }
 
 // collateRegisteredMutators constructs a single list of mutators from the separate lists.
-func collateRegisteredMutators(preArch, preDeps, postDeps, finalDeps []RegisterMutatorFunc) sortableComponents {
+func collateRegisteredMutators(preArch, preDeps, postDeps, finalDeps, modeMutators []RegisterMutatorFunc) sortableComponents {
 	mctx := &registerMutatorsContext{}
 
 	register := func(funcs []RegisterMutatorFunc) {
@@ -83,6 +104,8 @@ func collateRegisteredMutators(preArch, preDeps, postDeps, finalDeps []RegisterM
 	mctx.finalPhase = true
 	register(finalDeps)
 
+	register(modeMutators)
+
 	return mctx.mutators
 }
 
@@ -492,6 +515,7 @@ func componentDepsMutator(ctx BottomUpMutatorContext) {
 
 func depsMutator(ctx BottomUpMutatorContext) {
 	if m := ctx.Module(); m.Enabled() {
+		m.base().baseDepsMutator(ctx)
 		m.DepsMutator(ctx)
 	}
 }
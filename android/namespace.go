@@ -89,6 +89,12 @@ type NameResolver struct {
 
 	// func telling whether to export a namespace to Kati
 	namespaceExportFilter func(*Namespace) bool
+
+	// Sorted names of all modules, used to suggest alternatives for missing dependencies.  It is
+	// only built when the first missing dependency is reported, and is dropped when a module is
+	// renamed.
+	moduleNamesLock sync.Mutex
+	moduleNames     []string
 }
 
 func NewNameResolver(namespaceExportFilter func(*Namespace) bool) *NameResolver {
@@ -122,6 +128,7 @@ func (r *NameResolver) addNewNamespaceForModule(module *NamespaceModule, path st
 	module.namespace = namespace
 	module.resolver = r
 	namespace.importedNamespaceNames = module.properties.Imports
+	namespace.exportedModules = module.properties.Exported_modules
 	return r.addNamespace(namespace)
 }
 
@@ -249,7 +256,7 @@ func (r *NameResolver) getNamespacesToSearchForModule(sourceNamespace blueprint.
 	return ns.visibleNamespaces
 }
 
-func (r *NameResolver) ModuleFromName(name string, namespace blueprint.Namespace) (group blueprint.ModuleGroup, found bool) {
+func (r *NameResolver) ModuleFromName(name string, dependerNamespace blueprint.Namespace) (group blueprint.ModuleGroup, found bool) {
 	// handle fully qualified references like "//namespace_path:module_name"
 	nsName, moduleName, isAbs := r.parseFullyQualifiedName(name)
 	if isAbs {
@@ -257,10 +264,16 @@ func (r *NameResolver) ModuleFromName(name string, namespace blueprint.Namespace
 		if !found {
 			return blueprint.ModuleGroup{}, false
 		}
+		if !namespace.visibleTo(dependerNamespace, moduleName) {
+			return blueprint.ModuleGroup{}, false
+		}
 		container := namespace.moduleContainer
 		return container.ModuleFromName(moduleName, nil)
 	}
-	for _, candidate := range r.getNamespacesToSearchForModule(namespace) {
+	for _, candidate := range r.getNamespacesToSearchForModule(dependerNamespace) {
+		if !candidate.visibleTo(dependerNamespace, name) {
+			continue
+		}
 		group, found = candidate.moduleContainer.ModuleFromName(name, nil)
 		if found {
 			return group, true
@@ -271,7 +284,11 @@ func (r *NameResolver) ModuleFromName(name string, namespace blueprint.Namespace
 }
 
 func (r *NameResolver) Rename(oldName string, newName string, namespace blueprint.Namespace) []error {
-	return namespace.(*Namespace).moduleContainer.Rename(oldName, newName, namespace)
+	errs := namespace.(*Namespace).moduleContainer.Rename(oldName, newName, namespace)
+	r.moduleNamesLock.Lock()
+	r.moduleNames = nil
+	r.moduleNamesLock.Unlock()
+	return errs
 }
 
 // resolve each element of namespace.importedNamespaceNames and put the result in namespace.visibleNamespaces
@@ -303,13 +320,27 @@ func (r *NameResolver) chooseId(namespace *Namespace) {
 func (r *NameResolver) MissingDependencyError(depender string, dependerNamespace blueprint.Namespace, depName string) (err error) {
 	text := fmt.Sprintf("%q depends on undefined module %q", depender, depName)
 
-	_, _, isAbs := r.parseFullyQualifiedName(depName)
+	nsName, moduleName, isAbs := r.parseFullyQualifiedName(depName)
 	if isAbs {
+		if namespace, found := r.namespaceAt(nsName); found {
+			if err := r.notExportedError(depender, dependerNamespace, namespace, moduleName); err != nil {
+				return err
+			}
+		}
 		// if the user gave a fully-qualified name, we don't need to look for other
 		// modules that they might have been referring to
 		return fmt.Errorf(text)
 	}
 
+	// report modules that would have been found if their namespace exported them
+	if dependerNs, ok := dependerNamespace.(*Namespace); ok {
+		for _, namespace := range r.getNamespacesToSearchForModule(dependerNs) {
+			if err := r.notExportedError(depender, dependerNs, namespace, depName); err != nil {
+				return err
+			}
+		}
+	}
+
 	// determine which namespaces the module can be found in
 	foundInNamespaces := []string{}
 	for _, namespace := range r.sortedNamespaces.sortedItems() {
@@ -328,11 +359,64 @@ func (r *NameResolver) MissingDependencyError(depender string, dependerNamespace
 		}
 		text += fmt.Sprintf("\nModule %q is defined in namespace %q which can read these %v namespaces: %q", depender, dependerNs.Path, len(importedNames), importedNames)
 		text += fmt.Sprintf("\nModule %q can be found in these namespaces: %q", depName, foundInNamespaces)
+	} else if suggestion := r.similarModuleName(depName); suggestion != "" {
+		text += fmt.Sprintf("\nDid you mean %q?", suggestion)
 	}
 
 	return fmt.Errorf(text)
 }
 
+// sortedModuleNames returns the sorted names of all modules in any namespace, building the list the
+// first time it is needed.
+func (r *NameResolver) sortedModuleNames() []string {
+	r.moduleNamesLock.Lock()
+	defer r.moduleNamesLock.Unlock()
+	if r.moduleNames == nil {
+		groups := r.AllModules()
+		names := make([]string, 0, len(groups))
+		for _, group := range groups {
+			names = append(names, group.Name())
+		}
+		r.moduleNames = SortedUniqueStrings(names)
+	}
+	return r.moduleNames
+}
+
+// similarModuleName returns the name of the module in any namespace that is closest to name, or an
+// empty string if no module name is close enough to be a likely typo.  It scans every module name,
+// so it should only be called when reporting an error.
+func (r *NameResolver) similarModuleName(name string) string {
+	maxDistance := len(name) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+
+	best := ""
+	bestDistance := maxDistance + 1
+	for _, candidate := range r.sortedModuleNames() {
+		if distance := EditDistance(name, candidate); distance < bestDistance {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+// notExportedError returns an error if moduleName exists in namespace but is hidden from
+// dependerNamespace by namespace's exported_modules property.
+func (r *NameResolver) notExportedError(depender string, dependerNamespace blueprint.Namespace,
+	namespace *Namespace, moduleName string) error {
+
+	if namespace.visibleTo(dependerNamespace, moduleName) {
+		return nil
+	}
+	if _, found := namespace.moduleContainer.ModuleFromName(moduleName, nil); !found {
+		return nil
+	}
+	return fmt.Errorf("%q depends on module %q, which exists in namespace %q but is not exported by it",
+		depender, moduleName, namespace.Path)
+}
+
 func (r *NameResolver) GetNamespace(ctx blueprint.NamespaceContext) blueprint.Namespace {
 	return r.findNamespaceFromCtx(ctx)
 }
@@ -364,6 +448,10 @@ type Namespace struct {
 
 	exportToKati bool
 
+	// glob patterns of module names that modules in other namespaces may reference, or nil if
+	// all modules are exported
+	exportedModules []string
+
 	moduleContainer blueprint.NameInterface
 }
 
@@ -373,10 +461,34 @@ func NewNamespace(path string) *Namespace {
 
 var _ blueprint.Namespace = (*Namespace)(nil)
 
+// visibleTo returns true if the module called name in this namespace may be referenced by modules
+// in dependerNamespace.  Modules can always reference other modules in their own namespace.
+func (n *Namespace) visibleTo(dependerNamespace blueprint.Namespace, name string) bool {
+	if n.exportedModules == nil {
+		return true
+	}
+	// When handling dependencies before namespaceMutator, assume they are non-Soong Blueprint
+	// modules and give access to all modules.
+	if dependerNs, ok := dependerNamespace.(*Namespace); !ok || dependerNs == n {
+		return true
+	}
+	for _, pattern := range n.exportedModules {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
 type namespaceProperties struct {
 	// a list of namespaces that contain modules that will be referenced
 	// by modules in this namespace.
 	Imports []string `android:"path"`
+
+	// a list of modules in this namespace that may be referenced by modules in
+	// other namespaces.  Entries may contain glob patterns such as "lib*".  If
+	// unset, all modules in this namespace are exported.
+	Exported_modules []string
 }
 
 type NamespaceModule struct {
@@ -430,6 +542,12 @@ func namespaceMutator(ctx BottomUpMutatorContext) {
 			ctx.ModuleErrorf(err.Error())
 		}
 
+		for _, pattern := range module.properties.Exported_modules {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				ctx.PropertyErrorf("exported_modules", "invalid pattern %q: %s", pattern, err)
+			}
+		}
+
 		module.resolver.chooseId(module.namespace)
 	}
 }
@@ -18,6 +18,7 @@ import (
 	"errors"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/blueprint"
@@ -182,6 +183,34 @@ Module "a" can be found in these namespaces: ["dir1" "dir2"]`),
 	}
 }
 
+func TestDependingOnMisspelledModule(t *testing.T) {
+	_, errs := setupTestExpectErrs(t,
+		map[string]string{
+			"dir1": `
+			soong_namespace {
+			}
+			test_module {
+				name: "libfoo",
+			}
+			test_module {
+				name: "b",
+				deps: ["libfo"],
+			}
+			`,
+		},
+	)
+
+	expectedErrors := []error{
+		errors.New(
+			`dir1/Android.bp:7:4: "b" depends on undefined module "libfo"
+Did you mean "libfoo"?`),
+	}
+
+	if len(errs) != 1 || errs[0].Error() != expectedErrors[0].Error() {
+		t.Errorf("Incorrect errors. Expected:\n%v\n, got:\n%v\n", expectedErrors, errs)
+	}
+}
+
 func TestDependingOnModuleByFullyQualifiedReference(t *testing.T) {
 	ctx := setupTest(t,
 		map[string]string{
@@ -209,6 +238,147 @@ func TestDependingOnModuleByFullyQualifiedReference(t *testing.T) {
 	}
 }
 
+func TestDependingOnExportedModule(t *testing.T) {
+	ctx := setupTest(t,
+		map[string]string{
+			"dir1": `
+			soong_namespace {
+				exported_modules: ["liba*"],
+			}
+			test_module {
+				name: "liba1",
+			}
+			test_module {
+				name: "liba2",
+			}
+			`,
+			"dir2": `
+			soong_namespace {
+				imports: ["dir1"],
+			}
+			test_module {
+				name: "b",
+				deps: ["liba1", "//dir1:liba2"],
+			}
+			`,
+		},
+	)
+	a1 := getModule(ctx, "liba1")
+	a2 := getModule(ctx, "liba2")
+	b := getModule(ctx, "b")
+	if !dependsOn(ctx, b, a1) {
+		t.Errorf("module b does not depend on module liba1")
+	}
+	if !dependsOn(ctx, b, a2) {
+		t.Errorf("module b does not depend on module liba2")
+	}
+}
+
+func TestDependingOnUnexportedModuleInSameNamespace(t *testing.T) {
+	ctx := setupTest(t,
+		map[string]string{
+			"dir1": `
+			soong_namespace {
+				exported_modules: [],
+			}
+			test_module {
+				name: "a",
+			}
+			test_module {
+				name: "b",
+				deps: ["a"],
+			}
+			`,
+		},
+	)
+	a := getModule(ctx, "a")
+	b := getModule(ctx, "b")
+	if !dependsOn(ctx, b, a) {
+		t.Errorf("module b does not depend on module a")
+	}
+}
+
+func TestDependingOnUnexportedModule(t *testing.T) {
+	_, errs := setupTestExpectErrs(t,
+		map[string]string{
+			"dir1": `
+			soong_namespace {
+				exported_modules: ["b"],
+			}
+			test_module {
+				name: "a",
+			}
+			test_module {
+				name: "b",
+			}
+			`,
+			"dir2": `
+			soong_namespace {
+				imports: ["dir1"],
+			}
+			test_module {
+				name: "c",
+				deps: ["a"],
+			}
+			`,
+		},
+	)
+
+	expectedErrors := []error{
+		errors.New(`dir2/Android.bp:4:4: "c" depends on module "a", which exists in namespace "dir1" but is not exported by it`),
+	}
+	if len(errs) != 1 || errs[0].Error() != expectedErrors[0].Error() {
+		t.Errorf("Incorrect errors. Expected:\n%v\n, got:\n%v\n", expectedErrors, errs)
+	}
+}
+
+func TestDependingOnUnexportedModuleByFullyQualifiedReference(t *testing.T) {
+	_, errs := setupTestExpectErrs(t,
+		map[string]string{
+			"dir1": `
+			soong_namespace {
+				exported_modules: ["b"],
+			}
+			test_module {
+				name: "a",
+			}
+			`,
+			"dir2": `
+			soong_namespace {
+			}
+			test_module {
+				name: "c",
+				deps: ["//dir1:a"],
+			}
+			`,
+		},
+	)
+
+	expectedErrors := []error{
+		errors.New(`dir2/Android.bp:4:4: "c" depends on module "a", which exists in namespace "dir1" but is not exported by it`),
+	}
+	if len(errs) != 1 || errs[0].Error() != expectedErrors[0].Error() {
+		t.Errorf("Incorrect errors. Expected:\n%v\n, got:\n%v\n", expectedErrors, errs)
+	}
+}
+
+func TestInvalidExportedModulesPattern(t *testing.T) {
+	_, errs := setupTestExpectErrs(t,
+		map[string]string{
+			"dir1": `
+			soong_namespace {
+				exported_modules: ["lib["],
+			}
+			`,
+		},
+	)
+
+	expectedError := `module "soong_namespace": exported_modules: invalid pattern "lib[": syntax error in pattern`
+	if len(errs) != 1 || !strings.HasSuffix(errs[0].Error(), expectedError) {
+		t.Errorf("Incorrect errors. Expected:\n%v\n, got:\n%v\n", expectedError, errs)
+	}
+}
+
 func TestSameNameInTwoNamespaces(t *testing.T) {
 	ctx := setupTest(t,
 		map[string]string{
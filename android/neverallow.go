@@ -54,6 +54,7 @@ func init() {
 	AddNeverAllowRules(createTrebleRules()...)
 	AddNeverAllowRules(createJavaDeviceForHostRules()...)
 	AddNeverAllowRules(createCcSdkVariantRules()...)
+	AddNeverAllowRules(createCcVendorStubsRules()...)
 	AddNeverAllowRules(createUncompressDexRules()...)
 	AddNeverAllowRules(createMakefileGoalRules()...)
 	AddNeverAllowRules(createInitFirstStageRules()...)
@@ -204,6 +205,23 @@ func createCcSdkVariantRules() []Rule {
 	}
 }
 
+func createCcVendorStubsRules() []Rule {
+	// Vendor stubs make a platform library part of the interface between the system and vendor
+	// partitions without making it LLNDK, so the libraries that may provide them are limited.
+	vendorStubsAllowedList := []string{
+		"frameworks/av",
+		"frameworks/native",
+		"system/core",
+	}
+
+	return []Rule{
+		NeverAllow().
+			NotIn(vendorStubsAllowedList...).
+			With("stubs.vendor_available", "true").
+			Because("stubs.vendor_available can only be used in allowed projects"),
+	}
+}
+
 func createUncompressDexRules() []Rule {
 	return []Rule{
 		NeverAllow().
@@ -290,6 +290,33 @@ var neverallowTests = []struct {
 			`module "outside_allowed_list": violates neverallow`,
 		},
 	},
+	{
+		name: `"stubs.vendor_available" outside allowed list`,
+		fs: map[string][]byte{
+			"Android.bp": []byte(`
+				cc_library {
+					name: "outside_allowed_list",
+					stubs: {
+						vendor_available: true,
+					},
+				}`),
+		},
+		expectedErrors: []string{
+			"stubs.vendor_available can only be used in allowed projects",
+		},
+	},
+	{
+		name: `"stubs.vendor_available" inside allowed list`,
+		fs: map[string][]byte{
+			"frameworks/native/Android.bp": []byte(`
+				cc_library {
+					name: "inside_allowed_list",
+					stubs: {
+						vendor_available: true,
+					},
+				}`),
+		},
+	},
 	{
 		name: "uncompress_dex inside art",
 		fs: map[string][]byte{
@@ -394,6 +421,10 @@ type mockCcLibraryProperties struct {
 	Platform struct {
 		Shared_libs []string
 	}
+
+	Stubs struct {
+		Vendor_available *bool
+	}
 }
 
 type mockCcLibraryModule struct {
@@ -0,0 +1,136 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+)
+
+func init() {
+	RegisterPartitionBuildinfoPropBuildComponents(InitRegistrationContext)
+}
+
+func RegisterPartitionBuildinfoPropBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterModuleType("partition_buildinfo_prop", partitionBuildinfoPropFactory)
+}
+
+type partitionBuildinfoPropModule struct {
+	ModuleBase
+
+	outputFilePath OutputPath
+	jsonFilePath   OutputPath
+	installPath    InstallPath
+}
+
+var _ OutputFileProducer = (*partitionBuildinfoPropModule)(nil)
+
+// OutputFileProducer
+func (p *partitionBuildinfoPropModule) OutputFiles(tag string) (Paths, error) {
+	switch tag {
+	case "":
+		return Paths{p.outputFilePath}, nil
+	case ".json":
+		return Paths{p.jsonFilePath}, nil
+	default:
+		return nil, fmt.Errorf("unsupported tag %q", tag)
+	}
+}
+
+// partition returns the name of the partition the properties describe, which is used to prefix
+// their keys.
+func (p *partitionBuildinfoPropModule) partition(ctx ModuleContext) string {
+	if ctx.SocSpecific() {
+		return "vendor"
+	} else if ctx.DeviceSpecific() {
+		return "odm"
+	} else if ctx.ProductSpecific() {
+		return "product"
+	} else if ctx.SystemExtSpecific() {
+		return "system_ext"
+	}
+	return "system"
+}
+
+func (p *partitionBuildinfoPropModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	p.outputFilePath = PathForModuleOut(ctx, p.Name()).OutputPath
+	p.jsonFilePath = PathForModuleOut(ctx, p.Name()+".json").OutputPath
+
+	config := ctx.Config()
+	partition := p.partition(ctx)
+	buildNumber := buildNumberFromFile(ctx)
+	date := buildDateCommand(config)
+
+	// Match the output of build/make/tools/buildinfo_common.sh.
+	b := newBuildPropBuilder(ctx)
+	b.writeString("####################################")
+	b.writeString("# from generate-common-build-props")
+	b.writeString("# These properties identify this partition image.")
+	b.writeString("####################################")
+
+	b.writeProp("ro.product."+partition+".brand", config.ProductBrand())
+	b.writeProp("ro.product."+partition+".device", config.DeviceName())
+	b.writeProp("ro.product."+partition+".manufacturer", config.ProductManufacturer())
+	b.writeProp("ro.product."+partition+".model", config.ProductModel())
+	b.writeProp("ro.product."+partition+".name", config.DeviceProduct())
+
+	prefix := "ro." + partition + ".build."
+	b.writeProp(prefix+"date", "$("+date+")")
+	b.writeProp(prefix+"date.utc", "$("+date+" +%s)")
+	// The fingerprint will be set dynamically by init, by appending the unique vbmeta digest.
+	if config.BoardUseVbmetaDigestInFingerprint() {
+		b.writeProp(prefix+"legacy.fingerprint", buildFingerprint(config, buildNumber))
+	} else {
+		b.writeProp(prefix+"fingerprint", buildFingerprint(config, buildNumber))
+	}
+	if thumbprint := buildThumbprint(config, buildNumber); thumbprint != "" {
+		b.writeProp(prefix+"thumbprint", thumbprint)
+	}
+	b.writeProp(prefix+"id", config.BuildId())
+	b.writeProp(prefix+"tags", buildVersionTags(config))
+	b.writeProp(prefix+"type", config.BuildVariant())
+	b.writeProp(prefix+"version.incremental", buildNumber)
+	b.writeProp(prefix+"version.release", config.PlatformVersionLastStable())
+	b.writeProp(prefix+"version.release_or_codename", config.PlatformVersionName())
+	b.writeProp(prefix+"version.sdk", config.PlatformSdkVersion().String())
+
+	b.build(p.outputFilePath, p.jsonFilePath)
+
+	p.installPath = PathForModuleInstall(ctx)
+	ctx.InstallFile(p.installPath, p.Name(), p.outputFilePath)
+}
+
+func (p *partitionBuildinfoPropModule) AndroidMkEntries() []AndroidMkEntries {
+	return []AndroidMkEntries{AndroidMkEntries{
+		Class:      "ETC",
+		OutputFile: OptionalPathForPath(p.outputFilePath),
+		ExtraEntries: []AndroidMkExtraEntriesFunc{
+			func(ctx AndroidMkExtraEntriesContext, entries *AndroidMkEntries) {
+				entries.SetString("LOCAL_MODULE_PATH", p.installPath.String())
+				entries.SetString("LOCAL_INSTALLED_MODULE_STEM", p.outputFilePath.Base())
+			},
+		},
+	}}
+}
+
+// partition_buildinfo_prop module generates a build.prop fragment containing the common
+// properties that identify a partition image, such as ro.<partition>.build.fingerprint and
+// ro.product.<partition>.*.  The partition is selected with the usual vendor, odm,
+// product_specific or system_ext_specific properties, and the file is installed into it.  It is
+// the Soong equivalent of build/make/tools/buildinfo_common.sh.
+func partitionBuildinfoPropFactory() Module {
+	module := &partitionBuildinfoPropModule{}
+	InitAndroidModule(module)
+	return module
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"strings"
+	"testing"
+)
+
+var prepareForPartitionBuildinfoPropTest = GroupFixturePreparers(
+	prepareForBuildinfoPropTest,
+	FixtureRegisterWithContext(RegisterPartitionBuildinfoPropBuildComponents),
+	FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+		variables.ProductBrand = stringPtr("Android")
+		variables.ProductManufacturer = stringPtr("Google")
+		variables.ProductModel = stringPtr("AOSP on ARM64")
+		variables.Eng = boolPtr(false)
+		variables.Debuggable = boolPtr(true)
+	}),
+)
+
+func TestPartitionBuildinfoProp(t *testing.T) {
+	result := prepareForPartitionBuildinfoPropTest.RunTestWithBp(t, `
+		partition_buildinfo_prop {
+			name: "vendor_buildinfo.prop",
+			vendor: true,
+		}
+	`)
+
+	module := result.ModuleForTests("vendor_buildinfo.prop", "")
+	AssertPathRelativeToTopEquals(t, "install path", "out/soong/target/product/generic_arm64/vendor",
+		module.Module().(*partitionBuildinfoPropModule).installPath)
+
+	AssertDeepEquals(t, "build.prop", []string{
+		"####################################",
+		"# from generate-common-build-props",
+		"# These properties identify this partition image.",
+		"####################################",
+		"ro.product.vendor.brand=Android",
+		"ro.product.vendor.device=generic_arm64",
+		"ro.product.vendor.manufacturer=Google",
+		"ro.product.vendor.model=AOSP on ARM64",
+		"ro.product.vendor.name=aosp_arm64",
+		"ro.vendor.build.date=$$(date -d @$$(cat out/build_date.txt))",
+		"ro.vendor.build.date.utc=$$(date -d @$$(cat out/build_date.txt) +%s)",
+		"ro.vendor.build.fingerprint=Android/aosp_arm64/generic_arm64:13/TQ1A.221205.011/$$(cat out/soong/build_number.txt):userdebug/release-keys,test-keys",
+		"ro.vendor.build.id=TQ1A.221205.011",
+		"ro.vendor.build.tags=release-keys,test-keys",
+		"ro.vendor.build.type=userdebug",
+		"ro.vendor.build.version.incremental=$$(cat out/soong/build_number.txt)",
+		"ro.vendor.build.version.release=13",
+		"ro.vendor.build.version.release_or_codename=13",
+		"ro.vendor.build.version.sdk=33",
+	}, buildPropLines(t, module))
+}
+
+func TestPartitionBuildinfoPropProduct(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForPartitionBuildinfoPropTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.OemThumbprintProperties = []string{"ro.product.brand"}
+		}),
+	).RunTestWithBp(t, `
+		partition_buildinfo_prop {
+			name: "product_buildinfo.prop",
+			product_specific: true,
+		}
+	`)
+
+	module := result.ModuleForTests("product_buildinfo.prop", "")
+	AssertPathRelativeToTopEquals(t, "install path", "out/soong/target/product/generic_arm64/product",
+		module.Module().(*partitionBuildinfoPropModule).installPath)
+
+	lines := buildPropLines(t, module)
+	AssertStringListContains(t, "brand", lines, "ro.product.product.brand=Android")
+	AssertStringListContains(t, "fingerprint", lines,
+		"ro.product.build.fingerprint=Android/aosp_arm64/generic_arm64:13/TQ1A.221205.011/$$(cat out/soong/build_number.txt):userdebug/release-keys,test-keys")
+	AssertStringListContains(t, "thumbprint", lines,
+		"ro.product.build.thumbprint=13/TQ1A.221205.011/$$(cat out/soong/build_number.txt):userdebug/release-keys,test-keys")
+	AssertStringListContains(t, "sdk", lines, "ro.product.build.version.sdk=33")
+}
+
+func TestPartitionBuildinfoPropVbmetaDigestInFingerprint(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForPartitionBuildinfoPropTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.BoardUseVbmetaDigestInFingerprint = boolPtr(true)
+		}),
+	).RunTestWithBp(t, `
+		partition_buildinfo_prop {
+			name: "vendor_buildinfo.prop",
+			vendor: true,
+		}
+	`)
+
+	lines := buildPropLines(t, result.ModuleForTests("vendor_buildinfo.prop", ""))
+	AssertStringListContains(t, "legacy fingerprint", lines,
+		"ro.vendor.build.legacy.fingerprint=Android/aosp_arm64/generic_arm64:13/TQ1A.221205.011/$$(cat out/soong/build_number.txt):userdebug/release-keys,test-keys")
+	AssertStringListContains(t, "id", lines, "ro.vendor.build.id=TQ1A.221205.011")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "ro.vendor.build.fingerprint=") {
+			t.Errorf("expected the fingerprint to be left for init to set, found %q", line)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+func init() {
+	RegisterPartitionSizeCheckBuildComponents(InitRegistrationContext)
+}
+
+func RegisterPartitionSizeCheckBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("partition_size_check", partitionSizeCheckSingletonFactory)
+}
+
+var PrepareForTestWithPartitionSizeCheck = FixtureRegisterWithContext(RegisterPartitionSizeCheckBuildComponents)
+
+var (
+	_ = pctx.SourcePathVariable("checkPartitionSizePath", "build/soong/scripts/check_partition_size.sh")
+
+	// A rule for summing the sizes of the files installed to a partition and reporting the largest
+	// modules when the total is close to the size limit of the partition.
+	checkPartitionSize = pctx.AndroidStaticRule("checkPartitionSize",
+		blueprint.RuleParams{
+			Command: "$checkPartitionSizePath -p $partition -i ${in} -l $limit -m $margin " +
+				"$enforceFlag -o ${out}",
+			CommandDeps: []string{"$checkPartitionSizePath"},
+		},
+		"partition", "limit", "margin", "enforceFlag")
+)
+
+func partitionSizeCheckSingletonFactory() Singleton {
+	return &partitionSizeCheckSingleton{}
+}
+
+type partitionSizeCheckSingleton struct{}
+
+// GenerateBuildActions creates a rule for each partition with a size limit that compares the total
+// size of the files installed to it by the Soong modules in the product packages with the limit. The sizes are only known at
+// build time, so the files are listed with the module installing them and stat'ed by the rule,
+// which reports the largest modules when the total is within the margin below the limit. This
+// catches partitions that are about to overflow long before the image is built.
+func (partitionSizeCheckSingleton) GenerateBuildActions(ctx SingletonContext) {
+	limits := ctx.DeviceConfig().PartitionSizeLimits()
+	if len(limits) == 0 {
+		return
+	}
+
+	type installedFile struct {
+		module    string
+		partition string
+		path      Path
+	}
+
+	// Index the built file of every file installed by a module by its install path, and the
+	// modules by name.  Variants that are not installed, like the APEX variants, stubs or the
+	// source modules replaced by prebuilts, are skipped.
+	installed := make(map[string]installedFile)
+	modulesByName := make(map[string][]Module)
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() || module.Os().Class != Device || module.IsSkipInstall() {
+			return
+		}
+		name := ctx.ModuleName(module)
+		modulesByName[name] = append(modulesByName[name], module)
+
+		specs := make(map[string]PackagingSpec)
+		for _, spec := range module.PackagingSpecs() {
+			if spec.srcPath != nil {
+				specs[filepath.Join(spec.partition, spec.relPathInPackage)] = spec
+			}
+		}
+		for _, path := range module.FilesToInstall() {
+			rel, err := filepath.Rel(path.PartitionDir(), path.String())
+			if err != nil {
+				continue
+			}
+			if spec, ok := specs[filepath.Join(path.partition, rel)]; ok {
+				installed[path.String()] = installedFile{name, spec.partition, spec.srcPath}
+			}
+		}
+	})
+
+	// Only the product packages, the files they need installed and the modules they require are
+	// installed to the partitions.  Each install path is counted once, even when it is needed by
+	// more than one module.
+	installedFiles := make(map[string][]installedFile)
+	counted := make(map[string]bool)
+	visited := make(map[string]bool)
+	queue := append([]string(nil), ctx.DeviceConfig().ProductPackages()...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if visited[name] {
+			continue
+		}
+		visited[name] = true
+		for _, module := range modulesByName[name] {
+			for _, path := range module.base().installFilesDepSet.ToList() {
+				file, ok := installed[path.String()]
+				if !ok || counted[path.String()] {
+					continue
+				}
+				counted[path.String()] = true
+				if _, ok := limits[file.partition]; ok {
+					installedFiles[file.partition] = append(installedFiles[file.partition], file)
+				}
+			}
+			queue = append(queue, module.RequiredModuleNames()...)
+		}
+	}
+
+	enforceFlag := ""
+	if ctx.DeviceConfig().EnforcePartitionSizeLimits() {
+		enforceFlag = "-e"
+	}
+	margin := strconv.Itoa(ctx.DeviceConfig().PartitionSizeReportMarginPercent())
+
+	var reports Paths
+	for _, partition := range SortedStringKeys(limits) {
+		var lines []string
+		var implicits Paths
+		for _, file := range installedFiles[partition] {
+			lines = append(lines, file.module+" "+file.path.String())
+			implicits = append(implicits, file.path)
+		}
+		listFile := PathForOutput(ctx, "partition_sizes", partition+"_installed_files.txt")
+		WriteFileRule(ctx, listFile, strings.Join(lines, "\n"))
+
+		report := PathForOutput(ctx, "partition_sizes", partition+"_size_report.txt")
+		ctx.Build(pctx, BuildParams{
+			Rule:        checkPartitionSize,
+			Description: "check partition size " + partition,
+			Input:       listFile,
+			Implicits:   implicits,
+			Output:      report,
+			Args: map[string]string{
+				"partition":   partition,
+				"limit":       strconv.FormatInt(limits[partition], 10),
+				"margin":      margin,
+				"enforceFlag": enforceFlag,
+			},
+		})
+		reports = append(reports, report)
+	}
+
+	ctx.Phony("checkbuild", reports...)
+}
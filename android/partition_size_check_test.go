@@ -0,0 +1,176 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+type partitionSizeTestModule struct {
+	ModuleBase
+	properties struct {
+		Filename     *string
+		Install_deps []string
+		Skip_install *bool
+	}
+}
+
+var partitionSizeTestInstallDepTag = struct {
+	blueprint.BaseDependencyTag
+	InstallAlwaysNeededDependencyTag
+}{}
+
+func partitionSizeTestModuleFactory() Module {
+	m := &partitionSizeTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, DeviceSupported, MultilibCommon)
+	return m
+}
+
+func (m *partitionSizeTestModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddVariationDependencies(nil, partitionSizeTestInstallDepTag, m.properties.Install_deps...)
+}
+
+func (m *partitionSizeTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	outputFile := PathForModuleOut(ctx, ctx.ModuleName())
+	ctx.Build(pctx, BuildParams{
+		Rule:   Touch,
+		Output: outputFile,
+	})
+	if Bool(m.properties.Skip_install) {
+		m.SkipInstall()
+	}
+	ctx.InstallFile(PathForModuleInstall(ctx, "etc"), String(m.properties.Filename), outputFile)
+}
+
+var prepareForPartitionSizeCheckTest = GroupFixturePreparers(
+	PrepareForTestWithPartitionSizeCheck,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("install_test_module", partitionSizeTestModuleFactory)
+	}),
+	FixtureWithRootAndroidBp(`
+		install_test_module {
+			name: "foo",
+			filename: "foo.xml",
+			install_deps: ["shared"],
+		}
+
+		install_test_module {
+			name: "bar",
+			filename: "bar.xml",
+			install_deps: ["shared"],
+			required: ["required"],
+		}
+
+		install_test_module {
+			name: "shared",
+			filename: "shared.xml",
+		}
+
+		install_test_module {
+			name: "required",
+			filename: "required.xml",
+		}
+
+		install_test_module {
+			name: "not_a_product_package",
+			filename: "other.xml",
+		}
+
+		install_test_module {
+			name: "skipped",
+			filename: "skipped.xml",
+			skip_install: true,
+		}
+
+		install_test_module {
+			name: "vendor_foo",
+			filename: "foo.xml",
+			vendor: true,
+		}
+	`),
+	FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+		variables.ProductPackages = []string{"foo", "bar", "skipped", "vendor_foo"}
+	}),
+)
+
+func TestPartitionSizeCheck(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForPartitionSizeCheckTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.PartitionSizeLimits = map[string]int64{"system": 10}
+		}),
+	).RunTest(t)
+
+	singleton := result.SingletonForTests("partition_size_check")
+
+	listFile := singleton.Output("partition_sizes/system_installed_files.txt")
+	lines := strings.Split(StringRelativeToTop(result.Config, ContentFromFileRuleForTests(t, listFile)), "\n")
+	sort.Strings(lines)
+	// The files of the product packages, their install dependencies and required modules are counted
+	// once each, the files of other modules and skipped installs are not.
+	AssertDeepEquals(t, "system installed files", []string{
+		"bar out/soong/.intermediates/bar/android_common/bar",
+		"foo out/soong/.intermediates/foo/android_common/foo",
+		"required out/soong/.intermediates/required/android_common/required",
+		"shared out/soong/.intermediates/shared/android_common/shared",
+	}, lines)
+
+	check := singleton.Rule("checkPartitionSize")
+	AssertPathRelativeToTopEquals(t, "report", "out/soong/partition_sizes/system_size_report.txt", check.Output)
+	AssertPathsRelativeToTopEquals(t, "implicits", []string{
+		"out/soong/.intermediates/bar/android_common/bar",
+		"out/soong/.intermediates/foo/android_common/foo",
+		"out/soong/.intermediates/required/android_common/required",
+		"out/soong/.intermediates/shared/android_common/shared",
+	}, SortedUniquePaths(check.Implicits))
+	AssertStringEquals(t, "limit", "10", check.Args["limit"])
+	AssertStringEquals(t, "margin", "5", check.Args["margin"])
+	AssertStringEquals(t, "enforce flag", "", check.Args["enforceFlag"])
+
+	// The vendor partition has no size limit.
+	vendorReport := singleton.MaybeOutput("partition_sizes/vendor_size_report.txt")
+	AssertBoolEquals(t, "vendor report created", false, vendorReport.Rule != nil)
+}
+
+func TestPartitionSizeCheckEnforced(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForPartitionSizeCheckTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.PartitionSizeLimits = map[string]int64{"vendor": 1}
+			variables.PartitionSizeReportMarginPercent = intPtr(10)
+			variables.EnforcePartitionSizeLimits = boolPtr(true)
+		}),
+	).RunTest(t)
+
+	check := result.SingletonForTests("partition_size_check").Output("partition_sizes/vendor_size_report.txt")
+	AssertPathsRelativeToTopEquals(t, "implicits", []string{
+		"out/soong/.intermediates/vendor_foo/android_common/vendor_foo",
+	}, check.Implicits)
+	AssertStringEquals(t, "limit", "1", check.Args["limit"])
+	AssertStringEquals(t, "margin", "10", check.Args["margin"])
+	AssertStringEquals(t, "enforce flag", "-e", check.Args["enforceFlag"])
+}
+
+func TestPartitionSizeCheckWithoutLimits(t *testing.T) {
+	result := prepareForPartitionSizeCheckTest.RunTest(t)
+
+	rule := result.SingletonForTests("partition_size_check").MaybeRule("checkPartitionSize")
+	AssertBoolEquals(t, "check created", false, rule.Rule != nil)
+}
@@ -23,6 +23,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/google/blueprint"
 	"github.com/google/blueprint/bootstrap"
@@ -410,6 +411,11 @@ type SourceInput struct {
 	Paths        []string
 	ExcludePaths []string
 	IncludeDirs  bool
+
+	// ExcludeGeneratedPaths applies the file and glob patterns in ExcludePaths to the paths
+	// produced by module references in Paths as well, matching them against the Rel() of the
+	// generated paths. It has no effect if Paths contains no module references.
+	ExcludeGeneratedPaths bool
 }
 
 // PathsForModuleSrcExcludes returns a Paths{} containing the resolved references in paths, minus
@@ -507,7 +513,7 @@ func getPathsFromModuleDep(ctx ModuleWithDepsPathContext, path, moduleName, tag
 		goBinaryPath := PathForGoBinary(ctx, goBinary)
 		return Paths{goBinaryPath}, nil
 	} else if srcProducer, ok := module.(SourceFileProducer); ok {
-		return srcProducer.Srcs(), nil
+		return sourceFileProducerSrcs(ctx, srcProducer), nil
 	} else {
 		return nil, fmt.Errorf("path dependency %q is not a source file producing module", path)
 	}
@@ -614,9 +620,108 @@ func PathsAndMissingDepsRelativeToModuleSourceDir(input SourceInput) (Paths, []s
 		expandedSrcFiles = append(expandedSrcFiles, srcFiles...)
 	}
 
+	if input.ExcludeGeneratedPaths && hasModuleReference(input.Paths) {
+		expandedSrcFiles = excludeGeneratedPaths(input.Context, expandedSrcFiles, input.Paths,
+			input.ExcludePaths)
+	}
+
 	return expandedSrcFiles, append(missingDeps, missingExcludeDeps...)
 }
 
+// excludeGeneratedPaths removes the generated paths whose Rel() matches one of the file or glob
+// patterns in excludes. Module references in excludes have already been applied and are ignored.
+// A pattern that matches neither a generated path nor one of the files listed in srcs is reported
+// as an error, once for all variants of the module, as it is most likely stale. The check is
+// skipped when srcs contains globs, as the files they matched are not known anymore.
+func excludeGeneratedPaths(ctx EarlyModulePathContext, paths Paths, srcs, excludes []string) Paths {
+	var patterns []string
+	for _, e := range excludes {
+		if m, _ := SrcIsModuleWithTag(e); m == "" {
+			patterns = append(patterns, e)
+		}
+	}
+	if len(patterns) == 0 {
+		return paths
+	}
+
+	matched := make(map[string]bool, len(patterns))
+	remainder := make(Paths, 0, len(paths))
+	for _, p := range paths {
+		excluded := false
+		if _, generated := p.(WritablePath); generated {
+			for _, pattern := range patterns {
+				match, err := pathtools.Match(pattern, p.Rel())
+				if err != nil {
+					ReportPathErrorf(ctx, "exclude pattern %q: %s", pattern, err.Error())
+					return paths
+				}
+				if match {
+					matched[pattern] = true
+					excluded = true
+				}
+			}
+		}
+		if !excluded {
+			remainder = append(remainder, p)
+		}
+	}
+
+	var srcFiles []string
+	for _, s := range srcs {
+		if m, _ := SrcIsModuleWithTag(s); m != "" {
+			continue
+		}
+		if pathtools.IsGlob(s) {
+			return remainder
+		}
+		srcFiles = append(srcFiles, s)
+	}
+
+	for _, pattern := range patterns {
+		if matched[pattern] {
+			continue
+		}
+		matchesSrc := false
+		for _, src := range srcFiles {
+			if match, _ := pathtools.Match(pattern, src); match {
+				matchesSrc = true
+				break
+			}
+		}
+		if !matchesSrc && firstUnmatchedExcludeReport(ctx, pattern) {
+			ReportPathErrorf(ctx, "exclude pattern %q does not match any source or generated file", pattern)
+		}
+	}
+
+	return remainder
+}
+
+// hasModuleReference returns true if paths contains a ":module" reference.
+func hasModuleReference(paths []string) bool {
+	for _, p := range paths {
+		if m, _ := SrcIsModuleWithTag(p); m != "" {
+			return true
+		}
+	}
+	return false
+}
+
+var unmatchedExcludeReportsKey = NewOnceKey("unmatchedExcludeReports")
+
+// firstUnmatchedExcludeReport returns true the first time it is called for a pattern of a module,
+// so that an unmatched exclude pattern is only reported once for all variants of the module.
+func firstUnmatchedExcludeReport(ctx EarlyModulePathContext, pattern string) bool {
+	reports := ctx.Config().Once(unmatchedExcludeReportsKey, func() interface{} {
+		return &sync.Map{}
+	}).(*sync.Map)
+	name := ctx.ModuleDir()
+	if m, ok := ctx.(interface{ ModuleName() string }); ok {
+		name += ":" + m.ModuleName()
+	}
+	_, reported := reports.LoadOrStore(name+" "+pattern, true)
+	return !reported
+}
+
 type missingDependencyError struct {
 	missingDeps []string
 }
@@ -1751,7 +1856,18 @@ func PathForModuleInstall(ctx ModuleInstallPathContext, pathComponents ...string
 // PathForHostDexInstall returns an InstallPath representing the install path for the
 // module appended with paths...
 func PathForHostDexInstall(ctx ModuleInstallPathContext, pathComponents ...string) InstallPath {
-	return makePathForInstall(ctx, ctx.Config().BuildOS, ctx.Config().BuildArch, "", ctx.Debug(), pathComponents...)
+	os, arch := hostDexOsAndArch(ctx)
+	return makePathForInstall(ctx, os, arch, "", ctx.Debug(), pathComponents...)
+}
+
+// hostDexOsAndArch returns the OS and arch that the -hostdex copies of device modules are installed
+// for.  They are installed next to the linux_bionic host tools if the build prefers them, so that
+// they can be run by host ART built against bionic, and for the build OS otherwise.
+func hostDexOsAndArch(ctx PathContext) (OsType, ArchType) {
+	if target, ok := ctx.Config().LinuxBionicHostToolTarget(); ok {
+		return target.Os, target.Arch.ArchType
+	}
+	return ctx.Config().BuildOS, ctx.Config().BuildArch
 }
 
 // PathForModuleInPartitionInstall is similar to PathForModuleInstall but partition is provided by the caller
@@ -1933,6 +2049,26 @@ func (p InstallPaths) Strings() []string {
 	return ret
 }
 
+// ValidateRelativeInstallPath returns an error if path, the value of a property like
+// relative_install_path or sub_dir that places installed files in a subdirectory, could place files
+// outside of the directory of the module in the partition, or in APEXes and filesystem images that
+// use the same value to lay out their contents. An empty path is valid.
+//
+// Paths that only stay inside the directory lexically, like "foo/../bar", are rejected as well, as
+// they escape it if "foo" is a symlink. Installing files beneath symlinks that are installed by
+// other modules is reported by the install_symlink_check singleton.
+func ValidateRelativeInstallPath(path string) error {
+	if _, err := validatePath(path); err != nil {
+		return fmt.Errorf("invalid relative install path %q: %s", path, err)
+	}
+	for _, component := range strings.Split(path, "/") {
+		if component == ".." {
+			return fmt.Errorf("invalid relative install path %q: must not contain \"..\"", path)
+		}
+	}
+	return nil
+}
+
 // validateSafePath validates a path that we trust (may contain ninja variables).
 // Ensures that each path component does not attempt to leave its component.
 func validateSafePath(pathComponents ...string) (string, error) {
@@ -122,6 +122,56 @@ func TestValidateSafePath(t *testing.T) {
 	}
 }
 
+func TestValidateRelativeInstallPath(t *testing.T) {
+	testCases := []struct {
+		path string
+		err  string
+	}{
+		{path: ""},
+		{path: "foo"},
+		{path: "foo/bar"},
+		{path: "foo/..bar"},
+		{path: "foo/bar/"},
+		{
+			path: "foo/$bar",
+			err:  `invalid relative install path "foo/$bar": Path contains invalid character($): foo/$bar`,
+		},
+		{
+			path: "/foo",
+			err:  `invalid relative install path "/foo": Path is outside directory: /foo`,
+		},
+		{
+			path: "..",
+			err:  `invalid relative install path "..": Path is outside directory: ..`,
+		},
+		{
+			path: "../foo",
+			err:  `invalid relative install path "../foo": Path is outside directory: ../foo`,
+		},
+		{
+			path: "foo/../../bar",
+			err:  `invalid relative install path "foo/../../bar": Path is outside directory: ../bar`,
+		},
+		{
+			// Doesn't leave the directory lexically, but would if foo is a symlink.
+			path: "foo/../bar",
+			err:  `invalid relative install path "foo/../bar": must not contain ".."`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.path, func(t *testing.T) {
+			err := ValidateRelativeInstallPath(testCase.path)
+			if testCase.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+			} else {
+				AssertErrorMessageEquals(t, "error", testCase.err, err)
+			}
+		})
+	}
+}
+
 func TestValidatePath(t *testing.T) {
 	for _, testCase := range validatePathTestCases {
 		t.Run(strings.Join(testCase.in, ","), func(t *testing.T) {
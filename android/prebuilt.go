@@ -26,9 +26,18 @@ import (
 // This file implements common functionality for handling modules that may exist as prebuilts,
 // source, or both.
 
+func init() {
+	registerModuleFamiliesSingleton(InitRegistrationContext)
+}
+
 func RegisterPrebuiltMutators(ctx RegistrationContext) {
 	ctx.PreArchMutators(RegisterPrebuiltsPreArchMutators)
 	ctx.PostDepsMutators(RegisterPrebuiltsPostDepsMutators)
+	registerModuleFamiliesSingleton(ctx)
+}
+
+func registerModuleFamiliesSingleton(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("module_families", moduleFamiliesSingletonFactory)
 }
 
 // Marks a dependency tag as possibly preventing a reference to a source from being
@@ -72,6 +81,14 @@ type UserSuppliedPrebuiltProperties struct {
 	// If specified then the prefer property is ignored in favor of the value of the Soong config
 	// variable.
 	Use_source_config_var *ConfigVarProperties
+
+	// The name of the module family, e.g. a mainline module, that this prebuilt belongs to.
+	//
+	// If the product selects the source or the prebuilt modules of the family in
+	// ModuleFamilySelection then the prefer and use_source_config_var properties are ignored in
+	// favor of that selection, so that all the members of the family switch together. It is an
+	// error for some members of a family to use their prebuilt while others use their source.
+	Module_family *string
 }
 
 // CopyUserSuppliedPropertiesFromPrebuilt copies the user supplied prebuilt properties from the
@@ -471,6 +488,19 @@ func (p *Prebuilt) usePrebuilt(ctx TopDownMutatorContext, source Module, prebuil
 		return true
 	}
 
+	// If the product selected the source or the prebuilts of the module family then that overrides
+	// both the use_source_config_var and the prefer property settings.
+	if family := proptools.String(p.properties.Module_family); family != "" {
+		usePrebuilts, selected, err := ctx.Config().ModuleFamilyUsesPrebuilts(family)
+		if err != nil {
+			ctx.PropertyErrorf("module_family", "%s", err)
+			return false
+		}
+		if selected {
+			return usePrebuilts
+		}
+	}
+
 	// If the use_source_config_var property is set then it overrides the prefer property setting.
 	if configVar := p.properties.Use_source_config_var; configVar != nil {
 		return !ctx.Config().VendorConfig(proptools.String(configVar.Config_namespace)).Bool(proptools.String(configVar.Var_name))
@@ -483,3 +513,49 @@ func (p *Prebuilt) usePrebuilt(ctx TopDownMutatorContext, source Module, prebuil
 func (p *Prebuilt) SourceExists() bool {
 	return p.properties.SourceExists
 }
+
+func moduleFamiliesSingletonFactory() Singleton {
+	return &moduleFamiliesSingleton{}
+}
+
+type moduleFamiliesSingleton struct{}
+
+// GenerateBuildActions reports every module family whose members do not consistently use either
+// their prebuilts or their sources, e.g. because only some of them set prefer or because the
+// source of a member does not exist.
+func (moduleFamiliesSingleton) GenerateBuildActions(ctx SingletonContext) {
+	// The members of each family, and whether any variant of each member uses its prebuilt.
+	families := make(map[string]map[string]bool)
+	ctx.VisitAllModules(func(module Module) {
+		p := GetEmbeddedPrebuilt(module)
+		if p == nil || !module.Enabled() {
+			return
+		}
+		family := proptools.String(p.properties.Module_family)
+		if family == "" {
+			return
+		}
+		if families[family] == nil {
+			families[family] = make(map[string]bool)
+		}
+		name := module.base().BaseModuleName()
+		families[family][name] = families[family][name] || p.properties.UsePrebuilt
+	})
+
+	for _, family := range SortedStringKeys(families) {
+		var prebuilts, sources []string
+		for _, name := range SortedStringKeys(families[family]) {
+			if families[family][name] {
+				prebuilts = append(prebuilts, name)
+			} else {
+				sources = append(sources, name)
+			}
+		}
+		if len(prebuilts) > 0 && len(sources) > 0 {
+			ctx.Errorf("module family %q is inconsistent, the prebuilts of %s are used but the sources of %s are used.\n"+
+				"Select one of them for the whole family with %q or %q in ModuleFamilySelection.",
+				family, strings.Join(prebuilts, ", "), strings.Join(sources, ", "),
+				family+":source", family+":prebuilt")
+		}
+	}
+}
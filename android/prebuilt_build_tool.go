@@ -17,9 +17,15 @@ package android
 import "path/filepath"
 
 func init() {
-	RegisterModuleType("prebuilt_build_tool", prebuiltBuildToolFactory)
+	registerPrebuiltBuildToolBuildComponents(InitRegistrationContext)
 }
 
+func registerPrebuiltBuildToolBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterModuleType("prebuilt_build_tool", prebuiltBuildToolFactory)
+}
+
+var PrepareForTestWithPrebuiltBuildTool = FixtureRegisterWithContext(registerPrebuiltBuildToolBuildComponents)
+
 type prebuiltBuildToolProperties struct {
 	// Source file to be executed for this build tool
 	Src *string `android:"path,arch_variant"`
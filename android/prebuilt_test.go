@@ -375,6 +375,61 @@ func TestPrebuilts(t *testing.T) {
 			// Although the environment variable says to use source there is no source available.
 			prebuilt: []OsType{Android, buildOS},
 		},
+		{
+			name: "prebuilt module_family - myfamily:prebuilt",
+			modules: `
+				source {
+					name: "bar",
+				}
+
+				prebuilt {
+					name: "bar",
+					module_family: "myfamily",
+					srcs: ["prebuilt_file"],
+				}`,
+			preparer: FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.ModuleFamilySelection = []string{"myfamily:prebuilt"}
+			}),
+			prebuilt: []OsType{Android, buildOS},
+		},
+		{
+			name: "prefer prebuilt module_family - myfamily:source",
+			modules: `
+				source {
+					name: "bar",
+				}
+
+				prebuilt {
+					name: "bar",
+					prefer: true,
+					module_family: "myfamily",
+					srcs: ["prebuilt_file"],
+				}`,
+			preparer: FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.ModuleFamilySelection = []string{"myfamily:source"}
+			}),
+			// The selection of the family overrides prefer.
+			prebuilt: nil,
+		},
+		{
+			name: "prefer prebuilt module_family - other family selected",
+			modules: `
+				source {
+					name: "bar",
+				}
+
+				prebuilt {
+					name: "bar",
+					prefer: true,
+					module_family: "myfamily",
+					srcs: ["prebuilt_file"],
+				}`,
+			preparer: FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.ModuleFamilySelection = []string{"otherfamily:source"}
+			}),
+			// Without a selection for the family prefer is used.
+			prebuilt: []OsType{Android, buildOS},
+		},
 	}
 
 	fs := MockFS{
@@ -497,6 +552,72 @@ func TestPrebuilts(t *testing.T) {
 	}
 }
 
+func TestModuleFamilies(t *testing.T) {
+	bp := `
+		source {
+			name: "bar",
+		}
+
+		prebuilt {
+			name: "bar",
+			prefer: true,
+			module_family: "myfamily",
+			srcs: ["prebuilt_file"],
+		}
+
+		source {
+			name: "baz",
+		}
+
+		prebuilt {
+			name: "baz",
+			module_family: "myfamily",
+			srcs: ["prebuilt_file"],
+		}
+	`
+
+	preparer := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(registerTestPrebuiltBuildComponents),
+		FixtureAddFile("prebuilt_file", nil),
+	)
+
+	t.Run("inconsistent", func(t *testing.T) {
+		preparer.
+			ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+				`module family "myfamily" is inconsistent, the prebuilts of bar are used but the sources of baz are used`)).
+			RunTestWithBp(t, bp)
+	})
+
+	t.Run("selected", func(t *testing.T) {
+		result := GroupFixturePreparers(
+			preparer,
+			FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.ModuleFamilySelection = []string{"myfamily:prebuilt"}
+			}),
+		).RunTestWithBp(t, bp)
+
+		for _, name := range []string{"prebuilt_bar", "prebuilt_baz"} {
+			for _, variant := range result.ModuleVariantsForTests(name) {
+				p := GetEmbeddedPrebuilt(result.ModuleForTests(name, variant).Module())
+				AssertBoolEquals(t, name+" "+variant+" uses prebuilt", true, p.UsePrebuilt())
+			}
+		}
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		GroupFixturePreparers(
+			preparer,
+			FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.ModuleFamilySelection = []string{"myfamily:latest"}
+			}),
+		).
+			ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+				`module_family: invalid mode "latest" for module family "myfamily" in ModuleFamilySelection`)).
+			RunTestWithBp(t, bp)
+	})
+}
+
 func registerTestPrebuiltBuildComponents(ctx RegistrationContext) {
 	registerTestPrebuiltModules(ctx)
 
@@ -0,0 +1,348 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The product_variables_used singleton is registered last by collateGloballyRegisteredSingletons.
+func RegisterProductVariablesReportBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("product_variables_used", productVariablesReportSingletonFactory)
+}
+
+var PrepareForTestWithProductVariablesReport = GroupFixturePreparers(
+	FixtureRegisterWithContext(RegisterProductVariablesReportBuildComponents),
+	FixtureMergeEnv(map[string]string{
+		recordProductVariablesEnv: "true",
+	}),
+)
+
+// recordProductVariablesEnv is the environment variable that enables the report of the product
+// variables that are read by Soong in out/soong/product_variables_used.json.
+const recordProductVariablesEnv = "SOONG_RECORD_PRODUCT_VARIABLES"
+
+// soongSourceDir is the directory containing the Go sources of Soong, which are parsed to find
+// the product variables read by each config accessor and the packages that call it.
+const soongSourceDir = "build/soong"
+
+// productVariablesRecorder collects the product variables that are read by the build, the
+// accessors or properties that read them and the packages that use the accessors or properties.
+type productVariablesRecorder struct {
+	lock sync.Mutex
+
+	// uses maps from product variable name to accessor to consuming package.
+	uses map[string]map[string]map[string]bool
+}
+
+func (r *productVariablesRecorder) record(name, accessor, pkg string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.uses[name] == nil {
+		r.uses[name] = make(map[string]map[string]bool)
+	}
+	if r.uses[name][accessor] == nil {
+		r.uses[name][accessor] = make(map[string]bool)
+	}
+	r.uses[name][accessor][pkg] = true
+}
+
+// productVariableUse is the format of an entry in product_variables_used.json.
+type productVariableUse struct {
+	Name      string   `json:"name"`
+	Accessors []string `json:"accessors"`
+	Packages  []string `json:"packages"`
+}
+
+func (r *productVariablesRecorder) report() []productVariableUse {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var ret []productVariableUse
+	for _, name := range SortedStringKeys(r.uses) {
+		use := productVariableUse{Name: name}
+		packages := make(map[string]bool)
+		for _, accessor := range SortedStringKeys(r.uses[name]) {
+			use.Accessors = append(use.Accessors, accessor)
+			for pkg := range r.uses[name][accessor] {
+				packages[pkg] = true
+			}
+		}
+		use.Packages = SortedStringKeys(packages)
+		ret = append(ret, use)
+	}
+	return ret
+}
+
+// productVariablesRecorder returns the recorder for the product variables read by this build, or
+// nil if recording is not enabled.
+func (c *config) productVariablesRecorder() *productVariablesRecorder {
+	c.productVariablesRecorderOnce.Do(func() {
+		if c.IsEnvTrue(recordProductVariablesEnv) {
+			c.productVariablesRecorderInstance = &productVariablesRecorder{
+				uses: make(map[string]map[string]map[string]bool),
+			}
+		}
+	})
+	return c.productVariablesRecorderInstance
+}
+
+// recordProductVariableProperty records that a module of the given Go package used the
+// product_variables property for the given product variable.
+func (c *config) recordProductVariableProperty(name, property, pkg string) {
+	if r := c.productVariablesRecorder(); r != nil {
+		r.record(name, property, pkg)
+	}
+}
+
+// configAccessorTypes maps the receiver types of the config accessors to the name of the type that
+// is shown to users.
+var configAccessorTypes = map[string]string{
+	"config":       "Config",
+	"Config":       "Config",
+	"deviceConfig": "DeviceConfig",
+	"DeviceConfig": "DeviceConfig",
+}
+
+// configAccessorName returns the user visible name of the method declared by fn if it is a config
+// accessor, or the empty string if it is not.
+func configAccessorName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) != 1 {
+		return ""
+	}
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok && configAccessorTypes[ident.Name] != "" {
+		return configAccessorTypes[ident.Name] + "." + fn.Name.Name
+	}
+	return ""
+}
+
+// configAccessorReads returns the product variables read by each config accessor declared in the
+// given files of the android package, either directly or through the other accessors it calls.
+func configAccessorReads(files []*ast.File) map[string][]string {
+	reads := make(map[string]map[string]bool)
+	calls := make(map[string][]string)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			accessor := ""
+			if ok {
+				accessor = configAccessorName(fn)
+			}
+			if accessor == "" || fn.Body == nil {
+				continue
+			}
+			reads[accessor] = make(map[string]bool)
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.SelectorExpr:
+					if x, ok := n.X.(*ast.SelectorExpr); ok && x.Sel.Name == "productVariables" {
+						reads[accessor][n.Sel.Name] = true
+					}
+				case *ast.CallExpr:
+					if fun, ok := n.Fun.(*ast.SelectorExpr); ok {
+						calls[accessor] = append(calls[accessor], fun.Sel.Name)
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	// Resolve the methods called by each accessor, preferring the ones of its own type, and add
+	// the product variables they read until nothing changes.
+	resolve := func(accessor, method string) string {
+		typ := accessor[:strings.Index(accessor, ".")]
+		for _, t := range []string{typ, "Config", "DeviceConfig"} {
+			if _, ok := reads[t+"."+method]; ok {
+				return t + "." + method
+			}
+		}
+		return ""
+	}
+	for changed := true; changed; {
+		changed = false
+		for accessor, methods := range calls {
+			for _, method := range methods {
+				callee := resolve(accessor, method)
+				if callee == "" || callee == accessor {
+					continue
+				}
+				for name := range reads[callee] {
+					if !reads[accessor][name] {
+						reads[accessor][name] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+
+	ret := make(map[string][]string)
+	for accessor, names := range reads {
+		if len(names) > 0 {
+			ret[accessor] = SortedStringKeys(names)
+		}
+	}
+	return ret
+}
+
+// configReceiverType returns the user visible name of the config type that expr evaluates to, or
+// the empty string if it is not a config.  Soong code almost always reaches the config through
+// the Config() and DeviceConfig() methods of a context or through a variable or field named
+// config or deviceConfig, so the expression is matched by name without type checking.
+func configReceiverType(expr ast.Expr) string {
+	name := ""
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		if fun, ok := e.Fun.(*ast.SelectorExpr); ok && len(e.Args) == 0 {
+			name = fun.Sel.Name
+		}
+	case *ast.SelectorExpr:
+		name = e.Sel.Name
+	case *ast.Ident:
+		name = e.Name
+	}
+	switch name {
+	case "Config", "config":
+		return "Config"
+	case "DeviceConfig", "deviceConfig":
+		return "DeviceConfig"
+	}
+	return ""
+}
+
+// configAccessorCallers returns the import paths of the packages that call each of the accessors,
+// given the parsed Go files of Soong keyed by their path relative to soongSourceDir.  Calls from
+// one accessor to another are already accounted for by configAccessorReads.
+func configAccessorCallers(files map[string]*ast.File, accessors map[string][]string) map[string]map[string]bool {
+	callers := make(map[string]map[string]bool)
+	for rel, file := range files {
+		pkg := filepath.Join("android/soong", filepath.Dir(rel))
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && configAccessorName(fn) != "" {
+				continue
+			}
+			ast.Inspect(decl, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				fun, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				if typ := configReceiverType(fun.X); typ != "" {
+					accessor := typ + "." + fun.Sel.Name
+					if _, ok := accessors[accessor]; ok {
+						if callers[accessor] == nil {
+							callers[accessor] = make(map[string]bool)
+						}
+						callers[accessor][pkg] = true
+					}
+				}
+				return true
+			})
+		}
+	}
+	return callers
+}
+
+func productVariablesReportSingletonFactory() Singleton {
+	return &productVariablesReportSingleton{}
+}
+
+type productVariablesReportSingleton struct{}
+
+// GenerateBuildActions writes out/soong/product_variables_used.json, which lists the product
+// variables that are read by the config accessors called from Soong or by the product_variables
+// properties of the modules, when SOONG_RECORD_PRODUCT_VARIABLES is set.  The accessors and their
+// callers are found by parsing the Go sources of Soong, so that they don't need to be annotated.
+func (productVariablesReportSingleton) GenerateBuildActions(ctx SingletonContext) {
+	r := ctx.Config().productVariablesRecorder()
+	if r == nil {
+		return
+	}
+
+	srcs, err := ctx.GlobWithDeps(filepath.Join(soongSourceDir, "**/*.go"), nil)
+	if err != nil {
+		ctx.Errorf("failed to glob the sources of Soong: %s", err.Error())
+		return
+	}
+
+	fset := token.NewFileSet()
+	files := make(map[string]*ast.File)
+	var androidFiles []*ast.File
+	for _, src := range srcs {
+		if strings.HasSuffix(src, "_test.go") {
+			continue
+		}
+		rel, err := filepath.Rel(soongSourceDir, src)
+		if err != nil {
+			ctx.Errorf("%s", err.Error())
+			continue
+		}
+		file, err := parseGoSource(ctx.Config(), fset, src)
+		if err != nil {
+			ctx.Errorf("failed to parse %s: %s", src, err.Error())
+			continue
+		}
+		files[rel] = file
+		if filepath.Dir(rel) == "android" {
+			androidFiles = append(androidFiles, file)
+		}
+	}
+
+	accessors := configAccessorReads(androidFiles)
+	for accessor, pkgs := range configAccessorCallers(files, accessors) {
+		for _, name := range accessors[accessor] {
+			for pkg := range pkgs {
+				r.record(name, accessor, pkg)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(r.report(), "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal product_variables_used.json: %s", err.Error())
+		return
+	}
+
+	WriteFileRule(ctx, PathForOutput(ctx, "product_variables_used.json"), string(data))
+}
+
+func parseGoSource(config Config, fset *token.FileSet, path string) (*ast.File, error) {
+	f, err := config.fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ParseFile(fset, path, data, 0)
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// productVariablesReportTestSources are the Go sources of a fake Soong tree that are parsed to find
+// the config accessors and their callers.
+var productVariablesReportTestSources = MockFS{
+	"build/soong/android/config.go": []byte(`
+		package android
+
+		func (c *config) PlatformSdkCodename() string {
+			return String(c.productVariables.Platform_sdk_codename)
+		}
+
+		func (c *config) PlatformSdkVersion() ApiLevel {
+			return uncheckedFinalApiLevel(*c.productVariables.Platform_sdk_version)
+		}
+
+		func (c *config) DefaultAppTargetSdk(ctx EarlyModuleContext) ApiLevel {
+			if Bool(c.productVariables.Platform_sdk_final) {
+				return c.PlatformSdkVersion()
+			}
+			return ApiLevelOrPanic(ctx, c.PlatformSdkCodename())
+		}
+
+		func (c *config) Unused() bool {
+			return Bool(c.productVariables.Unused)
+		}
+
+		func (c *deviceConfig) VendorPath() string {
+			if c.config.productVariables.VendorPath != nil {
+				return *c.config.productVariables.VendorPath
+			}
+			return "vendor"
+		}
+
+		func (c *deviceConfig) ProductPath() string {
+			return String(c.config.productVariables.ProductPath)
+		}
+	`),
+	"build/soong/android/config_test.go": []byte(`
+		package android
+
+		func TestConfig(t *testing.T) {
+			testConfig.Unused()
+		}
+	`),
+	"build/soong/java/app.go": []byte(`
+		package java
+
+		func (a *AndroidApp) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+			a.targetSdk = ctx.Config().DefaultAppTargetSdk(ctx)
+			if ctx.DeviceConfig().VendorPath() != "" {
+				return
+			}
+		}
+	`),
+	"build/soong/cc/cc.go": []byte(`
+		package cc
+
+		func vendorPath(config android.Config) string {
+			return config.VendorPath()
+		}
+
+		func productPath(c *Module) string {
+			return c.productPath()
+		}
+	`),
+}
+
+func TestProductVariablesReport(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithProductVariablesReport,
+		productVariablesReportTestSources.AddToFixture(),
+	).RunTest(t)
+
+	content := ContentFromFileRuleForTests(t, result.SingletonForTests("product_variables_used").
+		Output("product_variables_used.json"))
+
+	var uses []productVariableUse
+	if err := json.Unmarshal([]byte(content), &uses); err != nil {
+		t.Fatalf("failed to parse product_variables_used.json: %s", err)
+	}
+
+	expected := []productVariableUse{
+		{
+			Name:      "Platform_sdk_codename",
+			Accessors: []string{"Config.DefaultAppTargetSdk"},
+			Packages:  []string{"android/soong/java"},
+		},
+		{
+			Name:      "Platform_sdk_final",
+			Accessors: []string{"Config.DefaultAppTargetSdk"},
+			Packages:  []string{"android/soong/java"},
+		},
+		{
+			Name:      "Platform_sdk_version",
+			Accessors: []string{"Config.DefaultAppTargetSdk"},
+			Packages:  []string{"android/soong/java"},
+		},
+		{
+			Name:      "VendorPath",
+			Accessors: []string{"DeviceConfig.VendorPath"},
+			Packages:  []string{"android/soong/cc", "android/soong/java"},
+		},
+	}
+	AssertDeepEquals(t, "product_variables_used.json", expected, uses)
+}
+
+func TestProductVariablesReportDisabled(t *testing.T) {
+	result := GroupFixturePreparers(
+		FixtureRegisterWithContext(RegisterProductVariablesReportBuildComponents),
+		productVariablesReportTestSources.AddToFixture(),
+	).RunTest(t)
+
+	output := result.SingletonForTests("product_variables_used").MaybeOutput("product_variables_used.json")
+	if output.Rule != nil {
+		t.Errorf("expected no product_variables_used.json when %s is not set", recordProductVariablesEnv)
+	}
+}
+
+func TestProductVariablesRecorderReport(t *testing.T) {
+	r := &productVariablesRecorder{uses: make(map[string]map[string]map[string]bool)}
+	r.record("B", "Config.B", "android/soong/java")
+	r.record("A", "Config.A", "android/soong/cc")
+	r.record("A", "DeviceConfig.A", "android/soong/apex")
+	r.record("A", "Config.A", "android/soong/apex")
+
+	expected := []productVariableUse{
+		{
+			Name:      "A",
+			Accessors: []string{"Config.A", "DeviceConfig.A"},
+			Packages:  []string{"android/soong/apex", "android/soong/cc"},
+		},
+		{
+			Name:      "B",
+			Accessors: []string{"Config.B"},
+			Packages:  []string{"android/soong/java"},
+		},
+	}
+
+	if got := r.report(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected %#v, got %#v", expected, got)
+	}
+}
@@ -16,6 +16,7 @@ package android
 
 import (
 	"android/soong/bazel"
+	"path/filepath"
 	"regexp"
 	"strings"
 
@@ -60,6 +61,22 @@ type protoDependencyTag struct {
 
 var ProtoPluginDepTag = protoDependencyTag{name: "plugin"}
 
+// ProtoDepTag is the dependency tag of the modules listed in proto.deps.
+var ProtoDepTag = protoDependencyTag{name: "deps"}
+
+// ProtoInfo contains the proto files exported by a module to the modules that list it in
+// proto.deps, along with the include dirs needed to import them.  It includes the proto files and
+// include dirs of the module's own proto.deps, transitively.
+type ProtoInfo struct {
+	// The include dirs that must be passed to protoc to import the exported proto files.
+	IncludeDirs Paths
+
+	// The exported proto files, which are implicit inputs of the protoc rules of dependent modules.
+	Srcs Paths
+}
+
+var ProtoInfoProvider = blueprint.NewProvider(ProtoInfo{})
+
 func ProtoDeps(ctx BottomUpMutatorContext, p *ProtoProperties) {
 	if String(p.Proto.Plugin) != "" && String(p.Proto.Type) != "" {
 		ctx.ModuleErrorf("only one of proto.type and proto.plugin can be specified.")
@@ -69,6 +86,57 @@ func ProtoDeps(ctx BottomUpMutatorContext, p *ProtoProperties) {
 		ctx.AddFarVariationDependencies(ctx.Config().BuildOSTarget.Variations(),
 			ProtoPluginDepTag, "protoc-gen-"+plugin)
 	}
+
+	// The exported protos do not depend on variations other than the target, so any variant of
+	// the dependency for the same target will do.
+	ctx.AddFarVariationDependencies(ctx.Target().Variations(), ProtoDepTag, p.Proto.Deps...)
+}
+
+// protoDepsInfo returns the merged ProtoInfo of the modules listed in proto.deps.
+func protoDepsInfo(ctx ModuleContext) ProtoInfo {
+	var info ProtoInfo
+	ctx.VisitDirectDepsWithTag(ProtoDepTag, func(dep Module) {
+		if !ctx.OtherModuleHasProvider(dep, ProtoInfoProvider) {
+			ctx.PropertyErrorf("proto.deps", "module %q does not export proto files",
+				ctx.OtherModuleName(dep))
+			return
+		}
+		depInfo := ctx.OtherModuleProvider(dep, ProtoInfoProvider).(ProtoInfo)
+		info.IncludeDirs = append(info.IncludeDirs, depInfo.IncludeDirs...)
+		info.Srcs = append(info.Srcs, depInfo.Srcs...)
+	})
+	info.IncludeDirs = FirstUniquePaths(info.IncludeDirs)
+	info.Srcs = FirstUniquePaths(info.Srcs)
+	return info
+}
+
+// SetProtoInfo sets the ProtoInfoProvider of a module with the given proto files, so that
+// modules that list it in proto.deps can import them.  It does nothing if the module has neither
+// proto files nor proto.deps.
+func SetProtoInfo(ctx ModuleContext, p *ProtoProperties, protoSrcs Paths) {
+	if len(protoSrcs) == 0 && len(p.Proto.Deps) == 0 {
+		return
+	}
+
+	var includeDirs Paths
+	canonicalPathFromRoot := proptools.BoolDefault(p.Proto.Canonical_path_from_root, canonicalPathFromRootDefault)
+	for _, src := range protoSrcs {
+		if canonicalPathFromRoot {
+			includeDirs = append(includeDirs, PathForSource(ctx, "."))
+		} else if _, ok := src.(SourcePath); ok {
+			// Match the -I flag that ProtoRule passes for the proto file.
+			includeDirs = append(includeDirs,
+				PathForSource(ctx, filepath.Clean(strings.TrimSuffix(src.String(), src.Rel()))))
+		}
+	}
+	includeDirs = append(includeDirs, PathsForModuleSrc(ctx, p.Proto.Local_include_dirs)...)
+	includeDirs = append(includeDirs, PathsForSource(ctx, p.Proto.Include_dirs)...)
+
+	depsInfo := protoDepsInfo(ctx)
+	ctx.SetProvider(ProtoInfoProvider, ProtoInfo{
+		IncludeDirs: FirstUniquePaths(append(includeDirs, depsInfo.IncludeDirs...)),
+		Srcs:        FirstUniquePaths(append(append(Paths(nil), protoSrcs...), depsInfo.Srcs...)),
+	})
 }
 
 func GetProtoFlags(ctx ModuleContext, p *ProtoProperties) ProtoFlags {
@@ -84,6 +152,12 @@ func GetProtoFlags(ctx ModuleContext, p *ProtoProperties) ProtoFlags {
 		flags = append(flags, JoinWithPrefix(rootProtoIncludeDirs.Strings(), "-I"))
 	}
 
+	depsInfo := protoDepsInfo(ctx)
+	if len(depsInfo.IncludeDirs) > 0 {
+		flags = append(flags, JoinWithPrefix(depsInfo.IncludeDirs.Strings(), "-I"))
+	}
+	deps = append(deps, depsInfo.Srcs...)
+
 	ctx.VisitDirectDepsWithTag(ProtoPluginDepTag, func(dep Module) {
 		if hostTool, ok := dep.(HostToolProvider); !ok || !hostTool.HostToolPath().Valid() {
 			ctx.PropertyErrorf("proto.plugin", "module %q is not a host tool provider",
@@ -125,6 +199,11 @@ type ProtoProperties struct {
 		// be added to the protoc include paths.
 		Local_include_dirs []string
 
+		// list of modules whose proto files can be imported by the proto files of this module.
+		// The include dirs needed to import them, including those of their own proto.deps, are
+		// added to the protoc include paths.
+		Deps []string
+
 		// whether to identify the proto files from the root of the
 		// source tree (the original method in Android, useful for
 		// android-specific protos), or relative from where they were
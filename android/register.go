@@ -193,7 +193,7 @@ func (ctx *Context) Register() {
 		t.register(ctx)
 	}
 
-	mutators := collateGloballyRegisteredMutators()
+	mutators := collateGloballyRegisteredMutators(ctx.config)
 	mutators.registerAll(ctx)
 
 	singletons := collateGloballyRegisteredSingletons()
@@ -214,6 +214,10 @@ func collateGloballyRegisteredSingletons() sortableComponents {
 		// Register env and ninjadeps last so that they can track all used environment variables and
 		// Ninja file dependencies stored in the config.
 		singleton{false, "ninjadeps", ninjaDepsSingletonFactory},
+
+		// Register product_variables_used last so that it reports everything recorded while the
+		// other singletons run.
+		singleton{false, "product_variables_used", productVariablesReportSingletonFactory},
 	)
 
 	return allSingletons
@@ -0,0 +1,132 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+func init() {
+	RegisterRequiredCheckBuildComponents(InitRegistrationContext)
+}
+
+func RegisterRequiredCheckBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("required_check", requiredCheckSingletonFactory)
+}
+
+var PrepareForTestWithRequiredCheck = FixtureRegisterWithContext(RegisterRequiredCheckBuildComponents)
+
+func requiredCheckSingletonFactory() Singleton {
+	return &requiredCheckSingleton{}
+}
+
+type requiredCheckSingleton struct{}
+
+// installsFor returns true if the module variant is installed by Make for the given OS and one of
+// the given architectures. Variants in namespaces that are not exported to Make are not visible to
+// Make, so they never satisfy a requirement. Variants without files to install are only counted
+// when they have requirements of their own, as they are then installed as a group of modules, like
+// phony modules.
+func installsFor(module Module, os OsType, archTypes []ArchType) bool {
+	if !module.Enabled() || module.Os() != os || !module.ExportedToMake() ||
+		module.IsHideFromMake() || module.IsSkipInstall() {
+		return false
+	}
+	if archType := module.Target().Arch.ArchType; archType != Common && len(archTypes) > 0 &&
+		!inArchTypeList(archType, archTypes) {
+		return false
+	}
+	return len(module.FilesToInstall()) > 0 || len(module.RequiredModuleNames()) > 0 ||
+		len(module.HostRequiredModuleNames()) > 0 || len(module.TargetRequiredModuleNames()) > 0
+}
+
+func inArchTypeList(archType ArchType, list []ArchType) bool {
+	for _, a := range list {
+		if a == archType {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateBuildActions reports the modules required by device modules through the required,
+// host_required and target_required properties that Make does not install for the OS and
+// architecture the property applies to, e.g. a host only module in required, or a 32-bit only
+// module required by a 64-bit module. Make silently ignores such a requirement, and the missing
+// file is only noticed in the image. Modules that are not defined in Soong are assumed to be
+// defined in Make and are not checked.
+//
+// Make resolves the requirements of a module by name, so the modules are matched by name among
+// the modules of the namespaces exported to Make. A requirement of a module in required is
+// resolved to the variant of the same architecture, or to the variant of the primary
+// architecture when there is none, like Make does for the secondary architecture.
+func (requiredCheckSingleton) GenerateBuildActions(ctx SingletonContext) {
+	// The requirements are only passed to Make, and the check is not needed when soong_build only
+	// writes the module graph or the module index.
+	if !ctx.Config().KatiEnabled() || ctx.Config().QueryingModules() {
+		return
+	}
+
+	variants := make(map[string][]Module)
+	ctx.VisitAllModules(func(module Module) {
+		name := RemoveOptionalPrebuiltPrefix(ctx.ModuleName(module))
+		variants[name] = append(variants[name], module)
+	})
+
+	installs := func(name string, os OsType, archTypes []ArchType) bool {
+		for _, variant := range variants[name] {
+			if installsFor(variant, os, archTypes) {
+				return true
+			}
+		}
+		return false
+	}
+
+	reported := make(map[string]bool)
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() || module.Os().Class != Device || !module.ExportedToMake() ||
+			module.IsHideFromMake() {
+			return
+		}
+		name := ctx.ModuleName(module)
+		if ctx.DeviceConfig().BuildBrokenUninstallableRequiredModule(RemoveOptionalPrebuiltPrefix(name)) {
+			return
+		}
+		check := func(property string, required []string, os OsType, archTypes []ArchType) {
+			for _, req := range required {
+				key := name + ":" + module.Target().String() + ":" + property + ":" + req
+				if _, exists := variants[req]; !exists || reported[key] || installs(req, os, archTypes) {
+					continue
+				}
+				reported[key] = true
+				where := os.Name
+				if len(archTypes) > 0 {
+					where += "_" + archTypes[0].Name
+				}
+				ctx.ModuleErrorf(module, "%s: %q is not installed for %s, so requiring it has no "+
+					"effect. Remove it, or add %q to BUILD_BROKEN_UNINSTALLABLE_REQUIRED_MODULES.",
+					property, req, where, RemoveOptionalPrebuiltPrefix(name))
+			}
+		}
+
+		var archTypes []ArchType
+		if archType := module.Target().Arch.ArchType; archType != Common {
+			archTypes = append(archTypes, archType)
+			if targets := ctx.Config().Targets[module.Os()]; len(targets) > 0 &&
+				targets[0].Arch.ArchType != archType {
+				archTypes = append(archTypes, targets[0].Arch.ArchType)
+			}
+		}
+		check("required", module.RequiredModuleNames(), module.Os(), archTypes)
+		check("host_required", module.HostRequiredModuleNames(), ctx.Config().BuildOS, nil)
+		check("target_required", module.TargetRequiredModuleNames(), Android, nil)
+	})
+}
@@ -0,0 +1,234 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type requiredCheckTestModule struct {
+	ModuleBase
+	properties struct {
+		Installable *bool
+	}
+}
+
+func requiredCheckTestModuleFactory() Module {
+	m := &requiredCheckTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, HostAndDeviceDefault, MultilibCommon)
+	return m
+}
+
+func (m *requiredCheckTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	outputFile := PathForModuleOut(ctx, ctx.ModuleName())
+	ctx.Build(pctx, BuildParams{
+		Rule:   Touch,
+		Output: outputFile,
+	})
+	if BoolDefault(m.properties.Installable, true) {
+		ctx.InstallFile(PathForModuleInstall(ctx, "etc"), ctx.ModuleName(), outputFile)
+	}
+}
+
+var prepareForRequiredCheckTest = GroupFixturePreparers(
+	PrepareForTestWithRequiredCheck,
+	PrepareForTestWithNamespace,
+	FixtureModifyContext(func(ctx *TestContext) {
+		ctx.NameResolver.namespaceExportFilter = func(namespace *Namespace) bool {
+			return namespace.Path != "hidden"
+		}
+	}),
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("required_test_module", requiredCheckTestModuleFactory)
+	}),
+	FixtureAddTextFile("deps/Android.bp", `
+		required_test_module {
+			name: "device_module",
+		}
+
+		required_test_module {
+			name: "host_module",
+			host_supported: true,
+			device_supported: false,
+		}
+
+		required_test_module {
+			name: "uninstallable_module",
+			installable: false,
+		}
+
+		required_test_module {
+			name: "group_module",
+			installable: false,
+			required: ["device_module"],
+		}
+
+		required_test_module {
+			name: "lib32_module",
+			compile_multilib: "32",
+		}
+
+		required_test_module {
+			name: "lib64_module",
+			compile_multilib: "64",
+		}
+	`),
+	FixtureAddTextFile("exported/Android.bp", `
+		soong_namespace {
+		}
+
+		required_test_module {
+			name: "exported_module",
+		}
+
+		required_test_module {
+			name: "namespaced_module",
+			installable: false,
+		}
+	`),
+	FixtureAddTextFile("hidden/Android.bp", `
+		soong_namespace {
+		}
+
+		required_test_module {
+			name: "namespaced_module",
+		}
+	`),
+)
+
+func TestRequiredCheck(t *testing.T) {
+	testCases := []struct {
+		name        string
+		bp          string
+		allowlist   []string
+		withoutKati bool
+		errors      []string
+	}{
+		{
+			name: "installed",
+			bp: `
+				required_test_module {
+					name: "foo",
+					required: [
+						"device_module",
+						"exported_module",
+						"group_module",
+						"make_module",
+					],
+					host_required: ["host_module"],
+					target_required: ["device_module"],
+				}
+			`,
+		},
+		{
+			name: "installed for the architecture",
+			bp: `
+				required_test_module {
+					name: "foo",
+					compile_multilib: "both",
+					required: ["lib64_module"],
+					target: {
+						android_arm64: {
+							required: ["lib32_module"],
+						},
+					},
+				}
+			`,
+			errors: []string{
+				`module "foo" variant "android_arm64_armv8-a": required: "lib32_module" is not installed for android_arm64`,
+			},
+		},
+		{
+			name: "not installed for the exported namespaces",
+			bp: `
+				required_test_module {
+					name: "foo",
+					required: ["namespaced_module"],
+				}
+			`,
+			errors: []string{
+				`module "foo" variant "android_common": required: "namespaced_module" is not installed for android`,
+			},
+		},
+		{
+			name: "not installed",
+			bp: `
+				required_test_module {
+					name: "foo",
+					required: [
+						"host_module",
+						"uninstallable_module",
+					],
+					host_required: ["device_module"],
+				}
+			`,
+			errors: []string{
+				`module "foo" variant "android_common": required: "host_module" is not installed for android`,
+				`module "foo" variant "android_common": required: "uninstallable_module" is not installed for android`,
+				`module "foo" variant "android_common": host_required: "device_module" is not installed for linux_glibc`,
+			},
+		},
+		{
+			name: "allowlisted",
+			bp: `
+				required_test_module {
+					name: "foo",
+					required: ["host_module"],
+				}
+			`,
+			allowlist: []string{"foo"},
+		},
+		{
+			name: "not checked without kati",
+			bp: `
+				required_test_module {
+					name: "foo",
+					required: ["host_module"],
+				}
+			`,
+			withoutKati: true,
+		},
+		{
+			name: "host modules are not checked",
+			bp: `
+				required_test_module {
+					name: "foo",
+					host_supported: true,
+					device_supported: false,
+					required: ["device_module"],
+				}
+			`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			GroupFixturePreparers(
+				prepareForRequiredCheckTest,
+				FixtureAddTextFile("foo/Android.bp", tc.bp),
+				FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+					variables.BuildBrokenUninstallableRequiredModules = tc.allowlist
+				}),
+				FixtureModifyConfig(func(config Config) {
+					if !tc.withoutKati {
+						SetKatiEnabledForTests(config)
+					}
+				}),
+			).ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern(tc.errors)).
+				RunTest(t)
+		})
+	}
+}
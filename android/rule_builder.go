@@ -385,6 +385,16 @@ func (r *RuleBuilder) DepFiles() WritablePaths {
 	return depFiles
 }
 
+// dirOutputs returns the list of directory outputs passed to RuleBuilderCommand.DirOutput or
+// RuleBuilderCommand.ImplicitDirOutput, in the order they were added.
+func (r *RuleBuilder) dirOutputs() []dirOutput {
+	var dirOutputs []dirOutput
+	for _, c := range r.commands {
+		dirOutputs = append(dirOutputs, c.dirOutputs...)
+	}
+	return dirOutputs
+}
+
 // Installs returns the list of tuples passed to Install.
 func (r *RuleBuilder) Installs() RuleBuilderInstalls {
 	return append(RuleBuilderInstalls(nil), r.installs...)
@@ -580,10 +590,34 @@ func (r *RuleBuilder) Build(name string, desc string) {
 			command.Chdir = proto.Bool(true)
 		}
 
+		// Add rules to the manifest to copy each directory output from the sbox directory to the
+		// output directory after running the commands.  The file lists are written by sbox itself
+		// and so must not be copied out of the sandbox.
+		dirOutputFileLists := make(map[string]bool)
+		for _, dirOutput := range r.dirOutputs() {
+			rel := Rel(r.ctx, r.outDir.String(), dirOutput.dir.String())
+			// The file list is written outside the sandbox, but it still must be in the output
+			// directory so that sbox deletes stale copies.
+			Rel(r.ctx, r.outDir.String(), dirOutput.fileList.String())
+			outputDir := &sbox_proto.OutputDir{
+				From:     proto.String(filepath.Join(sboxOutSubDir, rel)),
+				To:       proto.String(dirOutput.dir.String()),
+				FileList: proto.String(dirOutput.fileList.String()),
+			}
+			if dirOutput.expectedFileList != nil {
+				outputDir.ExpectedFileList = proto.String(dirOutput.expectedFileList.String())
+			}
+			command.OutputDirs = append(command.OutputDirs, outputDir)
+			dirOutputFileLists[dirOutput.fileList.String()] = true
+		}
+
 		// Add copy rules to the manifest to copy each output file from the sbox directory.
 		// to the output directory after running the commands.
 		sboxOutputs := make([]string, len(outputs))
 		for i, output := range outputs {
+			if dirOutputFileLists[output.String()] {
+				continue
+			}
 			rel := Rel(r.ctx, r.outDir.String(), output.String())
 			sboxOutputs[i] = filepath.Join(sboxOutDir, rel)
 			command.CopyAfter = append(command.CopyAfter, &sbox_proto.Copy{
@@ -755,6 +789,7 @@ type RuleBuilderCommand struct {
 	tools          Paths
 	packagedTools  []PackagingSpec
 	rspFiles       []rspFileAndPaths
+	dirOutputs     []dirOutput
 }
 
 type rspFileAndPaths struct {
@@ -762,6 +797,15 @@ type rspFileAndPaths struct {
 	paths Paths
 }
 
+// dirOutput is a directory written by a sandboxed command whose contents are not known ahead of
+// time.  fileList is the Ninja-visible output that sbox writes after copying the directory out of
+// the sandbox, and expectedFileList is an optional list of files the directory must contain.
+type dirOutput struct {
+	dir              WritablePath
+	fileList         WritablePath
+	expectedFileList Path
+}
+
 func checkPathNotNil(path Path) {
 	if path == nil {
 		panic("rule_builder paths cannot be nil")
@@ -1153,6 +1197,43 @@ func (c *RuleBuilderCommand) OutputDir() *RuleBuilderCommand {
 	return c.Text(sboxOutDir)
 }
 
+// DirOutput adds the specified directory to the command line, and declares that the command
+// writes an unknown set of files into it.  This is only available when used with RuleBuilder.Sbox,
+// dir must be inside the sbox output directory, and sbox will copy the whole directory out of the
+// sandbox after the command runs.  Because Ninja cannot track directories, sbox also writes a sorted
+// list of the files it found to fileList, which is added to the outputs returned by
+// RuleBuilder.Outputs and is what other rules should depend on.
+func (c *RuleBuilderCommand) DirOutput(dir, fileList WritablePath) *RuleBuilderCommand {
+	c.ImplicitDirOutput(dir, fileList)
+	return c.Text(c.PathForOutput(dir))
+}
+
+// ImplicitDirOutput is like DirOutput but does not add the directory to the command line.
+func (c *RuleBuilderCommand) ImplicitDirOutput(dir, fileList WritablePath) *RuleBuilderCommand {
+	if !c.rule.sbox {
+		panic("DirOutput only valid with Sbox")
+	}
+	checkPathNotNil(dir)
+	checkPathNotNil(fileList)
+	c.dirOutputs = append(c.dirOutputs, dirOutput{dir: dir, fileList: fileList})
+	c.outputs = append(c.outputs, fileList)
+	return c
+}
+
+// ExpectDirOutputFiles adds a check that the directory passed to DirOutput or ImplicitDirOutput
+// contains exactly the files listed in expectedFileList, one path relative to the directory per
+// line.  sbox fails the rule if the directory contents do not match.
+func (c *RuleBuilderCommand) ExpectDirOutputFiles(dir WritablePath, expectedFileList Path) *RuleBuilderCommand {
+	checkPathNotNil(expectedFileList)
+	for i := range c.dirOutputs {
+		if c.dirOutputs[i].dir == dir {
+			c.dirOutputs[i].expectedFileList = expectedFileList
+			return c.Implicit(expectedFileList)
+		}
+	}
+	panic(fmt.Errorf("ExpectDirOutputFiles called for %q which is not a DirOutput", dir))
+}
+
 // DepFile adds the specified depfile path to the paths returned by RuleBuilder.DepFiles and adds it to the command
 // line, and causes RuleBuilder.Build file to set the depfile flag for ninja.  If multiple depfiles are added to
 // commands in a single RuleBuilder then RuleBuilder.Build will add an extra command to merge the depfiles together.
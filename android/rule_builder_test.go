@@ -580,8 +580,38 @@ func testRuleBuilder_Build(ctx BuilderContext, in Paths, implicit, orderOnly, va
 	rule.Build("rule", "desc")
 }
 
+func testRuleBuilderDirOutputFactory() Module {
+	module := &testRuleBuilderDirOutputModule{}
+	module.AddProperties(&module.properties)
+	InitAndroidModule(module)
+	return module
+}
+
+type testRuleBuilderDirOutputModule struct {
+	ModuleBase
+	properties struct {
+		Expected_files *string
+	}
+}
+
+func (t *testRuleBuilderDirOutputModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	dir := PathForModuleOut(ctx, "gen", "dir")
+	fileList := PathForModuleOut(ctx, "gen", "dir.files")
+
+	rule := NewRuleBuilder(pctx, ctx).Sbox(PathForModuleOut(ctx, "gen"),
+		PathForModuleOut(ctx, "sbox.textproto"))
+	cmd := rule.Command().
+		Tool(PathForSource(ctx, "gen_tool")).
+		DirOutput(dir, fileList)
+	if t.properties.Expected_files != nil {
+		cmd.ExpectDirOutputFiles(dir, PathForModuleSrc(ctx, *t.properties.Expected_files))
+	}
+	rule.Build("rule", "desc")
+}
+
 var prepareForRuleBuilderTest = FixtureRegisterWithContext(func(ctx RegistrationContext) {
 	ctx.RegisterModuleType("rule_builder_test", testRuleBuilderFactory)
+	ctx.RegisterModuleType("rule_builder_dir_output_test", testRuleBuilderDirOutputFactory)
 	ctx.RegisterSingletonType("rule_builder_test", testRuleBuilderSingletonFactory)
 })
 
@@ -792,3 +822,56 @@ func TestRuleBuilderHashInputs(t *testing.T) {
 		})
 	}
 }
+
+func TestRuleBuilderDirOutput(t *testing.T) {
+	bp := `
+		rule_builder_dir_output_test {
+			name: "foo",
+		}
+		rule_builder_dir_output_test {
+			name: "foo_expected",
+			expected_files: "expected.txt",
+		}
+	`
+
+	result := GroupFixturePreparers(
+		prepareForRuleBuilderTest,
+		FixtureWithRootAndroidBp(bp),
+	).RunTest(t)
+
+	t.Run("dir output", func(t *testing.T) {
+		outDir := "out/soong/.intermediates/foo/gen"
+		module := result.ModuleForTests("foo", "")
+		params := module.Output("gen/dir.files")
+
+		AssertPathRelativeToTopEquals(t, "Output", outDir+"/dir.files", params.Output)
+
+		manifest := RuleBuilderSboxProtoForTests(t, module.Output("sbox.textproto"))
+		command := manifest.Commands[0]
+		AssertStringEquals(t, "command", "gen_tool __SBOX_SANDBOX_DIR__/out/dir", command.GetCommand())
+
+		// The file list is written by sbox, it must not be copied out of the sandbox.
+		AssertIntEquals(t, "len(CopyAfter)", 0, len(command.CopyAfter))
+
+		AssertIntEquals(t, "len(OutputDirs)", 1, len(command.OutputDirs))
+		outputDir := command.OutputDirs[0]
+		AssertStringEquals(t, "OutputDir.From", "out/dir", outputDir.GetFrom())
+		AssertStringEquals(t, "OutputDir.To", outDir+"/dir",
+			StringPathRelativeToTop(result.Config.SoongOutDir(), outputDir.GetTo()))
+		AssertStringEquals(t, "OutputDir.FileList", outDir+"/dir.files",
+			StringPathRelativeToTop(result.Config.SoongOutDir(), outputDir.GetFileList()))
+		AssertStringEquals(t, "OutputDir.ExpectedFileList", "", outputDir.GetExpectedFileList())
+	})
+
+	t.Run("expected files", func(t *testing.T) {
+		module := result.ModuleForTests("foo_expected", "")
+		params := module.Output("gen/dir.files")
+
+		AssertPathsRelativeToTopEquals(t, "Implicits",
+			[]string{"expected.txt", "out/soong/.intermediates/foo_expected/sbox.textproto"}, params.Implicits)
+
+		manifest := RuleBuilderSboxProtoForTests(t, module.Output("sbox.textproto"))
+		AssertStringEquals(t, "OutputDir.ExpectedFileList", "expected.txt",
+			manifest.Commands[0].OutputDirs[0].GetExpectedFileList())
+	})
+}
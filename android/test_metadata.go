@@ -0,0 +1,116 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+)
+
+func init() {
+	RegisterTestMetadataBuildComponents(InitRegistrationContext)
+}
+
+func RegisterTestMetadataBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("test_metadata", testMetadataSingletonFactory)
+}
+
+var PrepareForTestWithTestMetadata = FixtureRegisterWithContext(RegisterTestMetadataBuildComponents)
+
+// TestMetadata contains the metadata of a test module that is used by the test mapping tooling.
+type TestMetadata struct {
+	// The test suites the test is installed into.
+	Suites []string
+
+	// The test config of the test, if any.
+	Config Path
+
+	// Free-formed tags that categorize the test.
+	Tags []string
+
+	// Options passed to the test runner.
+	TestRunnerOptions []string
+}
+
+// TestMetadataModule is implemented by test modules to export their metadata to
+// test_metadata.json.
+type TestMetadataModule interface {
+	Module
+
+	// TestMetadata returns the metadata of the test, or nil if the module is not a test.
+	TestMetadata() *TestMetadata
+}
+
+// testMetadataJson is the format of an entry in test_metadata.json.
+type testMetadataJson struct {
+	Name              string   `json:"name"`
+	Suites            []string `json:"suites"`
+	Configs           []string `json:"test_configs"`
+	Tags              []string `json:"tags"`
+	TestRunnerOptions []string `json:"test_runner_options"`
+}
+
+func testMetadataSingletonFactory() Singleton {
+	return &testMetadataSingleton{}
+}
+
+type testMetadataSingleton struct{}
+
+// GenerateBuildActions writes out/soong/test_metadata.json, which contains an entry for every test
+// module with the metadata of all of its variants merged together.
+func (testMetadataSingleton) GenerateBuildActions(ctx SingletonContext) {
+	tests := make(map[string]*testMetadataJson)
+
+	ctx.VisitAllModules(func(m Module) {
+		tm, ok := m.(TestMetadataModule)
+		if !ok || !m.Enabled() {
+			return
+		}
+		metadata := tm.TestMetadata()
+		if metadata == nil {
+			return
+		}
+
+		name := ctx.ModuleName(m)
+		entry := tests[name]
+		if entry == nil {
+			entry = &testMetadataJson{Name: name}
+			tests[name] = entry
+		}
+		entry.Suites = append(entry.Suites, metadata.Suites...)
+		if metadata.Config != nil {
+			entry.Configs = append(entry.Configs, metadata.Config.String())
+		}
+		entry.Tags = append(entry.Tags, metadata.Tags...)
+		entry.TestRunnerOptions = append(entry.TestRunnerOptions, metadata.TestRunnerOptions...)
+	})
+
+	var entries []*testMetadataJson
+	for _, name := range SortedStringKeys(tests) {
+		entry := tests[name]
+		entry.Suites = SortedUniqueStrings(entry.Suites)
+		entry.Configs = SortedUniqueStrings(entry.Configs)
+		entry.Tags = SortedUniqueStrings(entry.Tags)
+		entry.TestRunnerOptions = FirstUniqueStrings(entry.TestRunnerOptions)
+		entries = append(entries, entry)
+	}
+
+	jsonStr, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		ctx.Errorf(err.Error())
+		return
+	}
+
+	WriteFileRule(ctx, PathForOutput(ctx, "test_metadata.json"), string(jsonStr))
+}
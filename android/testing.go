@@ -63,6 +63,10 @@ var PrepareForTestWithArchMutator = GroupFixturePreparers(
 	}),
 )
 
+// PrepareForTestWithHostMuslAndGlibc configures a musl build OS with glibc host targets, so that
+// host modules can be built against either libc.  It must come after PrepareForTestWithArchMutator.
+var PrepareForTestWithHostMuslAndGlibc = FixtureModifyConfig(modifyTestConfigForMuslAndGlibc)
+
 var PrepareForTestWithDefaults = FixtureRegisterWithContext(func(ctx RegistrationContext) {
 	ctx.PreArchMutators(RegisterDefaultsPreArchMutators)
 })
@@ -407,8 +411,10 @@ func (s *registrationSorter) populate() {
 		// Create an ordering from the globally registered pre-singletons.
 		s.preSingletonOrder = registeredComponentOrderFromExistingOrder("pre-singleton", preSingletons)
 
-		// Created an ordering from the globally registered mutators.
-		globallyRegisteredMutators := collateGloballyRegisteredMutators()
+		// Created an ordering from the globally registered mutators, including those of every
+		// soong_build mode.
+		globallyRegisteredMutators := collateRegisteredMutators(preArch, preDeps, postDeps, finalDeps,
+			allModeMutators)
 		s.mutatorOrder = registeredComponentOrderFromExistingOrder("mutator", globallyRegisteredMutators)
 
 		// Create an ordering from the globally registered singletons.
@@ -438,7 +444,8 @@ func (ctx *TestContext) Register() {
 	globalOrder.preSingletonOrder.enforceOrdering(ctx.preSingletons)
 	ctx.preSingletons.registerAll(ctx.Context)
 
-	mutators := collateRegisteredMutators(ctx.preArch, ctx.preDeps, ctx.postDeps, ctx.finalDeps)
+	mutators := collateRegisteredMutators(ctx.preArch, ctx.preDeps, ctx.postDeps, ctx.finalDeps,
+		modeMutators(ctx.config))
 	// Ensure that the mutators used in the test are in the same order as they are used at runtime.
 	globalOrder.mutatorOrder.enforceOrdering(mutators)
 	mutators.registerAll(ctx.Context)
@@ -591,6 +598,12 @@ func (ctx *TestContext) ModuleVariantsForTests(name string) []string {
 	return variants
 }
 
+// Phonies returns the dependencies of the phony targets created by the modules and singletons of
+// the test, keyed by the name of the phony target.
+func (ctx *TestContext) Phonies() map[string]Paths {
+	return getPhonyMap(ctx.config)
+}
+
 // SingletonForTests returns a TestingSingleton for the singleton registered with the given name.
 func (ctx *TestContext) SingletonForTests(name string) TestingSingleton {
 	allSingletonNames := []string{}
@@ -1085,6 +1098,25 @@ func AndroidMkEntriesForTest(t *testing.T, ctx *TestContext, mod blueprint.Modul
 	return entriesList
 }
 
+// AndroidMkTestDataSuitePaths returns the paths, relative to the testcases directory of a test
+// suite, that Make packages the LOCAL_TEST_DATA of the test module named module to.  Like the
+// compatibility_suite_dirs function in Make, the data files are placed in a directory named for the
+// module with LOCAL_COMPATIBILITY_PER_TESTCASE_DIRECTORY, and directly in the testcases directory
+// otherwise.  The arch subdirectory that Make adds for multilib modules is ignored.
+func AndroidMkTestDataSuitePaths(entries AndroidMkEntries, module string) []string {
+	dir := ""
+	if perTestcase := entries.EntryMap["LOCAL_COMPATIBILITY_PER_TESTCASE_DIRECTORY"]; len(perTestcase) > 0 && perTestcase[0] == "true" {
+		dir = module
+	}
+
+	var paths []string
+	for _, data := range entries.EntryMap["LOCAL_TEST_DATA"] {
+		rel := data[strings.LastIndex(data, ":")+1:]
+		paths = append(paths, filepath.Join(dir, rel))
+	}
+	return paths
+}
+
 func AndroidMkDataForTest(t *testing.T, ctx *TestContext, mod blueprint.Module) AndroidMkData {
 	var p AndroidMkDataProvider
 	var ok bool
@@ -754,3 +754,27 @@ func TestSortedUniqueStringValues(t *testing.T) {
 		})
 	}
 }
+
+func TestEditDistance(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"libfoo", "libfoo", 0},
+		{"libfo", "libfoo", 1},
+		{"libfoo", "libfpo", 1},
+		{"libfoo", "libbar", 3},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			if g, w := EditDistance(tt.a, tt.b), tt.expected; g != w {
+				t.Errorf("wanted %d, got %d", w, g)
+			}
+		})
+	}
+}
@@ -183,8 +183,11 @@ type productVariables struct {
 	// Suffix to add to generated Makefiles
 	Make_suffix *string `json:",omitempty"`
 
-	BuildId         *string `json:",omitempty"`
-	BuildNumberFile *string `json:",omitempty"`
+	BuildId                           *string  `json:",omitempty"`
+	BuildNumberFile                   *string  `json:",omitempty"`
+	BuildDisplayId                    *string  `json:",omitempty"`
+	BuildVersionTags                  []string `json:",omitempty"`
+	BoardUseVbmetaDigestInFingerprint *bool    `json:",omitempty"`
 
 	Platform_version_name                     *string  `json:",omitempty"`
 	Platform_sdk_version                      *int     `json:",omitempty"`
@@ -201,6 +204,9 @@ type productVariables struct {
 	Platform_min_supported_target_sdk_version *string  `json:",omitempty"`
 	Platform_base_os                          *string  `json:",omitempty"`
 	Platform_version_last_stable              *string  `json:",omitempty"`
+	Platform_version_known_codenames          []string `json:",omitempty"`
+	Platform_display_version                  *string  `json:",omitempty"`
+	Platform_preview_sdk_fingerprint          *string  `json:",omitempty"`
 
 	DeviceName                            *string  `json:",omitempty"`
 	DeviceProduct                         *string  `json:",omitempty"`
@@ -234,6 +240,7 @@ type productVariables struct {
 	HostArch          *string `json:",omitempty"`
 	HostSecondaryArch *string `json:",omitempty"`
 	HostMusl          *bool   `json:",omitempty"`
+	HostBionicTools   *bool   `json:",omitempty"`
 
 	CrossHost              *string `json:",omitempty"`
 	CrossHostArch          *string `json:",omitempty"`
@@ -249,6 +256,16 @@ type productVariables struct {
 	AAPTPreferredConfig *string  `json:",omitempty"`
 	AAPTPrebuiltDPI     []string `json:",omitempty"`
 
+	AppSetAbis    []string `json:",omitempty"`
+	AppSetLocales []string `json:",omitempty"`
+
+	ProductBrand               *string  `json:",omitempty"`
+	ProductManufacturer        *string  `json:",omitempty"`
+	ProductModel               *string  `json:",omitempty"`
+	ProductDefaultLocale       *string  `json:",omitempty"`
+	ProductDefaultWifiChannels *string  `json:",omitempty"`
+	OemThumbprintProperties    []string `json:",omitempty"`
+
 	DefaultAppCertificate *string `json:",omitempty"`
 
 	AppsDefaultVersionName *string `json:",omitempty"`
@@ -286,7 +303,16 @@ type productVariables struct {
 	BootJars     ConfiguredJarList `json:",omitempty"`
 	ApexBootJars ConfiguredJarList `json:",omitempty"`
 
+	// Selects whether the source or the prebuilt modules of a module family are used, as a list of
+	// "<family>:source" or "<family>:prebuilt" entries. The members of a family are the prebuilt
+	// modules whose module_family property names it.
+	ModuleFamilySelection []string `json:",omitempty"`
+
+	BootclasspathFragmentExtraContents          map[string]ConfiguredJarList `json:",omitempty"`
+	BoardAllowBootclasspathFragmentExtraContents *bool                       `json:",omitempty"`
+
 	IntegerOverflowExcludePaths []string `json:",omitempty"`
+	IntegerOverflowIncludePaths []string `json:",omitempty"`
 
 	EnableCFI       *bool    `json:",omitempty"`
 	CFIExcludePaths []string `json:",omitempty"`
@@ -313,6 +339,7 @@ type productVariables struct {
 	ClangCoverage               *bool    `json:",omitempty"`
 	NativeCoveragePaths         []string `json:",omitempty"`
 	NativeCoverageExcludePaths  []string `json:",omitempty"`
+	NativeCoverageNeverPaths    []string `json:",omitempty"`
 	ClangCoverageContinuousMode *bool    `json:",omitempty"`
 
 	// Set by NewConfig
@@ -392,6 +419,11 @@ type productVariables struct {
 	ManifestPackageNameOverrides []string `json:",omitempty"`
 	CertificateOverrides         []string `json:",omitempty"`
 	PackageNameOverrides         []string `json:",omitempty"`
+	TargetSdkVersionOverrides    []string `json:",omitempty"`
+
+	// The aapt2 link flags added to the apps in a directory and its subdirectories, keyed by the
+	// directory.
+	AaptFlagsByPath map[string][]string `json:",omitempty"`
 
 	ApexGlobalMinSdkVersionOverride *string `json:",omitempty"`
 
@@ -409,7 +441,8 @@ type productVariables struct {
 
 	TargetFSConfigGen []string `json:",omitempty"`
 
-	MissingUsesLibraries []string `json:",omitempty"`
+	MissingUsesLibraries              []string `json:",omitempty"`
+	AllowMissingOptionalUsesLibraries *bool    `json:",omitempty"`
 
 	EnforceProductPartitionInterface *bool `json:",omitempty"`
 
@@ -433,9 +466,18 @@ type productVariables struct {
 	BuildBrokenTrebleSyspropNeverallow bool     `json:",omitempty"`
 	BuildBrokenVendorPropertyNamespace bool     `json:",omitempty"`
 	BuildBrokenInputDirModules         []string `json:",omitempty"`
+	BuildBrokenDuplicateInstallPaths   []string `json:",omitempty"`
+
+	BuildBrokenUninstallableRequiredModules []string `json:",omitempty"`
 
 	BuildDebugfsRestrictionsEnabled bool `json:",omitempty"`
 
+	ProductPackages []string `json:",omitempty"`
+
+	PartitionSizeLimits              map[string]int64 `json:",omitempty"`
+	PartitionSizeReportMarginPercent *int             `json:",omitempty"`
+	EnforcePartitionSizeLimits       *bool            `json:",omitempty"`
+
 	RequiresInsecureExecmemForSwiftshader bool `json:",omitempty"`
 
 	SelinuxIgnoreNeverallows bool `json:",omitempty"`
@@ -450,6 +492,11 @@ type productVariables struct {
 	ForceMultilibFirstOnDevice bool `json:",omitempty"`
 
 	IncludeTags []string `json:",omitempty"`
+
+	GenerateLinkerMapFiles     bool    `json:",omitempty"`
+	BinarySizeBaseline         *string `json:",omitempty"`
+	BinarySizeMaxGrowthPercent *int    `json:",omitempty"`
+	BinarySizeCheckWarnOnly    bool    `json:",omitempty"`
 }
 
 func boolPtr(v bool) *bool {
@@ -1029,6 +1076,11 @@ func VariableMutator(mctx BottomUpMutatorContext) {
 		name := variableValues.Type().Field(i).Name
 		property := "product_variables." + proptools.PropertyNameForField(name)
 
+		if !variableValue.IsZero() {
+			mctx.Config().recordProductVariableProperty(name, property,
+				reflect.Indirect(reflect.ValueOf(module)).Type().PkgPath())
+		}
+
 		// Check that the variable was set for the product
 		val := productVariables.FieldByName(name)
 		if !val.IsValid() || val.Kind() != reflect.Ptr || val.IsNil() {
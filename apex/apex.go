@@ -52,6 +52,8 @@ func registerApexBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("override_apex", overrideApexFactory)
 	ctx.RegisterModuleType("apex_set", apexSetFactory)
 
+	ctx.RegisterSingletonType("apex_deploy", apexDeploySingletonFactory)
+
 	ctx.PreArchMutators(registerPreArchMutators)
 	ctx.PreDepsMutators(RegisterPreDepsMutators)
 	ctx.PostDepsMutators(RegisterPostDepsMutators)
@@ -107,6 +109,12 @@ type apexBundleProperties struct {
 	// is used.
 	Canned_fs_config *string `android:"path"`
 
+	// List of modes and capabilities to set on files in this APEX bundle, e.g.
+	// fs_config: [{pattern: "bin/mybin", capabilities: ["NET_ADMIN"]}]. Entries are applied in
+	// order, so a later entry overrides the mode or capabilities set by an earlier one for the
+	// same file. Entries from canned_fs_config are preferred over these.
+	Fs_config []apexFsConfigProperties
+
 	ApexNativeDependencies
 
 	Multilib apexMultilibProperties
@@ -190,6 +198,10 @@ type apexBundleProperties struct {
 	// with the tool to sign payload contents.
 	Custom_sign_tool *string
 
+	// Other APEXes that the <name>-deploy target installs together with this APEX in a single
+	// multi-package install, for APEXes that can only be activated together.
+	Deploy_with []string
+
 	// Canonical name of this APEX bundle. Used to determine the path to the
 	// activated APEX on device (i.e. /apex/<apexVariationName>), and used for the
 	// apex mutator variations. For override_apex modules, this is the name of the
@@ -231,6 +243,19 @@ type ApexNativeDependencies struct {
 	Filesystems []string
 }
 
+type apexFsConfigProperties struct {
+	// Path or glob pattern of the files in the APEX bundle, relative to the root of the bundle,
+	// e.g. bin/mybin. Must match at least one file.
+	Pattern *string
+
+	// Octal file mode, e.g. "0750". If not set, the default mode of the files is kept.
+	Mode *string
+
+	// Names of the capabilities to set on the files, with or without the CAP_ prefix, e.g.
+	// NET_ADMIN.
+	Capabilities []string
+}
+
 type apexMultilibProperties struct {
 	// Native dependencies whose compile_multilib is "first"
 	First ApexNativeDependencies
@@ -340,10 +365,17 @@ type overridableProperties struct {
 	Certificate *string
 
 	// Whether this APEX can be compressed or not. Setting this property to false means this
-	// APEX will never be compressed. When set to true, APEX will be compressed if other
-	// conditions, e.g., target device needs to support APEX compression, are also fulfilled.
+	// APEX will never be compressed, and a prebuilt of this APEX must not be compressed either.
+	// When set to true, APEX will be compressed if other conditions, e.g., target device needs
+	// to support APEX compression, are also fulfilled. An updatable APEX with this property set
+	// to true is compressed even if PRODUCT_COMPRESSED_APEX is not set.
 	// Default: false.
 	Compressible *bool
+
+	// Overrides the version in the apex_manifest.json of this APEX. This is intended for
+	// testing APEX update flows with an override_apex that has the same payload as its base
+	// APEX but a higher version.
+	Manifest_version_override *int64
 }
 
 type apexBundle struct {
@@ -368,6 +400,10 @@ type apexBundle struct {
 	publicKeyFile  android.Path
 	privateKeyFile android.Path
 
+	// The signing helper of the external signer of the apex_key, and the files it runs, if any.
+	externalSigner     android.Path
+	externalSignerDeps android.Paths
+
 	// Cert/priv-key for the zip container
 	containerCertificateFile android.Path
 	containerPrivateKeyFile  android.Path
@@ -439,6 +475,10 @@ type apexBundle struct {
 	// debugging purpose.
 	installedFilesFile android.WritablePath
 
+	// Text file listing the path, size and sha256 of each file in the payload of this APEX. It is
+	// dist'ed for release tooling and available via the ".filelist" tag.
+	payloadFileList android.WritablePath
+
 	// List of module names that this APEX is including (to be shown via *-deps-info target).
 	// Used for debugging purpose.
 	android.ApexBundleDepsInfo
@@ -1245,6 +1285,9 @@ const (
 	zipApexSuffix    = ".zipapex"
 	flattenedSuffix  = ".flattened"
 
+	// Output file tag of the list of the files in the payload of an image APEX
+	payloadFileListTag = ".filelist"
+
 	// variant names each of which is for a packaging method
 	imageApexType     = "image"
 	zipApexType       = "zip"
@@ -1346,6 +1389,11 @@ func (a *apexBundle) OutputFiles(tag string) (android.Paths, error) {
 	case "", android.DefaultDistTag:
 		// This is the default dist path.
 		return android.Paths{a.outputFile}, nil
+	case payloadFileListTag:
+		if a.payloadFileList != nil {
+			return android.Paths{a.payloadFileList}, nil
+		}
+		return nil, fmt.Errorf("%q is only available for image APEXes", tag)
 	case imageApexSuffix:
 		// uncompressed one
 		if a.outputApexFile != nil {
@@ -1392,6 +1440,30 @@ func (a *apexBundle) Updatable() bool {
 	return proptools.BoolDefault(a.properties.Updatable, true)
 }
 
+// isCompressible returns true if the APEX should be compressed when it is built as an image. APEXes
+// opt in to compression with compressible: true, which requires PRODUCT_COMPRESSED_APEX unless the
+// APEX is updatable.
+func (a *apexBundle) isCompressible(ctx android.BaseModuleContext) bool {
+	if !proptools.BoolDefault(a.overridableProperties.Compressible, false) {
+		return false
+	}
+	return ctx.Config().CompressedApex() || a.Updatable()
+}
+
+// checkCompressedPrebuilts reports an error if the APEX has compressible: false and a prebuilt of
+// it is a compressed APEX.
+func (a *apexBundle) checkCompressedPrebuilts(ctx android.ModuleContext) {
+	if proptools.BoolDefault(a.overridableProperties.Compressible, true) {
+		return
+	}
+	ctx.VisitDirectDepsWithTag(android.PrebuiltDepTag, func(dep android.Module) {
+		if p, ok := dep.(prebuilt); ok && strings.HasSuffix(p.InstallFilename(), imageCapexSuffix) {
+			ctx.PropertyErrorf("compressible", "prebuilt %s is a compressed APEX (%s) but compressible is false",
+				ctx.OtherModuleName(dep), imageCapexSuffix)
+		}
+	})
+}
+
 func (a *apexBundle) FutureUpdatable() bool {
 	return proptools.BoolDefault(a.properties.Future_updatable, false)
 }
@@ -1780,6 +1852,7 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	// 1) do some validity checks such as apex_available, min_sdk_version, etc.
 	a.checkApexAvailability(ctx)
 	a.checkUpdatable(ctx)
+	a.checkCompressedPrebuilts(ctx)
 	a.CheckMinSdkVersion(ctx)
 	a.checkStaticLinkingToStubLibraries(ctx)
 	a.checkStaticExecutables(ctx)
@@ -1981,6 +2054,8 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 				if key, ok := child.(*apexKey); ok {
 					a.privateKeyFile = key.privateKeyFile
 					a.publicKeyFile = key.publicKeyFile
+					a.externalSigner = key.externalSigner
+					a.externalSignerDeps = key.externalSignerDeps
 				} else {
 					ctx.PropertyErrorf("key", "%q is not an apex_key module", depName)
 				}
@@ -2423,6 +2498,7 @@ func DefaultsFactory(props ...interface{}) android.Module {
 	module.AddProperties(
 		&apexBundleProperties{},
 		&apexTargetBundleProperties{},
+		&apexArchBundleProperties{},
 		&overridableProperties{},
 	)
 
@@ -2576,6 +2652,7 @@ func (a *apexBundle) checkUpdatable(ctx android.ModuleContext) {
 			ctx.PropertyErrorf("future_updatable", "Already updatable. Remove `future_updatable: true:`")
 		}
 		a.checkJavaStableSdkVersion(ctx)
+		a.checkJavaPlatformImplLibraries(ctx)
 		a.checkClasspathFragments(ctx)
 	}
 }
@@ -2611,6 +2688,34 @@ func (a *apexBundle) checkJavaStableSdkVersion(ctx android.ModuleContext) {
 	})
 }
 
+// checkJavaPlatformImplLibraries enforces that the Java deps, including the libraries statically
+// linked into them, do not compile against the implementation of platform libraries. Those are
+// platform internals that can change independently of the APEX, only their stubs are stable.
+func (a *apexBundle) checkJavaPlatformImplLibraries(ctx android.ModuleContext) {
+	ctx.VisitDirectDeps(func(module android.Module) {
+		switch ctx.OtherModuleDependencyTag(module) {
+		case javaLibTag, androidAppTag:
+			info := ctx.OtherModuleProvider(module, java.PlatformImplLibrariesInfoProvider).(java.PlatformImplLibrariesInfo)
+			for _, chain := range info.DependencyChains {
+				lib := chain[len(chain)-1]
+				if android.InList(lib, platformImplLibrariesAllowedInUpdatableApex) {
+					continue
+				}
+				ctx.ModuleErrorf("%q compiles against the implementation of the platform library %q "+
+					"instead of its stubs, which is not allowed in updatable APEXes. Dependency path: %s",
+					ctx.OtherModuleName(module), lib, strings.Join(chain, " -> "))
+			}
+		}
+	})
+}
+
+// A small list of platform libraries that Java deps of updatable APEXes may compile against
+// without going through stubs, as they only provide annotations that are not retained at runtime.
+var platformImplLibrariesAllowedInUpdatableApex = []string{
+	"framework-annotations-lib",
+	"unsupportedappusage",
+}
+
 // checkApexAvailability ensures that the all the dependencies are marked as available for this APEX.
 func (a *apexBundle) checkApexAvailability(ctx android.ModuleContext) {
 	// Let's be practical. Availability for test, host, and the VNDK apex isn't important
@@ -691,6 +691,128 @@ func TestDefaults(t *testing.T) {
 	})
 }
 
+func TestDefaultsSharedByApexes(t *testing.T) {
+	ctx := testApex(t, `
+		apex_defaults {
+			name: "vendor-apex-defaults",
+			key: "myapex.key",
+			vendor: true,
+			file_contexts: ":vendor-file-contexts",
+			updatable: false,
+			min_sdk_version: "29",
+			native_shared_libs: ["mylib"],
+			arch: {
+				arm64: {
+					native_shared_libs: ["mylib_arm64"],
+				},
+			},
+			prebuilts: ["myetc"],
+		}
+
+		apex {
+			name: "myapex",
+			defaults: ["vendor-apex-defaults"],
+			native_shared_libs: ["mylib2"],
+		}
+
+		apex {
+			name: "myapex2",
+			defaults: ["vendor-apex-defaults"],
+			min_sdk_version: "30",
+			prebuilts: ["myetc2"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		filegroup {
+			name: "vendor-file-contexts",
+			srcs: ["vendor_file_contexts"],
+		}
+
+		cc_library {
+			name: "mylib",
+			system_shared_libs: [],
+			stl: "none",
+			vendor: true,
+			min_sdk_version: "29",
+			apex_available: ["myapex", "myapex2"],
+		}
+
+		cc_library {
+			name: "mylib2",
+			system_shared_libs: [],
+			stl: "none",
+			vendor: true,
+			min_sdk_version: "29",
+			apex_available: ["myapex"],
+		}
+
+		cc_library {
+			name: "mylib_arm64",
+			system_shared_libs: [],
+			stl: "none",
+			vendor: true,
+			min_sdk_version: "29",
+			apex_available: ["myapex", "myapex2"],
+		}
+
+		prebuilt_etc {
+			name: "myetc",
+			src: "myprebuilt",
+		}
+
+		prebuilt_etc {
+			name: "myetc2",
+			src: "myprebuilt",
+		}
+	`, withFiles(map[string][]byte{
+		"vendor_file_contexts": nil,
+	}))
+
+	// Lists from the defaults are appended to the ones set in the apex.
+	ensureExactContents(t, ctx, "myapex", "android_common_myapex_image", []string{
+		"etc/myetc",
+		"lib64/mylib.so",
+		"lib64/mylib2.so",
+		"lib64/mylib_arm64.so",
+	})
+	ensureExactContents(t, ctx, "myapex2", "android_common_myapex2_image", []string{
+		"etc/myetc",
+		"etc/myetc2",
+		"lib64/mylib.so",
+		"lib64/mylib_arm64.so",
+	})
+
+	testCases := []struct {
+		module        string
+		minSdkVersion string
+	}{
+		{
+			module:        "myapex",
+			minSdkVersion: "29",
+		},
+		{
+			module:        "myapex2",
+			minSdkVersion: "30",
+		},
+	}
+	for _, tc := range testCases {
+		module := ctx.ModuleForTests(tc.module, "android_common_"+tc.module+"_image")
+		if !module.Module().(*apexBundle).SocSpecific() {
+			t.Errorf("%s: expected to be vendor specific", tc.module)
+		}
+
+		args := module.Rule("apexRule").Args
+		ensureContains(t, args["opt_flags"], "--min_sdk_version "+tc.minSdkVersion)
+		ensureContains(t, args["key"], "testkey.pem")
+		ensureListContains(t, module.Output("file_contexts").Inputs.Strings(), "vendor_file_contexts")
+	}
+}
+
 func TestApexManifest(t *testing.T) {
 	ctx := testApex(t, `
 		apex {
@@ -2346,6 +2468,91 @@ func TestJavaStableSdkVersion(t *testing.T) {
 	}
 }
 
+func TestJavaPlatformImplLibraries(t *testing.T) {
+	bp := `
+		apex {
+			name: "myapex",
+			java_libs: ["myjar"],
+			key: "myapex.key",
+			updatable: %t,
+			min_sdk_version: "29",
+		}
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+		java_library {
+			name: "myjar",
+			srcs: ["foo/bar/MyClass.java"],
+			sdk_version: "current",
+			apex_available: ["myapex"],
+			min_sdk_version: "29",
+			static_libs: ["helper"],
+		}
+		java_library {
+			name: "helper",
+			srcs: ["foo/bar/MyClass.java"],
+			sdk_version: "current",
+			apex_available: ["myapex"],
+			min_sdk_version: "29",
+			libs: ["%s"],
+		}
+		java_library {
+			name: "platform-impl",
+			srcs: ["foo/bar/MyClass.java"],
+			sdk_version: "current",
+		}
+		java_library {
+			name: "framework-annotations-lib",
+			srcs: ["foo/bar/MyClass.java"],
+			sdk_version: "current",
+		}
+	`
+
+	testCases := []struct {
+		name          string
+		updatable     bool
+		lib           string
+		expectedError string
+	}{
+		{
+			name:      "Updatable apex with static dep using platform implementation",
+			updatable: true,
+			lib:       "platform-impl",
+			expectedError: `\Q"myjar" compiles against the implementation of the platform library "platform-impl" ` +
+				`instead of its stubs, which is not allowed in updatable APEXes. ` +
+				`Dependency path: myjar -> helper -> platform-impl\E`,
+		},
+		{
+			name:      "Non-updatable apex with static dep using platform implementation",
+			updatable: false,
+			lib:       "platform-impl",
+		},
+		{
+			name:      "Updatable apex with static dep using allowed platform library",
+			updatable: true,
+			lib:       "framework-annotations-lib",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			errorHandler := android.FixtureExpectsNoErrors
+			if test.expectedError != "" {
+				errorHandler = android.FixtureExpectsAtLeastOneErrorMatchingPattern(test.expectedError)
+			}
+			android.GroupFixturePreparers(
+				java.PrepareForTestWithJavaDefaultModules,
+				PrepareForTestWithApexBuildComponents,
+				prepareForTestWithMyapex,
+			).
+				ExtendWithErrorHandler(errorHandler).
+				RunTestWithBp(t, fmt.Sprintf(bp, test.updatable, test.lib))
+		})
+	}
+}
+
 func TestApexMinSdkVersion_ErrorIfDepIsNewer(t *testing.T) {
 	testApexError(t, `module "mylib2".*: should support min_sdk_version\(29\) for "myapex"`, `
 		apex {
@@ -2607,6 +2814,85 @@ func TestFilesInSubDir(t *testing.T) {
 	ensureContains(t, cmd, "/bin/foo/bar ")
 }
 
+func TestApexFsConfig(t *testing.T) {
+	bp := `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			binaries: ["mybin", "mybin2"],
+			updatable: false,
+			fs_config: [
+				{
+					pattern: "bin/mybin",
+					capabilities: ["NET_ADMIN", "CAP_NET_RAW"],
+					mode: "0750",
+				},
+				{
+					pattern: "bin/*",
+					mode: "0755",
+				},
+				{
+					pattern: "/lib64/mylib.so",
+					capabilities: ["SYS_NICE"],
+				},
+			],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+
+		cc_binary {
+			name: "mybin",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+
+		cc_binary {
+			name: "mybin2",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+	`
+
+	ctx := testApex(t, bp)
+	cmd := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("generateFsConfig").RuleParams.Command
+
+	// The entries are applied in order, so the mode of mybin is overridden by the second one.
+	ensureContains(t, cmd, "echo '/bin/mybin 0 2000 0755 capabilities=0x3000';")
+	ensureContains(t, cmd, "echo '/bin/mybin2 0 2000 0755';")
+	ensureContains(t, cmd, "echo '/lib64/mylib.so 1000 1000 0644 capabilities=0x800000';")
+	ensureContains(t, cmd, `echo 'warning: myapex: fs_config "bin/*" overrides the mode of /bin/mybin set by "bin/mybin"' >&2;`)
+	ensureNotContains(t, cmd, "/bin/mybin2 set by")
+
+	// The entries are preferred over the default ones.
+	if strings.Index(cmd, "echo '/bin/mybin 0 2000 0755 capabilities=0x3000';") < strings.Index(cmd, "echo '/bin/mybin 0 2000 0755';") {
+		t.Errorf("fs_config entries should come after the default ones: %s", cmd)
+	}
+
+	testApexError(t, `pattern "bin/missing" does not match any file in the APEX`,
+		strings.Replace(bp, `pattern: "bin/*"`, `pattern: "bin/missing"`, 1))
+	testApexError(t, `unknown capability "NET_MAGIC" for "bin/mybin"`,
+		strings.Replace(bp, `"CAP_NET_RAW"`, `"NET_MAGIC"`, 1))
+	testApexError(t, `invalid mode "0789" for "bin/mybin", must be an octal number`,
+		strings.Replace(bp, `mode: "0750"`, `mode: "0789"`, 1))
+}
+
 func TestFilesInSubDirWhenNativeBridgeEnabled(t *testing.T) {
 	ctx := testApex(t, `
 		apex {
@@ -4378,6 +4664,92 @@ func TestApexWithShBinary(t *testing.T) {
 	ensureContains(t, copyCmds, "image.apex/bin/script/myscript.sh")
 }
 
+func TestApexIsReproducible(t *testing.T) {
+	// Builds the same apex twice, with its contents listed in a different order, and checks that
+	// the files are staged identically and with a fixed timestamp.
+	build := func(nativeSharedLibs, binaries string) *android.TestContext {
+		return testApex(t, `
+			apex {
+				name: "myapex",
+				key: "myapex.key",
+				native_shared_libs: `+nativeSharedLibs+`,
+				binaries: `+binaries+`,
+				updatable: false,
+			}
+
+			apex_key {
+				name: "myapex.key",
+				public_key: "testkey.avbpubkey",
+				private_key: "testkey.pem",
+			}
+
+			cc_library {
+				name: "mylib",
+				srcs: ["mylib.cpp"],
+				system_shared_libs: [],
+				stl: "none",
+				apex_available: ["myapex"],
+			}
+
+			cc_library {
+				name: "mylib2",
+				srcs: ["mylib.cpp"],
+				system_shared_libs: [],
+				stl: "none",
+				apex_available: ["myapex"],
+			}
+
+			cc_binary {
+				name: "mybin",
+				srcs: ["mylib.cpp"],
+				system_shared_libs: [],
+				stl: "none",
+				apex_available: ["myapex"],
+			}
+
+			cc_binary {
+				name: "mybin2",
+				srcs: ["mylib.cpp"],
+				system_shared_libs: [],
+				stl: "none",
+				apex_available: ["myapex"],
+			}
+		`)
+	}
+
+	firstCtx := build(`["mylib", "mylib2"]`, `["mybin", "mybin2"]`)
+	secondCtx := build(`["mylib2", "mylib"]`, `["mybin2", "mybin"]`)
+	first := firstCtx.ModuleForTests("myapex", "android_common_myapex_image")
+	second := secondCtx.ModuleForTests("myapex", "android_common_myapex_image")
+
+	// The same files are staged at the same paths, in the same order.
+	android.AssertDeepEquals(t, "staged files",
+		getFiles(t, firstCtx, "myapex", "android_common_myapex_image"),
+		getFiles(t, secondCtx, "myapex", "android_common_myapex_image"))
+
+	// The apex and the canned_fs_config are built from the same inputs into the same outputs.
+	outputs := func(params android.TestingBuildParams) []string {
+		paths := params.Outputs.Paths()
+		if params.Output != nil {
+			paths = append(paths, params.Output)
+		}
+		return android.PathsRelativeToTop(paths)
+	}
+	inputs := func(params android.TestingBuildParams) []string {
+		return android.PathsRelativeToTop(append(params.Inputs, params.Implicits...))
+	}
+	for _, rule := range []string{"apexRule", "generateFsConfig"} {
+		firstRule := first.Rule(rule)
+		secondRule := second.Rule(rule)
+		android.AssertDeepEquals(t, rule+" outputs", outputs(firstRule), outputs(secondRule))
+		android.AssertDeepEquals(t, rule+" inputs", inputs(firstRule), inputs(secondRule))
+	}
+
+	// The staged files are given a fixed timestamp before the apex is built.
+	ensureContains(t, first.Rule("apexRule").RuleParams.Command,
+		"TZ=UTC find ${image_dir} -exec touch -h -t ${imageFileTimestamp} {} +")
+}
+
 func TestApexInVariousPartition(t *testing.T) {
 	testcases := []struct {
 		propName, parition, flattenedPartition string
@@ -4561,6 +4933,86 @@ func TestApexKeyFromOtherModule(t *testing.T) {
 	}
 }
 
+func TestApexKeyExternalSigner(t *testing.T) {
+	ctx := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+			external_signer: "kms_signer",
+			external_signer_args: ["--key", "release_apex"],
+		}
+
+		sh_binary_host {
+			name: "kms_signer",
+			src: "kms_signer.sh",
+			symlinks: ["kms_signer_alias"],
+		}
+	`, android.FixtureMergeMockFs(android.MockFS{
+		"kms_signer.sh": nil,
+	}))
+
+	tool := ctx.ModuleForTests("kms_signer", "linux_glibc_x86_64").Module().(*sh.ShBinary).HostToolPath().Path()
+	key := ctx.ModuleForTests("myapex.key", "android_common")
+	signer := key.Output("myapex.key_signer.sh")
+	android.AssertStringDoesContain(t, "signer command", signer.RuleParams.Command,
+		"exec "+tool.String()+" --key release_apex")
+	signerPath := android.PathRelativeToTop(signer.Output)
+
+	apexRule := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("apexRule")
+	android.AssertStringDoesContain(t, "opt_flags", apexRule.Args["opt_flags"],
+		"--signing_args '--signing_helper "+signerPath+"'")
+	// The public key is still embedded in the APEX.
+	android.AssertStringDoesContain(t, "opt_flags", apexRule.Args["opt_flags"],
+		"--pubkey vendor/foo/devkeys/testkey.avbpubkey")
+	implicits := android.PathsRelativeToTop(apexRule.Implicits)
+	android.AssertStringListContains(t, "implicits", implicits, signerPath)
+	android.AssertStringListContains(t, "implicits", implicits, android.PathRelativeToTop(tool))
+	// The other files installed by the tool are needed to run it.
+	alias := filepath.Join(filepath.Dir(android.PathRelativeToTop(tool)), "kms_signer_alias")
+	android.AssertStringListContains(t, "implicits", implicits, alias)
+
+	// The container is signed by the external signer instead of signapk.
+	apex := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+	signContainer := apex.Rule("externalSignContainer")
+	android.AssertStringEquals(t, "signer", signerPath,
+		android.StringRelativeToTop(ctx.Config(), signContainer.Args["signer"]))
+	android.AssertStringEquals(t, "certificate", "vendor/foo/devkeys/test.x509.pem",
+		signContainer.Args["certificate"])
+	containerImplicits := android.PathsRelativeToTop(signContainer.Implicits)
+	android.AssertStringListContains(t, "container implicits", containerImplicits, signerPath)
+	android.AssertStringListContains(t, "container implicits", containerImplicits, alias)
+	android.AssertStringListDoesNotContain(t, "container implicits", containerImplicits,
+		"vendor/foo/devkeys/test.pk8")
+	if rule := apex.MaybeRule("signapk"); rule.Rule != nil {
+		t.Errorf("expected the container not to be signed by signapk")
+	}
+}
+
+func TestApexKeyExternalSignerNotHostTool(t *testing.T) {
+	testApexError(t, `external_signer: "kms_signer" is not a host tool module`, `
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+			external_signer: "kms_signer",
+		}
+
+		cc_library_static {
+			name: "kms_signer",
+			host_supported: true,
+			system_shared_libs: [],
+			stl: "none",
+		}
+	`)
+}
+
 func TestPrebuilt(t *testing.T) {
 	ctx := testApex(t, `
 		prebuilt_apex {
@@ -5380,7 +5832,100 @@ func TestBootDexJarsFromSourcesAndPrebuilts(t *testing.T) {
 
 		java_sdk_library_import {
 			name: "libbar",
-			prefer: true,
+			prefer: true,
+			public: {
+				jars: ["libbar.jar"],
+			},
+			apex_available: ["myapex"],
+			shared_library: false,
+			permitted_packages: ["bar"],
+		}
+
+		java_sdk_library {
+			name: "libbar",
+			srcs: ["foo/bar/MyClass.java"],
+			unsafe_ignore_missing_latest_api: true,
+			apex_available: ["myapex"],
+		}
+	`
+
+		ctx := testDexpreoptWithApexes(t, bp, "", preparer, fragment)
+		checkBootDexJarPath(t, ctx, "libfoo", "out/soong/.intermediates/myapex.deapexer/android_common/deapexer/javalib/libfoo.jar")
+		checkBootDexJarPath(t, ctx, "libbar", "out/soong/.intermediates/myapex.deapexer/android_common/deapexer/javalib/libbar.jar")
+
+		// Verify the correct module jars contribute to the hiddenapi index file.
+		checkHiddenAPIIndexFromClassesInputs(t, ctx, ``)
+		checkHiddenAPIIndexFromFlagsInputs(t, ctx, `
+			my-bootclasspath-fragment/index.csv
+			out/soong/.intermediates/frameworks/base/boot/platform-bootclasspath/android_common/hiddenapi-monolithic/index-from-classes.csv
+		`)
+	})
+}
+
+func TestModuleFamilySelection(t *testing.T) {
+	bp := `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			bootclasspath_fragments: ["my-bootclasspath-fragment"],
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		bootclasspath_fragment {
+			name: "my-bootclasspath-fragment",
+			contents: ["libbar"],
+			apex_available: ["myapex"],
+		}
+
+		java_sdk_library {
+			name: "libbar",
+			srcs: ["foo/bar/MyClass.java"],
+			unsafe_ignore_missing_latest_api: true,
+			apex_available: ["myapex"],
+			permitted_packages: ["bar"],
+		}
+
+		prebuilt_apex {
+			name: "myapex",
+			module_family: "myfamily",
+			%s
+			arch: {
+				arm64: {
+					src: "myapex-arm64.apex",
+				},
+				arm: {
+					src: "myapex-arm.apex",
+				},
+			},
+			exported_bootclasspath_fragments: ["my-bootclasspath-fragment"],
+		}
+
+		prebuilt_bootclasspath_fragment {
+			name: "my-bootclasspath-fragment",
+			module_family: "myfamily",
+			%s
+			contents: ["libbar"],
+			apex_available: ["myapex"],
+			hidden_api: {
+				annotation_flags: "my-bootclasspath-fragment/annotation-flags.csv",
+				metadata: "my-bootclasspath-fragment/metadata.csv",
+				index: "my-bootclasspath-fragment/index.csv",
+				signature_patterns: "my-bootclasspath-fragment/signature-patterns.csv",
+				filtered_stub_flags: "my-bootclasspath-fragment/filtered-stub-flags.csv",
+				filtered_flags: "my-bootclasspath-fragment/filtered-flags.csv",
+			},
+		}
+
+		java_sdk_library_import {
+			name: "libbar",
+			module_family: "myfamily",
+			%s
 			public: {
 				jars: ["libbar.jar"],
 			},
@@ -5388,25 +5933,54 @@ func TestBootDexJarsFromSourcesAndPrebuilts(t *testing.T) {
 			shared_library: false,
 			permitted_packages: ["bar"],
 		}
+	`
 
-		java_sdk_library {
-			name: "libbar",
-			srcs: ["foo/bar/MyClass.java"],
-			unsafe_ignore_missing_latest_api: true,
-			apex_available: ["myapex"],
+	preparer := func(selection ...string) android.FixturePreparer {
+		return android.GroupFixturePreparers(
+			java.FixtureConfigureApexBootJars("myapex:libbar"),
+			android.FixtureAddTextFile("frameworks/base/Android.bp", ""),
+			android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+				variables.ModuleFamilySelection = selection
+			}),
+		)
+	}
+	fragment := java.ApexVariantReference{
+		Apex:   proptools.StringPtr("myapex"),
+		Module: proptools.StringPtr("my-bootclasspath-fragment"),
+	}
+
+	checkUsesPrebuilts := func(t *testing.T, ctx *android.TestContext, expected bool) {
+		t.Helper()
+		for _, name := range []string{"prebuilt_myapex", "prebuilt_my-bootclasspath-fragment", "prebuilt_libbar"} {
+			variants := ctx.ModuleVariantsForTests(name)
+			if len(variants) == 0 {
+				t.Errorf("no variants of %s", name)
+			}
+			for _, variant := range variants {
+				p := android.GetEmbeddedPrebuilt(ctx.ModuleForTests(name, variant).Module())
+				android.AssertBoolEquals(t, name+" "+variant+" uses prebuilt", expected, p.UsePrebuilt())
+			}
 		}
-	`
+	}
 
-		ctx := testDexpreoptWithApexes(t, bp, "", preparer, fragment)
-		checkBootDexJarPath(t, ctx, "libfoo", "out/soong/.intermediates/myapex.deapexer/android_common/deapexer/javalib/libfoo.jar")
-		checkBootDexJarPath(t, ctx, "libbar", "out/soong/.intermediates/myapex.deapexer/android_common/deapexer/javalib/libbar.jar")
+	t.Run("prebuilts selected", func(t *testing.T) {
+		ctx := testDexpreoptWithApexes(t, fmt.Sprintf(bp, "", "", ""), "", preparer("myfamily:prebuilt"), fragment)
+		checkUsesPrebuilts(t, ctx, true)
+	})
 
-		// Verify the correct module jars contribute to the hiddenapi index file.
-		checkHiddenAPIIndexFromClassesInputs(t, ctx, ``)
-		checkHiddenAPIIndexFromFlagsInputs(t, ctx, `
-			my-bootclasspath-fragment/index.csv
-			out/soong/.intermediates/frameworks/base/boot/platform-bootclasspath/android_common/hiddenapi-monolithic/index-from-classes.csv
-		`)
+	t.Run("sources selected", func(t *testing.T) {
+		prefer := "prefer: true,"
+		ctx := testDexpreoptWithApexes(t, fmt.Sprintf(bp, prefer, prefer, prefer), "", preparer("myfamily:source"), fragment)
+		checkUsesPrebuilts(t, ctx, false)
+	})
+
+	t.Run("inconsistent", func(t *testing.T) {
+		// Mixing the source and prebuilt members of the family fails in other ways too, e.g. the source
+		// fragment does not find the dex boot jar of the prebuilt libbar, so allow missing
+		// dependencies to turn those failures into build time errors.
+		testDexpreoptWithApexes(t, fmt.Sprintf(bp, "", "", "prefer: true,"),
+			`module family "myfamily" is inconsistent, the prebuilts of libbar(, [^ ]+)* are used but the sources of my-bootclasspath-fragment, myapex are used`,
+			android.GroupFixturePreparers(preparer(), android.PrepareForTestWithAllowMissingDependencies), fragment)
 	})
 }
 
@@ -6350,6 +6924,92 @@ func TestOverrideApex(t *testing.T) {
 	ensureNotContains(t, androidMk, "LOCAL_MODULE_STEM := myapex.apex")
 }
 
+func TestOverrideApexManifestVersion(t *testing.T) {
+	ctx := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			updatable: false,
+		}
+
+		override_apex {
+			name: "myapex.v2",
+			base: "myapex",
+			manifest_version_override: 999999999,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["myapex"],
+		}
+	`)
+
+	originalVariant := "android_common_myapex_image"
+	overriddenVariant := "android_common_myapex.v2_myapex_image"
+
+	// The payload of the overriding APEX is identical to that of the base APEX.
+	var originalFiles, overriddenFiles []string
+	for _, f := range getFiles(t, ctx, "myapex", originalVariant) {
+		originalFiles = append(originalFiles, f.path)
+	}
+	for _, f := range getFiles(t, ctx, "myapex", overriddenVariant) {
+		overriddenFiles = append(overriddenFiles, f.path)
+	}
+	ensureListContains(t, originalFiles, "lib64/mylib.so")
+	android.AssertDeepEquals(t, "files in apex", originalFiles, overriddenFiles)
+
+	// The key and file_contexts of the base APEX are reused.
+	original := ctx.ModuleForTests("myapex", originalVariant)
+	overridden := ctx.ModuleForTests("myapex", overriddenVariant)
+	ensureContains(t, overridden.Rule("apexRule").Args["opt_flags"], "--pubkey testkey.avbpubkey")
+	ensureContains(t, overridden.Output("file_contexts").RuleParams.Command,
+		"cat system/sepolicy/apex/myapex-file_contexts")
+
+	// Only the manifest of the overriding APEX has its version overridden.
+	ensureNotContains(t, original.Rule("apexManifestRule").Args["opt"], "version")
+	ensureContains(t, overridden.Rule("apexManifestRule").Args["opt"], "-i version 999999999")
+
+	// The overriding APEX is named differently.
+	overriddenBundle := overridden.Module().(*apexBundle)
+	android.AssertStringEquals(t, "name", "myapex.v2", overriddenBundle.Name())
+	data := android.AndroidMkDataForTest(t, ctx, overriddenBundle)
+	var builder strings.Builder
+	data.Custom(&builder, overriddenBundle.Name(), "TARGET_", "", data)
+	ensureContains(t, builder.String(), "LOCAL_MODULE_STEM := myapex.v2.apex")
+}
+
+func TestOverrideApexManifestVersionMustBePositive(t *testing.T) {
+	testApexError(t, `manifest_version_override: must be a positive number, got 0`, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			updatable: false,
+		}
+
+		override_apex {
+			name: "myapex.v2",
+			base: "myapex",
+			manifest_version_override: 0,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`)
+}
+
 func TestMinSdkVersionOverride(t *testing.T) {
 	// Override from 29 to 31
 	minSdkOverride31 := "31"
@@ -8507,6 +9167,193 @@ func TestCompressedApex(t *testing.T) {
 	ensureContains(t, androidMk, "LOCAL_MODULE_STEM := myapex.capex\n")
 }
 
+func TestCompressibleOverridesProductFlag(t *testing.T) {
+	testCases := []struct {
+		name           string
+		compressedApex bool
+		compressible   string
+		updatable      bool
+		compressed     bool
+	}{
+		{
+			name:           "product flag set, compressible not set",
+			compressedApex: true,
+			updatable:      true,
+			compressed:     false,
+		},
+		{
+			name:           "product flag set, compressible: true",
+			compressedApex: true,
+			compressible:   "compressible: true,",
+			updatable:      true,
+			compressed:     true,
+		},
+		{
+			name:           "product flag set, compressible: true, not updatable",
+			compressedApex: true,
+			compressible:   "compressible: true,",
+			updatable:      false,
+			compressed:     true,
+		},
+		{
+			name:           "product flag set, compressible: false",
+			compressedApex: true,
+			compressible:   "compressible: false,",
+			updatable:      true,
+			compressed:     false,
+		},
+		{
+			name:           "product flag not set, compressible: true",
+			compressedApex: false,
+			compressible:   "compressible: true,",
+			updatable:      true,
+			compressed:     true,
+		},
+		{
+			name:           "product flag not set, compressible: false",
+			compressedApex: false,
+			compressible:   "compressible: false,",
+			updatable:      true,
+			compressed:     false,
+		},
+		{
+			name:           "product flag not set, compressible: true, not updatable",
+			compressedApex: false,
+			compressible:   "compressible: true,",
+			updatable:      false,
+			compressed:     false,
+		},
+		{
+			name:           "product flag not set, compressible not set",
+			compressedApex: false,
+			updatable:      true,
+			compressed:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := testApex(t, `
+				apex {
+					name: "myapex",
+					key: "myapex.key",
+					`+tc.compressible+`
+					updatable: `+strconv.FormatBool(tc.updatable)+`,
+					min_sdk_version: "29",
+				}
+				apex_key {
+					name: "myapex.key",
+					public_key: "testkey.avbpubkey",
+					private_key: "testkey.pem",
+				}
+			`,
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					variables.CompressedApex = proptools.BoolPtr(tc.compressedApex)
+				}),
+			)
+
+			module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+			ab := module.Module().(*apexBundle)
+			android.AssertBoolEquals(t, "isCompressed", tc.compressed, ab.isCompressed)
+			android.AssertBoolEquals(t, "has compressRule", tc.compressed, module.MaybeRule("compressRule").Rule != nil)
+		})
+	}
+}
+
+func TestPrebuiltCompressedApexNotCompressible(t *testing.T) {
+	bp := func(filename string) string {
+		return `
+			apex {
+				name: "myapex",
+				key: "myapex.key",
+				compressible: false,
+				updatable: false,
+			}
+
+			apex_key {
+				name: "myapex.key",
+				public_key: "testkey.avbpubkey",
+				private_key: "testkey.pem",
+			}
+
+			apex_set {
+				name: "myapex",
+				set: "myapex.apks",
+				filename: "` + filename + `",
+				prefer: true,
+			}
+		`
+	}
+
+	testApexError(t, `compressible: prebuilt prebuilt_myapex is a compressed APEX \(.capex\) but compressible is false`,
+		bp("myapex.capex"))
+
+	// An uncompressed prebuilt is allowed.
+	testApex(t, bp("myapex.apex"))
+}
+
+func TestApexDeploy(t *testing.T) {
+	ctx := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			compressible: true,
+			updatable: false,
+			deploy_with: ["myapex2"],
+		}
+		apex {
+			name: "myapex2",
+			key: "myapex.key",
+			updatable: false,
+		}
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.CompressedApex = proptools.BoolPtr(true)
+		}),
+	)
+
+	myapexFile := "out/soong/.intermediates/myapex/android_common_myapex_image/myapex.capex"
+	myapex2File := "out/soong/.intermediates/myapex2/android_common_myapex2_image/myapex2.apex"
+
+	deploy := ctx.SingletonForTests("apex_deploy")
+	script := android.ContentFromFileRuleForTests(t, deploy.Output("out/soong/apex/deploy/myapex-deploy.sh"))
+	ensureContains(t, script, "adb install-multi-package \"$@\" "+myapexFile+" "+myapex2File+"\n")
+	script2 := android.ContentFromFileRuleForTests(t, deploy.Output("out/soong/apex/deploy/myapex2-deploy.sh"))
+	ensureContains(t, script2, "adb install \"$@\" "+myapex2File+"\n")
+
+	deployTargets := deploy.Singleton().(*apexDeploySingleton).deployTargets
+	android.AssertPathsRelativeToTopEquals(t, "myapex-deploy", []string{
+		"out/soong/apex/deploy/myapex-deploy.sh",
+		myapexFile,
+		myapex2File,
+	}, deployTargets["myapex"])
+	android.AssertPathsRelativeToTopEquals(t, "myapex2-deploy", []string{
+		"out/soong/apex/deploy/myapex2-deploy.sh",
+		myapex2File,
+	}, deployTargets["myapex2"])
+}
+
+func TestApexDeployWithUnknownApex(t *testing.T) {
+	testApexError(t, `deploy_with: "otherapex" is not an APEX`, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			updatable: false,
+			deploy_with: ["otherapex"],
+		}
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`)
+}
+
 func TestPreferredPrebuiltSharedLibDep(t *testing.T) {
 	ctx := testApex(t, `
 		apex {
@@ -9108,6 +9955,59 @@ func TestApexOutputFileProducer(t *testing.T) {
 	}
 }
 
+func TestApexPayloadFileList(t *testing.T) {
+	ctx := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+	`)
+
+	module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+	rule := module.Rule("generatePayloadFileList")
+	android.AssertStringEquals(t, "output", "payload-filelist.txt", rule.Output.Base())
+
+	cmd := rule.RuleParams.Command
+	ensureContains(t, cmd, "printf '%s %s %s\\n' apex_manifest.pb ")
+	ensureContains(t, cmd, "printf '%s %s %s\\n' lib64/mylib.so ")
+	ensureContains(t, cmd, "sha256sum")
+
+	inputs := rule.Implicits.Strings()
+	android.AssertStringListContains(t, "inputs", inputs,
+		"out/soong/.intermediates/myapex/android_common_myapex_image/apex_manifest.pb")
+	android.AssertStringListContains(t, "inputs", inputs,
+		"out/soong/.intermediates/mylib/android_arm64_armv8-a_shared_apex10000/mylib.so")
+
+	ab := module.Module().(*apexBundle)
+	outputs, err := ab.OutputFiles(".filelist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	android.AssertPathsRelativeToTopEquals(t, "filelist output", []string{rule.Output.String()}, outputs)
+
+	data := android.AndroidMkDataForTest(t, ctx, ab)
+	var builder strings.Builder
+	data.Custom(&builder, ab.BaseModuleName(), "TARGET_", "", data)
+	ensureContains(t, android.StringRelativeToTop(ctx.Config(), builder.String()),
+		"$(call dist-for-goals,checkbuild,out/soong/.intermediates/myapex/android_common_myapex_image/payload-filelist.txt:myapex-filelist.txt)")
+}
+
 func TestSdkLibraryCanHaveHigherMinSdkVersion(t *testing.T) {
 	preparer := android.GroupFixturePreparers(
 		PrepareForTestWithApexBuildComponents,
@@ -9481,6 +10381,50 @@ func TestApexStrictUpdtabilityLintBcpFragmentDeps(t *testing.T) {
 	}
 }
 
+func TestBootclasspathFragmentContentsSdkVersions(t *testing.T) {
+	bp := `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			bootclasspath_fragments: ["mybootclasspathfragment"],
+			updatable: true,
+			min_sdk_version: "30",
+		}
+		apex_key {
+			name: "myapex.key",
+		}
+		bootclasspath_fragment {
+			name: "mybootclasspathfragment",
+			contents: ["myjavalib"],
+			apex_available: ["myapex"],
+			%s
+		}
+		java_library {
+			name: "myjavalib",
+			srcs: ["MyClass.java"],
+			apex_available: [ "myapex" ],
+			sdk_version: "%s",
+			min_sdk_version: "%s",
+			compile_dex: true,
+		}
+		`
+	preparer := dexpreopt.FixtureSetApexBootJars("myapex:myjavalib")
+
+	t.Run("min_sdk_version mismatch", func(t *testing.T) {
+		testApexError(t, `\Qcontents module "myjavalib" has min_sdk_version 29 but the apex containing this fragment has min_sdk_version 30\E`,
+			fmt.Sprintf(bp, "", "current", "29"), preparer)
+	})
+
+	t.Run("sdk_version not allowed", func(t *testing.T) {
+		testApexError(t, `\Qcontents module "myjavalib" has sdk_version "test_current" but must use one of\E`,
+			fmt.Sprintf(bp, "", "test_current", "30"), preparer)
+	})
+
+	t.Run("allowlist", func(t *testing.T) {
+		testApex(t, fmt.Sprintf(bp, `sdk_version_check_allowlist: ["myjavalib"],`, "current", "29"), preparer)
+	})
+}
+
 // updatable apexes should propagate updatable=true to its apps
 func TestUpdatableApexEnforcesAppUpdatability(t *testing.T) {
 	bp := `
@@ -132,6 +132,98 @@ test_device/dex_artjars/android/apex/art_boot_images/javalib/arm64/boot-quuz.vde
 `)
 }
 
+func TestBootclasspathFragments_BootImageProfileFragments(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForTestWithBootclasspathFragment,
+		java.FixtureConfigureBootJars("com.android.art:baz", "platform:foo"),
+		prepareForTestWithArtApex,
+		dexpreopt.FixtureSetBootImageProfileFragments("device/sample/boot-image-profile-fragment.txt"),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["b.java"],
+			installable: true,
+		}
+
+		apex {
+			name: "com.android.art",
+			key: "com.android.art.key",
+			bootclasspath_fragments: ["art-bootclasspath-fragment"],
+			updatable: false,
+		}
+
+		apex_key {
+			name: "com.android.art.key",
+			public_key: "com.android.art.avbpubkey",
+			private_key: "com.android.art.pem",
+		}
+
+		java_library {
+			name: "baz",
+			apex_available: [
+				"com.android.art",
+			],
+			srcs: ["b.java"],
+			compile_dex: true,
+		}
+
+		bootclasspath_fragment {
+			name: "art-bootclasspath-fragment",
+			image_name: "art",
+			contents: ["baz"],
+			boot_image_profile_fragments: ["art-profile-fragment.txt"],
+			apex_available: [
+				"com.android.art",
+			],
+		}
+
+		platform_bootclasspath {
+			name: "platform-bootclasspath",
+			fragments: [
+				{
+					apex: "com.android.art",
+					module: "art-bootclasspath-fragment",
+				},
+			],
+		}
+`,
+	)
+
+	profileCommand := func(module, variant string) string {
+		rule := result.ModuleForTests(module, variant).Output("boot.prof")
+		return android.StringRelativeToTop(result.Config, rule.RuleParams.Command)
+	}
+
+	// The art boot image profile only contains the fragment from the art-bootclasspath-fragment,
+	// which is verified against the art boot image.
+	artCommand := profileCommand("art-bootclasspath-fragment", "android_common_apex10000")
+	android.AssertStringDoesContain(t, "art verify", artCommand,
+		"references classes that are not in the jars of boot image art")
+	android.AssertStringDoesContain(t, "art verify", artCommand,
+		"--create-profile-from=art-profile-fragment.txt --reference-profile-file=")
+	android.AssertStringDoesContain(t, "art merge", artCommand,
+		"--create-profile-from=art/build/boot/boot-image-profile.txt ")
+	android.AssertStringDoesContain(t, "art merge", artCommand, "--force-merge")
+	android.AssertStringDoesNotContain(t, "art merge", artCommand, "device/sample/boot-image-profile-fragment.txt")
+
+	// The platform boot image profile contains the fragments from the product and from all the
+	// bootclasspath_fragment modules, only the product fragment is verified against the platform boot
+	// image.
+	platformCommand := profileCommand("platform-bootclasspath", "android_common")
+	android.AssertStringDoesContain(t, "platform verify", platformCommand,
+		"boot image profile fragment device/sample/boot-image-profile-fragment.txt references classes")
+	android.AssertStringDoesNotContain(t, "platform verify", platformCommand,
+		"boot image profile fragment art-profile-fragment.txt")
+	for _, profile := range []string{
+		"art/build/boot/boot-image-profile.txt",
+		"device/sample/boot-image-profile-fragment.txt",
+		"art-profile-fragment.txt",
+	} {
+		android.AssertStringDoesContain(t, "platform merge", platformCommand, "--create-profile-from="+profile+" ")
+	}
+	android.AssertStringDoesContain(t, "platform merge", platformCommand, "--force-merge")
+}
+
 func TestBootclasspathFragments_FragmentDependency(t *testing.T) {
 	result := android.GroupFixturePreparers(
 		prepareForTestWithBootclasspathFragment,
@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	"android/soong/android"
+	"android/soong/jar"
 	"android/soong/java"
 
 	"github.com/google/blueprint"
@@ -69,6 +70,10 @@ func init() {
 	pctx.HostBinToolVariable("apex_compression_tool", "apex_compression_tool")
 	pctx.HostBinToolVariable("dexdeps", "dexdeps")
 	pctx.SourcePathVariable("genNdkUsedbyApexPath", "build/soong/scripts/gen_ndk_usedby_apex.sh")
+	// The files copied to the image directory are given the fixed modification time that soong_zip
+	// also uses, so that the timestamps that end up in the payload don't depend on when the files
+	// were built or copied, and two builds of the same tree produce identical APEXes.
+	pctx.StaticVariable("imageFileTimestamp", jar.DefaultTime.Format("200601021504.05"))
 }
 
 var (
@@ -101,6 +106,7 @@ var (
 	apexRule = pctx.StaticRule("apexRule", blueprint.RuleParams{
 		Command: `rm -rf ${image_dir} && mkdir -p ${image_dir} && ` +
 			`(. ${out}.copy_commands) && ` +
+			`TZ=UTC find ${image_dir} -exec touch -h -t ${imageFileTimestamp} {} + && ` +
 			`APEXER_TOOL_PATH=${tool_path} ` +
 			`${apexer} --force --manifest ${manifest} ` +
 			`--file_contexts ${file_contexts} ` +
@@ -119,6 +125,7 @@ var (
 	zipApexRule = pctx.StaticRule("zipApexRule", blueprint.RuleParams{
 		Command: `rm -rf ${image_dir} && mkdir -p ${image_dir} && ` +
 			`(. ${out}.copy_commands) && ` +
+			`TZ=UTC find ${image_dir} -exec touch -h -t ${imageFileTimestamp} {} + && ` +
 			`APEXER_TOOL_PATH=${tool_path} ` +
 			`${apexer} --force --manifest ${manifest} ` +
 			`--payload_type zip ` +
@@ -129,6 +136,11 @@ var (
 		Description:    "ZipAPEX ${image_dir} => ${out}",
 	}, "tool_path", "image_dir", "copy_commands", "manifest")
 
+	// Signs the zip container of an APEX with the external signer of its apex_key.
+	externalSignContainer = pctx.StaticRule("externalSignContainer", blueprint.RuleParams{
+		Command: `rm -f ${out} && ${signer} --sign_container ${certificate} ${in} ${out}`,
+	}, "signer", "certificate")
+
 	apexProtoConvertRule = pctx.AndroidStaticRule("apexProtoConvertRule",
 		blueprint.RuleParams{
 			Command:     `${aapt2} convert --output-format proto $in -o $out`,
@@ -194,6 +206,15 @@ func (a *apexBundle) buildManifest(ctx android.ModuleContext, provideNativeLibs,
 		optCommands = append(optCommands, "-v name "+*a.properties.Apex_name)
 	}
 
+	// APEX version can be overridden, e.g. to test updating an APEX with the same payload
+	if v := a.overridableProperties.Manifest_version_override; v != nil {
+		if *v <= 0 {
+			ctx.PropertyErrorf("manifest_version_override", "must be a positive number, got %d", *v)
+		}
+		// The version is a number in apex_manifest.json, so it must not be set as a string.
+		optCommands = append(optCommands, "-i version "+strconv.FormatInt(*v, 10))
+	}
+
 	// Collect jniLibs. Notice that a.filesInfo is already sorted
 	var jniLibs []string
 	for _, fi := range a.filesInfo {
@@ -548,7 +569,7 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 	prebuiltSdkToolsBinDir := filepath.Join("prebuilts", "sdk", "tools", runtime.GOOS, "bin")
 
 	// Figure out if we need to compress the apex.
-	compressionEnabled := ctx.Config().CompressedApex() && proptools.BoolDefault(a.overridableProperties.Compressible, false) && !a.testApex && !ctx.Config().UnbundledBuildApps()
+	compressionEnabled := a.isCompressible(ctx) && !a.testApex && !ctx.Config().UnbundledBuildApps()
 	if apexType == imageApex {
 
 		////////////////////////////////////////////////////////////////////////////////////
@@ -557,6 +578,10 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 		cannedFsConfig := a.buildCannedFsConfig(ctx)
 		implicitInputs = append(implicitInputs, cannedFsConfig)
 
+		// The payload file list is built from the same inputs so that release tooling can audit
+		// the content of the APEX without having to unpack it.
+		a.payloadFileList = a.buildPayloadFileList(ctx)
+
 		////////////////////////////////////////////////////////////////////////////////////
 		// Step 3: Prepare option flags for apexer and invoke it to create an unsigned APEX.
 		// TODO(jiyong): use the RuleBuilder
@@ -568,6 +593,14 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 		implicitInputs = append(implicitInputs, a.privateKeyFile, a.publicKeyFile)
 		optFlags = append(optFlags, "--pubkey "+a.publicKeyFile.String())
 
+		// Let avbtool sign the payload with the external signer of the key instead of the
+		// private key.
+		if a.externalSigner != nil {
+			implicitInputs = append(implicitInputs, a.externalSigner)
+			implicitInputs = append(implicitInputs, a.externalSignerDeps...)
+			optFlags = append(optFlags, "--signing_args '--signing_helper "+a.externalSigner.String()+"'")
+		}
+
 		manifestPackageName := a.getOverrideManifestPackageName(ctx)
 		if manifestPackageName != "" {
 			optFlags = append(optFlags, "--override_apk_package_name "+manifestPackageName)
@@ -775,25 +808,41 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 	signedOutputFile := android.PathForModuleOut(ctx, a.Name()+suffix)
 
 	pem, key := a.getCertificateAndPrivateKey(ctx)
-	rule := java.Signapk
-	args := map[string]string{
-		"certificates": pem.String() + " " + key.String(),
-		"flags":        "-a 4096 --align-file-size", //alignment
-	}
-	implicits := android.Paths{pem, key}
-	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_SIGNAPK") {
-		rule = java.SignapkRE
-		args["implicits"] = strings.Join(implicits.Strings(), ",")
-		args["outCommaList"] = signedOutputFile.String()
+	if a.externalSigner != nil {
+		// The external signer of the apex_key signs the container too, so that no local private key
+		// is used to sign the APEX.
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        externalSignContainer,
+			Description: "sign container",
+			Output:      signedOutputFile,
+			Input:       unsignedOutputFile,
+			Implicits:   append(android.Paths{pem, a.externalSigner}, a.externalSignerDeps...),
+			Args: map[string]string{
+				"signer":      a.externalSigner.String(),
+				"certificate": pem.String(),
+			},
+		})
+	} else {
+		rule := java.Signapk
+		args := map[string]string{
+			"certificates": pem.String() + " " + key.String(),
+			"flags":        "-a 4096 --align-file-size", //alignment
+		}
+		implicits := android.Paths{pem, key}
+		if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_SIGNAPK") {
+			rule = java.SignapkRE
+			args["implicits"] = strings.Join(implicits.Strings(), ",")
+			args["outCommaList"] = signedOutputFile.String()
+		}
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        rule,
+			Description: "signapk",
+			Output:      signedOutputFile,
+			Input:       unsignedOutputFile,
+			Implicits:   implicits,
+			Args:        args,
+		})
 	}
-	ctx.Build(pctx, android.BuildParams{
-		Rule:        rule,
-		Description: "signapk",
-		Output:      signedOutputFile,
-		Input:       unsignedOutputFile,
-		Implicits:   implicits,
-		Args:        args,
-	})
 	if suffix == imageApexSuffix {
 		a.outputApexFile = signedOutputFile
 	}
@@ -1071,6 +1120,15 @@ func (a *apexBundle) buildCannedFsConfig(ctx android.ModuleContext) android.Outp
 		file := appSetFiles[dir]
 		cmd.Text("zipinfo -1").Input(file).Textf(`| sed "s:\(.*\):/%s/\1 1000 1000 0644:";`, dir)
 	}
+	// Entries from the fs_config property are appended after the default ones so that they are
+	// preferred, and warnings about conflicting entries are shown when the rule runs.
+	fsConfigLines, fsConfigWarnings := a.fsConfigOverrides(ctx, readOnlyPaths, executablePaths)
+	for _, line := range fsConfigLines {
+		cmd.Textf("echo '%s';", line)
+	}
+	for _, warning := range fsConfigWarnings {
+		cmd.Textf("echo 'warning: %s: %s' >&2;", a.BaseModuleName(), warning)
+	}
 	// Custom fs_config is "appended" to the last so that entries from the file are preferred
 	// over default ones set above.
 	if a.properties.Canned_fs_config != nil {
@@ -1081,3 +1139,191 @@ func (a *apexBundle) buildCannedFsConfig(ctx android.ModuleContext) android.Outp
 
 	return cannedFsConfig.OutputPath
 }
+
+// buildPayloadFileList creates a rule to generate the list of the files in the payload of the APEX
+// with one "<path> <size> <sha256>" line per file, sorted by path.
+func (a *apexBundle) buildPayloadFileList(ctx android.ModuleContext) android.OutputPath {
+	files := map[string]android.Path{
+		"apex_manifest.pb": a.manifestPbOut,
+	}
+	if a.manifestJsonOut != nil {
+		files["apex_manifest.json"] = a.manifestJsonOut
+	}
+	for _, f := range a.filesInfo {
+		if f.class == appSet {
+			// The content of an app set is only known once its zip is extracted by apexer.
+			continue
+		}
+		files[f.path()] = f.builtFile
+		for _, d := range f.dataPaths {
+			files[filepath.Join(f.installDir, d.RelativeInstallPath, d.SrcPath.Rel())] = d.SrcPath
+		}
+	}
+
+	output := android.PathForModuleOut(ctx, "payload-filelist.txt")
+	builder := android.NewRuleBuilder(pctx, ctx)
+	cmd := builder.Command().Text("(")
+	for _, pathInApex := range android.SortedStringKeys(files) {
+		file := files[pathInApex]
+		cmd.Textf("printf '%%s %%s %%s\\n' %s", proptools.ShellEscape(pathInApex)).
+			Text("$(stat -L -c %s").Input(file).Text(")").
+			Text("$(sha256sum").Input(file).Text("| cut -d' ' -f1);")
+	}
+	cmd.Text(")").FlagWithOutput("> ", output)
+	builder.Build("generatePayloadFileList", fmt.Sprintf("Generating payload file list for %s", a.BaseModuleName()))
+
+	return output.OutputPath
+}
+
+// linuxCapabilities maps the names of the Linux capabilities, without the CAP_ prefix, to their
+// numbers as defined in linux/capability.h.
+var linuxCapabilities = map[string]uint{
+	"CHOWN":              0,
+	"DAC_OVERRIDE":       1,
+	"DAC_READ_SEARCH":    2,
+	"FOWNER":             3,
+	"FSETID":             4,
+	"KILL":               5,
+	"SETGID":             6,
+	"SETUID":             7,
+	"SETPCAP":            8,
+	"LINUX_IMMUTABLE":    9,
+	"NET_BIND_SERVICE":   10,
+	"NET_BROADCAST":      11,
+	"NET_ADMIN":          12,
+	"NET_RAW":            13,
+	"IPC_LOCK":           14,
+	"IPC_OWNER":          15,
+	"SYS_MODULE":         16,
+	"SYS_RAWIO":          17,
+	"SYS_CHROOT":         18,
+	"SYS_PTRACE":         19,
+	"SYS_PACCT":          20,
+	"SYS_ADMIN":          21,
+	"SYS_BOOT":           22,
+	"SYS_NICE":           23,
+	"SYS_RESOURCE":       24,
+	"SYS_TIME":           25,
+	"SYS_TTY_CONFIG":     26,
+	"MKNOD":              27,
+	"LEASE":              28,
+	"AUDIT_WRITE":        29,
+	"AUDIT_CONTROL":      30,
+	"SETFCAP":            31,
+	"MAC_OVERRIDE":       32,
+	"MAC_ADMIN":          33,
+	"SYSLOG":             34,
+	"WAKE_ALARM":         35,
+	"BLOCK_SUSPEND":      36,
+	"AUDIT_READ":         37,
+	"PERFMON":            38,
+	"BPF":                39,
+	"CHECKPOINT_RESTORE": 40,
+}
+
+// fsConfigEntry is the owner, mode and capabilities of a file in canned_fs_config.
+type fsConfigEntry struct {
+	uid, gid     string
+	mode         string
+	capabilities uint64
+}
+
+func (e fsConfigEntry) line(path string) string {
+	line := fmt.Sprintf("/%s %s %s %s", path, e.uid, e.gid, e.mode)
+	if e.capabilities != 0 {
+		line += fmt.Sprintf(" capabilities=0x%x", e.capabilities)
+	}
+	return line
+}
+
+// fsConfigOverrides applies the entries of the fs_config property to the files in the APEX bundle
+// and returns the resulting canned_fs_config lines for the files they match, along with warnings
+// about entries that override the mode or capabilities set by an earlier entry.
+func (a *apexBundle) fsConfigOverrides(ctx android.ModuleContext, readOnlyPaths, executablePaths []string) (lines []string, warnings []string) {
+	if len(a.properties.Fs_config) == 0 {
+		return nil, nil
+	}
+
+	defaults := make(map[string]fsConfigEntry)
+	for _, p := range readOnlyPaths {
+		defaults[p] = fsConfigEntry{uid: "1000", gid: "1000", mode: "0644"}
+	}
+	for _, p := range executablePaths {
+		defaults[p] = fsConfigEntry{uid: "0", gid: "2000", mode: "0755"}
+	}
+	paths := android.SortedStringKeys(defaults)
+
+	overrides := make(map[string]fsConfigEntry)
+	modeSetBy := make(map[string]string)
+	capabilitiesSetBy := make(map[string]string)
+	for _, config := range a.properties.Fs_config {
+		pattern := strings.TrimPrefix(proptools.String(config.Pattern), "/")
+		if pattern == "" {
+			ctx.PropertyErrorf("fs_config", "pattern must be set")
+			continue
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			ctx.PropertyErrorf("fs_config", "invalid pattern %q: %s", pattern, err)
+			continue
+		}
+
+		mode := proptools.String(config.Mode)
+		if mode != "" {
+			if _, err := strconv.ParseUint(mode, 8, 32); err != nil {
+				ctx.PropertyErrorf("fs_config", "invalid mode %q for %q, must be an octal number", mode, pattern)
+				continue
+			}
+		}
+
+		var capabilities uint64
+		validCapabilities := true
+		for _, name := range config.Capabilities {
+			bit, ok := linuxCapabilities[strings.TrimPrefix(name, "CAP_")]
+			if !ok {
+				ctx.PropertyErrorf("fs_config", "unknown capability %q for %q", name, pattern)
+				validCapabilities = false
+				continue
+			}
+			capabilities |= 1 << bit
+		}
+		if !validCapabilities {
+			continue
+		}
+
+		matched := false
+		for _, p := range paths {
+			if ok, _ := filepath.Match(pattern, p); !ok {
+				continue
+			}
+			matched = true
+
+			entry, ok := overrides[p]
+			if !ok {
+				entry = defaults[p]
+			}
+			if mode != "" {
+				if prev, ok := modeSetBy[p]; ok && entry.mode != mode {
+					warnings = append(warnings, fmt.Sprintf("fs_config %q overrides the mode of /%s set by %q", pattern, p, prev))
+				}
+				entry.mode = mode
+				modeSetBy[p] = pattern
+			}
+			if len(config.Capabilities) > 0 {
+				if prev, ok := capabilitiesSetBy[p]; ok && entry.capabilities != capabilities {
+					warnings = append(warnings, fmt.Sprintf("fs_config %q overrides the capabilities of /%s set by %q", pattern, p, prev))
+				}
+				entry.capabilities = capabilities
+				capabilitiesSetBy[p] = pattern
+			}
+			overrides[p] = entry
+		}
+		if !matched {
+			ctx.PropertyErrorf("fs_config", "pattern %q does not match any file in the APEX", pattern)
+		}
+	}
+
+	for _, p := range android.SortedStringKeys(overrides) {
+		lines = append(lines, overrides[p].line(p))
+	}
+	return lines, warnings
+}
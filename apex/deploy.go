@@ -0,0 +1,87 @@
+// Copyright (C) 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apex
+
+import (
+	"sort"
+	"strings"
+
+	"android/soong/android"
+)
+
+func apexDeploySingletonFactory() android.Singleton {
+	return &apexDeploySingleton{}
+}
+
+type apexDeploySingleton struct {
+	// The dependencies of the <name>-deploy phony target of each APEX, keyed by name.
+	deployTargets map[string]android.Paths
+}
+
+// GenerateBuildActions creates a <name>-deploy phony target for each APEX that builds the APEX
+// and out/soong/apex/deploy/<name>-deploy.sh, a script to be run with bash that installs the APEX
+// on a device with adb.  APEXes listed in deploy_with are installed in the same multi-package install.  The
+// script installs the file that is installed on the device, i.e. the compressed APEX when
+// compression is enabled.
+func (s *apexDeploySingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	apexes := make(map[string]*apexBundle)
+	ctx.VisitAllModules(func(module android.Module) {
+		if a, ok := module.(*apexBundle); ok && a.Enabled() && a.properties.ApexType == imageApex &&
+			a.outputFile != nil {
+			apexes[a.Name()] = a
+		}
+	})
+
+	names := make([]string, 0, len(apexes))
+	for name := range apexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s.deployTargets = make(map[string]android.Paths)
+	for _, name := range names {
+		a := apexes[name]
+		files := android.Paths{a.outputFile}
+		for _, other := range a.properties.Deploy_with {
+			if o, ok := apexes[other]; ok {
+				files = append(files, o.outputFile)
+			} else {
+				ctx.ModuleErrorf(a, "deploy_with: %q is not an APEX", other)
+			}
+		}
+
+		script := android.PathForOutput(ctx, "apex", "deploy", name+"-deploy.sh")
+		android.WriteFileRule(ctx, script, apexDeployScript(name, files))
+		s.deployTargets[name] = append(android.Paths{script}, files...)
+		ctx.Phony(name+"-deploy", s.deployTargets[name]...)
+	}
+}
+
+// apexDeployScript returns a script that installs the given APEX files with adb.  Multiple APEXes
+// are installed with install-multi-package so that they are staged together.
+func apexDeployScript(name string, files android.Paths) string {
+	installCmd := "install"
+	if len(files) > 1 {
+		installCmd = "install-multi-package"
+	}
+	return strings.Join([]string{
+		"#!/bin/bash",
+		"# Installs " + name + " on the device selected by ANDROID_SERIAL. Run from the root of",
+		"# the source tree after `m " + name + "-deploy`. Extra arguments are passed to adb.",
+		"set -e",
+		"adb " + installCmd + " \"$@\" " + strings.Join(files.Strings(), " "),
+		"echo \"Reboot the device to activate the staged APEXes: adb reboot\"",
+	}, "\n")
+}
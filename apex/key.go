@@ -22,6 +22,7 @@ import (
 	"android/soong/android"
 	"android/soong/bazel"
 
+	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
 )
 
@@ -45,6 +46,11 @@ type apexKey struct {
 	publicKeyFile  android.Path
 	privateKeyFile android.Path
 
+	// The script that avbtool calls to sign the payload with the external signer, and the files it
+	// runs, if external_signer is set.
+	externalSigner     android.Path
+	externalSignerDeps android.Paths
+
 	keyName string
 }
 
@@ -52,9 +58,25 @@ type apexKeyProperties struct {
 	// Path or module to the public key file in avbpubkey format. Installed to the device.
 	// Base name of the file is used as the ID for the key.
 	Public_key *string `android:"path"`
-	// Path or module to the private key file in pem format. Used to sign APEXs.
+	// Path or module to the private key file in pem format. Used to sign APEXs. When
+	// external_signer is set, this is the public key in pem format instead, which avbtool passes
+	// to the external signer to identify the key to sign with.
 	Private_key *string `android:"path"`
 
+	// Name of a host tool module that signs APEXs instead of avbtool and signapk signing them with
+	// local private keys, e.g. with keys that are kept in a key management service.  It is
+	// called by avbtool as a signing helper for the payload, with the external_signer_args
+	// followed by the algorithm and the path of the public key, with the data to sign on stdin, and
+	// must write the signature to stdout.  The public_key is still embedded in the APEX to verify
+	// it.  It is also called to sign the zip container of the APEX, with the external_signer_args
+	// followed by --sign_container, the certificate of the container in x509.pem format, and the
+	// paths of the unsigned and the signed APEX, and must align and sign the container like
+	// "signapk -a 4096 --align-file-size" does.
+	External_signer *string
+
+	// Arguments passed to the external_signer before the ones passed by avbtool.
+	External_signer_args []string
+
 	// Whether this key is installable to one of the partitions. Defualt: true.
 	Installable *bool
 }
@@ -71,6 +93,19 @@ func (m *apexKey) installable() bool {
 	return false
 }
 
+type externalSignerDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+var externalSignerTag = externalSignerDependencyTag{}
+
+func (m *apexKey) DepsMutator(ctx android.BottomUpMutatorContext) {
+	if m.properties.External_signer != nil {
+		ctx.AddFarVariationDependencies(ctx.Config().BuildOSTarget.Variations(), externalSignerTag,
+			String(m.properties.External_signer))
+	}
+}
+
 func (m *apexKey) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	// If the keys are from other modules (i.e. :module syntax) respect it.
 	// Otherwise, try to locate the key files in the default cert dir or
@@ -103,6 +138,47 @@ func (m *apexKey) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		return
 	}
 	m.keyName = pubKeyName
+
+	if m.properties.External_signer != nil {
+		m.buildExternalSigner(ctx)
+	}
+}
+
+// buildExternalSigner writes the script that avbtool calls as its signing helper, and that signs
+// the container, which runs the external_signer with the external_signer_args.
+func (m *apexKey) buildExternalSigner(ctx android.ModuleContext) {
+	var tool android.Path
+	var toolDeps android.Paths
+	ctx.VisitDirectDepsWithTag(externalSignerTag, func(dep android.Module) {
+		provider, ok := dep.(android.HostToolProvider)
+		if !ok || !provider.HostToolPath().Valid() {
+			ctx.PropertyErrorf("external_signer", "%q is not a host tool module",
+				ctx.OtherModuleName(dep))
+			return
+		}
+		tool = provider.HostToolPath().Path()
+		// The tool runs from where it is installed, along with the shared libraries and other files
+		// that its dependencies install for it.
+		toolDeps = dep.TransitiveInstallFiles().Paths()
+	})
+	if tool == nil {
+		return
+	}
+
+	signer := android.PathForModuleOut(ctx, ctx.ModuleName()+"_signer.sh")
+	command := append([]string{"exec", proptools.ShellEscape(tool.String())},
+		proptools.ShellEscapeList(m.properties.External_signer_args)...)
+	command = append(command, `"$@"`)
+	builder := android.NewRuleBuilder(pctx, ctx)
+	builder.Command().Text("printf '%s\\n'").
+		Text(proptools.ShellEscape("#!/bin/sh")).
+		Text(proptools.ShellEscape(strings.Join(command, " "))).
+		Text(">").Output(signer)
+	builder.Command().Text("chmod a+x").Text(signer.String())
+	builder.Build("external_signer", "external signer "+ctx.ModuleName())
+
+	m.externalSigner = signer
+	m.externalSignerDeps = android.FirstUniquePaths(append(android.Paths{tool}, toolDeps...))
 }
 
 ////////////////////////////////////////////////////////////////////////
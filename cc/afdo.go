@@ -46,8 +46,19 @@ func recordMissingAfdoProfileFile(ctx android.BaseModuleContext, missing string)
 
 type AfdoProperties struct {
 	// Afdo allows developers self-service enroll for
-	// automatic feedback-directed optimization using profile data.
-	Afdo bool
+	// automatic feedback-directed optimization using profile data. The profile of each arch
+	// variant is looked up as <module>_<arch>.afdo first, falling back to <module>.afdo.
+	Afdo *bool `android:"arch_variant"`
+
+	// The maximum depth of the static dependencies that are rebuilt with the profile of this
+	// module when afdo is enabled, the direct static dependencies being at depth 1. Defaults to
+	// rebuilding all the static dependencies.
+	Afdo_max_static_dep_depth *int64
+
+	// Static dependencies that are not rebuilt with the profile of this module when afdo is
+	// enabled. Their own static dependencies are only rebuilt with the profile when they are
+	// also reached through other static dependencies.
+	Afdo_exclude_static_deps []string
 
 	AfdoTarget *string  `blueprint:"mutated"`
 	AfdoDeps   []string `blueprint:"mutated"`
@@ -62,7 +73,7 @@ func (afdo *afdo) props() []interface{} {
 }
 
 func (afdo *afdo) AfdoEnabled() bool {
-	return afdo != nil && afdo.Properties.Afdo && afdo.Properties.AfdoTarget != nil
+	return afdo != nil && Bool(afdo.Properties.Afdo) && afdo.Properties.AfdoTarget != nil
 }
 
 // Get list of profile file names, ordered by level of specialisation. For example:
@@ -101,7 +112,7 @@ func (afdo *afdo) begin(ctx BaseModuleContext) {
 	if ctx.static() && !ctx.staticBinary() {
 		return
 	}
-	if afdo.Properties.Afdo {
+	if Bool(afdo.Properties.Afdo) {
 		module := ctx.ModuleName()
 		if afdo.Properties.GetAfdoProfileFile(ctx, module).Valid() {
 			afdo.Properties.AfdoTarget = proptools.StringPtr(module)
@@ -133,6 +144,19 @@ func (afdo *afdo) flags(ctx ModuleContext, flags Flags) Flags {
 func afdoDepsMutator(mctx android.TopDownMutatorContext) {
 	if m, ok := mctx.Module().(*Module); ok && m.afdo.AfdoEnabled() {
 		afdoTarget := *m.afdo.Properties.AfdoTarget
+		maxDepth := -1
+		if m.afdo.Properties.Afdo_max_static_dep_depth != nil {
+			maxDepth = int(*m.afdo.Properties.Afdo_max_static_dep_depth)
+			if maxDepth < 0 {
+				mctx.PropertyErrorf("afdo_max_static_dep_depth", "must not be negative, got %d", maxDepth)
+				return
+			}
+		}
+		excludes := m.afdo.Properties.Afdo_exclude_static_deps
+
+		// depths holds the depth of each module on the path that is currently being walked, as
+		// WalkDeps visits a module once for every path to it.
+		depths := map[android.Module]int{mctx.Module(): 0}
 		mctx.WalkDeps(func(dep android.Module, parent android.Module) bool {
 			tag := mctx.OtherModuleDependencyTag(dep)
 			libTag, isLibTag := tag.(libraryDependencyTag)
@@ -148,6 +172,20 @@ func afdoDepsMutator(mctx android.TopDownMutatorContext) {
 				}
 			}
 
+			// The objects reused from the static variant of the same module are not a level of
+			// static dependencies.
+			depth := depths[parent]
+			if tag != reuseObjTag {
+				depth++
+			}
+			if maxDepth >= 0 && depth > maxDepth {
+				return false
+			}
+			if android.InList(mctx.OtherModuleName(dep), excludes) {
+				return false
+			}
+			depths[dep] = depth
+
 			if dep, ok := dep.(*Module); ok {
 				dep.afdo.Properties.AfdoDeps = append(dep.afdo.Properties.AfdoDeps, afdoTarget)
 			}
@@ -171,6 +209,11 @@ func afdoMutator(mctx android.BottomUpMutatorContext) {
 			variationNames = append(variationNames, encodeTarget(dep))
 		}
 		if len(variationNames) > 1 {
+			// Static dependencies that are excluded or beyond the maximum depth of the afdo target
+			// have no variation for it. Link the afdo variations against their default variation.
+			defaultVariation := ""
+			mctx.SetDefaultDependencyVariation(&defaultVariation)
+
 			modules := mctx.CreateVariations(variationNames...)
 			for i, name := range variationNames {
 				if name == "" {
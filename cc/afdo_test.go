@@ -50,21 +50,178 @@ func TestAfdoDeps(t *testing.T) {
 	libFoo := result.ModuleForTests("libFoo", "android_arm64_armv8-a_static_afdo-libTest").Module()
 	libBar := result.ModuleForTests("libBar", "android_arm64_armv8-a_static_afdo-libTest").Module()
 
-	hasDep := func(m android.Module, wantDep android.Module) bool {
-		var found bool
-		result.VisitDirectDeps(m, func(dep blueprint.Module) {
-			if dep == wantDep {
-				found = true
-			}
-		})
-		return found
+	if !hasDirectDep(result, libTest, libFoo) {
+		t.Errorf("libTest missing dependency on afdo variant of libFoo")
+	}
+
+	if !hasDirectDep(result, libFoo, libBar) {
+		t.Errorf("libTest missing dependency on afdo variant of libBar")
+	}
+}
+
+func TestAfdoDepsMaxStaticDepDepth(t *testing.T) {
+	bp := `
+	cc_library {
+		name: "libTest",
+		srcs: ["foo.c"],
+		static_libs: ["libFoo"],
+		afdo: true,
+		afdo_max_static_dep_depth: 1,
 	}
 
-	if !hasDep(libTest, libFoo) {
+	cc_library {
+		name: "libFoo",
+		static_libs: ["libBar"],
+	}
+
+	cc_library {
+		name: "libBar",
+	}
+	`
+	prepareForAfdoTest := android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/libTest.afdo", "TEST")
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		prepareForAfdoTest,
+	).RunTestWithBp(t, bp)
+
+	libTest := result.ModuleForTests("libTest", "android_arm64_armv8-a_shared").Module()
+	libFoo := result.ModuleForTests("libFoo", "android_arm64_armv8-a_static_afdo-libTest").Module()
+	libBar := result.ModuleForTests("libBar", "android_arm64_armv8-a_static").Module()
+
+	if !hasDirectDep(result, libTest, libFoo) {
 		t.Errorf("libTest missing dependency on afdo variant of libFoo")
 	}
 
-	if !hasDep(libFoo, libBar) {
-		t.Errorf("libTest missing dependency on afdo variant of libBar")
+	if !hasDirectDep(result, libFoo, libBar) {
+		t.Errorf("afdo variant of libFoo missing dependency on non-afdo variant of libBar")
+	}
+
+	variants := result.ModuleVariantsForTests("libBar")
+	android.AssertStringListDoesNotContain(t, "libBar variants", variants,
+		"android_arm64_armv8-a_static_afdo-libTest")
+}
+
+func TestAfdoDepsExcludeStaticDeps(t *testing.T) {
+	bp := `
+	cc_library {
+		name: "libTest",
+		srcs: ["foo.c"],
+		static_libs: ["libFoo"],
+		afdo: true,
+		afdo_exclude_static_deps: ["libBar"],
+	}
+
+	cc_library {
+		name: "libOther",
+		srcs: ["foo.c"],
+		static_libs: ["libBar"],
+		afdo: true,
+	}
+
+	cc_library {
+		name: "libFoo",
+		static_libs: ["libBar"],
+	}
+
+	cc_library {
+		name: "libBar",
+		static_libs: ["libBaz"],
+	}
+
+	cc_library {
+		name: "libBaz",
+	}
+	`
+	prepareForAfdoTest := android.GroupFixturePreparers(
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/libTest.afdo", "TEST"),
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/libOther.afdo", "TEST"),
+	)
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		prepareForAfdoTest,
+	).RunTestWithBp(t, bp)
+
+	libFoo := result.ModuleForTests("libFoo", "android_arm64_armv8-a_static_afdo-libTest").Module()
+	libBar := result.ModuleForTests("libBar", "android_arm64_armv8-a_static").Module()
+
+	// libBar has an afdo variant for libOther, but not for libTest that excludes it, so the afdo
+	// variant of libFoo uses the non-afdo variant.
+	if !hasDirectDep(result, libFoo, libBar) {
+		t.Errorf("afdo variant of libFoo missing dependency on non-afdo variant of libBar")
 	}
+
+	android.AssertStringListDoesNotContain(t, "libBar variants", result.ModuleVariantsForTests("libBar"),
+		"android_arm64_armv8-a_static_afdo-libTest")
+	android.AssertStringListContains(t, "libBar variants", result.ModuleVariantsForTests("libBar"),
+		"android_arm64_armv8-a_static_afdo-libOther")
+
+	// The static dependencies of libBar are not rebuilt with the profile of libTest either.
+	android.AssertStringListDoesNotContain(t, "libBaz variants", result.ModuleVariantsForTests("libBaz"),
+		"android_arm64_armv8-a_static_afdo-libTest")
+}
+
+func TestAfdoProfilePerArch(t *testing.T) {
+	bp := `
+	cc_library_shared {
+		name: "libTest",
+		srcs: ["foo.c"],
+		afdo: true,
+	}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/libTest_arm64.afdo", "TEST"),
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/libTest.afdo", "TEST"),
+	).RunTestWithBp(t, bp)
+
+	checkProfile := func(variant, expected string) {
+		t.Helper()
+		cFlags := result.ModuleForTests("libTest", variant).Rule("cc").Args["cFlags"]
+		android.AssertStringDoesContain(t, variant+" cFlags", cFlags, "-fprofile-sample-use="+expected)
+		ldFlags := result.ModuleForTests("libTest", variant).Rule("ld").Args["ldFlags"]
+		android.AssertStringDoesContain(t, variant+" ldFlags", ldFlags, "-fprofile-sample-use="+expected)
+	}
+
+	// The arm64 variant uses its arch specific profile, while the arm variant that has none falls
+	// back to the generic profile.
+	checkProfile("android_arm64_armv8-a_shared", "toolchain/pgo-profiles/sampling/libTest_arm64.afdo")
+	checkProfile("android_arm_armv7-a-neon_shared", "toolchain/pgo-profiles/sampling/libTest.afdo")
+}
+
+func TestAfdoDisabledPerArch(t *testing.T) {
+	bp := `
+	cc_library_shared {
+		name: "libTest",
+		srcs: ["foo.c"],
+		afdo: true,
+		arch: {
+			arm64: {
+				afdo: false,
+			},
+		},
+	}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/libTest.afdo", "TEST"),
+	).RunTestWithBp(t, bp)
+
+	arm64CFlags := result.ModuleForTests("libTest", "android_arm64_armv8-a_shared").Rule("cc").Args["cFlags"]
+	android.AssertStringDoesNotContain(t, "arm64 cFlags", arm64CFlags, "-fprofile-sample-use")
+
+	armCFlags := result.ModuleForTests("libTest", "android_arm_armv7-a-neon_shared").Rule("cc").Args["cFlags"]
+	android.AssertStringDoesContain(t, "arm cFlags", armCFlags,
+		"-fprofile-sample-use=toolchain/pgo-profiles/sampling/libTest.afdo")
+}
+
+func hasDirectDep(result *android.TestResult, m android.Module, wantDep android.Module) bool {
+	var found bool
+	result.VisitDirectDeps(m, func(dep blueprint.Module) {
+		if dep == wantDep {
+			found = true
+		}
+	})
+	return found
 }
@@ -0,0 +1,89 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"android/soong/android"
+)
+
+type AnalyzerProperties struct {
+	// whether to run the clang static analyzer over C-like sources. The analyzer can also be
+	// enabled for all modules by setting the WITH_STATIC_ANALYZER environment variable. The
+	// reports are written to out/soong/analysis and are built by the analyze-<module> target.
+	Static_analyzer *bool
+}
+
+type analyzerFeature struct {
+	Properties AnalyzerProperties
+}
+
+func (analyzer *analyzerFeature) props() []interface{} {
+	return []interface{}{&analyzer.Properties}
+}
+
+func (analyzer *analyzerFeature) flags(ctx ModuleContext, flags Flags) Flags {
+	// Check if the analyzer is explicitly disabled for this module
+	if analyzer.Properties.Static_analyzer != nil && !*analyzer.Properties.Static_analyzer {
+		return flags
+	}
+
+	if ctx.Config().IsEnvTrue("WITH_STATIC_ANALYZER") || Bool(analyzer.Properties.Static_analyzer) {
+		flags.StaticAnalyzer = true
+	}
+	return flags
+}
+
+// analyzerPathForSrc returns the path of the static analyzer report for srcFile, which mirrors
+// the path of its object file with out/soong/.intermediates replaced by out/soong/analysis.
+func analyzerPathForSrc(ctx android.ModuleContext, subdir string, srcFile android.Path, ext string) android.OutputPath {
+	objFile := android.ObjPathWithExt(ctx, subdir, srcFile, ext)
+	return android.PathForOutput(ctx, "analysis", ctx.ModuleDir(), ctx.ModuleName(), ctx.ModuleSubDir(),
+		objFile.Rel())
+}
+
+func init() {
+	android.RegisterSingletonType("static_analyzer_phony_targets", StaticAnalyzerPhonySingleton)
+}
+
+// StaticAnalyzerPhonySingleton generates an analyze-<module> phony target for every C/C++ module
+// that runs the clang static analyzer, which builds the reports of all variants of the module.
+func StaticAnalyzerPhonySingleton() android.Singleton {
+	return &staticAnalyzerPhonySingleton{}
+}
+
+type staticAnalyzerPhonySingleton struct{}
+
+func (staticAnalyzerPhonySingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	ctx.VisitAllModules(func(module android.Module) {
+		if module != ctx.FinalModule(module) {
+			return
+		}
+		var analyzerFiles android.Paths
+		ctx.VisitAllModuleVariants(module, func(variant android.Module) {
+			if m, ok := variant.(*Module); ok {
+				analyzerFiles = append(analyzerFiles, m.analyzerFiles...)
+			}
+		})
+		if len(analyzerFiles) > 0 {
+			ctx.Phony(staticAnalyzerPhonyName(module), analyzerFiles...)
+		}
+	})
+}
+
+// staticAnalyzerPhonyName returns the name of the phony target that builds the static analyzer
+// reports of the module.
+func staticAnalyzerPhonyName(module android.Module) string {
+	return "analyze-" + module.Name()
+}
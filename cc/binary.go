@@ -15,6 +15,7 @@
 package cc
 
 import (
+	"fmt"
 	"path/filepath"
 
 	"github.com/google/blueprint"
@@ -37,7 +38,10 @@ type BinaryLinkerProperties struct {
 	// if set, add an extra objcopy --prefix-symbols= step
 	Prefix_symbols *string
 
-	// if set, install a symlink to the preferred architecture
+	// if set, install a symlink to the preferred architecture.  The symlink is named after the stem
+	// without the suffix, so suffix must also be set, e.g. with compile_multilib: "both" and
+	// multilib: { lib32: { suffix: "32" }, lib64: { suffix: "64" } } the binaries are installed as
+	// tool32 and tool64, and tool is a symlink to tool64 on a 64-bit device.
 	Symlink_preferred_arch *bool `android:"arch_variant"`
 
 	// install symlinks to the binary.  Symlink names will have the suffix and the binary
@@ -56,6 +60,16 @@ type BinaryLinkerProperties struct {
 
 	// Inject boringssl hash into the shared library.  This is only intended for use by external/boringssl.
 	Inject_bssl_hash *bool `android:"arch_variant"`
+
+	// Properties that only apply when building the binary for Windows, they are ignored for other
+	// targets.
+	Windows struct {
+		// list of resource scripts (.rc) compiled with llvm-rc and linked into the binary.
+		Resources []string `android:"path"`
+
+		// application manifest embedded into the binary.
+		Manifest *string `android:"path"`
+	}
 }
 
 func init() {
@@ -357,6 +371,13 @@ func (binary *binaryDecorator) link(ctx ModuleContext,
 		transformDarwinUniversalBinary(ctx, fatOutputFile, outputFile, deps.DarwinSecondArchOutput.Path())
 	}
 
+	var implicitOutputs android.WritablePaths
+	if mapFile := binary.addMapFile(ctx, &flags, fileName); mapFile != nil {
+		implicitOutputs = append(implicitOutputs, mapFile)
+	}
+	binary.addGcSectionsReport(ctx, &flags)
+	binary.addLinkDiagnosis(ctx, &flags)
+
 	builderFlags := flagsToBuilderFlags(flags)
 	stripFlags := flagsToStripFlags(flags)
 	if binary.stripper.NeedsStrip(ctx) {
@@ -430,10 +451,17 @@ func (binary *binaryDecorator) link(ctx ModuleContext,
 	validations = append(validations, objs.tidyDepFiles...)
 	linkerDeps = append(linkerDeps, flags.LdFlagsDeps...)
 
+	objFiles := objs.objFiles
+	if ctx.Windows() {
+		objFiles = append(android.Paths(nil), objFiles...)
+		objFiles = append(objFiles, binary.windowsResources(ctx)...)
+	}
+
 	// Register link action.
-	transformObjToDynamicBinary(ctx, objs.objFiles, sharedLibs, deps.StaticLibs,
+	binary.setGcSectionsReportInputs(objFiles, deps)
+	transformObjToDynamicBinary(ctx, objFiles, sharedLibs, deps.StaticLibs,
 		deps.LateStaticLibs, deps.WholeStaticLibs, linkerDeps, deps.CrtBegin, deps.CrtEnd, true,
-		builderFlags, outputFile, nil, validations)
+		builderFlags, outputFile, implicitOutputs, validations)
 
 	objs.coverageFiles = append(objs.coverageFiles, deps.StaticLibObjs.coverageFiles...)
 	objs.coverageFiles = append(objs.coverageFiles, deps.WholeStaticLibObjs.coverageFiles...)
@@ -446,6 +474,29 @@ func (binary *binaryDecorator) link(ctx ModuleContext,
 	return ret
 }
 
+// windowsResources compiles the resource scripts and the application manifest of a Windows binary
+// into resource files, which are linked into the binary like objects.
+func (binary *binaryDecorator) windowsResources(ctx ModuleContext) android.Paths {
+	var resFiles android.Paths
+	for _, rcFile := range android.PathsForModuleSrc(ctx, binary.Properties.Windows.Resources) {
+		resFile := android.ObjPathWithExt(ctx, "windows_resources", rcFile, "res")
+		transformWindowsRcToRes(ctx, rcFile, resFile, nil)
+		resFiles = append(resFiles, resFile)
+	}
+
+	if manifest := android.OptionalPathForModuleSrc(ctx, binary.Properties.Windows.Manifest); manifest.Valid() {
+		// The manifest is embedded as the resource with ID CREATEPROCESS_MANIFEST_RESOURCE_ID (1) and
+		// type RT_MANIFEST (24), which Windows reads when it creates the process.
+		rcFile := android.PathForModuleGen(ctx, "windows_resources", "manifest.rc")
+		android.WriteFileRule(ctx, rcFile, fmt.Sprintf("1 24 \"%s\"", manifest.String()))
+		resFile := android.PathForModuleObj(ctx, "windows_resources", "manifest.res")
+		transformWindowsRcToRes(ctx, rcFile, resFile, android.Paths{manifest.Path()})
+		resFiles = append(resFiles, resFile)
+	}
+
+	return resFiles
+}
+
 func (binary *binaryDecorator) unstrippedOutputFilePath() android.Path {
 	return binary.unstrippedOutputFile
 }
@@ -0,0 +1,84 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"android/soong/android"
+)
+
+func init() {
+	android.RegisterSingletonType("binary_size_check", binarySizeCheckSingletonFactory)
+}
+
+func binarySizeCheckSingletonFactory() android.Singleton {
+	return &binarySizeCheckSingleton{}
+}
+
+type binarySizeCheckSingleton struct{}
+
+// GenerateBuildActions creates the binary-size-check phony target, which records the sizes of the
+// native modules that generate linker map files in out/soong/binary_sizes/sizes.json.  If the
+// product configuration has a BinarySizeBaseline then it also reports the modules that have grown
+// by more than BinarySizeMaxGrowthPercent, so that the map files of those modules can be compared
+// to find the cause.
+func (binarySizeCheckSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	var entries []string
+	var outputs android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		c, ok := module.(*Module)
+		if !ok || !c.Enabled() || !c.outputFile.Valid() || !c.linkerMapFile().Valid() {
+			return
+		}
+		name := ctx.ModuleName(module) + ":" + ctx.ModuleSubDir(module)
+		entries = append(entries, name+" "+c.outputFile.Path().String())
+		outputs = append(outputs, c.outputFile.Path())
+	})
+	if len(entries) == 0 {
+		return
+	}
+	sort.Strings(entries)
+
+	modulesFile := android.PathForOutput(ctx, "binary_sizes", "modules.txt")
+	android.WriteFileRule(ctx, modulesFile, strings.Join(entries, "\n"))
+
+	sizesFile := android.PathForOutput(ctx, "binary_sizes", "sizes.json")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	cmd := rule.Command().
+		BuiltTool("check_binary_sizes").
+		FlagWithInput("--modules ", modulesFile).
+		Implicits(outputs).
+		FlagWithOutput("--output ", sizesFile)
+
+	if baseline := ctx.Config().BinarySizeBaseline(); baseline != "" {
+		baselinePath := android.ExistentPathForSource(ctx, baseline)
+		if !baselinePath.Valid() {
+			ctx.Errorf("binary size baseline %q does not exist", baseline)
+			return
+		}
+		cmd.FlagWithInput("--baseline ", baselinePath.Path()).
+			FlagWithArg("--max-growth-percent ", strconv.Itoa(ctx.Config().BinarySizeMaxGrowthPercent()))
+		if ctx.Config().BinarySizeCheckWarnOnly() {
+			cmd.Flag("--warn-only")
+		}
+	}
+
+	rule.Build("binary_size_check", "check binary sizes")
+
+	ctx.Phony("binary-size-check", sizesFile)
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+
+	"github.com/google/blueprint/proptools"
+)
+
+var prepareForBinarySizeTest = android.GroupFixturePreparers(
+	prepareForCcTest,
+	android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+		ctx.RegisterSingletonType("binary_size_check", binarySizeCheckSingletonFactory)
+	}),
+)
+
+const binarySizeTestBp = `
+	cc_binary {
+		name: "bin",
+		generate_map_file: true,
+	}
+
+	cc_library_shared {
+		name: "libfoo",
+		generate_map_file: true,
+	}
+
+	cc_library_shared {
+		name: "libbar",
+	}
+`
+
+func TestLinkerMapFile(t *testing.T) {
+	t.Parallel()
+	result := prepareForBinarySizeTest.RunTestWithBp(t, binarySizeTestBp)
+
+	checkMapFile := func(name, variant, expectedMapFile string) {
+		t.Helper()
+		module := result.ModuleForTests(name, variant)
+		link := module.Rule("ld")
+		android.AssertStringDoesContain(t, name+" ldflags", link.Args["ldFlags"], "-Wl,-Map="+expectedMapFile)
+		android.AssertStringListContains(t, name+" implicit outputs", link.ImplicitOutputs.Strings(), expectedMapFile)
+
+		outputFiles, err := module.Module().(*Module).OutputFiles(".map")
+		if err != nil {
+			t.Errorf("unexpected error for %s: %s", name, err)
+		}
+		android.AssertPathsRelativeToTopEquals(t, name+" .map output files", []string{expectedMapFile}, outputFiles)
+	}
+
+	checkMapFile("bin", "android_arm64_armv8-a",
+		"out/soong/.intermediates/bin/android_arm64_armv8-a/bin.map")
+	checkMapFile("libfoo", "android_arm64_armv8-a_shared",
+		"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/libfoo.so.map")
+
+	libbar := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+	android.AssertStringDoesNotContain(t, "libbar ldflags", libbar.Rule("ld").Args["ldFlags"], "-Wl,-Map=")
+	if _, err := libbar.Module().(*Module).OutputFiles(".map"); err == nil {
+		t.Errorf("expected an error for libbar .map output files")
+	}
+}
+
+func TestGenerateLinkerMapFilesForAllModules(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForBinarySizeTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.GenerateLinkerMapFiles = true
+		}),
+	).RunTestWithBp(t, binarySizeTestBp)
+
+	libbar := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+	android.AssertStringDoesContain(t, "libbar ldflags", libbar.Rule("ld").Args["ldFlags"],
+		"-Wl,-Map=out/soong/.intermediates/libbar/android_arm64_armv8-a_shared/libbar.so.map")
+}
+
+func TestBinarySizeCheck(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForBinarySizeTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.BinarySizeBaseline = proptools.StringPtr("build/binary_sizes.json")
+			maxGrowthPercent := 10
+			variables.BinarySizeMaxGrowthPercent = &maxGrowthPercent
+			variables.BinarySizeCheckWarnOnly = true
+		}),
+		android.FixtureAddTextFile("build/binary_sizes.json", "{}"),
+	).RunTestWithBp(t, binarySizeTestBp)
+
+	singleton := result.SingletonForTests("binary_size_check")
+
+	modules := android.ContentFromFileRuleForTests(t, singleton.Output("out/soong/binary_sizes/modules.txt"))
+	android.AssertStringDoesContain(t, "modules", modules, "bin:android_arm64_armv8-a ")
+	android.AssertStringDoesContain(t, "modules", modules, "libfoo:android_arm64_armv8-a_shared ")
+	android.AssertStringDoesNotContain(t, "modules", modules, "libbar")
+
+	check := singleton.Rule("binary_size_check")
+	android.AssertStringDoesContain(t, "command", check.RuleParams.Command,
+		"--baseline build/binary_sizes.json --max-growth-percent 10 --warn-only")
+	android.AssertStringDoesContain(t, "command", check.RuleParams.Command,
+		"--output out/soong/binary_sizes/sizes.json")
+}
+
+func TestBinarySizeCheckMissingBaseline(t *testing.T) {
+	t.Parallel()
+	android.GroupFixturePreparers(
+		prepareForBinarySizeTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.BinarySizeBaseline = proptools.StringPtr("build/binary_sizes.json")
+		}),
+	).
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`binary size baseline "build/binary_sizes.json" does not exist`)).
+		RunTestWithBp(t, binarySizeTestBp)
+}
@@ -49,3 +49,131 @@ cc_binary {
 	expectedUnStrippedFile := "outputbase/execroot/__main__/foo"
 	android.AssertStringEquals(t, "Unstripped output file", expectedUnStrippedFile, unStrippedFilePath.String())
 }
+
+func TestCcBinarySymlinkPreferredArch(t *testing.T) {
+	bp := `
+		cc_binary {
+			name: "tool",
+			srcs: ["foo.cc"],
+			compile_multilib: "both",
+			multilib: {
+				lib32: {
+					suffix: "32",
+				},
+				lib64: {
+					suffix: "64",
+				},
+			},
+			symlink_preferred_arch: true,
+		}
+	`
+	result := prepareForCcTest.RunTestWithBp(t, bp)
+
+	packagingSpecs := func(variant string) []string {
+		var paths []string
+		for _, spec := range result.ModuleForTests("tool", variant).Module().PackagingSpecs() {
+			paths = append(paths, spec.RelPathInPackage())
+		}
+		return paths
+	}
+
+	// The preferred architecture installs the symlink next to its suffixed binary.
+	tool64 := result.ModuleForTests("tool", "android_arm64_armv8-a").Module()
+	outputFiles, err := tool64.(android.OutputFileProducer).OutputFiles("")
+	if err != nil {
+		t.Fatalf("unexpected error getting cc_binary output files: %s", err)
+	}
+	android.AssertStringEquals(t, "64-bit output", "tool64", outputFiles[0].Base())
+	android.AssertDeepEquals(t, "64-bit packaging specs", []string{"bin/tool64", "bin/tool"},
+		packagingSpecs("android_arm64_armv8-a"))
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, tool64)[0]
+	android.AssertDeepEquals(t, "64-bit LOCAL_MODULE_SYMLINKS", []string{"tool"},
+		entries.EntryMap["LOCAL_MODULE_SYMLINKS"])
+
+	// The secondary architecture only installs its suffixed binary.
+	tool32 := result.ModuleForTests("tool", "android_arm_armv7-a-neon").Module()
+	outputFiles, err = tool32.(android.OutputFileProducer).OutputFiles("")
+	if err != nil {
+		t.Fatalf("unexpected error getting cc_binary output files: %s", err)
+	}
+	android.AssertStringEquals(t, "32-bit output", "tool32", outputFiles[0].Base())
+	android.AssertDeepEquals(t, "32-bit packaging specs", []string{"bin/tool32"},
+		packagingSpecs("android_arm_armv7-a-neon"))
+	entries = android.AndroidMkEntriesForTest(t, result.TestContext, tool32)[0]
+	if symlinks, ok := entries.EntryMap["LOCAL_MODULE_SYMLINKS"]; ok {
+		t.Errorf("unexpected LOCAL_MODULE_SYMLINKS for the 32-bit variant: %q", symlinks)
+	}
+}
+
+func TestCcBinarySymlinkPreferredArchWithoutSuffix(t *testing.T) {
+	testCcError(t, `symlink_preferred_arch: must also specify suffix`, `
+		cc_binary {
+			name: "tool",
+			srcs: ["foo.cc"],
+			compile_multilib: "both",
+			multilib: {
+				lib32: {
+					suffix: "32",
+				},
+			},
+			symlink_preferred_arch: true,
+		}
+	`)
+}
+
+func TestCcBinaryHostWindowsResources(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		PrepareForTestOnWindows,
+		android.FixtureModifyConfig(func(config android.Config) {
+			config.Targets[android.Windows] = []android.Target{
+				{android.Windows, android.Arch{ArchType: android.X86_64}, android.NativeBridgeDisabled, "", "", true},
+			}
+		}),
+	).RunTestWithBp(t, `
+		cc_binary_host {
+			name: "tool",
+			srcs: ["foo.cc"],
+			stl: "none",
+			target: {
+				windows: {
+					enabled: true,
+				},
+			},
+			windows: {
+				resources: ["app.rc"],
+				manifest: "app.manifest",
+			},
+		}
+	`)
+
+	windows := result.ModuleForTests("tool", "windows_x86_64")
+
+	rc := windows.Rule("windowsRc")
+	android.AssertPathRelativeToTopEquals(t, "app.rc input", "app.rc", rc.Input)
+	android.AssertPathRelativeToTopEquals(t, "app.rc output",
+		"out/soong/.intermediates/tool/windows_x86_64/obj/windows_resources/app.res", rc.Output)
+
+	manifestRc := windows.Output("windows_resources/manifest.rc")
+	android.AssertStringEquals(t, "manifest.rc", `1 24 "app.manifest"`,
+		android.ContentFromFileRuleForTests(t, manifestRc))
+	manifestRes := windows.Output("windows_resources/manifest.res")
+	android.AssertPathsRelativeToTopEquals(t, "manifest.res implicits", []string{"app.manifest"},
+		manifestRes.Implicits)
+
+	ld := windows.Rule("ld")
+	android.AssertPathsRelativeToTopEquals(t, "windows link inputs", []string{
+		"out/soong/.intermediates/tool/windows_x86_64/obj/foo.o",
+		"out/soong/.intermediates/tool/windows_x86_64/obj/windows_resources/app.res",
+		"out/soong/.intermediates/tool/windows_x86_64/obj/windows_resources/manifest.res",
+	}, ld.Inputs)
+
+	// The windows properties are ignored for other targets.
+	linux := result.ModuleForTests("tool", "linux_glibc_x86_64")
+	if rule := linux.MaybeRule("windowsRc").Rule; rule != nil {
+		t.Errorf("expected no windowsRc rule for linux, found %v", rule)
+	}
+	android.AssertPathsRelativeToTopEquals(t, "linux link inputs", []string{
+		"out/soong/.intermediates/tool/linux_glibc_x86_64/obj/foo.o",
+	}, linux.Rule("ld").Inputs)
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+	"android/soong/genrule"
+)
+
+func init() {
+	android.RegisterModuleType("cc_board_config_header", BoardConfigHeaderFactory)
+}
+
+type boardConfigHeaderProperties struct {
+	// the soong config namespace that the variables are read from, i.e. the namespace passed to
+	// add_soong_config_namespace in the board configuration.
+	Soong_config_namespace *string
+
+	// the soong config variables that are written to the header.  Each variable becomes a
+	// #define of the variable name to its value.  A variable that isn't added to the namespace
+	// with add_soong_config_var is an error.
+	Variables []string
+
+	// the name of the generated header.  Defaults to <module name>.h.
+	Header_name *string
+}
+
+// cc_board_config_header generates a header from soong config variables set in the board
+// configuration, replacing genrules that write the values of board variables exported through
+// Make into a header.  The header can be used by listing the module in generated_headers.  The
+// header is regenerated when the values of the variables change, which rebuilds the sources that
+// include it.
+//
+// For example, with the board configuration:
+//
+//     $(call add_soong_config_namespace,acme)
+//     $(call add_soong_config_var_value,acme,ACME_FEATURE_LEVEL,3)
+//
+// the module:
+//
+//     cc_board_config_header {
+//         name: "acme_config",
+//         soong_config_namespace: "acme",
+//         variables: ["ACME_FEATURE_LEVEL"],
+//     }
+//
+// generates acme_config.h containing "#define ACME_FEATURE_LEVEL 3".
+func BoardConfigHeaderFactory() android.Module {
+	module := &boardConfigHeader{}
+	module.AddProperties(&module.properties, &module.GenruleExtraProperties)
+	android.InitAndroidArchModule(module, android.HostAndDeviceSupported, android.MultilibBoth)
+	return module
+}
+
+type boardConfigHeader struct {
+	android.ModuleBase
+
+	// Provides the image variants, so that modules of every image can use the header.
+	GenruleExtraProperties
+
+	properties boardConfigHeaderProperties
+
+	header    android.Path
+	headerDir android.Path
+}
+
+var _ genrule.SourceFileGenerator = (*boardConfigHeader)(nil)
+
+func (m *boardConfigHeader) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	namespace := String(m.properties.Soong_config_namespace)
+	if namespace == "" {
+		ctx.PropertyErrorf("soong_config_namespace", "missing soong_config_namespace")
+		return
+	}
+
+	vendorConfig := ctx.Config().VendorConfig(namespace)
+	lines := []string{
+		fmt.Sprintf("// Generated by cc_board_config_header %q from the soong config namespace %q.",
+			ctx.ModuleName(), namespace),
+		"#pragma once",
+		"",
+	}
+	for _, variable := range m.properties.Variables {
+		if !vendorConfig.IsSet(variable) {
+			ctx.PropertyErrorf("variables", "unknown variable %q in soong config namespace %q",
+				variable, namespace)
+			continue
+		}
+		lines = append(lines, strings.TrimSpace("#define "+variable+" "+vendorConfig.String(variable)))
+	}
+
+	headerName := proptools.StringDefault(m.properties.Header_name, ctx.ModuleName()+".h")
+	header := android.PathForModuleGen(ctx, "include", headerName)
+	android.WriteFileRule(ctx, header, strings.Join(lines, "\n"))
+
+	m.header = header
+	m.headerDir = android.PathForModuleGen(ctx, "include")
+}
+
+func (m *boardConfigHeader) GeneratedSourceFiles() android.Paths {
+	return android.Paths{m.header}
+}
+
+func (m *boardConfigHeader) GeneratedHeaderDirs() android.Paths {
+	return android.Paths{m.headerDir}
+}
+
+func (m *boardConfigHeader) GeneratedDeps() android.Paths {
+	return android.Paths{m.header}
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+var prepareForBoardConfigHeaderTest = android.GroupFixturePreparers(
+	prepareForCcTest,
+	android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+		variables.VendorVars = map[string]map[string]string{
+			"acme": {
+				"ACME_FEATURE_LEVEL": "3",
+				"ACME_HAS_WIDGET":    "true",
+				"ACME_EMPTY":         "",
+			},
+		}
+	}),
+)
+
+func TestBoardConfigHeader(t *testing.T) {
+	bp := `
+		cc_board_config_header {
+			name: "acme_config",
+			soong_config_namespace: "acme",
+			variables: [
+				"ACME_FEATURE_LEVEL",
+				"ACME_HAS_WIDGET",
+				"ACME_EMPTY",
+			],
+		}
+
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			generated_headers: ["acme_config"],
+		}
+	`
+	result := prepareForBoardConfigHeaderTest.RunTestWithBp(t, bp)
+
+	header := result.ModuleForTests("acme_config", "android_arm64_armv8-a").Output("include/acme_config.h")
+	android.AssertStringEquals(t, "header content",
+		"// Generated by cc_board_config_header \"acme_config\" from the soong config namespace \"acme\".\n"+
+			"#pragma once\n"+
+			"\n"+
+			"#define ACME_FEATURE_LEVEL 3\n"+
+			"#define ACME_HAS_WIDGET true\n"+
+			"#define ACME_EMPTY",
+		android.ContentFromFileRuleForTests(t, header))
+
+	cc := result.ModuleForTests("libfoo", "android_arm64_armv8-a_static").Rule("cc")
+	android.AssertStringDoesContain(t, "include dir of the header", cc.Args["cFlags"],
+		"-Iout/soong/.intermediates/acme_config/android_arm64_armv8-a/gen/include")
+	android.AssertStringListContains(t, "dependency on the header", android.PathsRelativeToTop(cc.OrderOnly),
+		"out/soong/.intermediates/acme_config/android_arm64_armv8-a/gen/include/acme_config.h")
+}
+
+func TestBoardConfigHeaderName(t *testing.T) {
+	bp := `
+		cc_board_config_header {
+			name: "acme_config",
+			soong_config_namespace: "acme",
+			variables: ["ACME_FEATURE_LEVEL"],
+			header_name: "acme/config.h",
+		}
+	`
+	result := prepareForBoardConfigHeaderTest.RunTestWithBp(t, bp)
+
+	result.ModuleForTests("acme_config", "android_arm64_armv8-a").Output("include/acme/config.h")
+}
+
+func TestBoardConfigHeaderUnknownVariable(t *testing.T) {
+	bp := `
+		cc_board_config_header {
+			name: "acme_config",
+			soong_config_namespace: "acme",
+			variables: ["ACME_UNKNOWN"],
+		}
+	`
+	prepareForBoardConfigHeaderTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`variables: unknown variable "ACME_UNKNOWN" in soong config namespace "acme"`)).
+		RunTestWithBp(t, bp)
+}
@@ -58,6 +58,16 @@ var (
 		},
 		"ccCmd", "cFlags")
 
+	// Rule to run the clang static analyzer with the flags used to compile the source file and
+	// write its report as a .plist file. Warnings are never turned into errors so that the report
+	// is always written.
+	clangAnalyzer = pctx.AndroidStaticRule("clangAnalyzer",
+		blueprint.RuleParams{
+			Command:     "$relPwd $ccCmd --analyze --analyzer-output plist $cFlags -Wno-error -o $out $in",
+			CommandDeps: []string{"$ccCmd"},
+		},
+		"ccCmd", "cFlags")
+
 	// Rules to invoke ld to link binaries. Uses a .rsp file to list dependencies, as there may
 	// be many.
 	ld, ldRE = pctx.RemoteStaticRules("ld",
@@ -80,6 +90,43 @@ var (
 			Platform:        map[string]string{remoteexec.PoolKey: "${config.RECXXLinksPool}"},
 		}, []string{"ldCmd", "crtBegin", "libFlags", "crtEnd", "ldFlags", "extraLibFlags"}, []string{"implicitInputs", "implicitOutputs"})
 
+	// Rule for linking that also writes the sections that the linker reports as removed with
+	// --print-gc-sections to ${gcSectionsReport}.  The other output of the linker is passed
+	// through to stderr.
+	ldWithGcSectionsReport = pctx.AndroidStaticRule("ldWithGcSectionsReport",
+		blueprint.RuleParams{
+			Command: "$ldCmd ${crtBegin} @${out}.rsp ${libFlags} ${crtEnd} -o ${out} ${ldFlags} ${extraLibFlags} " +
+				"2> ${gcSectionsReport}.tmp; status=$$?; " +
+				"grep -v 'removing unused section' ${gcSectionsReport}.tmp >&2; " +
+				"grep 'removing unused section' ${gcSectionsReport}.tmp > ${gcSectionsReport}; " +
+				"rm -f ${gcSectionsReport}.tmp; exit $$status",
+			CommandDeps:    []string{"$ldCmd"},
+			Rspfile:        "${out}.rsp",
+			RspfileContent: "${in}",
+			Restat:         true,
+		},
+		"ldCmd", "crtBegin", "libFlags", "crtEnd", "ldFlags", "extraLibFlags", "gcSectionsReport")
+
+	// Rule for linking that, if the link fails, links again with --warn-backrefs and writes its
+	// warnings and the symbols defined by each of the ${staticLibs} to ${linkDiagnosis}.  The
+	// diagnosis is empty if the link succeeds.
+	ldWithLinkDiagnosis = pctx.AndroidStaticRule("ldWithLinkDiagnosis",
+		blueprint.RuleParams{
+			Command: "rm -f ${linkDiagnosis}; " +
+				"if $ldCmd ${crtBegin} @${out}.rsp ${libFlags} ${crtEnd} -o ${out} ${ldFlags} ${extraLibFlags}; then " +
+				"touch ${linkDiagnosis}; else " +
+				"$ldCmd ${crtBegin} @${out}.rsp ${libFlags} ${crtEnd} -o /dev/null ${ldFlags} ${extraLibFlags} " +
+				"-Wl,--warn-backrefs > ${linkDiagnosis} 2>&1; " +
+				"for lib in ${staticLibs}; do ${config.ClangBin}/llvm-nm -A --defined-only $$lib; done >> ${linkDiagnosis} 2>&1; " +
+				"echo \"error: linking ${out} failed, see ${linkDiagnosis} for the --warn-backrefs warnings " +
+				"and the symbols defined by each static library\" >&2; exit 1; fi",
+			CommandDeps:    []string{"$ldCmd", "${config.ClangBin}/llvm-nm"},
+			Rspfile:        "${out}.rsp",
+			RspfileContent: "${in}",
+			Restat:         true,
+		},
+		"ldCmd", "crtBegin", "libFlags", "crtEnd", "ldFlags", "extraLibFlags", "staticLibs", "linkDiagnosis")
+
 	// Rules for .o files to combine to other .o files, using ld partial linking.
 	partialLd, partialLdRE = pctx.RemoteStaticRules("partialLd",
 		blueprint.RuleParams{
@@ -125,6 +172,14 @@ var (
 		},
 		"objcopyCmd", "prefix")
 
+	// Rule to compile a Windows resource script (.rc) into a resource file (.res), which the linker
+	// embeds into Windows binaries.
+	windowsRc = pctx.AndroidStaticRule("windowsRc",
+		blueprint.RuleParams{
+			Command:     "${config.ClangBin}/llvm-rc /FO ${out} ${in}",
+			CommandDeps: []string{"${config.ClangBin}/llvm-rc"},
+		})
+
 	_ = pctx.SourcePathVariable("stripPath", "build/soong/scripts/strip.sh")
 	_ = pctx.SourcePathVariable("xzCmd", "prebuilts/build-tools/${config.HostPrebuiltTag}/bin/xz")
 	_ = pctx.SourcePathVariable("createMiniDebugInfo", "prebuilts/build-tools/${config.HostPrebuiltTag}/bin/create_minidebuginfo")
@@ -183,6 +238,22 @@ var (
 		},
 		"objects")
 
+	// Rule to preprocess a translation unit including all the LLNDK headers of a library once with the
+	// LLNDK include directories and once with the implementation include directories, and to fail
+	// if the results differ.
+	llndkHeaderDivergence = pctx.AndroidStaticRule("llndkHeaderDivergence",
+		blueprint.RuleParams{
+			Command: "rm -f $out ${out}.llndk ${out}.impl && " +
+				"${config.ClangBin}/clang++ -E -P -Werror $llndkIncludes $cFlags $in -o ${out}.llndk && " +
+				"${config.ClangBin}/clang++ -E -P -Werror $implIncludes $cFlags $in -o ${out}.impl && " +
+				"(diff -u ${out}.llndk ${out}.impl || " +
+				"(echo 'error: the LLNDK headers of $module diverge from its implementation headers.' && " +
+				"echo 'Remove llndk: { check_divergence: true } if this is intentional.' && exit 1)) && " +
+				"touch $out",
+			CommandDeps: []string{"${config.ClangBin}/clang++"},
+		},
+		"llndkIncludes", "implIncludes", "cFlags", "module")
+
 	// Rule to create an empty file at a given path.
 	emptyFile = pctx.AndroidStaticRule("emptyFile",
 		blueprint.RuleParams{
@@ -202,6 +273,59 @@ var (
 		},
 		"clangBin", "format")
 
+	_ = pctx.SourcePathVariable("checkExportedSymbolsPath", "build/soong/scripts/check_exported_symbols.sh")
+
+	// A rule for verifying that a shared library (.so) only exports the symbols in an allowlist.
+	checkExportedSymbols = pctx.AndroidStaticRule("checkExportedSymbols",
+		blueprint.RuleParams{
+			Command:     "CLANG_BIN=${config.ClangBin} $checkExportedSymbolsPath -i ${in} -a ${allowlist} -o ${out}",
+			CommandDeps: []string{"$checkExportedSymbolsPath", "${config.ClangBin}/llvm-nm"},
+		},
+		"allowlist")
+
+	_ = pctx.SourcePathVariable("checkIncludeDirsPath", "build/soong/scripts/check_include_dirs.sh")
+
+	// A rule for verifying that the include directories exported by a module contain headers.
+	checkIncludeDirs = pctx.AndroidStaticRule("checkIncludeDirs",
+		blueprint.RuleParams{
+			Command:     "$checkIncludeDirsPath -m ${module} ${flags} -o ${out} ${in}",
+			CommandDeps: []string{"$checkIncludeDirsPath"},
+		},
+		"module", "flags")
+
+	_ = pctx.SourcePathVariable("checkPrebuiltAbiPath", "build/soong/scripts/check_prebuilt_abi.sh")
+
+	// A rule for verifying that a prebuilt shared library (.so) exports all the symbols of the
+	// shared library built from source that it replaces.
+	checkPrebuiltAbi = pctx.AndroidStaticRule("checkPrebuiltAbi",
+		blueprint.RuleParams{
+			Command:     "CLANG_BIN=${config.ClangBin} $checkPrebuiltAbiPath -i ${in} -s ${source} -o ${out}",
+			CommandDeps: []string{"$checkPrebuiltAbiPath", "${config.ClangBin}/llvm-nm"},
+		},
+		"source")
+
+	// A rule for verifying that the symbols exported by a shared library (.so) match its version
+	// scripts.
+	checkVersionScriptSymbols = pctx.AndroidStaticRule("checkVersionScriptSymbols",
+		blueprint.RuleParams{
+			Command: "$checkVersionScriptSymbolsCmd --llvm-nm ${config.ClangBin}/llvm-nm " +
+				"$versionScriptFlags --output ${out} ${in}",
+			CommandDeps: []string{"$checkVersionScriptSymbolsCmd", "${config.ClangBin}/llvm-nm"},
+		},
+		"versionScriptFlags")
+
+	// A rule for generating a version script that exports the symbols annotated with an export
+	// macro in a library's exported headers. Uses a .rsp file to list the headers, as there may be
+	// many.
+	genVersionScript = pctx.AndroidStaticRule("genVersionScript",
+		blueprint.RuleParams{
+			Command:        "$genVersionScriptCmd --export-macro $exportMacro --output $out @${out}.rsp",
+			CommandDeps:    []string{"$genVersionScriptCmd"},
+			Rspfile:        "${out}.rsp",
+			RspfileContent: "${in}",
+		},
+		"exportMacro")
+
 	// Rule for invoking clang-tidy (a clang-based linter).
 	clangTidyDep, clangTidyDepRE = pctx.RemoteStaticRules("clangTidyDep",
 		blueprint.RuleParams{
@@ -218,13 +342,14 @@ var (
 			Platform:     map[string]string{remoteexec.PoolKey: "${config.REClangTidyPool}"},
 		}, []string{"ccCmd", "cFlags", "tidyFile"}, []string{})
 
+	// The .tidy file records how long clang-tidy took, in seconds, followed by the source file.
 	clangTidy, clangTidyRE = pctx.RemoteStaticRules("clangTidy",
 		blueprint.RuleParams{
 			Depfile: "${out}.d",
 			Deps:    blueprint.DepsGCC,
-			Command: "cp ${out}.dep ${out}.d && " +
+			Command: "cp ${out}.dep ${out}.d && start=$$(date +%s) && " +
 				"$tidyVars$reTemplate${config.ClangBin}/clang-tidy $tidyFlags $in -- $cFlags && " +
-				"touch $out",
+				"echo $$(($$(date +%s) - start)) $in > $out",
 			CommandDeps: []string{"${config.ClangBin}/clang-tidy"},
 		},
 		&remoteexec.REParams{
@@ -359,6 +484,8 @@ func init() {
 	pctx.StaticVariable("relPwd", PwdPrefix())
 
 	pctx.HostBinToolVariable("SoongZipCmd", "soong_zip")
+	pctx.HostBinToolVariable("genVersionScriptCmd", "gen_version_script_from_headers")
+	pctx.HostBinToolVariable("checkVersionScriptSymbolsCmd", "check_version_script_symbols")
 }
 
 // builderFlags contains various types of command line flags (and settings) for use in building
@@ -402,8 +529,15 @@ type builderFlags struct {
 	sAbiDump      bool
 	emitXrefs     bool
 
+	staticAnalyzer bool
+
 	assemblerWithCpp bool // True if .s files should be processed with the c preprocessor.
 
+	gcSectionsReport android.WritablePath // File to write the sections removed by --gc-sections to.
+	linkDiagnosis    android.WritablePath // File to write the diagnosis of a failed link to.
+
+	tidyTimeout string // The clang-tidy timeout in seconds for tidy_timeout_srcs.
+
 	systemIncludeFlags string
 
 	proto            android.ProtoFlags
@@ -434,6 +568,7 @@ type Objects struct {
 	coverageFiles android.Paths
 	sAbiDumpFiles android.Paths
 	kytheFiles    android.Paths
+	analyzerFiles android.Paths
 }
 
 func (a Objects) Copy() Objects {
@@ -444,6 +579,7 @@ func (a Objects) Copy() Objects {
 		coverageFiles: append(android.Paths{}, a.coverageFiles...),
 		sAbiDumpFiles: append(android.Paths{}, a.sAbiDumpFiles...),
 		kytheFiles:    append(android.Paths{}, a.kytheFiles...),
+		analyzerFiles: append(android.Paths{}, a.analyzerFiles...),
 	}
 }
 
@@ -455,6 +591,7 @@ func (a Objects) Append(b Objects) Objects {
 		coverageFiles: append(a.coverageFiles, b.coverageFiles...),
 		sAbiDumpFiles: append(a.sAbiDumpFiles, b.sAbiDumpFiles...),
 		kytheFiles:    append(a.kytheFiles, b.kytheFiles...),
+		analyzerFiles: append(a.analyzerFiles, b.analyzerFiles...),
 	}
 }
 
@@ -465,6 +602,7 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 	objFiles := make(android.Paths, len(srcFiles))
 	var tidyFiles android.Paths
 	noTidySrcsMap := make(map[string]bool)
+	timeoutTidySrcsMap := make(map[string]bool)
 	var tidyVars string
 	if flags.tidy {
 		tidyFiles = make(android.Paths, 0, len(srcFiles))
@@ -478,6 +616,12 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			for _, path := range timeoutTidySrcs {
 				noTidySrcsMap[path.String()] = true
 			}
+		} else if len(flags.tidyTimeout) > 0 {
+			// Without a global TIDY_TIMEOUT, the module's tidy_timeout only applies to
+			// timeoutTidySrcs, so the other source files are not killed by it.
+			for _, path := range timeoutTidySrcs {
+				timeoutTidySrcsMap[path.String()] = true
+			}
 		}
 	}
 	var coverageFiles android.Paths
@@ -488,6 +632,10 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 	if flags.emitXrefs {
 		kytheFiles = make(android.Paths, 0, len(srcFiles))
 	}
+	var analyzerFiles android.Paths
+	if flags.staticAnalyzer {
+		analyzerFiles = make(android.Paths, 0, len(srcFiles))
+	}
 
 	// Produce fully expanded flags for use by C tools, C compiles, C++ tools, C++ compiles, and asm compiles
 	// respectively.
@@ -572,8 +720,20 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		return "$" + kind + n
 	}
 
+	// Object paths mirror the source path, so sources with the same basename in different
+	// directories get distinct objects.  Sources in the same directory that only differ in
+	// their extension (foo.c and foo.cpp) would still collide, so keep the source extension
+	// in the object name for any source after the first.
+	seenObjFiles := make(map[string]bool, len(srcFiles))
+
 	for i, srcFile := range srcFiles {
+		objExtPrefix := ""
 		objFile := android.ObjPathWithExt(ctx, subdir, srcFile, "o")
+		if seenObjFiles[objFile.String()] {
+			objExtPrefix = strings.TrimPrefix(srcFile.Ext(), ".") + "."
+			objFile = android.ObjPathWithExt(ctx, subdir, srcFile, objExtPrefix+"o")
+		}
+		seenObjFiles[objFile.String()] = true
 
 		objFiles[i] = objFile
 
@@ -606,6 +766,7 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		dump := flags.sAbiDump
 		rule := cc
 		emitXref := flags.emitXrefs
+		analyze := flags.staticAnalyzer
 
 		switch srcFile.Ext() {
 		case ".s":
@@ -620,6 +781,7 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			coverage = false
 			dump = false
 			emitXref = false
+			analyze = false
 		case ".c":
 			ccCmd = "clang"
 			moduleFlags = cflags
@@ -642,7 +804,7 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 
 		var implicitOutputs android.WritablePaths
 		if coverage {
-			gcnoFile := android.ObjPathWithExt(ctx, subdir, srcFile, "gcno")
+			gcnoFile := android.ObjPathWithExt(ctx, subdir, srcFile, objExtPrefix+"gcno")
 			implicitOutputs = append(implicitOutputs, gcnoFile)
 			coverageFiles = append(coverageFiles, gcnoFile)
 		}
@@ -663,7 +825,7 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 
 		// Register post-process build statements (such as for tidy or kythe).
 		if emitXref {
-			kytheFile := android.ObjPathWithExt(ctx, subdir, srcFile, "kzip")
+			kytheFile := android.ObjPathWithExt(ctx, subdir, srcFile, objExtPrefix+"kzip")
 			ctx.Build(pctx, android.BuildParams{
 				Rule:        kytheExtract,
 				Description: "Xref C++ extractor " + srcFile.Rel(),
@@ -680,8 +842,8 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 
 		//  Even with tidy, some src file could be skipped by noTidySrcsMap.
 		if tidy && !noTidySrcsMap[srcFile.String()] {
-			tidyFile := android.ObjPathWithExt(ctx, subdir, srcFile, "tidy")
-			tidyDepFile := android.ObjPathWithExt(ctx, subdir, srcFile, "tidy.dep")
+			tidyFile := android.ObjPathWithExt(ctx, subdir, srcFile, objExtPrefix+"tidy")
+			tidyDepFile := android.ObjPathWithExt(ctx, subdir, srcFile, objExtPrefix+"tidy.dep")
 			tidyFiles = append(tidyFiles, tidyFile)
 
 			ruleDep := clangTidyDep
@@ -693,6 +855,10 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 
 			sharedCFlags := shareFlags("cFlags", moduleFlags)
 			srcRelPath := srcFile.Rel()
+			srcTidyVars := tidyVars
+			if timeoutTidySrcsMap[srcFile.String()] {
+				srcTidyVars = "TIDY_TIMEOUT=" + flags.tidyTimeout + " "
+			}
 
 			// Add the .tidy.d rule
 			ctx.Build(pctx, android.BuildParams{
@@ -719,13 +885,34 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 				Args: map[string]string{
 					"cFlags":    sharedCFlags,
 					"tidyFlags": shareFlags("tidyFlags", config.TidyFlagsForSrcFile(srcFile, flags.tidyFlags)),
-					"tidyVars":  tidyVars, // short and not shared
+					"tidyVars":  srcTidyVars, // short and not shared
+				},
+			})
+		}
+
+		if analyze {
+			analyzerFile := analyzerPathForSrc(ctx, subdir, srcFile, objExtPrefix+"plist")
+			analyzerFiles = append(analyzerFiles, analyzerFile)
+
+			// The object file is an implicit dependency so that the analysis is rerun whenever
+			// the source file or any of the headers it includes change.
+			ctx.Build(pctx, android.BuildParams{
+				Rule:        clangAnalyzer,
+				Description: "clang-analyzer " + srcFile.Rel(),
+				Output:      analyzerFile,
+				Input:       srcFile,
+				Implicit:    objFile,
+				Implicits:   cFlagsDeps,
+				OrderOnly:   pathDeps,
+				Args: map[string]string{
+					"ccCmd":  ccCmd,
+					"cFlags": shareFlags("cFlags", moduleFlags),
 				},
 			})
 		}
 
 		if dump {
-			sAbiDumpFile := android.ObjPathWithExt(ctx, subdir, srcFile, "sdump")
+			sAbiDumpFile := android.ObjPathWithExt(ctx, subdir, srcFile, objExtPrefix+"sdump")
 			sAbiDumpFiles = append(sAbiDumpFiles, sAbiDumpFile)
 
 			// TODO(b/226497964): dumpRule = sAbiDumpRE if USE_RBE and RBE_ABI_DUMPER are true.
@@ -758,9 +945,40 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		coverageFiles: coverageFiles,
 		sAbiDumpFiles: sAbiDumpFiles,
 		kytheFiles:    kytheFiles,
+		analyzerFiles: analyzerFiles,
 	}
 }
 
+// transformLlndkHeadersToDivergenceCheck generates a rule that preprocesses the translation unit
+// tu, which includes every LLNDK header of a library, against both the LLNDK and the
+// implementation include directories, and fails if the preprocessed outputs differ.
+func transformLlndkHeadersToDivergenceCheck(ctx android.ModuleContext, tu android.Path,
+	headers android.Paths, llndkDirs, implDirs android.Paths, flags builderFlags,
+	outputFile android.WritablePath) {
+
+	cFlags := strings.Join([]string{
+		flags.globalCommonFlags,
+		flags.globalCFlags,
+		flags.globalCppFlags,
+		flags.localCommonFlags,
+		flags.systemIncludeFlags,
+	}, " ")
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        llndkHeaderDivergence,
+		Description: "llndk header divergence check " + outputFile.Base(),
+		Output:      outputFile,
+		Input:       tu,
+		Implicits:   headers,
+		Args: map[string]string{
+			"llndkIncludes": includeDirsToFlags(llndkDirs),
+			"implIncludes":  includeDirsToFlags(implDirs),
+			"cFlags":        cFlags,
+			"module":        ctx.ModuleName(),
+		},
+	})
+}
+
 // Generate a rule for compiling multiple .o files to a static library (.a)
 func transformObjToStaticLib(ctx android.ModuleContext,
 	objFiles android.Paths, wholeStaticLibs android.Paths,
@@ -862,7 +1080,18 @@ func transformObjToDynamicBinary(ctx android.ModuleContext,
 		"ldFlags":       flags.globalLdFlags + " " + flags.localLdFlags,
 		"crtEnd":        strings.Join(crtEnd.Strings(), " "),
 	}
-	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_CXX_LINKS") {
+	if flags.gcSectionsReport != nil {
+		rule = ldWithGcSectionsReport
+		args["gcSectionsReport"] = flags.gcSectionsReport.String()
+		implicitOutputs = append(implicitOutputs, flags.gcSectionsReport)
+	} else if flags.linkDiagnosis != nil {
+		rule = ldWithLinkDiagnosis
+		allStaticLibs := append(append(android.Paths{}, wholeStaticLibs...), staticLibs...)
+		allStaticLibs = append(allStaticLibs, lateStaticLibs...)
+		args["staticLibs"] = strings.Join(allStaticLibs.Strings(), " ")
+		args["linkDiagnosis"] = flags.linkDiagnosis.String()
+		implicitOutputs = append(implicitOutputs, flags.linkDiagnosis)
+	} else if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_CXX_LINKS") {
 		rule = ldRE
 		args["implicitOutputs"] = strings.Join(implicitOutputs.Strings(), ",")
 		args["implicitInputs"] = strings.Join(deps.Strings(), ",")
@@ -1020,6 +1249,75 @@ func TransformSharedObjectToToc(ctx android.ModuleContext, inputFile android.Pat
 	})
 }
 
+// transformSharedObjectToExportedSymbolsCheck generates a rule that fails if the shared library
+// exports any symbol that is not listed in allowlist, and otherwise writes a timestamp file.
+func transformSharedObjectToExportedSymbolsCheck(ctx android.ModuleContext, inputFile android.Path,
+	allowlist android.Path, outputFile android.WritablePath) {
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkExportedSymbols,
+		Description: "check exported symbols " + inputFile.Base(),
+		Output:      outputFile,
+		Input:       inputFile,
+		Implicit:    allowlist,
+		Args: map[string]string{
+			"allowlist": allowlist.String(),
+		},
+	})
+}
+
+// transformPrebuiltSharedObjectToAbiCheck generates a rule that fails if the prebuilt shared
+// library does not export all the symbols exported by the shared library built from source, and
+// otherwise writes a timestamp file.
+func transformPrebuiltSharedObjectToAbiCheck(ctx android.ModuleContext, inputFile android.Path,
+	sourceFile android.Path, outputFile android.WritablePath) {
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkPrebuiltAbi,
+		Description: "check prebuilt abi " + inputFile.Base(),
+		Output:      outputFile,
+		Input:       inputFile,
+		Implicit:    sourceFile,
+		Args: map[string]string{
+			"source": sourceFile.String(),
+		},
+	})
+}
+
+// transformSharedObjectToVersionScriptSymbolsCheck generates a rule that fails if the symbols
+// exported by the shared library do not match the global symbols of its version scripts, and
+// otherwise writes a timestamp file.
+func transformSharedObjectToVersionScriptSymbolsCheck(ctx android.ModuleContext, inputFile android.Path,
+	versionScripts android.Paths, outputFile android.WritablePath) {
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkVersionScriptSymbols,
+		Description: "check version script symbols " + inputFile.Base(),
+		Output:      outputFile,
+		Input:       inputFile,
+		Implicits:   versionScripts,
+		Args: map[string]string{
+			"versionScriptFlags": android.JoinWithPrefix(versionScripts.Strings(), "--version-script "),
+		},
+	})
+}
+
+// transformHeadersToVersionScript generates a rule that creates a version script exporting the
+// functions and variables whose declarations in headers are annotated with exportMacro.
+func transformHeadersToVersionScript(ctx android.ModuleContext, headers android.Paths,
+	exportMacro string, outputFile android.WritablePath) {
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        genVersionScript,
+		Description: "generate version script " + outputFile.Base(),
+		Output:      outputFile,
+		Inputs:      headers,
+		Args: map[string]string{
+			"exportMacro": exportMacro,
+		},
+	})
+}
+
 // Generate a rule for compiling multiple .o files to a .o using ld partial linking
 func transformObjsToObj(ctx android.ModuleContext, objFiles android.Paths,
 	flags builderFlags, outputFile android.WritablePath, deps android.Paths) {
@@ -1064,6 +1362,19 @@ func transformBinaryPrefixSymbols(ctx android.ModuleContext, prefix string, inpu
 	})
 }
 
+// Generate a rule for compiling a Windows resource script into a resource file
+func transformWindowsRcToRes(ctx android.ModuleContext, rcFile android.Path, outputFile android.WritablePath,
+	implicits android.Paths) {
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        windowsRc,
+		Description: "llvm-rc " + rcFile.Base(),
+		Output:      outputFile,
+		Input:       rcFile,
+		Implicits:   implicits,
+	})
+}
+
 // Registers a build statement to invoke `strip` (to discard symbols and data from object files).
 func transformStrip(ctx android.ModuleContext, inputFile android.Path,
 	outputFile android.WritablePath, flags StripFlags) {
@@ -23,6 +23,7 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
@@ -71,6 +72,8 @@ func RegisterCCBuildComponents(ctx android.RegistrationContext) {
 		ctx.BottomUp("lto", ltoMutator).Parallel()
 
 		ctx.BottomUp("check_linktype", checkLinkTypeMutator).Parallel()
+		ctx.TopDown("image_deps_check", imageDepsCheckMutator)
+		ctx.TopDown("host_libc_deps_check", hostLibcDepsCheckMutator)
 		ctx.TopDown("double_loadable", checkDoubleLoadableLibraries).Parallel()
 	})
 
@@ -156,6 +159,10 @@ type PathDeps struct {
 	GeneratedSources android.Paths
 	GeneratedDeps    android.Paths
 
+	// Paths to generated directories of source files, and the lists of the files in them
+	GeneratedSourceDirs         android.Paths
+	GeneratedSourceDirFileLists android.Paths
+
 	Flags                      []string
 	IncludeDirs                android.Paths
 	SystemIncludeDirs          android.Paths
@@ -174,6 +181,10 @@ type PathDeps struct {
 	// For Darwin builds, the path to the second architecture's output that should
 	// be combined with this architectures's output into a FAT MachO file.
 	DarwinSecondArchOutput android.OptionalPath
+
+	// The minimum C++ standard versions required by the exported headers of direct library
+	// dependencies, keyed by the name of the dependency.
+	DepsMinCppStd map[string]string
 }
 
 // LocalOrGlobalFlags contains flags that need to have values set globally by the build system or locally by the module
@@ -217,6 +228,8 @@ type Flags struct {
 	SAbiDump      bool // True if header abi dumps should be generated.
 	EmitXrefs     bool // If true, generate Ninja rules to generate emitXrefs input files for Kythe
 
+	StaticAnalyzer bool // True if ninja rules running the clang static analyzer should be generated.
+
 	// The instruction set required for clang ("arm" or "thumb").
 	RequiredInstructionSet string
 	// The target-device system path to the dynamic linker.
@@ -225,6 +238,15 @@ type Flags struct {
 	CFlagsDeps  android.Paths // Files depended on by compiler flags
 	LdFlagsDeps android.Paths // Files depended on by linker flags
 
+	// File to write the sections removed by the linker's --gc-sections to, if any.
+	GcSectionsReport android.WritablePath
+
+	// File to write the diagnosis of a failed link to, if any.
+	LinkDiagnosis android.WritablePath
+
+	// The clang-tidy timeout in seconds for the source files listed in tidy_timeout_srcs.
+	TidyTimeout string
+
 	// True if .s files should be processed with the c preprocessor.
 	AssemblerWithCpp bool
 
@@ -236,6 +258,69 @@ type Flags struct {
 	Lex  *LexProperties
 }
 
+var flagSliceInternerOnceKey = android.NewOnceKey("flagSliceInterner")
+
+// flagSliceInterner shares the backing arrays of identical flag slices between modules.  The global
+// and toolchain flags are the same for most variants of an architecture, and keeping a separate
+// copy of them for every variant makes up a large part of the memory used by soong_build.
+//
+// The slices returned by intern must be treated as immutable.  Their capacity is equal to their
+// length so that appending to them always allocates a new backing array.
+type flagSliceInterner struct {
+	lock   sync.Mutex
+	slices map[string][]string
+	stats  *android.FlagSliceInternStats
+}
+
+func getFlagSliceInterner(config android.Config) *flagSliceInterner {
+	return config.Once(flagSliceInternerOnceKey, func() interface{} {
+		return &flagSliceInterner{
+			slices: make(map[string][]string),
+			stats:  android.GetFlagSliceInternStats(config),
+		}
+	}).(*flagSliceInterner)
+}
+
+// intern returns a slice with the same contents as flags that may be shared with other modules.
+func (i *flagSliceInterner) intern(flags []string) []string {
+	if len(flags) == 0 {
+		return flags
+	}
+
+	// Flags never contain a NUL character, so it can't cause two different slices to share a key.
+	key := strings.Join(flags, "\x00")
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if interned, ok := i.slices[key]; ok {
+		i.stats.Record(true)
+		return interned
+	}
+	i.stats.Record(false)
+
+	// Copy the slice so that later writes to the caller's backing array can't modify it.
+	interned := make([]string, len(flags))
+	copy(interned, flags)
+	i.slices[key] = interned
+	return interned
+}
+
+// internFlags interns each of the flag slices in flags.
+func (i *flagSliceInterner) internFlags(flags LocalOrGlobalFlags) LocalOrGlobalFlags {
+	return LocalOrGlobalFlags{
+		CommonFlags:     i.intern(flags.CommonFlags),
+		AsFlags:         i.intern(flags.AsFlags),
+		YasmFlags:       i.intern(flags.YasmFlags),
+		CFlags:          i.intern(flags.CFlags),
+		ToolingCFlags:   i.intern(flags.ToolingCFlags),
+		ConlyFlags:      i.intern(flags.ConlyFlags),
+		CppFlags:        i.intern(flags.CppFlags),
+		ToolingCppFlags: i.intern(flags.ToolingCppFlags),
+		LdFlags:         i.intern(flags.LdFlags),
+	}
+}
+
 // Properties used to compile all C or C++ modules
 type BaseProperties struct {
 	// Deprecated. true is the default, false is invalid.
@@ -310,6 +395,11 @@ type BaseProperties struct {
 	// Make this module available when building for recovery
 	Recovery_available *bool
 
+	// The libc the Linux host variants of this module are built against: "glibc", "musl" or
+	// "both".  Defaults to "both" for libraries and objects, and to the libc of the build OS for
+	// other modules.  The libraries the module depends on must be built against the same libc.
+	Host_libc *string
+
 	// Used by imageMutator, set by ImageMutatorBegin()
 	CoreVariantNeeded          bool `blueprint:"mutated"`
 	RamdiskVariantNeeded       bool `blueprint:"mutated"`
@@ -468,6 +558,10 @@ type VendorProperties struct {
 	// IsVendorPublicLibrary is set for the core and product variants of a library that has
 	// vendor_public_library stubs.
 	IsVendorPublicLibrary bool `blueprint:"mutated"`
+
+	// IsVendorStubs is set for the vendor variants of a library that sets
+	// stubs.vendor_available.
+	IsVendorStubs bool `blueprint:"mutated"`
 }
 
 // ModuleContextIntf is an interface (on a module context helper) consisting of functions related
@@ -497,6 +591,7 @@ type ModuleContextIntf interface {
 	isVndkSp() bool
 	IsVndkExt() bool
 	IsVendorPublicLibrary() bool
+	IsVendorStubs() bool
 	inProduct() bool
 	inVendor() bool
 	inRamdisk() bool
@@ -847,11 +942,25 @@ type Module struct {
 	objFiles android.Paths
 	// Tidy .tidy file output paths for this compilation module
 	tidyFiles android.Paths
+	// Static analyzer .plist report paths for this compilation module
+	analyzerFiles android.Paths
 
 	// For apex variants, this is set as apex.min_sdk_version
 	apexSdkVersion android.ApiLevel
 
 	hideApexVariantFromMake bool
+
+	// Libraries this recovery or vendor ramdisk variant depends on that have no variant for its
+	// image, reported by imageDepsCheckMutator.
+	missingImageDeps []string
+
+	// Libraries this Linux host variant depends on that are not built against its libc, reported
+	// by hostLibcDepsCheckMutator.
+	missingHostLibcDeps []string
+
+	// Set by hostLibcDepsCheckMutator when a module that is built against the other libc than the
+	// one of the build OS depends on this variant.
+	hostLibcVariantNeeded bool
 }
 
 func (c *Module) AddJSONData(d *map[string]interface{}) {
@@ -1218,6 +1327,17 @@ func (c *Module) IsVendorPublicLibrary() bool {
 	return c.VendorProperties.IsVendorPublicLibrary
 }
 
+func (m *Module) NeedsVendorStubsVariants() bool {
+	lib := moduleLibraryInterface(m)
+	return lib != nil && lib.hasVendorStubs()
+}
+
+// IsVendorStubs returns true for the vendor variants of a library that sets
+// stubs.vendor_available.
+func (c *Module) IsVendorStubs() bool {
+	return c.VendorProperties.IsVendorStubs
+}
+
 func (c *Module) IsVndkPrebuiltLibrary() bool {
 	if _, ok := c.linker.(*vndkPrebuiltLibraryDecorator); ok {
 		return true
@@ -1579,6 +1699,10 @@ func (ctx *moduleContextImpl) IsVendorPublicLibrary() bool {
 	return ctx.mod.IsVendorPublicLibrary()
 }
 
+func (ctx *moduleContextImpl) IsVendorStubs() bool {
+	return ctx.mod.IsVendorStubs()
+}
+
 func (ctx *moduleContextImpl) mustUseVendorVariant() bool {
 	return ctx.mod.MustUseVendorVariant()
 }
@@ -1654,6 +1778,7 @@ func newModule(hod android.HostOrDeviceSupported, multilib android.Multilib) *Mo
 	module := newBaseModule(hod, multilib)
 	module.features = []feature{
 		&tidyFeature{},
+		&analyzerFeature{},
 	}
 	module.stl = &stl{}
 	module.sanitize = &sanitize{}
@@ -1701,6 +1826,15 @@ func (c *Module) IsTestPerSrcAllTestsVariation() bool {
 	return ok && test.isAllTestsVariation()
 }
 
+func (c *Module) TestMetadata() *android.TestMetadata {
+	if test, ok := c.linker.(*testBinary); ok && !c.IsTestPerSrcAllTestsVariation() {
+		return test.testMetadata()
+	}
+	return nil
+}
+
+var _ android.TestMetadataModule = (*Module)(nil)
+
 func (c *Module) DataPaths() []android.DataPath {
 	if p, ok := c.installer.(interface {
 		dataPaths() []android.DataPath
@@ -1745,7 +1879,7 @@ func GetSubnameProperty(actx android.ModuleContext, c LinkableInterface) string
 		subName += NativeBridgeSuffix
 	}
 
-	llndk := c.IsLlndk()
+	llndk := c.IsLlndk() || c.IsVendorStubs()
 	if llndk || (c.UseVndk() && c.HasNonSystemVariants()) {
 		// .vendor.{version} suffix is added for vendor variant or .product.{version} suffix is
 		// added for product variant only when we have vendor and product variants with core
@@ -1838,6 +1972,12 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		ctx.PropertyErrorf("clang", "false (GCC) is no longer supported")
 	}
 
+	if c.installer != nil {
+		if err := android.ValidateRelativeInstallPath(c.installer.relativeInstallPath()); err != nil {
+			ctx.PropertyErrorf("relative_install_path", "%s", err)
+		}
+	}
+
 	flags := Flags{
 		Toolchain: c.toolchain(ctx),
 		EmitXrefs: ctx.Config().EmitXrefRules(),
@@ -1886,6 +2026,12 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		flags.Local.CommonFlags = append(flags.Local.CommonFlags, "-isystem "+dir.String())
 	}
 
+	// The global flags and system include flags only depend on the toolchain and the variant, share
+	// them between all modules that use the same ones.
+	interner := getFlagSliceInterner(ctx.Config())
+	flags.Global = interner.internFlags(flags.Global)
+	flags.SystemIncludeFlags = interner.intern(flags.SystemIncludeFlags)
+
 	c.flags = flags
 	// We need access to all the flags seen by a source file.
 	if c.sabi != nil {
@@ -1903,6 +2049,7 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		c.kytheFiles = objs.kytheFiles
 		c.objFiles = objs.objFiles
 		c.tidyFiles = objs.tidyFiles
+		c.analyzerFiles = objs.analyzerFiles
 	}
 
 	if c.linker != nil {
@@ -2216,6 +2363,10 @@ func (c *Module) DepsMutator(actx android.BottomUpMutatorContext) {
 
 	deps := c.deps(ctx)
 
+	c.filterDepsMissingImageVariant(actx, &deps)
+	c.filterDepsMissingHostLibcVariant(actx, &deps)
+	c.linkSharedLibsStatically(actx, &deps)
+
 	c.Properties.AndroidMkSystemSharedLibs = deps.SystemSharedLibs
 
 	var snapshotInfo *SnapshotInfo
@@ -2375,9 +2526,21 @@ func (c *Module) DepsMutator(actx android.BottomUpMutatorContext) {
 
 	actx.AddVariationDependencies(nil, dataBinDepTag, deps.DataBins...)
 
-	actx.AddVariationDependencies([]blueprint.Variation{
+	runtimeLibVariations := []blueprint.Variation{
 		{Mutator: "link", Variation: "shared"},
-	}, runtimeDepTag, deps.RuntimeLibs...)
+	}
+	for _, lib := range deps.RuntimeLibs {
+		if ctx.Config().AllowMissingDependencies() && !actx.OtherModuleDependencyVariantExists(runtimeLibVariations, lib) {
+			// runtime_libs are not needed to build the module, so when missing dependencies are
+			// allowed a missing one shouldn't turn the module into an error rule.  Pass the name
+			// on to Make for device modules, which will warn about the missing required module.
+			if ctx.Device() {
+				c.Properties.AndroidMkRuntimeLibs = append(c.Properties.AndroidMkRuntimeLibs, lib)
+			}
+			continue
+		}
+		actx.AddVariationDependencies(runtimeLibVariations, runtimeDepTag, lib)
+	}
 
 	actx.AddDependency(c, genSourceDepTag, deps.GeneratedSources...)
 
@@ -2628,12 +2791,32 @@ func checkDoubleLoadableLibraries(ctx android.TopDownMutatorContext) {
 	}
 }
 
+// availableLibraryVariants returns a suffix for errors about a library dependency of the wrong
+// kind that lists the kinds of library that the dependency does provide.
+func availableLibraryVariants(ctx android.ModuleContext, dep android.Module) string {
+	var kinds []string
+	if ctx.OtherModuleHasProvider(dep, HeaderLibraryInfoProvider) {
+		kinds = append(kinds, "header")
+	}
+	if ctx.OtherModuleHasProvider(dep, SharedLibraryInfoProvider) {
+		kinds = append(kinds, "shared")
+	}
+	if ctx.OtherModuleHasProvider(dep, StaticLibraryInfoProvider) {
+		kinds = append(kinds, "static")
+	}
+	if len(kinds) == 0 {
+		return ", it does not provide any library variants"
+	}
+	return fmt.Sprintf(", it only provides %s library variants", strings.Join(kinds, " and "))
+}
+
 // Convert dependencies to paths.  Returns a PathDeps containing paths
 func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 	var depPaths PathDeps
 
 	var directStaticDeps []StaticLibraryInfo
 	var directSharedDeps []SharedLibraryInfo
+	directStaticLibsByName := make(map[string]android.Path)
 
 	reexportExporter := func(exporter FlagExporterInfo) {
 		depPaths.ReexportedDirs = append(depPaths.ReexportedDirs, exporter.IncludeDirs...)
@@ -2666,6 +2849,10 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			return
 		}
 
+		if depTag == android.InstallAfterDepTag {
+			return
+		}
+
 		ccDep, ok := dep.(LinkableInterface)
 		if !ok {
 
@@ -2675,6 +2862,11 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 				if genRule, ok := dep.(genrule.SourceFileGenerator); ok {
 					depPaths.GeneratedSources = append(depPaths.GeneratedSources,
 						genRule.GeneratedSourceFiles()...)
+					if dirGenerator, ok := dep.(genrule.SourceDirGenerator); ok {
+						dirs, fileLists := dirGenerator.GeneratedSourceDirs()
+						depPaths.GeneratedSourceDirs = append(depPaths.GeneratedSourceDirs, dirs...)
+						depPaths.GeneratedSourceDirFileLists = append(depPaths.GeneratedSourceDirFileLists, fileLists...)
+					}
 				} else {
 					ctx.ModuleErrorf("module %q is not a gensrcs or genrule", depName)
 				}
@@ -2706,7 +2898,7 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			return
 		}
 
-		if depTag == android.ProtoPluginDepTag {
+		if depTag == android.ProtoPluginDepTag || depTag == android.ProtoDepTag {
 			return
 		}
 
@@ -2749,6 +2941,13 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 
 			depExporterInfo := ctx.OtherModuleProvider(dep, FlagExporterInfoProvider).(FlagExporterInfo)
 
+			if depExporterInfo.MinCppStd != "" {
+				if depPaths.DepsMinCppStd == nil {
+					depPaths.DepsMinCppStd = make(map[string]string)
+				}
+				depPaths.DepsMinCppStd[depName] = depExporterInfo.MinCppStd
+			}
+
 			var ptr *android.Paths
 			var depPtr *android.Paths
 
@@ -2758,7 +2957,7 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			case libDepTag.header():
 				if !ctx.OtherModuleHasProvider(dep, HeaderLibraryInfoProvider) {
 					if !ctx.Config().AllowMissingDependencies() {
-						ctx.ModuleErrorf("module %q is not a header library", depName)
+						ctx.ModuleErrorf("module %q is not a header library%s", depName, availableLibraryVariants(ctx, dep))
 					} else {
 						ctx.AddMissingDependencies([]string{depName})
 					}
@@ -2767,7 +2966,7 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			case libDepTag.shared():
 				if !ctx.OtherModuleHasProvider(dep, SharedLibraryInfoProvider) {
 					if !ctx.Config().AllowMissingDependencies() {
-						ctx.ModuleErrorf("module %q is not a shared library", depName)
+						ctx.ModuleErrorf("module %q is not a shared library%s", depName, availableLibraryVariants(ctx, dep))
 					} else {
 						ctx.AddMissingDependencies([]string{depName})
 					}
@@ -2804,7 +3003,7 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			case libDepTag.static():
 				if !ctx.OtherModuleHasProvider(dep, StaticLibraryInfoProvider) {
 					if !ctx.Config().AllowMissingDependencies() {
-						ctx.ModuleErrorf("module %q is not a static library", depName)
+						ctx.ModuleErrorf("module %q is not a static library%s", depName, availableLibraryVariants(ctx, dep))
 					} else {
 						ctx.AddMissingDependencies([]string{depName})
 					}
@@ -2847,6 +3046,7 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 						// using transitive dependencies.
 						ptr = nil
 						directStaticDeps = append(directStaticDeps, staticLibraryInfo)
+						directStaticLibsByName[android.RemoveOptionalPrebuiltPrefix(depName)] = staticLibraryInfo.StaticLibrary
 					case lateLibraryDependency:
 						ptr = &depPaths.LateStaticLibs
 					default:
@@ -2919,6 +3119,10 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 				c.Properties.AndroidMkHeaderLibs = append(
 					c.Properties.AndroidMkHeaderLibs, makeLibName)
 			case libDepTag.shared():
+				if linksSharedLibsStatically(ctx) && dep.IsSkipInstall() {
+					ctx.ModuleErrorf("links %q as a shared library, but its %s variant is not installed",
+						depName, hostLibcName(ctx.Os()))
+				}
 				if lib := moduleLibraryInterface(dep); lib != nil {
 					if lib.buildStubs() && dep.(android.ApexModule).InAnyApex() {
 						// Add the dependency to the APEX(es) providing the library so that
@@ -2971,6 +3175,12 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 
 	// use the ordered dependencies as this module's dependencies
 	orderedStaticPaths, transitiveStaticLibs := orderStaticModuleDeps(directStaticDeps, directSharedDeps)
+	if linksSharedLibsStatically(ctx) {
+		// Link the libraries the shared libraries would have loaded as well, see
+		// linkSharedLibsStatically.
+		orderedStaticPaths = transitiveStaticLibs.ToList()
+	}
+	orderedStaticPaths = moveStaticLibsFirst(ctx, orderedStaticPaths, c.staticLibsFirst(), directStaticLibsByName)
 	depPaths.TranstiveStaticLibrariesForOrdering = transitiveStaticLibs
 	depPaths.StaticLibs = append(depPaths.StaticLibs, orderedStaticPaths...)
 
@@ -3118,6 +3328,25 @@ func orderStaticModuleDeps(staticDeps []StaticLibraryInfo, sharedDeps []SharedLi
 	return orderedStaticPaths, transitiveStaticLibs
 }
 
+// moveStaticLibsFirst moves the static libraries listed in the static_libs_first property to the
+// front of the ordered static dependencies, in the listed order.
+func moveStaticLibsFirst(ctx android.ModuleContext, ordered android.Paths, first []string,
+	staticLibsByName map[string]android.Path) android.Paths {
+	if len(first) == 0 {
+		return ordered
+	}
+	var front android.Paths
+	for _, name := range android.FirstUniqueStrings(first) {
+		if path, ok := staticLibsByName[name]; ok {
+			front = append(front, path)
+		} else if !ctx.Config().AllowMissingDependencies() {
+			ctx.PropertyErrorf("static_libs_first", "%q is not in static_libs", name)
+		}
+	}
+	rest, _ := android.FilterPathList(ordered, front)
+	return append(front, rest...)
+}
+
 // BaseLibName trims known prefixes and suffixes
 func BaseLibName(depName string) string {
 	libName := strings.TrimSuffix(depName, llndkLibrarySuffix)
@@ -3130,7 +3359,7 @@ func MakeLibName(ctx android.ModuleContext, c LinkableInterface, ccDep LinkableI
 	libName := BaseLibName(depName)
 	ccDepModule, _ := ccDep.(*Module)
 	isLLndk := ccDepModule != nil && ccDepModule.IsLlndk()
-	nonSystemVariantsExist := ccDep.HasNonSystemVariants() || isLLndk
+	nonSystemVariantsExist := ccDep.HasNonSystemVariants() || isLLndk || ccDep.IsVendorStubs()
 
 	if ccDepModule != nil {
 		// TODO(ivanlozano) Support snapshots for Rust-produced C library variants.
@@ -3219,11 +3448,75 @@ func (c *Module) OutputFiles(tag string) (android.Paths, error) {
 			return android.Paths{c.outputFile.Path()}, nil
 		}
 		return android.Paths{}, nil
+	case ".map":
+		if mapFile := c.linkerMapFile(); mapFile.Valid() {
+			return android.Paths{mapFile.Path()}, nil
+		}
+		return nil, fmt.Errorf("module does not generate a linker map file, set generate_map_file: true")
+	case ".version_script":
+		if versionScript := c.generatedVersionScript(); versionScript.Valid() {
+			return android.Paths{versionScript.Path()}, nil
+		}
+		return nil, fmt.Errorf("module does not generate a version script, set generate_version_script_from_headers: true")
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
 }
 
+// linkerMapFile returns the linker map file generated for this module, if any.
+func (c *Module) linkerMapFile() android.OptionalPath {
+	if m, ok := c.linker.(interface {
+		linkerMapFile() android.OptionalPath
+	}); ok {
+		return m.linkerMapFile()
+	}
+	return android.OptionalPath{}
+}
+
+// linkerGcSectionsReport returns the report of the sections removed by --gc-sections generated
+// for this module, if any.
+func (c *Module) linkerGcSectionsReport() android.OptionalPath {
+	if m, ok := c.linker.(interface {
+		linkerGcSectionsReport() android.OptionalPath
+	}); ok {
+		return m.linkerGcSectionsReport()
+	}
+	return android.OptionalPath{}
+}
+
+// linkerGcSectionsReportInputs returns the object files and static libraries of the link that
+// the report of the sections removed by --gc-sections refers to.
+func (c *Module) linkerGcSectionsReportInputs() android.Paths {
+	if m, ok := c.linker.(interface {
+		linkerGcSectionsReportInputs() android.Paths
+	}); ok {
+		return m.linkerGcSectionsReportInputs()
+	}
+	return nil
+}
+
+// staticLibsFirst returns the static libraries that are linked before the other static libraries
+// of this module, if any.
+func (c *Module) staticLibsFirst() []string {
+	if m, ok := c.linker.(interface {
+		staticLibsFirst() []string
+	}); ok {
+		return m.staticLibsFirst()
+	}
+	return nil
+}
+
+// generatedVersionScript returns the version script generated for this module from its exported
+// headers, if any.
+func (c *Module) generatedVersionScript() android.OptionalPath {
+	if l, ok := c.linker.(interface {
+		generatedVersionScriptFile() android.OptionalPath
+	}); ok {
+		return l.generatedVersionScriptFile()
+	}
+	return android.OptionalPath{}
+}
+
 func (c *Module) static() bool {
 	if static, ok := c.linker.(interface {
 		static() bool
@@ -3635,6 +3928,7 @@ func DefaultsFactory(props ...interface{}) android.Module {
 		&StripProperties{},
 		&InstallerProperties{},
 		&TidyProperties{},
+		&AnalyzerProperties{},
 		&CoverageProperties{},
 		&SAbiProperties{},
 		&VndkProperties{},
@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"android/soong/android"
+	"android/soong/etc"
 )
 
 func TestMain(m *testing.M) {
@@ -721,6 +722,22 @@ func TestDataLibs(t *testing.T) {
 	}
 }
 
+func TestInvalidRelativeInstallPath(t *testing.T) {
+	testCcError(t, `relative_install_path: invalid relative install path "../xbin": Path is outside directory: ../xbin`, `
+		cc_binary {
+			name: "test_bin",
+			relative_install_path: "../xbin",
+		}
+	`)
+
+	testCcError(t, `relative_install_path: invalid relative install path "/system/lib": Path is outside directory: /system/lib`, `
+		cc_library_shared {
+			name: "libtest",
+			relative_install_path: "/system/lib",
+		}
+	`)
+}
+
 func TestDataLibsRelativeInstallPath(t *testing.T) {
 	bp := `
 		cc_test_library {
@@ -779,6 +796,65 @@ func TestDataLibsRelativeInstallPath(t *testing.T) {
 	}
 }
 
+func TestHostDataPrebuiltEtcInstallPath(t *testing.T) {
+	bp := `
+		prebuilt_etc_host {
+			name: "foo.conf",
+			src: "foo.conf",
+			relative_install_path: "foo",
+		}
+
+		prebuilt_root_host {
+			name: "bar.conf",
+			src: "bar.conf",
+			relative_install_path: "share/bar",
+		}
+
+		prebuilt_root_host {
+			name: "baz.conf",
+			src: "baz.conf",
+		}
+
+		cc_test {
+			name: "main_test",
+			host_supported: true,
+			device_supported: false,
+			data: [
+				":foo.conf",
+				":bar.conf",
+				":baz.conf",
+				"qux.conf",
+			],
+			test_suites: ["general-tests"],
+			gtest: false,
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		etc.PrepareForTestWithPrebuiltEtc,
+		android.FixtureMergeMockFs(android.MockFS{
+			"foo.conf": nil,
+			"bar.conf": nil,
+			"baz.conf": nil,
+			"qux.conf": nil,
+		}),
+	).RunTestWithBp(t, bp)
+
+	module := result.ModuleForTests("main_test", result.Config.BuildOSTarget.String()).Module()
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, module)[0]
+	testData := entries.EntryMap["LOCAL_TEST_DATA"]
+	android.AssertIntEquals(t, "number of test data files", 4, len(testData))
+	android.AssertStringDoesContain(t, "etc data", testData[0], ":foo.conf:etc/foo")
+	android.AssertStringDoesContain(t, "root data", testData[1], ":bar.conf:share/bar")
+	if !strings.HasSuffix(testData[2], ":baz.conf") {
+		t.Errorf("expected root data without a sub dir to end with `:baz.conf`, but was %q", testData[2])
+	}
+	if !strings.HasSuffix(testData[3], ":qux.conf") {
+		t.Errorf("expected source data to end with `:qux.conf`, but was %q", testData[3])
+	}
+}
+
 func TestTestBinaryTestSuites(t *testing.T) {
 	bp := `
 		cc_test {
@@ -810,6 +886,44 @@ func TestTestBinaryTestSuites(t *testing.T) {
 	}
 }
 
+func TestTestBinaryPerTestcaseDirectory(t *testing.T) {
+	bp := `
+		cc_test {
+			name: "main_test",
+			srcs: ["main_test.cpp"],
+			data: ["data/a.txt"],
+			test_suites: ["general-tests"],
+			per_testcase_directory: true,
+			gtest: false,
+		}
+
+		cc_test {
+			name: "main_test_default",
+			srcs: ["main_test.cpp"],
+			data: ["data/a.txt"],
+			test_suites: ["general-tests"],
+			gtest: false,
+		}
+	`
+
+	ctx := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"data/a.txt": nil,
+		}),
+	).RunTestWithBp(t, bp).TestContext
+
+	entries := func(name string) android.AndroidMkEntries {
+		module := ctx.ModuleForTests(name, "android_arm_armv7-a-neon").Module()
+		return android.AndroidMkEntriesForTest(t, ctx, module)[0]
+	}
+
+	android.AssertDeepEquals(t, "per testcase data", []string{"main_test/data/a.txt"},
+		android.AndroidMkTestDataSuitePaths(entries("main_test"), "main_test"))
+	android.AssertDeepEquals(t, "default data", []string{"data/a.txt"},
+		android.AndroidMkTestDataSuitePaths(entries("main_test_default"), "main_test_default"))
+}
+
 func TestTestLibraryTestSuites(t *testing.T) {
 	bp := `
 		cc_test_library {
@@ -2780,6 +2894,92 @@ func TestLlndkLibrary(t *testing.T) {
 	checkExportedIncludeDirs("libllndk_with_override_headers", "android_vendor.29_arm64_armv8-a_shared", "include_llndk")
 }
 
+func TestLlndkHeaderDivergenceCheck(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"include/foo.h":              nil,
+			"include/bar/bar.h":          nil,
+			"include_llndk/foo.h":        nil,
+			"include_llndk/bar/bar.h":    nil,
+			"include_diverged/foo.h":     nil,
+			"include_diverged/extra.h":   nil,
+			"include_diverged/bar/bar.h": nil,
+		}),
+	).RunTestWithBp(t, `
+	cc_library {
+		name: "libllndk_matching",
+		llndk: {
+			symbol_file: "libllndk.map.txt",
+			override_export_include_dirs: ["include_llndk"],
+			check_divergence: true,
+		},
+		export_include_dirs: ["include"],
+	}
+
+	cc_library {
+		name: "libllndk_diverging",
+		llndk: {
+			symbol_file: "libllndk.map.txt",
+			override_export_include_dirs: ["include_diverged"],
+			check_divergence: true,
+		},
+		export_include_dirs: ["include"],
+	}
+
+	cc_library {
+		name: "libllndk_unchecked",
+		llndk: {
+			symbol_file: "libllndk.map.txt",
+			override_export_include_dirs: ["include_diverged"],
+		},
+		export_include_dirs: ["include"],
+	}
+
+	cc_library {
+		name: "libllndk_no_override",
+		llndk: {
+			symbol_file: "libllndk.map.txt",
+			check_divergence: true,
+		},
+		export_include_dirs: ["include"],
+	}
+	`)
+
+	variant := "android_vendor.29_arm64_armv8-a_shared"
+
+	checkDivergenceRule := func(module string, expectedIncludes string) {
+		t.Helper()
+		m := result.ModuleForTests(module, variant)
+		check := m.Rule("llndkHeaderDivergence")
+		android.AssertStringEquals(t, "implementation includes", "-Iinclude", check.Args["implIncludes"])
+		tu := android.ContentFromFileRuleForTests(t, m.Output("llndk_divergence/headers.cpp"))
+		android.AssertStringEquals(t, "translation unit", expectedIncludes, tu)
+
+		// Modules that depend on the LLNDK stubs wait for the check.
+		f := result.ModuleProvider(m.Module(), FlagExporterInfoProvider).(FlagExporterInfo)
+		android.AssertPathsRelativeToTopEquals(t, "exported deps",
+			[]string{"out/soong/.intermediates/" + module + "/" + variant + "/llndk_divergence/check.timestamp"},
+			f.Deps)
+	}
+
+	checkDivergenceRule("libllndk_matching", "#include \"bar/bar.h\"\n#include \"foo.h\"\n")
+	checkDivergenceRule("libllndk_diverging", "#include \"bar/bar.h\"\n#include \"extra.h\"\n#include \"foo.h\"\n")
+
+	android.AssertStringEquals(t, "llndk includes of libllndk_matching", "-Iinclude_llndk",
+		result.ModuleForTests("libllndk_matching", variant).Rule("llndkHeaderDivergence").Args["llndkIncludes"])
+	android.AssertStringEquals(t, "llndk includes of libllndk_diverging", "-Iinclude_diverged",
+		result.ModuleForTests("libllndk_diverging", variant).Rule("llndkHeaderDivergence").Args["llndkIncludes"])
+
+	// The check is opt-in, and only applies to libraries with LLNDK specific headers.
+	for _, module := range []string{"libllndk_unchecked", "libllndk_no_override"} {
+		check := result.ModuleForTests(module, variant).MaybeRule("llndkHeaderDivergence")
+		if check.Rule != nil {
+			t.Errorf("expected no llndk header divergence check for %s", module)
+		}
+	}
+}
+
 func TestLlndkHeaders(t *testing.T) {
 	ctx := testCc(t, `
 	cc_library_headers {
@@ -2815,6 +3015,92 @@ func TestLlndkHeaders(t *testing.T) {
 	}
 }
 
+func TestVendorStubs(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("frameworks/native/libplatform/Android.bp", `
+			cc_library {
+				name: "libplatform",
+				srcs: ["foo.c"],
+				stubs: {
+					symbol_file: "libplatform.map.txt",
+					versions: ["29"],
+					vendor_available: true,
+				},
+				no_libcrt: true,
+				nocrt: true,
+			}
+		`),
+		android.FixtureAddFile("frameworks/native/libplatform/libplatform.map.txt", nil),
+	).RunTestWithBp(t, `
+		cc_library {
+			name: "libsystem",
+			shared_libs: ["libplatform"],
+			srcs: ["foo.c"],
+			no_libcrt: true,
+			nocrt: true,
+		}
+		cc_library {
+			name: "libvendor",
+			shared_libs: ["libplatform"],
+			vendor: true,
+			srcs: ["foo.c"],
+			no_libcrt: true,
+			nocrt: true,
+		}
+		cc_library {
+			name: "libvendor_versioned",
+			shared_libs: ["libplatform#29"],
+			vendor: true,
+			srcs: ["foo.c"],
+			no_libcrt: true,
+			nocrt: true,
+		}
+	`)
+
+	coreVariant := "android_arm64_armv8-a_shared"
+	vendorVariant := "android_vendor.29_arm64_armv8-a_shared"
+
+	actual := result.ModuleVariantsForTests("libplatform")
+	for i := 0; i < len(actual); i++ {
+		if !strings.HasPrefix(actual[i], "android_vendor.29_arm64_") {
+			actual = append(actual[:i], actual[i+1:]...)
+			i--
+		}
+	}
+	expected := []string{
+		"android_vendor.29_arm64_armv8-a_shared_29",
+		"android_vendor.29_arm64_armv8-a_shared_current",
+		"android_vendor.29_arm64_armv8-a_shared",
+	}
+	android.AssertArrayString(t, "vendor variants of libplatform", expected, actual)
+
+	params := result.ModuleForTests("libplatform", vendorVariant).Description("generate stubs")
+	android.AssertStringEquals(t, "api level of the vendor stubs", "current", params.Args["apiLevel"])
+	android.AssertStringEquals(t, "ndkstubgen flags of the vendor stubs", "--llndk", params.Args["flags"])
+
+	params = result.ModuleForTests("libplatform", vendorVariant+"_29").Description("generate stubs")
+	android.AssertStringEquals(t, "api level of the versioned vendor stubs", "29", params.Args["apiLevel"])
+
+	implementation := result.ModuleForTests("libplatform", coreVariant).Module().(*Module)
+	android.AssertBoolEquals(t, "core variant builds stubs", false, implementation.IsStubs())
+
+	// libsystem links against the implementation
+	libFlags := result.ModuleForTests("libsystem", coreVariant).Rule("ld").Args["libFlags"]
+	implPaths := GetOutputPaths(result.TestContext, coreVariant, []string{"libplatform"})
+	android.AssertStringDoesContain(t, "libFlags for libsystem", libFlags, implPaths[0].String())
+
+	// libvendor links against the vendor stubs
+	libFlags = result.ModuleForTests("libvendor", vendorVariant).Rule("ld").Args["libFlags"]
+	stubPaths := GetOutputPaths(result.TestContext, vendorVariant, []string{"libplatform"})
+	android.AssertStringDoesContain(t, "libFlags for libvendor", libFlags, stubPaths[0].String())
+
+	// libvendor_versioned links against the requested version of the vendor stubs
+	libFlags = result.ModuleForTests("libvendor_versioned", vendorVariant).Rule("ld").Args["libFlags"]
+	versionedStubPaths := GetOutputPaths(result.TestContext, vendorVariant+"_29", []string{"libplatform"})
+	android.AssertStringDoesContain(t, "libFlags for libvendor_versioned", libFlags, versionedStubPaths[0].String())
+}
+
 func checkRuntimeLibs(t *testing.T, expected []string, module *Module) {
 	actual := module.Properties.AndroidMkRuntimeLibs
 	if !reflect.DeepEqual(actual, expected) {
@@ -2977,6 +3263,79 @@ func TestRuntimeLibsNoVndk(t *testing.T) {
 	checkRuntimeLibs(t, []string{"liball_available", "libproduct1", "libproduct_vendor"}, module)
 }
 
+func TestExcludeRuntimeLibsForHost(t *testing.T) {
+	bp := `
+		cc_library {
+			name: "libdevice_only",
+			device_supported: true,
+			host_supported: false,
+		}
+
+		cc_library {
+			name: "libboth",
+			host_supported: true,
+			runtime_libs: ["libdevice_only"],
+			target: {
+				host: {
+					exclude_runtime_libs: ["libdevice_only"],
+				},
+			},
+		}
+	`
+	ctx := testCc(t, bp)
+
+	module := ctx.ModuleForTests("libboth", "android_arm64_armv8-a_shared").Module().(*Module)
+	checkRuntimeLibs(t, []string{"libdevice_only"}, module)
+
+	module = ctx.ModuleForTests("libboth", ctx.Config().BuildOSTarget.String()+"_shared").Module().(*Module)
+	checkRuntimeLibs(t, nil, module)
+}
+
+func TestMissingRuntimeLibsAllowMissingDependencies(t *testing.T) {
+	t.Parallel()
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			host_supported: true,
+			runtime_libs: ["libbar", "libmissing"],
+		}
+
+		cc_library_shared {
+			name: "libbar",
+			host_supported: true,
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.PrepareForTestWithAllowMissingDependencies,
+	).RunTestWithBp(t, bp)
+
+	// A missing runtime_libs entry doesn't need to stop the module from building, but it is still
+	// passed to Make for device modules.
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	android.AssertBoolEquals(t, "libfoo is an error rule", false,
+		libfoo.Output("libfoo.so").Rule == android.ErrorRule)
+	checkRuntimeLibs(t, []string{"libmissing", "libbar"}, libfoo.Module().(*Module))
+
+	hostVariant := result.Config.BuildOSTarget.String() + "_shared"
+	hostLibfoo := result.ModuleForTests("libfoo", hostVariant)
+	android.AssertBoolEquals(t, "host libfoo is an error rule", false,
+		hostLibfoo.Output("libfoo.so").Rule == android.ErrorRule)
+	checkRuntimeLibs(t, []string{"libbar"}, hostLibfoo.Module().(*Module))
+}
+
+func TestMissingRuntimeLibs(t *testing.T) {
+	t.Parallel()
+	testCcError(t, `"libfoo" depends on undefined module "libmissing"`, `
+		cc_library_shared {
+			name: "libfoo",
+			runtime_libs: ["libmissing"],
+		}
+	`)
+}
+
 func checkStaticLibs(t *testing.T, expected []string, module *Module) {
 	t.Helper()
 	actual := module.Properties.AndroidMkStaticLibs
@@ -3135,6 +3494,51 @@ func TestRecovery(t *testing.T) {
 	}
 }
 
+func TestRecoveryDependencyWithoutRecoveryVariant(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		bin      string
+		lib      string
+		expected string
+	}{
+		{
+			name:     "recovery",
+			bin:      "recovery: true",
+			lib:      "recovery_available: true",
+			expected: `(?s)module "bin" variant "android_recovery_arm64_armv8-a": "libfoo" depends on "libbar", which has no recovery variant\. Add ` + "`recovery_available: true`" + ` to "libbar"\. Dependency path:\nbin\{.*\n.*-> libfoo\{`,
+		},
+		{
+			name:     "vendor_ramdisk",
+			bin:      "vendor_ramdisk: true",
+			lib:      "vendor_ramdisk_available: true",
+			expected: `(?s)"libfoo" depends on "libbar", which has no vendor_ramdisk variant\. Add ` + "`vendor_ramdisk_available: true`" + ` to "libbar"\. Dependency path:\nbin\{.*\n.*-> libfoo\{`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prepareForCcTest.
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(tc.expected)).
+				RunTestWithBp(t, fmt.Sprintf(`
+					cc_binary {
+						name: "bin",
+						%s,
+						shared_libs: ["libfoo"],
+					}
+					cc_library_shared {
+						name: "libfoo",
+						%s,
+						shared_libs: ["libbar"],
+					}
+					cc_library_shared {
+						name: "libbar",
+					}
+				`, tc.bin, tc.lib))
+		})
+	}
+}
+
 func TestDataLibsPrebuiltSharedTestLibrary(t *testing.T) {
 	bp := `
 		cc_prebuilt_test_library_shared {
@@ -3284,6 +3688,53 @@ func TestStaticExecutable(t *testing.T) {
 	}
 }
 
+func TestStlNoneWithUnwind(t *testing.T) {
+	ctx := testCc(t, `
+		cc_library_shared {
+			name: "libunwinding",
+			srcs: ["foo.cpp"],
+			stl: "none",
+			unwind: true,
+		}
+
+		cc_library_shared {
+			name: "libnounwinding",
+			srcs: ["foo.cpp"],
+			stl: "none",
+		}`)
+
+	for _, variant := range []string{"android_arm64_armv8-a_shared", "android_arm_armv7-a-neon_shared"} {
+		t.Run(variant, func(t *testing.T) {
+			ld := ctx.ModuleForTests("libunwinding", variant).Rule("ld")
+			libFlags := ld.Args["libFlags"]
+			if !strings.Contains(libFlags, "libunwind.a") {
+				t.Errorf("libunwind.a was not found in %q", libFlags)
+			}
+			android.AssertStringDoesContain(t, "ldFlags", ld.Args["ldFlags"], "-Wl,--exclude-libs,libunwind.a")
+			if strings.Contains(libFlags, "libc++") {
+				t.Errorf("libc++ was found in %q", libFlags)
+			}
+
+			ld = ctx.ModuleForTests("libnounwinding", variant).Rule("ld")
+			libFlags = ld.Args["libFlags"]
+			if strings.Contains(libFlags, "libunwind.a") {
+				t.Errorf("libunwind.a was found in %q", libFlags)
+			}
+			android.AssertStringDoesNotContain(t, "ldFlags", ld.Args["ldFlags"], "--exclude-libs,libunwind.a")
+		})
+	}
+}
+
+func TestUnwindRequiresStlNone(t *testing.T) {
+	testCcError(t, `can only be set with stl: "none"`, `
+		cc_library_shared {
+			name: "libunwinding",
+			srcs: ["foo.cpp"],
+			stl: "libc++",
+			unwind: true,
+		}`)
+}
+
 func TestStaticDepsOrderWithStubs(t *testing.T) {
 	ctx := testCc(t, `
 		cc_binary {
@@ -3513,6 +3964,51 @@ func TestEmptyWholeStaticLibsAllowMissingDependencies(t *testing.T) {
 	android.AssertStringListContains(t, "libfoo.a dependencies", libfoo.Inputs.Strings(), libbar.Output.String())
 }
 
+func TestMissingVariantAllowMissingDependencies(t *testing.T) {
+	t.Parallel()
+	bp := `
+		cc_library_static {
+			name: "libfoo",
+			whole_static_libs: ["libbar", "libmissing"],
+		}
+
+		cc_library_shared {
+			name: "libbar",
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.PrepareForTestWithAllowMissingDependencies,
+	).RunTestWithBp(t, bp)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_static").Output("libfoo.a")
+	android.AssertDeepEquals(t, "libfoo rule", android.ErrorRule, libfoo.Rule)
+
+	android.AssertStringDoesContain(t, "libfoo error", libfoo.Args["error"],
+		"module libbar exists but does not have the requested variant")
+	// libbar only has shared variants.
+	android.AssertStringDoesContain(t, "libfoo error", libfoo.Args["error"],
+		"available variants:\n  {os:android,")
+	android.AssertStringDoesContain(t, "libfoo error", libfoo.Args["error"], "link:shared}")
+	android.AssertStringDoesNotContain(t, "libfoo error", libfoo.Args["error"],
+		"module libmissing exists")
+}
+
+func TestMisspelledDependency(t *testing.T) {
+	t.Parallel()
+	testCcError(t, `"libbar" depends on undefined module "libfooo"\nDid you mean "libfoo"\?`, `
+		cc_library {
+			name: "libfoo",
+		}
+
+		cc_library {
+			name: "libbar",
+			shared_libs: ["libfooo"],
+		}
+	`)
+}
+
 func TestInstallSharedLibs(t *testing.T) {
 	bp := `
 		cc_binary {
@@ -4196,3 +4692,140 @@ func TestIncludeDirectoryOrdering(t *testing.T) {
 	}
 
 }
+
+func TestFlagSliceInterning(t *testing.T) {
+	result := prepareForCcTest.RunTestWithBp(t, `
+		cc_library_static {
+			name: "libfoo",
+			srcs: ["foo.c"],
+		}
+
+		cc_library_static {
+			name: "libbar",
+			srcs: ["bar.c"],
+			cflags: ["-DBAR"],
+		}
+	`)
+
+	variant := "android_arm64_armv8-a_static"
+	libfoo := result.ModuleForTests("libfoo", variant).Module().(*Module)
+	libbar := result.ModuleForTests("libbar", variant).Module().(*Module)
+
+	fooFlags := libfoo.flags.Global.CommonFlags
+	barFlags := libbar.flags.Global.CommonFlags
+	android.AssertArrayString(t, "global common flags", fooFlags, barFlags)
+	if len(fooFlags) == 0 || &fooFlags[0] != &barFlags[0] {
+		t.Errorf("expected global common flags of libfoo and libbar to share a backing array")
+	}
+
+	// Appending to an interned slice must not write into the shared backing array.
+	original := append([]string(nil), barFlags...)
+	_ = append(fooFlags, "-DLEAKED")
+	android.AssertArrayString(t, "global common flags after append", original, barFlags)
+
+	stats := android.GetFlagSliceInternStats(result.Config)
+	if stats.Hits() == 0 || stats.Hits() > stats.Lookups() {
+		t.Errorf("unexpected interning stats: %d hits, %d lookups", stats.Hits(), stats.Lookups())
+	}
+}
+
+func TestFlagSliceInternerCopiesInput(t *testing.T) {
+	config := android.TestConfig(t.TempDir(), nil, "", nil)
+	interner := getFlagSliceInterner(config)
+
+	input := []string{"-DFOO", "-DBAR"}
+	interned := interner.intern(input)
+	input[0] = "-DLEAKED"
+
+	android.AssertArrayString(t, "interned flags", []string{"-DFOO", "-DBAR"}, interned)
+	android.AssertIntEquals(t, "interned capacity", len(interned), cap(interned))
+
+	again := interner.intern([]string{"-DFOO", "-DBAR"})
+	if &again[0] != &interned[0] {
+		t.Errorf("expected identical flags to share a backing array")
+	}
+	if other := interner.intern([]string{"-DFOO"}); &other[0] == &interned[0] {
+		t.Errorf("expected different flags not to share a backing array")
+	}
+}
+
+func TestStaticAnalyzer(t *testing.T) {
+	bp := `
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.c", "bar.cpp", "baz.S"],
+			static_analyzer: true,
+		}
+
+		cc_library {
+			name: "libbar",
+			srcs: ["bar.c"],
+		}
+
+		cc_library {
+			name: "libbaz",
+			srcs: ["baz.c"],
+			static_analyzer: false,
+		}
+	`
+
+	prepareForStaticAnalyzerTest := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterSingletonType("static_analyzer_phony_targets", StaticAnalyzerPhonySingleton)
+		}),
+	)
+
+	t.Run("per module", func(t *testing.T) {
+		result := prepareForStaticAnalyzerTest.RunTestWithBp(t, bp)
+
+		libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+		for _, src := range []string{"foo", "bar"} {
+			analyzer := libfoo.Output("out/soong/analysis/libfoo/android_arm64_armv8-a_shared/obj/" + src + ".plist")
+			obj := libfoo.Output("obj/" + src + ".o")
+			android.AssertStringDoesContain(t, "analyzer command", analyzer.RuleParams.Command, "--analyze --analyzer-output plist")
+			android.AssertStringEquals(t, "analyzer cFlags", obj.Args["cFlags"], analyzer.Args["cFlags"])
+			android.AssertStringEquals(t, "analyzer ccCmd", obj.Args["ccCmd"], analyzer.Args["ccCmd"])
+			android.AssertStringListContains(t, "analyzer implicits",
+				android.PathsRelativeToTop(analyzer.Implicits), android.PathRelativeToTop(obj.Output))
+		}
+		android.AssertBoolEquals(t, "assembly is not analyzed", false,
+			libfoo.MaybeOutput("out/soong/analysis/libfoo/android_arm64_armv8-a_shared/obj/baz.plist").Rule != nil)
+
+		for _, name := range []string{"libbar", "libbaz"} {
+			module := result.ModuleForTests(name, "android_arm64_armv8-a_shared")
+			android.AssertBoolEquals(t, name+" is not analyzed", false, module.MaybeRule("clangAnalyzer").Rule != nil)
+		}
+
+		phonies := result.Phonies()
+		android.AssertStringListContains(t, "analyze-libfoo",
+			android.PathsRelativeToTop(phonies["analyze-libfoo"]),
+			"out/soong/analysis/libfoo/android_arm64_armv8-a_shared/obj/foo.plist")
+		android.AssertStringListContains(t, "analyze-libfoo",
+			android.PathsRelativeToTop(phonies["analyze-libfoo"]),
+			"out/soong/analysis/libfoo/android_arm64_armv8-a_static/obj/foo.plist")
+		if _, ok := phonies["analyze-libbar"]; ok {
+			t.Errorf("unexpected phony analyze-libbar")
+		}
+	})
+
+	t.Run("global", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			prepareForStaticAnalyzerTest,
+			android.FixtureMergeEnv(map[string]string{
+				"WITH_STATIC_ANALYZER": "true",
+			}),
+		).RunTestWithBp(t, bp)
+
+		libbar := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+		libbar.Output("out/soong/analysis/libbar/android_arm64_armv8-a_shared/obj/bar.plist")
+
+		libbaz := result.ModuleForTests("libbaz", "android_arm64_armv8-a_shared")
+		android.AssertBoolEquals(t, "libbaz is not analyzed", false, libbaz.MaybeRule("clangAnalyzer").Rule != nil)
+
+		phonies := result.Phonies()
+		android.AssertStringListContains(t, "analyze-libbar",
+			android.PathsRelativeToTop(phonies["analyze-libbar"]),
+			"out/soong/analysis/libbar/android_arm64_armv8-a_shared/obj/bar.plist")
+	})
+}
@@ -46,12 +46,26 @@ type BaseCompilerProperties struct {
 	Tidy_timeout_srcs []string `android:"path,arch_variant"`
 
 	// list of source files that should not be used to build the C/C++ module.
-	// This is most useful in the arch/multilib variants to remove non-common files
+	// This is most useful in the arch/multilib variants to remove non-common files.
+	// File and glob patterns are also matched against the paths of files generated by
+	// modules referenced in srcs, relative to the generating module's output directory.
 	Exclude_srcs []string `android:"path,arch_variant"`
 
 	// list of module-specific flags that will be used for C and C++ compiles.
 	Cflags []string `android:"arch_variant"`
 
+	// the default visibility of the symbols defined by the C/C++ sources, either "default" or
+	// "hidden".  When set to "hidden" only symbols explicitly annotated with
+	// __attribute__((visibility("default"))) are exported, which is equivalent to passing
+	// -fvisibility=hidden and -fvisibility-inlines-hidden.  Defaults to "default".
+	Cflags_visibility *string `android:"arch_variant"`
+
+	// when true, calls to APIs that are newer than the min_sdk_version of a module with
+	// sdk_version set are only warnings, even in the directories listed in
+	// UnguardedAvailabilityErrorProjects where they are errors by default.  Such calls should be
+	// guarded with __builtin_available instead.
+	Allow_unguarded_availability *bool
+
 	// list of module-specific flags that will be used for C++ compiles
 	Cppflags []string `android:"arch_variant"`
 
@@ -110,7 +124,8 @@ type BaseCompilerProperties struct {
 
 	// C++ standard version to use. Can be a specific version (such as
 	// "gnu++11"), "experimental" (which will use draft versions like C++1z when
-	// available), or the empty string (which will use the default).
+	// available), or the empty string (which will use the default). The version
+	// must be supported by the toolchain.
 	Cpp_std *string
 
 	// if set to false, use -std=c++* instead of -std=gnu++*
@@ -132,6 +147,11 @@ type BaseCompilerProperties struct {
 
 		// list of flags that will be passed to the AIDL compiler
 		Flags []string
+
+		// whether to add the root directory of each of the srcs, e.g. the path of the filegroup
+		// that provides them, to the aidl include paths.  The root directories of the aidl srcs
+		// are always added.
+		Include_dirs_from_srcs *bool
 	}
 
 	Renderscript struct {
@@ -285,6 +305,19 @@ func (compiler *baseCompiler) compilerDeps(ctx DepsContext, deps Deps) Deps {
 	return deps
 }
 
+// The cflags that make calls to APIs newer than the min_sdk_version of a module an error unless they
+// are guarded with __builtin_available.
+var unguardedAvailabilityCflags = []string{
+	"-Werror=unguarded-availability",
+	"-D__ANDROID_UNAVAILABLE_SYMBOLS_ARE_WEAK__",
+}
+
+// Return true if the module is in the UnguardedAvailabilityErrorProjects.
+func unguardedAvailabilityIsError(subdir string) bool {
+	subdir += "/"
+	return android.HasAnyPrefix(subdir, config.UnguardedAvailabilityErrorProjects)
+}
+
 // Return true if the module is in the WarningAllowedProjects.
 func warningsAreAllowed(subdir string) bool {
 	subdir += "/"
@@ -315,6 +348,24 @@ func parseCppStd(cppStdPtr *string) string {
 	}
 }
 
+// cppStdVersionIndex returns the index of the C++ standard version, such as "gnu++17", in
+// config.CppStdVersions, so that a lower index is an older version, or -1 if the toolchain
+// doesn't support it.
+func cppStdVersionIndex(cppStd string) int {
+	var version string
+	if strings.HasPrefix(cppStd, "gnu++") {
+		version = strings.TrimPrefix(cppStd, "gnu++")
+	} else if strings.HasPrefix(cppStd, "c++") {
+		version = strings.TrimPrefix(cppStd, "c++")
+	} else {
+		return -1
+	}
+	if alias, ok := config.CppStdVersionAliases[version]; ok {
+		version = alias
+	}
+	return android.IndexList(version, config.CppStdVersions)
+}
+
 func parseCStd(cStdPtr *string) string {
 	cStd := String(cStdPtr)
 	switch cStd {
@@ -343,8 +394,18 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 		flags.Local.CommonFlags = append(flags.Local.CommonFlags, "-I" + additionalIncludeDirs)
 	}
 
-	compiler.srcsBeforeGen = android.PathsForModuleSrcExcludes(ctx, compiler.Properties.Srcs, compiler.Properties.Exclude_srcs)
+	compiler.srcsBeforeGen = android.PathsRelativeToModuleSourceDir(android.SourceInput{
+		Context:               ctx,
+		Paths:                 compiler.Properties.Srcs,
+		ExcludePaths:          compiler.Properties.Exclude_srcs,
+		IncludeDirs:           true,
+		ExcludeGeneratedPaths: true,
+	})
 	compiler.srcsBeforeGen = append(compiler.srcsBeforeGen, deps.GeneratedSources...)
+	for i, dir := range deps.GeneratedSourceDirs {
+		compiler.srcsBeforeGen = append(compiler.srcsBeforeGen,
+			genSourceDirSources(ctx, i, dir, deps.GeneratedSourceDirFileLists[i])...)
+	}
 
 	CheckBadCompilerFlags(ctx, "cflags", compiler.Properties.Cflags)
 	CheckBadCompilerFlags(ctx, "cppflags", compiler.Properties.Cppflags)
@@ -364,6 +425,15 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 	flags.Local.AsFlags = append(flags.Local.AsFlags, esc(compiler.Properties.Asflags)...)
 	flags.Local.YasmFlags = append(flags.Local.YasmFlags, esc(compiler.Properties.Asflags)...)
 
+	switch visibility := String(compiler.Properties.Cflags_visibility); visibility {
+	case "", "default":
+	case "hidden":
+		flags.Local.CFlags = append(flags.Local.CFlags, "-fvisibility=hidden")
+		flags.Local.CppFlags = append(flags.Local.CppFlags, "-fvisibility-inlines-hidden")
+	default:
+		ctx.PropertyErrorf("cflags_visibility", "must be \"default\" or \"hidden\", found %q", visibility)
+	}
+
 	flags.Yacc = compiler.Properties.Yacc
 	flags.Lex = compiler.Properties.Lex
 
@@ -468,6 +538,14 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 	}
 
 	flags.Global.CFlags = append(flags.Global.CFlags, target)
+	// The target above sets the API level that clang checks the availability annotations of the
+	// NDK headers against, calling newer APIs without a __builtin_available guard crashes on older
+	// devices.  The NDK headers only annotate the APIs with their availability, and declare them
+	// weak so that guarded calls link, when __ANDROID_UNAVAILABLE_SYMBOLS_ARE_WEAK__ is defined.
+	if ctx.useSdk() && !Bool(compiler.Properties.Allow_unguarded_availability) &&
+		unguardedAvailabilityIsError(ctx.ModuleDir()) {
+		flags.Local.CFlags = append(flags.Local.CFlags, unguardedAvailabilityCflags...)
+	}
 	flags.Global.AsFlags = append(flags.Global.AsFlags, target)
 	flags.Global.LdFlags = append(flags.Global.LdFlags, target)
 
@@ -515,6 +593,21 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 	flags.Local.ConlyFlags = append([]string{"-std=" + cStd}, flags.Local.ConlyFlags...)
 	flags.Local.CppFlags = append([]string{"-std=" + cppStd}, flags.Local.CppFlags...)
 
+	cppStdIndex := cppStdVersionIndex(cppStd)
+	if cppStdIndex < 0 {
+		ctx.PropertyErrorf("cpp_std", "%q is not a C++ standard version supported by the toolchain", cppStd)
+	} else {
+		// Headers of dependencies that require a newer C++ standard often fail to compile with
+		// confusing template errors, report the modules that use an older one.
+		for _, dep := range android.SortedStringKeys(deps.DepsMinCppStd) {
+			minCppStd := deps.DepsMinCppStd[dep]
+			if cppStdIndex < cppStdVersionIndex(minCppStd) {
+				addToModuleList(ctx, modulesUsingLowerCppStdThanDepsKey,
+					fmt.Sprintf("%s:%s:%s:%s", ctx.ModuleName(), cppStd, dep, minCppStd))
+			}
+		}
+	}
+
 	if ctx.inVendor() {
 		flags.Local.CFlags = append(flags.Local.CFlags, esc(compiler.Properties.Target.Vendor.Cflags)...)
 	}
@@ -569,6 +662,10 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 			rootAidlIncludeDirs := android.PathsForSource(ctx, compiler.Properties.Aidl.Include_dirs)
 			flags.aidlFlags = append(flags.aidlFlags, includeDirsToFlags(rootAidlIncludeDirs))
 		}
+		// Add the root directories of the srcs so that aidl files provided by filegroups in
+		// other directories can import each other.
+		flags.aidlFlags = append(flags.aidlFlags, aidlIncludeFlagsFromSrcs(compiler.srcsBeforeGen,
+			Bool(compiler.Properties.Aidl.Include_dirs_from_srcs))...)
 
 		if Bool(compiler.Properties.Aidl.Generate_traces) {
 			flags.aidlFlags = append(flags.aidlFlags, "-t")
@@ -667,6 +764,8 @@ func (compiler *baseCompiler) compile(ctx ModuleContext, flags Flags, deps PathD
 
 	srcs := append(android.Paths(nil), compiler.srcsBeforeGen...)
 
+	android.SetProtoInfo(ctx, &compiler.Proto, srcs.FilterByExt(".proto"))
+
 	srcs, genDeps, info := genSources(ctx, srcs, buildFlags)
 	pathDeps = append(pathDeps, genDeps...)
 
@@ -15,6 +15,9 @@
 package cc
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"testing"
 
 	"android/soong/android"
@@ -44,3 +47,260 @@ func TestIsThirdParty(t *testing.T) {
 		}
 	}
 }
+
+func TestCppStdVersionIndex(t *testing.T) {
+	testCases := []struct {
+		cppStd string
+		want   int
+	}{
+		{"c++98", 0},
+		{"gnu++11", 2},
+		{"c++1z", 4},
+		{"gnu++17", 4},
+		{"c++2a", 5},
+		{"gnu++20", 5},
+		{"c++42", -1},
+		{"gnu17", -1},
+		{"experimental", -1},
+	}
+	for _, tc := range testCases {
+		android.AssertIntEquals(t, tc.cppStd, tc.want, cppStdVersionIndex(tc.cppStd))
+	}
+}
+
+func TestCppStdNotSupported(t *testing.T) {
+	testCases := []struct {
+		name string
+		bp   string
+		err  string
+	}{
+		{
+			name: "cpp_std",
+			bp: `
+				cc_library {
+					name: "libfoo",
+					cpp_std: "c++42",
+				}`,
+			err: `"c\+\+42" is not a C\+\+ standard version supported by the toolchain`,
+		},
+		{
+			name: "min_cpp_std",
+			bp: `
+				cc_library_headers {
+					name: "libfoo_headers",
+					min_cpp_std: "c++42",
+				}`,
+			err: `"c\+\+42" is not a C\+\+ standard version supported by the toolchain`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prepareForCcTest.
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(tc.err)).
+				RunTestWithBp(t, tc.bp)
+		})
+	}
+}
+
+func TestCppStdLowerThanDeps(t *testing.T) {
+	result := prepareForCcTest.RunTestWithBp(t, `
+		cc_library {
+			name: "libbar",
+			min_cpp_std: "c++2a",
+		}
+
+		cc_library_headers {
+			name: "libbar_headers",
+			min_cpp_std: "c++17",
+		}
+
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.cpp"],
+			cpp_std: "c++14",
+			shared_libs: ["libbar"],
+			header_libs: ["libbar_headers"],
+		}
+
+		cc_library {
+			name: "libbaz",
+			srcs: ["baz.cpp"],
+			cpp_std: "gnu++20",
+			shared_libs: ["libbar"],
+			header_libs: ["libbar_headers"],
+		}
+
+		cc_library {
+			name: "libqux",
+			srcs: ["qux.cpp"],
+			header_libs: ["libbar_headers"],
+		}
+	`)
+
+	var reported []string
+	getNamedMapForConfig(result.Config, modulesUsingLowerCppStdThanDepsKey).Range(
+		func(key, value interface{}) bool {
+			reported = append(reported, key.(string))
+			return true
+		})
+	sort.Strings(reported)
+
+	android.AssertDeepEquals(t, "modules using a lower cpp_std than their deps", []string{
+		"libfoo:c++14:libbar:c++2a",
+		"libfoo:c++14:libbar_headers:c++17",
+	}, reported)
+}
+
+func TestCflagsVisibility(t *testing.T) {
+	result := prepareForCcTest.RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libhidden",
+			srcs: ["foo.cpp"],
+			cflags_visibility: "hidden",
+		}
+
+		cc_library_shared {
+			name: "libdefault",
+			srcs: ["foo.cpp"],
+		}
+	`)
+
+	hidden := result.ModuleForTests("libhidden", "android_arm64_armv8-a_shared").Rule("cc").Args["cFlags"]
+	android.AssertStringDoesContain(t, "cflags", hidden, "-fvisibility=hidden")
+	android.AssertStringDoesContain(t, "cflags", hidden, "-fvisibility-inlines-hidden")
+
+	def := result.ModuleForTests("libdefault", "android_arm64_armv8-a_shared").Rule("cc").Args["cFlags"]
+	android.AssertStringDoesNotContain(t, "cflags", def, "-fvisibility=hidden")
+}
+
+func TestCflagsVisibilityInvalid(t *testing.T) {
+	prepareForCcTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`module "libfoo".*: cflags_visibility: must be "default" or "hidden", found "protected"`)).
+		RunTestWithBp(t, `
+			cc_library_shared {
+				name: "libfoo",
+				srcs: ["foo.cpp"],
+				cflags_visibility: "protected",
+			}
+		`)
+}
+
+func TestUnguardedAvailabilityError(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libsdk",
+			srcs: ["foo.cpp"],
+			sdk_version: "29",
+			min_sdk_version: "24",
+			stl: "none",
+		}
+
+		cc_library_shared {
+			name: "libsdk_allowed",
+			srcs: ["foo.cpp"],
+			sdk_version: "29",
+			min_sdk_version: "24",
+			stl: "none",
+			allow_unguarded_availability: true,
+		}
+
+		cc_library_shared {
+			name: "libplatform",
+			srcs: ["foo.cpp"],
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("packages/modules/foo/Android.bp", bp),
+		android.FixtureAddTextFile("external/bar/Android.bp", strings.Replace(bp, `name: "lib`, `name: "libbar_`, -1)),
+	).RunTest(t)
+
+	cflags := func(name, variant string) string {
+		return result.ModuleForTests(name, variant).Rule("cc").Args["cFlags"]
+	}
+	const sdkVariant = "android_arm64_armv8-a_sdk_shared"
+
+	testCases := []struct {
+		name             string
+		cflags           string
+		unguardedIsError bool
+	}{
+		{"libsdk", cflags("libsdk", sdkVariant), true},
+		{"libsdk_allowed", cflags("libsdk_allowed", sdkVariant), false},
+		// Outside of UnguardedAvailabilityErrorProjects calls to newer APIs are only warnings.
+		{"libbar_sdk", cflags("libbar_sdk", sdkVariant), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			android.AssertStringDoesContain(t, "target", tc.cflags, "-target aarch64-linux-android24")
+			fields := strings.Fields(tc.cflags)
+			for _, flag := range unguardedAvailabilityCflags {
+				if tc.unguardedIsError {
+					android.AssertStringListContains(t, "cflags", fields, flag)
+				} else {
+					android.AssertStringListDoesNotContain(t, "cflags", fields, flag)
+				}
+			}
+		})
+	}
+
+	platform := strings.Fields(cflags("libplatform", "android_arm64_armv8-a_shared"))
+	for _, flag := range unguardedAvailabilityCflags {
+		android.AssertStringListDoesNotContain(t, "libplatform cflags", platform, flag)
+	}
+}
+
+func TestExcludeSrcsGeneratedSources(t *testing.T) {
+	result := prepareForCcTest.RunTestWithBp(t, `
+		gensrcs {
+			name: "gen_srcs",
+			cmd: "cp $(in) $(out)",
+			srcs: ["a.in", "b.in"],
+			output_extension: "cpp",
+		}
+
+		cc_library_static {
+			name: "libfoo",
+			srcs: ["foo.cpp", ":gen_srcs"],
+			exclude_srcs: ["**/b.cpp"],
+		}
+	`)
+
+	ar := result.ModuleForTests("libfoo", "android_arm64_armv8-a_static").Output("libfoo.a")
+	var objs []string
+	for _, obj := range ar.Inputs {
+		objs = append(objs, obj.Base())
+	}
+	android.AssertDeepEquals(t, "objects", []string{"foo.o", "a.o"}, objs)
+}
+
+func TestExcludeSrcsGeneratedSourcesUnmatched(t *testing.T) {
+	bp := `
+		gensrcs {
+			name: "gen_srcs",
+			cmd: "cp $(in) $(out)",
+			srcs: ["a.in"],
+			output_extension: "cpp",
+		}
+
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.cpp", %s],
+			exclude_srcs: ["**/missing.cpp"],
+		}
+	`
+
+	// The pattern is reported once for all the variants of the module.
+	prepareForCcTest.ExtendWithErrorHandler(android.FixtureCustomErrorHandler(
+		func(t *testing.T, result *android.TestResult) {
+			android.AssertIntEquals(t, "errors", 1, len(result.Errs))
+			android.AssertStringDoesContain(t, "error", result.Errs[0].Error(),
+				`exclude pattern "**/missing.cpp" does not match any source or generated file`)
+		})).RunTestWithBp(t, fmt.Sprintf(bp, `":gen_srcs"`))
+
+	// Without module references in srcs exclude_srcs only applies to the source files.
+	prepareForCcTest.RunTestWithBp(t, fmt.Sprintf(bp, `"bar.cpp"`))
+}
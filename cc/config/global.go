@@ -284,6 +284,11 @@ var (
 	ExperimentalCStdVersion   = "gnu11"
 	ExperimentalCppStdVersion = "gnu++2a"
 
+	// The C++ standard versions supported by the toolchain, oldest first, without the "c++" or
+	// "gnu++" prefix. CppStdVersionAliases maps the draft names of a version to the final one.
+	CppStdVersions       = []string{"98", "03", "11", "14", "17", "20", "2b"}
+	CppStdVersionAliases = map[string]string{"0x": "11", "1y": "14", "1z": "17", "2a": "20"}
+
 	// prebuilts/clang default settings.
 	ClangDefaultBase         = "prebuilts/clang/host"
 	ClangDefaultVersion      = "clang-r450784d"
@@ -297,6 +302,13 @@ var (
 
 	// Directories with warnings from Android.mk files.
 	WarningAllowedOldProjects = []string{}
+
+	// Directories in which modules with sdk_version set fail to build when they call APIs that
+	// are newer than their min_sdk_version without a __builtin_available guard, unless they set
+	// allow_unguarded_availability.
+	UnguardedAvailabilityErrorProjects = []string{
+		"packages/modules/",
+	}
 )
 
 // BazelCcToolchainVars generates bzl file content containing variables for
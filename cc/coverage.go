@@ -25,6 +25,9 @@ import (
 const profileInstrFlag = "-fprofile-instr-generate=/data/misc/trace/clang-%p-%m.profraw"
 
 type CoverageProperties struct {
+	// Whether to build a coverage variant of the module when native coverage is enabled.
+	// Defaults to true.  Modules with native_coverage: false are never instrumented and link
+	// against the uninstrumented variants of their static libraries.
 	Native_coverage *bool
 
 	NeedCoverageVariant bool `blueprint:"mutated"`
@@ -194,6 +197,11 @@ func SetCoverageProperties(ctx android.BaseModuleContext, properties CoveragePro
 	if moduleTypeHasCoverage {
 		// Check if Native_coverage is set to false.  This property defaults to true.
 		needCoverageVariant = BoolDefault(properties.Native_coverage, true)
+		if ctx.DeviceConfig().NativeCoverageNeverEnabledForPath(ctx.ModuleDir()) {
+			// Modules in these paths, e.g. boot critical libraries, must not end up with any
+			// instrumented code, including that of their static libraries.
+			needCoverageVariant = false
+		}
 		if useSdk && sdkVersion != "current" {
 			// Native coverage is not supported for SDK versions < 23
 			if fromApi, err := strconv.Atoi(sdkVersion); err == nil && fromApi < 23 {
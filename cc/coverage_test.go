@@ -0,0 +1,118 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+var prepareForCcCoverageTest = android.GroupFixturePreparers(
+	prepareForCcTest,
+	android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+		variables.ClangCoverage = BoolPtr(true)
+		variables.Native_coverage = BoolPtr(true)
+		variables.NativeCoveragePaths = []string{"*"}
+		variables.NativeCoverageNeverPaths = []string{"system/core"}
+	}),
+)
+
+func TestNativeCoverageExclusion(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForCcCoverageTest,
+		android.FixtureAddTextFile("system/core/Android.bp", `
+			cc_binary {
+				name: "bin_never",
+				srcs: ["foo.c"],
+				static_libs: ["libcov"],
+			}
+		`),
+		android.FixtureAddFile("system/core/foo.c", nil),
+	).RunTestWithBp(t, `
+		cc_library_static {
+			name: "libcov",
+			srcs: ["foo.c"],
+		}
+
+		cc_library_static {
+			name: "libnocov",
+			srcs: ["foo.c"],
+			native_coverage: false,
+			whole_static_libs: ["libcov"],
+		}
+
+		cc_binary {
+			name: "bin",
+			srcs: ["foo.c"],
+			static_libs: ["libcov", "libnocov"],
+		}
+
+		cc_binary {
+			name: "bin_nocov",
+			srcs: ["foo.c"],
+			static_libs: ["libcov"],
+			native_coverage: false,
+		}
+	`)
+
+	compileFlags := []string{profileInstrFlag, "-fcoverage-mapping"}
+
+	assertInstrumented := func(name, variant string) {
+		t.Helper()
+		cFlags := result.ModuleForTests(name, variant).Rule("cc").Args["cFlags"]
+		for _, flag := range compileFlags {
+			android.AssertStringDoesContain(t, name+" cflags", cFlags, flag)
+		}
+	}
+
+	assertNotInstrumented := func(name, variant string) {
+		t.Helper()
+		if android.InList(variant+"_cov", result.ModuleVariantsForTests(name)) {
+			t.Errorf("coverage variant created for %q with native coverage disabled", name)
+		}
+		cFlags := result.ModuleForTests(name, variant).Rule("cc").Args["cFlags"]
+		for _, flag := range compileFlags {
+			android.AssertStringDoesNotContain(t, name+" cflags", cFlags, flag)
+		}
+	}
+
+	staticVariant := "android_arm64_armv8-a_static"
+	binaryVariant := "android_arm64_armv8-a"
+
+	libcov := result.ModuleForTests("libcov", staticVariant).Output("libcov.a").Output.String()
+	libcovCov := result.ModuleForTests("libcov", staticVariant+"_cov").Output("libcov.a").Output.String()
+
+	assertInstrumented("libcov", staticVariant+"_cov")
+	assertNotInstrumented("libnocov", staticVariant)
+	assertNotInstrumented("bin_nocov", binaryVariant)
+	assertNotInstrumented("bin_never", binaryVariant)
+
+	// The instrumented binary links the instrumented variant of libcov and gets the profile
+	// runtime.
+	assertInstrumented("bin", binaryVariant+"_cov")
+	binLd := result.ModuleForTests("bin", binaryVariant+"_cov").Rule("ld")
+	android.AssertStringDoesContain(t, "bin ldflags", binLd.Args["ldFlags"], profileInstrFlag)
+	android.AssertStringDoesContain(t, "bin libflags", binLd.Args["libFlags"], libcovCov)
+
+	// Modules with native coverage disabled only see the uninstrumented variants of their static
+	// libraries, so they don't need the profile runtime either.
+	for _, name := range []string{"bin_nocov", "bin_never"} {
+		ld := result.ModuleForTests(name, binaryVariant).Rule("ld")
+		android.AssertStringDoesNotContain(t, name+" ldflags", ld.Args["ldFlags"], profileInstrFlag)
+		android.AssertStringDoesContain(t, name+" libflags", ld.Args["libFlags"], libcov)
+		android.AssertStringDoesNotContain(t, name+" libflags", ld.Args["libFlags"], libcovCov)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"android/soong/android"
+	"android/soong/cc/config"
+	"android/soong/genrule"
+)
+
+func init() {
+	android.RegisterModuleType("cc_external_build", ExternalBuildFactory)
+}
+
+type ExternalBuildProperties struct {
+	// Directory, relative to the module directory, containing the sources of the external
+	// project.  Every file under it is an input to the build.
+	Src_dir *string
+
+	// Static libraries built by the command, relative to the output directory.  Each one is
+	// exposed as a cc_prebuilt_library_static module named after the file without its
+	// extension, which exports the headers in export_include_dirs.
+	Static_libs []string
+
+	// Shared libraries built by the command, relative to the output directory.  Each one is
+	// exposed as a cc_prebuilt_library_shared module named after the file without its
+	// extension, which exports the headers in export_include_dirs.
+	Shared_libs []string
+}
+
+// cc_external_build runs the configure and build steps of a project that uses its own build
+// system, for example autotools or cmake, and exposes the libraries and headers it produces to
+// other cc modules.  The cmd is run from the root of an sbox sandbox that only contains the files
+// under src_dir, the tools and the clang toolchain, and only the files listed in out, static_libs
+// and shared_libs are kept.  The libraries listed in static_libs and shared_libs are exposed as
+// prebuilt library modules that can be used in the static_libs or shared_libs of other cc modules,
+// and the headers can also be used by listing the module in generated_headers.
+//
+// In addition to the variables supported by cc_genrule, the cmd supports $(clang_path <file>),
+// the path in the sandbox of a file relative to the root of the clang toolchain, and the following
+// environment variables are exported when the command executes:
+//
+//   CC, CXX, AR       the clang, clang++ and llvm-ar from the cc toolchain.
+//
+//   CLANG_TRIPLE      the target triple of the architecture the command is being executed for.
+//
+//   SRC_DIR           the path to src_dir.
+func ExternalBuildFactory() android.Module {
+	module := genrule.NewGenRule()
+
+	extra := &GenruleExtraProperties{}
+	props := &ExternalBuildProperties{}
+	module.Extra = extra
+	module.ImageInterface = extra
+	module.CmdModifier = func(ctx android.ModuleContext, cmd string) string {
+		return externalBuildCmdModifier(ctx, props, cmd)
+	}
+	module.ExtraVariables = externalBuildExtraVariables
+	module.SandboxInputs = true
+	module.AddProperties(module.Extra, props)
+
+	android.AddLoadHook(module, func(ctx android.LoadHookContext) {
+		if props.Src_dir == nil {
+			ctx.PropertyErrorf("src_dir", "missing src_dir")
+			return
+		}
+		ctx.AppendProperties(&struct {
+			Srcs []string
+			Out  []string
+		}{
+			Srcs: []string{filepath.Join(String(props.Src_dir), "**/*")},
+			Out:  append(append([]string(nil), props.Static_libs...), props.Shared_libs...),
+		})
+		createExternalBuildLibraries(ctx, props.Static_libs, PrebuiltStaticLibraryFactory)
+		createExternalBuildLibraries(ctx, props.Shared_libs, PrebuiltSharedLibraryFactory)
+	})
+
+	android.InitAndroidArchModule(module, android.HostAndDeviceSupported, android.MultilibBoth)
+
+	android.InitApexModule(module)
+
+	return module
+}
+
+// createExternalBuildLibraries creates a prebuilt library module for each of the libraries built
+// by the cc_external_build module, so that the libraries are linked and installed like any other
+// cc library.
+func createExternalBuildLibraries(ctx android.LoadHookContext, libs []string, factory android.ModuleFactory) {
+	for _, lib := range libs {
+		base := filepath.Base(lib)
+		ctx.CreateModule(factory, &struct {
+			Name                     *string
+			Srcs                     []string
+			Export_generated_headers []string
+		}{
+			Name:                     StringPtr(strings.TrimSuffix(base, filepath.Ext(base))),
+			Srcs:                     []string{fmt.Sprintf(":%s{%s}", ctx.ModuleName(), lib)},
+			Export_generated_headers: []string{ctx.ModuleName()},
+		})
+	}
+}
+
+// externalBuildToolchain returns the files of the clang toolchain that are copied into the
+// sandbox: the tools exported to the command, the libraries they link against and the resource
+// directory with the builtin headers and runtime libraries.
+func externalBuildToolchain(ctx android.ModuleContext) android.Paths {
+	files := android.Paths{
+		config.ClangPath(ctx, "bin/clang"),
+		config.ClangPath(ctx, "bin/clang++"),
+		config.ClangPath(ctx, "bin/llvm-ar"),
+		config.ClangPath(ctx, "lib64/libc++.so.1"),
+	}
+	return append(files, ctx.GlobFiles(config.ClangPath(ctx, "lib64/clang/**/*").String(), nil)...)
+}
+
+// externalBuildExtraVariables expands the variables that are only supported in the command of a
+// cc_external_build.
+func externalBuildExtraVariables(ctx android.ModuleContext, cmd *android.RuleBuilderCommand, name string) (string, bool, error) {
+	if !strings.HasPrefix(name, "clang_path ") {
+		return "", false, nil
+	}
+	file := strings.TrimSpace(strings.TrimPrefix(name, "clang_path "))
+	path := config.ClangPath(ctx, file)
+	cmd.ImplicitTools(externalBuildToolchain(ctx))
+	cmd.ImplicitTool(path)
+	return cmd.PathForTool(path), true, nil
+}
+
+func externalBuildCmdModifier(ctx android.ModuleContext, props *ExternalBuildProperties, cmd string) string {
+	srcDir := android.PathForModuleSrc(ctx, String(props.Src_dir))
+
+	// The inputs are copied into the sandbox at the same relative paths and the command is run
+	// from the root of the sandbox, so the path to src_dir doesn't need to be translated.
+	toolchain := config.FindToolchainWithContext(ctx)
+	env := []string{
+		"CC=$(clang_path bin/clang)",
+		"CXX=$(clang_path bin/clang++)",
+		"AR=$(clang_path bin/llvm-ar)",
+		"CLANG_TRIPLE=" + toolchain.ClangTriple(),
+		"SRC_DIR=" + srcDir.String(),
+	}
+
+	return fmt.Sprintf("export %s && %s", strings.Join(env, " "), genruleCmdModifier(ctx, cmd))
+}
@@ -0,0 +1,164 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"strings"
+	"testing"
+
+	"android/soong/android"
+	"android/soong/genrule"
+)
+
+var prepareForExternalBuildTest = android.GroupFixturePreparers(
+	prepareForCcTest,
+	android.FixtureMergeMockFs(android.MockFS{
+		"external/ext/src/configure":     nil,
+		"external/ext/src/Makefile":      nil,
+		"external/ext/src/ext.c":         nil,
+		"external/ext/src/include/ext.h": nil,
+	}),
+)
+
+func TestExternalBuildEnv(t *testing.T) {
+	bp := `
+		cc_external_build {
+			name: "ext",
+			src_dir: "src",
+			cmd: "$${SRC_DIR}/configure --host=$${CLANG_TRIPLE} && make -C $(genDir)",
+			out: ["lib/libext.a"],
+		}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForExternalBuildTest,
+		android.FixtureAddTextFile("external/ext/Android.bp", bp),
+	).RunTest(t)
+
+	gen := result.ModuleForTests("ext", "android_arm64_armv8-a")
+	sboxProto := android.RuleBuilderSboxProtoForTests(t, gen.Output("genrule.sbox.textproto"))
+	cmd := *sboxProto.Commands[0].Command
+
+	android.AssertStringDoesContain(t, "incorrect CC", cmd, "CC=__SBOX_SANDBOX_DIR__/tools/src/prebuilts/clang/host/linux-x86/")
+	android.AssertStringDoesContain(t, "incorrect CC", cmd, "/bin/clang ")
+	android.AssertStringDoesContain(t, "incorrect CXX", cmd, "/bin/clang++ ")
+	android.AssertStringDoesContain(t, "incorrect AR", cmd, "/bin/llvm-ar ")
+	android.AssertStringDoesContain(t, "incorrect CLANG_TRIPLE", cmd, "CLANG_TRIPLE=aarch64-linux-android ")
+	android.AssertStringDoesContain(t, "incorrect SRC_DIR", cmd, "SRC_DIR=external/ext/src ")
+	android.AssertStringDoesContain(t, "incorrect CC_ARCH", cmd, "CC_ARCH=arm64 ")
+}
+
+func TestExternalBuildSandbox(t *testing.T) {
+	bp := `
+		cc_external_build {
+			name: "ext",
+			src_dir: "src",
+			cmd: "make -C $${SRC_DIR} OUT=$(genDir)",
+			out: ["lib/libext.a"],
+		}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForExternalBuildTest,
+		android.FixtureAddTextFile("external/ext/Android.bp", bp),
+	).RunTest(t)
+
+	gen := result.ModuleForTests("ext", "android_arm64_armv8-a")
+	sboxProto := android.RuleBuilderSboxProtoForTests(t, gen.Output("genrule.sbox.textproto"))
+	command := sboxProto.Commands[0]
+
+	android.AssertBoolEquals(t, "command runs from the sandbox", true, command.GetChdir())
+	if command.GetInputHash() == "" {
+		t.Errorf("expected the manifest to contain a hash of the inputs")
+	}
+
+	copied := map[string]string{}
+	for _, c := range command.CopyBefore {
+		copied[c.GetFrom()] = c.GetTo()
+	}
+	for _, src := range []string{
+		"external/ext/src/Makefile",
+		"external/ext/src/configure",
+		"external/ext/src/ext.c",
+		"external/ext/src/include/ext.h",
+	} {
+		android.AssertStringEquals(t, "sandboxed input "+src, src, copied[src])
+	}
+
+	var clang string
+	for from, to := range copied {
+		if strings.HasSuffix(from, "/bin/clang") {
+			clang = to
+		}
+	}
+	android.AssertStringDoesContain(t, "sandboxed clang", clang, "tools/src/prebuilts/clang/host/linux-x86/")
+}
+
+func TestExternalBuildOutputs(t *testing.T) {
+	bp := `
+		cc_external_build {
+			name: "ext",
+			src_dir: "src",
+			cmd: "make -C $${SRC_DIR} OUT=$(genDir)",
+			out: ["include/ext.h"],
+			static_libs: ["lib/libext.a"],
+			export_include_dirs: ["include"],
+		}
+
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			static_libs: ["libext"],
+		}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForExternalBuildTest,
+		android.FixtureAddTextFile("external/ext/Android.bp", bp),
+		android.FixtureAddFile("external/ext/foo.c", nil),
+	).RunTest(t)
+
+	gen := result.ModuleForTests("ext", "android_arm64_armv8-a").Module().(*genrule.Module)
+	outs, err := gen.OutputFiles("lib/libext.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	android.AssertPathsRelativeToTopEquals(t, "ext outputs",
+		[]string{"out/soong/.intermediates/external/ext/ext/android_arm64_armv8-a/gen/lib/libext.a"}, outs)
+
+	static := result.ModuleForTests("libext", "android_arm64_armv8-a_static").Module().(*Module)
+	android.AssertPathRelativeToTopEquals(t, "libext output",
+		"out/soong/.intermediates/external/ext/ext/android_arm64_armv8-a/gen/lib/libext.a", static.OutputFile().Path())
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	cFlags := android.StringRelativeToTop(result.Config, libfoo.Rule("cc").Args["cFlags"])
+	android.AssertStringDoesContain(t, "libfoo include dirs", cFlags,
+		"-I"+android.PathRelativeToTop(gen.GeneratedHeaderDirs()[0]))
+	android.AssertStringListContains(t, "libfoo links libext",
+		android.PathsRelativeToTop(libfoo.Rule("ld").Implicits),
+		"out/soong/.intermediates/external/ext/ext/android_arm64_armv8-a/gen/lib/libext.a")
+}
+
+func TestExternalBuildMissingSrcDir(t *testing.T) {
+	bp := `
+		cc_external_build {
+			name: "ext",
+			cmd: "make",
+			out: ["libext.a"],
+		}
+	`
+	android.GroupFixturePreparers(
+		prepareForExternalBuildTest,
+		android.FixtureAddTextFile("external/ext/Android.bp", bp),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(`src_dir: missing src_dir`)).
+		RunTest(t)
+}
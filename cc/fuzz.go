@@ -164,6 +164,24 @@ func sharedLibrarySymbolsInstallLocation(libraryPath android.Path, archString st
 	return filepath.Join("$(PRODUCT_OUT)/symbols/data/fuzz/", archString, "/lib/", libraryPath.Base())
 }
 
+// copyCorpus copies the corpus files to the given directory under names that are unique even if
+// several of them have the same base name, and returns the copies, which are installed and
+// packaged instead of the original files.
+func copyCorpus(ctx ModuleContext, corpus android.Paths, dir android.ModuleOutPath) android.Paths {
+	corpus = android.FirstUniquePaths(corpus)
+	builder := android.NewRuleBuilder(pctx, ctx)
+	var copies android.Paths
+	for i, name := range fuzz.CorpusFileNames(corpus) {
+		dest := dir.Join(ctx, name)
+		builder.Command().Text("cp").
+			Input(corpus[i]).
+			Output(dest)
+		copies = append(copies, dest)
+	}
+	builder.Build("copy_corpus", "copy corpus")
+	return copies
+}
+
 func (fuzz *fuzzBinary) install(ctx ModuleContext, file android.Path) {
 	fuzz.binaryDecorator.baseInstaller.dir = filepath.Join(
 		"fuzz", ctx.Target().Arch.ArchType.String(), ctx.ModuleName())
@@ -171,19 +189,13 @@ func (fuzz *fuzzBinary) install(ctx ModuleContext, file android.Path) {
 		"fuzz", ctx.Target().Arch.ArchType.String(), ctx.ModuleName())
 	fuzz.binaryDecorator.baseInstaller.install(ctx, file)
 
-	fuzz.fuzzPackagedModule.Corpus = android.PathsForModuleSrc(ctx, fuzz.fuzzPackagedModule.FuzzProperties.Corpus)
-	builder := android.NewRuleBuilder(pctx, ctx)
+	corpus := android.PathsForModuleSrc(ctx, fuzz.fuzzPackagedModule.FuzzProperties.Corpus)
 	intermediateDir := android.PathForModuleOut(ctx, "corpus")
-	for _, entry := range fuzz.fuzzPackagedModule.Corpus {
-		builder.Command().Text("cp").
-			Input(entry).
-			Output(intermediateDir.Join(ctx, entry.Base()))
-	}
-	builder.Build("copy_corpus", "copy corpus")
+	fuzz.fuzzPackagedModule.Corpus = copyCorpus(ctx, corpus, intermediateDir)
 	fuzz.fuzzPackagedModule.CorpusIntermediateDir = intermediateDir
 
 	fuzz.fuzzPackagedModule.Data = android.PathsForModuleSrc(ctx, fuzz.fuzzPackagedModule.FuzzProperties.Data)
-	builder = android.NewRuleBuilder(pctx, ctx)
+	builder := android.NewRuleBuilder(pctx, ctx)
 	intermediateDir = android.PathForModuleOut(ctx, "data")
 	for _, entry := range fuzz.fuzzPackagedModule.Data {
 		builder.Command().Text("cp").
@@ -318,6 +330,9 @@ func (s *ccFuzzPackager) GenerateBuildActions(ctx android.SingletonContext) {
 
 		archString := ccModule.Arch().ArchType.String()
 		archDir := android.PathForIntermediates(ctx, "fuzz", hostOrTargetString, archString)
+		if ccModule.Host() {
+			archDir = android.PathForOutput(ctx, "host-fuzz", archString)
+		}
 		archOs := fuzz.ArchOs{HostOrTarget: hostOrTargetString, Arch: archString, Dir: archDir.String()}
 
 		// Grab the list of required shared libraries.
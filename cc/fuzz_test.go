@@ -0,0 +1,93 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestFuzzPackaging(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		// The prebuilt libFuzzer runtime of the tests only has device variants.
+		android.PrepareForTestWithAllowMissingDependencies,
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterSingletonType("cc_fuzz_packaging", fuzzPackagingFactory)
+		}),
+		android.FixtureAddTextFile("fuzzer/Android.bp", `
+			cc_fuzz {
+				name: "fuzz_test",
+				host_supported: true,
+				srcs: ["fuzz.c"],
+				corpus: ["corpus/**/*"],
+				dictionary: "fuzz.dict",
+				data: [
+					"assets/model.bin",
+					"data.txt",
+				],
+				fuzz_config: {
+					cc: ["someone@example.com"],
+				},
+			}
+		`),
+		android.MockFS{
+			"fuzzer/fuzz.c":           nil,
+			"fuzzer/corpus/a/seed":    nil,
+			"fuzzer/corpus/b/seed":    nil,
+			"fuzzer/corpus/other":     nil,
+			"fuzzer/fuzz.dict":        nil,
+			"fuzzer/assets/model.bin": nil,
+			"fuzzer/data.txt":         nil,
+		}.AddToFixture(),
+	).RunTest(t)
+
+	singleton := result.SingletonForTests("cc_fuzz_packaging")
+
+	checkPackage := func(hostOrTarget, archDir, variant string) {
+		t.Helper()
+		intermediates := "out/soong/.intermediates/fuzzer/fuzz_test/" + variant + "/"
+		fuzzZip := singleton.Output(archDir + "/fuzz_test.zip")
+		command := android.StringRelativeToTop(result.Config, fuzzZip.RuleParams.Command)
+
+		// The corpus files with the same base name are both packaged.
+		corpusZip := archDir + "/fuzz_test_seed_corpus.zip"
+		singleton.Output(corpusZip)
+		for _, corpus := range []string{"corpus_a_seed", "corpus_b_seed", "other"} {
+			android.AssertStringListContains(t, hostOrTarget+" corpus",
+				android.PathsRelativeToTop(fuzzZip.Implicits), intermediates+"corpus/"+corpus)
+		}
+		android.AssertStringDoesContain(t, hostOrTarget+" corpus", command, "-P '' -f "+corpusZip)
+
+		android.AssertStringDoesContain(t, hostOrTarget+" dictionary", command,
+			"-P '' -f fuzzer/fuzz.dict")
+		android.AssertStringDoesContain(t, hostOrTarget+" config", command,
+			"-P '' -f "+intermediates+"config/config.json")
+
+		// The data files are packaged next to the executable.
+		android.AssertStringDoesContain(t, hostOrTarget+" data", command,
+			"-P assets -f fuzzer/assets/model.bin")
+		android.AssertStringDoesContain(t, hostOrTarget+" data", command,
+			"-P '' -f fuzzer/data.txt")
+		android.AssertStringDoesNotContain(t, hostOrTarget+" data zip", command, "fuzz_test_data.zip")
+		android.AssertStringDoesContain(t, hostOrTarget+" executable", command,
+			"-P '' -f "+intermediates+"unstripped/fuzz_test")
+	}
+
+	checkPackage("target", "out/soong/.intermediates/fuzz/target/arm64", "android_arm64_armv8-a_fuzzer")
+	checkPackage("host", "out/soong/host-fuzz/x86_64", "linux_glibc_x86_64_fuzzer")
+}
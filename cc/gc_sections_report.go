@@ -0,0 +1,73 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"sort"
+	"strings"
+
+	"android/soong/android"
+)
+
+func init() {
+	android.RegisterSingletonType("gc_sections_report", gcSectionsReportSingletonFactory)
+}
+
+func gcSectionsReportSingletonFactory() android.Singleton {
+	return &gcSectionsReportSingleton{}
+}
+
+type gcSectionsReportSingleton struct{}
+
+// GenerateBuildActions creates the gc-sections-report phony target, which summarizes the number
+// of sections and bytes that the linker removed with --gc-sections for each native module that
+// sets generate_gc_sections_report in out/soong/gc_sections/summary.json.
+func (gcSectionsReportSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	var entries []string
+	var reports android.Paths
+	var linkInputs android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		c, ok := module.(*Module)
+		if !ok || !c.Enabled() || !c.linkerGcSectionsReport().Valid() {
+			return
+		}
+		report := c.linkerGcSectionsReport().Path()
+		name := ctx.ModuleName(module) + ":" + ctx.ModuleSubDir(module)
+		entries = append(entries, name+" "+report.String())
+		reports = append(reports, report)
+		// The summary reads the sizes of the removed sections from the object files and static
+		// libraries that the report refers to.
+		linkInputs = append(linkInputs, c.linkerGcSectionsReportInputs()...)
+	})
+	if len(entries) == 0 {
+		return
+	}
+	sort.Strings(entries)
+
+	modulesFile := android.PathForOutput(ctx, "gc_sections", "modules.txt")
+	android.WriteFileRule(ctx, modulesFile, strings.Join(entries, "\n"))
+
+	summaryFile := android.PathForOutput(ctx, "gc_sections", "summary.json")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("summarize_gc_sections").
+		FlagWithInput("--modules ", modulesFile).
+		Implicits(reports).
+		Implicits(android.FirstUniquePaths(linkInputs)).
+		FlagWithOutput("--output ", summaryFile)
+	rule.Build("gc_sections_report", "summarize sections removed by --gc-sections")
+
+	ctx.Phony("gc-sections-report", summaryFile)
+}
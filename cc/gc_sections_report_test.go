@@ -0,0 +1,111 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+var prepareForGcSectionsReportTest = android.GroupFixturePreparers(
+	prepareForCcTest,
+	android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+		ctx.RegisterSingletonType("gc_sections_report", gcSectionsReportSingletonFactory)
+	}),
+)
+
+const gcSectionsReportTestBp = `
+	cc_binary {
+		name: "bin",
+		srcs: ["bin.c"],
+		static_libs: ["libstatic"],
+		generate_gc_sections_report: true,
+	}
+
+	cc_library_static {
+		name: "libstatic",
+		srcs: ["static.c"],
+	}
+
+	cc_library_shared {
+		name: "libfoo",
+		generate_gc_sections_report: true,
+	}
+
+	cc_library_shared {
+		name: "libbar",
+	}
+`
+
+func TestGcSectionsReport(t *testing.T) {
+	t.Parallel()
+	result := prepareForGcSectionsReportTest.RunTestWithBp(t, gcSectionsReportTestBp)
+
+	checkReport := func(name, variant, expectedOutput, expectedReport string) {
+		t.Helper()
+		link := result.ModuleForTests(name, variant).Rule("ldWithGcSectionsReport")
+		android.AssertStringDoesContain(t, name+" ldflags", link.Args["ldFlags"], "-Wl,--print-gc-sections")
+		android.AssertStringEquals(t, name+" report", expectedReport, link.Args["gcSectionsReport"])
+		android.AssertStringListContains(t, name+" implicit outputs", link.ImplicitOutputs.Strings(), expectedReport)
+		// The report must not replace the linked output.
+		android.AssertPathRelativeToTopEquals(t, name+" output", expectedOutput, link.Output)
+
+		android.AssertStringDoesContain(t, name+" command", link.RuleParams.Command,
+			"2> ${gcSectionsReport}.tmp; status=$$?;")
+		android.AssertStringDoesContain(t, name+" command", link.RuleParams.Command,
+			"grep -v 'removing unused section' ${gcSectionsReport}.tmp >&2;")
+		android.AssertStringDoesContain(t, name+" command", link.RuleParams.Command,
+			"exit $$status")
+	}
+
+	checkReport("bin", "android_arm64_armv8-a",
+		"out/soong/.intermediates/bin/android_arm64_armv8-a/unstripped/bin",
+		"out/soong/.intermediates/bin/android_arm64_armv8-a/bin.gc_report.txt")
+	checkReport("libfoo", "android_arm64_armv8-a_shared",
+		"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/unstripped/libfoo.so",
+		"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/libfoo.gc_report.txt")
+
+	libbar := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+	android.AssertStringDoesNotContain(t, "libbar ldflags", libbar.Rule("ld").Args["ldFlags"],
+		"--print-gc-sections")
+}
+
+func TestGcSectionsReportSummary(t *testing.T) {
+	t.Parallel()
+	result := prepareForGcSectionsReportTest.RunTestWithBp(t, gcSectionsReportTestBp)
+
+	singleton := result.SingletonForTests("gc_sections_report")
+
+	modules := android.ContentFromFileRuleForTests(t, singleton.Output("out/soong/gc_sections/modules.txt"))
+	android.AssertStringDoesContain(t, "modules", modules,
+		"bin:android_arm64_armv8-a out/soong/.intermediates/bin/android_arm64_armv8-a/bin.gc_report.txt")
+	android.AssertStringDoesContain(t, "modules", modules,
+		"libfoo:android_arm64_armv8-a_shared out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/libfoo.gc_report.txt")
+	android.AssertStringDoesNotContain(t, "modules", modules, "libbar")
+
+	summary := singleton.Rule("gc_sections_report")
+	android.AssertStringDoesContain(t, "command", summary.RuleParams.Command,
+		"--output out/soong/gc_sections/summary.json")
+	android.AssertStringListContains(t, "inputs", summary.Implicits.Strings(),
+		"out/soong/.intermediates/bin/android_arm64_armv8-a/bin.gc_report.txt")
+
+	// The object files and static libraries of the link are inputs, as the summary reads the sizes
+	// of the removed sections from them.
+	android.AssertStringListContains(t, "inputs", summary.Implicits.Strings(),
+		"out/soong/.intermediates/bin/android_arm64_armv8-a/obj/bin.o")
+	android.AssertStringListContains(t, "inputs", summary.Implicits.Strings(),
+		"out/soong/.intermediates/libstatic/android_arm64_armv8-a_static/libstatic.a")
+}
@@ -16,6 +16,7 @@ package cc
 
 import (
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/google/blueprint"
@@ -45,6 +46,15 @@ var (
 			CommandDeps: []string{"$syspropCmd"},
 		},
 		"headerOutDir", "publicOutDir", "srcOutDir", "includeName")
+
+	// Writes a source file that #includes each of the files with one of the extensions in a
+	// generated directory, given the list of its files.
+	sourceDirIncludes = pctx.AndroidStaticRule("sourceDirIncludes",
+		blueprint.RuleParams{
+			Command: `(echo "// Sources in $dir" && ` +
+				`sed -n -E 's@^(.*\.($exts))$$@#include "$relDir/\1"@p' $in) > $out`,
+		},
+		"dir", "relDir", "exts")
 )
 
 type YaccProperties struct {
@@ -104,6 +114,22 @@ func genYacc(ctx android.ModuleContext, rule *android.RuleBuilder, yaccFile andr
 	return ret
 }
 
+// aidlIncludeFlagsFromSrcs returns the -I flags for the root directories of the aidl srcs, i.e. the
+// directories that the srcs are relative to, such as the path of the filegroup that provides them.
+// If allSrcs is true the root directories of all the srcs are returned.
+func aidlIncludeFlagsFromSrcs(srcs android.Paths, allSrcs bool) []string {
+	var flags []string
+	for _, src := range srcs {
+		if src.Ext() != ".aidl" && !allSrcs {
+			continue
+		}
+		if baseDir := strings.TrimSuffix(src.String(), src.Rel()); baseDir != "" {
+			flags = append(flags, "-I"+baseDir)
+		}
+	}
+	return android.FirstUniqueStrings(flags)
+}
+
 func genAidl(ctx android.ModuleContext, rule *android.RuleBuilder, aidlFile android.Path, aidlFlags string) (cppFile android.OutputPath, headerFiles android.Paths) {
 	aidlPackage := strings.TrimSuffix(aidlFile.Rel(), aidlFile.Base())
 	baseName := strings.TrimSuffix(aidlFile.Base(), aidlFile.Ext())
@@ -123,11 +149,6 @@ func genAidl(ctx android.ModuleContext, rule *android.RuleBuilder, aidlFile andr
 	headerBn := outDir.Join(ctx, aidlPackage, "Bn"+shortName+".h")
 	headerBp := outDir.Join(ctx, aidlPackage, "Bp"+shortName+".h")
 
-	baseDir := strings.TrimSuffix(aidlFile.String(), aidlFile.Rel())
-	if baseDir != "" {
-		aidlFlags += " -I" + baseDir
-	}
-
 	cmd := rule.Command()
 	cmd.BuiltTool("aidl-cpp").
 		FlagWithDepFile("-d", depFile).
@@ -218,6 +239,41 @@ type generatedSourceInfo struct {
 	syspropOrderOnlyDeps android.Paths
 }
 
+// genSourceDirSources returns a C and a C++ source file that #include the C and the C++ files of a
+// generated directory, whose files are listed in fileList.  The names of the files are only known
+// once the directory has been generated, so compiling these files is how they are compiled.  Like
+// in a unity build, the files share a translation unit, so they must not define conflicting
+// static symbols or macros.
+func genSourceDirSources(ctx android.ModuleContext, index int, dir, fileList android.Path) android.Paths {
+	outDir := android.PathForModuleGen(ctx, "source_dirs", strconv.Itoa(index))
+	// The generated files #include the files relative to their own directory.
+	relDir, err := filepath.Rel(outDir.String(), dir.String())
+	if err != nil {
+		panic(err)
+	}
+
+	var srcs android.Paths
+	for _, lang := range []struct{ file, exts string }{
+		{"c_sources.c", "c"},
+		{"cpp_sources.cpp", "cc|cpp|cxx"},
+	} {
+		src := outDir.Join(ctx, lang.file)
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        sourceDirIncludes,
+			Description: "source dir " + dir.Base() + " " + lang.file,
+			Output:      src,
+			Input:       fileList,
+			Args: map[string]string{
+				"dir":    dir.String(),
+				"relDir": relDir,
+				"exts":   lang.exts,
+			},
+		})
+		srcs = append(srcs, src)
+	}
+	return srcs
+}
+
 func genSources(ctx android.ModuleContext, srcFiles android.Paths,
 	buildFlags builderFlags) (android.Paths, android.Paths, generatedSourceInfo) {
 
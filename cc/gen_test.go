@@ -15,6 +15,7 @@
 package cc
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -74,4 +75,58 @@ func TestGen(t *testing.T) {
 
 	})
 
+	t.Run("filegroups in other directories", func(t *testing.T) {
+		bp := `
+		filegroup {
+			name: "fga",
+			srcs: ["a/aidl/com/a/IA.aidl"],
+			path: "a/aidl",
+		}
+
+		filegroup {
+			name: "fgb",
+			srcs: ["b/aidl/com/b/IB.aidl"],
+			path: "b/aidl",
+		}
+
+		filegroup {
+			name: "fgc",
+			srcs: ["c/src/com/c/c.cpp"],
+			path: "c/src",
+		}
+
+		cc_library_shared {
+			name: "libfoo",
+			srcs: [
+				":fga",
+				":fgb",
+				":fgc",
+			],
+			%s
+		}`
+
+		aidlCommands := func(ctx *android.TestContext) []string {
+			aidlManifest := ctx.ModuleForTests("libfoo", "android_arm_armv7-a-neon_shared").Output("aidl.sbox.textproto")
+			var commands []string
+			for _, command := range android.RuleBuilderSboxProtoForTests(t, aidlManifest).Commands {
+				commands = append(commands, command.GetCommand())
+			}
+			return commands
+		}
+
+		ctx := testCc(t, fmt.Sprintf(bp, ""))
+		commands := aidlCommands(ctx)
+		android.AssertIntEquals(t, "number of aidl commands", 2, len(commands))
+		for _, command := range commands {
+			// IA.aidl can import IB.aidl from the other filegroup and vice versa.
+			android.AssertStringDoesContain(t, "aidl command", command, "-Ia/aidl/ -Ib/aidl/")
+			android.AssertStringDoesNotContain(t, "aidl command", command, "-Ic/src/")
+		}
+
+		ctx = testCc(t, fmt.Sprintf(bp, "aidl: { include_dirs_from_srcs: true },"))
+		for _, command := range aidlCommands(ctx) {
+			android.AssertStringDoesContain(t, "aidl command", command, "-Ia/aidl/ -Ib/aidl/ -Ic/src/")
+		}
+	})
+
 }
@@ -187,3 +187,48 @@ func TestCmdPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestGenruleOutDirsGeneratedSources(t *testing.T) {
+	bp := `
+		genrule {
+			name: "gen",
+			out_dirs: ["src"],
+			cmd: "mkdir -p $(genDir)/src/nested && " +
+				"echo 'int a() { return 0; }' > $(genDir)/src/nested/a.c && " +
+				"echo 'int b() { return 0; }' > $(genDir)/src/nested/b.cpp && " +
+				"touch $(genDir)/src/nested/b.h",
+		}
+
+		cc_library_static {
+			name: "libfoo",
+			generated_sources: ["gen"],
+		}
+		`
+	result := PrepareForIntegrationTestWithCc.RunTestWithBp(t, bp)
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_static")
+	genDir := "out/soong/.intermediates/libfoo/android_arm64_armv8-a_static/gen/source_dirs/0/"
+
+	// The names of the files in the directory are not known, so the library compiles a C and a
+	// C++ file that include the files listed in the file list of the directory.
+	for _, tc := range []struct{ src, exts string }{
+		{"c_sources.c", "c"},
+		{"cpp_sources.cpp", "cc|cpp|cxx"},
+	} {
+		includes := libfoo.Output(genDir + tc.src)
+		android.AssertPathsRelativeToTopEquals(t, tc.src+" inputs",
+			[]string{"out/soong/.intermediates/gen/gen/src.files"}, includes.Inputs)
+		android.AssertStringEquals(t, tc.src+" relDir", "../../../../../gen/gen/src", includes.Args["relDir"])
+		android.AssertStringEquals(t, tc.src+" exts", tc.exts, includes.Args["exts"])
+	}
+	android.AssertPathsRelativeToTopEquals(t, "compiled sources",
+		[]string{genDir + "c_sources.c", genDir + "cpp_sources.cpp"},
+		libfoo.Module().(*Module).compiler.(*libraryDecorator).baseCompiler.srcs)
+
+	// The headers in the generated directory are found through the exported genDir, and the
+	// compile waits for the directory to be copied out of the sandbox.
+	compile := libfoo.Rule("cc")
+	android.AssertStringDoesContain(t, "cflags",
+		android.StringRelativeToTop(result.Config, compile.Args["cFlags"]), "-Iout/soong/.intermediates/gen/gen")
+	android.AssertStringListContains(t, "order only deps",
+		android.PathsRelativeToTop(compile.OrderOnly), "out/soong/.intermediates/gen/gen/src.files")
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+var _ android.HostLibcModule = (*Module)(nil)
+
+var hostLibcDepsCheckKey = android.NewOnceKey("hostLibcDepsCheck")
+
+// HostLibc implements android.HostLibcModule.  Libraries and objects are built against both libcs
+// unless host_libc is set, so that the libraries a module built against the other libc than the
+// one of the build OS depends on, including implicit ones like libc++, are available for its libc.
+// hostLibcDepsCheckMutator disables these variants when no such module depends on them.
+func (c *Module) HostLibc() string {
+	if c.Properties.Host_libc == nil && (c.CcLibraryInterface() || c.Object()) {
+		return "both"
+	}
+	return String(c.Properties.Host_libc)
+}
+
+// isLinuxHostVariant returns true if the module is a host variant built against glibc or musl.
+func isLinuxHostVariant(m android.Module) bool {
+	return m.Os() == android.Linux || m.Os() == android.LinuxMusl
+}
+
+// hostLibcName returns the name of the libc the given Linux host OS is built against.
+func hostLibcName(os android.OsType) string {
+	if os == android.LinuxMusl {
+		return "musl"
+	}
+	return "glibc"
+}
+
+// linksSharedLibsStatically returns true if the module is a Linux host variant built against the
+// other libc than the one of the build OS.  Only the variants for the libc of the build OS are
+// installed, so these variants link the libraries they depend on statically whenever possible,
+// libc++ included.
+func linksSharedLibsStatically(ctx android.BaseModuleContext) bool {
+	os := ctx.Os()
+	return (os == android.Linux || os == android.LinuxMusl) && os != ctx.Config().BuildOS
+}
+
+// linkSharedLibsStatically moves the shared libraries of deps that have a static variant to the
+// static libraries for the variants for which linksSharedLibsStatically returns true.  These
+// variants link all the static libraries they transitively depend on, so that the libraries the
+// shared libraries depend on are linked as well.
+func (c *Module) linkSharedLibsStatically(actx android.BottomUpMutatorContext, deps *Deps) {
+	if !linksSharedLibsStatically(actx) {
+		return
+	}
+
+	variations := append(actx.Target().Variations(), c.ImageVariation(),
+		blueprint.Variation{Mutator: "link", Variation: "static"})
+	split := func(libs []string) (shared, static []string) {
+		for _, lib := range libs {
+			name, _ := StubsLibNameAndVersion(lib)
+			if actx.OtherModuleFarDependencyVariantExists(variations, name) {
+				static = append(static, name)
+			} else {
+				shared = append(shared, lib)
+			}
+		}
+		return shared, static
+	}
+
+	var static, lateStatic []string
+	deps.SharedLibs, static = split(deps.SharedLibs)
+	deps.LateSharedLibs, lateStatic = split(deps.LateSharedLibs)
+	deps.StaticLibs = android.FirstUniqueStrings(append(deps.StaticLibs, static...))
+	deps.LateStaticLibs = android.FirstUniqueStrings(append(deps.LateStaticLibs, lateStatic...))
+
+	var reexported []string
+	deps.ReexportSharedLibHeaders, reexported = android.FilterList(deps.ReexportSharedLibHeaders, static)
+	deps.ReexportStaticLibHeaders = append(deps.ReexportStaticLibHeaders, reexported...)
+}
+
+// filterDepsMissingHostLibcVariant removes the libraries and generated sources that are not built
+// against the libc of the Linux host variant c from deps.  The dependencies of a variant are
+// always resolved to the variant of the same OS, and so of the same libc.  Rather than failing
+// with a missing variant error, the modules built for the other libc only are reported by
+// hostLibcDepsCheckMutator together with the chain of dependencies that requires them.
+func (c *Module) filterDepsMissingHostLibcVariant(actx android.BottomUpMutatorContext, deps *Deps) {
+	if !isLinuxHostVariant(c) {
+		return
+	}
+
+	variations := append(actx.Target().Variations(), c.ImageVariation())
+	syspropImplLibraries := syspropImplLibraries(actx.Config())
+	filter := func(libs []string) []string {
+		var ret []string
+		for _, lib := range libs {
+			name, _ := StubsLibNameAndVersion(lib)
+			if impl, ok := syspropImplLibraries[name]; ok {
+				name = impl
+			}
+			if actx.OtherModuleExists(name) && !actx.OtherModuleFarDependencyVariantExists(variations, name) {
+				if !inList(name, c.missingHostLibcDeps) {
+					c.missingHostLibcDeps = append(c.missingHostLibcDeps, name)
+				}
+				continue
+			}
+			ret = append(ret, lib)
+		}
+		return ret
+	}
+
+	deps.HeaderLibs = filter(deps.HeaderLibs)
+	deps.WholeStaticLibs = filter(deps.WholeStaticLibs)
+	deps.StaticLibs = filter(deps.StaticLibs)
+	deps.SharedLibs = filter(deps.SharedLibs)
+	deps.RuntimeLibs = filter(deps.RuntimeLibs)
+	deps.GeneratedSources = filter(deps.GeneratedSources)
+	deps.GeneratedHeaders = filter(deps.GeneratedHeaders)
+	deps.ReexportGeneratedHeaders = filter(deps.ReexportGeneratedHeaders)
+
+	// The variants built against the other libc than the one of the build OS are checked from
+	// the modules that depend on them regardless, see hostLibcDepsCheckMutator.
+	if len(c.missingHostLibcDeps) > 0 && c.Os() == actx.Config().BuildOS {
+		check := getMissingDepsCheck(actx.Config(), hostLibcDepsCheckKey)
+		check.lock.Lock()
+		defer check.lock.Unlock()
+		check.found = true
+	}
+}
+
+// hostLibcDepsCheckMutator reports the dependencies removed by filterDepsMissingHostLibcVariant.
+// Like imageDepsCheckMutator, each missing dependency is reported on the module at the top of the
+// longest chain that reaches it, along with that chain.
+//
+// It also disables the variants that libraries and objects only have because of their default
+// host_libc, see Module.HostLibc, when no module that sets host_libc depends on them.  A top down
+// mutator visits a module after all the modules that depend on it, so these variants are marked as
+// needed before they are visited.
+func hostLibcDepsCheckMutator(mctx android.TopDownMutatorContext) {
+	m, ok := mctx.Module().(*Module)
+	if !ok || !isLinuxHostVariant(m) || !m.Enabled() {
+		return
+	}
+
+	check := getMissingDepsCheck(mctx.Config(), hostLibcDepsCheckKey)
+	otherLibc := m.Os() != mctx.Config().BuildOS
+	if otherLibc && m.Properties.Host_libc == nil {
+		check.lock.Lock()
+		defer check.lock.Unlock()
+		if !m.hostLibcVariantNeeded {
+			m.Disable()
+		}
+		return
+	}
+	if !otherLibc && !check.found {
+		return
+	}
+
+	report := func(c *Module, path string) {
+		check.lock.Lock()
+		defer check.lock.Unlock()
+		if check.reported[c.String()] {
+			return
+		}
+		check.reported[c.String()] = true
+
+		libc := hostLibcName(c.Os())
+		for _, dep := range c.missingHostLibcDeps {
+			mctx.ModuleErrorf("%q is built against %s, but depends on %q, which is not. Dependency path:\n%s",
+				c.BaseModuleName(), libc, dep, path)
+		}
+	}
+
+	mctx.WalkDeps(func(child, parent android.Module) bool {
+		c, ok := child.(*Module)
+		if !ok || !isLinuxHostVariant(c) {
+			return false
+		}
+		if otherLibc {
+			check.lock.Lock()
+			c.hostLibcVariantNeeded = true
+			check.lock.Unlock()
+		}
+		if len(c.missingHostLibcDeps) > 0 {
+			report(c, mctx.GetPathString(false))
+		}
+		return true
+	})
+
+	if len(m.missingHostLibcDeps) > 0 {
+		report(m, m.String())
+	}
+}
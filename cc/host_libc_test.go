@@ -0,0 +1,142 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+var prepareForHostLibcTest = android.GroupFixturePreparers(
+	prepareForCcTest,
+	android.PrepareForTestWithHostMuslAndGlibc,
+)
+
+const hostLibcTestBp = `
+	cc_binary {
+		name: "foo",
+		host_supported: true,
+		device_supported: false,
+		host_libc: "%s",
+		srcs: ["foo.c"],
+		shared_libs: ["libbar"],
+	}
+
+	%s {
+		name: "libbar",
+		host_supported: true,
+		device_supported: false,
+		srcs: ["bar.c"],
+		shared_libs: ["libbaz"],
+		%s
+	}
+
+	cc_library {
+		name: "libbaz",
+		host_supported: true,
+		device_supported: false,
+		srcs: ["baz.c"],
+	}
+`
+
+func TestHostLibc(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+
+	result := prepareForHostLibcTest.RunTestWithBp(t, fmt.Sprintf(hostLibcTestBp, "glibc", "cc_library", ""))
+
+	foo := result.ModuleForTests("foo", "linux_glibc_x86_64")
+	for _, variant := range result.ModuleVariantsForTests("foo") {
+		android.AssertStringDoesNotContain(t, "foo variant", variant, "musl")
+	}
+	android.AssertBoolEquals(t, "foo hidden from make", false, foo.Module().IsHideFromMake())
+
+	// The shared libraries of the glibc variants are not installed, so the libraries foo depends
+	// on, directly or not, are linked statically.
+	implicits := foo.Rule("ld").Implicits.Strings()
+	for _, lib := range []string{"libbar", "libbaz", "libc++_static"} {
+		android.AssertStringListContains(t, "foo link dependencies", implicits,
+			result.ModuleForTests(lib, "linux_glibc_x86_64_static").Output(lib+".a").Output.String())
+	}
+	for _, implicit := range implicits {
+		android.AssertBoolEquals(t, implicit+" is a shared library", false, strings.HasSuffix(implicit, ".so"))
+	}
+
+	libbarGlibc := result.ModuleForTests("libbar", "linux_glibc_x86_64_static").Module()
+	libbarMusl := result.ModuleForTests("libbar", "linux_musl_x86_64_static").Module()
+
+	// The variant for the libc of the build OS is the one exported to Make.
+	android.AssertBoolEquals(t, "glibc libbar hidden from make", true, libbarGlibc.IsHideFromMake())
+	android.AssertBoolEquals(t, "musl libbar hidden from make", false, libbarMusl.IsHideFromMake())
+
+	// The glibc variants that nothing depends on are disabled.
+	for _, lib := range []string{"libbar", "libbaz", "libc++"} {
+		android.AssertBoolEquals(t, lib+" glibc shared enabled", false,
+			result.ModuleForTests(lib, "linux_glibc_x86_64_shared").Module().Enabled())
+	}
+	android.AssertBoolEquals(t, "musl libbar shared enabled", true,
+		result.ModuleForTests("libbar", "linux_musl_x86_64_shared").Module().Enabled())
+}
+
+func TestHostLibcSharedOnly(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+
+	prepareForHostLibcTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			regexp.QuoteMeta(`links "libbar" as a shared library, but its glibc variant is not installed`))).
+		RunTestWithBp(t, fmt.Sprintf(hostLibcTestBp, "glibc", "cc_library_shared", ""))
+}
+
+func TestHostLibcMissingVariant(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+
+	prepareForHostLibcTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			regexp.QuoteMeta(`"foo" is built against glibc, but depends on "libbar", which is not. Dependency path:`))).
+		RunTestWithBp(t, fmt.Sprintf(hostLibcTestBp, "glibc", "cc_library", `host_libc: "musl",`))
+}
+
+func TestHostLibcInvalid(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+
+	prepareForHostLibcTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`host_libc: must be "glibc", "musl" or "both", not "bionic"`)).
+		RunTestWithBp(t, fmt.Sprintf(hostLibcTestBp, "glibc", "cc_library", `host_libc: "bionic",`))
+}
+
+func TestHostLibcNotAvailable(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+
+	// Without a musl build OS there are no musl host targets.
+	prepareForCcTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			regexp.QuoteMeta(`host_libc: "musl" is not available, the build OS is linux_glibc and there are no musl host targets`))).
+		RunTestWithBp(t, fmt.Sprintf(hostLibcTestBp, "musl", "cc_library", ""))
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 
 	"android/soong/android"
 	"android/soong/snapshot"
@@ -397,6 +398,10 @@ func MutateImage(mctx android.BaseModuleContext, m ImageMutatableModule) {
 			mctx.PropertyErrorf("vendor_available",
 				"doesn't make sense at the same time as `odm_available: true`")
 		}
+		if m.NeedsVendorStubsVariants() {
+			mctx.PropertyErrorf("vendor_available",
+				"doesn't make sense at the same time as `stubs: { vendor_available: true }`")
+		}
 	}
 
 	if m.OdmAvailable() {
@@ -476,6 +481,11 @@ func MutateImage(mctx android.BaseModuleContext, m ImageMutatableModule) {
 		if productVndkVersion != "" {
 			productVariants = append(productVariants, productVndkVersion)
 		}
+	} else if m.NeedsVendorStubsVariants() && boardVndkVersion != "" {
+		// A platform library with vendor stubs has the implementation on /system, and the
+		// vendor variants are created with the stubs generated from stubs.symbol_file.
+		coreVariantNeeded = true
+		vendorVariants = append(vendorVariants, platformVndkVersion, boardVndkVersion)
 	} else if boardVndkVersion == "" {
 		// If the device isn't compiling against the VNDK, we always
 		// use the core mode.
@@ -710,4 +720,127 @@ func (c *Module) SetImageVariation(ctx android.BaseModuleContext, variant string
 		(variant == android.CoreVariation || strings.HasPrefix(variant, ProductVariationPrefix)) {
 		c.VendorProperties.IsVendorPublicLibrary = true
 	}
+
+	if c.NeedsVendorStubsVariants() && strings.HasPrefix(variant, VendorVariationPrefix) {
+		m.VendorProperties.IsVendorStubs = true
+	}
+}
+
+var imageDepsCheckKey = android.NewOnceKey("imageDepsCheck")
+
+// missingDepsCheck tracks the variants that depend on libraries without a matching variant, so
+// that each of them is reported once with its dependency chain.  There is one per kind of missing
+// variant, e.g. the recovery and vendor ramdisk variants checked by imageDepsCheckMutator.
+type missingDepsCheck struct {
+	lock     sync.Mutex
+	found    bool
+	reported map[string]bool
+}
+
+func getMissingDepsCheck(config android.Config, key android.OnceKey) *missingDepsCheck {
+	return config.Once(key, func() interface{} {
+		return &missingDepsCheck{reported: make(map[string]bool)}
+	}).(*missingDepsCheck)
+}
+
+// imageAvailableProperty returns the image name and the property that makes a library available
+// for the recovery or vendor ramdisk image of c.
+func (c *Module) imageAvailableProperty() (image, property string) {
+	if c.InVendorRamdisk() {
+		return "vendor_ramdisk", "vendor_ramdisk_available"
+	}
+	return "recovery", "recovery_available"
+}
+
+// filterDepsMissingImageVariant removes the libraries that have no variant for the recovery or
+// vendor ramdisk image of c from deps.  Rather than failing with a missing variant error, they are
+// reported by imageDepsCheckMutator together with the chain of dependencies that requires them.
+func (c *Module) filterDepsMissingImageVariant(actx android.BottomUpMutatorContext, deps *Deps) {
+	if !c.InRecovery() && !c.InVendorRamdisk() {
+		return
+	}
+	if c.InRecovery() {
+		if v := actx.DeviceConfig().RecoverySnapshotVersion(); v != "current" && v != "" {
+			// The dependencies are replaced with the modules of the recovery snapshot.
+			return
+		}
+	}
+
+	variations := append(actx.Target().Variations(), c.ImageVariation())
+	syspropImplLibraries := syspropImplLibraries(actx.Config())
+	filter := func(libs []string) []string {
+		var ret []string
+		for _, lib := range libs {
+			name, _ := StubsLibNameAndVersion(lib)
+			if impl, ok := syspropImplLibraries[name]; ok {
+				name = impl
+			}
+			if actx.OtherModuleExists(name) && !actx.OtherModuleFarDependencyVariantExists(variations, name) {
+				if !inList(name, c.missingImageDeps) {
+					c.missingImageDeps = append(c.missingImageDeps, name)
+				}
+				continue
+			}
+			ret = append(ret, lib)
+		}
+		return ret
+	}
+
+	deps.HeaderLibs = filter(deps.HeaderLibs)
+	deps.WholeStaticLibs = filter(deps.WholeStaticLibs)
+	deps.StaticLibs = filter(deps.StaticLibs)
+	deps.SharedLibs = filter(deps.SharedLibs)
+	deps.RuntimeLibs = filter(deps.RuntimeLibs)
+
+	if len(c.missingImageDeps) > 0 {
+		check := getMissingDepsCheck(actx.Config(), imageDepsCheckKey)
+		check.lock.Lock()
+		defer check.lock.Unlock()
+		check.found = true
+	}
+}
+
+// imageDepsCheckMutator reports the dependencies removed by filterDepsMissingImageVariant.  Modules
+// are visited before their dependencies, so each missing dependency is reported on the module at
+// the top of the longest chain that reaches it, along with that chain.
+func imageDepsCheckMutator(mctx android.TopDownMutatorContext) {
+	check := getMissingDepsCheck(mctx.Config(), imageDepsCheckKey)
+	if !check.found {
+		return
+	}
+
+	m, ok := mctx.Module().(*Module)
+	if !ok || !(m.InRecovery() || m.InVendorRamdisk()) {
+		return
+	}
+
+	report := func(c *Module, path string) {
+		check.lock.Lock()
+		defer check.lock.Unlock()
+		if check.reported[c.String()] {
+			return
+		}
+		check.reported[c.String()] = true
+
+		image, property := c.imageAvailableProperty()
+		for _, dep := range c.missingImageDeps {
+			mctx.ModuleErrorf("%q depends on %q, which has no %s variant. Add `%s: true` to %q. Dependency path:\n%s",
+				c.BaseModuleName(), dep, image, property, dep, path)
+		}
+	}
+
+	mctx.WalkDeps(func(child, parent android.Module) bool {
+		c, ok := child.(*Module)
+		if !ok || !(c.InRecovery() || c.InVendorRamdisk()) {
+			return false
+		}
+		if len(c.missingImageDeps) > 0 {
+			report(c, mctx.GetPathString(false))
+		}
+		return true
+	})
+
+	if len(m.missingImageDeps) > 0 {
+		report(m, m.String())
+	}
 }
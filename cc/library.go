@@ -44,6 +44,28 @@ type LibraryProperties struct {
 	// rename host libraries to prevent overlap with system installed libraries
 	Unique_host_soname *bool
 
+	// Generate the version script of the shared library from its exported headers instead of
+	// maintaining a symbol map by hand. Functions and variables whose declarations in the headers
+	// of export_include_dirs are annotated with version_script_export_macro are exported, all other
+	// symbols are made local. Only symbols with C linkage are supported. Cannot be used together
+	// with version_script.
+	Generate_version_script_from_headers *bool
+
+	// The macro that annotates the exported declarations when generate_version_script_from_headers
+	// is set. Defaults to EXPORT.
+	Version_script_export_macro *string
+
+	// local file name of an allowlist of the symbols the shared library is expected to export, one
+	// per line.  When set, the build fails if the dynamic symbol table of the shared library
+	// contains any other defined symbol.  Lines starting with '#' are ignored.
+	Exported_symbols_allowlist *string `android:"path,arch_variant"`
+
+	// Verify that the symbols exported by the shared library match its version script. The build
+	// fails if the library exports a symbol that is not global in the version script, or if a
+	// symbol listed by name in the version script is not exported. Requires version_script or
+	// generate_version_script_from_headers.
+	Check_version_script_symbols *bool
+
 	Aidl struct {
 		// export headers generated from .aidl sources
 		Export_aidl_headers *bool
@@ -70,6 +92,11 @@ type LibraryProperties struct {
 		// List versions to generate stubs libs for. The version name "current" is always
 		// implicitly added.
 		Versions []string
+
+		// if true, vendor variants of this platform library are created from the stubs instead of
+		// the implementation, so that modules installed to /vendor link against the stable subset
+		// of the library described by symbol_file. The implementation stays on /system.
+		Vendor_available *bool
 	}
 
 	// set the name of the output
@@ -202,6 +229,19 @@ type FlagExporterProperties struct {
 	// list of plain cc flags to be used for any module that links against this module.
 	Export_cflags []string  `android:"arch_variant"`
 
+	// the minimum C++ standard version (such as "c++17") that the exported headers require. Modules
+	// that depend on this module directly with a lower cpp_std are reported in
+	// SOONG_MODULES_USING_LOWER_CPP_STD_THAN_DEPS.
+	Min_cpp_std *string
+
+	// if set to true, exported include directories that don't exist are allowed, for example
+	// because they are created later in the build, and are exported as is.
+	Allow_missing_include_dirs *bool
+
+	// if set to true, each exported include directory that doesn't contain any headers is
+	// reported as a warning when the library is built.
+	Warn_empty_include_dirs *bool
+
 	Target struct {
 		Vendor, Product struct {
 			// list of exported include directories, like
@@ -500,26 +540,77 @@ type flagExporter struct {
 	flags      []string      // Exported raw flags.
 	deps       android.Paths
 	headers    android.Paths
+
+	// The paths of the exported include directories and system include directories, resolved
+	// once by exportedIncludeDirs so that missing directories are only reported once.
+	includeDirs, systemIncludeDirs android.Paths
+	includeDirsResolved            bool
 }
 
-// exportedIncludes returns the effective include paths for this module and
-// any module that links against this module. This is obtained from
-// the export_include_dirs property in the appropriate target stanza.
-func (f *flagExporter) exportedIncludes(ctx ModuleContext) android.Paths {
+// exportedIncludesProperty returns the name and the value of the property that provides the
+// effective exported include paths, export_include_dirs or its override in the appropriate target
+// stanza.
+func (f *flagExporter) exportedIncludesProperty(ctx ModuleContext) (string, []string) {
 	if ctx.inVendor() && f.Properties.Target.Vendor.Override_export_include_dirs != nil {
-		return android.PathsForModuleSrc(ctx, f.Properties.Target.Vendor.Override_export_include_dirs)
+		return "target.vendor.override_export_include_dirs", f.Properties.Target.Vendor.Override_export_include_dirs
 	}
 	if ctx.inProduct() && f.Properties.Target.Product.Override_export_include_dirs != nil {
-		return android.PathsForModuleSrc(ctx, f.Properties.Target.Product.Override_export_include_dirs)
+		return "target.product.override_export_include_dirs", f.Properties.Target.Product.Override_export_include_dirs
+	}
+	return "export_include_dirs", f.Properties.Export_include_dirs
+}
+
+// exportedIncludeDirs returns the effective exported include paths and system include paths for
+// this module and any module that links against this module.
+func (f *flagExporter) exportedIncludeDirs(ctx ModuleContext) (dirs, systemDirs android.Paths) {
+	if !f.includeDirsResolved {
+		property, includeDirs := f.exportedIncludesProperty(ctx)
+		f.includeDirs = f.includeDirsForModuleSrc(ctx, property, includeDirs)
+		f.systemIncludeDirs = f.includeDirsForModuleSrc(ctx, "export_system_include_dirs",
+			f.Properties.Export_system_include_dirs)
+		f.includeDirsResolved = true
+	}
+	return f.includeDirs, f.systemIncludeDirs
+}
+
+// includeDirsForModuleSrc returns the paths of the include directories in the given property,
+// relative to the module directory.  A directory that doesn't exist is reported as an error on
+// the property, unless allow_missing_include_dirs is set.  The existence of the directories is
+// looked up with globs, which are cached and make Soong rerun when a directory is added or
+// removed.
+func (f *flagExporter) includeDirsForModuleSrc(ctx ModuleContext, property string, dirs []string) android.Paths {
+	var ret android.Paths
+	for _, dir := range dirs {
+		if m, _ := android.SrcIsModuleWithTag(dir); m != "" || ctx.Config().TestAllowNonExistentPaths {
+			ret = append(ret, android.PathsForModuleSrc(ctx, []string{dir})...)
+			continue
+		}
+		if path := android.ExistentPathForSource(ctx, ctx.ModuleDir(), dir); path.Valid() {
+			ret = append(ret, path.Path())
+		} else if Bool(f.Properties.Allow_missing_include_dirs) {
+			ret = append(ret, android.PathForSource(ctx, ctx.ModuleDir()).Join(ctx, dir))
+		} else {
+			ctx.PropertyErrorf(property, "include directory %q does not exist, "+
+				"set allow_missing_include_dirs: true if it is created later", dir)
+		}
 	}
-	return android.PathsForModuleSrc(ctx, f.Properties.Export_include_dirs)
+	return ret
+}
+
+// exportedIncludes returns the effective include paths for this module and
+// any module that links against this module. This is obtained from
+// the export_include_dirs property in the appropriate target stanza.
+func (f *flagExporter) exportedIncludes(ctx ModuleContext) android.Paths {
+	dirs, _ := f.exportedIncludeDirs(ctx)
+	return dirs
 }
 
 // exportIncludes registers the include directories and system include directories to be exported
 // transitively to modules depending on this module.
 func (f *flagExporter) exportIncludes(ctx ModuleContext) {
-	f.dirs = append(f.dirs, f.exportedIncludes(ctx)...)
-	f.systemDirs = append(f.systemDirs, android.PathsForModuleSrc(ctx, f.Properties.Export_system_include_dirs)...)
+	dirs, systemDirs := f.exportedIncludeDirs(ctx)
+	f.dirs = append(f.dirs, dirs...)
+	f.systemDirs = append(f.systemDirs, systemDirs...)
 }
 
 func (f *flagExporter) exportExtraFlags(ctx ModuleContext) {
@@ -530,8 +621,39 @@ func (f *flagExporter) exportExtraFlags(ctx ModuleContext) {
 // exported transitively both as system include directories to modules depending on this module.
 func (f *flagExporter) exportIncludesAsSystem(ctx ModuleContext) {
 	// all dirs are force exported as system
-	f.systemDirs = append(f.systemDirs, f.exportedIncludes(ctx)...)
-	f.systemDirs = append(f.systemDirs, android.PathsForModuleSrc(ctx, f.Properties.Export_system_include_dirs)...)
+	dirs, systemDirs := f.exportedIncludeDirs(ctx)
+	f.systemDirs = append(f.systemDirs, dirs...)
+	f.systemDirs = append(f.systemDirs, systemDirs...)
+}
+
+// checkExportedIncludeDirs returns a timestamp file created by a rule that warns about each of the
+// exported include directories that doesn't contain any headers, or nil if
+// warn_empty_include_dirs is not set.  It is meant to be used as a validation of the rule that
+// links the library.
+func (f *flagExporter) checkExportedIncludeDirs(ctx ModuleContext) android.Path {
+	if !Bool(f.Properties.Warn_empty_include_dirs) {
+		return nil
+	}
+	dirs, systemDirs := f.exportedIncludeDirs(ctx)
+	dirs = append(android.CopyOfPaths(dirs), systemDirs...)
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	flags := "-e '" + strings.Join(HeaderExts, " ") + "'"
+
+	checkFile := android.PathForModuleOut(ctx, "check_include_dirs.timestamp")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkIncludeDirs,
+		Description: "check include dirs",
+		Inputs:      dirs,
+		Output:      checkFile,
+		Args: map[string]string{
+			"flags":  flags,
+			"module": ctx.ModuleName(),
+		},
+	})
+	return checkFile
 }
 
 // reexportDirs registers the given directories as include directories to be exported transitively
@@ -567,6 +689,11 @@ func (f *flagExporter) addExportedGeneratedHeaders(headers ...android.Path) {
 }
 
 func (f *flagExporter) setProvider(ctx android.ModuleContext) {
+	minCppStd := String(f.Properties.Min_cpp_std)
+	if minCppStd != "" && cppStdVersionIndex(minCppStd) < 0 {
+		ctx.PropertyErrorf("min_cpp_std", "%q is not a C++ standard version supported by the toolchain", minCppStd)
+	}
+
 	ctx.SetProvider(FlagExporterInfoProvider, FlagExporterInfo{
 		// Comes from Export_include_dirs property, and those of exported transitive deps
 		IncludeDirs: android.FirstUniquePaths(f.dirs),
@@ -580,6 +707,8 @@ func (f *flagExporter) setProvider(ctx android.ModuleContext) {
 		// For exported generated headers, such as exported aidl headers, proto headers, or
 		// sysprop headers.
 		GeneratedHeaders: f.headers,
+		// Comes from Min_cpp_std property, used to report dependers that use a lower cpp_std.
+		MinCppStd: minCppStd,
 	})
 }
 
@@ -632,6 +761,10 @@ type libraryDecorator struct {
 
 	versionScriptPath android.OptionalPath
 
+	// Version script generated from the exported headers when
+	// generate_version_script_from_headers is set
+	generatedVersionScript android.OptionalPath
+
 	postInstallCmds []string
 
 	// If useCoreVariant is true, the vendor variant of a VNDK library is
@@ -1044,9 +1177,18 @@ func (library *libraryDecorator) compile(ctx ModuleContext, flags Flags, deps Pa
 			ctx.PropertyErrorf("symbol_file", "%q doesn't have .map.txt suffix", symbolFile)
 			return Objects{}
 		}
+		stubsVersion := library.MutatedProperties.StubsVersion
+		stubsFlag := "--apex"
+		if ctx.IsVendorStubs() {
+			// The unversioned vendor variant of a library with vendor stubs is the stub for
+			// the current API.
+			if stubsVersion == "" {
+				stubsVersion = android.FutureApiLevel.String()
+			}
+			stubsFlag = "--llndk"
+		}
 		nativeAbiResult := parseNativeAbiDefinition(ctx, symbolFile,
-			android.ApiLevelOrPanic(ctx, library.MutatedProperties.StubsVersion),
-			"--apex")
+			android.ApiLevelOrPanic(ctx, stubsVersion), stubsFlag)
 		objs := compileStubLibrary(ctx, flags, nativeAbiResult.stubSrc)
 		library.versionScriptPath = android.OptionalPathForPath(
 			nativeAbiResult.versionScript)
@@ -1154,6 +1296,7 @@ type versionedInterface interface {
 	hasLLNDKStubs() bool
 	hasLLNDKHeaders() bool
 	hasVendorPublicLibrary() bool
+	hasVendorStubs() bool
 }
 
 var _ libraryInterface = (*libraryDecorator)(nil)
@@ -1235,6 +1378,11 @@ func (library *libraryDecorator) compilerDeps(ctx DepsContext, deps Deps) Deps {
 		// LLNDK-specific properties instead.
 		return deps
 	}
+	if ctx.IsVendorStubs() {
+		// The vendor stubs are generated from stubs.symbol_file and don't need the
+		// dependencies of the implementation, which may not have vendor variants.
+		return deps
+	}
 
 	deps = library.baseCompiler.compilerDeps(ctx, deps)
 
@@ -1255,6 +1403,9 @@ func (library *libraryDecorator) linkerDeps(ctx DepsContext, deps Deps) Deps {
 		deps.ReexportHeaderLibHeaders = append([]string(nil), headers...)
 		return deps
 	}
+	if ctx.IsVendorStubs() {
+		return deps
+	}
 
 	if library.static() {
 		// Compare with nil because an empty list needs to be propagated.
@@ -1380,7 +1531,12 @@ func (library *libraryDecorator) linkStatic(ctx ModuleContext,
 		}
 	}
 
-	transformObjToStaticLib(ctx, library.objects.objFiles, deps.WholeStaticLibsFromPrebuilts, builderFlags, outputFile, nil, objs.tidyDepFiles)
+	validations := android.CopyOfPaths(objs.tidyDepFiles)
+	if includeDirsCheck := library.checkExportedIncludeDirs(ctx); includeDirsCheck != nil {
+		validations = append(validations, includeDirsCheck)
+	}
+
+	transformObjToStaticLib(ctx, library.objects.objFiles, deps.WholeStaticLibsFromPrebuilts, builderFlags, outputFile, nil, validations)
 
 	library.coverageOutputFile = transformCoverageFilesToZip(ctx, library.objects, ctx.ModuleName())
 
@@ -1450,6 +1606,11 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 			linkerDeps = append(linkerDeps, forceWeakSymbols.Path())
 		}
 	}
+	// The stubs variants already have a version script generated from the symbol file.
+	if Bool(library.Properties.Generate_version_script_from_headers) && !library.versionScriptPath.Valid() {
+		library.generatedVersionScript = library.generateVersionScriptFromHeaders(ctx)
+		library.versionScriptPath = library.generatedVersionScript
+	}
 	if library.versionScriptPath.Valid() {
 		linkerScriptFlags := "-Wl,--version-script," + library.versionScriptPath.String()
 		flags.Local.LdFlags = append(flags.Local.LdFlags, linkerScriptFlags)
@@ -1468,6 +1629,12 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 		implicitOutputs = append(implicitOutputs, importLibraryPath)
 	}
 
+	if mapFile := library.addMapFile(ctx, &flags, fileName); mapFile != nil {
+		implicitOutputs = append(implicitOutputs, mapFile)
+	}
+	library.addGcSectionsReport(ctx, &flags)
+	library.addLinkDiagnosis(ctx, &flags)
+
 	builderFlags := flagsToBuilderFlags(flags)
 
 	if ctx.Darwin() && deps.DarwinSecondArchOutput.Valid() {
@@ -1526,9 +1693,22 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 	linkerDeps = append(linkerDeps, deps.EarlySharedLibsDeps...)
 	linkerDeps = append(linkerDeps, deps.SharedLibsDeps...)
 	linkerDeps = append(linkerDeps, deps.LateSharedLibsDeps...)
+
+	validations := android.CopyOfPaths(objs.tidyDepFiles)
+	if exportedSymbolsCheck := library.exportedSymbolsCheck(ctx, outputFile); exportedSymbolsCheck != nil {
+		validations = append(validations, exportedSymbolsCheck)
+	}
+	if versionScriptSymbolsCheck := library.versionScriptSymbolsCheck(ctx, outputFile); versionScriptSymbolsCheck != nil {
+		validations = append(validations, versionScriptSymbolsCheck)
+	}
+	if includeDirsCheck := library.checkExportedIncludeDirs(ctx); includeDirsCheck != nil {
+		validations = append(validations, includeDirsCheck)
+	}
+
+	library.setGcSectionsReportInputs(objs.objFiles, deps)
 	transformObjToDynamicBinary(ctx, objs.objFiles, sharedLibs,
 		deps.StaticLibs, deps.LateStaticLibs, deps.WholeStaticLibs,
-		linkerDeps, deps.CrtBegin, deps.CrtEnd, false, builderFlags, outputFile, implicitOutputs, objs.tidyDepFiles)
+		linkerDeps, deps.CrtBegin, deps.CrtEnd, false, builderFlags, outputFile, implicitOutputs, validations)
 
 	objs.coverageFiles = append(objs.coverageFiles, deps.StaticLibObjs.coverageFiles...)
 	objs.coverageFiles = append(objs.coverageFiles, deps.WholeStaticLibObjs.coverageFiles...)
@@ -1574,6 +1754,84 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 	return unstrippedOutputFile
 }
 
+// generateVersionScriptFromHeaders generates a version script that exports the functions and
+// variables annotated with the export macro in the exported headers of the library.
+func (library *libraryDecorator) generateVersionScriptFromHeaders(ctx ModuleContext) android.OptionalPath {
+	linkerProps := library.baseLinker.Properties
+	if linkerProps.Version_script != nil || linkerProps.Target.Vendor.Version_script != nil ||
+		linkerProps.Target.Product.Version_script != nil {
+		ctx.PropertyErrorf("generate_version_script_from_headers", "cannot be set with version_script")
+		return android.OptionalPath{}
+	}
+	if ctx.Darwin() {
+		ctx.PropertyErrorf("generate_version_script_from_headers", "Not supported on Darwin")
+		return android.OptionalPath{}
+	}
+
+	headers := GlobHeadersForSnapshot(ctx, library.flagExporter.exportedIncludes(ctx))
+	if len(headers) == 0 {
+		ctx.PropertyErrorf("generate_version_script_from_headers",
+			"no headers found in the exported include directories")
+		return android.OptionalPath{}
+	}
+
+	exportMacro := String(library.Properties.Version_script_export_macro)
+	if exportMacro == "" {
+		exportMacro = "EXPORT"
+	}
+	versionScript := android.PathForModuleOut(ctx, library.getLibName(ctx)+".map.txt")
+	transformHeadersToVersionScript(ctx, headers, exportMacro, versionScript)
+	return android.OptionalPathForPath(versionScript)
+}
+
+// exportedSymbolsCheck returns a timestamp file created by a rule that verifies the shared library
+// only exports the symbols listed in exported_symbols_allowlist, or nil if it is not set.  The
+// timestamp is meant to be used as a validation of the link rule so that the check runs whenever
+// the shared library is built.
+func (library *libraryDecorator) exportedSymbolsCheck(ctx ModuleContext, sharedLib android.Path) android.Path {
+	allowlist := ctx.ExpandOptionalSource(library.Properties.Exported_symbols_allowlist, "exported_symbols_allowlist")
+	if !allowlist.Valid() || library.buildStubs() {
+		return nil
+	}
+	if ctx.Darwin() || ctx.Windows() {
+		ctx.PropertyErrorf("exported_symbols_allowlist", "Only supported for ELF shared libraries")
+		return nil
+	}
+
+	checkFile := android.PathForModuleOut(ctx, "exported_symbols_check", sharedLib.Base()+".timestamp")
+	transformSharedObjectToExportedSymbolsCheck(ctx, sharedLib, allowlist.Path(), checkFile)
+	return checkFile
+}
+
+// versionScriptSymbolsCheck returns a timestamp file created by a rule that verifies the symbols
+// exported by the shared library match its version scripts, or nil if check_version_script_symbols
+// is not set.  Like exportedSymbolsCheck it is meant to be used as a validation of the link rule.
+func (library *libraryDecorator) versionScriptSymbolsCheck(ctx ModuleContext, sharedLib android.Path) android.Path {
+	if !Bool(library.Properties.Check_version_script_symbols) || library.buildStubs() {
+		return nil
+	}
+
+	versionScripts := android.CopyOfPaths(library.baseLinker.versionScripts)
+	if library.versionScriptPath.Valid() {
+		versionScripts = append(versionScripts, library.versionScriptPath.Path())
+	}
+	if len(versionScripts) == 0 {
+		ctx.PropertyErrorf("check_version_script_symbols",
+			"requires version_script or generate_version_script_from_headers")
+		return nil
+	}
+
+	checkFile := android.PathForModuleOut(ctx, "version_script_symbols_check", sharedLib.Base()+".timestamp")
+	transformSharedObjectToVersionScriptSymbolsCheck(ctx, sharedLib, versionScripts, checkFile)
+	return checkFile
+}
+
+// generatedVersionScriptFile returns the version script generated from the exported headers, if
+// any.
+func (library *libraryDecorator) generatedVersionScriptFile() android.OptionalPath {
+	return library.generatedVersionScript
+}
+
 func (library *libraryDecorator) unstrippedOutputFilePath() android.Path {
 	return library.unstrippedOutputFile
 }
@@ -1704,6 +1962,12 @@ func (library *libraryDecorator) link(ctx ModuleContext,
 		// override the module's export_include_dirs with llndk.override_export_include_dirs
 		// if it is set.
 		if override := library.Properties.Llndk.Override_export_include_dirs; override != nil {
+			if Bool(library.Properties.Llndk.Check_divergence) {
+				implDirs := library.flagExporter.Properties.Export_include_dirs
+				if timestamp := checkLlndkHeaderDivergence(ctx, flags, override, implDirs); timestamp != nil {
+					library.reexportDeps(timestamp)
+				}
+			}
 			library.flagExporter.Properties.Export_include_dirs = override
 		}
 
@@ -1991,6 +2255,12 @@ func (library *libraryDecorator) hasVendorPublicLibrary() bool {
 	return String(library.Properties.Vendor_public_library.Symbol_file) != ""
 }
 
+// hasVendorStubs returns true if this cc_library module has vendor variants that will build stubs
+// from stubs.symbol_file.
+func (library *libraryDecorator) hasVendorStubs() bool {
+	return Bool(library.Properties.Stubs.Vendor_available) && library.hasStubsVariants()
+}
+
 func (library *libraryDecorator) implementationModuleName(name string) string {
 	return name
 }
@@ -2244,10 +2514,13 @@ func LinkageMutator(mctx android.BottomUpMutatorContext) {
 		}
 
 		isLLNDK := false
+		isVendorStubs := false
 		if m, ok := mctx.Module().(*Module); ok {
 			isLLNDK = m.IsLlndk()
+			isVendorStubs = m.IsVendorStubs()
 		}
-		buildStatic := library.BuildStaticVariant() && !isLLNDK
+		// Vendor stubs only provide a shared library to link against.
+		buildStatic := library.BuildStaticVariant() && !isLLNDK && !isVendorStubs
 		buildShared := library.BuildSharedVariant()
 		if buildStatic && buildShared {
 			variations := append([]string{"static", "shared"}, variations...)
@@ -2309,11 +2582,12 @@ func createVersionVariations(mctx android.BottomUpMutatorContext, versions []str
 	m := mctx.Module().(*Module)
 	isLLNDK := m.IsLlndk()
 	isVendorPublicLibrary := m.IsVendorPublicLibrary()
+	isVendorStubs := m.IsVendorStubs()
 
 	modules := mctx.CreateLocalVariations(variants...)
 	for i, m := range modules {
 
-		if variants[i] != "" || isLLNDK || isVendorPublicLibrary {
+		if variants[i] != "" || isLLNDK || isVendorPublicLibrary || isVendorStubs {
 			// A stubs or LLNDK stubs variant.
 			c := m.(*Module)
 			c.sanitize = nil
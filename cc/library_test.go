@@ -335,6 +335,261 @@ func TestLibraryDynamicList(t *testing.T) {
 
 }
 
+var prepareForGeneratedVersionScriptTest = android.GroupFixturePreparers(
+	prepareForCcTest,
+	android.FixtureMergeMockFs(android.MockFS{
+		"foo/foo.c":                 nil,
+		"foo/foo.map.txt":           nil,
+		"foo/include/foo.h":         nil,
+		"foo/include/foo/private.h": nil,
+		"foo/include/README.md":     nil,
+	}),
+)
+
+func TestLibraryGeneratedVersionScript(t *testing.T) {
+	bp := `
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			export_include_dirs: ["include"],
+			generate_version_script_from_headers: true,
+			version_script_export_macro: "FOO_EXPORT",
+		}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForGeneratedVersionScriptTest,
+		android.FixtureAddTextFile("foo/Android.bp", bp),
+	).RunTest(t)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+
+	genVersionScript := libfoo.Rule("genVersionScript")
+	android.AssertPathsRelativeToTopEquals(t, "version script inputs",
+		[]string{"foo/include/foo.h", "foo/include/foo/private.h"}, genVersionScript.Inputs)
+	android.AssertStringEquals(t, "export macro", "FOO_EXPORT", genVersionScript.Args["exportMacro"])
+
+	versionScript := "out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/libfoo.map.txt"
+	android.AssertPathRelativeToTopEquals(t, "version script", versionScript, genVersionScript.Output)
+
+	ld := libfoo.Rule("ld")
+	android.AssertStringListContains(t, "missing dependency on generated version script",
+		ld.Implicits.Strings(), versionScript)
+	android.AssertStringDoesContain(t, "missing flag for generated version script",
+		ld.Args["ldFlags"], "-Wl,--version-script,"+versionScript)
+
+	android.AssertPathsRelativeToTopEquals(t, "version script output file",
+		[]string{versionScript}, libfoo.OutputFiles(t, ".version_script"))
+
+	// The static variant is not linked so it has no version script.
+	libfooStatic := result.ModuleForTests("libfoo", "android_arm64_armv8-a_static")
+	if libfooStatic.MaybeRule("genVersionScript").Rule != nil {
+		t.Errorf("unexpected version script for the static variant")
+	}
+}
+
+func TestLibraryGeneratedVersionScriptErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		bp    string
+		error string
+	}{
+		{
+			name: "with version_script",
+			bp: `
+				cc_library_shared {
+					name: "libfoo",
+					srcs: ["foo.c"],
+					export_include_dirs: ["include"],
+					version_script: "foo.map.txt",
+					generate_version_script_from_headers: true,
+				}
+			`,
+			error: `module "libfoo".*: generate_version_script_from_headers: cannot be set with version_script`,
+		},
+		{
+			name: "no headers",
+			bp: `
+				cc_library_shared {
+					name: "libfoo",
+					srcs: ["foo.c"],
+					generate_version_script_from_headers: true,
+				}
+			`,
+			error: `module "libfoo".*: generate_version_script_from_headers: no headers found in the exported include directories`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			android.GroupFixturePreparers(
+				prepareForGeneratedVersionScriptTest,
+				android.FixtureAddTextFile("foo/Android.bp", tc.bp),
+			).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(tc.error)).
+				RunTest(t)
+		})
+	}
+}
+
+func TestLibraryExportedSymbolsAllowlist(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddFile("foo/foo.c", nil),
+		android.FixtureAddFile("foo/exported_symbols.txt", nil),
+		android.FixtureAddTextFile("foo/Android.bp", `
+			cc_library {
+				name: "libfoo",
+				srcs: ["foo.c"],
+				cflags_visibility: "hidden",
+				exported_symbols_allowlist: "exported_symbols.txt",
+			}
+		`),
+	).RunTest(t)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	check := libfoo.Rule("checkExportedSymbols")
+	ld := libfoo.Rule("ld")
+
+	android.AssertPathRelativeToTopEquals(t, "check input", android.PathRelativeToTop(ld.Output), check.Input)
+	android.AssertPathsRelativeToTopEquals(t, "check implicits",
+		[]string{"foo/exported_symbols.txt"}, check.Implicits)
+	android.AssertStringEquals(t, "allowlist", "foo/exported_symbols.txt", check.Args["allowlist"])
+
+	checkFile := "out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/exported_symbols_check/libfoo.so.timestamp"
+	android.AssertPathRelativeToTopEquals(t, "check output", checkFile, check.Output)
+	android.AssertPathsRelativeToTopEquals(t, "link validations", []string{checkFile}, ld.Validations)
+
+	// The static variant is not linked so it is not checked.
+	libfooStatic := result.ModuleForTests("libfoo", "android_arm64_armv8-a_static")
+	if libfooStatic.MaybeRule("checkExportedSymbols").Rule != nil {
+		t.Errorf("unexpected exported symbols check for the static variant")
+	}
+}
+
+func TestLibraryCheckVersionScriptSymbols(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForGeneratedVersionScriptTest,
+		android.FixtureAddTextFile("foo/Android.bp", `
+			cc_library {
+				name: "libfoo",
+				srcs: ["foo.c"],
+				version_script: "foo.map.txt",
+				check_version_script_symbols: true,
+			}
+
+			cc_library_shared {
+				name: "libbar",
+				srcs: ["foo.c"],
+				export_include_dirs: ["include"],
+				generate_version_script_from_headers: true,
+				check_version_script_symbols: true,
+			}
+		`),
+	).RunTest(t)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	check := libfoo.Rule("checkVersionScriptSymbols")
+	ld := libfoo.Rule("ld")
+
+	android.AssertPathRelativeToTopEquals(t, "check input", android.PathRelativeToTop(ld.Output), check.Input)
+	android.AssertPathsRelativeToTopEquals(t, "check implicits", []string{"foo/foo.map.txt"}, check.Implicits)
+	android.AssertStringEquals(t, "version script flags", "--version-script foo/foo.map.txt",
+		check.Args["versionScriptFlags"])
+
+	checkFile := "out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/version_script_symbols_check/libfoo.so.timestamp"
+	android.AssertPathRelativeToTopEquals(t, "check output", checkFile, check.Output)
+	android.AssertPathsRelativeToTopEquals(t, "link validations", []string{checkFile}, ld.Validations)
+
+	// The static variant is not linked so it is not checked.
+	libfooStatic := result.ModuleForTests("libfoo", "android_arm64_armv8-a_static")
+	if libfooStatic.MaybeRule("checkVersionScriptSymbols").Rule != nil {
+		t.Errorf("unexpected version script symbols check for the static variant")
+	}
+
+	// A version script generated from the headers is checked too.
+	libbarCheck := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared").Rule("checkVersionScriptSymbols")
+	android.AssertPathsRelativeToTopEquals(t, "generated version script",
+		[]string{"out/soong/.intermediates/foo/libbar/android_arm64_armv8-a_shared/libbar.map.txt"},
+		libbarCheck.Implicits)
+}
+
+func TestLibraryCheckVersionScriptSymbolsVersionScripts(t *testing.T) {
+	// The symbols are only compared when the check runs, which is covered by
+	// check_version_script_symbols_test using the same kinds of version scripts.  These cases
+	// verify that the check is given every version script the library is linked with.
+	testCases := []struct {
+		name           string
+		props          string
+		versionScripts []string
+	}{
+		{
+			name:           "passing map",
+			props:          `version_script: "foo.map.txt",`,
+			versionScripts: []string{"foo/foo.map.txt"},
+		},
+		{
+			// With CFI the library also exports __cfi_check, which is not in its own version
+			// script and would be reported as unexpected without the CFI exports map.
+			name: "extra exported symbol",
+			props: `
+				version_script: "foo.map.txt",
+				sanitize: {
+					cfi: true,
+				},
+			`,
+			versionScripts: []string{"foo/foo.map.txt", "build/soong/cc/config/cfi_exports.map"},
+		},
+		{
+			name:           "glob",
+			props:          `version_script: "foo_glob.map.txt",`,
+			versionScripts: []string{"foo/foo_glob.map.txt"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := android.GroupFixturePreparers(
+				prepareForGeneratedVersionScriptTest,
+				android.FixtureMergeMockFs(android.MockFS{
+					"foo/foo_glob.map.txt":                  []byte("LIBFOO { global: foo_*; local: *; };\n"),
+					"build/soong/cc/config/cfi_exports.map": nil,
+				}),
+				android.FixtureAddTextFile("foo/Android.bp", `
+					cc_library_shared {
+						name: "libfoo",
+						srcs: ["foo.c"],
+						check_version_script_symbols: true,
+						`+tc.props+`
+					}
+				`),
+			).RunTest(t)
+
+			libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+			check := libfoo.Rule("checkVersionScriptSymbols")
+			android.AssertPathsRelativeToTopEquals(t, "version scripts", tc.versionScripts, check.Implicits)
+			android.AssertStringEquals(t, "version script flags",
+				android.JoinWithPrefix(tc.versionScripts, "--version-script "), check.Args["versionScriptFlags"])
+			android.AssertPathsRelativeToTopEquals(t, "link validations",
+				[]string{"out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/version_script_symbols_check/libfoo.so.timestamp"},
+				libfoo.Rule("ld").Validations)
+		})
+	}
+}
+
+func TestLibraryCheckVersionScriptSymbolsWithoutVersionScript(t *testing.T) {
+	android.GroupFixturePreparers(
+		prepareForGeneratedVersionScriptTest,
+		android.FixtureAddTextFile("foo/Android.bp", `
+			cc_library_shared {
+				name: "libfoo",
+				srcs: ["foo.c"],
+				check_version_script_symbols: true,
+			}
+		`),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`module "libfoo".*: check_version_script_symbols: requires version_script or generate_version_script_from_headers`)).
+		RunTest(t)
+}
+
 func TestCcLibrarySharedWithBazel(t *testing.T) {
 	bp := `
 cc_library_shared {
@@ -453,3 +708,137 @@ func TestWholeStaticLibPrebuilts(t *testing.T) {
 	android.AssertStringDoesContain(t, "missing flag for baz.o",
 		libtransitiveWithSrcs.Args["arObjs"], bazObj.Output.String())
 }
+
+func TestStaticLibraryDuplicateObjectBasenames(t *testing.T) {
+	result := PrepareForIntegrationTestWithCc.RunTestWithBp(t, `
+		cc_library_static {
+			name: "libdup",
+			srcs: [
+				"a/util.c",
+				"b/util.c",
+				"a/util.cpp",
+			],
+		}
+
+		cc_library_static {
+			name: "libwhole",
+			whole_static_libs: ["libdup"],
+		}
+	`)
+
+	objDir := "out/soong/.intermediates/libdup/android_arm64_armv8-a_static/obj/"
+	expectedObjs := []string{
+		objDir + "a/util.o",
+		objDir + "b/util.o",
+		objDir + "a/util.cpp.o",
+	}
+
+	libdup := result.ModuleForTests("libdup", "android_arm64_armv8-a_static").Rule("ar")
+	android.AssertPathsRelativeToTopEquals(t, "libdup archive inputs", expectedObjs, libdup.Inputs)
+
+	// Both util objects must survive when libdup is consumed through whole_static_libs.
+	libwhole := result.ModuleForTests("libwhole", "android_arm64_armv8-a_static").Rule("ar")
+	android.AssertPathsRelativeToTopEquals(t, "libwhole archive inputs", expectedObjs, libwhole.Inputs)
+}
+
+var prepareForExportedIncludeDirsTest = android.GroupFixturePreparers(
+	prepareForCcTest,
+	android.PrepareForTestDisallowNonExistentPaths,
+	android.FixtureMergeMockFs(android.MockFS{
+		"foo/include/foo.h":       nil,
+		"foo/include_cxx/vector":  nil,
+		"foo/empty/README.md":     nil,
+		"foo/system_include/b.h":  nil,
+		"foo/nested/sub/nested.h": nil,
+	}),
+)
+
+func TestExportedIncludeDirs(t *testing.T) {
+	bp := `
+		cc_library_headers {
+			name: "libfoo_headers",
+			export_include_dirs: [
+				"include",
+				"include_cxx",
+				"nested",
+				"empty",
+			],
+			export_system_include_dirs: ["system_include"],
+			warn_empty_include_dirs: true,
+		}
+
+		cc_library_headers {
+			name: "libbar_headers",
+			export_include_dirs: ["include", "generated"],
+			allow_missing_include_dirs: true,
+		}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForExportedIncludeDirsTest,
+		android.FixtureAddTextFile("foo/Android.bp", bp),
+	).RunTest(t)
+
+	foo := result.ModuleForTests("libfoo_headers", "android_arm64_armv8-a")
+	info := result.ModuleProvider(foo.Module(), FlagExporterInfoProvider).(FlagExporterInfo)
+	android.AssertPathsRelativeToTopEquals(t, "include dirs",
+		[]string{"foo/include", "foo/include_cxx", "foo/nested", "foo/empty"}, info.IncludeDirs)
+	android.AssertPathsRelativeToTopEquals(t, "system include dirs",
+		[]string{"foo/system_include"}, info.SystemIncludeDirs)
+
+	// With warn_empty_include_dirs the directories are checked for headers at build time.
+	check := foo.Output("check_include_dirs.timestamp")
+	android.AssertPathsRelativeToTopEquals(t, "checked dirs",
+		[]string{"foo/include", "foo/include_cxx", "foo/nested", "foo/empty", "foo/system_include"}, check.Inputs)
+	android.AssertStringDoesContain(t, "header extensions", check.Args["flags"], "-e '.h .hh ")
+	android.AssertStringListContains(t, "check is a validation of the library",
+		android.PathsRelativeToTop(foo.Output("libfoo_headers.a").Validations), android.PathRelativeToTop(check.Output))
+
+	// A missing directory is exported as is with allow_missing_include_dirs, and the directories
+	// are not checked for headers without warn_empty_include_dirs.
+	bar := result.ModuleForTests("libbar_headers", "android_arm64_armv8-a")
+	info = result.ModuleProvider(bar.Module(), FlagExporterInfoProvider).(FlagExporterInfo)
+	android.AssertPathsRelativeToTopEquals(t, "allowed missing include dirs",
+		[]string{"foo/include", "foo/generated"}, info.IncludeDirs)
+	if check := bar.MaybeOutput("check_include_dirs.timestamp"); check.Rule != nil {
+		t.Errorf("expected no check_include_dirs rule without warn_empty_include_dirs")
+	}
+}
+
+func TestExportedIncludeDirsMissing(t *testing.T) {
+	testCases := []struct {
+		name     string
+		bp       string
+		expected string
+	}{
+		{
+			name: "missing include dir",
+			bp: `
+				cc_library_headers {
+					name: "libfoo_headers",
+					export_include_dirs: ["include", "includ"],
+				}
+			`,
+			expected: `module "libfoo_headers".*: export_include_dirs: include directory "includ" does not exist, set allow_missing_include_dirs: true`,
+		},
+		{
+			name: "missing system include dir",
+			bp: `
+				cc_library_headers {
+					name: "libfoo_headers",
+					export_system_include_dirs: ["system_includ"],
+				}
+			`,
+			expected: `module "libfoo_headers".*: export_system_include_dirs: include directory "system_includ" does not exist`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			android.GroupFixturePreparers(
+				prepareForExportedIncludeDirsTest,
+				android.FixtureAddTextFile("foo/Android.bp", tc.bp),
+			).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(tc.expected)).
+				RunTest(t)
+		})
+	}
+}
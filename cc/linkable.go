@@ -155,6 +155,10 @@ type LinkableInterface interface {
 	// NeedsVendorPublicLibraryVariants returns true if this module has vendor public library stubs.
 	NeedsVendorPublicLibraryVariants() bool
 
+	// NeedsVendorStubsVariants returns true if this module has vendor variants built from its
+	// stubs.
+	NeedsVendorStubsVariants() bool
+
 	//StubsVersion returns the stubs version for this module.
 	StubsVersion() string
 
@@ -177,6 +181,7 @@ type LinkableInterface interface {
 	IsVndkExt() bool
 	IsVndkPrivate() bool
 	IsVendorPublicLibrary() bool
+	IsVendorStubs() bool
 	IsVndkPrebuiltLibrary() bool
 	HasVendorVariant() bool
 	HasProductVariant() bool
@@ -384,6 +389,7 @@ type FlagExporterInfo struct {
 	Flags             []string      // Exported raw flags.
 	Deps              android.Paths
 	GeneratedHeaders  android.Paths
+	MinCppStd         string // Minimum C++ standard version required by the exported headers.
 }
 
 var FlagExporterInfoProvider = blueprint.NewProvider(FlagExporterInfo{})
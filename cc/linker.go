@@ -232,6 +232,27 @@ type BaseLinkerProperties struct {
 
 	// list of shared libs that should not be used to build this module
 	Exclude_shared_libs []string `android:"arch_variant"`
+
+	// Generate a linker map file for this module, which can be referenced with the ".map" output
+	// tag.  Map files are generated for all modules when GenerateLinkerMapFiles is set in the
+	// product configuration.
+	Generate_map_file *bool `android:"arch_variant"`
+
+	// Write the sections that the linker removes with --gc-sections to <module>.gc_report.txt, and
+	// include the module in the summary of removed sections created by the gc-sections-report
+	// target.  The linked output is not affected.
+	Generate_gc_sections_report *bool `android:"arch_variant"`
+
+	// list of modules in static_libs that are linked before the other static libs, in the listed
+	// order, instead of in the order of their dependencies on each other.  Used to choose which
+	// archive the linker takes a symbol from when it is defined by more than one of them.
+	Static_libs_first []string `android:"arch_variant"`
+
+	// If the link fails, link again with -Wl,--warn-backrefs and write its warnings, followed by the
+	// symbols defined by each static library, to <module>.link_diagnosis.txt, which is referenced
+	// by the error message.  Used to debug link failures caused by duplicate symbols or by the
+	// order of the static libraries.  The linked output is not affected.
+	Diagnose_duplicate_symbols *bool `android:"arch_variant"`
 }
 
 func invertBoolPtr(value *bool) *bool {
@@ -272,6 +293,21 @@ type baseLinker struct {
 	}
 
 	sanitize *sanitize
+
+	// Location of the linker map file, if one was generated.
+	mapFile android.OptionalPath
+
+	// Location of the report of sections removed by --gc-sections, if one was generated.
+	gcSectionsReport android.OptionalPath
+
+	// The object files and static libraries of the link that the report refers to.
+	gcSectionsReportInputs android.Paths
+
+	// Location of the diagnosis written when the link fails, if one was requested.
+	linkDiagnosis android.OptionalPath
+
+	// The version scripts passed to the linker from the version_script properties.
+	versionScripts android.Paths
 }
 
 func (linker *baseLinker) appendLdflags(flags []string) {
@@ -582,12 +618,14 @@ func (linker *baseLinker) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 				flags.Local.LdFlags = append(flags.Local.LdFlags,
 					"-Wl,--version-script,"+versionScript.String())
 				flags.LdFlagsDeps = append(flags.LdFlagsDeps, versionScript.Path())
+				linker.versionScripts = append(linker.versionScripts, versionScript.Path())
 
 				if linker.sanitize.isSanitizerEnabled(cfi) {
 					cfiExportsMap := android.PathForSource(ctx, cfiExportsMapPath)
 					flags.Local.LdFlags = append(flags.Local.LdFlags,
 						"-Wl,--version-script,"+cfiExportsMap.String())
 					flags.LdFlagsDeps = append(flags.LdFlagsDeps, cfiExportsMap)
+					linker.versionScripts = append(linker.versionScripts, cfiExportsMap)
 				}
 			}
 		}
@@ -626,6 +664,81 @@ func (linker *baseLinker) linkerSpecifiedDeps(specifiedDeps specifiedDeps) speci
 	return specifiedDeps
 }
 
+// addMapFile configures the link of fileName to write a linker map file if it was requested by
+// the generate_map_file property or the product configuration, and returns the path to the map
+// file so that it can be added to the implicit outputs of the link, or nil.
+func (linker *baseLinker) addMapFile(ctx ModuleContext, flags *Flags, fileName string) android.WritablePath {
+	if ctx.Darwin() || ctx.Windows() {
+		return nil
+	}
+	if !Bool(linker.Properties.Generate_map_file) && !ctx.Config().GenerateLinkerMapFiles() {
+		return nil
+	}
+	mapFile := android.PathForModuleOut(ctx, fileName+".map")
+	flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,-Map="+mapFile.String())
+	linker.mapFile = android.OptionalPathForPath(mapFile)
+	return mapFile
+}
+
+func (linker *baseLinker) linkerMapFile() android.OptionalPath {
+	return linker.mapFile
+}
+
+// addGcSectionsReport configures the link to write the sections removed by --gc-sections to a
+// report if it was requested by the generate_gc_sections_report property.
+func (linker *baseLinker) addGcSectionsReport(ctx ModuleContext, flags *Flags) {
+	if ctx.Darwin() || ctx.Windows() || !Bool(linker.Properties.Generate_gc_sections_report) {
+		return
+	}
+	report := android.PathForModuleOut(ctx, ctx.ModuleName()+".gc_report.txt")
+	flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--print-gc-sections")
+	flags.GcSectionsReport = report
+	linker.gcSectionsReport = android.OptionalPathForPath(report)
+}
+
+func (linker *baseLinker) linkerGcSectionsReport() android.OptionalPath {
+	return linker.gcSectionsReport
+}
+
+// setGcSectionsReportInputs records the object files and static libraries of the link, whose
+// section sizes are read when summarizing the report.
+func (linker *baseLinker) setGcSectionsReportInputs(objFiles android.Paths, deps PathDeps) {
+	if !linker.gcSectionsReport.Valid() {
+		return
+	}
+	var inputs android.Paths
+	inputs = append(inputs, objFiles...)
+	inputs = append(inputs, deps.StaticLibs...)
+	inputs = append(inputs, deps.LateStaticLibs...)
+	inputs = append(inputs, deps.WholeStaticLibs...)
+	inputs = append(inputs, deps.CrtBegin...)
+	inputs = append(inputs, deps.CrtEnd...)
+	linker.gcSectionsReportInputs = android.FirstUniquePaths(inputs)
+}
+
+func (linker *baseLinker) linkerGcSectionsReportInputs() android.Paths {
+	return linker.gcSectionsReportInputs
+}
+
+// addLinkDiagnosis configures the link to write a diagnosis of a link failure if it was requested
+// by the diagnose_duplicate_symbols property.
+func (linker *baseLinker) addLinkDiagnosis(ctx ModuleContext, flags *Flags) {
+	if ctx.Darwin() || ctx.Windows() || !Bool(linker.Properties.Diagnose_duplicate_symbols) {
+		return
+	}
+	if flags.GcSectionsReport != nil {
+		ctx.PropertyErrorf("diagnose_duplicate_symbols", "cannot be used with generate_gc_sections_report")
+		return
+	}
+	diagnosis := android.PathForModuleOut(ctx, ctx.ModuleName()+".link_diagnosis.txt")
+	flags.LinkDiagnosis = diagnosis
+	linker.linkDiagnosis = android.OptionalPathForPath(diagnosis)
+}
+
+func (linker *baseLinker) staticLibsFirst() []string {
+	return linker.Properties.Static_libs_first
+}
+
 // Injecting version symbols
 // Some host modules want a version number, but we don't want to rebuild it every time.  Optionally add a step
 // after linking that injects a constant placeholder with the current version number.
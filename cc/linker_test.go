@@ -0,0 +1,144 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+const staticLibsFirstTestBp = `
+	cc_library_static {
+		name: "libb",
+		stl: "none",
+	}
+
+	cc_library_static {
+		name: "libc_static",
+		static_libs: ["libb"],
+		stl: "none",
+	}
+
+	cc_library_static {
+		name: "libd",
+		stl: "none",
+	}
+`
+
+// linkedStaticLibs returns the names of the given static libraries in the order they are passed
+// to the link of the module.
+func linkedStaticLibs(ctx *android.TestContext, module, variant string, libs ...string) []string {
+	var linked []string
+	libFlags := ctx.ModuleForTests(module, variant).Rule("ld").Args["libFlags"]
+	for _, flag := range strings.Fields(libFlags) {
+		if lib := strings.TrimSuffix(filepath.Base(flag), ".a"); android.InList(lib, libs) {
+			linked = append(linked, lib)
+		}
+	}
+	return linked
+}
+
+func TestStaticLibsFirst(t *testing.T) {
+	t.Parallel()
+	ctx := testCc(t, staticLibsFirstTestBp+`
+		cc_library_shared {
+			name: "libdefault",
+			static_libs: ["libb", "libc_static", "libd"],
+			stl: "none",
+		}
+
+		cc_library_shared {
+			name: "libfoo",
+			static_libs: ["libb", "libc_static", "libd"],
+			static_libs_first: ["libd", "libb"],
+			stl: "none",
+		}
+	`)
+
+	libs := []string{"libb", "libc_static", "libd"}
+	variant := "android_arm64_armv8-a_shared"
+	android.AssertDeepEquals(t, "default order", []string{"libc_static", "libb", "libd"},
+		linkedStaticLibs(ctx, "libdefault", variant, libs...))
+	android.AssertDeepEquals(t, "static_libs_first order", []string{"libd", "libb", "libc_static"},
+		linkedStaticLibs(ctx, "libfoo", variant, libs...))
+}
+
+func TestStaticLibsFirstNotInStaticLibs(t *testing.T) {
+	t.Parallel()
+	testCcError(t, `static_libs_first: "libd" is not in static_libs`, staticLibsFirstTestBp+`
+		cc_library_shared {
+			name: "libfoo",
+			static_libs: ["libb"],
+			static_libs_first: ["libd"],
+			stl: "none",
+		}
+	`)
+}
+
+func TestDiagnoseDuplicateSymbols(t *testing.T) {
+	t.Parallel()
+	ctx := testCc(t, staticLibsFirstTestBp+`
+		cc_binary {
+			name: "bin",
+			static_libs: ["libb", "libd"],
+			diagnose_duplicate_symbols: true,
+			stl: "none",
+		}
+
+		cc_library_shared {
+			name: "libfoo",
+			static_libs: ["libb"],
+			stl: "none",
+		}
+	`)
+
+	bin := ctx.ModuleForTests("bin", "android_arm64_armv8-a")
+	link := bin.Rule("ldWithLinkDiagnosis")
+	report := "out/soong/.intermediates/bin/android_arm64_armv8-a/bin.link_diagnosis.txt"
+	android.AssertStringEquals(t, "report", report, link.Args["linkDiagnosis"])
+	android.AssertStringListContains(t, "implicit outputs", link.ImplicitOutputs.Strings(), report)
+	android.AssertPathRelativeToTopEquals(t, "output",
+		"out/soong/.intermediates/bin/android_arm64_armv8-a/unstripped/bin", link.Output)
+	for _, lib := range []string{"libb", "libd"} {
+		android.AssertStringDoesContain(t, "static libs", link.Args["staticLibs"],
+			"out/soong/.intermediates/"+lib+"/android_arm64_armv8-a_static/"+lib+".a")
+	}
+	// The flag is only added when the link is rerun after a failure.
+	android.AssertStringDoesNotContain(t, "ldflags", link.Args["ldFlags"], "--warn-backrefs")
+	android.AssertStringDoesContain(t, "command", link.RuleParams.Command,
+		"-o /dev/null ${ldFlags} ${extraLibFlags} -Wl,--warn-backrefs > ${linkDiagnosis} 2>&1;")
+	android.AssertStringDoesContain(t, "command", link.RuleParams.Command,
+		"llvm-nm -A --defined-only $$lib; done >> ${linkDiagnosis} 2>&1;")
+	android.AssertStringDoesContain(t, "command", link.RuleParams.Command,
+		"see ${linkDiagnosis}")
+
+	libfoo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	android.AssertBoolEquals(t, "libfoo diagnosis", false,
+		libfoo.MaybeRule("ldWithLinkDiagnosis").Rule != nil)
+}
+
+func TestDiagnoseDuplicateSymbolsWithGcSectionsReport(t *testing.T) {
+	t.Parallel()
+	testCcError(t, `diagnose_duplicate_symbols: cannot be used with generate_gc_sections_report`, `
+		cc_binary {
+			name: "bin",
+			diagnose_duplicate_symbols: true,
+			generate_gc_sections_report: true,
+		}
+	`)
+}
@@ -14,6 +14,14 @@
 
 package cc
 
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"android/soong/android"
+)
+
 var (
 	llndkLibrarySuffix = ".llndk"
 	llndkHeadersSuffix = ".llndk"
@@ -54,4 +62,52 @@ type llndkLibraryProperties struct {
 	// if true, make this module available to provide headers to other modules that set
 	// llndk.symbol_file.
 	Llndk_headers *bool
+
+	// if true, check that the headers in override_export_include_dirs match the headers in
+	// export_include_dirs, so that the LLNDK headers don't silently diverge from the
+	// implementation headers.  Defaults to false.
+	Check_divergence *bool
+}
+
+// checkLlndkHeaderDivergence generates a rule that fails if the headers in the LLNDK include
+// directories of a library don't preprocess to the same declarations when they are looked up in
+// the implementation include directories, and returns the timestamp file of the check.  It returns
+// nil if there are no LLNDK headers to check.
+func checkLlndkHeaderDivergence(ctx ModuleContext, flags Flags, llndkDirs, implDirs []string) android.Path {
+	var includes []string
+	var headers android.Paths
+	for _, dir := range append(append([]string(nil), llndkDirs...), implDirs...) {
+		srcDir := android.PathForModuleSrc(ctx, dir)
+		dirHeaders := ctx.GlobFiles(filepath.Join(srcDir.String(), "**/*.h"), nil)
+		headers = append(headers, dirHeaders...)
+		if !android.InList(dir, llndkDirs) {
+			continue
+		}
+		for _, header := range dirHeaders {
+			rel, err := filepath.Rel(srcDir.String(), header.String())
+			if err != nil {
+				ctx.ModuleErrorf("filepath.Rel(%q, %q) failed: %s", srcDir.String(), header.String(), err)
+				continue
+			}
+			includes = append(includes, rel)
+		}
+	}
+
+	if len(includes) == 0 {
+		return nil
+	}
+	includes = android.SortedUniqueStrings(includes)
+
+	tu := android.PathForModuleOut(ctx, "llndk_divergence", "headers.cpp")
+	var content strings.Builder
+	for _, include := range includes {
+		fmt.Fprintf(&content, "#include \"%s\"\n", include)
+	}
+	android.WriteFileRule(ctx, tu, content.String())
+
+	timestamp := android.PathForModuleOut(ctx, "llndk_divergence", "check.timestamp")
+	transformLlndkHeadersToDivergenceCheck(ctx, tu, android.SortedUniquePaths(headers),
+		android.PathsForModuleSrc(ctx, llndkDirs), android.PathsForModuleSrc(ctx, implDirs),
+		flagsToBuilderFlags(flags), timestamp)
+	return timestamp
 }
@@ -37,6 +37,11 @@ import (
 //
 // This file adds support to soong to automatically propogate LTO options to a
 // new variant of all static dependencies for each module with LTO enabled.
+//
+// Whole program vtables require every static dependency to be built as bitcode,
+// so modules that set whole_program_vtables are built with ThinLTO unless LTO
+// is explicitly set, which propagates ThinLTO to their static dependencies, and
+// it is an error for them to depend on a prebuilt static library.
 
 type LTOProperties struct {
 	// Lto must violate capitialization style for acronyms so that it can be
@@ -56,7 +61,8 @@ type LTOProperties struct {
 	// Use clang lld instead of gnu ld.
 	Use_clang_lld *bool
 
-	// Use -fwhole-program-vtables cflag.
+	// Use -fwhole-program-vtables cflag.  Enables ThinLTO for the module and its
+	// static dependencies unless lto is explicitly set.
 	Whole_program_vtables *bool
 }
 
@@ -72,6 +78,11 @@ func (lto *lto) begin(ctx BaseModuleContext) {
 	if ctx.Config().IsEnvTrue("DISABLE_LTO") {
 		lto.Properties.Lto.Never = proptools.BoolPtr(true)
 	}
+
+	// -fwhole-program-vtables only has an effect with LTO, so default to ThinLTO.
+	if Bool(lto.Properties.Whole_program_vtables) && !lto.FullLTO() && !lto.Never() {
+		lto.Properties.Lto.Thin = proptools.BoolPtr(true)
+	}
 }
 
 func (lto *lto) useClangLld(ctx BaseModuleContext) bool {
@@ -168,6 +179,7 @@ func ltoDepsMutator(mctx android.TopDownMutatorContext) {
 		if full && thin {
 			mctx.PropertyErrorf("LTO", "FullLTO and ThinLTO are mutually exclusive")
 		}
+		wholeProgramVtables := Bool(m.lto.Properties.Whole_program_vtables) && (full || thin)
 
 		mctx.WalkDeps(func(dep android.Module, parent android.Module) bool {
 			tag := mctx.OtherModuleDependencyTag(dep)
@@ -185,6 +197,15 @@ func ltoDepsMutator(mctx android.TopDownMutatorContext) {
 			}
 
 			if dep, ok := dep.(*Module); ok {
+				// The compiler runtime libraries and the static unwinder are never built
+				// as bitcode, and do not need to be for whole program vtables.
+				runtimeLib := isLibTag && (libTag.Order == lateLibraryDependency || libTag.staticUnwinder)
+				if wholeProgramVtables && dep.IsPrebuilt() && !runtimeLib {
+					mctx.ModuleErrorf("whole_program_vtables requires static dependencies to be built with LTO, "+
+						"but %q is a prebuilt static library without bitcode",
+						android.RemoveOptionalPrebuiltPrefix(mctx.OtherModuleName(dep)))
+					return false
+				}
 				if full && !dep.lto.FullLTO() {
 					dep.lto.Properties.FullDep = true
 				}
@@ -0,0 +1,102 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+	"github.com/google/blueprint"
+)
+
+func TestWholeProgramVtablesDeps(t *testing.T) {
+	bp := `
+	cc_library {
+		name: "libTest",
+		srcs: ["foo.c"],
+		static_libs: ["libFoo"],
+		whole_program_vtables: true,
+	}
+
+	cc_library {
+		name: "libFoo",
+		srcs: ["foo.c"],
+		static_libs: ["libBar"],
+	}
+
+	cc_library {
+		name: "libBar",
+		srcs: ["foo.c"],
+	}
+	`
+
+	result := prepareForCcTest.RunTestWithBp(t, bp)
+
+	libTest := result.ModuleForTests("libTest", "android_arm64_armv8-a_shared")
+	libFoo := result.ModuleForTests("libFoo", "android_arm64_armv8-a_static_lto-thin")
+	libBar := result.ModuleForTests("libBar", "android_arm64_armv8-a_static_lto-thin")
+
+	hasDep := func(m android.Module, wantDep android.Module) bool {
+		var found bool
+		result.VisitDirectDeps(m, func(dep blueprint.Module) {
+			if dep == wantDep {
+				found = true
+			}
+		})
+		return found
+	}
+
+	if !hasDep(libTest.Module(), libFoo.Module()) {
+		t.Errorf("libTest missing dependency on lto-thin variant of libFoo")
+	}
+
+	if !hasDep(libFoo.Module(), libBar.Module()) {
+		t.Errorf("libFoo missing dependency on lto-thin variant of libBar")
+	}
+
+	cFlags := libTest.Rule("cc").Args["cFlags"]
+	android.AssertStringDoesContain(t, "libTest cflags", cFlags, "-flto=thin")
+	android.AssertStringDoesContain(t, "libTest cflags", cFlags, "-fwhole-program-vtables")
+	android.AssertStringDoesContain(t, "libBar cflags", libBar.Rule("cc").Args["cFlags"], "-flto=thin")
+}
+
+func TestWholeProgramVtablesPrebuiltDep(t *testing.T) {
+	bp := `
+	cc_library_shared {
+		name: "libTest",
+		srcs: ["foo.c"],
+		static_libs: ["libFoo"],
+		whole_program_vtables: true,
+	}
+
+	cc_library_static {
+		name: "libFoo",
+		srcs: ["foo.c"],
+		static_libs: ["libPrebuilt"],
+	}
+
+	cc_prebuilt_library_static {
+		name: "libPrebuilt",
+		srcs: ["libPrebuilt.a"],
+	}
+	`
+
+	android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddFile("libPrebuilt.a", nil),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`"libPrebuilt" is a prebuilt static library without bitcode`)).
+		RunTestWithBp(t, bp)
+}
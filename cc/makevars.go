@@ -25,9 +25,10 @@ import (
 )
 
 var (
-	modulesAddedWallKey          = android.NewOnceKey("ModulesAddedWall")
-	modulesUsingWnoErrorKey      = android.NewOnceKey("ModulesUsingWnoError")
-	modulesMissingProfileFileKey = android.NewOnceKey("ModulesMissingProfileFile")
+	modulesAddedWallKey                = android.NewOnceKey("ModulesAddedWall")
+	modulesUsingWnoErrorKey            = android.NewOnceKey("ModulesUsingWnoError")
+	modulesUsingLowerCppStdThanDepsKey = android.NewOnceKey("ModulesUsingLowerCppStdThanDeps")
+	modulesMissingProfileFileKey       = android.NewOnceKey("ModulesMissingProfileFile")
 )
 
 func init() {
@@ -121,6 +122,7 @@ func makeVarsProvider(ctx android.MakeVarsContext) {
 	ctx.Strict("ANDROID_WARNING_ALLOWED_PROJECTS", makeStringOfWarningAllowedProjects())
 	ctx.Strict("SOONG_MODULES_ADDED_WALL", makeStringOfKeys(ctx, modulesAddedWallKey))
 	ctx.Strict("SOONG_MODULES_USING_WNO_ERROR", makeStringOfKeys(ctx, modulesUsingWnoErrorKey))
+	ctx.Strict("SOONG_MODULES_USING_LOWER_CPP_STD_THAN_DEPS", makeStringOfKeys(ctx, modulesUsingLowerCppStdThanDepsKey))
 	ctx.Strict("SOONG_MODULES_MISSING_PGO_PROFILE_FILE", makeStringOfKeys(ctx, modulesMissingProfileFileKey))
 
 	ctx.Strict("ADDRESS_SANITIZER_CONFIG_EXTRA_CFLAGS", strings.Join(asanCflags, " "))
@@ -138,17 +138,23 @@ func (stub *stubDecorator) implementationModuleName(name string) string {
 	return strings.TrimSuffix(name, ndkLibrarySuffix)
 }
 
-func ndkLibraryVersions(ctx android.BaseMutatorContext, from android.ApiLevel) []string {
+// ndkLibraryApiLevels returns the API levels that an ndk_library whose first version is from must
+// provide stubs for, i.e. every supported API level from from to current.
+func ndkLibraryApiLevels(config android.Config, from android.ApiLevel) []android.ApiLevel {
 	var versions []android.ApiLevel
-	versionStrs := []string{}
-	for _, version := range ctx.Config().AllSupportedApiLevels() {
+	for _, version := range config.AllSupportedApiLevels() {
 		if version.GreaterThanOrEqualTo(from) {
 			versions = append(versions, version)
-			versionStrs = append(versionStrs, version.String())
 		}
 	}
-	versionStrs = append(versionStrs, android.FutureApiLevel.String())
+	return append(versions, android.FutureApiLevel)
+}
 
+func ndkLibraryVersions(ctx android.BaseMutatorContext, from android.ApiLevel) []string {
+	versionStrs := []string{}
+	for _, version := range ndkLibraryApiLevels(ctx.Config(), from) {
+		versionStrs = append(versionStrs, version.String())
+	}
 	return versionStrs
 }
 
@@ -524,8 +530,7 @@ func (stub *stubDecorator) install(ctx ModuleContext, path android.Path) {
 		libDir = "lib64"
 	}
 
-	installDir := getNdkInstallBase(ctx).Join(ctx, fmt.Sprintf(
-		"platforms/android-%s/arch-%s/usr/%s", stub.apiLevel, arch, libDir))
+	installDir := getNdkInstallBase(ctx).Join(ctx, ndkStubApiLevelDir(stub.apiLevel, arch), "usr", libDir)
 	stub.installPath = ctx.InstallFile(installDir, path.Base(), path)
 }
 
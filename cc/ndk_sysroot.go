@@ -53,6 +53,10 @@ package cc
 // TODO(danalbert): Write `ndk_static_library` rule.
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"android/soong/android"
 )
 
@@ -94,17 +98,89 @@ func getNdkFullTimestampFile(ctx android.PathContext) android.WritablePath {
 	return android.PathForOutput(ctx, "ndk.timestamp")
 }
 
+// The sysroot zip contains the NDK sysroot for the architectures in the current lunch target and
+// is dist'ed for the ndk goal.
+func getNdkSysrootZipFile(ctx android.PathContext) android.WritablePath {
+	return android.PathForOutput(ctx, "ndk_sysroot.zip")
+}
+
 func NdkSingleton() android.Singleton {
 	return &ndkSingleton{}
 }
 
-type ndkSingleton struct{}
+type ndkSingleton struct {
+	sysrootZip android.Path
+}
+
+// ndkStubsKey identifies the stub libraries of an ndk_library for a single architecture.
+type ndkStubsKey struct {
+	name string
+	arch string
+}
+
+type ndkStubs struct {
+	firstVersion android.ApiLevel
+	// The stub libraries installed into the sysroot, relative to getNdkInstallBase.
+	installed []string
+}
+
+// ndkStubApiLevelDir returns the directory of the sysroot that the stubs for arch are installed
+// under for an API level, relative to getNdkInstallBase.
+func ndkStubApiLevelDir(level android.ApiLevel, arch string) string {
+	return fmt.Sprintf("platforms/android-%s/arch-%s", level, arch)
+}
+
+// missingNdkApiLevels returns the API levels in expected for which none of the installed stub
+// libraries is in the sysroot directory for the level and arch.
+func missingNdkApiLevels(expected []android.ApiLevel, arch string, installed []string) []string {
+	var missing []string
+	for _, level := range expected {
+		dir := ndkStubApiLevelDir(level, arch) + "/"
+		ok := false
+		for _, path := range installed {
+			if strings.HasPrefix(path, dir) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			missing = append(missing, level.String())
+		}
+	}
+	return missing
+}
+
+// checkNdkStubs reports an error for every ndk_library that does not install stubs into the sysroot
+// for every API level from its first version to current, as each of those levels is expected to be
+// in the sysroot.
+func checkNdkStubs(ctx android.SingletonContext, stubs map[ndkStubsKey]*ndkStubs) {
+	keys := make([]ndkStubsKey, 0, len(stubs))
+	for key := range stubs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].arch < keys[j].arch
+	})
+
+	for _, key := range keys {
+		s := stubs[key]
+		expected := ndkLibraryApiLevels(ctx.Config(), s.firstVersion)
+		if missing := missingNdkApiLevels(expected, key.arch, s.installed); len(missing) > 0 {
+			ctx.Errorf("ndk_library %q has no stubs for %s at API levels %s",
+				key.name, key.arch, strings.Join(missing, ", "))
+		}
+	}
+}
 
 func (n *ndkSingleton) GenerateBuildActions(ctx android.SingletonContext) {
 	var staticLibInstallPaths android.Paths
 	var headerPaths android.Paths
 	var installPaths android.Paths
 	var licensePaths android.Paths
+	stubs := make(map[ndkStubsKey]*ndkStubs)
 	ctx.VisitAllModules(func(module android.Module) {
 		if m, ok := module.(android.Module); ok && !m.Enabled() {
 			return
@@ -131,6 +207,13 @@ func (n *ndkSingleton) GenerateBuildActions(ctx android.SingletonContext) {
 		if m, ok := module.(*Module); ok {
 			if installer, ok := m.installer.(*stubDecorator); ok && m.library.buildStubs() {
 				installPaths = append(installPaths, installer.installPath)
+
+				key := ndkStubsKey{name: ctx.ModuleName(module), arch: m.Arch().ArchType.String()}
+				if stubs[key] == nil {
+					stubs[key] = &ndkStubs{firstVersion: installer.firstVersion}
+				}
+				stubs[key].installed = append(stubs[key].installed,
+					strings.TrimPrefix(installer.installPath.String(), getNdkInstallBase(ctx).String()+"/"))
 			}
 
 			if library, ok := m.linker.(*libraryDecorator); ok {
@@ -181,4 +264,23 @@ func (n *ndkSingleton) GenerateBuildActions(ctx android.SingletonContext) {
 		Output:    getNdkFullTimestampFile(ctx),
 		Implicits: fullDepPaths,
 	})
+
+	checkNdkStubs(ctx, stubs)
+
+	sysrootZip := getNdkSysrootZipFile(ctx)
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("soong_zip").
+		FlagWithOutput("-o ", sysrootZip).
+		FlagWithArg("-C ", getNdkInstallBase(ctx).String()).
+		FlagWithArg("-D ", getNdkSysrootBase(ctx).String()).
+		Implicit(getNdkFullTimestampFile(ctx))
+	rule.Build("ndk_sysroot_zip", "zip NDK sysroot")
+	n.sysrootZip = sysrootZip
+}
+
+func (n *ndkSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if n.sysrootZip != nil {
+		ctx.DistForGoal("ndk", n.sysrootZip)
+	}
 }
@@ -0,0 +1,108 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestNdkLibraryApiLevels(t *testing.T) {
+	t.Parallel()
+	config := android.TestConfig(t.TempDir(), nil, "", nil)
+
+	var levels []string
+	for _, level := range ndkLibraryApiLevels(config, android.ApiLevelForTest("28")) {
+		levels = append(levels, level.String())
+	}
+	android.AssertArrayString(t, "api levels", []string{"28", "29", "30", "S", "Tiramisu", "current"}, levels)
+}
+
+func TestMissingNdkApiLevels(t *testing.T) {
+	t.Parallel()
+	installed := func(levels ...string) []string {
+		var ret []string
+		for _, level := range levels {
+			ret = append(ret, "platforms/android-"+level+"/arch-arm64/usr/lib/libfoo.so")
+		}
+		return ret
+	}
+
+	testCases := []struct {
+		name      string
+		installed []string
+		expected  []string
+	}{
+		{
+			name:      "no gaps",
+			installed: installed("29", "30", "31", "current"),
+			expected:  nil,
+		},
+		{
+			name:      "no gaps unordered",
+			installed: installed("current", "31", "29", "30"),
+			expected:  nil,
+		},
+		{
+			name:      "gap in the middle",
+			installed: installed("29", "31", "current"),
+			expected:  []string{"30"},
+		},
+		{
+			name:      "missing first version and current",
+			installed: installed("30", "31"),
+			expected:  []string{"29", "current"},
+		},
+		{
+			name:      "no stubs",
+			installed: nil,
+			expected:  []string{"29", "30", "31", "current"},
+		},
+		{
+			name: "installed for another arch",
+			installed: []string{
+				"platforms/android-29/arch-arm/usr/lib/libfoo.so",
+				"platforms/android-30/arch-arm64/usr/lib/libfoo.so",
+				"platforms/android-31/arch-arm64/usr/lib/libfoo.so",
+				"platforms/android-current/arch-arm64/usr/lib/libfoo.so",
+			},
+			expected: []string{"29"},
+		},
+		{
+			name: "installed for a level with the same prefix",
+			installed: []string{
+				"platforms/android-290/arch-arm64/usr/lib/libfoo.so",
+				"platforms/android-30/arch-arm64/usr/lib/libfoo.so",
+				"platforms/android-31/arch-arm64/usr/lib/libfoo.so",
+				"platforms/android-current/arch-arm64/usr/lib/libfoo.so",
+			},
+			expected: []string{"29"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expected := []android.ApiLevel{
+				android.ApiLevelForTest("29"),
+				android.ApiLevelForTest("30"),
+				android.ApiLevelForTest("31"),
+				android.ApiLevelForTest("current"),
+			}
+			missing := missingNdkApiLevels(expected, "arm64", tc.installed)
+			android.AssertDeepEquals(t, "missing api levels", tc.expected, missing)
+		})
+	}
+}
@@ -20,6 +20,8 @@ import (
 
 	"android/soong/android"
 	"android/soong/bazel"
+
+	"github.com/google/blueprint"
 )
 
 func init() {
@@ -70,6 +72,29 @@ func (p *prebuiltLinker) PrebuiltSrcs() []string {
 	return p.properties.Srcs
 }
 
+type prebuiltLibraryProperties struct {
+	// Name of the source shared library that this prebuilt shared library replaces, usually the
+	// module of the same name.  When set, the build fails if the prebuilt does not export all the
+	// dynamic symbols exported by the library built from source.  The check can be skipped by
+	// setting SOONG_SKIP_PREBUILT_ABI_CHECK=true in the environment.
+	Abi_check_against *string
+}
+
+// prebuiltAbiCheckDependencyTag is the dependency tag from a prebuilt shared library to the source
+// library named in abi_check_against.
+type prebuiltAbiCheckDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+// The dependency must stay on the source library when the prebuilt is preferred over it.
+func (prebuiltAbiCheckDependencyTag) ReplaceSourceWithPrebuilt() bool {
+	return false
+}
+
+var _ android.ReplaceSourceWithPrebuilt = prebuiltAbiCheckDependencyTag{}
+
+var prebuiltAbiCheckDepTag = prebuiltAbiCheckDependencyTag{}
+
 type prebuiltLibraryInterface interface {
 	libraryInterface
 	prebuiltLinkerInterface
@@ -79,6 +104,8 @@ type prebuiltLibraryInterface interface {
 type prebuiltLibraryLinker struct {
 	*libraryDecorator
 	prebuiltLinker
+
+	libraryProperties prebuiltLibraryProperties
 }
 
 var _ prebuiltLinkerInterface = (*prebuiltLibraryLinker)(nil)
@@ -87,7 +114,51 @@ var _ prebuiltLibraryInterface = (*prebuiltLibraryLinker)(nil)
 func (p *prebuiltLibraryLinker) linkerInit(ctx BaseModuleContext) {}
 
 func (p *prebuiltLibraryLinker) linkerDeps(ctx DepsContext, deps Deps) Deps {
-	return p.libraryDecorator.linkerDeps(ctx, deps)
+	if p.libraryProperties.Abi_check_against != nil && !p.libraryDecorator.buildShared() {
+		ctx.PropertyErrorf("abi_check_against", "only supported for shared libraries")
+	} else if p.abiCheckEnabled(ctx) && p.shared() {
+		ctx.AddVariationDependencies([]blueprint.Variation{
+			{Mutator: "link", Variation: "shared"},
+		}, prebuiltAbiCheckDepTag, String(p.libraryProperties.Abi_check_against))
+	}
+	deps = p.libraryDecorator.linkerDeps(ctx, deps)
+	// A prebuilt library has no compiler to list generated_headers in, so the generated headers
+	// it exports are also the ones it depends on.
+	deps.GeneratedHeaders = append(deps.GeneratedHeaders, p.libraryDecorator.baseLinker.Properties.Export_generated_headers...)
+	return deps
+}
+
+// abiCheckEnabled returns true if the prebuilt shared library is checked against the source
+// library named in abi_check_against.
+func (p *prebuiltLibraryLinker) abiCheckEnabled(ctx android.BaseModuleContext) bool {
+	return String(p.libraryProperties.Abi_check_against) != "" &&
+		!ctx.Config().IsEnvTrue("SOONG_SKIP_PREBUILT_ABI_CHECK")
+}
+
+// abiCheck returns a timestamp file created by a rule that verifies that the prebuilt shared
+// library exports all the symbols of the source library named in abi_check_against, or nil if the
+// check is disabled.  It is meant to be used as a validation of the rule that copies the prebuilt.
+func (p *prebuiltLibraryLinker) abiCheck(ctx ModuleContext, in android.Path) android.Path {
+	if !p.abiCheckEnabled(ctx) {
+		return nil
+	}
+
+	var sourceLib android.Path
+	ctx.VisitDirectDepsWithTag(prebuiltAbiCheckDepTag, func(dep android.Module) {
+		if !ctx.OtherModuleHasProvider(dep, SharedLibraryInfoProvider) {
+			ctx.PropertyErrorf("abi_check_against", "module %q is not a shared library",
+				ctx.OtherModuleName(dep))
+			return
+		}
+		sourceLib = ctx.OtherModuleProvider(dep, SharedLibraryInfoProvider).(SharedLibraryInfo).SharedLibrary
+	})
+	if sourceLib == nil {
+		return nil
+	}
+
+	checkFile := android.PathForModuleOut(ctx, "prebuilt_abi_check", in.Base()+".timestamp")
+	transformPrebuiltSharedObjectToAbiCheck(ctx, in, sourceLib, checkFile)
+	return checkFile
 }
 
 func (p *prebuiltLibraryLinker) linkerFlags(ctx ModuleContext, flags Flags) Flags {
@@ -137,6 +208,7 @@ func (p *prebuiltLibraryLinker) link(ctx ModuleContext,
 			libName := p.libraryDecorator.getLibName(ctx) + flags.Toolchain.ShlibSuffix()
 			outputFile := android.PathForModuleOut(ctx, libName)
 			var implicits android.Paths
+			abiCheck := p.abiCheck(ctx, in)
 
 			if p.stripper.NeedsStrip(ctx) {
 				stripFlags := flagsToStripFlags(flags)
@@ -175,6 +247,7 @@ func (p *prebuiltLibraryLinker) link(ctx ModuleContext,
 				Rule:        android.Cp,
 				Description: "prebuilt shared library",
 				Implicits:   implicits,
+				Validation:  abiCheck,
 				Input:       in,
 				Output:      outputFile,
 				Args: map[string]string{
@@ -261,7 +334,7 @@ func NewPrebuiltLibrary(hod android.HostOrDeviceSupported, srcsProperty string)
 	module.linker = prebuilt
 	module.library = prebuilt
 
-	module.AddProperties(&prebuilt.properties)
+	module.AddProperties(&prebuilt.properties, &prebuilt.libraryProperties)
 
 	if srcsProperty == "" {
 		android.InitPrebuiltModuleWithoutSrcs(module)
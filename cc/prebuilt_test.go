@@ -188,6 +188,67 @@ func TestPrebuiltLibraryShared(t *testing.T) {
 	assertString(t, shared.OutputFile().Path().Base(), "libtest.so")
 }
 
+func TestPrebuiltLibrarySharedAbiCheck(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+		}
+
+		cc_prebuilt_library_shared {
+			name: "libfoo",
+			prefer: true,
+			srcs: ["libfoo.so"],
+			abi_check_against: "libfoo",
+		}
+	`
+	fs := android.MockFS{
+		"libfoo.so": nil,
+	}
+
+	t.Run("prefer", func(t *testing.T) {
+		ctx := testPrebuilt(t, bp, fs)
+
+		source := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared").Module()
+		sourceLib := ctx.ModuleProvider(source, SharedLibraryInfoProvider).(SharedLibraryInfo).SharedLibrary
+		prebuilt := ctx.ModuleForTests("prebuilt_libfoo", "android_arm64_armv8-a_shared")
+
+		// The prebuilt is compared against the library built from source even though the
+		// prebuilt replaces it.
+		check := prebuilt.Rule("checkPrebuiltAbi")
+		android.AssertPathRelativeToTopEquals(t, "prebuilt abi check input", "libfoo.so", check.Input)
+		android.AssertStringEquals(t, "prebuilt abi check source", sourceLib.String(), check.Args["source"])
+
+		// The check is a validation of the rule that copies the prebuilt.
+		cp := prebuilt.Output("libfoo.so")
+		android.AssertStringEquals(t, "prebuilt copy validation",
+			check.Output.String(), cp.Validation.String())
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		ctx := testPrebuilt(t, bp, fs, android.FixtureMergeEnv(map[string]string{
+			"SOONG_SKIP_PREBUILT_ABI_CHECK": "true",
+		}))
+
+		prebuilt := ctx.ModuleForTests("prebuilt_libfoo", "android_arm64_armv8-a_shared")
+		if prebuilt.MaybeRule("checkPrebuiltAbi").Rule != nil {
+			t.Errorf("expected no prebuilt abi check when SOONG_SKIP_PREBUILT_ABI_CHECK is set")
+		}
+	})
+}
+
+func TestPrebuiltLibraryStaticAbiCheck(t *testing.T) {
+	prepareForPrebuiltTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`abi_check_against: only supported for shared libraries`)).
+		RunTestWithBp(t, `
+		cc_prebuilt_library_static {
+			name: "libfoo",
+			srcs: ["libfoo.a"],
+			abi_check_against: "libfoo",
+		}
+	`)
+}
+
 func TestPrebuiltLibraryStatic(t *testing.T) {
 	ctx := testPrebuilt(t, `
 	cc_prebuilt_library_static {
@@ -68,4 +68,49 @@ func TestProto(t *testing.T) {
 		}
 	})
 
+	t.Run("deps", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			prepareForCcTest,
+			android.FixtureAddTextFile("a/Android.bp", `
+				cc_library_static {
+					name: "liba",
+					srcs: ["a.proto"],
+					proto: {
+						canonical_path_from_root: false,
+					},
+				}`),
+			android.FixtureAddTextFile("b/Android.bp", `
+				cc_library_static {
+					name: "libb",
+					srcs: ["b.proto"],
+					proto: {
+						canonical_path_from_root: false,
+						deps: ["liba"],
+					},
+				}`),
+			android.FixtureMergeMockFs(android.MockFS{
+				"a/a.proto": nil,
+				"b/b.proto": nil,
+				"c.proto":   nil,
+			}),
+		).RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["c.proto"],
+			proto: {
+				deps: ["libb"],
+			},
+		}`)
+
+		libb := result.ModuleForTests("libb", "android_arm_armv7-a-neon_static").Output("proto/b.pb.cc")
+		android.AssertStringDoesContain(t, "libb protoc command", libb.RuleParams.Command, " -Ia ")
+		android.AssertStringListContains(t, "libb protoc implicits", libb.Implicits.Strings(), "a/a.proto")
+
+		// The proto files and include dirs of liba are propagated through libb.
+		libfoo := result.ModuleForTests("libfoo", "android_arm_armv7-a-neon_shared").Output("proto/c.pb.cc")
+		android.AssertStringDoesContain(t, "libfoo protoc command", libfoo.RuleParams.Command, " -Ib -Ia ")
+		android.AssertStringListContains(t, "libfoo protoc implicits", libfoo.Implicits.Strings(), "a/a.proto")
+		android.AssertStringListContains(t, "libfoo protoc implicits", libfoo.Implicits.Strings(), "b/b.proto")
+	})
+
 }
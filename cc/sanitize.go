@@ -15,6 +15,7 @@
 package cc
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -283,6 +284,10 @@ type SanitizeProperties struct {
 	InSanitizerDir    bool              `blueprint:"mutated"`
 	Sanitizers        []string          `blueprint:"mutated"`
 	DiagSanitizers    []string          `blueprint:"mutated"`
+
+	// True if the module is in a path where integer_overflow is enabled by default, but sets
+	// sanitize: { integer_overflow: false }.
+	IntegerOverflowOptOut bool `blueprint:"mutated"`
 }
 
 type sanitize struct {
@@ -299,6 +304,7 @@ var _ android.SkipApexAllowedDependenciesCheck = (*libraryDependencyTag)(nil)
 func init() {
 	android.RegisterMakeVarsProvider(pctx, cfiMakeVarsProvider)
 	android.RegisterMakeVarsProvider(pctx, hwasanMakeVarsProvider)
+	android.RegisterSingletonType("sanitizer_opt_outs", sanitizerOptOutsSingletonFactory)
 }
 
 func (sanitize *sanitize) props() []interface{} {
@@ -318,6 +324,13 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		return
 	}
 
+	// Modules that never use sanitizers are not opt-outs, as integer_overflow would not have been
+	// enabled for them anyway.
+	integerOverflowPath := !ctx.Host() && ctx.Config().IntegerOverflowEnabledForPath(ctx.ModuleDir())
+	if integerOverflowPath && s.Integer_overflow != nil && !*s.Integer_overflow {
+		sanitize.Properties.IntegerOverflowOptOut = true
+	}
+
 	// cc_test targets default to SYNC MemTag unless explicitly set to ASYNC (via diag: {memtag_heap}).
 	if ctx.testBinary() {
 		if s.Memtag_heap == nil {
@@ -453,6 +466,15 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		}
 	}
 
+	// Enable integer_overflow for non-host components in the include paths.  Static libraries
+	// are not supported, as with global integer_overflow builds.
+	if s.Integer_overflow == nil && integerOverflowPath && !ctx.static() {
+		s.Integer_overflow = proptools.BoolPtr(true)
+		if inList("integer_overflow", ctx.Config().SanitizeDeviceDiag()) {
+			s.Diag.Integer_overflow = proptools.BoolPtr(true)
+		}
+	}
+
 	// Is CFI actually enabled?
 	if !ctx.Config().EnableCFI() {
 		s.Cfi = nil
@@ -1545,3 +1567,43 @@ func cfiMakeVarsProvider(ctx android.MakeVarsContext) {
 func hwasanMakeVarsProvider(ctx android.MakeVarsContext) {
 	hwasanStaticLibs(ctx.Config()).exportToMake(ctx)
 }
+
+// sanitizerOptOut is an entry in sanitizer_opt_outs.json.
+type sanitizerOptOut struct {
+	Name string `json:"name"`
+	Dir  string `json:"dir"`
+}
+
+func sanitizerOptOutsSingletonFactory() android.Singleton {
+	return &sanitizerOptOutsSingleton{}
+}
+
+type sanitizerOptOutsSingleton struct{}
+
+// GenerateBuildActions writes out/soong/sanitizer_opt_outs.json, which lists the modules in the
+// paths where integer_overflow is enabled by default that disable it, to track the progress of
+// rolling it out.
+func (sanitizerOptOutsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	optOuts := make(map[string]sanitizerOptOut)
+	ctx.VisitAllModules(func(module android.Module) {
+		if c, ok := module.(*Module); ok && c.sanitize != nil && c.sanitize.Properties.IntegerOverflowOptOut {
+			name := ctx.ModuleName(module)
+			optOuts[name] = sanitizerOptOut{Name: name, Dir: ctx.ModuleDir(module)}
+		}
+	})
+
+	integerOverflow := []sanitizerOptOut{}
+	for _, name := range android.SortedStringKeys(optOuts) {
+		integerOverflow = append(integerOverflow, optOuts[name])
+	}
+
+	jsonStr, err := json.MarshalIndent(map[string][]sanitizerOptOut{
+		"integer_overflow": integerOverflow,
+	}, "", "  ")
+	if err != nil {
+		ctx.Errorf(err.Error())
+		return
+	}
+
+	android.WriteFileRule(ctx, android.PathForOutput(ctx, "sanitizer_opt_outs.json"), string(jsonStr))
+}
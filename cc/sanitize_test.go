@@ -551,3 +551,81 @@ func TestSanitizeMemtagHeapWithSanitizeDeviceDiag(t *testing.T) {
 	checkHasMemtagNote(t, ctx.ModuleForTests("unset_test_override_default_disable", variant), Sync)
 	checkHasMemtagNote(t, ctx.ModuleForTests("unset_test_override_default_sync", variant), Sync)
 }
+
+func TestIntegerOverflowIncludePaths(t *testing.T) {
+	bp := `
+		cc_binary {
+			name: "bin_in_path",
+			srcs: ["foo.c"],
+		}
+
+		cc_binary {
+			name: "bin_opt_out",
+			srcs: ["foo.c"],
+			sanitize: {
+				integer_overflow: false,
+			},
+		}
+
+		cc_binary {
+			name: "bin_never",
+			srcs: ["foo.c"],
+			sanitize: {
+				never: true,
+				integer_overflow: false,
+			},
+		}
+
+		cc_library_static {
+			name: "libstatic_in_path",
+			srcs: ["foo.c"],
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("vendor/hal/Android.bp", bp),
+		android.FixtureAddTextFile("vendor/hal/excluded/Android.bp", `
+			cc_binary {
+				name: "bin_excluded",
+				srcs: ["foo.c"],
+			}
+		`),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.IntegerOverflowIncludePaths = []string{"vendor/hal"}
+			variables.IntegerOverflowExcludePaths = []string{"vendor/hal/excluded"}
+		}),
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterSingletonType("sanitizer_opt_outs", sanitizerOptOutsSingletonFactory)
+		}),
+	).RunTestWithBp(t, `
+		cc_binary {
+			name: "bin_not_in_path",
+			srcs: ["foo.c"],
+		}
+	`)
+
+	intOverflowEnabled := func(name, variant string) bool {
+		module := result.ModuleForTests(name, variant).Module().(*Module)
+		return module.sanitize.isSanitizerEnabled(intOverflow)
+	}
+
+	android.AssertBoolEquals(t, "bin_in_path", true, intOverflowEnabled("bin_in_path", "android_arm64_armv8-a"))
+	android.AssertBoolEquals(t, "bin_opt_out", false, intOverflowEnabled("bin_opt_out", "android_arm64_armv8-a"))
+	android.AssertBoolEquals(t, "bin_excluded", false, intOverflowEnabled("bin_excluded", "android_arm64_armv8-a"))
+	android.AssertBoolEquals(t, "bin_not_in_path", false, intOverflowEnabled("bin_not_in_path", "android_arm64_armv8-a"))
+	// Static libraries are not supported, as with global integer_overflow builds.
+	android.AssertBoolEquals(t, "libstatic_in_path", false,
+		intOverflowEnabled("libstatic_in_path", "android_arm64_armv8-a_static"))
+
+	content := android.ContentFromFileRuleForTests(t,
+		result.SingletonForTests("sanitizer_opt_outs").Output("sanitizer_opt_outs.json"))
+	android.AssertStringEquals(t, "sanitizer_opt_outs.json", `{
+  "integer_overflow": [
+    {
+      "name": "bin_opt_out",
+      "dir": "vendor/hal"
+    }
+  ]
+}`, content)
+}
@@ -47,6 +47,11 @@ type StlProperties struct {
 	// default.
 	Stl *string `android:"arch_variant"`
 
+	// if set to true with stl: "none", statically link the unwinder on Android so that the
+	// module can unwind frames, e.g. in a crash handler, without depending on an STL.  The STLs
+	// already provide the unwinder, so this can't be set with any other stl.
+	Unwind *bool `android:"arch_variant"`
+
 	SelectedStl string `blueprint:"mutated"`
 }
 
@@ -115,6 +120,15 @@ func (stl *stl) begin(ctx BaseModuleContext) {
 			}
 		}
 	}()
+
+	// The shared libc++ is not installed for the libc of these variants.
+	if stl.Properties.SelectedStl == "libc++" && linksSharedLibsStatically(ctx) {
+		stl.Properties.SelectedStl = "libc++_static"
+	}
+
+	if Bool(stl.Properties.Unwind) && stl.Properties.SelectedStl != "" {
+		ctx.PropertyErrorf("unwind", "can only be set with stl: \"none\"")
+	}
 }
 
 func needsLibAndroidSupport(ctx BaseModuleContext) bool {
@@ -168,6 +182,16 @@ func (stl *stl) deps(ctx BaseModuleContext, deps Deps) Deps {
 		if ctx.toolchain().Bionic() && ctx.Module().Name() == "libc++" {
 			deps.StaticUnwinderIfLegacy = true
 		}
+		// Without an STL there is no shared library that provides the unwinder, so it is linked
+		// statically, just like the NDK STLs do.  On the host the unwinder is provided by the
+		// runtime of the toolchain.
+		if Bool(stl.Properties.Unwind) && ctx.toolchain().Bionic() {
+			if ctx.useSdk() {
+				deps.StaticLibs = append(deps.StaticLibs, "ndk_libunwind")
+			} else {
+				deps.StaticLibs = append(deps.StaticLibs, staticUnwinder(ctx))
+			}
+		}
 	case "ndk_system":
 		// TODO: Make a system STL prebuilt for the NDK.
 		// The system STL doesn't have a prebuilt (it uses the system's libstdc++), but it does have
@@ -235,6 +259,10 @@ func (stl *stl) flags(ctx ModuleContext, flags Flags) Flags {
 		if !ctx.toolchain().Bionic() {
 			flags.Local.CppFlags = append(flags.Local.CppFlags, "-nostdinc++")
 			flags.extraLibFlags = append(flags.extraLibFlags, "-nostdlib++")
+		} else if Bool(stl.Properties.Unwind) {
+			// Make sure the _Unwind_XXX symbols of the statically linked unwinder are not
+			// re-exported.
+			flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--exclude-libs,libunwind.a")
 		}
 	default:
 		panic(fmt.Errorf("Unknown stl: %q", stl.Properties.SelectedStl))
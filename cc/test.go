@@ -22,6 +22,7 @@ import (
 	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
+	"android/soong/etc"
 	"android/soong/tradefed"
 )
 
@@ -68,6 +69,14 @@ type TestOptions struct {
 	// Add MinApiLevelModuleController with ro.vndk.version property. If ro.vndk.version has an
 	// integer value and the value is less than the min_vndk_version, skip this module.
 	Min_vndk_version *int64
+
+	// A list of free-formed strings that categorize the test, exported to test_metadata.json for
+	// the test mapping tooling.
+	Tags []string
+
+	// A list of options for the test runner, exported to test_metadata.json for the test mapping
+	// tooling.
+	Test_runner_options []string
 }
 
 type TestBinaryProperties struct {
@@ -81,7 +90,9 @@ type TestBinaryProperties struct {
 	No_named_install_directory *bool
 
 	// list of files or filegroup modules that provide data that should be installed alongside
-	// the test
+	// the test. For host tests, the files of prebuilt_etc_host, prebuilt_usr_share_host and
+	// prebuilt_root_host modules are installed in the same directory relative to the test as
+	// they are relative to the host out directory, e.g. etc/<relative_install_path>.
 	Data []string `android:"path,arch_variant"`
 
 	// list of shared library modules that should be installed alongside the test
@@ -342,6 +353,15 @@ type testBinary struct {
 	extraTestConfigs android.Paths
 }
 
+func (test *testBinary) testMetadata() *android.TestMetadata {
+	return &android.TestMetadata{
+		Suites:            test.testDecorator.InstallerProperties.Test_suites,
+		Config:            test.testConfig,
+		Tags:              test.Properties.Test_options.Tags,
+		TestRunnerOptions: test.Properties.Test_options.Test_runner_options,
+	}
+}
+
 func (test *testBinary) linkerProps() []interface{} {
 	props := append(test.testDecorator.linkerProps(), test.binaryDecorator.linkerProps()...)
 	props = append(props, &test.Properties)
@@ -371,6 +391,29 @@ func (test *testBinary) installerProps() []interface{} {
 	return append(test.baseInstaller.installerProps(), test.testDecorator.installerProps()...)
 }
 
+// hostPrebuiltDataInstallDir returns the directory that the data file referenced by data is
+// installed to relative to the data directory of a host test. The files of prebuilt_etc_host,
+// prebuilt_usr_share_host and prebuilt_root_host modules keep the directory they are installed to
+// relative to the host out directory, e.g. etc/<relative_install_path>, so that host tools that
+// look for them relative to their binary also find them when the test is packaged into a suite.
+func hostPrebuiltDataInstallDir(ctx ModuleContext, data string) string {
+	if !ctx.Host() {
+		return ""
+	}
+	moduleName, tag := android.SrcIsModuleWithTag(data)
+	if moduleName == "" || tag != "" {
+		return ""
+	}
+	prebuilt, ok := android.GetModuleFromPathDep(ctx, moduleName, tag).(etc.PrebuiltEtcModule)
+	if !ok {
+		return ""
+	}
+	if dir := filepath.Join(prebuilt.BaseDir(), prebuilt.SubDir()); dir != "." {
+		return dir
+	}
+	return ""
+}
+
 func (test *testBinary) install(ctx ModuleContext, file android.Path) {
 	// TODO: (b/167308193) Switch to /data/local/tests/unrestricted as the default install base.
 	testInstallBase := "/data/local/tmp"
@@ -378,10 +421,12 @@ func (test *testBinary) install(ctx ModuleContext, file android.Path) {
 		testInstallBase = "/data/local/tests/vendor"
 	}
 
-	dataSrcPaths := android.PathsForModuleSrc(ctx, test.Properties.Data)
-
-	for _, dataSrcPath := range dataSrcPaths {
-		test.data = append(test.data, android.DataPath{SrcPath: dataSrcPath})
+	for _, data := range test.Properties.Data {
+		relativeInstallPath := hostPrebuiltDataInstallDir(ctx, data)
+		for _, dataSrcPath := range android.PathsForModuleSrc(ctx, []string{data}) {
+			test.data = append(test.data,
+				android.DataPath{SrcPath: dataSrcPath, RelativeInstallPath: relativeInstallPath})
+		}
 	}
 
 	ctx.VisitDirectDepsWithTag(dataLibDepTag, func(dep android.Module) {
@@ -17,8 +17,10 @@ package cc
 import (
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
@@ -37,6 +39,11 @@ type TidyProperties struct {
 
 	// Checks that should be treated as errors.
 	Tidy_checks_as_errors []string
+
+	// timeout in seconds for running clang-tidy over each of the source files listed in
+	// tidy_timeout_srcs. Other source files are run without a timeout. It has no effect
+	// when TIDY_TIMEOUT is set, as the listed source files are then skipped.
+	Tidy_timeout *int `android:"arch_variant"`
 }
 
 type tidyFeature struct {
@@ -82,6 +89,13 @@ func (tidy *tidyFeature) flags(ctx ModuleContext, flags Flags) Flags {
 		flags.NeedTidyFiles = true
 	}
 
+	if timeout := tidy.Properties.Tidy_timeout; timeout != nil {
+		if *timeout <= 0 {
+			ctx.PropertyErrorf("tidy_timeout", "must be a positive number of seconds, got %d", *timeout)
+		}
+		flags.TidyTimeout = strconv.Itoa(*timeout)
+	}
+
 	// Add global WITH_TIDY_FLAGS and local tidy_flags.
 	withTidyFlags := ctx.Config().Getenv("WITH_TIDY_FLAGS")
 	if len(withTidyFlags) > 0 {
@@ -268,6 +282,43 @@ func (m *tidyPhonySingleton) GenerateBuildActions(ctx android.SingletonContext)
 	}
 	generateObjTidyPhonyTargets(ctx, suffix, "obj", objModulesInDirGroup)
 	generateObjTidyPhonyTargets(ctx, suffix, "tidy", tidyModulesInDirGroup)
+
+	if ctx.Config().IsEnvTrue("WITH_TIDY") {
+		generateTidyDurationsReport(ctx, suffix)
+	}
+}
+
+var (
+	// Rule for collecting the durations recorded in .tidy files into a report, slowest first.
+	tidyDurationsReport = pctx.AndroidStaticRule("tidyDurationsReport",
+		blueprint.RuleParams{
+			Command:        "xargs cat < ${out}.rsp | sort -k1,1nr -k2 > ${out}",
+			Rspfile:        "${out}.rsp",
+			RspfileContent: "${in}",
+		})
+)
+
+// Generate a report of how long clang-tidy took for each source file, so that the files
+// that need tidy_timeout_srcs or tidy_disabled_srcs can be found. It is built by the
+// tidy phony target.
+func generateTidyDurationsReport(ctx android.SingletonContext, suffix string) {
+	var tidyFiles android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if m, ok := module.(*Module); ok && m.Enabled() {
+			tidyFiles = append(tidyFiles, m.tidyFiles...)
+		}
+	})
+	if len(tidyFiles) == 0 {
+		return
+	}
+	report := android.PathForOutput(ctx, "tidy", "tidy_durations.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        tidyDurationsReport,
+		Description: "clang-tidy durations report",
+		Inputs:      android.SortedUniquePaths(tidyFiles),
+		Output:      report,
+	})
+	ctx.Phony("tidy"+suffix, report)
 }
 
 // The name for an obj/tidy module variant group phony target is Name_group-obj/tidy,
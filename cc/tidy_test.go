@@ -0,0 +1,119 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+const tidyTestObjDir = "out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/obj/"
+
+func TestTidyDisabledSrcs(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c", "big_generated.c", "arm64.c"],
+			tidy: true,
+			tidy_disabled_srcs: ["big_generated.c"],
+			arch: {
+				arm64: {
+					tidy_disabled_srcs: ["arm64.c"],
+				},
+			},
+		}
+	`
+	result := prepareForCcTest.RunTestWithBp(t, bp)
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+
+	libfoo.Output(tidyTestObjDir + "foo.tidy")
+	for _, src := range []string{"big_generated", "arm64"} {
+		android.AssertBoolEquals(t, src+".c is tidied", false,
+			libfoo.MaybeOutput(tidyTestObjDir+src+".tidy").Rule != nil)
+		// Source files without tidy are still compiled.
+		libfoo.Output(tidyTestObjDir + src + ".o")
+	}
+}
+
+func TestTidyTimeout(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c", "slow.c"],
+			tidy: true,
+			tidy_timeout: 60,
+			tidy_timeout_srcs: ["slow.c"],
+		}
+	`
+
+	t.Run("module timeout", func(t *testing.T) {
+		result := prepareForCcTest.RunTestWithBp(t, bp)
+		libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+
+		android.AssertStringEquals(t, "foo.c tidyVars", "",
+			libfoo.Output(tidyTestObjDir + "foo.tidy").Args["tidyVars"])
+		android.AssertStringEquals(t, "slow.c tidyVars", "TIDY_TIMEOUT=60 ",
+			libfoo.Output(tidyTestObjDir + "slow.tidy").Args["tidyVars"])
+	})
+
+	t.Run("global timeout", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			prepareForCcTest,
+			android.FixtureMergeEnv(map[string]string{
+				"TIDY_TIMEOUT": "30",
+			}),
+		).RunTestWithBp(t, bp)
+		libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+
+		android.AssertStringEquals(t, "foo.c tidyVars", "TIDY_TIMEOUT=30 ",
+			libfoo.Output(tidyTestObjDir + "foo.tidy").Args["tidyVars"])
+		android.AssertBoolEquals(t, "slow.c is tidied", false,
+			libfoo.MaybeOutput(tidyTestObjDir+"slow.tidy").Rule != nil)
+	})
+}
+
+func TestTidyDurationsReport(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			tidy: true,
+		}
+	`
+
+	prepareForTidyPhonyTest := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterSingletonType("tidy_phony_targets", TidyPhonySingleton)
+		}),
+	)
+
+	result := android.GroupFixturePreparers(
+		prepareForTidyPhonyTest,
+		android.FixtureMergeEnv(map[string]string{
+			"WITH_TIDY": "1",
+		}),
+	).RunTestWithBp(t, bp)
+
+	report := result.SingletonForTests("tidy_phony_targets").Rule("tidyDurationsReport")
+	android.AssertPathRelativeToTopEquals(t, "report", "out/soong/tidy/tidy_durations.txt", report.Output)
+	android.AssertStringListContains(t, "report inputs", android.PathsRelativeToTop(report.Inputs),
+		tidyTestObjDir+"foo.tidy")
+
+	result = prepareForTidyPhonyTest.RunTestWithBp(t, bp)
+	rule := result.SingletonForTests("tidy_phony_targets").MaybeRule("tidyDurationsReport")
+	android.AssertBoolEquals(t, "report without WITH_TIDY", false, rule.Rule != nil)
+}
@@ -89,10 +89,17 @@ func flagsToBuilderFlags(in Flags) builderFlags {
 		sAbiDump:      in.SAbiDump,
 		emitXrefs:     in.EmitXrefs,
 
+		staticAnalyzer: in.StaticAnalyzer,
+
 		systemIncludeFlags: strings.Join(in.SystemIncludeFlags, " "),
 
 		assemblerWithCpp: in.AssemblerWithCpp,
 
+		gcSectionsReport: in.GcSectionsReport,
+		linkDiagnosis:    in.LinkDiagnosis,
+
+		tidyTimeout: in.TidyTimeout,
+
 		proto:            in.proto,
 		protoC:           in.protoC,
 		protoOptionsFile: in.protoOptionsFile,
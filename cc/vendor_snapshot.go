@@ -90,6 +90,11 @@ func isSnapshotAware(cfg android.DeviceConfig, m LinkableInterface, inProprietar
 		return false
 	}
 
+	// vendor stubs are built from the symbol file of a platform library and are never installed
+	if m.IsVendorStubs() {
+		return false
+	}
+
 	// Libraries
 	if sanitizable, ok := m.(PlatformSanitizeable); ok && sanitizable.IsSnapshotLibrary() {
 		if sanitizable.SanitizePropDefined() {
@@ -38,7 +38,9 @@ type TargetConfig struct {
 	sdkVersion int32
 	screenDpi  map[android_bundle_proto.ScreenDensity_DensityAlias]bool
 	// Map holding <ABI alias>:<its sequence number in the flag> info.
-	abis             map[android_bundle_proto.Abi_AbiAlias]int
+	abis map[android_bundle_proto.Abi_AbiAlias]int
+	// Set of the locales to select, all locales are selected if it is empty.
+	locales          map[string]bool
 	allowPrereleased bool
 	stem             string
 }
@@ -153,11 +155,15 @@ type languageTargetingMatcher struct {
 	*android_bundle_proto.LanguageTargeting
 }
 
-func (m languageTargetingMatcher) matches(_ TargetConfig) bool {
-	if m.LanguageTargeting == nil {
+func (m languageTargetingMatcher) matches(config TargetConfig) bool {
+	if m.LanguageTargeting == nil || len(config.locales) == 0 {
 		return true
 	}
-	log.Fatal("language based entry selection is not implemented")
+	for _, v := range m.GetValue() {
+		if config.locales[v] {
+			return true
+		}
+	}
 	return false
 }
 
@@ -422,6 +428,35 @@ func selectApks(toc Toc, targetConfig TargetConfig) SelectionResult {
 	return result
 }
 
+// Returns an error if the selected module has ABI specific splits but none of them
+// has been selected.
+func checkAbiSplits(toc Toc, selected SelectionResult) error {
+	hasAbiSplits := false
+	for _, variant := range (*toc).GetVariant() {
+		for _, as := range variant.GetApkSet() {
+			if as.GetModuleMetadata().GetName() != selected.moduleName {
+				continue
+			}
+			for _, apkdesc := range as.GetApkDescription() {
+				if len(apkdesc.GetTargeting().GetAbiTargeting().GetValue()) == 0 {
+					continue
+				}
+				hasAbiSplits = true
+				for _, entry := range selected.entries {
+					if entry == apkdesc.GetPath() {
+						return nil
+					}
+				}
+			}
+		}
+	}
+	if hasAbiSplits {
+		return fmt.Errorf("module %q has no ABI split compatible with the target configuration",
+			selected.moduleName)
+	}
+	return nil
+}
+
 type Zip2ZipWriter interface {
 	CopyFrom(file *zip.File, name string) error
 }
@@ -519,7 +554,9 @@ var (
 		"extract a single target and output it uncompressed. only available for standalone apks and apexes.")
 	apkcertsOutput = flag.String("apkcerts", "",
 		"optional apkcerts.txt output file containing signing info of all outputted apks")
-	partition = flag.String("partition", "", "partition string. required when -apkcerts is used.")
+	partition       = flag.String("partition", "", "partition string. required when -apkcerts is used.")
+	requireAbiSplit = flag.Bool("require-abi-split", false,
+		"fail if the APK set has ABI splits but none of them matches the target ABIs")
 )
 
 // Parse abi values
@@ -569,11 +606,33 @@ func (s screenDensityFlagValue) Set(densityList string) error {
 	return nil
 }
 
+// Parse locale values
+type localeFlagValue struct {
+	targetConfig *TargetConfig
+}
+
+func (l localeFlagValue) String() string {
+	return "all"
+}
+
+func (l localeFlagValue) Set(localeList string) error {
+	if localeList == "all" {
+		return nil
+	}
+	if targetConfig.locales == nil {
+		targetConfig.locales = map[string]bool{}
+	}
+	for _, locale := range strings.Split(localeList, ",") {
+		targetConfig.locales[locale] = true
+	}
+	return nil
+}
+
 func processArgs() {
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, `usage: extract_apks -o <output-file> [-zip <output-zip-file>] `+
 			`-sdk-version value -abis value `+
-			`-screen-densities value {-stem value | -extract-single} [-allow-prereleased] `+
+			`-screen-densities value [-locales value] [-require-abi-split] {-stem value | -extract-single} [-allow-prereleased] `+
 			`[-apkcerts <apkcerts output file> -partition <partition>] <APK set>`)
 		flag.PrintDefaults()
 		os.Exit(2)
@@ -583,6 +642,8 @@ func processArgs() {
 		"comma-separated ABIs list of ARMEABI ARMEABI_V7A ARM64_V8A X86 X86_64 MIPS MIPS64")
 	flag.Var(screenDensityFlagValue{&targetConfig}, "screen-densities",
 		"'all' or comma-separated list of screen density names (NODPI LDPI MDPI TVDPI HDPI XHDPI XXHDPI XXXHDPI)")
+	flag.Var(localeFlagValue{&targetConfig}, "locales",
+		"'all' or comma-separated list of locales (en, fr, ...)")
 	flag.BoolVar(&targetConfig.allowPrereleased, "allow-prereleased", false,
 		"allow prereleased")
 	flag.StringVar(&targetConfig.stem, "stem", "", "output entries base name in the output zip file")
@@ -611,6 +672,11 @@ func main() {
 	if len(sel.entries) == 0 {
 		log.Fatalf("there are no entries for the target configuration: %#v", targetConfig)
 	}
+	if *requireAbiSplit {
+		if err := checkAbiSplits(toc, sel); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	outFile, err := os.Create(*outputFile)
 	if err != nil {
@@ -883,3 +883,130 @@ func TestWriteApks(t *testing.T) {
 		})
 	}
 }
+
+const localeAndAbiSplitsToc = `
+variant {
+  targeting {
+    sdk_version_targeting {
+      value { min { value: 21 } } } }
+  apk_set {
+    module_metadata {
+      name: "base" targeting {} delivery_type: INSTALL_TIME }
+    apk_description {
+      targeting {
+        sdk_version_targeting {
+          value { min { value: 21 } } } }
+      path: "splits/base-master.apk"
+      split_apk_metadata { is_master_split: true } }
+    apk_description {
+      targeting {
+        language_targeting {
+          value: "en"
+          alternatives: "fr" }
+        sdk_version_targeting {
+          value { min { value: 21 } } } }
+      path: "splits/base-en.apk"
+      split_apk_metadata { split_id: "config.en" } }
+    apk_description {
+      targeting {
+        language_targeting {
+          value: "fr"
+          alternatives: "en" }
+        sdk_version_targeting {
+          value { min { value: 21 } } } }
+      path: "splits/base-fr.apk"
+      split_apk_metadata { split_id: "config.fr" } }
+    apk_description {
+      targeting {
+        abi_targeting {
+          value { alias: ARM64_V8A } }
+        sdk_version_targeting {
+          value { min { value: 21 } } } }
+      path: "splits/base-arm64_v8a.apk"
+      split_apk_metadata { split_id: "config.arm64_v8a" } } }
+}
+bundletool {
+  version: "0.10.3" }
+`
+
+func TestSelectApks_Locales(t *testing.T) {
+	var toc bp.BuildApksResult
+	if err := prototext.Unmarshal([]byte(localeAndAbiSplitsToc), &toc); err != nil {
+		t.Fatal(err)
+	}
+	configs := []testConfigDesc{
+		{
+			name: "all locales",
+			targetConfig: TargetConfig{
+				sdkVersion: 29,
+				abis:       map[bp.Abi_AbiAlias]int{bp.Abi_ARM64_V8A: 0},
+			},
+			expected: SelectionResult{
+				"base",
+				[]string{
+					"splits/base-master.apk",
+					"splits/base-en.apk",
+					"splits/base-fr.apk",
+					"splits/base-arm64_v8a.apk",
+				},
+			},
+		},
+		{
+			name: "fr",
+			targetConfig: TargetConfig{
+				sdkVersion: 29,
+				abis:       map[bp.Abi_AbiAlias]int{bp.Abi_ARM64_V8A: 0},
+				locales:    map[string]bool{"fr": true},
+			},
+			expected: SelectionResult{
+				"base",
+				[]string{
+					"splits/base-master.apk",
+					"splits/base-fr.apk",
+					"splits/base-arm64_v8a.apk",
+				},
+			},
+		},
+	}
+	for _, config := range configs {
+		actual := selectApks(&toc, config.targetConfig)
+		if !reflect.DeepEqual(config.expected, actual) {
+			t.Errorf("%s: expected %v, got %v", config.name, config.expected, actual)
+		}
+	}
+}
+
+func TestCheckAbiSplits(t *testing.T) {
+	var toc bp.BuildApksResult
+	if err := prototext.Unmarshal([]byte(localeAndAbiSplitsToc), &toc); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name        string
+		abis        map[bp.Abi_AbiAlias]int
+		expectedErr string
+	}{
+		{
+			name: "arm64",
+			abis: map[bp.Abi_AbiAlias]int{bp.Abi_ARM64_V8A: 0, bp.Abi_ARMEABI_V7A: 1},
+		},
+		{
+			name:        "x86_64",
+			abis:        map[bp.Abi_AbiAlias]int{bp.Abi_X86_64: 0, bp.Abi_X86: 1},
+			expectedErr: `module "base" has no ABI split compatible with the target configuration`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			sel := selectApks(&toc, TargetConfig{sdkVersion: 29, abis: testCase.abis})
+			err := checkAbiSplits(&toc, sel)
+			if testCase.expectedErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+			} else if err == nil || err.Error() != testCase.expectedErr {
+				t.Errorf("expected error %q, got %v", testCase.expectedErr, err)
+			}
+		})
+	}
+}
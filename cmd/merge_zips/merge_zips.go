@@ -15,6 +15,7 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"flag"
 	"fmt"
@@ -137,6 +138,48 @@ func (be ZipEntryFromBuffer) WriteToZip(dest string, zw *zip.Writer) error {
 	return nil
 }
 
+// a deflatedZipEntry is a ZipEntryContents whose contents are deflated when it is written to the
+// output zip if they are stored uncompressed by its source.
+type deflatedZipEntry struct {
+	ZipEntryContents
+}
+
+func (de deflatedZipEntry) WriteToZip(dest string, zw *zip.Writer) error {
+	var fh zip.FileHeader
+	var contents io.Reader
+	switch entry := de.ZipEntryContents.(type) {
+	case *ZipEntryFromZip:
+		if err := entry.inputZip.Open(); err != nil {
+			return err
+		}
+		file := entry.inputZip.Entries()[entry.index]
+		if file.Method == zip.Store && !entry.IsDir() {
+			r, err := file.Open()
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			fh, contents = file.FileHeader, r
+		}
+	case ZipEntryFromBuffer:
+		if entry.fh.Method == zip.Store && !entry.IsDir() {
+			fh, contents = *entry.fh, bytes.NewReader(entry.content)
+		}
+	}
+	if contents == nil {
+		return de.ZipEntryContents.WriteToZip(dest, zw)
+	}
+
+	fh.Name = dest
+	fh.Method = zip.Deflate
+	w, err := zw.CreateHeader(&fh)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, contents)
+	return err
+}
+
 // Processing state.
 type OutputZip struct {
 	outputWriter     *zip.Writer
@@ -144,6 +187,7 @@ type OutputZip struct {
 	emulateJar       bool
 	sortEntries      bool
 	ignoreDuplicates bool
+	deflate          bool
 	excludeDirs      []string
 	excludeFiles     []string
 	sourceByDest     map[string]ZipEntryContents
@@ -171,12 +215,20 @@ func (oz *OutputZip) setExcludeFiles(excludeFiles []string) {
 	oz.excludeFiles = excludeFiles
 }
 
+// setDeflate makes the entries that are stored uncompressed be deflated in the output zip.
+func (oz *OutputZip) setDeflate(deflate bool) {
+	oz.deflate = deflate
+}
+
 // Adds an entry with given name whose source is given ZipEntryContents. Returns old ZipEntryContents
 // if entry with given name already exists.
 func (oz *OutputZip) addZipEntry(name string, source ZipEntryContents) (ZipEntryContents, error) {
 	if existingSource, exists := oz.sourceByDest[name]; exists {
 		return existingSource, nil
 	}
+	if oz.deflate {
+		source = deflatedZipEntry{source}
+	}
 	oz.sourceByDest[name] = source
 	// Delay writing an entry if entries need to be rearranged.
 	if oz.emulateJar || oz.sortEntries {
@@ -532,12 +584,13 @@ func (miz *ManagedInputZip) Entries() []*zip.File {
 
 // Actual processing.
 func mergeZips(inputZips []InputZip, writer *zip.Writer, manifest, pyMain string,
-	sortEntries, emulateJar, emulatePar, stripDirEntries, ignoreDuplicates bool,
+	sortEntries, emulateJar, emulatePar, stripDirEntries, ignoreDuplicates, deflate bool,
 	excludeFiles, excludeDirs []string, zipsToNotStrip map[string]bool) error {
 
 	out := NewOutputZip(writer, sortEntries, emulateJar, stripDirEntries, ignoreDuplicates)
 	out.setExcludeFiles(excludeFiles)
 	out.setExcludeDirs(excludeDirs)
+	out.setDeflate(deflate)
 	if manifest != "" {
 		if err := out.addManifest(manifest); err != nil {
 			return err
@@ -627,6 +680,7 @@ var (
 	pyMain           = flag.String("pm", "", "__main__.py file to insert in par")
 	prefix           = flag.String("prefix", "", "A file to prefix to the zip file")
 	ignoreDuplicates = flag.Bool("ignore-duplicates", false, "take each entry from the first zip it exists in and don't warn")
+	deflate          = flag.Bool("deflate", false, "deflate the entries that are stored uncompressed")
 )
 
 func init() {
@@ -753,7 +807,7 @@ func main() {
 		inputZips[i] = inputZipsManager.Manage(&FileInputZip{name: input})
 	}
 	err = mergeZips(inputZips, writer, *manifest, *pyMain, *sortEntries, *emulateJar, *emulatePar,
-		*stripDirEntries, *ignoreDuplicates, []string(excludeFiles), []string(excludeDirs),
+		*stripDirEntries, *ignoreDuplicates, *deflate, []string(excludeFiles), []string(excludeDirs),
 		map[string]bool(zipsToNotStrip))
 	if err != nil {
 		log.Fatal(err)
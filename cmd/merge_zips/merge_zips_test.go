@@ -251,7 +251,7 @@ func TestMergeZips(t *testing.T) {
 			writer := zip.NewWriter(out)
 
 			err := mergeZips(inputZips, writer, "", "",
-				test.sort, test.jar, false, test.stripDirEntries, test.ignoreDuplicates,
+				test.sort, test.jar, false, test.stripDirEntries, test.ignoreDuplicates, false,
 				test.stripFiles, test.stripDirs, test.zipsToNotStrip)
 
 			closeErr := writer.Close()
@@ -277,6 +277,58 @@ func TestMergeZips(t *testing.T) {
 	}
 }
 
+func TestMergeZipsDeflate(t *testing.T) {
+	inputZips := []InputZip{
+		&testInputZip{name: "in0", entries: []testZipEntry{a, bDir, bc}},
+		&testInputZip{name: "in1", entries: []testZipEntry{bd, be}},
+	}
+
+	out := &bytes.Buffer{}
+	writer := zip.NewWriter(out)
+	err := mergeZips(inputZips, writer, "", "", false, false, false, false, false, true,
+		nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []testZipEntry{a, bDir, bc, bd, be}
+	if len(zr.File) != len(want) {
+		t.Fatalf("want %d entries, got:\n%s", len(want), dumpZip(out.Bytes()))
+	}
+	for i, f := range zr.File {
+		wantMethod := zip.Deflate
+		if want[i].mode.IsDir() {
+			wantMethod = zip.Store
+		}
+		if f.Name != want[i].name || f.Method != wantMethod {
+			t.Errorf("entry %d: want %s with method %d, got %s with method %d",
+				i, want[i].name, wantMethod, f.Name, f.Method)
+		}
+		if f.Mode() != want[i].mode {
+			t.Errorf("%s: want mode %v, got %v", f.Name, want[i].mode, f.Mode())
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents := &bytes.Buffer{}
+		if _, err := contents.ReadFrom(r); err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+		if !bytes.Equal(contents.Bytes(), want[i].data) {
+			t.Errorf("%s: want contents %q, got %q", f.Name, want[i].data, contents.Bytes())
+		}
+	}
+}
+
 func testZipEntriesToBuf(entries []testZipEntry) []byte {
 	b := &bytes.Buffer{}
 	zw := zip.NewWriter(b)
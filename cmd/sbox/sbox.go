@@ -26,6 +26,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -35,6 +36,7 @@ import (
 	"android/soong/response"
 
 	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
 )
 
 var (
@@ -244,7 +246,7 @@ func runCommand(command *sbox_proto.Command, tempDir string, commandIndex int) (
 	}
 
 	// Remove files from the output directory
-	err = clearOutputDirectory(command.CopyAfter, outputDir, writeType(writeIfChanged))
+	err = clearOutputDirectory(command.CopyAfter, command.OutputDirs, outputDir, writeType(writeIfChanged))
 	if err != nil {
 		return "", err
 	}
@@ -280,7 +282,7 @@ func runCommand(command *sbox_proto.Command, tempDir string, commandIndex int) (
 
 	// Emulate ninja's behavior of creating the directories for any output files before
 	// running the command.
-	err = makeOutputDirs(command.CopyAfter, tempDir)
+	err = makeOutputDirs(command.CopyAfter, command.OutputDirs, tempDir)
 	if err != nil {
 		return "", err
 	}
@@ -343,8 +345,20 @@ func runCommand(command *sbox_proto.Command, tempDir string, commandIndex int) (
 		return "", err
 	}
 
+	// Find the files that were written into any output directories.
+	dirCopies, dirFileLists, err := collectOutputDirs(command.OutputDirs, tempDir)
+	if err != nil {
+		return "", err
+	}
+
 	// the created files match the declared files; now move them
-	err = moveFiles(command.CopyAfter, tempDir, "", writeType(writeIfChanged))
+	copies := append(append([]*sbox_proto.Copy(nil), command.CopyAfter...), dirCopies...)
+	err = moveFiles(copies, tempDir, "", writeType(writeIfChanged))
+	if err != nil {
+		return "", err
+	}
+
+	err = writeFileLists(command.OutputDirs, dirFileLists, writeType(writeIfChanged))
 	if err != nil {
 		return "", err
 	}
@@ -354,8 +368,9 @@ func runCommand(command *sbox_proto.Command, tempDir string, commandIndex int) (
 
 // makeOutputDirs creates directories in the sandbox dir for every file that has a rule to be copied
 // out of the sandbox.  This emulate's Ninja's behavior of creating directories for output files
-// so that the tools don't have to.
-func makeOutputDirs(copies []*sbox_proto.Copy, sandboxDir string) error {
+// so that the tools don't have to.  Output directories are created too, so that tools that expect
+// to be given an existing directory to write into work without an extra mkdir.
+func makeOutputDirs(copies []*sbox_proto.Copy, outputDirs []*sbox_proto.OutputDir, sandboxDir string) error {
 	for _, copyPair := range copies {
 		dir := joinPath(sandboxDir, filepath.Dir(copyPair.GetFrom()))
 		err := os.MkdirAll(dir, 0777)
@@ -363,6 +378,163 @@ func makeOutputDirs(copies []*sbox_proto.Copy, sandboxDir string) error {
 			return err
 		}
 	}
+	for _, outputDir := range outputDirs {
+		err := os.MkdirAll(joinPath(sandboxDir, outputDir.GetFrom()), 0777)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectOutputDirs finds the files that the command wrote into each output directory, checks them
+// against the expected file list if one was provided, and returns copy rules that will move each
+// file out of the sandbox along with the sorted list of files found in each output directory.
+func collectOutputDirs(outputDirs []*sbox_proto.OutputDir, sandboxDir string) ([]*sbox_proto.Copy, [][]string, error) {
+	var copies []*sbox_proto.Copy
+	fileLists := make([][]string, len(outputDirs))
+	for i, outputDir := range outputDirs {
+		fromDir := joinPath(sandboxDir, outputDir.GetFrom())
+		fileInfo, err := os.Stat(fromDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("output directory %s: does not exist", fromDir)
+		}
+		if !fileInfo.IsDir() {
+			return nil, nil, fmt.Errorf("output directory %s: not a directory", fromDir)
+		}
+
+		files := findAllFilesUnder(fromDir)
+		sort.Strings(files)
+
+		if expectedFileList := outputDir.GetExpectedFileList(); expectedFileList != "" {
+			err := checkExpectedFiles(files, expectedFileList)
+			if err != nil {
+				return nil, nil, fmt.Errorf("output directory %s: %w", outputDir.GetTo(), err)
+			}
+		}
+
+		// Remove any files left in the output directory by a previous run that the command didn't
+		// write this time.  The rest will be replaced by moveFiles.
+		err = removeStaleFiles(outputDir.GetTo(), files)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, file := range files {
+			copies = append(copies, &sbox_proto.Copy{
+				From: proto.String(filepath.Join(outputDir.GetFrom(), file)),
+				To:   proto.String(filepath.Join(outputDir.GetTo(), file)),
+			})
+		}
+		fileLists[i] = files
+	}
+	return copies, fileLists, nil
+}
+
+// checkExpectedFiles compares the sorted list of files found in an output directory against the
+// list of files in expectedFileList, and returns an error describing any differences.
+func checkExpectedFiles(files []string, expectedFileList string) error {
+	data, err := ioutil.ReadFile(expectedFileList)
+	if err != nil {
+		return err
+	}
+
+	expected := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			expected[filepath.Clean(line)] = true
+		}
+	}
+
+	var unexpected, missing []string
+	found := make(map[string]bool, len(files))
+	for _, file := range files {
+		found[file] = true
+		if !expected[file] {
+			unexpected = append(unexpected, file)
+		}
+	}
+	for file := range expected {
+		if !found[file] {
+			missing = append(missing, file)
+		}
+	}
+	sort.Strings(missing)
+
+	if len(unexpected) == 0 && len(missing) == 0 {
+		return nil
+	}
+
+	errorMessage := fmt.Sprintf("mismatch with expected file list %s\n", expectedFileList)
+	if len(missing) > 0 {
+		errorMessage += fmt.Sprintf("failed to create %v files:\n", len(missing))
+		for _, file := range missing {
+			errorMessage += "  " + file + "\n"
+		}
+	}
+	if len(unexpected) > 0 {
+		errorMessage += fmt.Sprintf("created %v unexpected files:\n", len(unexpected))
+		for _, file := range unexpected {
+			errorMessage += "  " + file + "\n"
+		}
+	}
+	return errors.New(errorMessage)
+}
+
+// removeStaleFiles removes any files under dir that are not in files.
+func removeStaleFiles(dir string, files []string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+
+	keep := make(map[string]bool, len(files))
+	for _, file := range files {
+		keep[file] = true
+	}
+
+	for _, existingFile := range findAllFilesUnder(dir) {
+		if !keep[existingFile] {
+			fullExistingFile := filepath.Join(dir, existingFile)
+			err := os.Remove(fullExistingFile)
+			if err != nil {
+				return fmt.Errorf("failed to remove obsolete output file %s: %w", fullExistingFile, err)
+			}
+		}
+	}
+	return nil
+}
+
+// writeFileLists writes the list of files found in each output directory to the output directory's
+// file list, if it has one.  If write is onlyWriteIfChanged then a file list is not written if its
+// contents would not change, avoiding updating the timestamp.
+func writeFileLists(outputDirs []*sbox_proto.OutputDir, fileLists [][]string, write writeType) error {
+	for i, outputDir := range outputDirs {
+		fileList := outputDir.GetFileList()
+		if fileList == "" {
+			continue
+		}
+
+		var buf bytes.Buffer
+		for _, file := range fileLists[i] {
+			buf.WriteString(file)
+			buf.WriteByte('\n')
+		}
+
+		if write == onlyWriteIfChanged {
+			if existing, err := ioutil.ReadFile(fileList); err == nil && bytes.Equal(existing, buf.Bytes()) {
+				continue
+			}
+		}
+
+		err := os.MkdirAll(filepath.Dir(fileList), 0777)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(fileList, buf.Bytes(), 0666)
+		if err != nil {
+			return fmt.Errorf("failed to write file list %s: %w", fileList, err)
+		}
+	}
 	return nil
 }
 
@@ -654,8 +826,10 @@ func moveFiles(copies []*sbox_proto.Copy, fromDir, toDir string, write writeType
 }
 
 // clearOutputDirectory removes all files in the output directory if write is alwaysWrite, or
-// any files not listed in copies if write is onlyWriteIfChanged
-func clearOutputDirectory(copies []*sbox_proto.Copy, outputDir string, write writeType) error {
+// any files not listed in copies, not in one of outputDirs and not one of their file lists if write
+// is onlyWriteIfChanged.  Stale files inside outputDirs are removed after the command has run.
+func clearOutputDirectory(copies []*sbox_proto.Copy, outputDirs []*sbox_proto.OutputDir,
+	outputDir string, write writeType) error {
 	if outputDir == "" {
 		return fmt.Errorf("output directory must be set")
 	}
@@ -669,11 +843,14 @@ func clearOutputDirectory(copies []*sbox_proto.Copy, outputDir string, write wri
 	for _, copyPair := range copies {
 		outputFiles[copyPair.GetTo()] = true
 	}
+	for _, dir := range outputDirs {
+		outputFiles[dir.GetFileList()] = true
+	}
 
 	existingFiles := findAllFilesUnder(outputDir)
 	for _, existingFile := range existingFiles {
 		fullExistingFile := filepath.Join(outputDir, existingFile)
-		if !outputFiles[fullExistingFile] {
+		if !outputFiles[fullExistingFile] && !inOutputDirs(fullExistingFile, outputDirs) {
 			err := os.Remove(fullExistingFile)
 			if err != nil {
 				return fmt.Errorf("failed to remove obsolete output file %s: %w", fullExistingFile, err)
@@ -684,6 +861,16 @@ func clearOutputDirectory(copies []*sbox_proto.Copy, outputDir string, write wri
 	return nil
 }
 
+// inOutputDirs returns true if path is inside any of outputDirs.
+func inOutputDirs(path string, outputDirs []*sbox_proto.OutputDir) bool {
+	for _, dir := range outputDirs {
+		if strings.HasPrefix(path, dir.GetTo()+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // Rewrite one or more depfiles so that it doesn't include the (randomized) sandbox directory
 // to an output file.
 func rewriteDepFiles(ins []string, out string) error {
@@ -18,8 +18,13 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"android/soong/cmd/sbox/sbox_proto"
+
+	"google.golang.org/protobuf/proto"
 )
 
 func Test_filesHaveSameContents(t *testing.T) {
@@ -125,3 +130,105 @@ func Test_filesHaveSameContents(t *testing.T) {
 		})
 	}
 }
+
+func Test_collectOutputDirs(t *testing.T) {
+	writeFile := func(t *testing.T, path, contents string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setup := func(t *testing.T) (sandboxDir, outDir string) {
+		t.Helper()
+		tempDir := t.TempDir()
+		sandboxDir = filepath.Join(tempDir, "sandbox")
+		outDir = filepath.Join(tempDir, "out")
+		writeFile(t, filepath.Join(sandboxDir, "out/gen/b.txt"), "b")
+		writeFile(t, filepath.Join(sandboxDir, "out/gen/a/c.txt"), "c")
+		// A stale file from a previous run that the command didn't write this time.
+		writeFile(t, filepath.Join(outDir, "gen/stale.txt"), "stale")
+		return sandboxDir, outDir
+	}
+
+	t.Run("copies and lists files", func(t *testing.T) {
+		sandboxDir, outDir := setup(t)
+		fileList := filepath.Join(outDir, "gen.files")
+		outputDirs := []*sbox_proto.OutputDir{{
+			From:     proto.String("out/gen"),
+			To:       proto.String(filepath.Join(outDir, "gen")),
+			FileList: proto.String(fileList),
+		}}
+
+		copies, fileLists, err := collectOutputDirs(outputDirs, sandboxDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var gotCopies []string
+		for _, c := range copies {
+			gotCopies = append(gotCopies, c.GetFrom()+" -> "+c.GetTo())
+		}
+		wantCopies := []string{
+			"out/gen/a/c.txt -> " + filepath.Join(outDir, "gen/a/c.txt"),
+			"out/gen/b.txt -> " + filepath.Join(outDir, "gen/b.txt"),
+		}
+		if !reflect.DeepEqual(gotCopies, wantCopies) {
+			t.Errorf("want copies %q, got %q", wantCopies, gotCopies)
+		}
+
+		if _, err := os.Stat(filepath.Join(outDir, "gen/stale.txt")); !os.IsNotExist(err) {
+			t.Errorf("expected stale file to be removed, got %v", err)
+		}
+
+		if err := writeFileLists(outputDirs, fileLists, alwaysWrite); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(fileList)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "a/c.txt\nb.txt\n"; string(got) != want {
+			t.Errorf("want file list %q, got %q", want, string(got))
+		}
+	})
+
+	t.Run("matches expected files", func(t *testing.T) {
+		sandboxDir, outDir := setup(t)
+		expected := filepath.Join(outDir, "expected.txt")
+		writeFile(t, expected, "b.txt\na/c.txt\n")
+		outputDirs := []*sbox_proto.OutputDir{{
+			From:             proto.String("out/gen"),
+			To:               proto.String(filepath.Join(outDir, "gen")),
+			ExpectedFileList: proto.String(expected),
+		}}
+
+		if _, _, err := collectOutputDirs(outputDirs, sandboxDir); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("mismatched expected files", func(t *testing.T) {
+		sandboxDir, outDir := setup(t)
+		expected := filepath.Join(outDir, "expected.txt")
+		writeFile(t, expected, "b.txt\nd.txt\n")
+		outputDirs := []*sbox_proto.OutputDir{{
+			From:             proto.String("out/gen"),
+			To:               proto.String(filepath.Join(outDir, "gen")),
+			ExpectedFileList: proto.String(expected),
+		}}
+
+		_, _, err := collectOutputDirs(outputDirs, sandboxDir)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		for _, want := range []string{"failed to create 1 files:\n  d.txt", "created 1 unexpected files:\n  a/c.txt"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected error to contain %q, got %q", want, err.Error())
+			}
+		}
+	})
+}
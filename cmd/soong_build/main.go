@@ -48,11 +48,14 @@ var (
 	delveListen string
 	delvePath   string
 
-	moduleGraphFile   string
-	moduleActionsFile string
-	docFile           string
-	bazelQueryViewDir string
-	bp2buildMarker    string
+	moduleGraphFile      string
+	moduleActionsFile    string
+	moduleDepsGraphFile  string
+	moduleDepsGraphRoots string
+	moduleIndexFile      string
+	docFile              string
+	bazelQueryViewDir    string
+	bp2buildMarker       string
 
 	cmdlineArgs bootstrap.Args
 )
@@ -79,6 +82,9 @@ func init() {
 	// Flags representing various modes soong_build can run in
 	flag.StringVar(&moduleGraphFile, "module_graph_file", "", "JSON module graph file to output")
 	flag.StringVar(&moduleActionsFile, "module_actions_file", "", "JSON file to output inputs/outputs of actions of modules")
+	flag.StringVar(&moduleDepsGraphFile, "module_deps_graph_file", "", "JSON file to output the dependencies of the modules in --module_deps_graph_roots to")
+	flag.StringVar(&moduleDepsGraphRoots, "module_deps_graph_roots", "", "comma separated list of root modules for --module_deps_graph_file")
+	flag.StringVar(&moduleIndexFile, "module_index_file", "", "JSON file to output the directory, outputs and installed files of every module to")
 	flag.StringVar(&docFile, "soong_docs", "", "build documentation file to output")
 	flag.StringVar(&bazelQueryViewDir, "bazel_queryview_dir", "", "path to the bazel queryview directory relative to --top")
 	flag.StringVar(&bp2buildMarker, "bp2build_marker", "", "If set, run bp2build, touch the specified marker file then exit")
@@ -211,6 +217,34 @@ func writeJsonModuleGraphAndActions(ctx *android.Context, graphPath string, acti
 	ctx.Context.PrintJSONGraphAndActions(graphFile, actionsFile)
 }
 
+func writeModuleDepsGraph(ctx *android.Context, graphPath string, roots []string) {
+	graphFile, err := os.Create(shared.JoinPath(topDir, graphPath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating module dependency graph file: %s\n", err)
+		os.Exit(1)
+	}
+	defer graphFile.Close()
+
+	if err := android.WriteModuleDepsGraph(ctx, roots, graphFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing module dependency graph: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func writeModuleIndex(ctx *android.Context, indexPath string) {
+	indexFile, err := os.Create(shared.JoinPath(topDir, indexPath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating module index file: %s\n", err)
+		os.Exit(1)
+	}
+	defer indexFile.Close()
+
+	if err := android.WriteModuleIndex(ctx, indexFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing module index: %s\n", err)
+		os.Exit(1)
+	}
+}
+
 func writeBuildGlobsNinjaFile(ctx *android.Context, buildDir string, config interface{}) []string {
 	ctx.EventHandler.Begin("globs_ninja_file")
 	defer ctx.EventHandler.End("globs_ninja_file")
@@ -244,6 +278,8 @@ func doChosenActivity(configuration android.Config, extraNinjaDeps []string) str
 	generateBazelWorkspace := bp2buildMarker != ""
 	generateQueryView := bazelQueryViewDir != ""
 	generateModuleGraphFile := moduleGraphFile != ""
+	generateModuleDepsGraphFile := moduleDepsGraphFile != ""
+	generateModuleIndexFile := moduleIndexFile != ""
 	generateDocFile := docFile != ""
 
 	if generateBazelWorkspace {
@@ -255,6 +291,16 @@ func doChosenActivity(configuration android.Config, extraNinjaDeps []string) str
 
 	blueprintArgs := cmdlineArgs
 
+	if generateModuleDepsGraphFile {
+		// The mutator that collects the dependencies is only registered in this mode.
+		configuration.SetCollectingModuleDepsGraph()
+	}
+
+	if generateModuleGraphFile || generateModuleIndexFile {
+		// The build.ninja file is not written, so the checks of the build are not needed.
+		configuration.SetQueryingModules()
+	}
+
 	ctx := newContext(configuration)
 	if mixedModeBuild {
 		runMixedModeBuild(configuration, ctx, extraNinjaDeps)
@@ -262,6 +308,12 @@ func doChosenActivity(configuration android.Config, extraNinjaDeps []string) str
 		var stopBefore bootstrap.StopBefore
 		if generateModuleGraphFile {
 			stopBefore = bootstrap.StopBeforeWriteNinja
+		} else if generateModuleDepsGraphFile {
+			// Only the mutators need to run to collect the dependencies.
+			stopBefore = bootstrap.StopBeforePrepareBuildActions
+		} else if generateModuleIndexFile {
+			// The outputs and installed files are only known once the build actions are generated.
+			stopBefore = bootstrap.StopBeforeWriteNinja
 		} else if generateQueryView {
 			stopBefore = bootstrap.StopBeforePrepareBuildActions
 		} else if generateDocFile {
@@ -286,6 +338,14 @@ func doChosenActivity(configuration android.Config, extraNinjaDeps []string) str
 			writeJsonModuleGraphAndActions(ctx, moduleGraphFile, moduleActionsFile)
 			writeDepFile(moduleGraphFile, *ctx.EventHandler, ninjaDeps)
 			return moduleGraphFile
+		} else if generateModuleDepsGraphFile {
+			writeModuleDepsGraph(ctx, moduleDepsGraphFile, strings.Split(moduleDepsGraphRoots, ","))
+			writeDepFile(moduleDepsGraphFile, *ctx.EventHandler, ninjaDeps)
+			return moduleDepsGraphFile
+		} else if generateModuleIndexFile {
+			writeModuleIndex(ctx, moduleIndexFile)
+			writeDepFile(moduleIndexFile, *ctx.EventHandler, ninjaDeps)
+			return moduleIndexFile
 		} else if generateDocFile {
 			// TODO: we could make writeDocs() return the list of documentation files
 			// written and add them to the .d file. Then soong_docs would be re-run
@@ -85,10 +85,11 @@ type GlobalConfig struct {
 	CpuVariant             map[android.ArchType]string // cpu variant for each architecture
 	InstructionSetFeatures map[android.ArchType]string // instruction set for each architecture
 
-	BootImageProfiles android.Paths // path to a boot-image-profile.txt file
-	BootFlags         string        // extra flags to pass to dex2oat for the boot image
-	Dex2oatImageXmx   string        // max heap size for dex2oat for the boot image
-	Dex2oatImageXms   string        // initial heap size for dex2oat for the boot image
+	BootImageProfiles         android.Paths // path to a boot-image-profile.txt file
+	BootImageProfileFragments android.Paths // additional entries for the platform boot image profile
+	BootFlags                 string        // extra flags to pass to dex2oat for the boot image
+	Dex2oatImageXmx           string        // max heap size for dex2oat for the boot image
+	Dex2oatImageXms           string        // initial heap size for dex2oat for the boot image
 
 	// If true, downgrade the compiler filter of dexpreopt to "verify" when verify_uses_libraries
 	// check fails, instead of failing the build. This will disable any AOT-compilation.
@@ -236,7 +237,8 @@ func ParseGlobalConfig(ctx android.PathContext, data []byte) (*GlobalConfig, err
 
 		// Copies of entries in GlobalConfig that are not constructable without extra parameters.  They will be
 		// used to construct the real value manually below.
-		BootImageProfiles []string
+		BootImageProfiles         []string
+		BootImageProfileFragments []string
 	}
 
 	config := GlobalJSONConfig{}
@@ -247,6 +249,7 @@ func ParseGlobalConfig(ctx android.PathContext, data []byte) (*GlobalConfig, err
 
 	// Construct paths that require a PathContext.
 	config.GlobalConfig.BootImageProfiles = constructPaths(ctx, config.BootImageProfiles)
+	config.GlobalConfig.BootImageProfileFragments = constructPaths(ctx, config.BootImageProfileFragments)
 
 	return config.GlobalConfig, nil
 }
@@ -686,6 +689,7 @@ func GlobalConfigForTests(ctx android.PathContext) *GlobalConfig {
 		CpuVariant:                         nil,
 		InstructionSetFeatures:             nil,
 		BootImageProfiles:                  nil,
+		BootImageProfileFragments:          nil,
 		BootFlags:                          "",
 		Dex2oatImageXmx:                    "",
 		Dex2oatImageXms:                    "",
@@ -17,6 +17,7 @@ package dexpreopt
 import (
 	"android/soong/android"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -213,6 +214,37 @@ func TestDexPreoptStandaloneSystemServerJars(t *testing.T) {
 	android.AssertStringEquals(t, "installs", wantInstalls.String(), rule.Installs().String())
 }
 
+func TestDexPreoptStandaloneSystemServerJarsClassLoaderContext(t *testing.T) {
+	config := android.TestConfig("out", nil, "", nil)
+	ctx := android.BuilderContextForTesting(config)
+	globalSoong := globalSoongConfigForTests()
+	global := GlobalConfigForTests(ctx)
+	module := testPlatformSystemServerModuleConfig(ctx, "service-A")
+
+	global.SystemServerJars = android.CreateTestConfiguredJarList(
+		[]string{"platform:service-B", "platform:service-C"})
+	global.StandaloneSystemServerJars = android.CreateTestConfiguredJarList(
+		[]string{"platform:service-A"})
+
+	rule, err := GenerateDexpreoptRule(ctx, globalSoong, global, module)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A standalone jar is compiled against the full system server classpath, which is the parent
+	// of its class loader.
+	serviceB := SystemServerDexJarHostPath(ctx, "service-B")
+	serviceC := SystemServerDexJarHostPath(ctx, "service-C")
+	commands := strings.Join(rule.Commands(), "\n")
+	android.AssertStringDoesContain(t, "class loader context", commands,
+		`--class-loader-context="PCL[];PCL[`+serviceB.String()+`:`+serviceC.String()+`]"`)
+	android.AssertStringDoesContain(t, "stored class loader context", commands,
+		`--stored-class-loader-context="PCL[];PCL[/system/framework/service-B.jar:/system/framework/service-C.jar]"`)
+	inputs := rule.Inputs().Strings()
+	android.AssertStringListContains(t, "inputs", inputs, serviceB.String())
+	android.AssertStringListContains(t, "inputs", inputs, serviceC.String())
+}
+
 func TestDexPreoptApexStandaloneSystemServerJars(t *testing.T) {
 	config := android.TestConfig("out", nil, "", nil)
 	ctx := android.BuilderContextForTesting(config)
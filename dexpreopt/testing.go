@@ -168,6 +168,14 @@ func FixtureSetBootImageProfiles(profiles ...string) android.FixturePreparer {
 	})
 }
 
+// FixtureSetBootImageProfileFragments sets the BootImageProfileFragments property in the global
+// config.
+func FixtureSetBootImageProfileFragments(fragments ...string) android.FixturePreparer {
+	return FixtureModifyGlobalConfig(func(ctx android.PathContext, dexpreoptConfig *GlobalConfig) {
+		dexpreoptConfig.BootImageProfileFragments = android.PathsForSource(ctx, fragments)
+	})
+}
+
 // FixtureDisableGenerateProfile sets the DisableGenerateProfile property in the global config.
 func FixtureDisableGenerateProfile(disable bool) android.FixturePreparer {
 	return FixtureModifyGlobalConfig(func(_ android.PathContext, dexpreoptConfig *GlobalConfig) {
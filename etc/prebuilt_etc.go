@@ -135,6 +135,7 @@ type PrebuiltEtc struct {
 	android.ModuleBase
 	android.DefaultableModuleBase
 	android.BazelModuleBase
+	android.SdkBase
 
 	snapshot.VendorSnapshotModuleInterface
 	snapshot.RecoverySnapshotModuleInterface
@@ -282,6 +283,11 @@ func (p *PrebuiltEtc) Installable() bool {
 	return p.properties.Installable == nil || proptools.Bool(p.properties.Installable)
 }
 
+// EverInstallable returns true if the module is ever installable.
+func (p *PrebuiltEtc) EverInstallable() bool {
+	return p.Installable()
+}
+
 func (p *PrebuiltEtc) InVendor() bool {
 	return p.ModuleBase.InstallInVendor()
 }
@@ -328,6 +334,14 @@ func (p *PrebuiltEtc) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	if p.subdirProperties.Sub_dir != nil && p.subdirProperties.Relative_install_path != nil {
 		ctx.PropertyErrorf("sub_dir", "relative_install_path is set. Cannot set sub_dir")
 	}
+	if err := android.ValidateRelativeInstallPath(p.SubDir()); err != nil {
+		if p.subdirProperties.Sub_dir != nil {
+			ctx.PropertyErrorf("sub_dir", "%s", err)
+		} else {
+			ctx.PropertyErrorf("relative_install_path", "%s", err)
+		}
+		return
+	}
 
 	// If soc install dir was specified and SOC specific is set, set the installDirPath to the
 	// specified socInstallDirBase.
@@ -395,11 +409,13 @@ func InitPrebuiltEtcModule(p *PrebuiltEtc, dirBase string) {
 	p.installDirBase = dirBase
 	p.AddProperties(&p.properties)
 	p.AddProperties(&p.subdirProperties)
+	android.InitSdkAwareModule(p)
 }
 
 func InitPrebuiltRootModule(p *PrebuiltEtc) {
 	p.installDirBase = "."
 	p.AddProperties(&p.properties)
+	android.InitSdkAwareModule(p)
 }
 
 // prebuilt_etc is for a prebuilt artifact that is installed in
@@ -0,0 +1,132 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etc
+
+import (
+	"path/filepath"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// Contains support for adding prebuilt_etc modules to a module_exports.
+
+func init() {
+	android.RegisterSdkMemberType(prebuiltEtcMemberType)
+}
+
+var prebuiltEtcMemberType = &prebuiltEtcSdkMemberType{
+	SdkMemberTypeBase: android.SdkMemberTypeBase{
+		PropertyName: "prebuilt_etcs",
+
+		// A prebuilt_etc has no prebuilt counterpart that can coexist with the source module so the
+		// snapshot simply contains another prebuilt_etc module that replaces the source one.
+		UseSourceModuleTypeInSnapshot: true,
+	},
+}
+
+type prebuiltEtcSdkMemberType struct {
+	android.SdkMemberTypeBase
+}
+
+func (mt *prebuiltEtcSdkMemberType) AddDependencies(ctx android.SdkDependencyContext, dependencyTag blueprint.DependencyTag, names []string) {
+	// prebuilt_etc modules are device only and only have a variant for the primary architecture.
+	targets := ctx.MultiTargets()
+	if !ctx.Device() || len(targets) == 0 {
+		return
+	}
+	variations := append(targets[0].Variations(),
+		blueprint.Variation{Mutator: "image", Variation: android.CoreVariation})
+	ctx.AddFarVariationDependencies(variations, dependencyTag, names...)
+}
+
+func (mt *prebuiltEtcSdkMemberType) IsInstance(module android.Module) bool {
+	// Only modules installed into etc/ can be represented by a prebuilt_etc in the snapshot.
+	p, ok := module.(*PrebuiltEtc)
+	return ok && p.BaseDir() == "etc" && p.Os().Class == android.Device
+}
+
+func (mt *prebuiltEtcSdkMemberType) AddPrebuiltModule(ctx android.SdkMemberContext, member android.SdkMember) android.BpModule {
+	return ctx.SnapshotBuilder().AddPrebuiltModule(member, "prebuilt_etc")
+}
+
+func (mt *prebuiltEtcSdkMemberType) CreateVariantPropertiesStruct() android.SdkMemberProperties {
+	return &prebuiltEtcSdkMemberProperties{}
+}
+
+var _ android.SdkMemberType = (*prebuiltEtcSdkMemberType)(nil)
+
+// prebuiltEtcSdkMemberProperties is the set of properties that need to be added to the
+// prebuilt_etc module in the snapshot.
+//
+// The exported (capitalized) fields will be examined and may be changed during common value
+// extraction. The unexported fields will be left untouched.
+type prebuiltEtcSdkMemberProperties struct {
+	android.SdkMemberPropertiesBase
+
+	// archType is not exported as if set (to a non default value) it is always arch specific.
+	// This is "" for common properties.
+	archType string
+
+	// The source file of the prebuilt.
+	Src android.Path `android:"arch_variant"`
+
+	// The name of the installed file.
+	Filename string `android:"arch_variant"`
+
+	// The subdirectory of etc/ into which the file is installed.
+	Sub_dir string `android:"arch_variant"`
+
+	// Set to false if the module is not installable, nil otherwise.
+	Installable *bool
+}
+
+func (p *prebuiltEtcSdkMemberProperties) PopulateFromVariant(ctx android.SdkMemberContext, variant android.Module) {
+	m := variant.(*PrebuiltEtc)
+
+	p.archType = m.Target().Arch.ArchType.String()
+	p.Src = m.sourceFilePath
+	p.Filename = m.OutputFile().Base()
+	p.Sub_dir = m.SubDir()
+	if !m.Installable() {
+		p.Installable = m.properties.Installable
+	}
+}
+
+func (p *prebuiltEtcSdkMemberProperties) AddToPropertySet(ctx android.SdkMemberContext, propertySet android.BpPropertySet) {
+	if p.Src != nil {
+		// prebuilt_etc modules are device only so there is no need for an os prefix.
+		dest := filepath.Join(p.archType, "etc", ctx.Name(), p.Src.Base())
+		ctx.SnapshotBuilder().CopyToSnapshot(p.Src, dest)
+		propertySet.AddProperty("src", dest)
+	}
+
+	// Always set the filename as otherwise the versioned module in the snapshot would install the
+	// file using its own name.
+	if p.Filename != "" {
+		propertySet.AddProperty("filename", p.Filename)
+	}
+
+	if p.Sub_dir != "" {
+		propertySet.AddProperty("sub_dir", p.Sub_dir)
+	}
+
+	if p.Installable != nil {
+		propertySet.AddProperty("installable", *p.Installable)
+	}
+}
+
+var _ android.SdkMemberProperties = (*prebuiltEtcSdkMemberProperties)(nil)
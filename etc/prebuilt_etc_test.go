@@ -180,6 +180,28 @@ func TestPrebuiltEtcCannotSetRelativeInstallPathAndSubDir(t *testing.T) {
 		`)
 }
 
+func TestPrebuiltEtcInvalidRelativeInstallPath(t *testing.T) {
+	prepareForPrebuiltEtcTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(`sub_dir: invalid relative install path "../bar": Path is outside directory: ../bar`)).
+		RunTestWithBp(t, `
+			prebuilt_etc {
+				name: "foo.conf",
+				src: "foo.conf",
+				sub_dir: "../bar",
+			}
+		`)
+
+	prepareForPrebuiltEtcTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(`relative_install_path: invalid relative install path "/bar": Path is outside directory: /bar`)).
+		RunTestWithBp(t, `
+			prebuilt_etc {
+				name: "foo.conf",
+				src: "foo.conf",
+				relative_install_path: "/bar",
+			}
+		`)
+}
+
 func TestPrebuiltEtcHost(t *testing.T) {
 	result := prepareForPrebuiltEtcTest.RunTestWithBp(t, `
 		prebuilt_etc_host {
@@ -195,6 +217,36 @@ func TestPrebuiltEtcHost(t *testing.T) {
 	}
 }
 
+func TestPrebuiltEtcHostRelativeInstallPathInstallDirPath(t *testing.T) {
+	result := prepareForPrebuiltEtcTest.RunTestWithBp(t, `
+		prebuilt_etc_host {
+			name: "foo.conf",
+			src: "foo.conf",
+			relative_install_path: "bar/baz",
+		}
+	`)
+
+	buildOS := result.Config.BuildOS.String()
+	p := result.Module("foo.conf", buildOS+"_common").(*PrebuiltEtc)
+	expected := filepath.Join("out/soong/host", result.Config.PrebuiltOS(), "etc", "bar", "baz")
+	android.AssertPathRelativeToTopEquals(t, "install dir", expected, p.installDirPath)
+}
+
+func TestPrebuiltRootHostInstallDirPath(t *testing.T) {
+	result := prepareForPrebuiltEtcTest.RunTestWithBp(t, `
+		prebuilt_root_host {
+			name: "foo.conf",
+			src: "foo.conf",
+			relative_install_path: "share/bar",
+		}
+	`)
+
+	buildOS := result.Config.BuildOS.String()
+	p := result.Module("foo.conf", buildOS+"_common").(*PrebuiltEtc)
+	expected := filepath.Join("out/soong/host", result.Config.PrebuiltOS(), "share", "bar")
+	android.AssertPathRelativeToTopEquals(t, "install dir", expected, p.installDirPath)
+}
+
 func TestPrebuiltRootInstallDirPath(t *testing.T) {
 	result := prepareForPrebuiltEtcTest.RunTestWithBp(t, `
 		prebuilt_root {
@@ -106,6 +106,10 @@ func (s *SnapshotEtc) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	if s.properties.Relative_install_path != nil {
 		subDir = *s.properties.Relative_install_path
 	}
+	if err := android.ValidateRelativeInstallPath(subDir); err != nil {
+		ctx.PropertyErrorf("relative_install_path", "%s", err)
+		return
+	}
 
 	s.installDirPath = android.PathForModuleInstall(ctx, "etc", subDir)
 
@@ -76,10 +76,13 @@ type filesystemProperties struct {
 	// Name of the partition stored in vbmeta desc. Defaults to the name of this module.
 	Partition_name *string
 
-	// Type of the filesystem. Currently, ext4, cpio, and compressed_cpio are supported. Default
-	// is ext4.
+	// Type of the filesystem. Currently, ext4, erofs, cpio, and compressed_cpio are supported.
+	// Default is ext4.
 	Type *string
 
+	// Properties used when type is erofs.
+	Erofs erofsProperties
+
 	// file_contexts file to make image. Currently, only ext4 is supported.
 	File_contexts *string `android:"path"`
 
@@ -94,6 +97,15 @@ type filesystemProperties struct {
 	Symlinks []symlinkDefinition
 }
 
+type erofsProperties struct {
+	// Compressor passed to mkfs.erofs. One of "lz4hc", "lz4" and "none". Default is "lz4hc".
+	Compressor *string
+
+	// Path to the file passed to mkfs.erofs as --compress-hints, which selects the compression
+	// of individual files.
+	Compress_hints *string `android:"path"`
+}
+
 // android_filesystem packages a set of modules and their transitive dependencies into a filesystem
 // image. The filesystem images are expected to be mounted in the target device, which means the
 // modules in the filesystem image are built for the target device (i.e. Android, not Linux host).
@@ -124,6 +136,7 @@ type fsType int
 
 const (
 	ext4Type fsType = iota
+	erofsType
 	compressedCpioType
 	cpioType // uncompressed
 	unknown
@@ -134,6 +147,8 @@ func (f *filesystem) fsType(ctx android.ModuleContext) fsType {
 	switch typeStr {
 	case "ext4":
 		return ext4Type
+	case "erofs":
+		return erofsType
 	case "compressed_cpio":
 		return compressedCpioType
 	case "cpio":
@@ -152,7 +167,7 @@ var pctx = android.NewPackageContext("android/soong/filesystem")
 
 func (f *filesystem) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	switch f.fsType(ctx) {
-	case ext4Type:
+	case ext4Type, erofsType:
 		f.output = f.buildImageUsingBuildImage(ctx)
 	case compressedCpioType:
 		f.output = f.buildCpioImage(ctx, true)
@@ -299,20 +314,42 @@ func (f *filesystem) buildPropFile(ctx android.ModuleContext) (propFile android.
 	// Type string that build_image.py accepts.
 	fsTypeStr := func(t fsType) string {
 		switch t {
-		// TODO(jiyong): add more types like f2fs, erofs, etc.
+		// TODO(jiyong): add more types like f2fs, etc.
 		case ext4Type:
 			return "ext4"
+		case erofsType:
+			return "erofs"
 		}
 		panic(fmt.Errorf("unsupported fs type %v", t))
 	}
 
-	addStr("fs_type", fsTypeStr(f.fsType(ctx)))
+	t := f.fsType(ctx)
+	addStr("fs_type", fsTypeStr(t))
 	addStr("mount_point", "/")
 	addStr("use_dynamic_partition_size", "true")
-	addPath("ext_mkuserimg", ctx.Config().HostToolPath(ctx, "mkuserimg_mke2fs"))
-	// b/177813163 deps of the host tools have to be added. Remove this.
-	for _, t := range []string{"mke2fs", "e2fsdroid", "tune2fs"} {
-		deps = append(deps, ctx.Config().HostToolPath(ctx, t))
+	switch t {
+	case ext4Type:
+		addPath("ext_mkuserimg", ctx.Config().HostToolPath(ctx, "mkuserimg_mke2fs"))
+		// b/177813163 deps of the host tools have to be added. Remove this.
+		for _, tool := range []string{"mke2fs", "e2fsdroid", "tune2fs"} {
+			deps = append(deps, ctx.Config().HostToolPath(ctx, tool))
+		}
+		if f.properties.Erofs.Compressor != nil || f.properties.Erofs.Compress_hints != nil {
+			ctx.PropertyErrorf("erofs", "erofs properties are only supported when type is erofs")
+		}
+	case erofsType:
+		// build_image runs mkfs.erofs from the host tools.
+		deps = append(deps, ctx.Config().HostToolPath(ctx, "mkfs.erofs"))
+		switch compressor := proptools.StringDefault(f.properties.Erofs.Compressor, "lz4hc"); compressor {
+		case "lz4hc", "lz4":
+			addStr("erofs_default_compressor", compressor)
+		case "none":
+		default:
+			ctx.PropertyErrorf("erofs.compressor", "%q not supported, must be one of lz4hc, lz4 or none", compressor)
+		}
+		if hints := proptools.String(f.properties.Erofs.Compress_hints); hints != "" {
+			addPath("erofs_default_compress_hints", android.PathForModuleSrc(ctx, hints))
+		}
 	}
 
 	if proptools.Bool(f.properties.Use_avb) {
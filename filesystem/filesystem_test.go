@@ -43,6 +43,59 @@ func TestFileSystemDeps(t *testing.T) {
 	result.ModuleForTests("myfilesystem", "android_common").Output("myfilesystem.img")
 }
 
+func TestFileSystemErofs(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		fixture,
+		android.FixtureMergeMockFs(android.MockFS{
+			"compress_hints.txt": nil,
+		}),
+	).RunTestWithBp(t, `
+		android_filesystem {
+			name: "myfilesystem",
+			type: "erofs",
+			erofs: {
+				compressor: "lz4",
+				compress_hints: "compress_hints.txt",
+			},
+			symlinks: [{
+				target: "/system/bin/foo",
+				name: "bin/foo",
+			}],
+		}
+	`)
+
+	module := result.ModuleForTests("myfilesystem", "android_common")
+
+	// build_image runs mkfs.erofs with the options from the prop file.
+	prop := module.Output("prop")
+	command := android.StringRelativeToTop(result.Config, prop.RuleParams.Command)
+	android.AssertStringDoesContain(t, "fs_type", command, `"fs_type=erofs"`)
+	android.AssertStringDoesContain(t, "compressor", command, `"erofs_default_compressor=lz4"`)
+	android.AssertStringDoesContain(t, "compress hints", command, `"erofs_default_compress_hints=compress_hints.txt"`)
+	android.AssertStringDoesNotContain(t, "ext4 tools", command, "ext_mkuserimg")
+
+	// The output file name and the symlinks are the same as for ext4.
+	image := module.Output("myfilesystem.img")
+	android.AssertStringListContains(t, "build_image implicits", android.PathsRelativeToTop(image.Implicits),
+		"out/soong/host/linux-x86/bin/mkfs.erofs")
+	android.AssertStringDoesContain(t, "symlinks",
+		module.Output("root.zip").RuleParams.Command, "ln -sf /system/bin/foo")
+}
+
+func TestFileSystemErofsUnsupportedCompressor(t *testing.T) {
+	fixture.ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`erofs.compressor: "gzip" not supported, must be one of lz4hc, lz4 or none`)).
+		RunTestWithBp(t, `
+		android_filesystem {
+			name: "myfilesystem",
+			type: "erofs",
+			erofs: {
+				compressor: "gzip",
+			},
+		}
+	`)
+}
+
 func TestFileSystemFillsLinkerConfigWithStubLibs(t *testing.T) {
 	result := fixture.RunTestWithBp(t, `
 		android_system_image {
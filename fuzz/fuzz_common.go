@@ -18,6 +18,8 @@ package fuzz
 
 import (
 	"encoding/json"
+	"fmt"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -122,6 +124,31 @@ func IsValid(fuzzModule FuzzModule) bool {
 	return true
 }
 
+// CorpusFileNames returns the names of the corpus files in the seed corpus. A file is named after
+// its base name, unless several files have the same base name, e.g. because they were matched by
+// globs in different directories. These are named after their path relative to the module
+// directory, with the separators replaced by underscores, so that none of them are dropped.
+func CorpusFileNames(corpus android.Paths) []string {
+	count := make(map[string]int)
+	for _, f := range corpus {
+		count[f.Base()]++
+	}
+	names := make([]string, 0, len(corpus))
+	used := make(map[string]bool)
+	for _, f := range corpus {
+		name := f.Base()
+		if count[name] > 1 {
+			name = strings.ReplaceAll(f.Rel(), "/", "_")
+		}
+		for i := 1; used[name]; i++ {
+			name = fmt.Sprintf("%d_%s", i, f.Base())
+		}
+		used[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
 func (s *FuzzPackager) PackageArtifacts(ctx android.SingletonContext, module android.Module, fuzzModule FuzzPackagedModule, archDir android.OutputPath, builder *android.RuleBuilder) []FileToZip {
 	// Package the corpora into a zipfile.
 	var files []FileToZip
@@ -135,17 +162,14 @@ func (s *FuzzPackager) PackageArtifacts(ctx android.SingletonContext, module and
 		files = append(files, FileToZip{corpusZip, ""})
 	}
 
-	// Package the data into a zipfile.
-	if fuzzModule.Data != nil {
-		dataZip := archDir.Join(ctx, module.Name()+"_data.zip")
-		command := builder.Command().BuiltTool("soong_zip").
-			FlagWithOutput("-o ", dataZip)
-		for _, f := range fuzzModule.Data {
-			intermediateDir := strings.TrimSuffix(f.String(), f.Rel())
-			command.FlagWithArg("-C ", intermediateDir)
-			command.FlagWithInput("-f ", f)
+	// The data files are packaged next to the fuzz target, with their paths relative to the module
+	// directory preserved, so that the fuzz target finds them at runtime.
+	for _, f := range fuzzModule.Data {
+		dir := filepath.Dir(f.Rel())
+		if dir == "." {
+			dir = ""
 		}
-		files = append(files, FileToZip{dataZip, ""})
+		files = append(files, FileToZip{f, dir})
 	}
 
 	// The dictionary.
@@ -183,8 +207,10 @@ func (s *FuzzPackager) BuildZipFile(ctx android.SingletonContext, module android
 	// Don't add modules to 'make haiku-rust' that are set to not be
 	// exported to the fuzzing infrastructure.
 	if config := fuzzModule.FuzzProperties.Fuzz_config; config != nil {
-		if strings.Contains(hostOrTargetString, "host") && !BoolDefault(config.Fuzz_on_haiku_host, true) {
-			return archDirs[archOs], false
+		if strings.Contains(hostOrTargetString, "host") {
+			if !BoolDefault(config.Fuzz_on_haiku_host, true) {
+				return archDirs[archOs], false
+			}
 		} else if !BoolDefault(config.Fuzz_on_haiku_device, true) {
 			return archDirs[archOs], false
 		}
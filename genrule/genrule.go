@@ -95,6 +95,14 @@ type SourceFileGenerator interface {
 	GeneratedDeps() android.Paths
 }
 
+// SourceDirGenerator is implemented by generators of directories whose files are only known after
+// they have been generated.
+type SourceDirGenerator interface {
+	// GeneratedSourceDirs returns the generated directories and, in the same order, the files that
+	// list the paths of the files in each of them, relative to the directory.
+	GeneratedSourceDirs() (dirs android.Paths, fileLists android.Paths)
+}
+
 // Alias for android.HostToolProvider
 // Deprecated: use android.HostToolProvider instead.
 type HostToolProvider interface {
@@ -105,9 +113,18 @@ type hostToolDependencyTag struct {
 	blueprint.BaseDependencyTag
 	android.LicenseAnnotationToolchainDependencyTag
 	label string
+
+	// True if this is a dependency on the linux_bionic variant of the tool, which is preferred over
+	// the BuildOSTarget variant when it is enabled.
+	linuxBionic bool
 }
 
 func (t hostToolDependencyTag) AllowDisabledModuleDependency(target android.Module) bool {
+	// Allow depending on a disabled linux_bionic variant as the BuildOSTarget variant is used
+	// instead.
+	if t.linuxBionic {
+		return true
+	}
 	// Allow depending on a disabled module if it's replaced by a prebuilt
 	// counterpart. We get the prebuilt through android.PrebuiltGetPreferred in
 	// GenerateAndroidBuildActions.
@@ -127,7 +144,7 @@ type generatorProperties struct {
 	//  $(in): one or more input files.
 	//  $(out): a single output file.
 	//  $(depfile): a file to which dependencies will be written, if the depfile property is set to true.
-	//  $(genDir): the sandbox directory for this tool; contains $(out).
+	//  $(genDir): the sandbox directory for this tool; contains $(out) and the out_dirs.
 	//  $$: a literal $
 	Cmd *string
 
@@ -165,6 +182,17 @@ type Module struct {
 	// prefix environment variables to it.
 	CmdModifier func(ctx android.ModuleContext, cmd string) string
 
+	// ExtraVariables can be set by wrappers around genrule to support variables in the command
+	// that genrule doesn't know about, for example $(classpath :module) in java_genrule. It is
+	// called with the variable, including any arguments, and returns its expansion and true, or
+	// false if it doesn't support the variable. The command can be used to add implicit inputs.
+	ExtraVariables func(ctx android.ModuleContext, cmd *android.RuleBuilderCommand, name string) (string, bool, error)
+
+	// SandboxInputs can be set by wrappers around genrule to copy the srcs into the sandbox in
+	// addition to the tools, so that the command can't read files that are not declared.  The
+	// command is run from the root of the sandbox.
+	SandboxInputs bool
+
 	android.ImageInterface
 
 	properties generatorProperties
@@ -182,6 +210,10 @@ type Module struct {
 	outputFiles android.Paths
 	outputDeps  android.Paths
 
+	// The directories listed in out_dirs, and the lists of the files that were generated in them.
+	outputDirs         android.WritablePaths
+	outputDirFileLists android.WritablePaths
+
 	subName string
 	subDir  string
 
@@ -199,6 +231,11 @@ type generateTask struct {
 	genDir     android.WritablePath
 	extraTools android.Paths // dependencies on tools used by the generator
 
+	// Directories containing files that are not known ahead of time, and the lists of the files
+	// found in them that sbox writes after the command has run.
+	outDirs         android.WritablePaths
+	outDirFileLists android.WritablePaths
+
 	cmd string
 	// For gensrsc sharding.
 	shard  int
@@ -221,6 +258,10 @@ func (g *Module) GeneratedDeps() android.Paths {
 	return g.outputDeps
 }
 
+func (g *Module) GeneratedSourceDirs() (android.Paths, android.Paths) {
+	return g.outputDirs.Paths(), g.outputDirFileLists.Paths()
+}
+
 func (g *Module) OutputFiles(tag string) (android.Paths, error) {
 	if tag == "" {
 		return append(android.Paths{}, g.outputFiles...), nil
@@ -231,6 +272,12 @@ func (g *Module) OutputFiles(tag string) (android.Paths, error) {
 			return android.Paths{outputFile}, nil
 		}
 	}
+	// or one of the output directories
+	for _, outputDir := range g.outputDirs {
+		if outputDir.Rel() == tag {
+			return android.Paths{outputDir}, nil
+		}
+	}
 	return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 }
 
@@ -244,7 +291,26 @@ func toolDepsMutator(ctx android.BottomUpMutatorContext) {
 			if m := android.SrcIsModule(tool); m != "" {
 				tool = m
 			}
+			// A tool runs on the build host, so it must have a variant for it. Report a device only or
+			// arch independent module here instead of as a missing variant of the dependency.
+			if ctx.OtherModuleExists(tool) &&
+				!ctx.OtherModuleFarDependencyVariantExists(ctx.Config().BuildOSTarget.Variations(), tool) {
+				ctx.PropertyErrorf("tools", "%q has no %s variant, tools run on the build host so they "+
+					"must be host modules, e.g. a java_binary_host or a module with host_supported: true",
+					tag.label, ctx.Config().BuildOSTarget.String())
+				continue
+			}
 			ctx.AddFarVariationDependencies(ctx.Config().BuildOSTarget.Variations(), tag, tool)
+
+			// Also depend on the linux_bionic variant of the tool if the build prefers them, it will be
+			// used instead of the BuildOSTarget variant if it is enabled.
+			if target, ok := ctx.Config().LinuxBionicHostToolTarget(); ok {
+				variations := target.Variations()
+				if ctx.OtherModuleFarDependencyVariantExists(variations, tool) {
+					bionicTag := hostToolDependencyTag{label: tag.label, linuxBionic: true}
+					ctx.AddFarVariationDependencies(variations, bionicTag, tool)
+				}
+			}
 		}
 	}
 }
@@ -304,9 +370,23 @@ func (g *Module) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	if len(g.properties.Tools) > 0 {
 		seenTools := make(map[string]bool)
 
+		// Find the tools whose linux_bionic variant will be used instead of the BuildOSTarget
+		// variant.
+		linuxBionicTools := make(map[string]bool)
+		ctx.VisitDirectDepsBlueprint(func(module blueprint.Module) {
+			if tag, ok := ctx.OtherModuleDependencyTag(module).(hostToolDependencyTag); ok && tag.linuxBionic {
+				if m, ok := module.(android.Module); ok && android.PrebuiltGetPreferred(ctx, m).Enabled() {
+					linuxBionicTools[tag.label] = true
+				}
+			}
+		})
+
 		ctx.VisitDirectDepsBlueprint(func(module blueprint.Module) {
 			switch tag := ctx.OtherModuleDependencyTag(module).(type) {
 			case hostToolDependencyTag:
+				if tag.linuxBionic != linuxBionicTools[tag.label] {
+					return
+				}
 				tool := ctx.OtherModuleName(module)
 				if m, ok := module.(android.Module); ok {
 					// Necessary to retrieve any prebuilt replacement for the tool, since
@@ -418,7 +498,7 @@ func (g *Module) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 	// Generate tasks, either from genrule or gensrcs.
 	for _, task := range g.taskGenerator(ctx, cmd, srcFiles) {
-		if len(task.out) == 0 {
+		if len(task.out) == 0 && len(task.outDirs) == 0 {
 			ctx.ModuleErrorf("must have at least one output file")
 			return
 		}
@@ -440,11 +520,17 @@ func (g *Module) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 		// Use a RuleBuilder to create a rule that runs the command inside an sbox sandbox.
 		rule := android.NewRuleBuilder(pctx, ctx).Sbox(task.genDir, manifestPath).SandboxTools()
+		if g.SandboxInputs {
+			rule.SandboxInputs()
+		}
 		cmd := rule.Command()
 
 		for _, out := range task.out {
 			addLocationLabel(out.Rel(), outputLocation{out})
 		}
+		for _, outDir := range task.outDirs {
+			addLocationLabel(outDir.Rel(), outputLocation{outDir})
+		}
 
 		referencedDepfile := false
 
@@ -512,9 +598,15 @@ func (g *Module) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 					} else {
 						return reportError("unknown locations label %q is not in srcs, out, tools or tool_files.", label)
 					}
-				} else {
-					return reportError("unknown variable '$(%s)'", name)
+				} else if g.ExtraVariables != nil {
+					value, ok, err := g.ExtraVariables(ctx, cmd, name)
+					if err != nil {
+						return reportError("%s", err.Error())
+					} else if ok {
+						return value, nil
+					}
 				}
+				return reportError("unknown variable '$(%s)'", name)
 			}
 		})
 
@@ -531,6 +623,11 @@ func (g *Module) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 		cmd.Text(rawCommand)
 		cmd.ImplicitOutputs(task.out)
+		for i, outDir := range task.outDirs {
+			cmd.ImplicitDirOutput(outDir, task.outDirFileLists[i])
+			g.outputDirs = append(g.outputDirs, outDir)
+			g.outputDirFileLists = append(g.outputDirFileLists, task.outDirFileLists[i])
+		}
 		cmd.Implicits(task.in)
 		cmd.ImplicitTools(tools)
 		cmd.ImplicitTools(task.extraTools)
@@ -576,6 +673,17 @@ func (g *Module) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 	g.outputFiles = outputFiles.Paths()
 
+	// Ninja cannot track the contents of the output directories, so make each of them a phony
+	// target that depends on the list of its files.  sbox only rewrites the list and the files that
+	// changed, which keeps restat working for the rules that use them.
+	for i, outputDir := range g.outputDirs {
+		ctx.Build(pctx, android.BuildParams{
+			Rule:   blueprint.Phony,
+			Output: outputDir,
+			Input:  g.outputDirFileLists[i],
+		})
+	}
+
 	bazelModuleLabel := g.GetBazelLabel(ctx, g)
 	bazelActionsUsed := false
 	if g.MixedBuildsEnabled(ctx) {
@@ -586,14 +694,16 @@ func (g *Module) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		// the genrules on AOSP. That will make things simpler to look at the graph in the common
 		// case. For larger sets of outputs, inject a phony target in between to limit ninja file
 		// growth.
-		if len(g.outputFiles) <= 6 {
-			g.outputDeps = g.outputFiles
+		outputDeps := append(android.Paths{}, g.outputFiles...)
+		outputDeps = append(outputDeps, g.outputDirFileLists.Paths()...)
+		if len(outputDeps) <= 6 {
+			g.outputDeps = outputDeps
 		} else {
 			phonyFile := android.PathForModuleGen(ctx, "genrule-phony")
 			ctx.Build(pctx, android.BuildParams{
 				Rule:   blueprint.Phony,
 				Output: phonyFile,
-				Inputs: g.outputFiles,
+				Inputs: outputDeps,
 			})
 			g.outputDeps = android.Paths{phonyFile}
 		}
@@ -613,9 +723,12 @@ func (g *Module) IDEInfo(dpInfo *android.IdeInfo) {
 }
 
 func (g *Module) AndroidMk() android.AndroidMkData {
+	// A genrule that only generates directories is represented by the list of the files in the
+	// first one.
+	outputFile := append(append(android.Paths{}, g.outputFiles...), g.outputDirFileLists.Paths()...)[0]
 	return android.AndroidMkData{
 		Class:      "ETC",
-		OutputFile: android.OptionalPathForPath(g.outputFiles[0]),
+		OutputFile: android.OptionalPathForPath(outputFile),
 		SubName:    g.subName,
 		Extra: []android.AndroidMkExtraFunc{
 			func(w io.Writer, outputFile android.Path) {
@@ -813,12 +926,32 @@ func NewGenRule() *Module {
 			}
 			outs[i] = outPath
 		}
+
+		var outDirs, outDirFileLists android.WritablePaths
+		for i, outDir := range properties.Out_dirs {
+			if err := checkOutDir(outDir, properties.Out, properties.Out_dirs[:i]); err != nil {
+				ctx.PropertyErrorf("out_dirs", "%s", err)
+				continue
+			}
+			outDirs = append(outDirs, android.PathForModuleGen(ctx, outDir))
+			// The file list must be inside the sandbox output directory but outside outDir.
+			outDirFileLists = append(outDirFileLists, android.PathForModuleGen(ctx, outDir+".files"))
+		}
+		// A depfile: true genrule without out files still needs a path for $(depfile).  Put it next
+		// to the file list of the first directory, where it isn't copied out as one of the files
+		// of the directory.
+		if depFile == nil && len(outDirs) > 0 {
+			depFile = android.PathForModuleGen(ctx, properties.Out_dirs[0]+".d")
+		}
+
 		return []generateTask{{
-			in:      srcFiles,
-			out:     outs,
-			depFile: depFile,
-			genDir:  android.PathForModuleGen(ctx),
-			cmd:     rawCommand,
+			in:              srcFiles,
+			out:             outs,
+			outDirs:         outDirs,
+			outDirFileLists: outDirFileLists,
+			depFile:         depFile,
+			genDir:          android.PathForModuleGen(ctx),
+			cmd:             rawCommand,
 		}}
 	}
 
@@ -833,9 +966,38 @@ func GenRuleFactory() android.Module {
 	return m
 }
 
+// checkOutDir returns an error if an out_dirs entry would contain, or be contained in, one of the
+// out files or a previous out_dirs entry, as sbox could not copy both out of the sandbox.
+func checkOutDir(outDir string, outs, prevOutDirs []string) error {
+	if outDir == "" || outDir == "." || filepath.IsAbs(outDir) || filepath.Clean(outDir) != outDir {
+		return fmt.Errorf("%q must be a clean relative path", outDir)
+	}
+	overlaps := func(a, b string) bool {
+		return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+	}
+	for _, out := range outs {
+		if overlaps(out, outDir) {
+			return fmt.Errorf("%q overlaps the out entry %q", outDir, out)
+		}
+	}
+	for _, prev := range prevOutDirs {
+		if overlaps(prev, outDir) {
+			return fmt.Errorf("%q overlaps the out_dirs entry %q", outDir, prev)
+		}
+	}
+	return nil
+}
+
 type genRuleProperties struct {
 	// names of the output files that will be generated
 	Out []string `android:"arch_variant"`
+
+	// names of the output directories that will be generated, for tools that write a set of files
+	// that cannot be listed in out.  The command must write each directory to $(genDir)/<dir>, and
+	// its whole contents are copied out of the sandbox after the command has run.  Other modules can
+	// reference a directory as ":<module>{<dir>}", and cc modules that list the genrule in
+	// generated_sources compile the C and C++ files in the directories.
+	Out_dirs []string `android:"arch_variant"`
 }
 
 type bazelGenruleAttributes struct {
@@ -890,6 +1052,10 @@ func (m *Module) ConvertWithBp2build(ctx android.TopDownMutatorContext) {
 	for _, propIntf := range m.GetProperties() {
 		if props, ok := propIntf.(*genRuleProperties); ok {
 			outs = props.Out
+			// Bazel genrules cannot declare output directories, so leave the genrule unconverted.
+			if len(props.Out_dirs) > 0 {
+				return
+			}
 			break
 		}
 	}
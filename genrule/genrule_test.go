@@ -17,6 +17,7 @@ package genrule
 import (
 	"os"
 	"regexp"
+	"strings"
 	"testing"
 
 	"android/soong/android"
@@ -411,6 +412,40 @@ func TestGenruleCmd(t *testing.T) {
 			`,
 			err: "must have at least one output file",
 		},
+		{
+			name: "out_dirs genDir",
+			prop: `
+				out_dirs: ["dir"],
+				cmd: "mkdir -p $(genDir)/dir/sub && touch $(genDir)/dir/sub/a",
+			`,
+			expect: "mkdir -p __SBOX_SANDBOX_DIR__/out/dir/sub && touch __SBOX_SANDBOX_DIR__/out/dir/sub/a",
+		},
+		{
+			name: "out_dirs location",
+			prop: `
+				out: ["out"],
+				out_dirs: ["dir"],
+				cmd: "touch $(location dir)/a $(out)",
+			`,
+			expect: "touch __SBOX_SANDBOX_DIR__/out/dir/a __SBOX_SANDBOX_DIR__/out/out",
+		},
+		{
+			name: "error out_dirs overlaps out",
+			prop: `
+				out: ["dir/out"],
+				out_dirs: ["dir"],
+				cmd: "echo foo > $(out)",
+			`,
+			err: `"dir" overlaps the out entry "dir/out"`,
+		},
+		{
+			name: "error out_dirs not clean",
+			prop: `
+				out_dirs: ["../dir"],
+				cmd: "touch $(genDir)/../dir/a",
+			`,
+			err: `"../dir" must be a clean relative path`,
+		},
 		{
 			name: "srcs allow missing dependencies",
 			prop: `
@@ -722,6 +757,61 @@ func TestGenruleOutputFiles(t *testing.T) {
 		result.ModuleForTests("gen_all", "").Module().(*useSource).srcs)
 }
 
+func TestGenruleOutDirs(t *testing.T) {
+	bp := `
+				genrule {
+					name: "gen",
+					out: ["foo"],
+					out_dirs: ["dir"],
+					cmd: "mkdir -p $(genDir)/dir/sub && echo a > $(genDir)/dir/sub/a && echo foo > $(out)",
+				}
+				use_source {
+					name: "gen_dir",
+					srcs: [":gen{dir}"],
+				}
+				use_source {
+					name: "gen_all",
+					srcs: [":gen"],
+				}
+			`
+
+	result := prepareForGenRuleTest.RunTestWithBp(t, testGenruleBp()+bp)
+	outDir := "out/soong/.intermediates/gen/gen"
+	gen := result.ModuleForTests("gen", "")
+
+	manifest := android.RuleBuilderSboxProtoForTests(t, gen.Output("genrule.sbox.textproto"))
+	command := manifest.Commands[0]
+	android.AssertIntEquals(t, "len(OutputDirs)", 1, len(command.OutputDirs))
+	android.AssertStringEquals(t, "OutputDir.From", "out/dir", command.OutputDirs[0].GetFrom())
+	android.AssertStringEquals(t, "OutputDir.To", outDir+"/dir",
+		android.StringPathRelativeToTop(result.Config.SoongOutDir(), command.OutputDirs[0].GetTo()))
+	android.AssertStringEquals(t, "OutputDir.FileList", outDir+"/dir.files",
+		android.StringPathRelativeToTop(result.Config.SoongOutDir(), command.OutputDirs[0].GetFileList()))
+
+	// The generator rule outputs the out files and the file list of the directory.
+	generator := gen.Output("dir.files")
+	android.AssertPathsRelativeToTopEquals(t, "generator outputs",
+		[]string{outDir + "/dir.files", outDir + "/foo"},
+		append(android.Paths{generator.Output}, generator.ImplicitOutputs.Paths()...))
+
+	// The directory is a phony target that depends on the file list.
+	phony := gen.Output("dir")
+	android.AssertPathsRelativeToTopEquals(t, "dir phony inputs", []string{outDir + "/dir.files"}, phony.Inputs)
+
+	module := gen.Module().(*Module)
+	android.AssertPathsRelativeToTopEquals(t, "GeneratedDeps",
+		[]string{outDir + "/foo", outDir + "/dir.files"}, module.GeneratedDeps())
+
+	android.AssertPathsRelativeToTopEquals(t,
+		"genrule.tag with output directory",
+		[]string{outDir + "/dir"},
+		result.ModuleForTests("gen_dir", "").Module().(*useSource).srcs)
+	android.AssertPathsRelativeToTopEquals(t,
+		"genrule.tag with all",
+		[]string{outDir + "/foo"},
+		result.ModuleForTests("gen_all", "").Module().(*useSource).srcs)
+}
+
 func TestPrebuiltTool(t *testing.T) {
 	testcases := []struct {
 		name             string
@@ -785,6 +875,75 @@ func TestPrebuiltTool(t *testing.T) {
 	}
 }
 
+func TestLinuxBionicHostTool(t *testing.T) {
+	testcases := []struct {
+		name         string
+		bp           string
+		bionicTools  bool
+		expectedTool string
+	}{
+		{
+			name: "glibc",
+			bp: `
+				tool { name: "tool" }
+			`,
+			bionicTools:  false,
+			expectedTool: "host/linux-x86/bin/tool",
+		},
+		{
+			name: "bionic",
+			bp: `
+				tool { name: "tool" }
+			`,
+			bionicTools:  true,
+			expectedTool: "host/linux_bionic-x86/bin/tool",
+		},
+		{
+			name: "bionic disabled",
+			bp: `
+				tool {
+					name: "tool",
+					target: {
+						linux_bionic: {
+							enabled: false,
+						},
+					},
+				}
+			`,
+			bionicTools:  true,
+			expectedTool: "host/linux-x86/bin/tool",
+		},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			result := android.GroupFixturePreparers(
+				prepareForGenRuleTest,
+				android.FixtureModifyConfig(func(config android.Config) {
+					config.Targets[android.LinuxBionic] = []android.Target{
+						{Os: android.LinuxBionic, Arch: android.Arch{ArchType: android.X86_64}},
+					}
+					config.TestProductVariables.HostBionicTools = proptools.BoolPtr(test.bionicTools)
+				}),
+			).RunTestWithBp(t, test.bp+`
+				genrule {
+					name: "gen",
+					tools: ["tool"],
+					out: ["foo"],
+					cmd: "$(location tool)",
+				}
+			`)
+			gen := result.ModuleForTests("gen", "")
+			manifest := android.RuleBuilderSboxProtoForTests(t, gen.Output("genrule.sbox.textproto"))
+			var tools []string
+			for _, copy := range manifest.Commands[0].GetCopyBefore() {
+				tools = append(tools, copy.GetFrom())
+			}
+			android.AssertStringDoesContain(t, "tools", strings.Join(tools, " "), test.expectedTool)
+		})
+	}
+}
+
 func TestGenruleWithBazel(t *testing.T) {
 	bp := `
 		genrule {
@@ -34,6 +34,8 @@ type AndroidLibraryDependency interface {
 	ExportedStaticPackages() android.Paths
 	ExportedManifests() android.Paths
 	ExportedAssets() android.OptionalPath
+	RTxt() android.Path
+	ExportedStaticRTxts() android.Paths
 	SetRROEnforcedForDependent(enforce bool)
 	IsRROEnforced(ctx android.BaseModuleContext) bool
 }
@@ -45,6 +47,7 @@ func init() {
 func RegisterAARBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("android_library_import", AARImportFactory)
 	ctx.RegisterModuleType("android_library", AndroidLibraryFactory)
+	ctx.RegisterSingletonType("android_library_aars", androidLibraryAARsSingletonFactory)
 	ctx.PostDepsMutators(func(ctx android.RegisterMutatorsContext) {
 		ctx.TopDown("propagate_rro_enforcement", propagateRROEnforcementMutator).Parallel()
 	})
@@ -71,6 +74,12 @@ type aaptProperties struct {
 	// to disable the default.
 	Asset_dirs []string
 
+	// list of files or modules producing files to package into the assets of the apk.  The
+	// files keep their path relative to the module directory, or to the output directory of
+	// the module that produced them, so a genrule output "models/a.tflite" is packaged as
+	// "assets/models/a.tflite".
+	Asset_srcs []string `android:"path"`
+
 	// list of directories relative to the Blueprints file containing
 	// Android resources.  Defaults to ["res"] if a directory called res exists.
 	// Set to [] to disable the default.
@@ -88,6 +97,12 @@ type aaptProperties struct {
 	// do not include AndroidManifest from dependent libraries
 	Dont_merge_manifests *bool
 
+	// list of android libraries in static_libs in increasing order of priority.  Their resources
+	// are merged after the resources of the other static_libs, so a resource defined by a library
+	// in this list overrides the resources of the same name in the libraries before it and in the
+	// unlisted static_libs.  It is an error for unlisted static_libs to define the same resource.
+	Resource_merge_order []string
+
 	// true if RRO is enforced for any of the dependent modules
 	RROEnforcedForDependent bool `blueprint:"mutated"`
 }
@@ -111,6 +126,18 @@ type aapt struct {
 	hasNoCode               bool
 	LoggingParent           string
 	resourceFiles           android.Paths
+	resourceDirs            []globbedResourceDir
+
+	// The targetSdkVersion that the product configuration sets for the module, if any.
+	TargetSdkVersionOverride string
+
+	// The inputs of the aapt2 link of the resources, used by robolectric tests to link the
+	// resources of the instrumented app together with those of their static_libs.
+	linkFlags       []string
+	linkDeps        android.Paths
+	compiledRes     android.Paths
+	compiledOverlay android.Paths
+	assetPackages   android.Paths
 
 	splitNames []string
 	splits     []split
@@ -152,6 +179,10 @@ func (a *aapt) ExportedAssets() android.OptionalPath {
 	return a.assetPackage
 }
 
+func (a *aapt) RTxt() android.Path {
+	return a.rTxt
+}
+
 func (a *aapt) SetRROEnforcedForDependent(enforce bool) {
 	a.aaptProperties.RROEnforcedForDependent = enforce
 }
@@ -163,15 +194,118 @@ func (a *aapt) IsRROEnforced(ctx android.BaseModuleContext) bool {
 		a.aaptProperties.RROEnforcedForDependent
 }
 
+// aaptSingleValueFlags are the aapt2 link flags that can only take one value, e.g. an app has a
+// single package id, so that different values given by aaptflags and the product conflict.
+var aaptSingleValueFlags = []string{
+	"--package-id",
+	"--rename-manifest-package",
+	"--rename-resources-package",
+	"--rename-instrumentation-target-package",
+	"--version-code",
+	"--version-name",
+}
+
+// aaptFlag is an aapt2 link flag with its values.
+type aaptFlag struct {
+	name   string
+	values []string
+}
+
+func (f aaptFlag) String() string {
+	return strings.Join(append([]string{f.name}, f.values...), " ")
+}
+
+// parseAaptFlags splits the aapt2 flags into flags with their values, which are given either in
+// the same element, e.g. "--package-id 0x7f", or in the next ones.
+func parseAaptFlags(flags []string) []aaptFlag {
+	var parsed []aaptFlag
+	for _, token := range strings.Fields(strings.Join(flags, " ")) {
+		if strings.HasPrefix(token, "-") || len(parsed) == 0 {
+			parsed = append(parsed, aaptFlag{name: token})
+		} else {
+			parsed[len(parsed)-1].values = append(parsed[len(parsed)-1].values, token)
+		}
+	}
+	return parsed
+}
+
+// aaptFlagValues returns the values of the flag with the given name in the parsed aapt2 flags.
+func aaptFlagValues(flags []aaptFlag, name string) []string {
+	var values []string
+	for _, flag := range flags {
+		if flag.name == name {
+			values = append(values, flag.values...)
+		}
+	}
+	return values
+}
+
+// withoutAaptFlags returns the parsed aapt2 flags without the flags named like one of the given
+// flags, along with their values.
+func withoutAaptFlags(flags []aaptFlag, remove []aaptFlag) []aaptFlag {
+	var ret []aaptFlag
+	for _, flag := range flags {
+		removed := false
+		for _, r := range remove {
+			if flag.name == r.name {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			ret = append(ret, flag)
+		}
+	}
+	return ret
+}
+
+// mergeProductAaptFlags returns the aaptflags of an app preceded by the aapt2 link flags that the
+// product adds to the apps in its directory. The flags of an entry for a subdirectory replace the
+// flags with the same name of the entries for its parents. The aaptflags take precedence over the
+// product flags with the same name, but flags that can only take one value are reported when the
+// aaptflags and the product give them different values.
+func mergeProductAaptFlags(ctx android.ModuleContext, aaptflags []string) []string {
+	entries := ctx.Config().AaptFlagsForPath(ctx.ModuleDir())
+	if len(entries) == 0 {
+		return aaptflags
+	}
+
+	var productFlags []aaptFlag
+	for _, entry := range entries {
+		entryFlags := parseAaptFlags(entry)
+		productFlags = append(withoutAaptFlags(productFlags, entryFlags), entryFlags...)
+	}
+
+	moduleFlags := parseAaptFlags(aaptflags)
+	for _, name := range aaptSingleValueFlags {
+		values := append(aaptFlagValues(moduleFlags, name), aaptFlagValues(productFlags, name)...)
+		if values = android.FirstUniqueStrings(values); len(values) > 1 {
+			ctx.ModuleErrorf("conflicting values %q for aapt2 flag %s from aaptflags and the "+
+				"product aapt flags for %q", values, name, ctx.ModuleDir())
+		}
+	}
+
+	var flags []string
+	for _, flag := range withoutAaptFlags(productFlags, moduleFlags) {
+		flags = append(flags, flag.String())
+	}
+	return append(flags, aaptflags...)
+}
+
 func (a *aapt) aapt2Flags(ctx android.ModuleContext, sdkContext android.SdkContext,
 	manifestPath android.Path) (compileFlags, linkFlags []string, linkDeps android.Paths,
 	resDirs, overlayDirs []globbedResourceDir, rroDirs []rroDir, resZips android.Paths) {
 
-	hasVersionCode := android.PrefixInList(a.aaptProperties.Aaptflags, "--version-code")
-	hasVersionName := android.PrefixInList(a.aaptProperties.Aaptflags, "--version-name")
+	aaptflags := a.aaptProperties.Aaptflags
+	if !a.isLibrary {
+		aaptflags = mergeProductAaptFlags(ctx, aaptflags)
+	}
+
+	hasVersionCode := android.PrefixInList(aaptflags, "--version-code")
+	hasVersionName := android.PrefixInList(aaptflags, "--version-name")
 
-	// Flags specified in Android.bp
-	linkFlags = append(linkFlags, a.aaptProperties.Aaptflags...)
+	// Flags specified in Android.bp, and by the product for the directory of the module
+	linkFlags = append(linkFlags, aaptflags...)
 
 	linkFlags = append(linkFlags, "--no-static-lib-packages")
 
@@ -271,11 +405,16 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext android.SdkCon
 	extraLinkFlags ...string) {
 
 	transitiveStaticLibs, transitiveStaticLibManifests, staticRRODirs, assetPackages, libDeps, libFlags :=
-		aaptLibs(ctx, sdkContext, classLoaderContexts)
+		aaptLibs(ctx, sdkContext, classLoaderContexts, a.aaptProperties.Resource_merge_order)
 
 	// Exclude any libraries from the supplied list.
 	classLoaderContexts = classLoaderContexts.ExcludeLibs(excludedLibs)
 
+	// The assets from asset_srcs take priority over the assets of the dependencies.
+	if assetSrcs := a.assetSrcsZip(ctx); assetSrcs != nil {
+		assetPackages = append(android.Paths{assetSrcs}, assetPackages...)
+	}
+
 	// App manifest file
 	manifestFile := proptools.StringDefault(a.aaptProperties.Manifest, "AndroidManifest.xml")
 	manifestSrcPath := android.PathForModuleSrc(ctx, manifestFile)
@@ -289,6 +428,8 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext android.SdkCon
 		UseEmbeddedDex:        a.useEmbeddedDex,
 		HasNoCode:             a.hasNoCode,
 		LoggingParent:         a.LoggingParent,
+
+		TargetSdkVersionOverride: a.TargetSdkVersionOverride,
 	})
 
 	// Add additional manifest files to transitive manifests.
@@ -313,6 +454,9 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext android.SdkCon
 	rroDirs = append(rroDirs, staticRRODirs...)
 	linkFlags = append(linkFlags, libFlags...)
 	linkDeps = append(linkDeps, libDeps...)
+	if conflictsCheck := resourceConflictsCheck(ctx, a.aaptProperties.Resource_merge_order); conflictsCheck != nil {
+		linkDeps = append(linkDeps, conflictsCheck)
+	}
 	linkFlags = append(linkFlags, extraLinkFlags...)
 	if a.isLibrary {
 		linkFlags = append(linkFlags, "--static-lib")
@@ -326,6 +470,7 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext android.SdkCon
 	// This file isn't used by Soong, but is generated for exporting
 	extraPackages := android.PathForModuleOut(ctx, "extra_packages")
 
+	a.resourceDirs = resDirs
 	var compiledResDirs []android.Paths
 	for _, dir := range resDirs {
 		a.resourceFiles = append(a.resourceFiles, dir.files...)
@@ -366,6 +511,12 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext android.SdkCon
 		compiledOverlay = append(compiledOverlay, aapt2Compile(ctx, dir.dir, dir.files, compileFlags).Paths()...)
 	}
 
+	a.linkFlags = android.CopyOf(linkFlags)
+	a.linkDeps = linkDeps
+	a.compiledRes = compiledRes
+	a.compiledOverlay = compiledOverlay
+	a.assetPackages = assetPackages
+
 	var splitPackages android.WritablePaths
 	var splits []split
 
@@ -407,11 +558,41 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext android.SdkCon
 	a.splits = splits
 }
 
-// aaptLibs collects libraries from dependencies and sdk_version and converts them into paths
-func aaptLibs(ctx android.ModuleContext, sdkContext android.SdkContext, classLoaderContexts dexpreopt.ClassLoaderContextMap) (
+// assetSrcsZip packages the files listed in asset_srcs into a zip under assets/ that can be merged
+// into the resource package, or returns nil if asset_srcs is empty.
+func (a *aapt) assetSrcsZip(ctx android.ModuleContext) android.Path {
+	assetSrcs := android.PathsForModuleSrc(ctx, a.aaptProperties.Asset_srcs)
+	if len(assetSrcs) == 0 {
+		return nil
+	}
+
+	assetsZip := android.PathForModuleOut(ctx, "asset_srcs.zip")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	cmd := rule.Command().
+		BuiltTool("soong_zip").
+		FlagWithOutput("-o ", assetsZip).
+		FlagWithArg("-P ", "assets")
+	for _, src := range assetSrcs {
+		root := strings.TrimSuffix(src.String(), src.Rel())
+		if root == "" {
+			root = "."
+		}
+		cmd.FlagWithArg("-C ", root).FlagWithInput("-f ", src)
+	}
+	rule.Build("asset_srcs_zip", "zip asset_srcs")
+
+	return assetsZip
+}
+
+// aaptLibs collects libraries from dependencies and sdk_version and converts them into paths.
+// The resources of the static libraries listed in resourceMergeOrder are ordered after the
+// resources of the other static libraries, in the order of the list.
+func aaptLibs(ctx android.ModuleContext, sdkContext android.SdkContext, classLoaderContexts dexpreopt.ClassLoaderContextMap,
+	resourceMergeOrder []string) (
 	transitiveStaticLibs, transitiveStaticLibManifests android.Paths, staticRRODirs []rroDir, assets, deps android.Paths, flags []string) {
 
 	var sharedLibs android.Paths
+	orderedStaticLibs := make(map[string]android.Paths)
 
 	if classLoaderContexts == nil {
 		// Not all callers need to compute class loader context, those who don't just pass nil.
@@ -446,8 +627,13 @@ func aaptLibs(ctx android.ModuleContext, sdkContext android.SdkContext, classLoa
 			}
 		case staticLibTag:
 			if exportPackage != nil {
-				transitiveStaticLibs = append(transitiveStaticLibs, aarDep.ExportedStaticPackages()...)
-				transitiveStaticLibs = append(transitiveStaticLibs, exportPackage)
+				staticLibs := append(android.Paths(nil), aarDep.ExportedStaticPackages()...)
+				staticLibs = append(staticLibs, exportPackage)
+				if name := ctx.OtherModuleName(module); android.InList(name, resourceMergeOrder) {
+					orderedStaticLibs[name] = staticLibs
+				} else {
+					transitiveStaticLibs = append(transitiveStaticLibs, staticLibs...)
+				}
 				transitiveStaticLibManifests = append(transitiveStaticLibManifests, aarDep.ExportedManifests()...)
 				if aarDep.ExportedAssets().Valid() {
 					assets = append(assets, aarDep.ExportedAssets().Path())
@@ -468,6 +654,15 @@ func aaptLibs(ctx android.ModuleContext, sdkContext android.SdkContext, classLoa
 		addCLCFromDep(ctx, module, classLoaderContexts)
 	})
 
+	for _, name := range resourceMergeOrder {
+		staticLibs, ok := orderedStaticLibs[name]
+		if !ok {
+			ctx.PropertyErrorf("resource_merge_order", "%q is not an android library in static_libs", name)
+			continue
+		}
+		transitiveStaticLibs = append(transitiveStaticLibs, staticLibs...)
+	}
+
 	deps = append(deps, sharedLibs...)
 	deps = append(deps, transitiveStaticLibs...)
 
@@ -485,6 +680,39 @@ func aaptLibs(ctx android.ModuleContext, sdkContext android.SdkContext, classLoa
 	return transitiveStaticLibs, transitiveStaticLibManifests, staticRRODirs, assets, deps, flags
 }
 
+// resourceConflictsCheck creates a rule that checks that the static libraries that are not listed
+// in resourceMergeOrder do not define the same resources, since the resources that end up in the
+// module would then depend on the order of the static libraries.  It returns the timestamp file
+// of the check, or nil if there is nothing to check.
+func resourceConflictsCheck(ctx android.ModuleContext, resourceMergeOrder []string) android.Path {
+	type staticLib struct {
+		name     string
+		rTxt     android.Path
+		depRTxts android.Paths
+	}
+	var staticLibs []staticLib
+	ctx.VisitDirectDepsWithTag(staticLibTag, func(module android.Module) {
+		name := ctx.OtherModuleName(module)
+		if aarDep, ok := module.(AndroidLibraryDependency); ok && aarDep.RTxt() != nil &&
+			!android.InList(name, resourceMergeOrder) {
+			staticLibs = append(staticLibs, staticLib{name, aarDep.RTxt(), aarDep.ExportedStaticRTxts()})
+		}
+	})
+	if len(staticLibs) < 2 {
+		return nil
+	}
+
+	timestamp := android.PathForModuleOut(ctx, "resource_conflicts.timestamp")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	cmd := rule.Command().BuiltTool("check_resource_conflicts")
+	for _, lib := range staticLibs {
+		cmd.Flag("--lib").Text(lib.name).Input(lib.rTxt).Inputs(lib.depRTxts)
+	}
+	cmd.FlagWithOutput("--output ", timestamp)
+	rule.Build("resource_conflicts", "check resource conflicts")
+	return timestamp
+}
+
 type AndroidLibrary struct {
 	Library
 	aapt
@@ -495,6 +723,7 @@ type AndroidLibrary struct {
 
 	exportedProguardFlagFiles android.Paths
 	exportedStaticPackages    android.Paths
+	exportedStaticRTxts       android.Paths
 }
 
 var _ android.OutputFileProducer = (*AndroidLibrary)(nil)
@@ -517,6 +746,10 @@ func (a *AndroidLibrary) ExportedStaticPackages() android.Paths {
 	return a.exportedStaticPackages
 }
 
+func (a *AndroidLibrary) ExportedStaticRTxts() android.Paths {
+	return a.exportedStaticRTxts
+}
+
 var _ AndroidLibraryDependency = (*AndroidLibrary)(nil)
 
 func (a *AndroidLibrary) DepsMutator(ctx android.BottomUpMutatorContext) {
@@ -551,13 +784,6 @@ func (a *AndroidLibrary) GenerateAndroidBuildActions(ctx android.ModuleContext)
 
 	a.Module.compile(ctx, a.aaptSrcJar)
 
-	a.aarFile = android.PathForModuleOut(ctx, ctx.ModuleName()+".aar")
-	var res android.Paths
-	if a.androidLibraryProperties.BuildAAR {
-		BuildAAR(ctx, a.aarFile, a.outputFile, a.manifestPath, a.rTxt, res)
-		ctx.CheckbuildFile(a.aarFile)
-	}
-
 	a.exportedProguardFlagFiles = append(a.exportedProguardFlagFiles,
 		android.PathsForModuleSrc(ctx, a.dexProperties.Optimize.Proguard_flags_files)...)
 	ctx.VisitDirectDeps(func(m android.Module) {
@@ -565,13 +791,57 @@ func (a *AndroidLibrary) GenerateAndroidBuildActions(ctx android.ModuleContext)
 			a.exportedProguardFlagFiles = append(a.exportedProguardFlagFiles, lib.ExportedProguardFlagFiles()...)
 			a.exportedStaticPackages = append(a.exportedStaticPackages, lib.ExportPackage())
 			a.exportedStaticPackages = append(a.exportedStaticPackages, lib.ExportedStaticPackages()...)
+			if lib.RTxt() != nil {
+				a.exportedStaticRTxts = append(a.exportedStaticRTxts, lib.RTxt())
+			}
+			a.exportedStaticRTxts = append(a.exportedStaticRTxts, lib.ExportedStaticRTxts()...)
 		}
 	})
 
 	a.exportedProguardFlagFiles = android.FirstUniquePaths(a.exportedProguardFlagFiles)
 	a.exportedStaticPackages = android.FirstUniquePaths(a.exportedStaticPackages)
+	a.exportedStaticRTxts = android.FirstUniquePaths(a.exportedStaticRTxts)
+
+	a.aarFile = android.PathForModuleOut(ctx, ctx.ModuleName()+".aar")
+	if a.androidLibraryProperties.BuildAAR {
+		BuildAAR(ctx, a.aarFile, a.outputFile, a.manifestPath, a.rTxt, a.resourceDirs,
+			a.exportedProguardFlagFiles)
+		ctx.CheckbuildFile(a.aarFile)
+	}
+}
+
+func androidLibraryAARsSingletonFactory() android.Singleton {
+	return &androidLibraryAARsSingleton{}
+}
+
+type androidLibraryAARsSingleton struct {
+	aars android.Paths
+}
+
+// GenerateBuildActions collects the .aar files of the android_library modules into the
+// android_library_aars goal, which dists them for use by projects built outside of the platform,
+// e.g. with Gradle.
+func (s *androidLibraryAARsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	ctx.VisitAllModules(func(module android.Module) {
+		lib, ok := module.(*AndroidLibrary)
+		if !ok || !lib.Enabled() || lib.hideApexVariantFromMake || !lib.androidLibraryProperties.BuildAAR {
+			return
+		}
+		s.aars = append(s.aars, lib.aarFile)
+	})
+	if len(s.aars) > 0 {
+		ctx.Phony("android_library_aars", s.aars...)
+	}
+}
+
+func (s *androidLibraryAARsSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if len(s.aars) > 0 {
+		ctx.DistForGoal("android_library_aars", s.aars...)
+	}
 }
 
+var _ android.SingletonMakeVarsProvider = (*androidLibraryAARsSingleton)(nil)
+
 // android_library builds and links sources into a `.jar` file for the device along with Android resources.
 //
 // An android_library has a single variant that produces a `.jar` file containing `.class` files that were
@@ -638,6 +908,7 @@ type AARImport struct {
 	extraAaptPackagesFile android.WritablePath
 	manifest              android.WritablePath
 	assetsPackage         android.WritablePath
+	rTxt                  android.WritablePath
 
 	exportedStaticPackages android.Paths
 
@@ -712,6 +983,14 @@ func (a *AARImport) ExportedAssets() android.OptionalPath {
 	return android.OptionalPathForPath(a.assetsPackage)
 }
 
+func (a *AARImport) RTxt() android.Path {
+	return a.rTxt
+}
+
+func (a *AARImport) ExportedStaticRTxts() android.Paths {
+	return nil
+}
+
 // RRO enforcement is not available on aar_import since its RRO dirs are not
 // exported.
 func (a *AARImport) SetRROEnforcedForDependent(enforce bool) {
@@ -808,7 +1087,7 @@ func (a *AARImport) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	// the subdir "android" is required to be filtered by package names
 	srcJar := android.PathForModuleGen(ctx, "android", "R.srcjar")
 	proguardOptionsFile := android.PathForModuleGen(ctx, "proguard.options")
-	rTxt := android.PathForModuleOut(ctx, "R.txt")
+	a.rTxt = android.PathForModuleOut(ctx, "R.txt")
 	a.extraAaptPackagesFile = android.PathForModuleOut(ctx, "extra_packages")
 
 	var linkDeps android.Paths
@@ -823,7 +1102,7 @@ func (a *AARImport) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	linkDeps = append(linkDeps, a.manifest)
 
 	transitiveStaticLibs, staticLibManifests, staticRRODirs, transitiveAssets, libDeps, libFlags :=
-		aaptLibs(ctx, android.SdkContext(a), nil)
+		aaptLibs(ctx, android.SdkContext(a), nil, nil)
 
 	_ = staticLibManifests
 	_ = staticRRODirs
@@ -833,7 +1112,7 @@ func (a *AARImport) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 	overlayRes := append(android.Paths{flata}, transitiveStaticLibs...)
 
-	aapt2Link(ctx, a.exportPackage, srcJar, proguardOptionsFile, rTxt, a.extraAaptPackagesFile,
+	aapt2Link(ctx, a.exportPackage, srcJar, proguardOptionsFile, a.rTxt, a.extraAaptPackagesFile,
 		linkFlags, linkDeps, nil, overlayRes, transitiveAssets, nil)
 
 	// Merge this import's assets with its dependencies' assets (if there are any).
@@ -849,10 +1128,19 @@ func (a *AARImport) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		a.assetsPackage = mergedAssets
 	}
 
+	exportedDisableTurbine := false
+	ctx.VisitDirectDepsWithTag(staticLibTag, func(module android.Module) {
+		if ctx.OtherModuleHasProvider(module, JavaInfoProvider) {
+			dep := ctx.OtherModuleProvider(module, JavaInfoProvider).(JavaInfo)
+			exportedDisableTurbine = exportedDisableTurbine || dep.ExportedPluginDisableTurbine
+		}
+	})
+
 	ctx.SetProvider(JavaInfoProvider, JavaInfo{
 		HeaderJars:                     android.PathsIfNonNil(a.classpathFile),
 		ImplementationAndResourcesJars: android.PathsIfNonNil(a.classpathFile),
 		ImplementationJars:             android.PathsIfNonNil(a.classpathFile),
+		ExportedPluginDisableTurbine:   exportedDisableTurbine,
 	})
 }
 
@@ -43,8 +43,7 @@ var manifestMergerRule = pctx.AndroidStaticRule("manifestMerger",
 // targetSdkVersion for manifest_fixer
 // When TARGET_BUILD_APPS is not empty, this method returns 10000 for modules targeting an unreleased SDK
 // This enables release builds (that run with TARGET_BUILD_APPS=[val...]) to target APIs that have not yet been finalized as part of an SDK
-func targetSdkVersionForManifestFixer(ctx android.ModuleContext, sdkContext android.SdkContext) string {
-	targetSdkVersionSpec := sdkContext.TargetSdkVersion(ctx)
+func targetSdkVersionForManifestFixer(ctx android.ModuleContext, targetSdkVersionSpec android.SdkSpec) string {
 	if ctx.Config().UnbundledBuildApps() && targetSdkVersionSpec.ApiLevel.IsPreview() {
 		return strconv.Itoa(android.FutureApiLevel.FinalOrFutureInt())
 	}
@@ -65,6 +64,9 @@ type ManifestFixerParams struct {
 	HasNoCode             bool
 	TestOnly              bool
 	LoggingParent         string
+
+	// Replaces the targetSdkVersion of the SdkContext when set.
+	TargetSdkVersionOverride string
 }
 
 // Uses manifest_fixer.py to inject minSdkVersion, etc. into an AndroidManifest.xml
@@ -123,7 +125,11 @@ func ManifestFixer(ctx android.ModuleContext, manifest android.Path,
 	var argsMapper = make(map[string]string)
 
 	if params.SdkContext != nil {
-		targetSdkVersion := targetSdkVersionForManifestFixer(ctx, params.SdkContext)
+		targetSdkVersionSpec := params.SdkContext.TargetSdkVersion(ctx)
+		if params.TargetSdkVersionOverride != "" {
+			targetSdkVersionSpec = android.SdkSpecFrom(ctx, params.TargetSdkVersionOverride)
+		}
+		targetSdkVersion := targetSdkVersionForManifestFixer(ctx, targetSdkVersionSpec)
 		args = append(args, "--targetSdkVersion ", targetSdkVersion)
 
 		if UseApiFingerprint(ctx) && ctx.ModuleName() != "framework-res" {
@@ -28,11 +28,11 @@ func (library *Library) AndroidMkEntriesHostDex() android.AndroidMkEntries {
 	}
 
 	if hostDexNeeded {
-		var output android.Path
-		if library.dexJarFile.IsSet() {
-			output = library.dexJarFile.Path()
-		} else {
-			output = library.implementationAndResourcesJar
+		output := library.hostdexOutputJar()
+		if library.hostdexTestSuiteJar != nil {
+			// The copy that Make installs into the test suites references its runtime
+			// dependencies, which are installed next to it, in its manifest.
+			output = library.hostdexTestSuiteJar
 		}
 		return android.AndroidMkEntries{
 			Class:      "JAVA_LIBRARIES",
@@ -51,6 +51,10 @@ func (library *Library) AndroidMkEntriesHostDex() android.AndroidMkEntries {
 					entries.SetPath("LOCAL_SOONG_HEADER_JAR", library.headerJarFile)
 					entries.SetPath("LOCAL_SOONG_CLASSES_JAR", library.implementationAndResourcesJar)
 					entries.SetString("LOCAL_MODULE_STEM", library.Stem()+"-hostdex")
+					entries.AddCompatibilityTestSuites(library.deviceProperties.Target.Hostdex.Test_suites...)
+					for _, dep := range library.hostdexTestSuiteDeps {
+						entries.AddStrings("LOCAL_COMPATIBILITY_SUPPORT_FILES", dep.jar.String()+":"+dep.name)
+					}
 				},
 			},
 		}
@@ -171,6 +175,19 @@ func androidMkWriteExtraTestConfigs(extraTestConfigs android.Paths, entries *and
 	}
 }
 
+func (j *JavaTestImport) AndroidMkEntries() []android.AndroidMkEntries {
+	entriesList := j.Import.AndroidMkEntries()
+	entries := &entriesList[0]
+	entries.ExtraEntries = append(entries.ExtraEntries, func(ctx android.AndroidMkExtraEntriesContext, entries *android.AndroidMkEntries) {
+		testSuiteComponent(entries, j.prebuiltTestProperties.Test_suites, Bool(j.prebuiltTestProperties.Per_testcase_directory))
+		if j.testConfig != nil {
+			entries.SetPath("LOCAL_FULL_TEST_CONFIG", j.testConfig)
+		}
+	})
+
+	return entriesList
+}
+
 func (j *TestHelperLibrary) AndroidMkEntries() []android.AndroidMkEntries {
 	entriesList := j.Library.AndroidMkEntries()
 	entries := &entriesList[0]
@@ -204,6 +221,12 @@ func (prebuilt *Import) AndroidMkEntries() []android.AndroidMkEntries {
 				if prebuilt.dexJarFile.IsSet() {
 					entries.SetPath("LOCAL_SOONG_DEX_JAR", prebuilt.dexJarFile.Path())
 				}
+				if len(prebuilt.dexpreopter.builtInstalled) > 0 {
+					entries.SetString("LOCAL_SOONG_BUILT_INSTALLED", prebuilt.dexpreopter.builtInstalled)
+				}
+				if prebuilt.dexpreopter.configPath != nil {
+					entries.SetPath("LOCAL_SOONG_DEXPREOPT_CONFIG", prebuilt.dexpreopter.configPath)
+				}
 				entries.SetPath("LOCAL_SOONG_HEADER_JAR", prebuilt.combinedClasspathFile)
 				entries.SetPath("LOCAL_SOONG_CLASSES_JAR", prebuilt.combinedClasspathFile)
 				entries.SetString("LOCAL_SDK_VERSION", prebuilt.sdkVersion.String())
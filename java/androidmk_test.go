@@ -252,3 +252,24 @@ func TestGetOverriddenPackages(t *testing.T) {
 		android.AssertDeepEquals(t, "overrides property", expected.overrides, actual)
 	}
 }
+
+func TestJavaTestImportPerTestcaseDirectory(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_test_import {
+			name: "test",
+			jars: ["a.jar"],
+			test_suites: ["general-tests"],
+			test_config: "AndroidTest.xml",
+			per_testcase_directory: true,
+		}
+	`)
+
+	mod := result.ModuleForTests("test", "android_common").Module()
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, mod)[0]
+	android.AssertDeepEquals(t, "LOCAL_COMPATIBILITY_SUITE", []string{"general-tests"},
+		entries.EntryMap["LOCAL_COMPATIBILITY_SUITE"])
+	android.AssertDeepEquals(t, "LOCAL_COMPATIBILITY_PER_TESTCASE_DIRECTORY", []string{"true"},
+		entries.EntryMap["LOCAL_COMPATIBILITY_PER_TESTCASE_DIRECTORY"])
+	android.AssertStringPathsRelativeToTopEquals(t, "LOCAL_FULL_TEST_CONFIG", result.Config,
+		[]string{"AndroidTest.xml"}, entries.EntryMap["LOCAL_FULL_TEST_CONFIG"])
+}
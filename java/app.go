@@ -42,6 +42,8 @@ func RegisterAppBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("android_app_certificate", AndroidAppCertificateFactory)
 	ctx.RegisterModuleType("override_android_app", OverrideAndroidAppModuleFactory)
 	ctx.RegisterModuleType("override_android_test", OverrideAndroidTestModuleFactory)
+
+	ctx.RegisterSingletonType("target_sdk_version_overrides", targetSdkVersionOverridesSingletonFactory)
 }
 
 // AndroidManifest.xml merging
@@ -184,6 +186,10 @@ func (a *AndroidApp) ExportedStaticPackages() android.Paths {
 	return nil
 }
 
+func (a *AndroidApp) ExportedStaticRTxts() android.Paths {
+	return nil
+}
+
 func (a *AndroidApp) OutputFile() android.Path {
 	return a.outputFile
 }
@@ -423,6 +429,7 @@ func (a *AndroidApp) aaptBuildActions(ctx android.ModuleContext) {
 
 	a.aapt.splitNames = a.appProperties.Package_splits
 	a.aapt.LoggingParent = String(a.overridableAppProperties.Logging_parent)
+	a.aapt.TargetSdkVersionOverride = a.targetSdkVersionOverride(ctx)
 	a.aapt.buildActions(ctx, android.SdkContext(a), a.classLoaderContexts,
 		a.usesLibraryProperties.Exclude_uses_libs, aaptLinkFlags...)
 
@@ -430,6 +437,20 @@ func (a *AndroidApp) aaptBuildActions(ctx android.ModuleContext) {
 	a.properties.Manifest = nil
 }
 
+// targetSdkVersionOverride returns the targetSdkVersion that PRODUCT_TARGET_SDK_VERSION_OVERRIDES
+// sets for the directory of the app, unless the app sets target_sdk_version itself.
+func (a *AndroidApp) targetSdkVersionOverride(ctx android.ModuleContext) string {
+	if a.deviceProperties.Target_sdk_version != nil {
+		return ""
+	}
+	targetSdkVersion, _, err := ctx.DeviceConfig().OverrideTargetSdkVersionFor(ctx.ModuleDir())
+	if err != nil {
+		ctx.ModuleErrorf("%s", err)
+		return ""
+	}
+	return targetSdkVersion
+}
+
 func (a *AndroidApp) proguardBuildActions(ctx android.ModuleContext) {
 	var staticLibProguardFlagFiles android.Paths
 	ctx.VisitDirectDeps(func(m android.Module) {
@@ -963,6 +984,15 @@ func (a *AndroidTest) InstallInTestcases() bool {
 	return true
 }
 
+func (a *AndroidTest) TestMetadata() *android.TestMetadata {
+	return &android.TestMetadata{
+		Suites:            a.testProperties.Test_suites,
+		Config:            a.testConfig,
+		Tags:              a.testProperties.Test_options.Tags,
+		TestRunnerOptions: a.testProperties.Test_options.Test_runner_options,
+	}
+}
+
 func (a *AndroidTest) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	var configs []tradefed.Config
 	if a.appTestProperties.Instrumentation_target_package != nil {
@@ -970,8 +1000,7 @@ func (a *AndroidTest) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 			"--rename-instrumentation-target-package "+*a.appTestProperties.Instrumentation_target_package)
 	} else if a.appTestProperties.Instrumentation_for != nil {
 		// Check if the instrumentation target package is overridden.
-		manifestPackageName, overridden := ctx.DeviceConfig().OverrideManifestPackageNameFor(*a.appTestProperties.Instrumentation_for)
-		if overridden {
+		if manifestPackageName := a.overriddenInstrumentationTargetPackage(ctx); manifestPackageName != "" {
 			a.additionalAaptFlags = append(a.additionalAaptFlags, "--rename-instrumentation-target-package "+manifestPackageName)
 		}
 	}
@@ -988,6 +1017,24 @@ func (a *AndroidTest) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	a.data = android.PathsForModuleSrc(ctx, a.testProperties.Data)
 }
 
+// overriddenInstrumentationTargetPackage returns the package name that the app in
+// instrumentation_for is renamed to, or an empty string if it isn't renamed.
+func (a *AndroidTest) overriddenInstrumentationTargetPackage(ctx android.ModuleContext) string {
+	manifestPackageName, overridden := ctx.DeviceConfig().OverrideManifestPackageNameFor(*a.appTestProperties.Instrumentation_for)
+	if overridden {
+		return manifestPackageName
+	}
+
+	// Dependencies on an override_android_app are redirected to the variant of its base module that
+	// it overrides, which has the package name of the override_android_app.
+	ctx.VisitDirectDepsWithTag(instrumentationForTag, func(dep android.Module) {
+		if app, ok := dep.(*AndroidApp); ok && app.GetOverriddenBy() != "" {
+			manifestPackageName = app.OverriddenManifestPackageName()
+		}
+	})
+	return manifestPackageName
+}
+
 func (a *AndroidTest) FixTestConfig(ctx android.ModuleContext, testConfig android.Path) android.Path {
 	if testConfig == nil {
 		return nil
@@ -1273,9 +1320,20 @@ func (u *usesLibrary) deps(ctx android.BottomUpMutatorContext, hasFrameworkLibs
 }
 
 // presentOptionalUsesLibs returns optional_uses_libs after filtering out MissingUsesLibraries, which don't exist in the
-// build.
+// build.  If AllowMissingOptionalUsesLibraries is set, libraries that are not defined in the build are filtered out as
+// well.  The filtered out libraries are left out of the class loader context, but they are still verified against the
+// manifest, so that the app is dexpreopted the same way as on a device where the libraries are absent.
 func (u *usesLibrary) presentOptionalUsesLibs(ctx android.BaseModuleContext) []string {
 	optionalUsesLibs, _ := android.FilterList(u.usesLibraryProperties.Optional_uses_libs, ctx.Config().MissingUsesLibraries())
+	if ctx.Config().AllowMissingOptionalUsesLibraries() {
+		var present []string
+		for _, lib := range optionalUsesLibs {
+			if ctx.OtherModuleExists(lib) {
+				present = append(present, lib)
+			}
+		}
+		optionalUsesLibs = present
+	}
 	return optionalUsesLibs
 }
 
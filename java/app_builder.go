@@ -19,6 +19,7 @@ package java
 // functions.
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 
@@ -123,17 +124,26 @@ func SignAppPackage(ctx android.ModuleContext, signedApk android.WritablePath, u
 
 var buildAAR = pctx.AndroidStaticRule("buildAAR",
 	blueprint.RuleParams{
-		Command: `rm -rf ${outDir} && mkdir -p ${outDir} && ` +
+		Command: `rm -rf ${outDir} && mkdir -p ${outDir}/res && ` +
 			`cp ${manifest} ${outDir}/AndroidManifest.xml && ` +
-			`cp ${classesJar} ${outDir}/classes.jar && ` +
+			`${config.Zip2ZipCmd} -i ${classesJar} -o ${outDir}/classes.jar ` +
+			`-x 'R.class' -x '**/R.class' -x 'R$$*.class' -x '**/R$$*.class' && ` +
 			`cp ${rTxt} ${outDir}/R.txt && ` +
+			`cat ${proguardFlags} > ${outDir}/proguard.txt && ` +
+			`while read -r src dst; do ` +
+			`mkdir -p $$(dirname ${outDir}/res/$$dst) && cp $$src ${outDir}/res/$$dst || exit 1; ` +
+			`done < ${resList} && ` +
 			`${config.SoongZipCmd} -jar -o $out -C ${outDir} -D ${outDir}`,
-		CommandDeps: []string{"${config.SoongZipCmd}"},
+		CommandDeps: []string{"${config.SoongZipCmd}", "${config.Zip2ZipCmd}"},
 	},
-	"manifest", "classesJar", "rTxt", "outDir")
+	"manifest", "classesJar", "rTxt", "proguardFlags", "resList", "outDir")
 
+// BuildAAR creates an Android library archive as specified by
+// https://developer.android.com/studio/projects/android-library#aar-contents, containing the
+// manifest, the classes jar without the R classes, the R.txt file, the consumer proguard flags and
+// the sources of the resources, for use outside of the platform build.
 func BuildAAR(ctx android.ModuleContext, outputFile android.WritablePath,
-	classesJar, manifest, rTxt android.Path, res android.Paths) {
+	classesJar, manifest, rTxt android.Path, resDirs []globbedResourceDir, proguardFlags android.Paths) {
 
 	// TODO(ccross): uniquify and copy resources with dependencies
 
@@ -144,20 +154,87 @@ func BuildAAR(ctx android.ModuleContext, outputFile android.WritablePath,
 		classesJarPath = classesJar.String()
 	}
 
+	proguardFlagsArg := "/dev/null"
+	if len(proguardFlags) > 0 {
+		deps = append(deps, proguardFlags...)
+		proguardFlagsArg = strings.Join(proguardFlags.Strings(), " ")
+	}
+
+	// The resources of all the resource directories are merged into the res directory of the .aar,
+	// which is listed in a file of source and destination pairs, one per line.
+	resList := android.PathForModuleOut(ctx, "aar_res.list")
+	var resListContent strings.Builder
+	for _, entry := range aarResources(ctx, resDirs) {
+		fmt.Fprintf(&resListContent, "%s %s\n", entry.src, entry.dst)
+		deps = append(deps, entry.src)
+	}
+	android.WriteFileRule(ctx, resList, resListContent.String())
+	deps = append(deps, resList)
+
 	ctx.Build(pctx, android.BuildParams{
 		Rule:        buildAAR,
 		Description: "aar",
 		Implicits:   deps,
 		Output:      outputFile,
 		Args: map[string]string{
-			"manifest":   manifest.String(),
-			"classesJar": classesJarPath,
-			"rTxt":       rTxt.String(),
-			"outDir":     android.PathForModuleOut(ctx, "aar").String(),
+			"manifest":      manifest.String(),
+			"classesJar":    classesJarPath,
+			"rTxt":          rTxt.String(),
+			"proguardFlags": proguardFlagsArg,
+			"resList":       resList.String(),
+			"outDir":        android.PathForModuleOut(ctx, "aar").String(),
 		},
 	})
 }
 
+type aarResource struct {
+	src android.Path
+	dst string
+}
+
+// aarResources returns the files of the resource directories with their paths in the res
+// directory of an .aar. aapt2 merges the resources of values files with the same path in
+// different resource directories, so such files are renamed, e.g. to values/strings_1.xml. Other
+// resource files can only be in one of the resource directories.
+func aarResources(ctx android.ModuleContext, resDirs []globbedResourceDir) []aarResource {
+	var resources []aarResource
+	// The first file with each path keeps it, so the renamed files must not take one of the paths.
+	taken := make(map[string]android.Path)
+	for _, dir := range resDirs {
+		for _, file := range dir.files {
+			rel, err := filepath.Rel(dir.dir.String(), file.String())
+			if err != nil {
+				panic(err)
+			}
+			if taken[rel] == nil {
+				taken[rel] = file
+			}
+			resources = append(resources, aarResource{src: file, dst: rel})
+		}
+	}
+
+	for i, resource := range resources {
+		rel := resource.dst
+		other := taken[rel]
+		if other == resource.src {
+			continue
+		}
+		if !strings.HasPrefix(filepath.Dir(rel), "values") {
+			ctx.ModuleErrorf("resource %q is in more than one of the resource directories: %s and %s",
+				rel, other, resource.src)
+			continue
+		}
+		ext := filepath.Ext(rel)
+		dst := rel
+		for n := 1; taken[dst] != nil; n++ {
+			dst = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(rel, ext), n, ext)
+		}
+		taken[dst] = resource.src
+		resources[i].dst = dst
+	}
+	return resources
+}
+
 var buildBundleModule = pctx.AndroidStaticRule("buildBundleModule",
 	blueprint.RuleParams{
 		Command:     `${config.MergeZipsCmd} ${out} ${in}`,
@@ -48,6 +48,12 @@ type AndroidAppSetProperties struct {
 	// Names of modules to be overridden. Listed modules can only be other apps
 	//	(in Make or Soong).
 	Overrides []string
+
+	// If true, the ABI and locale specific splits to extract are selected by the
+	// PRODUCT_APP_SET_ABIS and PRODUCT_APP_SET_LOCALES variables instead of the device
+	// architectures and all locales.  The build fails if the APK set has ABI specific
+	// splits but none of them match the selected ABIs.
+	Use_product_split_filters *bool
 }
 
 type AndroidAppSet struct {
@@ -115,6 +121,39 @@ func SupportedAbis(ctx android.ModuleContext, excludeNativeBridgeAbis bool) []st
 	return result
 }
 
+// splitAbis returns the ABIs of the splits to extract from the APK set.
+func (as *AndroidAppSet) splitAbis(ctx android.ModuleContext) []string {
+	productAbis := ctx.Config().ProductAppSetAbis()
+	if !proptools.Bool(as.properties.Use_product_split_filters) || len(productAbis) == 0 {
+		return SupportedAbis(ctx, false)
+	}
+
+	deviceAbis := SupportedAbis(ctx, false)
+	var abis []string
+	for _, arch := range productAbis {
+		abi, found := TargetCpuAbi[arch]
+		if !found {
+			ctx.ModuleErrorf("PRODUCT_APP_SET_ABIS has invalid Arch: %s", arch)
+			continue
+		}
+		if !android.InList(abi, deviceAbis) {
+			ctx.ModuleErrorf("PRODUCT_APP_SET_ABIS contains %s, which is not an architecture of the device", arch)
+			continue
+		}
+		abis = append(abis, abi)
+	}
+	return abis
+}
+
+// splitLocales returns the locales of the splits to extract from the APK set, or "all".
+func (as *AndroidAppSet) splitLocales(ctx android.ModuleContext) string {
+	locales := ctx.Config().ProductAppSetLocales()
+	if !proptools.Bool(as.properties.Use_product_split_filters) || len(locales) == 0 {
+		return "all"
+	}
+	return strings.Join(locales, ",")
+}
+
 func (as *AndroidAppSet) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	as.packedOutput = android.PathForModuleOut(ctx, ctx.ModuleName()+".zip")
 	as.primaryOutput = android.PathForModuleOut(ctx, as.BaseModuleName()+".apk")
@@ -126,7 +165,6 @@ func (as *AndroidAppSet) GenerateAndroidBuildActions(ctx android.ModuleContext)
 	if dpis := ctx.Config().ProductAAPTPrebuiltDPI(); len(dpis) > 0 {
 		screenDensities = strings.ToUpper(strings.Join(dpis, ","))
 	}
-	// TODO(asmundak): handle locales.
 	// TODO(asmundak): do we support device features
 	ctx.Build(pctx,
 		android.BuildParams{
@@ -136,9 +174,11 @@ func (as *AndroidAppSet) GenerateAndroidBuildActions(ctx android.ModuleContext)
 			ImplicitOutputs: android.WritablePaths{as.packedOutput, as.apkcertsFile},
 			Inputs:          android.Paths{as.prebuilt.SingleSourcePath(ctx)},
 			Args: map[string]string{
-				"abis":              strings.Join(SupportedAbis(ctx, false), ","),
+				"abis":              strings.Join(as.splitAbis(ctx), ","),
 				"allow-prereleased": strconv.FormatBool(proptools.Bool(as.properties.Prerelease)),
 				"screen-densities":  screenDensities,
+				"locales":           as.splitLocales(ctx),
+				"require-abi-split": strconv.FormatBool(proptools.Bool(as.properties.Use_product_split_filters)),
 				"sdk-version":       ctx.Config().PlatformSdkVersion().String(),
 				"stem":              as.BaseModuleName(),
 				"apkcerts":          as.apkcertsFile.String(),
@@ -132,3 +132,89 @@ func TestAndroidAppSet_Variants(t *testing.T) {
 		}
 	}
 }
+
+func TestAndroidAppSet_ProductSplitFilters(t *testing.T) {
+	bp := `
+		android_app_set {
+			name: "foo",
+			set: "prebuilts/apks/app.apks",
+			use_product_split_filters: true,
+		}`
+	testCases := []struct {
+		name     string
+		targets  []android.Target
+		abis     []string
+		locales  []string
+		expected map[string]string
+	}{
+		{
+			name: "arm64",
+			targets: []android.Target{
+				{Os: android.Android, Arch: android.Arch{ArchType: android.Arm64}},
+				{Os: android.Android, Arch: android.Arch{ArchType: android.Arm}},
+			},
+			abis:    []string{"arm64"},
+			locales: []string{"en", "fr"},
+			expected: map[string]string{
+				"abis":              "ARM64_V8A",
+				"locales":           "en,fr",
+				"require-abi-split": "true",
+			},
+		},
+		{
+			name: "x86_64",
+			targets: []android.Target{
+				{Os: android.Android, Arch: android.Arch{ArchType: android.X86_64}},
+				{Os: android.Android, Arch: android.Arch{ArchType: android.X86}},
+			},
+			abis:    nil,
+			locales: nil,
+			expected: map[string]string{
+				"abis":              "X86_64,X86",
+				"locales":           "all",
+				"require-abi-split": "true",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			result := android.GroupFixturePreparers(
+				PrepareForTestWithJavaDefaultModules,
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					variables.AppSetAbis = test.abis
+					variables.AppSetLocales = test.locales
+				}),
+				android.FixtureModifyConfig(func(config android.Config) {
+					config.Targets[android.Android] = test.targets
+				}),
+			).RunTestWithBp(t, bp)
+
+			params := result.ModuleForTests("foo", "android_common").Output("foo.zip")
+			for k, v := range test.expected {
+				android.AssertStringEquals(t, fmt.Sprintf("arg value for `%s`", k), v, params.Args[k])
+			}
+		})
+	}
+}
+
+func TestAndroidAppSet_ProductSplitFiltersIncompatibleAbi(t *testing.T) {
+	android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.AppSetAbis = []string{"x86_64"}
+		}),
+		android.FixtureModifyConfig(func(config android.Config) {
+			config.Targets[android.Android] = []android.Target{
+				{Os: android.Android, Arch: android.Arch{ArchType: android.Arm64}},
+			}
+		}),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`PRODUCT_APP_SET_ABIS contains x86_64, which is not an architecture of the device`)).
+		RunTestWithBp(t, `
+		android_app_set {
+			name: "foo",
+			set: "prebuilts/apks/app.apks",
+			use_product_split_filters: true,
+		}`)
+}
@@ -672,6 +672,151 @@ func TestLibraryAssets(t *testing.T) {
 	}
 }
 
+func TestAndroidLibraryAAR(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.MockFS{
+			"res/layout/main.xml":       nil,
+			"res/values/strings.xml":    nil,
+			"res2/drawable/icon.png":    nil,
+			"res2/values/strings.xml":   nil,
+			"res2/values/strings_1.xml": nil,
+			"proguard.flags":            nil,
+			"proguard2.flags":           nil,
+		}.AddToFixture(),
+	).RunTestWithBp(t, `
+		android_library {
+			name: "lib",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			static_libs: ["lib2"],
+			resource_dirs: ["res", "res2"],
+			optimize: {
+				proguard_flags_files: ["proguard.flags"],
+			},
+		}
+
+		android_library {
+			name: "lib2",
+			srcs: ["b.java"],
+			sdk_version: "current",
+			resource_dirs: [],
+			optimize: {
+				proguard_flags_files: ["proguard2.flags"],
+			},
+		}
+	`)
+
+	lib := result.ModuleForTests("lib", "android_common")
+	aar := lib.Output("lib.aar")
+	module := lib.Module().(*AndroidLibrary)
+	android.AssertStringEquals(t, "manifest", module.manifestPath.String(), aar.Args["manifest"])
+	android.AssertStringEquals(t, "R.txt", "out/soong/.intermediates/lib/android_common/R.txt",
+		android.StringRelativeToTop(result.Config, aar.Args["rTxt"]))
+	android.AssertStringEquals(t, "classes jar", module.outputFile.String(), aar.Args["classesJar"])
+	android.AssertStringEquals(t, "proguard flags", "proguard.flags proguard2.flags", aar.Args["proguardFlags"])
+	for _, file := range []string{"res/layout/main.xml", "res2/values/strings.xml", "proguard.flags", "proguard2.flags"} {
+		android.AssertStringListContains(t, "implicits", aar.Implicits.Strings(), file)
+	}
+
+	// The resources are merged into the res directory of the .aar, and the values files of the
+	// second resource directory with the same path as one of the first are renamed.
+	resList := lib.Output("aar_res.list")
+	android.AssertStringEquals(t, "lib resources", strings.Join([]string{
+		"res/layout/main.xml layout/main.xml",
+		"res/values/strings.xml values/strings.xml",
+		"res2/drawable/icon.png drawable/icon.png",
+		"res2/values/strings.xml values/strings_2.xml",
+		"res2/values/strings_1.xml values/strings_1.xml",
+		"",
+	}, "\n"), android.ContentFromFileRuleForTests(t, resList))
+	android.AssertStringEquals(t, "resource list", resList.Output.String(), aar.Args["resList"])
+
+	// A library without resources has an empty res directory in its .aar.
+	lib2 := result.ModuleForTests("lib2", "android_common")
+	aar2 := lib2.Output("lib2.aar")
+	android.AssertStringEquals(t, "lib2 proguard flags", "proguard2.flags", aar2.Args["proguardFlags"])
+	android.AssertStringEquals(t, "lib2 resources", "",
+		android.ContentFromFileRuleForTests(t, lib2.Output("aar_res.list")))
+
+	android.AssertPathsRelativeToTopEquals(t, "android_library_aars", []string{
+		"out/soong/.intermediates/lib/android_common/lib.aar",
+		"out/soong/.intermediates/lib2/android_common/lib2.aar",
+	}, android.SortedUniquePaths(result.Phonies()["android_library_aars"]))
+}
+
+func TestAndroidLibraryAARConflictingResources(t *testing.T) {
+	android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.MockFS{
+			"res/layout/main.xml":  nil,
+			"res2/layout/main.xml": nil,
+		}.AddToFixture(),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`module "lib".*: resource "layout/main.xml" is in more than one of the resource directories: `+
+			`res/layout/main.xml and res2/layout/main.xml`)).
+		RunTestWithBp(t, `
+			android_library {
+				name: "lib",
+				srcs: ["a.java"],
+				sdk_version: "current",
+				resource_dirs: ["res", "res2"],
+			}
+		`)
+}
+
+func TestAppAssetSrcs(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"extra/config.json": nil,
+			"model.bin":         nil,
+		}),
+	).RunTestWithBp(t, `
+			genrule {
+				name: "model_gen",
+				cmd: "compress $(in) > $(out)",
+				srcs: ["model.bin"],
+				out: ["models/model.tflite"],
+			}
+
+			android_app {
+				name: "foo",
+				sdk_version: "current",
+				asset_srcs: [":model_gen", "extra/config.json"],
+				static_libs: ["lib"],
+			}
+
+			android_library {
+				name: "lib",
+				sdk_version: "current",
+				asset_dirs: ["assets_a"],
+			}
+		`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+
+	// The asset_srcs are zipped under assets/, keeping their path relative to the genrule output
+	// directory or to the module directory.
+	assetSrcsZip := foo.Output("asset_srcs.zip")
+	command := android.StringRelativeToTop(result.Config, assetSrcsZip.RuleParams.Command)
+	android.AssertStringDoesContain(t, "asset_srcs zip command", command,
+		"-P assets -C out/soong/.intermediates/model_gen/gen/ -f out/soong/.intermediates/model_gen/gen/models/model.tflite")
+	android.AssertStringDoesContain(t, "asset_srcs zip command", command,
+		"-C . -f extra/config.json")
+
+	// The asset_srcs are merged before the assets of the static libraries.
+	mergeAssets := foo.Output("package-res.apk")
+	android.AssertPathsRelativeToTopEquals(t, "mergeAssets inputs", []string{
+		"out/soong/.intermediates/foo/android_common/aapt2/package-res.apk",
+		"out/soong/.intermediates/foo/android_common/asset_srcs.zip",
+		"out/soong/.intermediates/lib/android_common/assets.zip",
+	}, mergeAssets.Inputs)
+
+	// The assets are exported for modules that depend on foo.
+	foo.Output("assets.zip")
+}
+
 func TestAppJavaResources(t *testing.T) {
 	bp := `
 			android_app {
@@ -717,6 +862,96 @@ func TestAppJavaResources(t *testing.T) {
 	}
 }
 
+func TestResourceMergeOrder(t *testing.T) {
+	bp := `
+			android_app {
+				name: "foo",
+				sdk_version: "current",
+				static_libs: ["lib1", "lib2", "lib3"],
+				resource_merge_order: ["lib3", "lib1"],
+			}
+
+			android_app {
+				name: "bar",
+				sdk_version: "current",
+				static_libs: ["lib1", "lib2", "lib3"],
+			}
+
+			android_library {
+				name: "lib1",
+				sdk_version: "current",
+			}
+
+			android_library {
+				name: "lib2",
+				sdk_version: "current",
+			}
+
+			android_library {
+				name: "lib3",
+				sdk_version: "current",
+				static_libs: ["lib4"],
+			}
+
+			android_library {
+				name: "lib4",
+				sdk_version: "current",
+			}
+		`
+
+	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, bp)
+
+	// The libraries in resource_merge_order are merged last, in the order of the list.
+	foo := result.ModuleForTests("foo", "android_common")
+	android.AssertPathsRelativeToTopEquals(t, "foo overlays", []string{
+		"out/soong/.intermediates/lib2/android_common/package-res.apk",
+		"out/soong/.intermediates/lib4/android_common/package-res.apk",
+		"out/soong/.intermediates/lib3/android_common/package-res.apk",
+		"out/soong/.intermediates/lib1/android_common/package-res.apk",
+	}, foo.Output("aapt2/overlay.list").Inputs)
+
+	// Only lib2 is unprioritized in foo, so there is nothing to check.
+	if foo.MaybeRule("resource_conflicts").Rule != nil {
+		t.Errorf("expected no resource_conflicts rule for foo")
+	}
+
+	// All the libraries are unprioritized in bar, so they are checked for conflicts.
+	bar := result.ModuleForTests("bar", "android_common")
+	check := bar.Rule("resource_conflicts")
+	android.AssertStringDoesContain(t, "bar resource conflicts check", check.RuleParams.Command,
+		"--lib lib1 out/soong/.intermediates/lib1/android_common/R.txt --lib lib2")
+	android.AssertStringDoesContain(t, "bar resource conflicts check", check.RuleParams.Command,
+		"--lib lib3 out/soong/.intermediates/lib3/android_common/R.txt out/soong/.intermediates/lib4/android_common/R.txt")
+	android.AssertPathsRelativeToTopEquals(t, "bar aapt2 link implicits contain check",
+		[]string{"out/soong/.intermediates/bar/android_common/resource_conflicts.timestamp"},
+		bar.Output("package-res.apk").Implicits.FilterByExt(".timestamp"))
+}
+
+func TestResourceMergeOrderNotStaticLib(t *testing.T) {
+	PrepareForTestWithJavaDefaultModules.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`resource_merge_order: "lib2" is not an android library in static_libs`)).
+		RunTestWithBp(t, `
+			android_app {
+				name: "foo",
+				sdk_version: "current",
+				static_libs: ["lib1"],
+				libs: ["lib2"],
+				resource_merge_order: ["lib2"],
+			}
+
+			android_library {
+				name: "lib1",
+				sdk_version: "current",
+			}
+
+			android_library {
+				name: "lib2",
+				sdk_version: "current",
+			}
+		`)
+}
+
 func TestAndroidResources(t *testing.T) {
 	testCases := []struct {
 		name                       string
@@ -1774,6 +2009,97 @@ func TestPackageNameOverride(t *testing.T) {
 	}
 }
 
+func TestAppProductAaptFlags(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureAddTextFile("vendor/foo/Android.bp", `
+			android_app {
+				name: "foo",
+				srcs: ["a.java"],
+				sdk_version: "current",
+				aaptflags: [
+					"--extra-packages",
+					"com.foo.extra",
+					"--package-id 0x7d",
+				],
+			}
+
+			android_library {
+				name: "lib",
+				srcs: ["a.java"],
+				sdk_version: "current",
+			}
+		`),
+		android.FixtureAddTextFile("vendor/bar/Android.bp", `
+			android_app {
+				name: "bar",
+				srcs: ["a.java"],
+				sdk_version: "current",
+			}
+		`),
+		android.FixtureAddTextFile("vendor_other/Android.bp", `
+			android_app {
+				name: "baz",
+				srcs: ["a.java"],
+				sdk_version: "current",
+			}
+		`),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.AaptFlagsByPath = map[string][]string{
+				"vendor": {
+					"--rename-resources-package com.vendor",
+					"--package-id 0x7e",
+					"--extra-packages com.vendor.extra",
+				},
+				"vendor/foo": {"--package-id", "0x7d", "--no-version-vectors"},
+				"other":      {"--auto-add-overlay"},
+			}
+		}),
+	).RunTest(t)
+
+	linkFlags := func(module string) string {
+		return result.ModuleForTests(module, "android_common").Output("package-res.apk").Args["flags"]
+	}
+
+	// The flags of the entry for the subdirectory replace the flags of the entry for its parent, and
+	// the flags of the module replace the product flags with the same name, along with their values.
+	fooFlags := linkFlags("foo")
+	android.AssertStringDoesContain(t, "foo flags", fooFlags,
+		"--rename-resources-package com.vendor --no-version-vectors --extra-packages com.foo.extra --package-id 0x7d ")
+	android.AssertStringDoesNotContain(t, "foo flags", fooFlags, "0x7e")
+	android.AssertStringDoesNotContain(t, "foo flags", fooFlags, "com.vendor.extra")
+	android.AssertStringDoesNotContain(t, "foo flags", fooFlags, "--auto-add-overlay")
+
+	android.AssertStringDoesContain(t, "bar flags", linkFlags("bar"),
+		"--rename-resources-package com.vendor --package-id 0x7e --extra-packages com.vendor.extra ")
+
+	// The product flags are only added to apps in the directories and their subdirectories.
+	android.AssertStringDoesNotContain(t, "baz flags", linkFlags("baz"), "--rename-resources-package")
+	android.AssertStringDoesNotContain(t, "lib flags", linkFlags("lib"), "--rename-resources-package")
+}
+
+func TestAppProductAaptFlagsConflict(t *testing.T) {
+	android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureAddTextFile("vendor/foo/Android.bp", `
+			android_app {
+				name: "foo",
+				srcs: ["a.java"],
+				sdk_version: "current",
+				aaptflags: ["--package-id", "0x7f"],
+			}
+		`),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.AaptFlagsByPath = map[string][]string{
+				"vendor":     {"--package-id 0x7e"},
+				"vendor/foo": {"--package-id 0x7d"},
+			}
+		}),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`module "foo".*: conflicting values \["0x7f" "0x7d"\] for aapt2 flag --package-id`)).
+		RunTest(t)
+}
+
 func TestInstrumentationTargetOverridden(t *testing.T) {
 	bp := `
 		android_app {
@@ -1805,6 +2131,70 @@ func TestInstrumentationTargetOverridden(t *testing.T) {
 	}
 }
 
+func TestInstrumentationTargetOverrideAndroidApp(t *testing.T) {
+	bp := `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+		}
+
+		override_android_app {
+			name: "bar",
+			base: "foo",
+			package_name: "org.dandroid.bp",
+		}
+
+		android_test {
+			name: "foo_test",
+			instrumentation_for: "foo",
+			sdk_version: "current",
+		}
+
+		android_test {
+			name: "bar_test",
+			instrumentation_for: "bar",
+			sdk_version: "current",
+		}
+
+		android_test {
+			name: "baz_test",
+			instrumentation_for: "bar",
+			instrumentation_target_package: "org.dandroid.baz",
+			sdk_version: "current",
+		}
+		`
+
+	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, bp)
+
+	testCases := []struct {
+		moduleName        string
+		targetPackageFlag string
+	}{
+		{
+			// The base app keeps the package name from its manifest.
+			moduleName:        "foo_test",
+			targetPackageFlag: "",
+		},
+		{
+			// The package name of the override_android_app is used instead of the base app's.
+			moduleName:        "bar_test",
+			targetPackageFlag: "org.dandroid.bp",
+		},
+		{
+			// instrumentation_target_package takes precedence over the instrumented app.
+			moduleName:        "baz_test",
+			targetPackageFlag: "org.dandroid.baz",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.moduleName, func(t *testing.T) {
+			res := result.ModuleForTests(tc.moduleName, "android_common").Output("package-res.apk")
+			checkAapt2LinkFlag(t, res.Args["flags"], "rename-instrumentation-target-package", tc.targetPackageFlag)
+		})
+	}
+}
+
 func TestOverrideAndroidApp(t *testing.T) {
 	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(
 		t, `
@@ -2685,6 +3075,59 @@ func TestUsesLibraries(t *testing.T) {
 			`#PCL[/system/framework/android.test.mock.jar] `)
 }
 
+func TestMissingOptionalUsesLibraries(t *testing.T) {
+	bp := `
+		java_library {
+			name: "runtime-optional",
+			srcs: ["a.java"],
+			installable: true,
+			sdk_version: "current",
+		}
+
+		android_app {
+			name: "app",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			optional_uses_libs: [
+				"runtime-optional",
+				"runtime-optional-missing",
+			],
+		}
+	`
+
+	t.Run("allowed", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			prepareForJavaTest,
+			android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+				variables.AllowMissingOptionalUsesLibraries = proptools.BoolPtr(true)
+			}),
+		).RunTestWithBp(t, bp)
+
+		app := result.ModuleForTests("app", "android_common")
+
+		// The missing library is still verified against the manifest.
+		verifyCmd := app.Rule("verify_uses_libraries").RuleParams.Command
+		android.AssertStringDoesContain(t, "verify cmd args", verifyCmd,
+			`--optional-uses-library runtime-optional `+
+				`--optional-uses-library runtime-optional-missing `)
+
+		// The app is still dexpreopted, with the missing library left out of the class loader
+		// context.
+		cmd := app.Rule("dexpreopt").RuleParams.Command
+		android.AssertStringDoesContain(t, "dexpreopt app cmd args", cmd,
+			`--target-context-for-sdk any PCL[/system/framework/runtime-optional.jar] `)
+		android.AssertStringDoesNotContain(t, "dexpreopt app cmd args", cmd, "runtime-optional-missing")
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		android.GroupFixturePreparers(
+			prepareForJavaTest,
+		).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`depends on undefined module "runtime-optional-missing"`,
+		)).RunTestWithBp(t, bp)
+	})
+}
+
 func TestDexpreoptBcp(t *testing.T) {
 	bp := `
 		java_sdk_library {
@@ -3049,6 +3492,87 @@ func TestTargetSdkVersionManifestFixer(t *testing.T) {
 	}
 }
 
+func TestTargetSdkVersionOverrides(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.TargetSdkVersionOverrides = []string{"vendor:30", "vendor/bar/:31"}
+		}),
+		android.FixtureAddTextFile("vendor/foo/Android.bp", `
+			android_app {
+				name: "foo",
+				sdk_version: "current",
+			}
+		`),
+		android.FixtureAddTextFile("vendor/bar/Android.bp", `
+			android_app {
+				name: "bar",
+				sdk_version: "current",
+			}
+
+			android_app {
+				name: "bar_explicit",
+				sdk_version: "current",
+				target_sdk_version: "29",
+			}
+		`),
+		android.FixtureAddTextFile("vendors/baz/Android.bp", `
+			android_app {
+				name: "baz",
+				sdk_version: "current",
+			}
+		`),
+	).RunTest(t)
+
+	testCases := []struct {
+		module                   string
+		targetSdkVersionExpected string
+	}{
+		{module: "foo", targetSdkVersionExpected: "30"},
+		// The longest matching path prefix wins.
+		{module: "bar", targetSdkVersionExpected: "31"},
+		// An explicit target_sdk_version wins over the product configuration.
+		{module: "bar_explicit", targetSdkVersionExpected: "29"},
+	}
+	for _, testCase := range testCases {
+		module := result.ModuleForTests(testCase.module, "android_common")
+		manifestFixerArgs := module.Output("manifest_fixer/AndroidManifest.xml").Args["args"]
+		android.AssertStringDoesContain(t, testCase.module, manifestFixerArgs,
+			"--targetSdkVersion  "+testCase.targetSdkVersionExpected)
+
+		// Lint checks the app against the same targetSdkVersion.
+		android.AssertStringEquals(t, testCase.module+" lint", testCase.targetSdkVersionExpected,
+			module.Module().(*AndroidApp).linter.targetSdkVersion.String())
+	}
+
+	// Path prefixes only match whole directories.
+	manifestFixerArgs := result.ModuleForTests("baz", "android_common").
+		Output("manifest_fixer/AndroidManifest.xml").Args["args"]
+	android.AssertStringDoesNotContain(t, "baz", manifestFixerArgs, "--targetSdkVersion  30")
+
+	report := result.SingletonForTests("target_sdk_version_overrides").
+		Output("target_sdk_version_overrides.txt")
+	android.AssertStringEquals(t, "report",
+		"bar vendor/bar 31\nfoo vendor/foo 30",
+		android.ContentFromFileRuleForTests(t, report))
+}
+
+func TestTargetSdkVersionOverridesInvalid(t *testing.T) {
+	android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.TargetSdkVersionOverrides = []string{"vendor"}
+		}),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`module "foo".*: invalid override rule "vendor" in PRODUCT_TARGET_SDK_VERSION_OVERRIDES`)).
+		RunTestWithBp(t, `
+			android_app {
+				name: "foo",
+				sdk_version: "current",
+			}
+		`)
+}
+
 func TestAppMissingCertificateAllowMissingDependencies(t *testing.T) {
 	result := android.GroupFixturePreparers(
 		PrepareForTestWithJavaDefaultModules,
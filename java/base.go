@@ -52,7 +52,9 @@ type CommonProperties struct {
 	Common_srcs []string `android:"path,arch_variant"`
 
 	// list of source files that should not be used to build the Java module.
-	// This is most useful in the arch/multilib variants to remove non-common files
+	// This is most useful in the arch/multilib variants to remove non-common files.
+	// File and glob patterns are also matched against the paths of files generated by
+	// modules referenced in srcs, relative to the generating module's output directory.
 	Exclude_srcs []string `android:"path,arch_variant"`
 
 	// list of directories containing Java resources
@@ -102,12 +104,25 @@ type CommonProperties struct {
 	// List of modules to use as annotation processors
 	Plugins []string
 
+	// List of options to pass to the annotation processors, in the form key=value.  They are
+	// passed to javac as -Akey=value, and to kapt as apoptions when kapt runs the annotation
+	// processors.
+	Plugin_options []string
+
 	// List of modules to export to libraries that directly depend on this library as annotation
 	// processors.  Note that if the plugins set generates_api: true this will disable the turbine
 	// optimization on modules that depend on this module, which will reduce parallelism and cause
 	// more recompilation.
 	Exported_plugins []string
 
+	// List of host tools that transform the compiled classes of this module, in the form
+	// ":<module>".  Each tool is run as "<tool> <input jar> <output jar>" after javac and kotlinc,
+	// in order, and before the classes are combined with the static libraries, jarjared,
+	// instrumented or dexed.  Only the classes compiled from the sources of this module are
+	// transformed.  The header jar used to compile the modules that depend on this module is never
+	// transformed, so the transforms must not change the API of the classes.
+	Jar_transforms []string
+
 	// The number of Java source entries each Javac instance can process
 	Javac_shard_size *int64
 
@@ -153,7 +168,8 @@ type CommonProperties struct {
 		// List of javac flags that should only be used when running errorprone.
 		Javacflags []string
 
-		// List of java_plugin modules that provide extra errorprone checks.
+		// List of java_plugin or host java_library modules that provide extra errorprone checks.
+		// They are only added to the processorpath when errorprone is run.
 		Extra_check_modules []string
 
 		// This property can be in 3 states. When set to true, errorprone will
@@ -238,6 +254,12 @@ type DeviceProperties struct {
 
 		// list of flags that will be passed to the AIDL compiler
 		Flags []string
+
+		// whether to add the root directory of each of the srcs, e.g. the path of the filegroup
+		// that provides them, to the aidl include paths so that aidl files can import the
+		// parcelables declared next to the other srcs.  The root directories of the aidl srcs
+		// are always added.
+		Include_dirs_from_srcs *bool
 	}
 
 	// If true, export a copy of the module as a -hostdex module for host testing.
@@ -247,6 +269,12 @@ type DeviceProperties struct {
 		Hostdex struct {
 			// Additional required dependencies to add to -hostdex modules.
 			Required []string
+
+			// list of compatibility suites (for example "cts", "vts") that the -hostdex module
+			// should be installed into for running on host ART.  The -hostdex jar is installed
+			// into the test suites with a Class-Path manifest that references the -hostdex jars of
+			// its transitive libs dependencies, which are installed next to it.
+			Test_suites []string
 		}
 	}
 
@@ -438,6 +466,11 @@ type Module struct {
 	// installed file for hostdex copy
 	hostdexInstallFile android.InstallPath
 
+	// The -hostdex jar with a Class-Path manifest and the -hostdex jars that it references, which
+	// Make installs into the test suites of target.hostdex.test_suites.
+	hostdexTestSuiteJar  android.Path
+	hostdexTestSuiteDeps []hostdexJar
+
 	// list of .java files and srcjars that was passed to javac
 	compiledJavaSrcs android.Paths
 	compiledSrcJars  android.Paths
@@ -457,6 +490,10 @@ type Module struct {
 	// if true, the exported plugins generate API and require disabling turbine.
 	exportedDisableTurbine bool
 
+	// dependency chains to the platform libraries whose implementation jars are on the compile
+	// classpath of this module, see PlatformImplLibrariesInfo.
+	platformImplLibraryChains [][]string
+
 	// list of source files, collected from srcFiles with unique java and all kt files,
 	// will be used by android.IDEInfo struct
 	expandIDEInfoCompiledSrcs []string
@@ -663,6 +700,15 @@ func (j *Module) TargetSdkVersion(ctx android.EarlyModuleContext) android.SdkSpe
 	return j.SdkVersion(ctx)
 }
 
+// lintTargetSdkVersion returns the targetSdkVersion to lint the module against, which for an app
+// includes the one that PRODUCT_TARGET_SDK_VERSION_OVERRIDES sets for it.
+func (j *Module) lintTargetSdkVersion(ctx android.ModuleContext) android.SdkSpec {
+	if app, ok := ctx.Module().(targetSdkVersionOverridden); ok && app.overriddenTargetSdkVersion() != "" {
+		return android.SdkSpecFrom(ctx, app.overriddenTargetSdkVersion())
+	}
+	return j.TargetSdkVersion(ctx)
+}
+
 func (j *Module) AvailableFor(what string) bool {
 	if what == android.AvailableToPlatform && Bool(j.deviceProperties.Hostdex) {
 		// Exception: for hostdex: true libraries, the platform variant is created
@@ -738,6 +784,13 @@ func (j *Module) deps(ctx android.BottomUpMutatorContext) {
 	ctx.AddFarVariationDependencies(ctx.Config().BuildOSCommonTarget.Variations(), pluginTag, j.properties.Plugins...)
 	ctx.AddFarVariationDependencies(ctx.Config().BuildOSCommonTarget.Variations(), errorpronePluginTag, j.properties.Errorprone.Extra_check_modules...)
 	ctx.AddFarVariationDependencies(ctx.Config().BuildOSCommonTarget.Variations(), exportedPluginTag, j.properties.Exported_plugins...)
+	for _, transform := range j.properties.Jar_transforms {
+		if tool := android.SrcIsModule(transform); tool != "" {
+			ctx.AddFarVariationDependencies(ctx.Config().BuildOSTarget.Variations(), jarTransformTag, tool)
+		} else {
+			ctx.PropertyErrorf("jar_transforms", "%q is not a module reference, use \":<module>\"", transform)
+		}
+	}
 
 	android.ProtoDeps(ctx, &j.protoProperties)
 	if j.hasSrcExt(".proto") {
@@ -928,6 +981,21 @@ func (j *Module) collectJavacFlags(
 	}
 	javacFlags = append(javacFlags, "-Xlint:-dep-ann")
 
+	// Annotation processor options may be set either through plugin_options or as -A flags in
+	// javacflags, collect both so that they can also be passed to kapt.
+	for _, option := range j.properties.Plugin_options {
+		if strings.Index(option, "=") <= 0 || strings.ContainsAny(option, " \t") {
+			ctx.PropertyErrorf("plugin_options", "invalid option %q, must be key=value", option)
+			continue
+		}
+		javacFlags = append(javacFlags, "-A"+option)
+	}
+	for _, flag := range javacFlags {
+		if strings.HasPrefix(flag, "-A") {
+			flags.processorOptions = append(flags.processorOptions, strings.TrimPrefix(flag, "-A"))
+		}
+	}
+
 	if flags.javaVersion.usesJavaModules() {
 		javacFlags = append(javacFlags, j.properties.Openjdk9.Javacflags...)
 
@@ -1005,7 +1073,13 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		j.properties.Srcs = append(j.properties.Srcs, j.properties.Openjdk9.Srcs...)
 	}
 
-	srcFiles := android.PathsForModuleSrcExcludes(ctx, j.properties.Srcs, j.properties.Exclude_srcs)
+	srcFiles := android.PathsRelativeToModuleSourceDir(android.SourceInput{
+		Context:               ctx,
+		Paths:                 j.properties.Srcs,
+		ExcludePaths:          j.properties.Exclude_srcs,
+		IncludeDirs:           true,
+		ExcludeGeneratedPaths: true,
+	})
 	j.sourceExtensions = []string{}
 	for _, ext := range []string{".kt", ".proto", ".aidl", ".java", ".logtags"} {
 		if hasSrcExt(srcFiles.Strings(), ext) {
@@ -1015,6 +1089,7 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 	if hasSrcExt(srcFiles.Strings(), ".proto") {
 		flags = protoFlags(ctx, &j.properties, &j.protoProperties, flags)
 	}
+	android.SetProtoInfo(ctx, &j.protoProperties, srcFiles.FilterByExt(".proto"))
 
 	kotlinCommonSrcFiles := android.PathsForModuleSrcExcludes(ctx, j.properties.Common_srcs, nil)
 	if len(kotlinCommonSrcFiles.FilterOutByExt(".kt")) > 0 {
@@ -1061,6 +1136,7 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 
 	var kotlinJars android.Paths
 	var kotlinHeaderJars android.Paths
+	var staticKotlinJars android.Paths
 
 	if srcFiles.HasExt(".kt") {
 		// When using kotlin sources turbine is used to generate annotation processor sources,
@@ -1137,6 +1213,8 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		if BoolDefault(j.properties.Static_kotlin_stdlib, true) {
 			kotlinJars = append(kotlinJars, deps.kotlinStdlib...)
 			kotlinJars = append(kotlinJars, deps.kotlinAnnotations...)
+			staticKotlinJars = append(staticKotlinJars, deps.kotlinStdlib...)
+			staticKotlinJars = append(staticKotlinJars, deps.kotlinAnnotations...)
 			kotlinHeaderJars = append(kotlinHeaderJars, deps.kotlinStdlib...)
 			kotlinHeaderJars = append(kotlinHeaderJars, deps.kotlinAnnotations...)
 		} else {
@@ -1218,6 +1296,21 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		}
 	}
 
+	// Only the classes compiled from the sources of this module are transformed, the static
+	// libraries have already been transformed by their own modules.
+	var untransformedJars android.Paths
+	if len(j.properties.Jar_transforms) > 0 {
+		compiledJars, _ := android.FilterPathList(jars, staticKotlinJars)
+		untransformedJars = jars
+		jars = staticKotlinJars
+		if len(compiledJars) > 0 {
+			jars = append(android.Paths{j.transformJar(ctx, compiledJars, jarName)}, jars...)
+		}
+		if ctx.Failed() {
+			return
+		}
+	}
+
 	j.srcJarArgs, j.srcJarDeps = resourcePathsToJarArgs(srcFiles), srcFiles
 
 	var includeSrcJar android.WritablePath
@@ -1388,7 +1481,12 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 
 	j.implementationJarFile = outputFile
 	if j.headerJarFile == nil {
-		j.headerJarFile = j.implementationJarFile
+		if untransformedJars != nil {
+			j.headerJarFile = j.untransformedHeaderJar(ctx,
+				append(android.CopyOfPaths(untransformedJars), deps.staticJars...), jarName)
+		} else {
+			j.headerJarFile = j.implementationJarFile
+		}
 	}
 
 	if j.shouldInstrumentInApex(ctx) {
@@ -1498,7 +1596,7 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		j.linter.classpath = append(append(android.Paths(nil), flags.bootClasspath...), flags.classpath...)
 		j.linter.classes = j.implementationJarFile
 		j.linter.minSdkVersion = lintSDKVersion(j.MinSdkVersion(ctx))
-		j.linter.targetSdkVersion = lintSDKVersion(j.TargetSdkVersion(ctx))
+		j.linter.targetSdkVersion = lintSDKVersion(j.lintTargetSdkVersion(ctx))
 		j.linter.compileSdkVersion = lintSDKVersion(j.SdkVersion(ctx))
 		j.linter.compileSdkKind = j.SdkVersion(ctx).Kind
 		j.linter.javaLanguageLevel = flags.javaVersion.String()
@@ -1525,6 +1623,10 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		JacocoReportClassesFile:        j.jacocoReportClassesFile,
 	})
 
+	ctx.SetProvider(PlatformImplLibrariesInfoProvider, PlatformImplLibrariesInfo{
+		DependencyChains: j.platformImplLibraryChains,
+	})
+
 	// Save the output file with no relative path so that it doesn't end up in a subdirectory when used as a resource
 	j.outputFile = outputFile.WithoutRel()
 }
@@ -1636,6 +1738,75 @@ func (j *Module) compileJavaHeader(ctx android.ModuleContext, srcFiles, srcJars
 	return headerJar, jarjarAndDepsHeaderJar
 }
 
+// jarTransformTools returns the paths to the host tools listed in jar_transforms, in order.
+func (j *Module) jarTransformTools(ctx android.ModuleContext) android.Paths {
+	toolPaths := make(map[string]android.Path)
+	ctx.VisitDirectDepsWithTag(jarTransformTag, func(m android.Module) {
+		name := ctx.OtherModuleName(m)
+		if t, ok := m.(android.HostToolProvider); ok {
+			if path := t.HostToolPath(); path.Valid() {
+				toolPaths[name] = path.Path()
+			} else {
+				ctx.ModuleErrorf("host tool %q missing output file", name)
+			}
+		} else {
+			ctx.PropertyErrorf("jar_transforms", "%q is not a host tool provider", name)
+		}
+	})
+
+	var tools android.Paths
+	for _, transform := range j.properties.Jar_transforms {
+		if path, ok := toolPaths[android.SrcIsModule(transform)]; ok {
+			tools = append(tools, path)
+		}
+	}
+	return tools
+}
+
+// transformJar combines the jars compiled from the sources of this module, runs the
+// jar_transforms tools on them one after the other and returns the output of the last one.
+func (j *Module) transformJar(ctx android.ModuleContext, compiledJars android.Paths,
+	jarName string) android.Path {
+
+	classesJar := compiledJars[0]
+	if len(compiledJars) > 1 {
+		combinedJar := android.PathForModuleOut(ctx, "jar-transforms", "compiled", jarName)
+		TransformJarsToJar(ctx, combinedJar, "for jar transforms", compiledJars, android.OptionalPath{},
+			false, nil, nil)
+		classesJar = combinedJar
+	}
+
+	for i, tool := range j.jarTransformTools(ctx) {
+		transformedJar := android.PathForModuleOut(ctx, "jar-transforms", strconv.Itoa(i), jarName)
+
+		rule := android.NewRuleBuilder(pctx, ctx)
+		rule.Command().
+			Tool(tool).
+			Input(classesJar).
+			Output(transformedJar)
+		rule.Build("jar_transform_"+strconv.Itoa(i), "jar transform "+tool.Base())
+
+		classesJar = transformedJar
+	}
+	return classesJar
+}
+
+// untransformedHeaderJar returns a header jar built from the jars of the module before
+// jar_transforms were applied, for modules that use their implementation jar as the header jar.
+func (j *Module) untransformedHeaderJar(ctx android.ModuleContext, jars android.Paths,
+	jarName string) android.Path {
+
+	headerJar := android.PathForModuleOut(ctx, "jar-transforms", "untransformed", jarName)
+	TransformJarsToJar(ctx, headerJar, "for untransformed header jar", jars, android.OptionalPath{},
+		false, nil, nil)
+	if j.expandJarjarRules != nil {
+		jarjarFile := android.PathForModuleOut(ctx, "jar-transforms", "untransformed-jarjar", jarName)
+		TransformJarJar(ctx, jarjarFile, headerJar, j.expandJarjarRules)
+		return jarjarFile
+	}
+	return headerJar
+}
+
 func (j *Module) instrument(ctx android.ModuleContext, flags javaBuilderFlags,
 	classesJar android.Path, jarName string, specs string) android.OutputPath {
 
@@ -1857,11 +2028,37 @@ func (j *Module) checkSdkLinkType(
 	}
 }
 
+// isPlatformImplLibrary returns true if the module is a java library that is only available to the
+// platform and whose jars contain its implementation rather than the API stubs of a
+// java_sdk_library.
+func isPlatformImplLibrary(module android.Module) bool {
+	if stubs, ok := module.(interface{ sdkLibraryStubs() bool }); ok && stubs.sdkLibraryStubs() {
+		return false
+	}
+	am, ok := module.(interface{ ApexAvailable() []string })
+	if !ok {
+		return false
+	}
+	for _, available := range am.ApexAvailable() {
+		if available != android.AvailableToPlatform {
+			return false
+		}
+	}
+	return true
+}
+
 func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 	var deps deps
+	j.platformImplLibraryChains = nil
 
+	// The libraries on the classpath of the SDK are its stubs, unless the module is built against
+	// the platform private APIs.
+	var sdkClasspath []string
 	if ctx.Device() {
 		sdkDep := decodeSdkDep(ctx, android.SdkContext(j))
+		if j.SdkVersion(ctx).Kind != android.SdkPrivate {
+			sdkClasspath = sdkDep.classpath
+		}
 		if sdkDep.invalidVersion {
 			ctx.AddMissingDependencies(sdkDep.bootclasspath)
 			ctx.AddMissingDependencies(sdkDep.java9Classpath)
@@ -1913,6 +2110,10 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 			case bootClasspathTag:
 				deps.bootClasspath = append(deps.bootClasspath, dep.HeaderJars...)
 			case libTag, instrumentationForTag:
+				if tag == libTag && !android.InList(otherName, sdkClasspath) && isPlatformImplLibrary(module) {
+					j.platformImplLibraryChains = append(j.platformImplLibraryChains,
+						[]string{ctx.ModuleName(), otherName})
+				}
 				deps.classpath = append(deps.classpath, dep.HeaderJars...)
 				deps.dexClasspath = append(deps.dexClasspath, dep.HeaderJars...)
 				deps.aidlIncludeDirs = append(deps.aidlIncludeDirs, dep.AidlIncludeDirs...)
@@ -1931,6 +2132,16 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 				// annotation processor that generates API is incompatible with the turbine
 				// optimization.
 				deps.disableTurbine = deps.disableTurbine || dep.ExportedPluginDisableTurbine
+				// The classes of a static library are merged into this module, so any module
+				// that depends on this module is also incompatible with the turbine optimization.
+				// The flag is propagated through the JavaInfoProvider, so it is only computed
+				// once per module however deep the chain of static libraries is.
+				j.exportedDisableTurbine = j.exportedDisableTurbine || dep.ExportedPluginDisableTurbine
+				implInfo := ctx.OtherModuleProvider(module, PlatformImplLibrariesInfoProvider).(PlatformImplLibrariesInfo)
+				for _, chain := range implInfo.DependencyChains {
+					j.platformImplLibraryChains = append(j.platformImplLibraryChains,
+						append([]string{ctx.ModuleName()}, chain...))
+				}
 			case pluginTag:
 				if plugin, ok := module.(*Plugin); ok {
 					if plugin.pluginProperties.Processor_class != nil {
@@ -1946,10 +2157,11 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 					ctx.PropertyErrorf("plugins", "%q is not a java_plugin module", otherName)
 				}
 			case errorpronePluginTag:
-				if _, ok := module.(*Plugin); ok {
+				switch module.(type) {
+				case *Plugin, *Library:
 					deps.errorProneProcessorPath = append(deps.errorProneProcessorPath, dep.ImplementationAndResourcesJars...)
-				} else {
-					ctx.PropertyErrorf("plugins", "%q is not a java_plugin module", otherName)
+				default:
+					ctx.PropertyErrorf("errorprone.extra_check_modules", "%q is not a java_plugin or java_library module", otherName)
 				}
 			case exportedPluginTag:
 				if plugin, ok := module.(*Plugin); ok {
@@ -1960,7 +2172,7 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 					// Turbine doesn't run annotation processors, so any module that uses an
 					// annotation processor that generates API is incompatible with the turbine
 					// optimization.
-					j.exportedDisableTurbine = Bool(plugin.pluginProperties.Generates_api)
+					j.exportedDisableTurbine = j.exportedDisableTurbine || Bool(plugin.pluginProperties.Generates_api)
 				} else {
 					ctx.PropertyErrorf("exported_plugins", "%q is not a java_plugin module", otherName)
 				}
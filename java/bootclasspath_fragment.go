@@ -43,6 +43,7 @@ func init() {
 func registerBootclasspathFragmentBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("bootclasspath_fragment", bootclasspathFragmentFactory)
 	ctx.RegisterModuleType("prebuilt_bootclasspath_fragment", prebuiltBootclasspathFragmentFactory)
+	ctx.RegisterSingletonType("bootclasspath_fragment_extra_contents", bootclasspathFragmentExtraContentsSingletonFactory)
 }
 
 type bootclasspathFragmentContentDependencyTag struct {
@@ -134,10 +135,23 @@ type bootclasspathFragmentProperties struct {
 	// stubs are needed and so on.
 	Additional_stubs []string
 
+	// The list of modules from contents whose sdk_version and min_sdk_version are allowed to be
+	// inconsistent with the apex that contains this fragment.
+	//
+	// This is a temporary measure to allow existing inconsistencies to be fixed and no new modules
+	// should be added to it.
+	Sdk_version_check_allowlist []string
+
 	// Properties that allow a fragment to depend on other fragments. This is needed for hidden API
 	// processing as it needs access to all the classes used by a fragment including those provided
 	// by other fragments.
 	BootclasspathFragmentsDepsProperties
+
+	// Additional entries for the boot image profile, in the same text format as
+	// boot-image-profile.txt. They are merged into the profile of the boot image built by this
+	// fragment, if any, and into the profile of the platform boot image. Every class they reference
+	// must be in the boot jars.
+	Boot_image_profile_fragments []string `android:"path"`
 }
 
 type HiddenApiPackageProperties struct {
@@ -238,6 +252,10 @@ type BootclasspathFragmentModule struct {
 	// handled by Make (e.g., the boot image should be installed on the system partition, rather than
 	// in the APEX).
 	bootImageDeviceInstalls []dexpreopterInstall
+
+	// True if the boot image profile fragments were verified against the boot image built by this
+	// module.
+	bootImageProfileFragmentsVerified bool
 }
 
 // commonBootclasspathFragment defines the methods that are implemented by both source and prebuilt
@@ -292,12 +310,85 @@ func bootclasspathFragmentFactory() android.Module {
 			}
 		}
 
+		// Append any contents added by the product configuration.
+		bootclasspathFragmentAppendExtraContents(ctx, m)
+
 		// Initialize the contents property from the image_name.
 		bootclasspathFragmentInitContentsFromImage(ctx, m)
 	})
 	return m
 }
 
+// bootclasspathFragmentAppendExtraContents appends the jars that the product configuration adds to
+// this module through PRODUCT_BOOTCLASSPATH_FRAGMENT_EXTRA_CONTENTS to its contents property.
+//
+// The added jars are treated exactly like the ones listed in the Android.bp file so they are subject
+// to the same hidden API and package checks, and must also be listed in PRODUCT_APEX_BOOT_JARS.
+func bootclasspathFragmentAppendExtraContents(ctx android.LoadHookContext, m *BootclasspathFragmentModule) {
+	if !ctx.Config().AllowBootclasspathFragmentExtraContents() {
+		// An error is reported by bootclasspathFragmentExtraContentsSingleton if the product
+		// configuration tries to add contents anyway.
+		return
+	}
+
+	extraContents, ok := ctx.Config().BootclasspathFragmentExtraContents()[ctx.ModuleName()]
+	if !ok {
+		return
+	}
+
+	if m.properties.Image_name != nil {
+		ctx.ModuleErrorf("PRODUCT_BOOTCLASSPATH_FRAGMENT_EXTRA_CONTENTS cannot add contents to a bootclasspath_fragment with an image_name")
+		return
+	}
+
+	for i := 0; i < extraContents.Len(); i++ {
+		apex := extraContents.Apex(i)
+		jar := extraContents.Jar(i)
+		if !m.AvailableFor(apex) {
+			ctx.ModuleErrorf("PRODUCT_BOOTCLASSPATH_FRAGMENT_EXTRA_CONTENTS adds %q in apex %q but this is only in apexes %q",
+				jar, apex, m.ApexAvailable())
+			continue
+		}
+		if !android.InList(jar, m.properties.Contents) {
+			m.properties.Contents = append(m.properties.Contents, jar)
+		}
+	}
+}
+
+func bootclasspathFragmentExtraContentsSingletonFactory() android.Singleton {
+	return &bootclasspathFragmentExtraContentsSingleton{}
+}
+
+// bootclasspathFragmentExtraContentsSingleton checks that the product configuration only adds
+// contents to bootclasspath_fragment modules when the board allows it and that all the
+// bootclasspath_fragment modules to which it adds contents exist.
+type bootclasspathFragmentExtraContentsSingleton struct{}
+
+func (bootclasspathFragmentExtraContentsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	extraContents := ctx.Config().BootclasspathFragmentExtraContents()
+	if len(extraContents) == 0 {
+		return
+	}
+
+	if !ctx.Config().AllowBootclasspathFragmentExtraContents() {
+		ctx.Errorf("PRODUCT_BOOTCLASSPATH_FRAGMENT_EXTRA_CONTENTS is set but BOARD_ALLOW_BOOTCLASSPATH_FRAGMENT_EXTRA_CONTENTS is not true")
+		return
+	}
+
+	fragments := make(map[string]bool)
+	ctx.VisitAllModules(func(module android.Module) {
+		if _, ok := module.(*BootclasspathFragmentModule); ok {
+			fragments[ctx.ModuleName(module)] = true
+		}
+	})
+
+	for _, name := range android.SortedStringKeys(extraContents) {
+		if !fragments[name] {
+			ctx.Errorf("PRODUCT_BOOTCLASSPATH_FRAGMENT_EXTRA_CONTENTS adds contents to bootclasspath_fragment %q which does not exist", name)
+		}
+	}
+}
+
 // bootclasspathFragmentInitContentsFromImage will initialize the contents property from the image_name if
 // necessary.
 func bootclasspathFragmentInitContentsFromImage(ctx android.EarlyModuleContext, m *BootclasspathFragmentModule) {
@@ -400,6 +491,80 @@ func (b *BootclasspathFragmentModule) bootclasspathImageNameContentsConsistencyC
 	}
 }
 
+// bootclasspathFragmentContentsAllowedSdkVersions is the set of sdk_version values that may be used
+// by the contents of a bootclasspath_fragment that is part of an updatable apex.
+var bootclasspathFragmentContentsAllowedSdkVersions = []string{
+	"none",
+	"core_platform",
+	"core_current",
+	"current",
+	"system_current",
+	"module_current",
+}
+
+// isAllowedBootclasspathFragmentContentsSdkVersion returns true if sdkVersion is one of
+// bootclasspathFragmentContentsAllowedSdkVersions.
+func isAllowedBootclasspathFragmentContentsSdkVersion(sdkVersion android.SdkSpec) bool {
+	switch sdkVersion.Kind {
+	case android.SdkNone, android.SdkCorePlatform:
+		return true
+	case android.SdkCore, android.SdkPublic, android.SdkSystem, android.SdkModule:
+		return sdkVersion.ApiLevel.IsCurrent()
+	default:
+		return false
+	}
+}
+
+// checkContentsSdkVersions checks that the contents of a bootclasspath_fragment in an updatable apex
+// are built against an API surface that is suitable for updatable code and have the same
+// min_sdk_version as the apex. Otherwise, the problem will only be found when the contents are
+// verified on a device running an older release.
+func (b *BootclasspathFragmentModule) checkContentsSdkVersions(ctx android.ModuleContext, contents []android.Module) {
+	apexInfo := ctx.Provider(android.ApexInfoProvider).(android.ApexInfo)
+	if apexInfo.IsForPlatform() || !apexInfo.Updatable {
+		return
+	}
+
+	for _, content := range contents {
+		name := android.RemoveOptionalPrebuiltPrefix(ctx.OtherModuleName(content))
+		if android.InList(name, b.properties.Sdk_version_check_allowlist) {
+			continue
+		}
+
+		sdkContext, ok := content.(android.SdkContext)
+		if !ok {
+			continue
+		}
+
+		// An unset sdk_version is the default for modules that are built from source in the
+		// platform so it is not checked here.
+		sdkVersion := sdkContext.SdkVersion(ctx)
+		if sdkVersion.Kind != android.SdkPrivate && !isAllowedBootclasspathFragmentContentsSdkVersion(sdkVersion) {
+			ctx.ModuleErrorf("contents module %q has sdk_version %q but must use one of %q",
+				name, sdkVersion.Raw, bootclasspathFragmentContentsAllowedSdkVersions)
+		}
+
+		minSdkVersion := sdkContext.MinSdkVersion(ctx).ApiLevel
+		if !minSdkVersion.EqualTo(apexInfo.MinSdkVersion) {
+			ctx.ModuleErrorf("contents module %q has min_sdk_version %s but the apex containing this fragment has min_sdk_version %s",
+				name, minSdkVersion, apexInfo.MinSdkVersion)
+		}
+	}
+}
+
+// BootImageProfileFragmentsInfo contains the boot image profile fragments provided by a
+// bootclasspath_fragment.
+type BootImageProfileFragmentsInfo struct {
+	// The paths to the profile fragments.
+	ProfileFragments android.Paths
+
+	// True if the profile fragments were verified against the boot image of the
+	// bootclasspath_fragment, otherwise they have to be verified against the platform boot image.
+	Verified bool
+}
+
+var BootImageProfileFragmentsInfoProvider = blueprint.NewProvider(BootImageProfileFragmentsInfo{})
+
 var BootclasspathFragmentApexContentInfoProvider = blueprint.NewProvider(BootclasspathFragmentApexContentInfo{})
 
 // BootclasspathFragmentApexContentInfo contains the bootclasspath_fragments contributions to the
@@ -557,6 +722,12 @@ func (b *BootclasspathFragmentModule) GenerateAndroidBuildActions(ctx android.Mo
 
 	fragments := gatherApexModulePairDepsWithTag(ctx, bootclasspathFragmentDepTag)
 
+	// A prebuilt fragment's contents come from a prebuilt apex that has already been built so it is
+	// too late to check them.
+	if isActiveModule(ctx.Module()) && !android.IsModulePrebuilt(ctx.Module()) {
+		b.checkContentsSdkVersions(ctx, contents)
+	}
+
 	// Verify that the image_name specified on a bootclasspath_fragment is valid even if this is a
 	// prebuilt which will not use the image config.
 	imageConfig := b.getImageConfig(ctx)
@@ -613,6 +784,12 @@ func (b *BootclasspathFragmentModule) GenerateAndroidBuildActions(ctx android.Mo
 		b.HideFromMake()
 	}
 
+	// Make the boot image profile fragments available to the platform_bootclasspath.
+	ctx.SetProvider(BootImageProfileFragmentsInfoProvider, BootImageProfileFragmentsInfo{
+		ProfileFragments: b.bootImageProfileFragments(ctx),
+		Verified:         b.bootImageProfileFragmentsVerified,
+	})
+
 	// In order for information about bootclasspath_fragment modules to be added to module-info.json
 	// it is necessary to output an entry to Make. As bootclasspath_fragment modules are part of an
 	// APEX there can be multiple variants, including the default/platform variant and only one can
@@ -852,6 +1029,12 @@ func (b *BootclasspathFragmentModule) produceBootImageFiles(ctx android.ModuleCo
 	return b.generateBootImageBuildActions(ctx, imageConfig)
 }
 
+// bootImageProfileFragments returns the paths to the boot image profile fragments provided by this
+// module.
+func (b *BootclasspathFragmentModule) bootImageProfileFragments(ctx android.ModuleContext) android.Paths {
+	return android.PathsForModuleSrc(ctx, b.properties.Boot_image_profile_fragments)
+}
+
 // generateBootImageBuildActions generates ninja rules to create the boot image if required for this
 // module.
 //
@@ -875,7 +1058,8 @@ func (b *BootclasspathFragmentModule) generateBootImageBuildActions(ctx android.
 	}
 
 	// Build a profile for the image config and then use that to build the boot image.
-	profile := bootImageProfileRule(ctx, imageConfig)
+	profile := bootImageProfileRule(ctx, imageConfig, b.bootImageProfileFragments(ctx), nil)
+	b.bootImageProfileFragmentsVerified = profile != nil
 
 	// Build boot image files for the host variants.
 	buildBootImageVariantsForBuildOs(ctx, imageConfig, profile)
@@ -19,6 +19,8 @@ import (
 
 	"android/soong/android"
 	"android/soong/dexpreopt"
+
+	"github.com/google/blueprint/proptools"
 )
 
 // Contains some simple tests for bootclasspath_fragment logic, additional tests can be found in
@@ -278,3 +280,112 @@ func TestBootclasspathFragment_StubLibs(t *testing.T) {
 
 	android.AssertPathsRelativeToTopEquals(t, "widest dex stubs jar", expectedWidestPaths, info.TransitiveStubDexJarsByScope.StubDexJarsForWidestAPIScope())
 }
+
+func TestBootclasspathFragment_ExtraContents(t *testing.T) {
+	prepareWithBp := android.FixtureWithRootAndroidBp(`
+		bootclasspath_fragment {
+			name: "myfragment",
+			contents: [
+				"mybootlib",
+			],
+			apex_available: [
+				"someapex",
+			],
+		}
+
+		java_library {
+			name: "mybootlib",
+			srcs: ["Test.java"],
+			system_modules: "none",
+			sdk_version: "none",
+			compile_dex: true,
+		}
+
+		java_library {
+			name: "myextralib",
+			srcs: ["Test.java"],
+			system_modules: "none",
+			sdk_version: "none",
+			compile_dex: true,
+		}
+	`)
+
+	fixtureSetExtraContents := func(allow bool, extraContents map[string][]string) android.FixturePreparer {
+		return android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.BoardAllowBootclasspathFragmentExtraContents = proptools.BoolPtr(allow)
+			variables.BootclasspathFragmentExtraContents = make(map[string]android.ConfiguredJarList)
+			for name, jars := range extraContents {
+				variables.BootclasspathFragmentExtraContents[name] = android.CreateTestConfiguredJarList(jars)
+			}
+		})
+	}
+
+	checkContents := func(t *testing.T, result *android.TestResult, expected ...string) {
+		module := result.Module("myfragment", "android_common").(*BootclasspathFragmentModule)
+		android.AssertArrayString(t, "contents property", expected, module.properties.Contents)
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			prepareForTestWithBootclasspathFragment,
+			FixtureConfigureApexBootJars("someapex:mybootlib", "someapex:myextralib"),
+			fixtureSetExtraContents(true, map[string][]string{
+				"myfragment": {"someapex:myextralib"},
+			}),
+			prepareWithBp,
+		).RunTest(t)
+		checkContents(t, result, "mybootlib", "myextralib")
+	})
+
+	t.Run("not allowed by board", func(t *testing.T) {
+		android.GroupFixturePreparers(
+			prepareForTestWithBootclasspathFragment,
+			FixtureConfigureApexBootJars("someapex:mybootlib", "someapex:myextralib"),
+			fixtureSetExtraContents(false, map[string][]string{
+				"myfragment": {"someapex:myextralib"},
+			}),
+			prepareWithBp,
+		).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`\QPRODUCT_BOOTCLASSPATH_FRAGMENT_EXTRA_CONTENTS is set but BOARD_ALLOW_BOOTCLASSPATH_FRAGMENT_EXTRA_CONTENTS is not true\E`)).
+			RunTest(t)
+	})
+
+	t.Run("unknown fragment", func(t *testing.T) {
+		android.GroupFixturePreparers(
+			prepareForTestWithBootclasspathFragment,
+			FixtureConfigureApexBootJars("someapex:mybootlib", "someapex:myextralib"),
+			fixtureSetExtraContents(true, map[string][]string{
+				"otherfragment": {"someapex:myextralib"},
+			}),
+			prepareWithBp,
+		).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`\Qadds contents to bootclasspath_fragment "otherfragment" which does not exist\E`)).
+			RunTest(t)
+	})
+
+	t.Run("wrong apex", func(t *testing.T) {
+		android.GroupFixturePreparers(
+			prepareForTestWithBootclasspathFragment,
+			FixtureConfigureApexBootJars("someapex:mybootlib", "otherapex:myextralib"),
+			fixtureSetExtraContents(true, map[string][]string{
+				"myfragment": {"otherapex:myextralib"},
+			}),
+			prepareWithBp,
+		).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`\QPRODUCT_BOOTCLASSPATH_FRAGMENT_EXTRA_CONTENTS adds "myextralib" in apex "otherapex" but this is only in apexes ["someapex"]\E`)).
+			RunTest(t)
+	})
+
+	t.Run("missing from apex boot jars", func(t *testing.T) {
+		android.GroupFixturePreparers(
+			prepareForTestWithBootclasspathFragment,
+			FixtureConfigureApexBootJars("someapex:mybootlib"),
+			fixtureSetExtraContents(true, map[string][]string{
+				"myfragment": {"someapex:myextralib"},
+			}),
+			prepareWithBp,
+		).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`\Q[myextralib] in contents must also be declared in PRODUCT_APEX_BOOT_JARS\E`)).
+			RunTest(t)
+	})
+}
@@ -122,12 +122,14 @@ var (
 			Command: `rm -rf "$out" && ` +
 				`${config.ExtractApksCmd} -o "${out}" -zip "${zip}" -allow-prereleased=${allow-prereleased} ` +
 				`-sdk-version=${sdk-version} -abis=${abis} ` +
-				`--screen-densities=${screen-densities} --stem=${stem} ` +
+				`--screen-densities=${screen-densities} --locales=${locales} --stem=${stem} ` +
+				`-require-abi-split=${require-abi-split} ` +
 				`-apkcerts=${apkcerts} -partition=${partition} ` +
 				`${in}`,
 			CommandDeps: []string{"${config.ExtractApksCmd}"},
 		},
-		"abis", "allow-prereleased", "screen-densities", "sdk-version", "stem", "apkcerts", "partition", "zip")
+		"abis", "allow-prereleased", "screen-densities", "locales", "require-abi-split", "sdk-version", "stem",
+		"apkcerts", "partition", "zip")
 
 	turbine, turbineRE = pctx.RemoteStaticRules("turbine",
 		blueprint.RuleParams{
@@ -271,6 +273,10 @@ type javaBuilderFlags struct {
 	aidlDeps      android.Paths
 	javaVersion   javaVersion
 
+	// processorOptions is the list of key=value options passed to the annotation processors,
+	// which are also passed to javac as -Akey=value.
+	processorOptions []string
+
 	errorProneExtraJavacFlags string
 	errorProneProcessorPath   classpath
 
@@ -580,6 +586,30 @@ func GenerateMainClassManifest(ctx android.ModuleContext, outputFile android.Wri
 	android.WriteFileRule(ctx, outputFile, "Main-Class: "+mainClass+"\n")
 }
 
+// GenerateClassPathManifest writes a jar manifest with a Class-Path attribute listing classPath,
+// which are paths relative to the directory of the jar.
+func GenerateClassPathManifest(ctx android.ModuleContext, outputFile android.WritablePath, classPath []string) {
+	android.WriteFileRule(ctx, outputFile, wrapManifestLine("Class-Path: "+strings.Join(classPath, " ")))
+}
+
+// wrapManifestLine splits a manifest line into lines of at most 72 bytes, the limit from the jar
+// file specification, using continuation lines that start with a space.
+func wrapManifestLine(line string) string {
+	const maxLineLength = 72
+	var sb strings.Builder
+	limit := maxLineLength
+	for len(line) > limit {
+		sb.WriteString(line[:limit])
+		sb.WriteString("\n ")
+		line = line[limit:]
+		// Continuation lines lose a byte to the leading space.
+		limit = maxLineLength - 1
+	}
+	sb.WriteString(line)
+	sb.WriteString("\n")
+	return sb.String()
+}
+
 func TransformZipAlign(ctx android.ModuleContext, outputFile android.WritablePath, inputFile android.Path) {
 	ctx.Build(pctx, android.BuildParams{
 		Rule:        zipalign,
@@ -16,6 +16,7 @@ package java
 
 import (
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"android/soong/android"
@@ -782,7 +783,14 @@ const failureMessage = `ERROR: Dex2oat failed to compile a boot image.
 It is likely that the boot classpath is inconsistent.
 Rebuild with ART_BOOT_IMAGE_EXTRA_ARGS="--runtime-arg -verbose:verifier" to see verification errors.`
 
-func bootImageProfileRule(ctx android.ModuleContext, image *bootImageConfig) android.WritablePath {
+// bootImageProfileRule generates the rule to create the boot image profile from the profiles in
+// the global config, or the default profile, merged with the supplied profile fragments and returns
+// a path to the generated file.
+//
+// The fragments in ownFragments are verified to only reference classes in this image, the ones in
+// otherFragments were already verified against the image of the bootclasspath_fragment that
+// provides them.
+func bootImageProfileRule(ctx android.ModuleContext, image *bootImageConfig, ownFragments, otherFragments android.Paths) android.WritablePath {
 	globalSoong := dexpreopt.GetGlobalSoongConfig(ctx)
 	global := dexpreopt.GetGlobalConfig(ctx)
 
@@ -794,32 +802,100 @@ func bootImageProfileRule(ctx android.ModuleContext, image *bootImageConfig) and
 
 	rule := android.NewRuleBuilder(pctx, ctx)
 
-	var bootImageProfile android.Path
-	if len(global.BootImageProfiles) > 1 {
-		combinedBootImageProfile := image.dir.Join(ctx, "boot-image-profile.txt")
-		rule.Command().Text("cat").Inputs(global.BootImageProfiles).Text(">").Output(combinedBootImageProfile)
-		bootImageProfile = combinedBootImageProfile
-	} else if len(global.BootImageProfiles) == 1 {
-		bootImageProfile = global.BootImageProfiles[0]
+	var bootImageProfiles android.Paths
+	if len(global.BootImageProfiles) > 0 {
+		bootImageProfiles = global.BootImageProfiles
 	} else if path := android.ExistentPathForSource(ctx, defaultProfile); path.Valid() {
-		bootImageProfile = path.Path()
-	} else {
+		bootImageProfiles = android.Paths{path.Path()}
+	}
+
+	ownFragments = android.FirstUniquePaths(ownFragments)
+	otherFragments = android.FirstUniquePaths(otherFragments)
+
+	var allProfiles android.Paths
+	allProfiles = append(allProfiles, bootImageProfiles...)
+	allProfiles = append(allProfiles, ownFragments...)
+	allProfiles = append(allProfiles, otherFragments...)
+	allProfiles = android.FirstUniquePaths(allProfiles)
+
+	if len(allProfiles) == 0 {
 		// No profile (not even a default one, which is the case on some branches
 		// like master-art-host that don't have frameworks/base).
 		// Return nil and continue without profile.
 		return nil
 	}
 
+	// profmanCmd returns a command that runs profman against the dex files of the image.
+	profmanCmd := func() *android.RuleBuilderCommand {
+		return rule.Command().
+			Text(`ANDROID_LOG_TAGS="*:e"`).
+			Tool(globalSoong.Profman).
+			Flag("--output-profile-type=boot").
+			FlagForEachInput("--apk=", image.dexPathsDeps.Paths()).
+			FlagForEachArg("--dex-location=", image.getAnyAndroidVariant().dexLocationsDeps)
+	}
+
+	// Make sure that every class referenced by the fragments of this image is in its boot jars,
+	// otherwise the entries would silently be ignored by profman.
+	for i, fragment := range ownFragments {
+		fragmentProfile := image.dir.Join(ctx, "boot_profile_fragments", strconv.Itoa(i)+".prof")
+		profmanCmd().
+			FlagWithInput("--create-profile-from=", fragment).
+			FlagWithOutput("--reference-profile-file=", fragmentProfile)
+		rule.Temporary(fragmentProfile)
+
+		// The classes that profman resolved against the dex files, and the classes the fragment
+		// references, as sorted lists of class descriptors.
+		resolvedClasses := image.dir.Join(ctx, "boot_profile_fragments", strconv.Itoa(i)+".resolved")
+		referencedClasses := image.dir.Join(ctx, "boot_profile_fragments", strconv.Itoa(i)+".referenced")
+		profmanCmd().
+			Flag("--dump-classes-and-methods").
+			FlagWithInput("--profile-file=", fragmentProfile).
+			Text(`| sed -n 's/^\(L[^;]*;\).*/\1/p' | sort -u >`).Output(resolvedClasses)
+		rule.Command().Text(`sed -n 's/^[HSP]*\(L[^;]*;\).*/\1/p'`).Input(fragment).
+			Text("| sort -u >").Output(referencedClasses)
+		rule.Temporary(resolvedClasses)
+		rule.Temporary(referencedClasses)
+
+		rule.Command().
+			Text("missing=$(comm -23").Input(referencedClasses).Input(resolvedClasses).Text(");").
+			Text(`if [ -n "${missing}" ]; then echo "error: boot image profile fragment`).Input(fragment).
+			Textf(`references classes that are not in the jars of boot image %s:" >&2; echo "${missing}" >&2; exit 1; fi`, image.name)
+	}
+
 	profile := image.dir.Join(ctx, "boot.prof")
 
-	rule.Command().
-		Text(`ANDROID_LOG_TAGS="*:e"`).
-		Tool(globalSoong.Profman).
-		Flag("--output-profile-type=boot").
-		FlagWithInput("--create-profile-from=", bootImageProfile).
-		FlagForEachInput("--apk=", image.dexPathsDeps.Paths()).
-		FlagForEachArg("--dex-location=", image.getAnyAndroidVariant().dexLocationsDeps).
-		FlagWithOutput("--reference-profile-file=", profile)
+	if len(allProfiles) > 1 && len(ownFragments) == 0 && len(otherFragments) == 0 {
+		// Without fragments the profiles from the global config are concatenated as a single text
+		// profile.
+		combinedBootImageProfile := image.dir.Join(ctx, "boot-image-profile.txt")
+		rule.Command().Text("cat").Inputs(allProfiles).Text(">").Output(combinedBootImageProfile)
+		allProfiles = android.Paths{combinedBootImageProfile}
+	}
+
+	if len(allProfiles) == 1 {
+		profmanCmd().
+			FlagWithInput("--create-profile-from=", allProfiles[0]).
+			FlagWithOutput("--reference-profile-file=", profile)
+	} else {
+		// Convert each text profile to a binary profile and merge them with profman.
+		var binaryProfiles android.Paths
+		for i, textProfile := range allProfiles {
+			binaryProfile := image.dir.Join(ctx, "boot_profiles", strconv.Itoa(i)+".prof")
+			profmanCmd().
+				FlagWithInput("--create-profile-from=", textProfile).
+				FlagWithOutput("--reference-profile-file=", binaryProfile)
+			rule.Temporary(binaryProfile)
+			binaryProfiles = append(binaryProfiles, binaryProfile)
+		}
+
+		rule.Command().Text("rm -f").Output(profile)
+		profmanCmd().
+			Flag("--force-merge").
+			FlagForEachInput("--profile-file=", binaryProfiles).
+			FlagWithOutput("--reference-profile-file=", profile)
+	}
+	rule.DeleteTemporaryFiles()
 
 	if image == defaultBootImageConfig(ctx) {
 		rule.Install(profile, "/system/etc/boot-image.prof")
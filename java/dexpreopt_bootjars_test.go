@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"android/soong/android"
+	"android/soong/dexpreopt"
 )
 
 func testDexpreoptBoot(t *testing.T, ruleFile string, expectedInputs, expectedOutputs []string) {
@@ -128,3 +129,89 @@ func TestDexpreoptBootZip(t *testing.T) {
 
 	testDexpreoptBoot(t, ruleFile, expectedInputs, expectedOutputs)
 }
+
+func TestBootImageProfileFragments(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		PrepareForTestWithJavaSdkLibraryFiles,
+		FixtureWithLastReleaseApis("foo"),
+		FixtureConfigureBootJars("platform:foo", "platform:bar"),
+		dexpreopt.FixtureSetBootImageProfiles("frameworks/base/config/boot-image-profile.txt"),
+		// The duplicate fragment must only be merged once.
+		dexpreopt.FixtureSetBootImageProfileFragments(
+			"device/sample/boot-image-profile-fragment.txt",
+			"device/sample/boot-image-profile-fragment.txt",
+		),
+	).RunTestWithBp(t, `
+		java_sdk_library {
+			name: "foo",
+			srcs: ["a.java"],
+			api_packages: ["foo"],
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			installable: true,
+		}
+
+		platform_bootclasspath {
+			name: "platform-bootclasspath",
+		}
+	`)
+
+	platformBootclasspath := result.ModuleForTests("platform-bootclasspath", "android_common")
+	rule := platformBootclasspath.Output("boot.prof")
+	command := android.StringRelativeToTop(result.Config, rule.RuleParams.Command)
+
+	android.AssertStringDoesContain(t, "verify fragments", command,
+		"boot image profile fragment device/sample/boot-image-profile-fragment.txt references classes that are not in the jars of boot image boot")
+	android.AssertStringDoesContain(t, "verify against foo", command, "--apk=out/soong/test_device/dex_bootjars_input/foo.jar")
+	android.AssertStringDoesContain(t, "verify against bar", command, "--apk=out/soong/test_device/dex_bootjars_input/bar.jar")
+	android.AssertStringDoesContain(t, "convert profile", command,
+		"--create-profile-from=frameworks/base/config/boot-image-profile.txt"+
+			" --reference-profile-file=out/soong/test_device/dex_bootjars/boot_profiles/0.prof")
+	android.AssertStringDoesContain(t, "merge profiles", command,
+		"--force-merge --profile-file=out/soong/test_device/dex_bootjars/boot_profiles/0.prof"+
+			" --profile-file=out/soong/test_device/dex_bootjars/boot_profiles/1.prof"+
+			" --reference-profile-file=out/soong/test_device/dex_bootjars/boot.prof")
+	android.AssertStringDoesNotContain(t, "merge fragment once", command, "boot_profiles/2.prof")
+	android.AssertStringListContains(t, "fragment is an input",
+		rule.Implicits.Strings(), "device/sample/boot-image-profile-fragment.txt")
+}
+
+func TestBootImageProfilesWithoutFragments(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		PrepareForTestWithJavaSdkLibraryFiles,
+		FixtureWithLastReleaseApis("foo"),
+		FixtureConfigureBootJars("platform:foo"),
+		dexpreopt.FixtureSetBootImageProfiles(
+			"frameworks/base/config/boot-image-profile.txt",
+			"device/sample/boot-image-profile.txt",
+		),
+	).RunTestWithBp(t, `
+		java_sdk_library {
+			name: "foo",
+			srcs: ["a.java"],
+			api_packages: ["foo"],
+		}
+
+		platform_bootclasspath {
+			name: "platform-bootclasspath",
+		}
+	`)
+
+	platformBootclasspath := result.ModuleForTests("platform-bootclasspath", "android_common")
+	rule := platformBootclasspath.Output("boot.prof")
+	command := android.StringRelativeToTop(result.Config, rule.RuleParams.Command)
+
+	// Without fragments the text profiles are concatenated and converted once.
+	android.AssertStringDoesContain(t, "concatenate profiles", command,
+		"cat frameworks/base/config/boot-image-profile.txt device/sample/boot-image-profile.txt"+
+			" > out/soong/test_device/dex_bootjars/boot-image-profile.txt")
+	android.AssertStringDoesContain(t, "convert profile", command,
+		"--create-profile-from=out/soong/test_device/dex_bootjars/boot-image-profile.txt"+
+			" --reference-profile-file=out/soong/test_device/dex_bootjars/boot.prof")
+	android.AssertStringDoesNotContain(t, "merge profiles", command, "--force-merge")
+}
@@ -437,3 +437,95 @@ func TestAndroidMkEntriesForApex(t *testing.T) {
 
 	android.AssertIntEquals(t, "entries count", 0, len(entriesList))
 }
+
+func TestDexpreoptJavaImport(t *testing.T) {
+	preparer := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureAddFile("art-profile", nil),
+	)
+
+	testCases := []struct {
+		name             string
+		props            string
+		enabled          bool
+		profile          bool
+		installDirPrefix string
+	}{
+		{
+			name:    "not enabled",
+			props:   ``,
+			enabled: false,
+		},
+		{
+			name:             "plain",
+			props:            `dex_preopt: { enabled: true },`,
+			enabled:          true,
+			installDirPrefix: "/system/",
+		},
+		{
+			name:             "with profile",
+			props:            `dex_preopt: { enabled: true, profile: "art-profile" },`,
+			enabled:          true,
+			profile:          true,
+			installDirPrefix: "/system/",
+		},
+		{
+			name:             "system_ext",
+			props:            `dex_preopt: { enabled: true }, system_ext_specific: true,`,
+			enabled:          true,
+			installDirPrefix: "/system_ext/",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := preparer.RunTestWithBp(t, fmt.Sprintf(`
+				java_import {
+					name: "foo",
+					jars: ["a.jar"],
+					installable: true,
+					compile_dex: true,
+					%s
+				}`, tc.props))
+
+			foo := result.ModuleForTests("foo", "android_common")
+			dexpreopt := foo.MaybeRule("dexpreopt")
+			if enabled := dexpreopt.Rule != nil; enabled != tc.enabled {
+				t.Fatalf("want dexpreopt %s, got %s", enabledString(tc.enabled), enabledString(enabled))
+			}
+			if !tc.enabled {
+				return
+			}
+
+			android.AssertStringDoesContain(t, "dex2oat input", dexpreopt.RuleParams.Command,
+				"--dex-file=out/soong/.intermediates/foo/android_common/dex/foo.jar")
+			android.AssertStringContainsEquals(t, "profile", dexpreopt.RuleParams.Command,
+				"--create-profile-from=art-profile", tc.profile)
+
+			module := foo.Module().(*Import)
+			android.AssertBoolEquals(t, "uncompressed dex", true, module.dexpreopter.uncompressedDex)
+			for _, ext := range []string{"odex", "vdex"} {
+				android.AssertStringDoesContain(t, "built installed", module.dexpreopter.builtInstalled,
+					":"+tc.installDirPrefix+"framework/oat/arm64/foo."+ext)
+			}
+
+			entries := android.AndroidMkEntriesForTest(t, result.TestContext, module)[0]
+			android.AssertStringEquals(t, "LOCAL_SOONG_BUILT_INSTALLED",
+				module.dexpreopter.builtInstalled, entries.EntryMap["LOCAL_SOONG_BUILT_INSTALLED"][0])
+		})
+	}
+}
+
+func TestDexpreoptJavaImportWithoutCompileDex(t *testing.T) {
+	android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`dex_preopt.enabled: requires compile_dex: true`,
+	)).RunTestWithBp(t, `
+		java_import {
+			name: "foo",
+			jars: ["a.jar"],
+			installable: true,
+			dex_preopt: { enabled: true },
+		}`)
+}
@@ -151,7 +151,9 @@ type DroiddocProperties struct {
 	// filegroup or genrule can be included within this property.
 	Knowntags []string `android:"path"`
 
-	// if set to true, generate docs through Dokka instead of Doclava.
+	// if set to true, generate docs through Dokka instead of Doclava.  The Dokka tool is provided by
+	// the host module named "dokka", usually a prebuilt_build_tool, if it exists, otherwise the
+	// dokka built by make is used.  Kotlin srcs require the dokka module.
 	Dokka_enabled *bool
 
 	// Compat config XML. Generates compat change documentation if set.
@@ -314,7 +316,7 @@ func (j *Javadoc) genSources(ctx android.ModuleContext, srcFiles android.Paths,
 	outSrcFiles := make(android.Paths, 0, len(srcFiles))
 	var aidlSrcs android.Paths
 
-	aidlIncludeFlags := genAidlIncludeFlags(srcFiles)
+	aidlIncludeFlags := genAidlIncludeFlags(srcFiles, false)
 
 	for _, srcFile := range srcFiles {
 		switch srcFile.Ext() {
@@ -590,6 +592,31 @@ func (d *Droiddoc) DepsMutator(ctx android.BottomUpMutatorContext) {
 	if String(d.properties.Custom_template) != "" {
 		ctx.AddDependency(ctx.Module(), droiddocTemplateTag, String(d.properties.Custom_template))
 	}
+
+	// Whether the srcs contain Kotlin files is only known once they are resolved, so depend on the
+	// dokka module whenever it exists.
+	if Bool(d.properties.Dokka_enabled) && ctx.OtherModuleExists("dokka") {
+		ctx.AddFarVariationDependencies(ctx.Config().BuildOSTarget.Variations(), dokkaToolTag, "dokka")
+	}
+}
+
+// dokkaTool returns the path to the Dokka tool provided by the dokka host module, or nil if there
+// is no dependency on it.
+func (d *Droiddoc) dokkaTool(ctx android.ModuleContext) android.Path {
+	var dokka android.Path
+	ctx.VisitDirectDepsWithTag(dokkaToolTag, func(m android.Module) {
+		name := ctx.OtherModuleName(m)
+		if t, ok := m.(android.HostToolProvider); ok {
+			if path := t.HostToolPath(); path.Valid() {
+				dokka = path.Path()
+			} else {
+				ctx.ModuleErrorf("host tool %q missing output file", name)
+			}
+		} else {
+			ctx.ModuleErrorf("%q is not a host tool provider", name)
+		}
+	})
+	return dokka
 }
 
 func (d *Droiddoc) doclavaDocsFlags(ctx android.ModuleContext, cmd *android.RuleBuilderCommand, docletPath classpath) {
@@ -748,16 +775,25 @@ func javadocBootclasspathCmd(ctx android.ModuleContext, rule *android.RuleBuilde
 	return cmd
 }
 
-func dokkaCmd(ctx android.ModuleContext, rule *android.RuleBuilder,
+func dokkaCmd(ctx android.ModuleContext, rule *android.RuleBuilder, dokka android.Path, srcs android.Paths,
 	outDir, srcJarDir android.Path, bootclasspath, classpath classpath) *android.RuleBuilderCommand {
 
 	// Dokka doesn't support bootClasspath, so combine these two classpath vars for Dokka.
 	dokkaClasspath := append(bootclasspath.Paths(), classpath.Paths()...)
 
-	return rule.Command().
-		BuiltTool("dokka").
+	cmd := rule.Command()
+	if dokka != nil {
+		cmd.Tool(dokka)
+	} else {
+		cmd.BuiltTool("dokka")
+	}
+
+	// Unlike javadoc, Dokka handles both Java and Kotlin sources, so pass the srcs along with the
+	// extracted srcjars.
+	return cmd.
 		Flag(config.JavacVmFlags).
 		Flag(srcJarDir.String()).
+		Inputs(srcs).
 		FlagWithInputList("-classpath ", dokkaClasspath, ":").
 		FlagWithArg("-format ", "dac").
 		FlagWithArg("-dacRoot ", "/reference/kotlin").
@@ -781,7 +817,13 @@ func (d *Droiddoc) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 	var cmd *android.RuleBuilderCommand
 	if Bool(d.properties.Dokka_enabled) {
-		cmd = dokkaCmd(ctx, rule, outDir, srcJarDir, deps.bootClasspath, deps.classpath)
+		dokka := d.dokkaTool(ctx)
+		if kotlinSrcs := d.Javadoc.srcFiles.FilterByExt(".kt"); dokka == nil && len(kotlinSrcs) > 0 {
+			ctx.PropertyErrorf("dokka_enabled", "Kotlin srcs %s require the host module named \"dokka\"",
+				kotlinSrcs.Strings())
+			return
+		}
+		cmd = dokkaCmd(ctx, rule, dokka, d.Javadoc.srcFiles, outDir, srcJarDir, deps.bootClasspath, deps.classpath)
 	} else {
 		cmd = javadocBootclasspathCmd(ctx, rule, d.Javadoc.srcFiles, outDir, srcJarDir, srcJarList,
 			deps.bootClasspath, deps.classpath, d.Javadoc.sourcepaths)
@@ -828,6 +870,8 @@ func (d *Droiddoc) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 //
 var droiddocTemplateTag = dependencyTag{name: "droiddoc-template"}
 
+var dokkaToolTag = dependencyTag{name: "dokka-tool"}
+
 type ExportedDroiddocDirProperties struct {
 	// path to the directory containing Droiddoc related files.
 	Path *string
@@ -145,3 +145,91 @@ func TestDroiddocArgsAndFlagsCausesError(t *testing.T) {
 		}
 		`)
 }
+
+func TestDroiddocDokka(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.PrepareForTestWithPrebuiltBuildTool,
+		android.FixtureMergeMockFs(android.MockFS{
+			"prebuilts/dokka/dokka": nil,
+			"foo-doc/a.java":        nil,
+			"foo-doc/b.kt":          nil,
+		}),
+	).RunTestWithBp(t, `
+		prebuilt_build_tool {
+			name: "dokka",
+			src: "prebuilts/dokka/dokka",
+		}
+
+		droiddoc {
+			name: "foo-doc",
+			srcs: [
+				"foo-doc/a.java",
+				"foo-doc/b.kt",
+			],
+			dokka_enabled: true,
+		}
+	`)
+
+	fooDoc := result.ModuleForTests("foo-doc", "android_common")
+	javadoc := fooDoc.Rule("javadoc")
+	cmd := javadoc.RuleParams.Command
+
+	dokka := result.ModuleForTests("dokka", result.Config.BuildOSTarget.String()).Output("dokka")
+	android.AssertStringDoesContain(t, "dokka tool", cmd, android.PathRelativeToTop(dokka.Output)+" ")
+	android.AssertStringDoesNotContain(t, "doclava", cmd, "doclava.jar")
+	android.AssertStringDoesContain(t, "java srcs", cmd, " foo-doc/a.java ")
+	android.AssertStringDoesContain(t, "kotlin srcs", cmd, " foo-doc/b.kt ")
+	android.AssertStringDoesContain(t, "output", cmd, "-output out/soong/.intermediates/foo-doc/android_common/out")
+
+	// The docs zip is produced in the same place as for doclava.
+	android.AssertPathRelativeToTopEquals(t, "docs zip",
+		"out/soong/.intermediates/foo-doc/android_common/foo-doc-docs.zip", fooDoc.Output("foo-doc-docs.zip").Output)
+}
+
+func TestDroiddocDokkaJavaOnly(t *testing.T) {
+	// Without Kotlin srcs the dokka module, which isn't defined here, isn't required.
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"foo-doc/a.java": nil,
+		}),
+	).RunTestWithBp(t, `
+		droiddoc {
+			name: "foo-doc",
+			srcs: ["foo-doc/a.java"],
+			dokka_enabled: true,
+		}
+	`)
+
+	cmd := result.ModuleForTests("foo-doc", "android_common").Rule("javadoc").RuleParams.Command
+	android.AssertStringDoesContain(t, "dokka tool", cmd, "out/soong/host/linux-x86/bin/dokka ")
+	android.AssertStringDoesContain(t, "java srcs", cmd, " foo-doc/a.java ")
+}
+
+func TestDroiddocDokkaKotlinRequiresDokkaModule(t *testing.T) {
+	// The Kotlin srcs are found after the srcs are resolved, here through a filegroup.
+	android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"foo-doc/a.java": nil,
+			"foo-doc/b.kt":   nil,
+		}),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`dokka_enabled: Kotlin srcs \[foo-doc/b.kt\] require the host module named "dokka"`)).
+		RunTestWithBp(t, `
+		filegroup {
+			name: "kotlin-srcs",
+			srcs: ["foo-doc/b.kt"],
+		}
+
+		droiddoc {
+			name: "foo-doc",
+			srcs: [
+				"foo-doc/a.java",
+				":kotlin-srcs",
+			],
+			dokka_enabled: true,
+		}
+	`)
+}
@@ -257,7 +257,7 @@ func (d *Droidstubs) DepsMutator(ctx android.BottomUpMutatorContext) {
 	}
 }
 
-func (d *Droidstubs) stubsFlags(ctx android.ModuleContext, cmd *android.RuleBuilderCommand, stubsDir android.OptionalPath) {
+func (d *Droidstubs) stubsFlags(ctx android.ModuleContext, cmd *android.RuleBuilderCommand, stubsDir android.WritablePath) {
 	if apiCheckEnabled(ctx, d.properties.Check_api.Current, "current") ||
 		apiCheckEnabled(ctx, d.properties.Check_api.Last_released, "last_released") ||
 		String(d.properties.Api_filename) != "" {
@@ -287,11 +287,14 @@ func (d *Droidstubs) stubsFlags(ctx android.ModuleContext, cmd *android.RuleBuil
 		cmd.FlagWithArg("--sdk-values ", d.metadataDir.String())
 	}
 
-	if stubsDir.Valid() {
+	if stubsDir != nil {
+		// Metalava writes an unknown set of files into the stubs directory, let sbox capture the
+		// whole directory.
+		stubsFileList := android.PathForModuleOut(ctx, "metalava", "stubsDir.files")
 		if Bool(d.properties.Create_doc_stubs) {
-			cmd.FlagWithArg("--doc-stubs ", stubsDir.String())
+			cmd.Flag("--doc-stubs").DirOutput(stubsDir, stubsFileList)
 		} else {
-			cmd.FlagWithArg("--stubs ", stubsDir.String())
+			cmd.Flag("--stubs").DirOutput(stubsDir, stubsFileList)
 			if !Bool(d.properties.Output_javadoc_comments) {
 				cmd.Flag("--exclude-documentation-from-stubs")
 			}
@@ -509,12 +512,10 @@ func (d *Droidstubs) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 
 	generateStubs := BoolDefault(d.properties.Generate_stubs, true)
-	var stubsDir android.OptionalPath
+	var stubsDir android.WritablePath
 	if generateStubs {
 		d.Javadoc.stubsSrcJar = android.PathForModuleOut(ctx, "metalava", ctx.ModuleName()+"-"+"stubs.srcjar")
-		stubsDir = android.OptionalPathForPath(android.PathForModuleOut(ctx, "metalava", "stubsDir"))
-		rule.Command().Text("rm -rf").Text(stubsDir.String())
-		rule.Command().Text("mkdir -p").Text(stubsDir.String())
+		stubsDir = android.PathForModuleOut(ctx, "metalava", "stubsDir")
 	}
 
 	srcJarList := zipSyncCmd(ctx, rule, srcJarDir, d.Javadoc.srcJars)
@@ -639,13 +640,13 @@ func (d *Droidstubs) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 
 	if generateStubs {
-		rule.Command().
-			BuiltTool("soong_zip").
+		zipCmd := rule.Command()
+		zipCmd.BuiltTool("soong_zip").
 			Flag("-write_if_changed").
 			Flag("-jar").
 			FlagWithOutput("-o ", d.Javadoc.stubsSrcJar).
-			FlagWithArg("-C ", stubsDir.String()).
-			FlagWithArg("-D ", stubsDir.String())
+			FlagWithArg("-C ", zipCmd.PathForOutput(stubsDir)).
+			FlagWithArg("-D ", zipCmd.PathForOutput(stubsDir))
 	}
 
 	if Bool(d.properties.Write_sdk_values) {
@@ -182,6 +182,17 @@ func TestDroidstubsSandbox(t *testing.T) {
 	if g, w := manifest.Commands[0].GetCommand(), "reference __SBOX_SANDBOX_DIR__/out/.intermediates/foo/gen/foo.txt"; !strings.Contains(g, w) {
 		t.Errorf("Expected command to contain %q, got %q", w, g)
 	}
+
+	// The stubs directory is captured from the sandbox as a directory output.
+	if g, w := manifest.Commands[0].GetCommand(), "--stubs __SBOX_SANDBOX_DIR__/out/stubsDir"; !strings.Contains(g, w) {
+		t.Errorf("Expected command to contain %q, got %q", w, g)
+	}
+	outputDirs := manifest.Commands[0].GetOutputDirs()
+	if len(outputDirs) != 1 {
+		t.Fatalf("Expected one output dir, got %d", len(outputDirs))
+	}
+	android.AssertStringEquals(t, "output dir", "out/stubsDir", outputDirs[0].GetFrom())
+	android.AssertStringDoesContain(t, "file list", outputDirs[0].GetFileList(), "bar-stubs/android_common/metalava/stubsDir.files")
 }
 
 func TestDroidstubsWithSystemModules(t *testing.T) {
@@ -116,10 +116,13 @@ func genLogtags(ctx android.ModuleContext, logtagsFile android.Path) android.Pat
 	return javaFile
 }
 
-func genAidlIncludeFlags(srcFiles android.Paths) string {
+// genAidlIncludeFlags returns the -I flags for the root directories of the aidl srcs, i.e. the
+// directories that the srcs are relative to, such as the path of the filegroup that provides them.
+// If allSrcs is true the root directories of all the srcs are returned.
+func genAidlIncludeFlags(srcFiles android.Paths, allSrcs bool) string {
 	var baseDirs []string
 	for _, srcFile := range srcFiles {
-		if srcFile.Ext() == ".aidl" {
+		if srcFile.Ext() == ".aidl" || allSrcs {
 			baseDir := strings.TrimSuffix(srcFile.String(), srcFile.Rel())
 			if baseDir != "" && !android.InList(baseDir, baseDirs) {
 				baseDirs = append(baseDirs, baseDir)
@@ -136,7 +139,7 @@ func (j *Module) genSources(ctx android.ModuleContext, srcFiles android.Paths,
 	var protoSrcs android.Paths
 	var aidlSrcs android.Paths
 
-	aidlIncludeFlags := genAidlIncludeFlags(srcFiles)
+	aidlIncludeFlags := genAidlIncludeFlags(srcFiles, Bool(j.deviceProperties.Aidl.Include_dirs_from_srcs))
 
 	for _, srcFile := range srcFiles {
 		switch srcFile.Ext() {
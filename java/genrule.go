@@ -15,6 +15,9 @@
 package java
 
 import (
+	"fmt"
+	"strings"
+
 	"android/soong/android"
 	"android/soong/genrule"
 )
@@ -39,6 +42,10 @@ func RegisterGenRuleBuildComponents(ctx android.RegistrationContext) {
 // Use a java_genrule instead of a genrule when it needs to depend on or be depended on by other java modules, unless
 // the dependency is for a generated source file.
 //
+// In addition to the variables supported by genrule, the command of a java_genrule can use $(classpath :module),
+// which expands to the colon separated runtime classpath of a java module listed in srcs, i.e. the jar of the module
+// followed by the jars of its transitive libs.  Each jar is an input of the command.
+//
 // Examples:
 //
 // Use a java_genrule to package generated java resources:
@@ -62,6 +69,7 @@ func RegisterGenRuleBuildComponents(ctx android.RegistrationContext) {
 //     }
 func GenRuleFactory() android.Module {
 	module := genrule.NewGenRule()
+	module.ExtraVariables = genruleExtraVariables
 
 	android.InitAndroidArchModule(module, android.HostAndDeviceSupported, android.MultilibCommon)
 	android.InitDefaultableModule(module)
@@ -76,6 +84,7 @@ func GenRuleFactory() android.Module {
 // produce an output that can be used as an input to a host java rule.
 func GenRuleFactoryHost() android.Module {
 	module := genrule.NewGenRule()
+	module.ExtraVariables = genruleExtraVariables
 
 	android.InitAndroidArchModule(module, android.HostSupported, android.MultilibCommon)
 	android.InitDefaultableModule(module)
@@ -83,3 +92,67 @@ func GenRuleFactoryHost() android.Module {
 
 	return module
 }
+
+// genruleExtraVariables expands the variables that are only supported in the command of a java_genrule.
+func genruleExtraVariables(ctx android.ModuleContext, cmd *android.RuleBuilderCommand, name string) (string, bool, error) {
+	if !strings.HasPrefix(name, "classpath ") {
+		return "", false, nil
+	}
+	label := strings.TrimSpace(strings.TrimPrefix(name, "classpath "))
+	jars, err := genruleClasspath(ctx, label)
+	if err != nil {
+		return "", true, err
+	}
+	cmd.Implicits(jars)
+	return strings.Join(cmd.PathsForInputs(jars), ":"), true, nil
+}
+
+// genruleClasspath returns the runtime classpath of the java module referenced by label, which is the
+// implementation jar of the module followed by the jars of its transitive libs.  The jars of the static_libs are
+// already included in the jar of the module that depends on them.
+func genruleClasspath(ctx android.ModuleContext, label string) (android.Paths, error) {
+	moduleName, tag := android.SrcIsModuleWithTag(label)
+	if moduleName == "" {
+		return nil, fmt.Errorf("$(classpath) label %q is not a module reference, it must be of the form \":module\"", label)
+	} else if tag != "" {
+		return nil, fmt.Errorf("$(classpath) label %q must not have an output tag", label)
+	}
+
+	var jars android.Paths
+	found := false
+	var walkErr error
+	ctx.WalkDeps(func(child, parent android.Module) bool {
+		if parent == ctx.Module() {
+			if android.RemoveOptionalPrebuiltPrefix(ctx.OtherModuleName(child)) != moduleName || found {
+				return false
+			}
+			found = true
+			if !ctx.OtherModuleHasProvider(child, JavaInfoProvider) {
+				walkErr = fmt.Errorf("$(classpath) label %q is not a java module", label)
+				return false
+			}
+			dep := ctx.OtherModuleProvider(child, JavaInfoProvider).(JavaInfo)
+			jars = append(jars, dep.ImplementationAndResourcesJars...)
+			return true
+		}
+
+		switch ctx.OtherModuleDependencyTag(child) {
+		case libTag:
+			if ctx.OtherModuleHasProvider(child, JavaInfoProvider) {
+				dep := ctx.OtherModuleProvider(child, JavaInfoProvider).(JavaInfo)
+				jars = append(jars, dep.ImplementationAndResourcesJars...)
+			}
+			return true
+		case staticLibTag:
+			return true
+		}
+		return false
+	})
+
+	if walkErr != nil {
+		return nil, walkErr
+	} else if !found {
+		return nil, fmt.Errorf("$(classpath) label %q must be listed in srcs", label)
+	}
+	return android.FirstUniquePaths(jars), nil
+}
@@ -116,3 +116,178 @@ func TestJarGenrules(t *testing.T) {
 			barCombined.Inputs.Strings(), bar.Output.String(), jargen.Output.String())
 	}
 }
+
+func TestJavaGenruleClasspath(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			libs: ["bar"],
+			static_libs: ["baz"],
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			libs: ["qux"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["c.java"],
+		}
+
+		java_library {
+			name: "qux",
+			srcs: ["d.java"],
+		}
+
+		java_genrule {
+			name: "gen",
+			tool_files: ["b.java"],
+			cmd: "$(location b.java) -cp $(classpath :foo) $(out)",
+			srcs: [":foo"],
+			out: ["out"],
+		}
+	`)
+
+	var jars android.Paths
+	for _, name := range []string{"foo", "bar", "qux"} {
+		module := result.Module(name, "android_common")
+		jars = append(jars, result.ModuleProvider(module, JavaInfoProvider).(JavaInfo).ImplementationAndResourcesJars...)
+	}
+	baz := result.ModuleProvider(result.Module("baz", "android_common"), JavaInfoProvider).(JavaInfo)
+
+	gen := result.ModuleForTests("gen", "android_common")
+	manifest := android.RuleBuilderSboxProtoForTests(t, gen.Output("genrule.sbox.textproto"))
+	var sandboxJars []string
+	for _, jar := range jars {
+		sandboxJars = append(sandboxJars, "__SBOX_SANDBOX_DIR__/"+jar.RelativeToTop().String())
+	}
+	android.AssertStringDoesContain(t, "genrule command", manifest.Commands[0].GetCommand(),
+		"-cp "+strings.Join(sandboxJars, ":")+" ")
+	android.AssertStringDoesNotContain(t, "genrule command", manifest.Commands[0].GetCommand(),
+		baz.ImplementationAndResourcesJars[0].RelativeToTop().String())
+
+	implicits := gen.Output("out").Implicits.RelativeToTop().Strings()
+	for _, jar := range jars {
+		android.AssertStringListContains(t, "genrule inputs", implicits, jar.RelativeToTop().String())
+	}
+}
+
+func TestJavaGenruleClasspathErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		cmd  string
+		srcs string
+		err  string
+	}{
+		{
+			name: "not a java module",
+			cmd:  "$(classpath :other_gen)",
+			srcs: `[":other_gen"]`,
+			err:  `\$\(classpath\) label ":other_gen" is not a java module`,
+		},
+		{
+			name: "not in srcs",
+			cmd:  "$(classpath :foo)",
+			srcs: `[]`,
+			err:  `\$\(classpath\) label ":foo" must be listed in srcs`,
+		},
+		{
+			name: "not a module",
+			cmd:  "$(classpath foo)",
+			srcs: `[":foo"]`,
+			err:  `\$\(classpath\) label "foo" is not a module reference`,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			prepareForJavaTest.
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(test.err)).
+				RunTestWithBp(t, `
+					java_library {
+						name: "foo",
+						srcs: ["a.java"],
+					}
+
+					genrule {
+						name: "other_gen",
+						cmd: "touch $(out)",
+						out: ["other.jar"],
+					}
+
+					java_genrule {
+						name: "gen",
+						cmd: "`+test.cmd+` > $(out)",
+						srcs: `+test.srcs+`,
+						out: ["out"],
+					}
+				`)
+		})
+	}
+}
+
+func TestJavaGenruleTools(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_binary_host {
+			name: "host_tool",
+			srcs: ["a.java"],
+		}
+
+		java_genrule {
+			name: "gen",
+			tools: ["host_tool"],
+			cmd: "$(location host_tool) > $(out)",
+			out: ["out"],
+		}
+	`)
+
+	gen := result.ModuleForTests("gen", "android_common")
+	manifest := android.RuleBuilderSboxProtoForTests(t, gen.Output("genrule.sbox.textproto"))
+	android.AssertStringDoesContain(t, "genrule command", manifest.Commands[0].GetCommand(),
+		"__SBOX_SANDBOX_DIR__/tools/out/bin/host_tool > ")
+}
+
+func TestJavaGenruleDeviceTool(t *testing.T) {
+	testCases := []struct {
+		name string
+		tool string
+	}{
+		{
+			name: "device binary",
+			tool: "device_tool",
+		},
+		{
+			name: "host library",
+			tool: "host_lib",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			prepareForJavaTest.
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+					`tools: "`+test.tool+`" has no linux_glibc_x86_64 variant, tools run on the build host`)).
+				RunTestWithBp(t, `
+					java_binary {
+						name: "device_tool",
+						srcs: ["a.java"],
+					}
+
+					java_library_host {
+						name: "host_lib",
+						srcs: ["a.java"],
+					}
+
+					java_genrule {
+						name: "gen",
+						tools: ["`+test.tool+`"],
+						cmd: "$(location) > $(out)",
+						out: ["out"],
+					}
+				`)
+		})
+	}
+}
@@ -246,8 +246,9 @@ type JavaInfo struct {
 	// any module that depends on this module.
 	ExportedPluginClasses []string
 
-	// ExportedPluginDisableTurbine is true if this module's annotation processors generate APIs,
-	// requiring disbling turbine for any modules that depend on it.
+	// ExportedPluginDisableTurbine is true if this module's annotation processors, or those of
+	// any of its transitive static libraries, generate APIs, requiring disabling turbine for any
+	// modules that depend on it.
 	ExportedPluginDisableTurbine bool
 
 	// JacocoReportClassesFile is the path to a jar containing uninstrumented classes that will be
@@ -257,6 +258,17 @@ type JavaInfo struct {
 
 var JavaInfoProvider = blueprint.NewProvider(JavaInfo{})
 
+// PlatformImplLibrariesInfo lists the platform libraries whose implementation jars, rather than
+// their API stubs, are on the compile classpath of a java module, either through its own libs or
+// through the libs of its static_libs.
+type PlatformImplLibrariesInfo struct {
+	// DependencyChains contains one chain of module names per platform library, starting with the
+	// module itself and ending with the platform library.
+	DependencyChains [][]string
+}
+
+var PlatformImplLibrariesInfoProvider = blueprint.NewProvider(PlatformImplLibrariesInfo{})
+
 // SyspropPublicStubInfo contains info about the sysprop public stub library that corresponds to
 // the sysprop implementation library.
 type SyspropPublicStubInfo struct {
@@ -368,6 +380,7 @@ var (
 	certificateTag          = dependencyTag{name: "certificate"}
 	instrumentationForTag   = dependencyTag{name: "instrumentation_for"}
 	extraLintCheckTag       = dependencyTag{name: "extra-lint-check", toolchain: true}
+	jarTransformTag         = dependencyTag{name: "jar-transform", toolchain: true}
 	jniLibTag               = dependencyTag{name: "jnilib", runtimeLinked: true}
 	syspropPublicStubDepTag = dependencyTag{name: "sysprop public stub"}
 	jniInstallTag           = installDependencyTag{name: "jni install"}
@@ -653,6 +666,9 @@ func (j *Library) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 			j.hostdexInstallFile = ctx.InstallFile(
 				android.PathForHostDexInstall(ctx, "framework"),
 				j.Stem()+"-hostdex.jar", j.outputFile)
+			if len(j.deviceProperties.Target.Hostdex.Test_suites) > 0 {
+				j.hostdexTestSuiteBuildActions(ctx)
+			}
 		}
 		var installDir android.InstallPath
 		if ctx.InstallInTestcases() {
@@ -668,6 +684,70 @@ func (j *Library) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 }
 
+// hostdexJar is a -hostdex jar and the name it is installed with.
+type hostdexJar struct {
+	jar  android.Path
+	name string
+}
+
+// hostdexOutputJar returns the jar of the -hostdex copy of the module.
+func (j *Module) hostdexOutputJar() android.Path {
+	if j.dexJarFile.IsSet() {
+		return j.dexJarFile.Path()
+	}
+	return j.implementationAndResourcesJar
+}
+
+// hostdexTestSuiteBuildActions builds the -hostdex jar that Make installs into the test suites,
+// which has a Class-Path manifest referencing the -hostdex jars of its runtime dependencies that
+// are installed next to it, so that host ART can run it from the test suite.
+func (j *Library) hostdexTestSuiteBuildActions(ctx android.ModuleContext) {
+	var classPath []string
+	for _, dep := range hostdexRuntimeDeps(ctx) {
+		name := dep.Stem() + "-hostdex.jar"
+		classPath = append(classPath, name)
+		j.hostdexTestSuiteDeps = append(j.hostdexTestSuiteDeps, hostdexJar{dep.hostdexOutputJar(), name})
+	}
+
+	j.hostdexTestSuiteJar = j.hostdexOutputJar()
+	if len(classPath) > 0 {
+		manifest := android.PathForModuleOut(ctx, "hostdex", "manifest.txt")
+		GenerateClassPathManifest(ctx, manifest, classPath)
+		combinedJar := android.PathForModuleOut(ctx, "hostdex", j.Stem()+"-hostdex.jar")
+		TransformJarsToJar(ctx, combinedJar, "for hostdex class path", android.Paths{j.hostdexOutputJar()},
+			android.OptionalPathForPath(manifest), false, nil, nil)
+		j.hostdexTestSuiteJar = combinedJar
+	}
+}
+
+// hostdexRuntimeDeps returns the transitive runtime dependencies of the module that have a
+// -hostdex copy, which are needed when running the module on host ART. These are the libs of the
+// module and of its static_libs, whose classes are already in the jar of the module.
+func hostdexRuntimeDeps(ctx android.ModuleContext) []*Library {
+	var deps []*Library
+	seen := make(map[*Library]bool)
+	ctx.WalkDeps(func(child, parent android.Module) bool {
+		switch ctx.OtherModuleDependencyTag(child) {
+		case staticLibTag:
+			// The static lib is merged into the jar of its parent, but its libs are runtime
+			// dependencies.
+			return true
+		case libTag:
+			dep, ok := child.(*Library)
+			if !ok || !Bool(dep.deviceProperties.Hostdex) || dep.hostdexOutputJar() == nil {
+				return false
+			}
+			if !seen[dep] {
+				seen[dep] = true
+				deps = append(deps, dep)
+			}
+			return true
+		}
+		return false
+	})
+	return deps
+}
+
 func (j *Library) DepsMutator(ctx android.BottomUpMutatorContext) {
 	j.deps(ctx)
 	j.usesLibrary.deps(ctx, false)
@@ -847,6 +927,14 @@ type TestOptions struct {
 
 	// If the test is a hostside(no device required) unittest that shall be run during presubmit check.
 	Unit_test *bool
+
+	// A list of free-formed strings that categorize the test, exported to test_metadata.json for
+	// the test mapping tooling.
+	Tags []string
+
+	// A list of options for the test runner, exported to test_metadata.json for the test mapping
+	// tooling.
+	Test_runner_options []string
 }
 
 type testProperties struct {
@@ -928,6 +1016,9 @@ type prebuiltTestProperties struct {
 	// the name of the test configuration (for example "AndroidTest.xml") that should be
 	// installed with the module.
 	Test_config *string `android:"path,arch_variant"`
+
+	// Install the test into a folder named for the module in all test suites.
+	Per_testcase_directory *bool
 }
 
 type Test struct {
@@ -961,6 +1052,17 @@ type JavaTestImport struct {
 	dexJarFile android.Path
 }
 
+func (j *Test) TestMetadata() *android.TestMetadata {
+	return &android.TestMetadata{
+		Suites:            j.testProperties.Test_suites,
+		Config:            j.testConfig,
+		Tags:              j.testProperties.Test_options.Tags,
+		TestRunnerOptions: j.testProperties.Test_options.Test_runner_options,
+	}
+}
+
+var _ android.TestMetadataModule = (*Test)(nil)
+
 func (j *Test) InstallInTestcases() bool {
 	// Host java tests install into $(HOST_OUT_JAVA_LIBRARIES), and then are copied into
 	// testcases by base_rules.mk.
@@ -1617,6 +1719,7 @@ func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	j.classLoaderContexts = make(dexpreopt.ClassLoaderContextMap)
 
 	var flags javaBuilderFlags
+	exportedDisableTurbine := false
 
 	ctx.VisitDirectDeps(func(module android.Module) {
 		tag := ctx.OtherModuleDependencyTag(module)
@@ -1629,6 +1732,7 @@ func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 				flags.dexClasspath = append(flags.dexClasspath, dep.HeaderJars...)
 			case staticLibTag:
 				flags.classpath = append(flags.classpath, dep.HeaderJars...)
+				exportedDisableTurbine = exportedDisableTurbine || dep.ExportedPluginDisableTurbine
 			case bootClasspathTag:
 				flags.bootClasspath = append(flags.bootClasspath, dep.HeaderJars...)
 			}
@@ -1717,6 +1821,13 @@ func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 			j.dexJarFile = makeDexJarPathFromPath(dexOutputFile)
 			j.dexJarInstallFile = android.PathForModuleInstall(ctx, "framework", jarName)
+
+			// Dexpreopting
+			if !j.dexpreoptDisabled(ctx) {
+				j.dexpreopt(ctx, dexOutputFile)
+			}
+		} else if Bool(j.dexpreoptProperties.Dex_preopt.Enabled) {
+			ctx.PropertyErrorf("dex_preopt.enabled", "requires compile_dex: true")
 		}
 	}
 
@@ -1725,6 +1836,7 @@ func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		ImplementationAndResourcesJars: android.PathsIfNonNil(j.combinedClasspathFile),
 		ImplementationJars:             android.PathsIfNonNil(j.combinedClasspathFile),
 		AidlIncludeDirs:                j.exportAidlIncludeDirs,
+		ExportedPluginDisableTurbine:   exportedDisableTurbine,
 	})
 }
 
@@ -1761,6 +1873,15 @@ func (j *Import) DexJarInstallPath() android.Path {
 	return j.dexJarInstallFile
 }
 
+// Unlike java_library, java_import is only dexpreopted when dex_preopt.enabled is set to true, except
+// for a library imported from a prebuilt APEX.
+func (j *Import) dexpreoptDisabled(ctx android.BaseModuleContext) bool {
+	if !forPrebuiltApex(ctx) && !Bool(j.dexpreoptProperties.Dex_preopt.Enabled) {
+		return true
+	}
+	return j.dexpreopter.dexpreoptDisabled(ctx)
+}
+
 func (j *Import) ClassLoaderContexts() dexpreopt.ClassLoaderContextMap {
 	return j.classLoaderContexts
 }
@@ -1849,6 +1970,7 @@ func ImportFactory() android.Module {
 	module.AddProperties(
 		&module.properties,
 		&module.dexer.dexProperties,
+		&module.dexpreoptProperties,
 	)
 
 	module.initModuleAndImport(module)
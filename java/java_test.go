@@ -15,6 +15,7 @@
 package java
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -328,11 +329,65 @@ func TestExportedPlugins(t *testing.T) {
 				{library: "bar", processors: "-processor com.android.TestPlugin", disableTurbine: true},
 			},
 		},
+		{
+			name: "Exports plugin with generates_api disables turbine through static_libs",
+			extra: `
+				java_library{name: "exports", exported_plugins: ["plugin_generates_api"]}
+				java_library{name: "foo", srcs: ["a.java"], static_libs: ["exports"]}
+				java_library{name: "bar", srcs: ["a.java"], static_libs: ["foo"]}
+				java_library{name: "baz", srcs: ["a.java"], static_libs: ["bar"]}
+				java_library{name: "qux", srcs: ["a.java"], libs: ["baz"]}
+			`,
+			results: []Result{
+				{library: "foo", processors: "-processor com.android.TestPlugin", disableTurbine: true},
+				{library: "bar", processors: "-proc:none", disableTurbine: true},
+				{library: "baz", processors: "-proc:none", disableTurbine: true},
+				{library: "qux", processors: "-proc:none", disableTurbine: true},
+			},
+		},
+		{
+			name: "Exports plugin without generates_api does not disable turbine through static_libs",
+			extra: `
+				java_library{name: "exports", exported_plugins: ["plugin"]}
+				java_library{name: "foo", srcs: ["a.java"], static_libs: ["exports"]}
+				java_library{name: "bar", srcs: ["a.java"], static_libs: ["foo"]}
+				java_library{name: "baz", srcs: ["a.java"], static_libs: ["bar"]}
+			`,
+			results: []Result{
+				{library: "foo", processors: "-processor com.android.TestPlugin"},
+				{library: "bar", processors: "-proc:none"},
+				{library: "baz", processors: "-proc:none"},
+			},
+		},
+		{
+			name: "Exports plugin with generates_api disables turbine through android_library and aar static_libs",
+			extra: `
+				java_library{name: "exports", exported_plugins: ["plugin_generates_api"]}
+				android_library_import{name: "aar", aars: ["aar.aar"], static_libs: ["exports"]}
+				android_library{name: "foo", srcs: ["a.java"], static_libs: ["aar"]}
+				java_library{name: "bar", srcs: ["a.java"], static_libs: ["foo"]}
+			`,
+			results: []Result{
+				{library: "foo", processors: "-proc:none", disableTurbine: true},
+				{library: "bar", processors: "-proc:none", disableTurbine: true},
+			},
+		},
+		{
+			name: "Exports multiple plugins with generates_api",
+			extra: `
+				java_plugin{name: "plugin2", processor_class: "com.android.TestPlugin2"}
+				java_library{name: "exports", exported_plugins: ["plugin_generates_api", "plugin2"]}
+				java_library{name: "foo", srcs: ["a.java"], libs: ["exports"]}
+			`,
+			results: []Result{
+				{library: "foo", processors: "-processor com.android.TestPlugin,com.android.TestPlugin2", disableTurbine: true},
+			},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			ctx, _ := testJava(t, `
+			ctx, _ := testJavaWithFS(t, `
 				java_plugin {
 					name: "plugin",
 					processor_class: "com.android.TestPlugin",
@@ -342,7 +397,7 @@ func TestExportedPlugins(t *testing.T) {
 					generates_api: true,
 					processor_class: "com.android.TestPlugin",
 				}
-			`+test.extra)
+			`+test.extra, android.MockFS{"aar.aar": nil})
 
 			for _, want := range test.results {
 				javac := ctx.ModuleForTests(want.library, "android_common").Rule("javac")
@@ -1023,6 +1078,29 @@ func TestExcludeFileGroupInSrcs(t *testing.T) {
 	}
 }
 
+func TestExcludeGeneratedSrcs(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java", ":foo-gen"],
+			exclude_srcs: ["**/b.java"],
+		}
+
+		gensrcs {
+			name: "foo-gen",
+			cmd: "cp $(in) $(out)",
+			srcs: ["gen/b.txt", "gen/c.txt"],
+			output_extension: "java",
+		}
+	`)
+
+	javac := ctx.ModuleForTests("foo", "android_common").Rule("javac")
+	android.AssertPathsRelativeToTopEquals(t, "javac inputs", []string{
+		"a.java",
+		"out/soong/.intermediates/foo-gen/gen/gensrcs/gen/c.java",
+	}, javac.Inputs)
+}
+
 func TestJavaLibrary(t *testing.T) {
 	testJavaWithFS(t, "", map[string][]byte{
 		"libcore/Android.bp": []byte(`
@@ -1369,6 +1447,46 @@ func TestAidlEnforcePermissionsException(t *testing.T) {
 	}
 }
 
+func TestAidlIncludeDirsFromFilegroups(t *testing.T) {
+	bp := `
+		filegroup {
+			name: "fga",
+			srcs: ["a/aidl/com/a/IA.aidl"],
+			path: "a/aidl",
+		}
+
+		filegroup {
+			name: "fgb",
+			srcs: ["b/aidl/com/b/IB.aidl"],
+			path: "b/aidl",
+		}
+
+		filegroup {
+			name: "fgc",
+			srcs: ["c/src/com/c/C.java"],
+			path: "c/src",
+		}
+
+		java_library {
+			name: "foo",
+			srcs: [":fga", ":fgb", ":fgc"],
+			%s
+		}
+	`
+
+	ctx, _ := testJava(t, fmt.Sprintf(bp, ""))
+	aidlCommand := ctx.ModuleForTests("foo", "android_common").Rule("aidl").RuleParams.Command
+	// IA.aidl can import IB.aidl from the other filegroup and vice versa.
+	android.AssertStringDoesContain(t, "aidl command", aidlCommand, "-Ia/aidl/ -Ib/aidl/")
+	android.AssertStringDoesNotContain(t, "aidl command", aidlCommand, "-Ic/src/")
+
+	ctx, _ = testJava(t, fmt.Sprintf(bp, "aidl: { include_dirs_from_srcs: true },"))
+	aidl := ctx.ModuleForTests("foo", "android_common").Rule("aidl")
+	android.AssertStringDoesContain(t, "aidl command", aidl.RuleParams.Command, "-Ia/aidl/ -Ib/aidl/ -Ic/src/")
+	// Edits to imported aidl files rerun aidl through its depfile.
+	android.AssertBoolEquals(t, "aidl has depfile", true, aidl.Depfile != nil)
+}
+
 func TestDataNativeBinaries(t *testing.T) {
 	ctx, _ := testJava(t, `
 		java_test_host {
@@ -1392,6 +1510,61 @@ func TestDataNativeBinaries(t *testing.T) {
 	android.AssertStringPathsRelativeToTopEquals(t, "LOCAL_COMPATIBILITY_SUPPORT_FILES", ctx.Config(), expected, actual)
 }
 
+func TestTestMetadata(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.PrepareForTestWithTestMetadata,
+	).RunTestWithBp(t, cc.GatherRequiredDepsForTest(android.Android)+`
+		java_test {
+			name: "java-test",
+			srcs: ["a.java"],
+			test_suites: ["general-tests"],
+			test_options: {
+				tags: ["presubmit", "java"],
+				test_runner_options: ["--exclude-annotation=Flaky"],
+			},
+		}
+
+		cc_test {
+			name: "cc-test",
+			srcs: ["a.cpp"],
+			gtest: false,
+			test_suites: ["device-tests"],
+			test_options: {
+				tags: ["native"],
+			},
+		}
+	`)
+
+	content := android.ContentFromFileRuleForTests(t,
+		result.SingletonForTests("test_metadata").Output("test_metadata.json"))
+
+	var entries []struct {
+		Name              string   `json:"name"`
+		Suites            []string `json:"suites"`
+		Configs           []string `json:"test_configs"`
+		Tags              []string `json:"tags"`
+		TestRunnerOptions []string `json:"test_runner_options"`
+	}
+	if err := json.Unmarshal([]byte(content), &entries); err != nil {
+		t.Fatalf("failed to parse test_metadata.json: %s\n%s", err, content)
+	}
+
+	android.AssertIntEquals(t, "number of tests", 2, len(entries))
+
+	android.AssertStringEquals(t, "first test", "cc-test", entries[0].Name)
+	android.AssertArrayString(t, "cc-test suites", []string{"device-tests"}, entries[0].Suites)
+	android.AssertArrayString(t, "cc-test tags", []string{"native"}, entries[0].Tags)
+	android.AssertIntEquals(t, "cc-test test runner options", 0, len(entries[0].TestRunnerOptions))
+
+	android.AssertStringEquals(t, "second test", "java-test", entries[1].Name)
+	android.AssertArrayString(t, "java-test suites", []string{"general-tests"}, entries[1].Suites)
+	android.AssertArrayString(t, "java-test tags", []string{"java", "presubmit"}, entries[1].Tags)
+	android.AssertArrayString(t, "java-test test runner options", []string{"--exclude-annotation=Flaky"},
+		entries[1].TestRunnerOptions)
+	android.AssertIntEquals(t, "java-test test configs", 1, len(entries[1].Configs))
+}
+
 func TestDefaultInstallable(t *testing.T) {
 	ctx, _ := testJava(t, `
 		java_test_host {
@@ -1497,6 +1670,71 @@ func TestErrorproneEnabledOnlyByEnvironmentVariable(t *testing.T) {
 	}
 }
 
+func TestErrorproneExtraCheckModules(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			errorprone: {
+				extra_check_modules: ["my_checks"],
+			},
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["a.java"],
+		}
+
+		java_library_host {
+			name: "my_checks",
+			srcs: ["b.java"],
+		}
+	`
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureMergeEnv(map[string]string{
+			"RUN_ERROR_PRONE": "true",
+		}),
+	).RunTestWithBp(t, bp)
+
+	buildOS := result.Config.BuildOS.String()
+	myChecks := result.ModuleForTests("my_checks", buildOS+"_common").Module().(*Library).implementationAndResourcesJar.String()
+
+	foo := result.ModuleForTests("foo", "android_common")
+	fooErrorprone := foo.Description("errorprone")
+	android.AssertStringDoesContain(t, "foo errorprone processorpath", fooErrorprone.Args["processorpath"], myChecks)
+	android.AssertStringListContains(t, "foo errorprone implicits", fooErrorprone.Implicits.Strings(), myChecks)
+
+	// The checks are only loaded by the errorprone compilation.
+	fooJavac := foo.Description("javac")
+	android.AssertStringDoesNotContain(t, "foo javac processorpath", fooJavac.Args["processorpath"], myChecks)
+	android.AssertStringDoesNotContain(t, "foo javac classpath", fooJavac.Args["classpath"], myChecks)
+	android.AssertStringListDoesNotContain(t, "foo javac implicits", fooJavac.Implicits.Strings(), myChecks)
+
+	barErrorprone := result.ModuleForTests("bar", "android_common").Description("errorprone")
+	android.AssertStringDoesNotContain(t, "bar errorprone processorpath", barErrorprone.Args["processorpath"], myChecks)
+}
+
+func TestErrorproneExtraCheckModulesNotJava(t *testing.T) {
+	PrepareForTestWithJavaDefaultModules.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`errorprone.extra_check_modules: "my_checks" is not a java_plugin or java_library module`)).
+		RunTestWithBp(t, `
+			java_library {
+				name: "foo",
+				srcs: ["a.java"],
+				errorprone: {
+					extra_check_modules: ["my_checks"],
+				},
+			}
+
+			java_import_host {
+				name: "my_checks",
+				jars: ["my_checks.jar"],
+			}
+		`)
+}
+
 func TestDataDeviceBinsBuildsDeviceBinary(t *testing.T) {
 	testCases := []struct {
 		dataDeviceBinType  string
@@ -1667,3 +1905,188 @@ func TestDataDeviceBinsBuildsDeviceBinary(t *testing.T) {
 		})
 	}
 }
+
+func TestHostdexTestSuites(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			libs: ["bar", "qux"],
+			static_libs: ["static"],
+			hostdex: true,
+			target: {
+				hostdex: {
+					test_suites: ["general-tests"],
+				},
+			},
+		}
+
+		java_library {
+			name: "static",
+			srcs: ["a.java"],
+			libs: ["quux"],
+		}
+
+		java_library {
+			name: "quux",
+			srcs: ["a.java"],
+			hostdex: true,
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["a.java"],
+			libs: ["baz"],
+			hostdex: true,
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["a.java"],
+			hostdex: true,
+		}
+
+		java_library {
+			name: "qux",
+			srcs: ["a.java"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+
+	manifest := foo.Output("hostdex/manifest.txt")
+	android.AssertStringEquals(t, "manifest", "Class-Path: bar-hostdex.jar baz-hostdex.jar quux-hostdex.jar\n",
+		android.ContentFromFileRuleForTests(t, manifest))
+
+	combined := foo.Output("hostdex/foo-hostdex.jar")
+	android.AssertStringDoesContain(t, "manifest of the combined jar", combined.Args["jarArgs"],
+		"out/soong/.intermediates/foo/android_common/hostdex/manifest.txt")
+
+	// Soong only installs the -hostdex jar into the host framework directory, Make installs it into
+	// the test suites with the jars it references.
+	for _, output := range foo.AllOutputs() {
+		if strings.Contains(output, "testcases") {
+			t.Errorf("unexpected testcases install %q", output)
+		}
+	}
+
+	entriesList := android.AndroidMkEntriesForTest(t, result.TestContext, foo.Module())
+	hostdexEntries := entriesList[1]
+	android.AssertDeepEquals(t, "LOCAL_COMPATIBILITY_SUITE", []string{"general-tests"},
+		hostdexEntries.EntryMap["LOCAL_COMPATIBILITY_SUITE"])
+	android.AssertStringPathsRelativeToTopEquals(t, "LOCAL_PREBUILT_MODULE_FILE", result.Config,
+		[]string{"out/soong/.intermediates/foo/android_common/hostdex/foo-hostdex.jar"},
+		hostdexEntries.EntryMap["LOCAL_PREBUILT_MODULE_FILE"])
+	// The libs of foo and of its static_libs are installed next to it, but not the static_libs
+	// themselves nor the libs without a -hostdex copy.
+	var supportFiles []string
+	for _, dep := range []string{"bar", "baz", "quux"} {
+		depJar := result.ModuleForTests(dep, "android_common").Module().(*Library).hostdexOutputJar()
+		supportFiles = append(supportFiles, android.PathRelativeToTop(depJar)+":"+dep+"-hostdex.jar")
+	}
+	android.AssertStringPathsRelativeToTopEquals(t, "LOCAL_COMPATIBILITY_SUPPORT_FILES", result.Config,
+		supportFiles, hostdexEntries.EntryMap["LOCAL_COMPATIBILITY_SUPPORT_FILES"])
+}
+
+func TestWrapManifestLine(t *testing.T) {
+	line := "Class-Path: " + strings.Repeat("a", 150)
+	expected := line[:72] + "\n " + line[72:143] + "\n " + line[143:] + "\n"
+	android.AssertStringEquals(t, "wrapped line", expected, wrapManifestLine(line))
+
+	android.AssertStringEquals(t, "short line", "Class-Path: a.jar\n", wrapManifestLine("Class-Path: a.jar"))
+}
+
+func TestJarTransforms(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.PrepareForTestWithPrebuiltBuildTool,
+		android.FixtureMergeMockFs(android.MockFS{
+			"transform_a": nil,
+			"transform_b": nil,
+		}),
+	).RunTestWithBp(t, `
+		prebuilt_build_tool {
+			name: "transform_a",
+			src: "transform_a",
+		}
+
+		prebuilt_build_tool {
+			name: "transform_b",
+			src: "transform_b",
+		}
+
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			static_libs: ["baz"],
+			jar_transforms: [":transform_a", ":transform_b"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["c.java"],
+		}
+
+		java_library_host {
+			name: "bar",
+			srcs: ["b.java"],
+			jar_transforms: [":transform_b"],
+		}
+	`)
+
+	toolPath := func(name string) string {
+		tool := result.ModuleForTests(name, result.Config.BuildOSTarget.String()).Output(name)
+		return android.PathRelativeToTop(tool.Output)
+	}
+
+	foo := result.ModuleForTests("foo", "android_common")
+	transformA := foo.Rule("jar_transform_0")
+	transformB := foo.Rule("jar_transform_1")
+
+	javacJar := "out/soong/.intermediates/foo/android_common/javac/foo.jar"
+	transformedAJar := "out/soong/.intermediates/foo/android_common/jar-transforms/0/foo.jar"
+	transformedBJar := "out/soong/.intermediates/foo/android_common/jar-transforms/1/foo.jar"
+
+	android.AssertStringDoesContain(t, "first transform", transformA.RuleParams.Command,
+		toolPath("transform_a")+" "+javacJar+" "+transformedAJar)
+	android.AssertStringDoesContain(t, "second transform", transformB.RuleParams.Command,
+		toolPath("transform_b")+" "+transformedAJar+" "+transformedBJar)
+
+	// The static libraries are combined with the transformed classes, they are not transformed.
+	combined := foo.Output("combined/foo.jar")
+	android.AssertPathsRelativeToTopEquals(t, "combined jar inputs", []string{
+		transformedBJar,
+		"out/soong/.intermediates/baz/android_common/javac/baz.jar",
+	}, combined.Inputs)
+
+	fooInfo := result.ModuleProvider(foo.Module(), JavaInfoProvider).(JavaInfo)
+	android.AssertPathsRelativeToTopEquals(t, "implementation jars",
+		[]string{"out/soong/.intermediates/foo/android_common/combined/foo.jar"}, fooInfo.ImplementationJars)
+	android.AssertPathsRelativeToTopEquals(t, "header jars",
+		[]string{"out/soong/.intermediates/foo/android_common/turbine-combined/foo.jar"}, fooInfo.HeaderJars)
+
+	// Without turbine the header jar is built from the classes from before the transforms.
+	bar := result.ModuleForTests("bar", result.Config.BuildOSCommonTarget.String())
+	barInfo := result.ModuleProvider(bar.Module(), JavaInfoProvider).(JavaInfo)
+	android.AssertPathsRelativeToTopEquals(t, "host implementation jars",
+		[]string{"out/soong/.intermediates/bar/linux_glibc_common/jar-transforms/0/bar.jar"}, barInfo.ImplementationJars)
+	untransformedJar := "out/soong/.intermediates/bar/linux_glibc_common/jar-transforms/untransformed/bar.jar"
+	android.AssertPathsRelativeToTopEquals(t, "host header jars", []string{untransformedJar}, barInfo.HeaderJars)
+	android.AssertPathsRelativeToTopEquals(t, "untransformed header jar inputs",
+		[]string{"out/soong/.intermediates/bar/linux_glibc_common/javac/bar.jar"}, bar.Output(untransformedJar).Inputs)
+}
+
+func TestJarTransformsNotAModuleReference(t *testing.T) {
+	android.GroupFixturePreparers(prepareForJavaTest).
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`jar_transforms: "transform_a" is not a module reference, use ":<module>"`)).
+		RunTestWithBp(t, `
+			java_library {
+				name: "foo",
+				srcs: ["a.java"],
+				jar_transforms: ["transform_a"],
+			}
+		`)
+}
@@ -142,6 +142,7 @@ var kapt = pctx.AndroidRemoteStaticRule("kapt", android.RemoteRuleSupports{Goma:
 			`-P plugin:org.jetbrains.kotlin.kapt3:correctErrorTypes=true ` +
 			`-P plugin:org.jetbrains.kotlin.kapt3:aptMode=stubsAndApt ` +
 			`-P plugin:org.jetbrains.kotlin.kapt3:javacArguments=$encodedJavacFlags ` +
+			`$kaptApOptions ` +
 			`$kaptProcessorPath ` +
 			`$kaptProcessor ` +
 			`-Xbuild-file=$kotlinBuildFile && ` +
@@ -159,7 +160,7 @@ var kapt = pctx.AndroidRemoteStaticRule("kapt", android.RemoteRuleSupports{Goma:
 		Rspfile:        "$out.rsp",
 		RspfileContent: `$in`,
 	},
-	"kotlincFlags", "encodedJavacFlags", "kaptProcessorPath", "kaptProcessor",
+	"kotlincFlags", "encodedJavacFlags", "kaptProcessorPath", "kaptProcessor", "kaptApOptions",
 	"classpath", "srcJars", "commonSrcFilesArg", "srcJarDir", "kaptDir", "kotlinJvmTarget",
 	"kotlinBuildFile", "name", "classesJarOut")
 
@@ -202,6 +203,14 @@ func kotlinKapt(ctx android.ModuleContext, srcJarOutputFile, resJarOutputFile an
 		{"-target", flags.javaVersion.String()},
 	})
 
+	// kapt does not pass -A flags in javacArguments to the annotation processors, they have to be
+	// passed as apoptions.
+	kaptApOptions := ""
+	if len(flags.processorOptions) > 0 {
+		kaptApOptions = "-P plugin:org.jetbrains.kotlin.kapt3:apoptions=" +
+			kaptEncodeFlags(kaptProcessorOptions(flags.processorOptions))
+	}
+
 	kotlinName := filepath.Join(ctx.ModuleDir(), ctx.ModuleSubDir(), ctx.ModuleName())
 	kotlinName = strings.ReplaceAll(kotlinName, "/", "__")
 
@@ -221,6 +230,7 @@ func kotlinKapt(ctx android.ModuleContext, srcJarOutputFile, resJarOutputFile an
 			"kotlinBuildFile":   android.PathForModuleOut(ctx, "kapt", "build.xml").String(),
 			"kaptProcessorPath": strings.Join(kaptProcessorPath, " "),
 			"kaptProcessor":     kaptProcessor,
+			"kaptApOptions":     kaptApOptions,
 			"kaptDir":           android.PathForModuleOut(ctx, "kapt/gen").String(),
 			"encodedJavacFlags": encodedJavacFlags,
 			"name":              kotlinName,
@@ -229,6 +239,20 @@ func kotlinKapt(ctx android.ModuleContext, srcJarOutputFile, resJarOutputFile an
 	})
 }
 
+// kaptProcessorOptions converts a list of key=value annotation processor options into key, value
+// pairs.  An option without a value is passed with an empty value, as javac does for -Akey.
+func kaptProcessorOptions(options []string) [][2]string {
+	ret := make([][2]string, 0, len(options))
+	for _, option := range options {
+		key, value := option, ""
+		if i := strings.Index(option, "="); i >= 0 {
+			key, value = option[:i], option[i+1:]
+		}
+		ret = append(ret, [2]string{key, value})
+	}
+	return ret
+}
+
 // kapt converts a list of key, value pairs into a base64 encoded Java serialization, which is what kapt expects.
 func kaptEncodeFlags(options [][2]string) string {
 	buf := &bytes.Buffer{}
@@ -15,6 +15,8 @@
 package java
 
 import (
+	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
@@ -246,6 +248,86 @@ func TestKapt(t *testing.T) {
 	})
 }
 
+func TestKaptProcessorOptions(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java", "b.kt"],
+			plugins: ["bar"],
+			plugin_options: ["dagger.fastInit=enabled", "dagger.formatGeneratedSource=disabled"],
+			javacflags: ["-Aroom.incremental=true", "-Aflag"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["a.java", "b.kt"],
+			plugins: ["bar"],
+		}
+
+		java_plugin {
+			name: "bar",
+			processor_class: "com.bar",
+			srcs: ["b.java"],
+		}
+	`
+
+	expectedApOptions := "-P plugin:org.jetbrains.kotlin.kapt3:apoptions=" + kaptEncodeFlags([][2]string{
+		{"room.incremental", "true"},
+		{"flag", ""},
+		{"dagger.fastInit", "enabled"},
+		{"dagger.formatGeneratedSource", "disabled"},
+	})
+
+	t.Run("", func(t *testing.T) {
+		result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, bp)
+
+		foo := result.ModuleForTests("foo", "android_common")
+		android.AssertStringEquals(t, "foo kapt apoptions", expectedApOptions, foo.Rule("kapt").Args["kaptApOptions"])
+
+		// The options are also passed to javac, which does not run the annotation processors.
+		javacFlags := foo.Module().VariablesForTests()["javacFlags"]
+		android.AssertStringDoesContain(t, "foo javacflags", javacFlags, "-Adagger.fastInit=enabled -Adagger.formatGeneratedSource=disabled")
+		android.AssertStringEquals(t, "foo javac processor", "-proc:none", foo.Rule("javac").Args["processor"])
+
+		baz := result.ModuleForTests("baz", "android_common")
+		android.AssertStringEquals(t, "baz kapt apoptions", "", baz.Rule("kapt").Args["kaptApOptions"])
+	})
+
+	t.Run("errorprone", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			PrepareForTestWithJavaDefaultModules,
+			android.FixtureMergeEnv(map[string]string{
+				"RUN_ERROR_PRONE": "true",
+			}),
+		).RunTestWithBp(t, bp)
+
+		foo := result.ModuleForTests("foo", "android_common")
+		android.AssertStringEquals(t, "foo kapt apoptions", expectedApOptions, foo.Rule("kapt").Args["kaptApOptions"])
+
+		// The annotation processors are run by kapt, errorprone only runs its own plugins.
+		errorprone := foo.Description("errorprone")
+		android.AssertStringEquals(t, "errorprone processor", "-proc:none", errorprone.Args["processor"])
+		android.AssertStringDoesNotContain(t, "errorprone processorpath", errorprone.Args["processorpath"], "bar")
+	})
+}
+
+func TestInvalidPluginOptions(t *testing.T) {
+	for _, option := range []string{"=value", "key", "", "key=a b"} {
+		t.Run(option, func(t *testing.T) {
+			android.GroupFixturePreparers(PrepareForTestWithJavaDefaultModules).
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+					regexp.QuoteMeta(fmt.Sprintf(`plugin_options: invalid option %q, must be key=value`, option)))).
+				RunTestWithBp(t, fmt.Sprintf(`
+					java_library {
+						name: "foo",
+						srcs: ["a.java"],
+						plugin_options: [%q],
+					}
+				`, option))
+		})
+	}
+}
+
 func TestKaptEncodeFlags(t *testing.T) {
 	// Compares the kaptEncodeFlags against the results of the example implementation at
 	// https://kotlinlang.org/docs/reference/kapt.html#apjavac-options-encoding
@@ -404,6 +404,28 @@ func (b *platformBootclasspathModule) generateHiddenApiMakeVars(ctx android.Make
 	ctx.Strict("INTERNAL_PLATFORM_HIDDENAPI_FLAGS", b.hiddenAPIFlagsCSV.String())
 }
 
+// bootImageProfileFragments returns the boot image profile fragments provided by the product and
+// by all the bootclasspath_fragment modules.
+//
+// The fragments provided by the product only apply to the platform boot image as they can
+// reference any class in the boot jars. The first list contains the fragments that have to be
+// verified against the platform boot image, the second one those that were already verified against
+// the boot image of their bootclasspath_fragment.
+func (b *platformBootclasspathModule) bootImageProfileFragments(ctx android.ModuleContext) (unverified, verified android.Paths) {
+	unverified = append(unverified, dexpreopt.GetGlobalConfig(ctx).BootImageProfileFragments...)
+	for _, fragment := range b.fragments {
+		if ctx.OtherModuleHasProvider(fragment, BootImageProfileFragmentsInfoProvider) {
+			info := ctx.OtherModuleProvider(fragment, BootImageProfileFragmentsInfoProvider).(BootImageProfileFragmentsInfo)
+			if info.Verified {
+				verified = append(verified, info.ProfileFragments...)
+			} else {
+				unverified = append(unverified, info.ProfileFragments...)
+			}
+		}
+	}
+	return unverified, verified
+}
+
 // generateBootImageBuildActions generates ninja rules related to the boot image creation.
 func (b *platformBootclasspathModule) generateBootImageBuildActions(ctx android.ModuleContext, platformModules, apexModules []android.Module) {
 	// Force the GlobalSoongConfig to be created and cached for use by the dex_bootjars
@@ -433,7 +455,8 @@ func (b *platformBootclasspathModule) generateBootImageBuildActions(ctx android.
 	copyBootJarsToPredefinedLocations(ctx, apexBootDexJarsByModule, config.dexPathsByModule)
 
 	// Build a profile for the image config and then use that to build the boot image.
-	profile := bootImageProfileRule(ctx, imageConfig)
+	unverifiedFragments, verifiedFragments := b.bootImageProfileFragments(ctx)
+	profile := bootImageProfileRule(ctx, imageConfig, unverifiedFragments, verifiedFragments)
 
 	// Build boot image files for the android variants.
 	androidBootImageFilesByArch := buildBootImageVariantsForAndroidOs(ctx, imageConfig, profile)
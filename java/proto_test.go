@@ -51,3 +51,53 @@ func TestProtoStream(t *testing.T) {
 		t.Errorf("expected '--javastream_out' in %q", cmd)
 	}
 }
+
+func TestProtoDeps(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["c.proto"],
+			proto: {
+				deps: ["b-protos"],
+			},
+		}
+	`
+
+	ctx := android.GroupFixturePreparers(
+		PrepareForIntegrationTestWithJava,
+		android.FixtureAddTextFile("a/Android.bp", `
+			java_library {
+				name: "a-protos",
+				srcs: ["a.proto"],
+				proto: {
+					canonical_path_from_root: false,
+				},
+			}
+		`),
+		android.FixtureAddTextFile("b/Android.bp", `
+			java_library {
+				name: "b-protos",
+				srcs: ["b.proto"],
+				proto: {
+					canonical_path_from_root: false,
+					deps: ["a-protos"],
+				},
+			}
+		`),
+		android.FixtureMergeMockFs(android.MockFS{
+			"a/a.proto": nil,
+			"b/b.proto": nil,
+			"c.proto":   nil,
+		}),
+	).RunTestWithBp(t, protoModules+bp)
+
+	b := ctx.ModuleForTests("b-protos", "android_common").Output("proto/proto0.srcjar")
+	android.AssertStringDoesContain(t, "b-protos protoc command", b.RuleParams.Command, " -Ia ")
+	android.AssertStringListContains(t, "b-protos protoc implicits", b.Implicits.Strings(), "a/a.proto")
+
+	// The proto files and include dirs of a-protos are propagated through b-protos.
+	foo := ctx.ModuleForTests("foo", "android_common").Output("proto/proto0.srcjar")
+	android.AssertStringDoesContain(t, "foo protoc command", foo.RuleParams.Command, " -Ib -Ia ")
+	android.AssertStringListContains(t, "foo protoc implicits", foo.Implicits.Strings(), "a/a.proto")
+	android.AssertStringListContains(t, "foo protoc implicits", foo.Implicits.Strings(), "b/b.proto")
+}
@@ -54,6 +54,12 @@ type robolectricProperties struct {
 	// Additional libraries for which coverage data should be generated
 	Coverage_libs []string
 
+	// Prebuilt jars, or modules producing jars, that are added to the runtime classpath of the
+	// tests as is.  Unlike libs and static_libs they are not compiled against or processed in any
+	// way.  They are merged into the jar installed with the test after the test classes and before
+	// the Robolectric runtime, so they are staged and loaded with it.
+	Resource_jars []string `android:"path"`
+
 	Test_options struct {
 		// Timeout in seconds when running the tests.
 		Timeout *int64
@@ -87,6 +93,9 @@ type robolectricTest struct {
 
 	roboSrcJar android.Path
 
+	// The jars listed in resource_jars.
+	resourceJars android.Paths
+
 	testConfig android.Path
 	data       android.Paths
 
@@ -152,9 +161,9 @@ func (r *robolectricTest) GenerateAndroidBuildActions(ctx android.ModuleContext)
 	}
 
 	r.manifest = instrumentedApp.mergedManifestFile
-	r.resourceApk = instrumentedApp.outputFile
+	r.resourceApk = r.linkResourceApk(ctx, instrumentedApp, r.staticLibResourceApks(ctx))
 
-	generateRoboTestConfig(ctx, roboTestConfig, instrumentedApp)
+	generateRoboTestConfig(ctx, roboTestConfig, r.manifest, r.resourceApk)
 	r.extraResources = android.Paths{roboTestConfig}
 
 	r.Library.GenerateAndroidBuildActions(ctx)
@@ -175,6 +184,11 @@ func (r *robolectricTest) GenerateAndroidBuildActions(ctx android.ModuleContext)
 		instrumentedApp.implementationAndResourcesJar,
 	}
 
+	// resource_jars come after the classes of the test so that they cannot shadow them, but before
+	// the Robolectric runtime and the other libs.
+	r.resourceJars = android.PathsForModuleSrc(ctx, r.robolectricProperties.Resource_jars)
+	combinedJarJars = append(combinedJarJars, r.resourceJars...)
+
 	for _, dep := range ctx.GetDirectDepsWithTag(libTag) {
 		m := ctx.OtherModuleProvider(dep, JavaInfoProvider).(JavaInfo)
 		r.libs = append(r.libs, ctx.OtherModuleName(dep))
@@ -225,13 +239,65 @@ func (r *robolectricTest) GenerateAndroidBuildActions(ctx android.ModuleContext)
 	r.installFile = ctx.InstallFile(installPath, ctx.ModuleName()+".jar", r.combinedJar, installDeps...)
 }
 
+// staticLibResourceApks returns the resource packages of the android_library modules in
+// static_libs, whose classes are compiled into the test by Library.  It reports the static_libs
+// that have native JNI libraries, which cannot be loaded by robolectric tests.
+func (r *robolectricTest) staticLibResourceApks(ctx android.ModuleContext) android.Paths {
+	var apks android.Paths
+	ctx.WalkDeps(func(child, parent android.Module) bool {
+		tag := ctx.OtherModuleDependencyTag(child)
+		if parent == ctx.Module() {
+			if tag != staticLibTag {
+				return false
+			}
+			if lib, ok := child.(AndroidLibraryDependency); ok && lib.ExportPackage() != nil {
+				apks = append(apks, lib.ExportPackage())
+			}
+			return true
+		}
+		if tag == jniLibTag || tag == jniInstallTag {
+			ctx.PropertyErrorf("static_libs", "module %q has native JNI libraries, which are not supported in robolectric tests",
+				ctx.OtherModuleName(parent))
+		}
+		return false
+	})
+	return android.FirstUniquePaths(apks)
+}
+
+// linkResourceApk returns the resource apk that the tests run against.  Robolectric loads the
+// resources from a single apk, so when static_libs have resources of their own they are linked
+// together with the resources of the instrumented app, which take priority over them.
+func (r *robolectricTest) linkResourceApk(ctx android.ModuleContext, app *AndroidApp,
+	libraryResourceApks android.Paths) android.Path {
+	if len(libraryResourceApks) == 0 {
+		return app.outputFile
+	}
+
+	// As for apps with static android libraries, every compiled resource becomes an overlay.
+	var compiledOverlay android.Paths
+	compiledOverlay = append(compiledOverlay, libraryResourceApks...)
+	compiledOverlay = append(compiledOverlay, app.aapt.compiledRes...)
+	compiledOverlay = append(compiledOverlay, app.aapt.compiledOverlay...)
+
+	linkFlags := android.CopyOf(app.aapt.linkFlags)
+	if !android.InList("--auto-add-overlay", linkFlags) {
+		linkFlags = append(linkFlags, "--auto-add-overlay")
+	}
+
+	packageRes := android.PathForModuleOut(ctx, "robolectric_resources", "package-res.apk")
+	aapt2Link(ctx, packageRes,
+		android.PathForModuleOut(ctx, "robolectric_resources", "R.srcjar"),
+		android.PathForModuleOut(ctx, "robolectric_resources", "proguard.options"),
+		android.PathForModuleOut(ctx, "robolectric_resources", "R.txt"),
+		android.PathForModuleOut(ctx, "robolectric_resources", "extra_packages"),
+		linkFlags, app.aapt.linkDeps, nil, compiledOverlay, app.aapt.assetPackages, nil)
+	return packageRes
+}
+
 func generateRoboTestConfig(ctx android.ModuleContext, outputFile android.WritablePath,
-	instrumentedApp *AndroidApp) {
+	manifest, resourceApk android.Path) {
 	rule := android.NewRuleBuilder(pctx, ctx)
 
-	manifest := instrumentedApp.mergedManifestFile
-	resourceApk := instrumentedApp.outputFile
-
 	rule.Command().Text("rm -f").Output(outputFile)
 	rule.Command().
 		Textf(`echo "android_merged_manifest=%s" >>`, manifest.String()).Output(outputFile).Text("&&").
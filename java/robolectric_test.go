@@ -0,0 +1,229 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"android/soong/android"
+	"android/soong/cc"
+)
+
+var prepareForRobolectricTest = android.GroupFixturePreparers(
+	prepareForJavaTest,
+	android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+		ctx.RegisterModuleType("android_robolectric_test", RobolectricTestFactory)
+		ctx.RegisterModuleType("android_robolectric_runtimes", robolectricRuntimesFactory)
+	}),
+	android.FixtureAddTextFile("robolectric/Android.bp", `
+		java_library {
+			name: "Robolectric_all-target",
+			srcs: ["Robo.java"],
+		}
+
+		java_library {
+			name: "mockito-robolectric-prebuilt",
+			srcs: ["Mockito.java"],
+		}
+
+		java_library {
+			name: "truth-prebuilt",
+			srcs: ["Truth.java"],
+		}
+
+		java_library {
+			name: "junitxml",
+			srcs: ["JUnitXml.java"],
+		}
+
+		android_robolectric_runtimes {
+			name: "robolectric-android-all-prebuilts",
+			jars: ["android-all.jar"],
+		}
+
+		android_app {
+			name: "robo-app",
+			srcs: ["App.java"],
+			sdk_version: "current",
+		}
+	`),
+)
+
+func TestRobolectricStaticAndroidLibrary(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+
+	result := android.GroupFixturePreparers(
+		prepareForRobolectricTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"res/values/strings.xml": nil,
+		}),
+	).RunTestWithBp(t, `
+		android_library {
+			name: "libres",
+			srcs: ["a.java"],
+			resource_dirs: ["res"],
+			sdk_version: "current",
+		}
+
+		android_robolectric_test {
+			name: "robo-test",
+			srcs: ["FooTest.java"],
+			static_libs: ["libres"],
+			instrumentation_for: "robo-app",
+		}
+	`)
+
+	libres := result.ModuleForTests("libres", "android_common")
+	roboTest := result.ModuleForTests("robo-test", "android_common")
+
+	// The classes of the device android_library are compiled into the test jar.
+	javac := roboTest.Rule("javac")
+	android.AssertStringDoesContain(t, "javac classpath", javac.Args["classpath"],
+		"/libres/android_common/")
+
+	// The resource package of the android_library is linked together with the resources of the
+	// instrumented app into the resource apk that the tests run against.
+	link := roboTest.Output("robolectric_resources/package-res.apk")
+	android.AssertStringListContains(t, "resource link inputs", link.Implicits.Strings(),
+		libres.Output("package-res.apk").Output.String())
+
+	installedResourceApk := roboTest.Output("out/soong/host/linux-x86/testcases/robo-test/robo-test.apk")
+	android.AssertStringEquals(t, "installed resource apk input",
+		link.Output.String(), installedResourceApk.Input.String())
+	android.AssertBoolEquals(t, "library resources installed separately", false,
+		roboTest.MaybeOutput("out/soong/host/linux-x86/testcases/robo-test/resources/libres.apk").Rule != nil)
+}
+
+func TestRobolectricWithoutStaticAndroidLibrary(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+
+	result := prepareForRobolectricTest.RunTestWithBp(t, `
+		java_library {
+			name: "libjava",
+			srcs: ["a.java"],
+		}
+
+		android_robolectric_test {
+			name: "robo-test",
+			srcs: ["FooTest.java"],
+			static_libs: ["libjava"],
+			instrumentation_for: "robo-app",
+		}
+	`)
+
+	roboTest := result.ModuleForTests("robo-test", "android_common")
+	app := result.ModuleForTests("robo-app", "android_common")
+
+	// Without resources in static_libs the tests run against the apk of the instrumented app.
+	installedResourceApk := roboTest.Output("out/soong/host/linux-x86/testcases/robo-test/robo-test.apk")
+	android.AssertStringEquals(t, "installed resource apk input",
+		app.Output("robo-app.apk").Output.String(), installedResourceApk.Input.String())
+}
+
+func TestRobolectricStaticLibraryWithJni(t *testing.T) {
+	for _, moduleType := range []string{"android_app", "android_test"} {
+		t.Run(moduleType, func(t *testing.T) {
+			android.GroupFixturePreparers(
+				prepareForRobolectricTest,
+			).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+				`static_libs: module "app-with-jni" has native JNI libraries, which are not supported in robolectric tests`)).
+				RunTestWithBp(t, cc.GatherRequiredDepsForTest(android.Android)+`
+				cc_library {
+					name: "libjni",
+					system_shared_libs: [],
+					sdk_version: "current",
+					stl: "none",
+				}
+
+				`+moduleType+` {
+					name: "app-with-jni",
+					srcs: ["a.java"],
+					sdk_version: "current",
+					jni_libs: ["libjni"],
+				}
+
+				android_robolectric_test {
+					name: "robo-test",
+					srcs: ["FooTest.java"],
+					static_libs: ["app-with-jni"],
+					instrumentation_for: "robo-app",
+				}
+			`)
+		})
+	}
+}
+
+func TestRobolectricResourceJars(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+
+	result := android.GroupFixturePreparers(
+		prepareForRobolectricTest,
+		android.FixtureModifyConfig(android.SetKatiEnabledForTests),
+		android.FixtureMergeMockFs(android.MockFS{
+			"res-a.jar": nil,
+			"res-b.jar": nil,
+		}),
+	).RunTestWithBp(t, `
+		android_robolectric_test {
+			name: "robo-test",
+			srcs: ["FooTest.java"],
+			resource_jars: ["res-a.jar", "res-b.jar"],
+			instrumentation_for: "robo-app",
+		}
+	`)
+
+	roboTest := result.ModuleForTests("robo-test", "android_common")
+	robolectric := result.ModuleProvider(result.Module("Robolectric_all-target", "android_common"),
+		JavaInfoProvider).(JavaInfo)
+
+	// atest stages the files that module-info.json lists for the test, which come from the install
+	// pairs. The resource jars are not installed separately, but merged into the installed jar.
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, roboTest.Module())[0]
+	installedJar := entries.EntryMap["LOCAL_SOONG_INSTALLED_MODULE"][0]
+	android.AssertStringEquals(t, "installed module", "robo-test.jar", filepath.Base(installedJar))
+	var combinedJar string
+	for _, pair := range strings.Fields(strings.Join(entries.EntryMap["LOCAL_SOONG_INSTALL_PAIRS"], " ")) {
+		android.AssertStringDoesNotContain(t, "install pair", pair, "res-a.jar")
+		if strings.HasSuffix(pair, ":"+installedJar) {
+			combinedJar = strings.TrimSuffix(pair, ":"+installedJar)
+		}
+	}
+
+	// The runner loads the tests from the installed jar, whose classes are merged from the jars on
+	// the runtime classpath, in order.
+	classpath := android.PathsRelativeToTop(roboTest.Output(combinedJar).Inputs)
+	testJarIndex := android.IndexList(
+		android.PathRelativeToTop(roboTest.Module().(*robolectricTest).outputFile), classpath)
+	resAIndex := android.IndexList("res-a.jar", classpath)
+	resBIndex := android.IndexList("res-b.jar", classpath)
+	runtimeIndex := android.IndexList(
+		android.PathRelativeToTop(robolectric.ImplementationAndResourcesJars[0]), classpath)
+
+	if testJarIndex == -1 || resAIndex == -1 || resBIndex == -1 || runtimeIndex == -1 {
+		t.Fatalf("missing expected jars in the runtime classpath %q", classpath)
+	}
+	if !(testJarIndex < resAIndex && resAIndex < resBIndex && resBIndex < runtimeIndex) {
+		t.Errorf("expected test jar, res-a.jar, res-b.jar and the Robolectric runtime in order, got %q", classpath)
+	}
+}
@@ -452,6 +452,12 @@ type sdkLibraryProperties struct {
 	// Do not use.
 	Unsafe_ignore_missing_latest_api bool
 
+	// If set to true, generate a JSON report of the classes and members that were added, removed
+	// or changed in each stable API surface since the latest released API.  The report is
+	// available through the ".api-diff" output file tag, e.g. so that it can be copied to the dist
+	// directory.
+	Api_diff_report *bool
+
 	// indicates whether system and test apis should be generated.
 	Generate_system_and_test_apis bool `blueprint:"mutated"`
 
@@ -968,6 +974,16 @@ func (c *commonToSdkLibraryAndImport) sdkComponentPropertiesForChildLibrary() in
 	return componentProps
 }
 
+// sdkStubsComponentProperties returns the properties that mark a library created by the
+// java_sdk_library/_import as containing its API stubs.
+func (c *commonToSdkLibraryAndImport) sdkStubsComponentProperties() interface{} {
+	return &struct {
+		SdkLibraryStubs *bool
+	}{
+		SdkLibraryStubs: proptools.BoolPtr(true),
+	}
+}
+
 func (c *commonToSdkLibraryAndImport) sharedLibrary() bool {
 	return proptools.BoolDefault(c.commonSdkLibraryProperties.Shared_library, true)
 }
@@ -988,6 +1004,10 @@ type SdkLibraryComponentProperties struct {
 	// in the AndroidManifest.xml of any Android app that includes code that references
 	// this module. If not set then no java_sdk_library/_import is tracked.
 	SdkLibraryToImplicitlyTrack *string `blueprint:"mutated"`
+
+	// Whether the module contains the API stubs of the java_sdk_library/_import rather than its
+	// implementation.
+	SdkLibraryStubs *bool `blueprint:"mutated"`
 }
 
 // Structure to be embedded in a module struct that needs to support the
@@ -1000,6 +1020,10 @@ func (e *EmbeddableSdkLibraryComponent) initSdkLibraryComponent(module android.M
 	module.AddProperties(&e.sdkLibraryComponentProperties)
 }
 
+func (e *EmbeddableSdkLibraryComponent) sdkLibraryStubs() bool {
+	return proptools.Bool(e.sdkLibraryComponentProperties.SdkLibraryStubs)
+}
+
 // to satisfy SdkLibraryComponentDependency
 func (e *EmbeddableSdkLibraryComponent) SdkLibraryName() *string {
 	return e.sdkLibraryComponentProperties.SdkLibraryName
@@ -1075,6 +1099,9 @@ type SdkLibrary struct {
 	scopeToProperties map[*apiScope]*ApiScopeProperties
 
 	commonToSdkLibraryAndImport
+
+	// The report generated when api_diff_report is set.
+	apiDiffReport android.Path
 }
 
 var _ SdkLibraryDependency = (*SdkLibrary)(nil)
@@ -1216,13 +1243,71 @@ func (module *SdkLibrary) DepsMutator(ctx android.BottomUpMutatorContext) {
 		m += "Please see the documentation of the prebuilt_apis module type (and a usage example in prebuilts/sdk) for a convenient way to generate these."
 		ctx.ModuleErrorf(m)
 	}
+	if proptools.Bool(module.sdkLibraryProperties.Api_diff_report) {
+		for _, apiScope := range module.apiDiffReportScopes(ctx) {
+			latestApi := module.latestApiFilegroupName(apiScope)
+			android.ExtractSourceDeps(ctx, &latestApi)
+		}
+	}
 	if module.requiresRuntimeImplementationLibrary() {
 		// Only add the deps for the library if it is actually going to be built.
 		module.Library.deps(ctx)
 	}
 }
 
+// apiDiffReportScopes returns the stable api scopes that have a latest released API to compare
+// the current API against.
+func (module *SdkLibrary) apiDiffReportScopes(ctx android.BaseModuleContext) apiScopes {
+	var scopes apiScopes
+	for _, apiScope := range module.getGeneratedApiScopes(ctx) {
+		if apiScope.unstable {
+			continue
+		}
+		if !ctx.OtherModuleExists(android.SrcIsModule(module.latestApiFilegroupName(apiScope))) {
+			continue
+		}
+		scopes = append(scopes, apiScope)
+	}
+	return scopes
+}
+
+// buildApiDiffReport creates a rule that compares the current API of every stable api scope with
+// the latest released API.
+func (module *SdkLibrary) buildApiDiffReport(ctx android.ModuleContext) {
+	rule := android.NewRuleBuilder(pctx, ctx)
+	cmd := rule.Command().BuiltTool("api_diff_report")
+
+	scopes := 0
+	for _, apiScope := range module.apiDiffReportScopes(ctx) {
+		paths := module.findScopePaths(apiScope)
+		if paths == nil || !paths.currentApiFilePath.Valid() {
+			continue
+		}
+		cmd.Flag("--surface").
+			Text(apiScope.name).
+			Input(android.PathForModuleSrc(ctx, module.latestApiFilegroupName(apiScope))).
+			Input(paths.currentApiFilePath.Path())
+		scopes++
+	}
+	if scopes == 0 {
+		ctx.PropertyErrorf("api_diff_report", "no stable api scope has a released API to compare against")
+		return
+	}
+
+	report := android.PathForModuleOut(ctx, "api_diff_report", module.BaseModuleName()+".api-diff.json")
+	cmd.FlagWithOutput("--output ", report)
+	rule.Build("api_diff_report", "api diff report")
+
+	module.apiDiffReport = report
+}
+
 func (module *SdkLibrary) OutputFiles(tag string) (android.Paths, error) {
+	if tag == ".api-diff" {
+		if module.apiDiffReport == nil {
+			return nil, fmt.Errorf("api_diff_report is not set on %s", module.BaseModuleName())
+		}
+		return android.Paths{module.apiDiffReport}, nil
+	}
 	paths, err := module.commonOutputFiles(tag)
 	if paths != nil || err != nil {
 		return paths, err
@@ -1274,6 +1359,10 @@ func (module *SdkLibrary) GenerateAndroidBuildActions(ctx android.ModuleContext)
 	// Make the set of components exported by this module available for use elsewhere.
 	exportedComponentInfo := android.ExportedComponentsInfo{Components: android.SortedStringKeys(exportedComponents)}
 	ctx.SetProvider(android.ExportedComponentsInfoProvider, exportedComponentInfo)
+
+	if proptools.Bool(module.sdkLibraryProperties.Api_diff_report) {
+		module.buildApiDiffReport(ctx)
+	}
 }
 
 func (module *SdkLibrary) AndroidMkEntries() []android.AndroidMkEntries {
@@ -1447,7 +1536,7 @@ func (module *SdkLibrary) createStubsLibrary(mctx android.DefaultableHookContext
 		props.Dist.Tag = proptools.StringPtr(".jar")
 	}
 
-	mctx.CreateModule(LibraryFactory, &props, module.sdkComponentPropertiesForChildLibrary())
+	mctx.CreateModule(LibraryFactory, &props, module.sdkComponentPropertiesForChildLibrary(), module.sdkStubsComponentProperties())
 }
 
 // Creates a droidstubs module that creates stubs source files from the given full source
@@ -2140,7 +2229,7 @@ func (module *SdkLibraryImport) createJavaImportForStubs(mctx android.Defaultabl
 	}
 	props.Compile_dex = compileDex
 
-	mctx.CreateModule(ImportFactory, &props, module.sdkComponentPropertiesForChildLibrary())
+	mctx.CreateModule(ImportFactory, &props, module.sdkComponentPropertiesForChildLibrary(), module.sdkStubsComponentProperties())
 }
 
 func (module *SdkLibraryImport) createPrebuiltStubsSources(mctx android.DefaultableHookContext, apiScope *apiScope, scopeProperties *sdkLibraryScopeProperties) {
@@ -510,6 +510,64 @@ func TestJavaSdkLibrary_AccessOutputFiles_MissingScope(t *testing.T) {
 		`)
 }
 
+func TestJavaSdkLibrary_ApiDiffReport(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		PrepareForTestWithJavaSdkLibraryFiles,
+		FixtureWithLastReleaseApis("foo", "bar"),
+	).RunTestWithBp(t, `
+		java_sdk_library {
+			name: "foo",
+			srcs: ["a.java"],
+			api_packages: ["foo"],
+			api_diff_report: true,
+			public: {
+				enabled: true,
+			},
+			system: {
+				enabled: true,
+			},
+			test: {
+				enabled: true,
+			},
+		}
+
+		java_sdk_library {
+			name: "bar",
+			srcs: ["a.java"],
+			api_packages: ["bar"],
+			public: {
+				enabled: true,
+			},
+		}
+		`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	fooModule := foo.Module().(*SdkLibrary)
+	rule := foo.Rule("api_diff_report")
+
+	currentApi := func(scope *apiScope) string {
+		return android.PathRelativeToTop(fooModule.findScopePaths(scope).currentApiFilePath.Path())
+	}
+	report := "out/soong/.intermediates/foo/android_common/api_diff_report/foo.api-diff.json"
+
+	// The test scope is unstable so it is not compared.
+	android.AssertStringDoesContain(t, "api diff report command", rule.RuleParams.Command,
+		"out/soong/host/linux-x86/bin/api_diff_report"+
+			" --surface public prebuilts/sdk/30/public/api/foo.txt "+currentApi(apiScopePublic)+
+			" --surface system prebuilts/sdk/30/system/api/foo.txt "+currentApi(apiScopeSystem)+
+			" --output "+report)
+	android.AssertPathsRelativeToTopEquals(t, "api diff report", []string{report}, foo.OutputFiles(t, ".api-diff"))
+
+	// The report is only generated when api_diff_report is set.
+	bar := result.ModuleForTests("bar", "android_common")
+	if bar.MaybeRule("api_diff_report").Rule != nil {
+		t.Errorf("unexpected api diff report for bar")
+	}
+	_, err := bar.Module().(*SdkLibrary).OutputFiles(".api-diff")
+	android.AssertErrorMessageEquals(t, "bar api diff output file", "api_diff_report is not set on bar", err)
+}
+
 func TestJavaSdkLibrary_Deps(t *testing.T) {
 	result := android.GroupFixturePreparers(
 		prepareForJavaTest,
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"android/soong/android"
+	"android/soong/dexpreopt"
 )
 
 var prepareForTestWithSystemServerClasspath = android.GroupFixturePreparers(
@@ -53,6 +54,30 @@ func TestPlatformSystemServerClasspath_ClasspathFragmentPaths(t *testing.T) {
 	android.AssertPathRelativeToTopEquals(t, "install filepath", "out/soong/target/product/test_device/system/etc/classpaths", p.ClasspathFragmentBase.installDirPath)
 }
 
+func TestPlatformSystemServerClasspath_StandaloneJars(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForTestWithSystemServerClasspath,
+		dexpreopt.FixtureSetSystemServerJars("platform:foo"),
+		dexpreopt.FixtureSetStandaloneSystemServerJars("platform:bar"),
+		android.FixtureWithRootAndroidBp(`
+			platform_systemserverclasspath {
+				name: "platform-systemserverclasspath",
+			}
+		`),
+	).RunTest(t)
+
+	module := result.ModuleForTests("platform-systemserverclasspath", "android_common")
+
+	info := result.ModuleProvider(module.Module(), ClasspathFragmentProtoContentInfoProvider).(ClasspathFragmentProtoContentInfo)
+	android.AssertStringEquals(t, "classpath proto contents", "platform:foo,platform:bar", info.ClasspathFragmentProtoContents.String())
+
+	textproto := android.ContentFromFileRuleForTests(t, module.Output("systemserverclasspath.pb.textproto"))
+	android.AssertStringDoesContain(t, "classpath jar", textproto,
+		"path: \"/system/framework/foo.jar\"\nclasspath: SYSTEMSERVERCLASSPATH\n")
+	android.AssertStringDoesContain(t, "standalone jar", textproto,
+		"path: \"/system/framework/bar.jar\"\nclasspath: STANDALONE_SYSTEMSERVER_JARS\n")
+}
+
 func TestPlatformSystemServerClasspathModule_AndroidMkEntries(t *testing.T) {
 	preparer := android.GroupFixturePreparers(
 		prepareForTestWithSystemServerClasspath,
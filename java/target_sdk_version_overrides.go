@@ -0,0 +1,61 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"fmt"
+	"strings"
+
+	"android/soong/android"
+)
+
+// targetSdkVersionOverridden is implemented by the app modules, whose targetSdkVersion can be
+// set by PRODUCT_TARGET_SDK_VERSION_OVERRIDES.
+type targetSdkVersionOverridden interface {
+	overriddenTargetSdkVersion() string
+}
+
+func (a *AndroidApp) overriddenTargetSdkVersion() string {
+	return a.aapt.TargetSdkVersionOverride
+}
+
+func targetSdkVersionOverridesSingletonFactory() android.Singleton {
+	return &targetSdkVersionOverridesSingleton{}
+}
+
+type targetSdkVersionOverridesSingleton struct{}
+
+// GenerateBuildActions writes out/soong/target_sdk_version_overrides.txt, which lists the apps
+// whose targetSdkVersion is set by PRODUCT_TARGET_SDK_VERSION_OVERRIDES, so that the effect of a
+// path prefix can be reviewed when bumping the targetSdkVersion of many apps at once.
+func (targetSdkVersionOverridesSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	overrides := make(map[string]bool)
+	ctx.VisitAllModules(func(module android.Module) {
+		app, ok := module.(targetSdkVersionOverridden)
+		if !ok || !module.Enabled() || app.overriddenTargetSdkVersion() == "" {
+			return
+		}
+		overrides[fmt.Sprintf("%s %s %s", ctx.ModuleName(module), ctx.ModuleDir(module),
+			app.overriddenTargetSdkVersion())] = true
+	})
+	if len(overrides) == 0 {
+		return
+	}
+
+	lines := android.SortedStringKeys(overrides)
+	report := android.PathForOutput(ctx, "target_sdk_version_overrides.txt")
+	android.WriteFileRule(ctx, report, strings.Join(lines, "\n"))
+	ctx.Phony("target_sdk_version_overrides", report)
+}
@@ -64,12 +64,15 @@ func (p *testDecorator) AndroidMk(base *Module, entries *android.AndroidMkEntrie
 			if p.testConfig != nil {
 				entries.SetString("LOCAL_FULL_TEST_CONFIG", p.testConfig.String())
 			}
+			entries.AddStrings("LOCAL_EXTRA_FULL_TEST_CONFIGS", p.shardTestConfigs.Strings()...)
 
 			entries.SetBoolIfTrue("LOCAL_DISABLE_AUTO_GENERATE_TEST_CONFIG", !BoolDefault(p.binaryProperties.Auto_gen_config, true))
 
 			entries.AddStrings("LOCAL_TEST_DATA", android.AndroidMkDataPaths(p.data)...)
 
 			entries.SetBoolIfTrue("LOCAL_IS_UNIT_TEST", Bool(p.testProperties.Test_options.Unit_test))
+
+			entries.SetBoolIfTrue("LOCAL_COMPATIBILITY_PER_TESTCASE_DIRECTORY", Bool(p.testProperties.Per_testcase_directory))
 		})
 	base.subAndroidMk(entries, p.binaryDecorator.pythonInstaller)
 }
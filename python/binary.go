@@ -114,6 +114,16 @@ type BinaryProperties struct {
 	// doesn't exist next to the Android.bp, this attribute doesn't need to be set to true
 	// explicitly.
 	Auto_gen_config *bool
+
+	// whether to deflate all the entries of the par file, including the ones that are stored
+	// uncompressed in the zips of the sources. Defaults to false.
+	Compression *bool
+
+	// whether to precompile the python sources to .pyc files that are added to the par file, so
+	// that they are not compiled each time the program starts. The .pyc files do not depend on
+	// timestamps, keeping the par file deterministic. Only supported for python 3 with an embedded
+	// launcher, as the .pyc files must match the interpreter of the par file. Defaults to false.
+	Precompile *bool
 }
 
 type binaryDecorator struct {
@@ -178,9 +188,18 @@ func (binary *binaryDecorator) bootstrap(ctx android.ModuleContext, actualVersio
 		})
 	}
 
+	options := parOptions{
+		compression: Bool(binary.binaryProperties.Compression),
+		precompile:  Bool(binary.binaryProperties.Precompile),
+	}
+	if options.precompile && (!embeddedLauncher || actualVersion != pyVersion3) {
+		ctx.PropertyErrorf("precompile", "only supported for python 3 with an embedded launcher")
+		options.precompile = false
+	}
+
 	binFile := registerBuildActionForParFile(ctx, embeddedLauncher, launcherPath,
 		binary.getHostInterpreterName(ctx, actualVersion),
-		main, binary.getStem(ctx), append(android.Paths{srcsZip}, depsSrcsZips...))
+		main, binary.getStem(ctx), append(android.Paths{srcsZip}, depsSrcsZips...), options)
 
 	return android.OptionalPathForPath(binFile)
 }
@@ -46,29 +46,37 @@ var (
 		blueprint.RuleParams{
 			Command: `sed -e 's/%interpreter%/$interp/g' -e 's/%main%/$main/g' $template > $stub && ` +
 				`echo "#!/usr/bin/env $interp" >${out}.prefix &&` +
-				`$mergeParCmd -p --prefix ${out}.prefix -pm $stub $out $srcsZips && ` +
+				`$mergeParCmd -p $mergeFlags --prefix ${out}.prefix -pm $stub $out $srcsZips && ` +
 				`chmod +x $out && (rm -f $stub; rm -f ${out}.prefix)`,
 			CommandDeps: []string{"$mergeParCmd"},
 		},
-		"interp", "main", "template", "stub", "srcsZips")
+		"interp", "main", "template", "stub", "srcsZips", "mergeFlags")
 
 	embeddedPar = pctx.AndroidStaticRule("embeddedPar",
 		blueprint.RuleParams{
 			Command: `rm -f $out.main && ` +
 				`sed 's/ENTRY_POINT/$main/' build/soong/python/scripts/main.py >$out.main &&` +
-				`$mergeParCmd -p -pm $out.main --prefix $launcher $out $srcsZips && ` +
+				`$mergeParCmd -p $mergeFlags -pm $out.main --prefix $launcher $out $srcsZips && ` +
 				`chmod +x $out && rm -rf $out.main`,
 			CommandDeps: []string{"$mergeParCmd", "$parCmd", "build/soong/python/scripts/main.py"},
 		},
-		"main", "srcsZips", "launcher")
+		"main", "srcsZips", "launcher", "mergeFlags")
 
 	embeddedParNoMain = pctx.AndroidStaticRule("embeddedParNoMain",
 		blueprint.RuleParams{
-			Command: `$mergeParCmd -p --prefix $launcher $out $srcsZips && ` +
+			Command: `$mergeParCmd -p $mergeFlags --prefix $launcher $out $srcsZips && ` +
 				`chmod +x $out`,
 			CommandDeps: []string{"$mergeParCmd"},
 		},
-		"srcsZips", "launcher")
+		"srcsZips", "launcher", "mergeFlags")
+
+	// Precompiles the python sources of the zips into a zip of .pyc files placed next to them.
+	precompile = pctx.AndroidStaticRule("precompile",
+		blueprint.RuleParams{
+			Command:     `$precompileCmd $precompileFlags -o $out $in`,
+			CommandDeps: []string{"$precompileCmd"},
+		},
+		"precompileFlags")
 )
 
 func init() {
@@ -77,15 +85,60 @@ func init() {
 
 	pctx.HostBinToolVariable("parCmd", "soong_zip")
 	pctx.HostBinToolVariable("mergeParCmd", "merge_zips")
+	pctx.HostBinToolVariable("precompileCmd", "precompile_python")
+}
+
+// parOptions are the options of the par file of a python binary.
+type parOptions struct {
+	// Whether the entries of the par file are deflated, including the ones that are stored
+	// uncompressed in the zips of the sources.
+	compression bool
+
+	// Whether the python sources are precompiled to .pyc files that are added to the par file.
+	precompile bool
+}
+
+// registerBuildActionForPrecompile generates a rule that precompiles the python sources of the
+// given zips, and returns the zip of the .pyc files.
+func registerBuildActionForPrecompile(ctx android.ModuleContext, binName string,
+	srcsZips android.Paths, compression bool) android.Path {
+
+	pycZip := android.PathForModuleOut(ctx, binName+".pyc.zip")
+	flags := ""
+	if compression {
+		flags = "--deflate"
+	}
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        precompile,
+		Description: "precompile python sources",
+		Inputs:      srcsZips,
+		Output:      pycZip,
+		Args: map[string]string{
+			"precompileFlags": flags,
+		},
+	})
+	return pycZip
 }
 
 func registerBuildActionForParFile(ctx android.ModuleContext, embeddedLauncher bool,
 	launcherPath android.OptionalPath, interpreter, main, binName string,
-	srcsZips android.Paths) android.Path {
+	srcsZips android.Paths, options parOptions) android.Path {
 
 	// .intermediate output path for bin executable.
 	binFile := android.PathForModuleOut(ctx, binName)
 
+	if options.precompile {
+		// The .pyc files are placed after the sources, which take precedence over them if they are
+		// duplicated in the zips.
+		srcsZips = append(srcsZips,
+			registerBuildActionForPrecompile(ctx, binName, srcsZips, options.compression))
+	}
+
+	mergeFlags := ""
+	if options.compression {
+		mergeFlags = "-deflate"
+	}
+
 	// implicit dependency for parFile build action.
 	implicits := srcsZips
 
@@ -103,11 +156,12 @@ func registerBuildActionForParFile(ctx android.ModuleContext, embeddedLauncher b
 			Output:      binFile,
 			Implicits:   implicits,
 			Args: map[string]string{
-				"interp":   strings.Replace(interpreter, "/", `\/`, -1),
-				"main":     strings.Replace(main, "/", `\/`, -1),
-				"template": template.String(),
-				"stub":     stub,
-				"srcsZips": strings.Join(srcsZips.Strings(), " "),
+				"interp":     strings.Replace(interpreter, "/", `\/`, -1),
+				"main":       strings.Replace(main, "/", `\/`, -1),
+				"template":   template.String(),
+				"stub":       stub,
+				"srcsZips":   strings.Join(srcsZips.Strings(), " "),
+				"mergeFlags": mergeFlags,
 			},
 		})
 	} else if launcherPath.Valid() {
@@ -121,8 +175,9 @@ func registerBuildActionForParFile(ctx android.ModuleContext, embeddedLauncher b
 				Output:      binFile,
 				Implicits:   implicits,
 				Args: map[string]string{
-					"srcsZips": strings.Join(srcsZips.Strings(), " "),
-					"launcher": launcherPath.String(),
+					"srcsZips":   strings.Join(srcsZips.Strings(), " "),
+					"launcher":   launcherPath.String(),
+					"mergeFlags": mergeFlags,
 				},
 			})
 		} else {
@@ -132,9 +187,10 @@ func registerBuildActionForParFile(ctx android.ModuleContext, embeddedLauncher b
 				Output:      binFile,
 				Implicits:   implicits,
 				Args: map[string]string{
-					"main":     strings.Replace(strings.TrimSuffix(main, pyExt), "/", ".", -1),
-					"srcsZips": strings.Join(srcsZips.Strings(), " "),
-					"launcher": launcherPath.String(),
+					"main":       strings.Replace(strings.TrimSuffix(main, pyExt), "/", ".", -1),
+					"srcsZips":   strings.Join(srcsZips.Strings(), " "),
+					"launcher":   launcherPath.String(),
+					"mergeFlags": mergeFlags,
 				},
 			})
 		}
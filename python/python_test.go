@@ -19,6 +19,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 
 	"android/soong/android"
@@ -375,6 +376,375 @@ func expectModule(t *testing.T, ctx *android.TestContext, name, variant, expecte
 	android.AssertPathsRelativeToTopEquals(t, "depsSrcsZips", expectedDepsSrcsZips, base.depsSrcsZips)
 }
 
+func TestShardTestModules(t *testing.T) {
+	testModules := []string{"a_test", "b_test", "pkg.c_test", "pkg.test_d", "test_e", "test_f"}
+	shards := shardTestModules(testModules, 3)
+	android.AssertIntEquals(t, "number of shards", 3, len(shards))
+
+	var all []string
+	for _, shard := range shards {
+		all = append(all, shard...)
+	}
+	android.AssertDeepEquals(t, "all test modules", testModules, android.SortedUniqueStrings(all))
+
+	// Removing a test module doesn't move the other ones to other shards.
+	shardsWithoutB := shardTestModules(android.RemoveListFromList(testModules, []string{"b_test"}), 3)
+	for i := range shards {
+		android.AssertDeepEquals(t, fmt.Sprintf("shard %d", i),
+			android.RemoveListFromList(shards[i], []string{"b_test"}), shardsWithoutB[i])
+	}
+}
+
+var prepareForPythonTestShardsTest = android.GroupFixturePreparers(
+	android.PrepareForTestWithDefaults,
+	android.PrepareForTestWithAndroidMk,
+	PrepareForTestWithPythonBuildComponents,
+	android.FixtureMergeMockFs(android.MockFS{
+		"dir/main.py":       nil,
+		"dir/helper.py":     nil,
+		"dir/a_test.py":     nil,
+		"dir/pkg/b_test.py": nil,
+		"dir/test_c.py":     nil,
+		StubTemplateHost:    nil,
+	}),
+)
+
+func TestPythonTestHostShards(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForPythonTestShardsTest,
+		android.FixtureAddTextFile("dir/Android.bp", `
+			python_test_host {
+				name: "mytest",
+				main: "main.py",
+				srcs: [
+					"main.py",
+					"helper.py",
+					"a_test.py",
+					"pkg/b_test.py",
+					"test_c.py",
+				],
+				test_options: {
+					shards: 2,
+				},
+			}
+		`),
+	).RunTest(t)
+
+	mytest := result.ModuleForTests("mytest", "linux_glibc_x86_64_PY3")
+
+	var expectedConfigs []string
+	for i, shard := range shardTestModules([]string{"a_test", "pkg.b_test", "test_c"}, 2) {
+		if len(shard) == 0 {
+			continue
+		}
+		config := fmt.Sprintf("mytest_shard%d.config", i)
+		expectedConfigs = append(expectedConfigs,
+			"out/soong/.intermediates/dir/mytest/linux_glibc_x86_64_PY3/"+config)
+
+		extraConfigs := mytest.Output(config).Args["extraConfigs"]
+		for _, testModule := range shard {
+			android.AssertStringDoesContain(t, "shard config", extraConfigs,
+				`<option name="python-options" value="`+testModule+`" />`)
+		}
+		android.AssertStringDoesNotContain(t, "shard config", extraConfigs, `value="main"`)
+		android.AssertStringDoesNotContain(t, "shard config", extraConfigs, `value="helper"`)
+	}
+
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, mytest.Module())[0]
+	android.AssertStringPathsRelativeToTopEquals(t, "LOCAL_EXTRA_FULL_TEST_CONFIGS", result.Config,
+		expectedConfigs, entries.EntryMap["LOCAL_EXTRA_FULL_TEST_CONFIGS"])
+
+	// Each shard is run by its own rule, and the merge rule writes the merged result only if they
+	// all pass.
+	var shardResults []string
+	for i, shard := range shardTestModules([]string{"a_test", "pkg.b_test", "test_c"}, 2) {
+		if len(shard) == 0 {
+			continue
+		}
+		shardResult := fmt.Sprintf("shards/shard%d.txt", i)
+		run := mytest.Output(shardResult)
+		android.AssertStringDoesContain(t, "run shard", android.StringRelativeToTop(result.Config, run.RuleParams.Command),
+			fmt.Sprintf("( out/soong/.intermediates/dir/mytest/linux_glibc_x86_64_PY3/mytest %s || echo \"FAILED: shard %d\") > out/soong/.intermediates/dir/mytest/linux_glibc_x86_64_PY3/shards/shard%d.txt 2>&1",
+				strings.Join(shard, " "), i, i))
+		shardResults = append(shardResults, "out/soong/.intermediates/dir/mytest/linux_glibc_x86_64_PY3/"+shardResult)
+	}
+	merge := mytest.Output("mytest_shards.txt")
+	mergeCommand := android.StringRelativeToTop(result.Config, merge.RuleParams.Command)
+	android.AssertArrayString(t, "merged shards", shardResults, android.PathsRelativeToTop(merge.Implicits))
+	android.AssertStringDoesContain(t, "merge shards", mergeCommand,
+		"> out/soong/.intermediates/dir/mytest/linux_glibc_x86_64_PY3/mytest_shards.txt")
+	android.AssertStringDoesContain(t, "check shards", mergeCommand, `if grep -q "^FAILED: shard"`)
+}
+
+func TestPythonTestPerTestcaseDirectory(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForPythonTestShardsTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"dir/testdata/data.txt": nil,
+		}),
+		android.FixtureAddTextFile("dir/Android.bp", `
+			python_test_host {
+				name: "mytest",
+				main: "main.py",
+				srcs: ["main.py"],
+				data: ["testdata/data.txt"],
+				test_suites: ["general-tests"],
+				per_testcase_directory: true,
+			}
+
+			python_test_host {
+				name: "mytest_default",
+				main: "main.py",
+				srcs: ["main.py"],
+				data: ["testdata/data.txt"],
+				test_suites: ["general-tests"],
+			}
+		`),
+	).RunTest(t)
+
+	entries := func(name string) android.AndroidMkEntries {
+		module := result.ModuleForTests(name, "linux_glibc_x86_64_PY3").Module()
+		return android.AndroidMkEntriesForTest(t, result.TestContext, module)[0]
+	}
+
+	android.AssertDeepEquals(t, "per testcase data", []string{"mytest/testdata/data.txt"},
+		android.AndroidMkTestDataSuitePaths(entries("mytest"), "mytest"))
+	android.AssertDeepEquals(t, "default data", []string{"testdata/data.txt"},
+		android.AndroidMkTestDataSuitePaths(entries("mytest_default"), "mytest_default"))
+}
+
+func TestPythonTestShardsErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		bp    string
+		error string
+	}{
+		{
+			name: "no test modules",
+			bp: `
+				python_test_host {
+					name: "mytest",
+					main: "main.py",
+					srcs: [
+						"main.py",
+						"helper.py",
+					],
+					test_options: {
+						shards: 2,
+					},
+				}
+			`,
+			error: `module "mytest".*: test_options.shards: no test modules, named test_\*.py or \*_test.py, found in srcs`,
+		},
+		{
+			name: "no shards",
+			bp: `
+				python_test_host {
+					name: "mytest",
+					main: "main.py",
+					srcs: [
+						"main.py",
+						"a_test.py",
+					],
+					test_options: {
+						shards: 0,
+					},
+				}
+			`,
+			error: `module "mytest".*: test_options.shards: must be at least 1, got 0`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			android.GroupFixturePreparers(
+				prepareForPythonTestShardsTest,
+				android.FixtureAddTextFile("dir/Android.bp", tc.bp),
+			).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(tc.error)).
+				RunTest(t)
+		})
+	}
+}
+
+// pythonTestLauncher is a fake for the launcher of python binaries with an embedded launcher and
+// for its shared libraries.
+type pythonTestLauncher struct {
+	android.ModuleBase
+	outputFile android.Path
+}
+
+func pythonTestLauncherFactory() android.Module {
+	m := &pythonTestLauncher{}
+	android.InitAndroidArchModule(m, android.HostAndDeviceSupported, android.MultilibFirst)
+	return m
+}
+
+func (m *pythonTestLauncher) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	outputFile := android.PathForModuleOut(ctx, ctx.ModuleName())
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   android.Touch,
+		Output: outputFile,
+	})
+	m.outputFile = outputFile
+}
+
+func (m *pythonTestLauncher) IntermPathForModuleOut() android.OptionalPath {
+	return android.OptionalPathForPath(m.outputFile)
+}
+
+var prepareForPythonParTest = android.GroupFixturePreparers(
+	android.PrepareForTestWithDefaults,
+	PrepareForTestWithPythonBuildComponents,
+	android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+		ctx.RegisterModuleType("python_test_launcher", pythonTestLauncherFactory)
+	}),
+	android.FixtureMergeMockFs(android.MockFS{
+		"dir/main.py":    nil,
+		"stdlib/os.py":   nil,
+		StubTemplateHost: nil,
+	}),
+	android.FixtureAddTextFile("stdlib/Android.bp", `
+		python_library_host {
+			name: "py3-stdlib",
+			srcs: ["os.py"],
+		}
+
+		python_test_launcher {
+			name: "py3-launcher-autorun",
+		}
+
+		python_test_launcher {
+			name: "libsqlite",
+		}
+	`),
+)
+
+func TestPythonBinaryCompressionAndPrecompile(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForPythonParTest,
+		android.FixtureAddTextFile("dir/Android.bp", `
+			python_binary_host {
+				name: "bin",
+				main: "main.py",
+				srcs: ["main.py"],
+				compression: true,
+				precompile: true,
+				version: {
+					py3: {
+						embedded_launcher: true,
+					},
+				},
+			}
+		`),
+	).RunTest(t)
+
+	bin := result.ModuleForTests("bin", "linux_glibc_x86_64_PY3")
+	base := bin.Module().(*Module)
+	srcsZips := append(android.Paths{base.srcsZip}, base.depsSrcsZips...)
+
+	precompile := bin.Rule("precompile")
+	android.AssertPathsRelativeToTopEquals(t, "precompile inputs", android.PathsRelativeToTop(srcsZips),
+		precompile.Inputs)
+	android.AssertPathRelativeToTopEquals(t, "precompile output",
+		"out/soong/.intermediates/dir/bin/linux_glibc_x86_64_PY3/bin.pyc.zip", precompile.Output)
+	android.AssertStringEquals(t, "precompile flags", "--deflate", precompile.Args["precompileFlags"])
+
+	par := bin.Rule("embeddedPar")
+	android.AssertStringEquals(t, "merge flags", "-deflate", par.Args["mergeFlags"])
+	android.AssertStringPathsRelativeToTopEquals(t, "par zips", result.Config,
+		append(android.PathsRelativeToTop(srcsZips),
+			"out/soong/.intermediates/dir/bin/linux_glibc_x86_64_PY3/bin.pyc.zip"),
+		strings.Fields(par.Args["srcsZips"]))
+	android.AssertPathsRelativeToTopEquals(t, "par implicits", append(android.PathsRelativeToTop(srcsZips),
+		"out/soong/.intermediates/dir/bin/linux_glibc_x86_64_PY3/bin.pyc.zip",
+		"out/soong/.intermediates/stdlib/py3-launcher-autorun/linux_glibc_x86_64/py3-launcher-autorun"),
+		par.Implicits)
+}
+
+func TestPythonBinaryWithoutCompressionAndPrecompile(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForPythonParTest,
+		android.FixtureAddTextFile("dir/Android.bp", `
+			python_binary_host {
+				name: "bin",
+				main: "main.py",
+				srcs: ["main.py"],
+				version: {
+					py3: {
+						embedded_launcher: true,
+					},
+				},
+			}
+
+			python_binary_host {
+				name: "bin_host_par",
+				main: "main.py",
+				srcs: ["main.py"],
+				compression: true,
+			}
+		`),
+	).RunTest(t)
+
+	bin := result.ModuleForTests("bin", "linux_glibc_x86_64_PY3")
+	android.AssertBoolEquals(t, "precompile rule", false, bin.MaybeRule("precompile").Rule != nil)
+	android.AssertStringEquals(t, "merge flags", "", bin.Rule("embeddedPar").Args["mergeFlags"])
+
+	// Compression is also supported without an embedded launcher.
+	hostPar := result.ModuleForTests("bin_host_par", "linux_glibc_x86_64_PY3").Rule("hostPar")
+	android.AssertStringEquals(t, "host par merge flags", "-deflate", hostPar.Args["mergeFlags"])
+}
+
+func TestPythonBinaryPrecompileErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		bp   string
+	}{
+		{
+			name: "no embedded launcher",
+			bp: `
+				python_binary_host {
+					name: "bin",
+					main: "main.py",
+					srcs: ["main.py"],
+					precompile: true,
+				}
+			`,
+		},
+		{
+			name: "python 2",
+			bp: `
+				python_binary_host {
+					name: "bin",
+					main: "main.py",
+					srcs: ["main.py"],
+					precompile: true,
+					version: {
+						py2: {
+							enabled: true,
+							embedded_launcher: true,
+						},
+						py3: {
+							enabled: false,
+						},
+					},
+				}
+			`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			android.GroupFixturePreparers(
+				prepareForPythonParTest,
+				android.PrepareForTestWithAllowMissingDependencies,
+				android.FixtureAddTextFile("dir/Android.bp", tc.bp),
+			).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+				`module "bin".*: precompile: only supported for python 3 with an embedded launcher`)).
+				RunTest(t)
+		})
+	}
+}
+
 func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
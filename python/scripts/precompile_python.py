@@ -0,0 +1,83 @@
+#!/usr/bin/env python3
+#
+# Copyright 2022 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Precompiles the python sources of zip files into a zip of .pyc files.
+
+The .pyc files are placed next to their sources, where zipimport looks for them,
+and use unchecked hash based invalidation, so that they are used without
+comparing them with the sources and do not depend on timestamps. The output is
+deterministic: the entries are sorted and have a fixed timestamp.
+"""
+
+import argparse
+import importlib.util
+import marshal
+import zipfile
+
+# The timestamp of the entries, matching the one used by soong_zip.
+ZIP_TIMESTAMP = (2008, 1, 1, 0, 0, 0)
+
+# The flags of an unchecked hash based .pyc file, see PEP 552.
+PYC_UNCHECKED_HASH_FLAGS = 0b01
+
+
+def compile_source(name, source):
+    """Returns the contents of the .pyc file of the given source."""
+    code = compile(source, name, 'exec', dont_inherit=True)
+    source_hash = importlib.util.source_hash(source)
+    return (importlib.util.MAGIC_NUMBER +
+            PYC_UNCHECKED_HASH_FLAGS.to_bytes(4, 'little') + source_hash +
+            marshal.dumps(code))
+
+
+def parse_args():
+    parser = argparse.ArgumentParser(description=__doc__)
+    parser.add_argument(
+        '-d', '--deflate', action='store_true',
+        help='deflate the .pyc files instead of storing them uncompressed')
+    parser.add_argument(
+        '-o', '--output', required=True, help='the output zip file')
+    parser.add_argument('inputs', nargs='*', help='the input zip files')
+    return parser.parse_args()
+
+
+def main():
+    args = parse_args()
+
+    sources = {}
+    for input_zip in args.inputs:
+        with zipfile.ZipFile(input_zip) as z:
+            for name in z.namelist():
+                # The first source of a path is the one that ends up in the par.
+                if name.endswith('.py') and name not in sources:
+                    sources[name] = z.read(name)
+
+    compress_type = zipfile.ZIP_DEFLATED if args.deflate else zipfile.ZIP_STORED
+    with zipfile.ZipFile(args.output, 'w') as out:
+        for name in sorted(sources):
+            try:
+                pyc = compile_source(name, sources[name])
+            except (SyntaxError, ValueError):
+                # Sources that do not compile, like test data of the standard library, are left
+                # to fail when they are imported.
+                continue
+            info = zipfile.ZipInfo(name + 'c', date_time=ZIP_TIMESTAMP)
+            info.external_attr = 0o644 << 16
+            info.compress_type = compress_type
+            out.writestr(info, pyc)
+
+
+if __name__ == '__main__':
+    main()
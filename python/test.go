@@ -15,6 +15,11 @@
 package python
 
 import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+
 	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
@@ -36,6 +41,14 @@ func registerPythonTestComponents(ctx android.RegistrationContext) {
 type TestOptions struct {
 	// If the test is a hostside(no device required) unittest that shall be run during presubmit check.
 	Unit_test *bool
+
+	// Split the test into this many shards, each run by its own test config. The test modules in
+	// srcs, i.e. the files other than main that are named test_*.py or *_test.py, are distributed
+	// among the shards by a hash of their path. Each shard passes the names of its test modules to
+	// the main program as arguments, so main must only run the test modules it is given, e.g. with
+	// unittest.main(module=None). Building <name>-shards runs all the shards and merges their
+	// results into <name>_shards.txt. Only supported by python_test_host.
+	Shards *int64
 }
 
 type TestProperties struct {
@@ -56,6 +69,9 @@ type TestProperties struct {
 
 	// Test options.
 	Test_options TestOptions
+
+	// Install the test into a folder named for the module in all test suites.
+	Per_testcase_directory *bool
 }
 
 type testDecorator struct {
@@ -65,6 +81,9 @@ type testDecorator struct {
 
 	testConfig android.Path
 
+	// The test configs of the shards, if test_options.shards is set.
+	shardTestConfigs android.Paths
+
 	data []android.DataPath
 }
 
@@ -84,6 +103,10 @@ func (test *testDecorator) install(ctx android.ModuleContext, file android.Path)
 
 	test.binaryDecorator.pythonInstaller.install(ctx, file)
 
+	if test.testProperties.Test_options.Shards != nil {
+		test.generateShardTestConfigs(ctx, file)
+	}
+
 	dataSrcPaths := android.PathsForModuleSrc(ctx, test.testProperties.Data)
 
 	for _, dataSrcPath := range dataSrcPaths {
@@ -98,6 +121,102 @@ func (test *testDecorator) install(ctx android.ModuleContext, file android.Path)
 	}
 }
 
+// generateShardTestConfigs generates a test config for each shard of the test, which runs the main
+// program with the names of the test modules of the shard, a rule that runs each shard, and a
+// <name>-shards phony that merges their results.
+func (test *testDecorator) generateShardTestConfigs(ctx android.ModuleContext, file android.Path) {
+	shards := int(*test.testProperties.Test_options.Shards)
+	if !ctx.Host() {
+		ctx.PropertyErrorf("test_options.shards", "only supported by python_test_host")
+		return
+	}
+	if shards < 1 {
+		ctx.PropertyErrorf("test_options.shards", "must be at least 1, got %d", shards)
+		return
+	}
+	testModules := test.testModules(ctx)
+	if len(testModules) == 0 {
+		ctx.PropertyErrorf("test_options.shards",
+			"no test modules, named test_*.py or *_test.py, found in srcs")
+		return
+	}
+
+	var shardResults android.Paths
+	for i, shard := range shardTestModules(testModules, shards) {
+		// Hashing may leave a shard without any test module. There is nothing to run for it, and
+		// running the main program without any test module could run all the tests.
+		if len(shard) == 0 {
+			continue
+		}
+		var configs []tradefed.Config
+		for _, testModule := range shard {
+			configs = append(configs, tradefed.Option{Name: "python-options", Value: testModule})
+		}
+		config := android.PathForModuleOut(ctx, fmt.Sprintf("%s_shard%d.config", ctx.ModuleName(), i))
+		tradefed.AutoGenPythonBinaryHostTestShardConfig(ctx, config,
+			test.testProperties.Test_config_template, configs)
+		test.shardTestConfigs = append(test.shardTestConfigs, config)
+
+		// Run the shard in its own rule, recording a failure in its result instead of failing so
+		// that the results of all the shards are merged.
+		shardResult := android.PathForModuleOut(ctx, "shards", fmt.Sprintf("shard%d.txt", i))
+		rule := android.NewRuleBuilder(pctx, ctx)
+		rule.Command().
+			Text("(").Tool(file).Text(strings.Join(shard, " ")).
+			Textf(`|| echo "FAILED: shard %d") >`, i).Output(shardResult).Text("2>&1")
+		rule.Build(fmt.Sprintf("python_test_shard%d", i),
+			fmt.Sprintf("run shard %d of %s", i, ctx.ModuleName()))
+		shardResults = append(shardResults, shardResult)
+	}
+
+	// Merge the results of the shards into the result of the test, which is only written if all the
+	// shards passed.
+	result := android.PathForModuleOut(ctx, ctx.ModuleName()+"_shards.txt")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().Text("cat").Inputs(shardResults).Text(">").Output(result)
+	rule.Command().
+		Text(`if grep -q "^FAILED: shard"`).Input(result).Text("; then cat").Input(result).
+		Text(">&2; rm -f").Input(result).Text("; exit 1; fi")
+	rule.Build("python_test_shards", "merge the shards of "+ctx.ModuleName())
+
+	ctx.Phony(ctx.ModuleName()+"-shards", result)
+}
+
+// testModules returns the dotted names of the test modules in the srcs of the test, excluding the
+// main program.
+func (test *testDecorator) testModules(ctx android.ModuleContext) []string {
+	main := String(test.binaryDecorator.binaryProperties.Main)
+	if main == "" {
+		main = ctx.ModuleName() + pyExt
+	}
+
+	var testModules []string
+	for _, path := range ctx.Module().(*Module).getSrcsPathMappings() {
+		if path.src.Rel() == main || filepath.Ext(path.dest) != pyExt {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(path.dest), pyExt)
+		if strings.HasPrefix(name, "test_") || strings.HasSuffix(name, "_test") {
+			testModules = append(testModules,
+				strings.Replace(strings.TrimSuffix(path.dest, pyExt), "/", ".", -1))
+		}
+	}
+	return testModules
+}
+
+// shardTestModules distributes the test modules among the given number of shards by a hash of
+// their names, so that adding or removing a test module doesn't move the others to other shards.
+func shardTestModules(testModules []string, shards int) [][]string {
+	ret := make([][]string, shards)
+	for _, testModule := range testModules {
+		h := fnv.New32a()
+		h.Write([]byte(testModule))
+		shard := h.Sum32() % uint32(shards)
+		ret[shard] = append(ret[shard], testModule)
+	}
+	return ret
+}
+
 func NewTest(hod android.HostOrDeviceSupported) *Module {
 	module, binary := NewBinary(hod)
 
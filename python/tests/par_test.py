@@ -26,8 +26,12 @@ def assert_equal(what, a, b):
         print("Expected %s('%s') == '%s'" % (what, a, b))
         failed = True
 
+# Set when the par is built with precompile: true, in which case the modules are loaded from .pyc
+# files.
+ext = ".pyc" if os.getenv('PAR_TEST_PRECOMPILED', False) else ".py"
+
 assert_equal("__name__", __name__, "__main__")
-assert_equal("os.path.basename(__file__)", os.path.basename(__file__), "par_test.py")
+assert_equal("os.path.basename(__file__)", os.path.basename(__file__), "par_test" + ext)
 
 archive = os.path.dirname(__file__)
 
@@ -25,9 +25,12 @@ fi
 
 if [[ ( ! -f $ANDROID_HOST_OUT/nativetest64/par_test/par_test ) ||
       ( ! -f $ANDROID_HOST_OUT/nativetest64/par_test3/par_test3 ) ||
+      ( ! -f $ANDROID_HOST_OUT/nativetest64/par_test3_compressed/par_test3_compressed ) ||
       ( ! -f $ANDROID_HOST_OUT/bin/py2-cmd ) ||
-      ( ! -f $ANDROID_HOST_OUT/bin/py3-cmd )]]; then
-  echo "Run 'm par_test par_test3 py2-cmd py3-cmd' first"
+      ( ! -f $ANDROID_HOST_OUT/bin/py3-cmd ) ||
+      ( ! -f $ANDROID_HOST_OUT/bin/precompile_python ) ||
+      ( ! -f $ANDROID_HOST_OUT/bin/soong_zip )]]; then
+  echo "Run 'm par_test par_test3 par_test3_compressed py2-cmd py3-cmd precompile_python soong_zip' first"
   exit 1
 fi
 
@@ -47,8 +50,32 @@ PYTHONPATH=/usr $ANDROID_HOST_OUT/nativetest64/par_test3/par_test3
 
 ARGTEST=true $ANDROID_HOST_OUT/nativetest64/par_test3/par_test3 --arg1 arg2
 
+export PAR_TEST_PRECOMPILED=true
+PYTHONHOME= PYTHONPATH= $ANDROID_HOST_OUT/nativetest64/par_test3_compressed/par_test3_compressed
+PYTHONHOME=/usr $ANDROID_HOST_OUT/nativetest64/par_test3_compressed/par_test3_compressed
+PYTHONPATH=/usr $ANDROID_HOST_OUT/nativetest64/par_test3_compressed/par_test3_compressed
+
+ARGTEST=true $ANDROID_HOST_OUT/nativetest64/par_test3_compressed/par_test3_compressed --arg1 arg2
+unset PAR_TEST_PRECOMPILED
+
 cd $(dirname ${BASH_SOURCE[0]})
 
+# The precompiled .pyc files must be byte-for-byte reproducible, with and without compression.
+tmpdir=$(mktemp -d)
+trap "rm -rf ${tmpdir}" EXIT
+$ANDROID_HOST_OUT/bin/soong_zip -o ${tmpdir}/srcs.zip -f par_test.py -f testpkg/par_test.py
+for flags in "" "--deflate"; do
+  $ANDROID_HOST_OUT/bin/precompile_python ${flags} -o ${tmpdir}/pyc1.zip ${tmpdir}/srcs.zip
+  $ANDROID_HOST_OUT/bin/precompile_python ${flags} -o ${tmpdir}/pyc2.zip ${tmpdir}/srcs.zip
+  cmp ${tmpdir}/pyc1.zip ${tmpdir}/pyc2.zip
+done
+
+# Rebuilding the compressed and precompiled par file must produce the same file.
+cp $ANDROID_HOST_OUT/nativetest64/par_test3_compressed/par_test3_compressed ${tmpdir}/par_test3_compressed
+touch par_test.py testpkg/par_test.py
+(cd $ANDROID_BUILD_TOP && build/soong/soong_ui.bash --make-mode par_test3_compressed)
+cmp ${tmpdir}/par_test3_compressed $ANDROID_HOST_OUT/nativetest64/par_test3_compressed/par_test3_compressed
+
 PYTHONPATH=/extra $ANDROID_HOST_OUT/bin/py2-cmd py-cmd_test.py
 PYTHONPATH=/extra $ANDROID_HOST_OUT/bin/py3-cmd py-cmd_test.py
 
@@ -26,9 +26,10 @@ def assert_equal(what, a, b):
         failed = True
 
 archive = sys.modules["__main__"].__loader__.archive
+ext = ".pyc" if os.getenv('PAR_TEST_PRECOMPILED', False) else ".py"
 
 assert_equal("__name__", __name__, "testpkg.par_test")
-assert_equal("__file__", __file__, os.path.join(archive, "testpkg/par_test.py"))
+assert_equal("__file__", __file__, os.path.join(archive, "testpkg/par_test" + ext))
 
 # Python3 is returning None here for me, and I haven't found any problems caused by this.
 if sys.version_info[0] == 2:
@@ -17,6 +17,8 @@ package rust
 import (
 	"fmt"
 
+	"github.com/google/blueprint/proptools"
+
 	"android/soong/cc"
 )
 
@@ -35,7 +37,7 @@ func (afdo *afdo) flags(ctx ModuleContext, flags Flags, deps PathDeps) (Flags, P
 		return flags, deps
 	}
 
-	if afdo != nil && afdo.Properties.Afdo {
+	if afdo != nil && proptools.Bool(afdo.Properties.Afdo) {
 		if profileFile := afdo.Properties.GetAfdoProfileFile(ctx, ctx.ModuleName()); profileFile.Valid() {
 			profileUseFlag := fmt.Sprintf(afdoFlagFormat, profileFile)
 			flags.RustFlags = append(flags.RustFlags, profileUseFlag)
@@ -187,6 +187,15 @@ func TestInstallDir(t *testing.T) {
 	}
 }
 
+func TestInvalidRelativeInstallPath(t *testing.T) {
+	testRustError(t, `relative_install_path: invalid relative install path "../xbin": Path is outside directory: ../xbin`, `
+		rust_binary {
+			name: "fizzbuzz",
+			srcs: ["foo.rs"],
+			relative_install_path: "../xbin",
+		}`)
+}
+
 func TestLints(t *testing.T) {
 
 	bp := `
@@ -341,6 +341,10 @@ func (mod *Module) IsVendorPublicLibrary() bool {
 	return mod.VendorProperties.IsVendorPublicLibrary
 }
 
+func (mod *Module) IsVendorStubs() bool {
+	return false
+}
+
 func (mod *Module) SdkAndPlatformVariantVisibleToMake() bool {
 	// Rust modules to not provide Sdk variants
 	return false
@@ -370,6 +374,10 @@ func (m *Module) NeedsVendorPublicLibraryVariants() bool {
 	return false
 }
 
+func (m *Module) NeedsVendorStubsVariants() bool {
+	return false
+}
+
 func (mod *Module) HasLlndkStubs() bool {
 	return false
 }
@@ -862,6 +870,12 @@ func (mod *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		return
 	}
 
+	if mod.compiler != nil {
+		if err := android.ValidateRelativeInstallPath(mod.compiler.relativeInstallPath()); err != nil {
+			ctx.PropertyErrorf("relative_install_path", "%s", err)
+		}
+	}
+
 	deps := mod.depsToPaths(ctx)
 	flags := Flags{
 		Toolchain: toolchain,
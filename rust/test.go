@@ -70,6 +70,9 @@ type TestProperties struct {
 	// Add RootTargetPreparer to auto generated test config. This guarantees the test to run
 	// with root permission.
 	Require_root *bool
+
+	// Install the test into a folder named for the module in all test suites.
+	Per_testcase_directory *bool
 }
 
 // A test module is a binary module with extra --test compiler flag
@@ -144,17 +147,31 @@ func (test *testDecorator) install(ctx ModuleContext) {
 
 	dataSrcPaths := android.PathsForModuleSrc(ctx, test.Properties.Data)
 
+	// The same module may be listed more than once, e.g. in both the common and the arch
+	// specific data_libs, but it only needs to be installed next to the test once.
+	seenDataDeps := make(map[string]bool)
+	addDataDep := func(linkableDep cc.LinkableInterface) {
+		if !linkableDep.OutputFile().Valid() {
+			return
+		}
+		srcPath := linkableDep.OutputFile().Path()
+		if seenDataDeps[srcPath.String()] {
+			return
+		}
+		seenDataDeps[srcPath.String()] = true
+		test.data = append(test.data,
+			android.DataPath{SrcPath: srcPath,
+				RelativeInstallPath: linkableDep.RelativeInstallPath()})
+	}
+
 	ctx.VisitDirectDepsWithTag(dataLibDepTag, func(dep android.Module) {
 		depName := ctx.OtherModuleName(dep)
 		linkableDep, ok := dep.(cc.LinkableInterface)
 		if !ok {
 			ctx.ModuleErrorf("data_lib %q is not a linkable module", depName)
+			return
 		}
-		if linkableDep.OutputFile().Valid() {
-			test.data = append(test.data,
-				android.DataPath{SrcPath: linkableDep.OutputFile().Path(),
-					RelativeInstallPath: linkableDep.RelativeInstallPath()})
-		}
+		addDataDep(linkableDep)
 	})
 
 	ctx.VisitDirectDepsWithTag(dataBinDepTag, func(dep android.Module) {
@@ -162,12 +179,9 @@ func (test *testDecorator) install(ctx ModuleContext) {
 		linkableDep, ok := dep.(cc.LinkableInterface)
 		if !ok {
 			ctx.ModuleErrorf("data_bin %q is not a linkable module", depName)
+			return
 		}
-		if linkableDep.OutputFile().Valid() {
-			test.data = append(test.data,
-				android.DataPath{SrcPath: linkableDep.OutputFile().Path(),
-					RelativeInstallPath: linkableDep.RelativeInstallPath()})
-		}
+		addDataDep(linkableDep)
 	})
 
 	for _, dataSrcPath := range dataSrcPaths {
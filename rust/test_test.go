@@ -43,6 +43,34 @@ func TestRustTest(t *testing.T) {
 	}
 }
 
+func TestRustTestPerTestcaseDirectory(t *testing.T) {
+	ctx := testRust(t, `
+		rust_test_host {
+			name: "my_test",
+			srcs: ["foo.rs"],
+			data: ["data.txt"],
+			test_suites: ["general-tests"],
+			per_testcase_directory: true,
+		}
+
+		rust_test_host {
+			name: "my_test_default",
+			srcs: ["foo.rs"],
+			data: ["data.txt"],
+			test_suites: ["general-tests"],
+		}`)
+
+	entries := func(name string) android.AndroidMkEntries {
+		module := ctx.ModuleForTests(name, "linux_glibc_x86_64").Module()
+		return android.AndroidMkEntriesForTest(t, ctx, module)[0]
+	}
+
+	android.AssertDeepEquals(t, "per testcase data", []string{"my_test/data.txt"},
+		android.AndroidMkTestDataSuitePaths(entries("my_test"), "my_test"))
+	android.AssertDeepEquals(t, "default data", []string{"data.txt"},
+		android.AndroidMkTestDataSuitePaths(entries("my_test_default"), "my_test_default"))
+}
+
 func TestRustTestLinkage(t *testing.T) {
 	ctx := testRust(t, `
 		rust_test {
@@ -200,3 +228,74 @@ func TestDataLibsRelativeInstallPath(t *testing.T) {
 			" but was '%s'", entries.EntryMap["LOCAL_TEST_DATA"][2])
 	}
 }
+
+func TestDataLibsDeduplicated(t *testing.T) {
+	bp := `
+		cc_library {
+			name: "test_lib",
+			srcs: ["test_lib.cpp"],
+		}
+
+		rust_test {
+			name: "main_test",
+			srcs: ["foo.rs"],
+			shared_libs: ["test_lib"],
+			data_libs: ["test_lib"],
+			arch: {
+				arm64: {
+					data_libs: ["test_lib"],
+				},
+			},
+		}
+ `
+
+	ctx := testRust(t, bp)
+	module := ctx.ModuleForTests("main_test", "android_arm64_armv8-a").Module()
+	testBinary := module.(*Module).compiler.(*testDecorator)
+	if len(testBinary.dataPaths()) != 1 {
+		t.Fatalf("expected exactly one test data file. test data files: [%s]", testBinary.dataPaths())
+	}
+
+	entries := android.AndroidMkEntriesForTest(t, ctx, module)[0]
+	if len(entries.EntryMap["LOCAL_TEST_DATA"]) != 1 {
+		t.Errorf("expected exactly one LOCAL_TEST_DATA entry, but was %q", entries.EntryMap["LOCAL_TEST_DATA"])
+	}
+}
+
+func TestDataLibsMultilib(t *testing.T) {
+	bp := `
+		cc_library {
+			name: "test_lib",
+			srcs: ["test_lib.cpp"],
+		}
+
+		rust_test {
+			name: "main_test",
+			srcs: ["foo.rs"],
+			data_libs: ["test_lib"],
+		}
+ `
+
+	ctx := testRust(t, bp)
+	for variant, testDir := range map[string]string{
+		"android_arm64_armv8-a":    "nativetest64",
+		"android_arm_armv7-a-neon": "nativetest",
+	} {
+		module := ctx.ModuleForTests("main_test", variant).Module()
+		testBinary := module.(*Module).compiler.(*testDecorator)
+		if len(testBinary.dataPaths()) != 1 {
+			t.Fatalf("expected exactly one test data file for %s. test data files: [%s]", variant, testBinary.dataPaths())
+		}
+
+		// Each arch variant of the test packages the data_lib of the same arch into its own
+		// test directory.
+		dataLibraryPath := testBinary.dataPaths()[0].SrcPath.String()
+		if !strings.Contains(dataLibraryPath, "/"+variant+"_shared/") {
+			t.Errorf("expected test data file for %s to be from the %s_shared variant, but was '%s'",
+				variant, variant, dataLibraryPath)
+		}
+		if installPath := testBinary.path.String(); !strings.Contains(installPath, "/"+testDir+"/main_test/") {
+			t.Errorf("expected %s test to be installed in %s, but was '%s'", variant, testDir, installPath)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for generating a JSON report of the differences between the
+previously released and the current API signature files of the surfaces of a
+java_sdk_library.
+
+For each surface the report lists the classes and members that were added,
+removed or changed. A member is identified by its class, its kind, its name
+and, for methods and constructors, its parameter types, so a member whose
+modifiers, return type or value changed is reported as changed."""
+
+from __future__ import print_function
+
+import argparse
+import collections
+import json
+import re
+import sys
+
+CLASS_RE = re.compile(
+    r'^(.*?)\b(class|interface|enum|@interface)\s+([^\s<{]+)')
+MEMBER_KINDS = ('ctor', 'method', 'field', 'enum_constant', 'property')
+
+
+def parse_args(args):
+    """Parse commandline arguments."""
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        '--surface',
+        nargs=3,
+        action='append',
+        default=[],
+        metavar=('NAME', 'PREVIOUS', 'CURRENT'),
+        help='the name of an API surface, and its previously released and '
+        'current API signature files')
+    parser.add_argument(
+        '--output', required=True, help='the JSON report to write')
+    return parser.parse_args(args)
+
+
+def parameters(declaration):
+    """Returns the text between the outermost parentheses of declaration."""
+    start = declaration.index('(')
+    depth = 0
+    for i in range(start, len(declaration)):
+        if declaration[i] == '(':
+            depth += 1
+        elif declaration[i] == ')':
+            depth -= 1
+            if depth == 0:
+                return declaration[start + 1:i]
+    return declaration[start + 1:]
+
+
+def member_key(kind, declaration):
+    """Returns the key that identifies a member across API versions."""
+    if '(' in declaration:
+        name = declaration[:declaration.index('(')].split()[-1]
+        return '%s %s(%s)' % (kind, name, parameters(declaration))
+    # Fields and properties, e.g. "field public static final int X = 1;".
+    name = re.split(r'\s=\s|;', declaration)[0].split()[-1]
+    return '%s %s' % (kind, name)
+
+
+def parse_api(text):
+    """Parses an API signature file into a dict from class name to a tuple of
+    its declaration and a dict from member key to member declaration."""
+    classes = collections.OrderedDict()
+    package = None
+    current = None
+    for line in text.splitlines():
+        line = line.strip()
+        if not line or line.startswith('//'):
+            continue
+        if line.startswith('package ') and line.endswith('{'):
+            package = line[len('package '):-1].strip()
+        elif line.endswith('{'):
+            match = CLASS_RE.match(line)
+            if match and package is not None:
+                name = package + '.' + match.group(3)
+                current = collections.OrderedDict()
+                classes[name] = (line[:-1].strip(), current)
+        elif line == '}':
+            if current is not None:
+                current = None
+            else:
+                package = None
+        elif current is not None:
+            kind = line.split()[0]
+            if kind in MEMBER_KINDS:
+                current[member_key(kind, line)] = line
+    return classes
+
+
+def diff_api(previous, current):
+    """Returns the added, removed and changed classes and members between two
+    parsed API signature files."""
+    added = []
+    removed = []
+    changed = []
+
+    for name, (declaration, members) in previous.items():
+        if name not in current:
+            removed.append(name)
+            continue
+        current_declaration, current_members = current[name]
+        if declaration != current_declaration:
+            changed.append({
+                'name': name,
+                'previous': declaration,
+                'current': current_declaration,
+            })
+        for key, member in members.items():
+            if key not in current_members:
+                removed.append('%s#%s' % (name, key))
+            elif member != current_members[key]:
+                changed.append({
+                    'name': '%s#%s' % (name, key),
+                    'previous': member,
+                    'current': current_members[key],
+                })
+        for key in current_members:
+            if key not in members:
+                added.append('%s#%s' % (name, key))
+
+    for name in current:
+        if name not in previous:
+            added.append(name)
+
+    return collections.OrderedDict([
+        ('added', sorted(added)),
+        ('removed', sorted(removed)),
+        ('changed', sorted(changed, key=lambda c: c['name'])),
+    ])
+
+
+def main(argv):
+    args = parse_args(argv)
+
+    report = collections.OrderedDict()
+    for name, previous_file, current_file in args.surface:
+        with open(previous_file) as f:
+            previous = parse_api(f.read())
+        with open(current_file) as f:
+            current = parse_api(f.read())
+        report[name] = diff_api(previous, current)
+
+    with open(args.output, 'w') as f:
+        json.dump(report, f, indent=2)
+        f.write('\n')
+
+    return 0
+
+
+if __name__ == '__main__':
+    sys.exit(main(sys.argv[1:]))
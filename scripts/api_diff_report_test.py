@@ -0,0 +1,135 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for api_diff_report.py."""
+
+import sys
+import unittest
+
+import api_diff_report as adr
+
+sys.dont_write_bytecode = True
+
+PREVIOUS = '''// Signature format: 2.0
+package android.foo {
+
+  public class Foo {
+    ctor public Foo();
+    method public void bar(int);
+    method public void bar(int, String);
+    method public int baz();
+    field public static final int VALUE = 1; // 0x1
+  }
+
+  public static class Foo.Inner {
+    method public void inner();
+  }
+
+  public final class Removed {
+    method public void gone();
+  }
+
+}
+
+'''
+
+CURRENT = '''// Signature format: 2.0
+package android.foo {
+
+  public class Foo implements java.io.Closeable {
+    ctor public Foo();
+    method public void bar(int);
+    method public long baz();
+    method public void close();
+    field public static final int VALUE = 2; // 0x2
+  }
+
+  public static class Foo.Inner {
+    method public void inner();
+  }
+
+}
+
+package android.foo.added {
+
+  public interface Added {
+    method public void added();
+  }
+
+}
+
+'''
+
+
+class ParseApiTest(unittest.TestCase):
+
+    def test_parse(self):
+        api = adr.parse_api(PREVIOUS)
+        self.assertEqual(
+            list(api.keys()),
+            ['android.foo.Foo', 'android.foo.Foo.Inner', 'android.foo.Removed'])
+        declaration, members = api['android.foo.Foo']
+        self.assertEqual(declaration, 'public class Foo')
+        self.assertEqual(
+            list(members.keys()), [
+                'ctor Foo()',
+                'method bar(int)',
+                'method bar(int, String)',
+                'method baz()',
+                'field VALUE',
+            ])
+
+
+class DiffApiTest(unittest.TestCase):
+
+    def test_diff(self):
+        report = adr.diff_api(adr.parse_api(PREVIOUS), adr.parse_api(CURRENT))
+        self.assertEqual(report['added'], [
+            'android.foo.Foo#method close()',
+            'android.foo.added.Added',
+        ])
+        self.assertEqual(report['removed'], [
+            'android.foo.Foo#method bar(int, String)',
+            'android.foo.Removed',
+        ])
+        self.assertEqual(report['changed'], [
+            {
+                'name': 'android.foo.Foo',
+                'previous': 'public class Foo',
+                'current': 'public class Foo implements java.io.Closeable',
+            },
+            {
+                'name': 'android.foo.Foo#field VALUE',
+                'previous': 'field public static final int VALUE = 1; // 0x1',
+                'current': 'field public static final int VALUE = 2; // 0x2',
+            },
+            {
+                'name': 'android.foo.Foo#method baz()',
+                'previous': 'method public int baz();',
+                'current': 'method public long baz();',
+            },
+        ])
+
+    def test_unchanged(self):
+        api = adr.parse_api(PREVIOUS)
+        report = adr.diff_api(api, api)
+        self.assertEqual(report['added'], [])
+        self.assertEqual(report['removed'], [])
+        self.assertEqual(report['changed'], [])
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
@@ -0,0 +1,69 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for converting a build.prop file into a JSON object containing the
+same properties, so that they can be consumed without parsing the prop
+format."""
+
+from __future__ import print_function
+
+import argparse
+import collections
+import json
+import sys
+
+
+def parse_args(args):
+    """Parse commandline arguments."""
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        '--input', required=True, help='build.prop file to convert')
+    parser.add_argument(
+        '--output', required=True, help='JSON file to write the properties to')
+    return parser.parse_args(args)
+
+
+def parse_build_prop(lines):
+    """Returns an OrderedDict of the properties in the lines of a build.prop
+    file, in the order they first appear.  Keys are kept exactly as they are
+    written, including the '?' of optional properties.  A later value for the
+    same key replaces the earlier one, like it does when the file is loaded."""
+    props = collections.OrderedDict()
+    for line in lines:
+        line = line.rstrip('\n')
+        if not line.strip() or line.lstrip().startswith('#'):
+            continue
+        if '=' not in line:
+            continue
+        key, value = line.split('=', 1)
+        props[key] = value
+    return props
+
+
+def main():
+    """Program entry point."""
+    args = parse_args(sys.argv[1:])
+
+    with open(args.input) as f:
+        props = parse_build_prop(f.readlines())
+
+    with open(args.output, 'w') as f:
+        json.dump(props, f, indent=2)
+        f.write('\n')
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,140 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for build_prop_to_json.py."""
+
+import collections
+import json
+import os
+import shutil
+import sys
+import tempfile
+import unittest
+
+import build_prop_to_json as bptj
+
+sys.dont_write_bytecode = True
+
+
+class BuildPropToJsonTest(unittest.TestCase):
+
+    def test_parse_build_prop(self):
+        lines = [
+            '# begin build properties\n',
+            'ro.build.id?=TQ1A.221205.011\n',
+            'ro.build.display.id?=TQ1A.221205.011 release-keys\n',
+            '\n',
+            'ro.build.fingerprint=Android/aosp_arm64:13/TQ1A/1:user/a=b\n',
+            'ro.product.locale=\n',
+            '# end build properties\n',
+            'not a property\n',
+        ]
+        self.assertEqual(
+            list(bptj.parse_build_prop(lines).items()), [
+                ('ro.build.id?', 'TQ1A.221205.011'),
+                ('ro.build.display.id?', 'TQ1A.221205.011 release-keys'),
+                ('ro.build.fingerprint', 'Android/aosp_arm64:13/TQ1A/1:user/a=b'),
+                ('ro.product.locale', ''),
+            ])
+
+    def test_later_value_wins(self):
+        lines = [
+            'ro.carrier=unknown\n',
+            'ro.wifi.channels=\n',
+            'ro.carrier=wifi-only\n',
+        ]
+        self.assertEqual(
+            list(bptj.parse_build_prop(lines).items()), [
+                ('ro.carrier', 'wifi-only'),
+                ('ro.wifi.channels', ''),
+            ])
+
+    def test_json_keys_match_build_prop(self):
+        lines = [
+            'ro.build.id?=TQ1A.221205.011\n',
+            'ro.build.type=user\n',
+        ]
+        props = json.loads(json.dumps(bptj.parse_build_prop(lines)))
+        self.assertEqual(
+            sorted(props.keys()), sorted(
+                line.split('=', 1)[0] for line in lines))
+
+
+class BuildPropToJsonMainTest(unittest.TestCase):
+
+    # The start and end of a build.prop generated by buildinfo_prop, with
+    # props and a footer file.
+    BUILD_PROP = '\n'.join([
+        '# begin build properties',
+        '# autogenerated by build/soong/android/buildinfo_prop.go',
+        'ro.build.id?=TQ1A.221205.011',
+        'ro.build.display.id?=TQ1A.221205.011 release-keys',
+        'ro.build.version.incremental=eng.user',
+        'ro.build.version.all_codenames=',
+        'ro.build.fingerprint=Android/aosp_arm64/generic_arm64:13/TQ1A/1:userdebug/release-keys,test-keys',
+        'ro.com.google.clientidbase=android-google',
+        'ro.carrier="unknown"',
+        '# end build properties',
+        'ro.carrier.footer=true',
+    ]) + '\n'
+
+    def setUp(self):
+        self.tmpdir = tempfile.mkdtemp()
+
+    def tearDown(self):
+        shutil.rmtree(self.tmpdir)
+
+    def write_file(self, name, contents):
+        path = os.path.join(self.tmpdir, name)
+        with open(path, 'w') as f:
+            f.write(contents)
+        return path
+
+    def convert(self, name):
+        prop = self.write_file('build.prop', self.BUILD_PROP)
+        output = os.path.join(self.tmpdir, name)
+        argv = sys.argv
+        sys.argv = ['build_prop_to_json', '--input', prop, '--output', output]
+        try:
+            bptj.main()
+        finally:
+            sys.argv = argv
+        return prop, output
+
+    def test_outputs_match(self):
+        prop, output = self.convert('build.prop.json')
+
+        with open(prop) as f:
+            prop_items = [
+                tuple(line.split('=', 1))
+                for line in f.read().splitlines()
+                if line and not line.startswith('#')
+            ]
+        with open(output) as f:
+            json_items = list(
+                json.load(f, object_pairs_hook=collections.OrderedDict).items())
+
+        self.assertEqual(json_items, prop_items)
+
+    def test_output_is_deterministic(self):
+        _, first = self.convert('first.json')
+        _, second = self.convert('second.json')
+        with open(first) as f1, open(second) as f2:
+            self.assertEqual(f1.read(), f2.read())
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
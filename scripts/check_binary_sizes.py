@@ -0,0 +1,110 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for recording the sizes of native binaries and comparing them
+against a checked-in baseline so that size regressions can be attributed to
+the modules that caused them."""
+
+from __future__ import print_function
+
+import argparse
+import json
+import os
+import sys
+
+
+def parse_args(args):
+    """Parse commandline arguments."""
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        '--modules',
+        required=True,
+        help='file containing a "name path" line for each module to check')
+    parser.add_argument(
+        '--baseline', help='JSON file mapping module names to expected sizes')
+    parser.add_argument(
+        '--max-growth-percent',
+        type=float,
+        default=5,
+        help='percentage a module may grow beyond its baseline size')
+    parser.add_argument(
+        '--warn-only',
+        action='store_true',
+        help='report modules that grew too much without failing')
+    parser.add_argument(
+        '--output',
+        required=True,
+        help='JSON file to write the current module sizes to')
+    return parser.parse_args(args)
+
+
+def read_module_sizes(modules_file):
+    """Returns a dict mapping the module names listed in modules_file to the
+    sizes of their files."""
+    sizes = {}
+    with open(modules_file) as f:
+        for line in f:
+            fields = line.split()
+            if len(fields) == 2:
+                sizes[fields[0]] = os.path.getsize(fields[1])
+    return sizes
+
+
+def find_regressions(sizes, baseline, max_growth_percent):
+    """Returns a list of (name, baseline size, size) for each module that grew
+    by more than max_growth_percent compared to its baseline size."""
+    regressions = []
+    for name, size in sorted(sizes.items()):
+        expected = baseline.get(name)
+        if not expected:
+            continue
+        if (size - expected) * 100.0 / expected > max_growth_percent:
+            regressions.append((name, expected, size))
+    return regressions
+
+
+def main():
+    """Program entry point."""
+    args = parse_args(sys.argv[1:])
+
+    sizes = read_module_sizes(args.modules)
+
+    regressions = []
+    if args.baseline:
+        with open(args.baseline) as f:
+            baseline = json.load(f)
+        regressions = find_regressions(sizes, baseline,
+                                       args.max_growth_percent)
+
+    level = 'warning' if args.warn_only else 'error'
+    for name, expected, size in regressions:
+        print('%s: %s grew from %d to %d bytes (%+.1f%%), more than the '
+              'allowed %g%%' % (level, name, expected, size,
+                               (size - expected) * 100.0 / expected,
+                               args.max_growth_percent),
+              file=sys.stderr)
+    if regressions and not args.warn_only:
+        print('error: update the baseline %s if the growth is expected' %
+              args.baseline, file=sys.stderr)
+        sys.exit(1)
+
+    with open(args.output, 'w') as f:
+        json.dump(sizes, f, indent=2, sort_keys=True)
+        f.write('\n')
+
+
+if __name__ == '__main__':
+    main()
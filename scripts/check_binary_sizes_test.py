@@ -0,0 +1,69 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_binary_sizes.py."""
+
+import os
+import shutil
+import sys
+import tempfile
+import unittest
+
+import check_binary_sizes as cbs
+
+sys.dont_write_bytecode = True
+
+
+class CheckBinarySizesTest(unittest.TestCase):
+
+    def setUp(self):
+        self.tmpdir = tempfile.mkdtemp()
+
+    def tearDown(self):
+        shutil.rmtree(self.tmpdir)
+
+    def write_file(self, name, contents):
+        path = os.path.join(self.tmpdir, name)
+        with open(path, 'w') as f:
+            f.write(contents)
+        return path
+
+    def test_read_module_sizes(self):
+        libfoo = self.write_file('libfoo.so', 'x' * 10)
+        bar = self.write_file('bar', 'x' * 20)
+        modules = self.write_file(
+            'modules.txt', 'libfoo:android_arm64_shared %s\n'
+            'bar:android_arm64 %s\n' % (libfoo, bar))
+        self.assertEqual(
+            cbs.read_module_sizes(modules), {
+                'libfoo:android_arm64_shared': 10,
+                'bar:android_arm64': 20,
+            })
+
+    def test_find_regressions(self):
+        sizes = {'a': 106, 'b': 105, 'c': 50, 'd': 1000}
+        baseline = {'a': 100, 'b': 100, 'c': 100}
+        self.assertEqual(
+            cbs.find_regressions(sizes, baseline, 5), [('a', 100, 106)])
+
+    def test_find_regressions_none(self):
+        sizes = {'a': 106}
+        self.assertEqual(cbs.find_regressions(sizes, {}, 5), [])
+        self.assertEqual(cbs.find_regressions(sizes, {'a': 100}, 10), [])
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
@@ -0,0 +1,78 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for checking that a build.prop file doesn't set a property more than
+once, unless one of the values is optional.  buildinfo_prop uses it to check
+the properties appended from footer files, which are only known at build
+time."""
+
+from __future__ import print_function
+
+import argparse
+import sys
+
+
+def parse_args(args):
+    """Parse commandline arguments."""
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        '--input', required=True, help='build.prop file to check')
+    parser.add_argument(
+        '--output', required=True, help='timestamp file to write on success')
+    return parser.parse_args(args)
+
+
+def find_duplicate_props(lines):
+    """Returns an error message for each property that is set again after an
+    earlier line already set it.  Optional properties, whose keys end with '?',
+    may be overridden by or override another value of the same property."""
+    errors = []
+    first_lines = {}
+    for line_number, line in enumerate(lines, 1):
+        line = line.rstrip('\n')
+        if not line.strip() or line.lstrip().startswith('#'):
+            continue
+        if '=' not in line:
+            continue
+        key = line.split('=', 1)[0]
+        if key.endswith('?'):
+            continue
+        if key in first_lines:
+            errors.append('property %s on line %d is already set on line %d' %
+                          (key, line_number, first_lines[key]))
+        else:
+            first_lines[key] = line_number
+    return errors
+
+
+def main():
+    """Program entry point."""
+    args = parse_args(sys.argv[1:])
+
+    with open(args.input) as f:
+        errors = find_duplicate_props(f.readlines())
+
+    if errors:
+        for error in errors:
+            print('error: %s: %s' % (args.input, error), file=sys.stderr)
+        sys.exit(1)
+
+    with open(args.output, 'w'):
+        pass
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,66 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_build_prop_duplicates.py."""
+
+import sys
+import unittest
+
+import check_build_prop_duplicates as cbpd
+
+sys.dont_write_bytecode = True
+
+
+class CheckBuildPropDuplicatesTest(unittest.TestCase):
+
+    def test_find_duplicate_props(self):
+        lines = [
+            'ro.build.id?=TQ1A.221205.011\n',
+            'ro.carrier=unknown\n',
+            '# ro.carrier=comment\n',
+            'ro.build.id=TQ1A.221205.012\n',
+            'ro.build.id?=TQ1A.221205.013\n',
+            'ro.carrier=wifi-only\n',
+        ]
+        self.assertEqual(
+            cbpd.find_duplicate_props(lines),
+            ['property ro.carrier on line 6 is already set on line 2'])
+
+    def test_footer_repeats_generated_prop(self):
+        lines = [
+            '# begin build properties\n',
+            'ro.com.google.clientidbase=android-google\n',
+            '# end build properties\n',
+            'ro.carrier.footer=true\n',
+            'ro.com.google.clientidbase=android-oem\n',
+        ]
+        self.assertEqual(
+            cbpd.find_duplicate_props(lines), [
+                'property ro.com.google.clientidbase on line 5 is already set '
+                'on line 2'
+            ])
+
+    def test_no_duplicates(self):
+        lines = [
+            'ro.build.id?=TQ1A.221205.011\n',
+            'ro.build.type=user\n',
+            'not a property\n',
+        ]
+        self.assertEqual(cbpd.find_duplicate_props(lines), [])
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
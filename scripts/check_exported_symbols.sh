@@ -0,0 +1,83 @@
+#!/bin/bash -eu
+
+# Copyright 2022 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Script to verify that a shared library only exports the symbols listed in an allowlist
+# Inputs:
+#  Environment:
+#   CLANG_BIN: path to the clang bin directory
+#  Arguments:
+#   -i ${file}: input shared library (required)
+#   -a ${file}: allowlist of symbol names, one per line, '#' starts a comment (required)
+#   -o ${file}: timestamp file written when the check passes (required)
+
+OPTSTRING=a:i:o:
+
+usage() {
+    cat <<EOF
+Usage: check_exported_symbols.sh -i in-file -a allowlist-file -o out-file
+EOF
+    exit 1
+}
+
+while getopts $OPTSTRING opt; do
+    case "$opt" in
+        a) allowlist="${OPTARG}" ;;
+        i) infile="${OPTARG}" ;;
+        o) outfile="${OPTARG}" ;;
+        ?) usage ;;
+        *) echo "'${opt}' '${OPTARG}'"
+    esac
+done
+
+if [ -z "${infile:-}" ]; then
+    echo "-i argument is required"
+    usage
+fi
+
+if [ -z "${allowlist:-}" ]; then
+    echo "-a argument is required"
+    usage
+fi
+
+if [ -z "${outfile:-}" ]; then
+    echo "-o argument is required"
+    usage
+fi
+
+if [ -z "${CLANG_BIN:-}" ]; then
+    echo "CLANG_BIN environment variable must be set"
+    usage
+fi
+
+rm -f "${outfile}"
+
+# Symbol versions are dropped so that the allowlist only needs to list the symbol names.
+"${CLANG_BIN}/llvm-nm" -D --defined-only -P "${infile}" | cut -f1 -d" " | sed -e 's/@.*//' | \
+    LC_ALL=C sort -u > "${outfile}.exported"
+(sed -e 's/#.*//' -e 's/[[:space:]]//g' "${allowlist}" | grep -v '^$' || true) | \
+    LC_ALL=C sort -u > "${outfile}.allowed"
+
+unexpected=$(LC_ALL=C comm -23 "${outfile}.exported" "${outfile}.allowed")
+rm -f "${outfile}.exported" "${outfile}.allowed"
+
+if [ -n "${unexpected}" ]; then
+    echo "error: ${infile} exports symbols that are not listed in ${allowlist}:" >&2
+    echo "${unexpected}" | sed -e 's/^/    /' >&2
+    echo "Hide them, for example with cflags_visibility: \"hidden\", or add them to the allowlist." >&2
+    exit 1
+fi
+
+touch "${outfile}"
@@ -0,0 +1,76 @@
+#!/bin/bash -eu
+
+# Copyright 2022 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Script to warn about each of the include directories exported by a module that doesn't contain
+# any header, which is a file with one of the header extensions or without an extension, like the
+# C++ standard headers.
+# Inputs:
+#  Arguments:
+#   -m ${name}: name of the module, used in the messages (required)
+#   -e ${exts}: space separated list of header extensions (required)
+#   -o ${file}: timestamp file written when the check is done (required)
+#   ${dirs}: the exported include directories
+
+OPTSTRING=e:m:o:
+
+usage() {
+    cat <<EOF
+Usage: check_include_dirs.sh -m module -e exts -o out-file dir...
+EOF
+    exit 1
+}
+
+while getopts $OPTSTRING opt; do
+    case "$opt" in
+        e) exts="${OPTARG}" ;;
+        m) module="${OPTARG}" ;;
+        o) outfile="${OPTARG}" ;;
+        ?) usage ;;
+        *) echo "'${opt}' '${OPTARG}'"
+    esac
+done
+shift $((OPTIND - 1))
+
+if [ -z "${module:-}" ]; then
+    echo "-m argument is required"
+    usage
+fi
+
+if [ -z "${exts:-}" ]; then
+    echo "-e argument is required"
+    usage
+fi
+
+if [ -z "${outfile:-}" ]; then
+    echo "-o argument is required"
+    usage
+fi
+
+rm -f "${outfile}"
+
+find_args=(-type f "(" "!" -name "*.*")
+for ext in ${exts}; do
+    find_args+=(-o -name "*${ext}")
+done
+find_args+=(")" -print -quit)
+
+for dir in "$@"; do
+    if [ -z "$(find -L "${dir}" "${find_args[@]}")" ]; then
+        echo "warning: include directory ${dir} exported by ${module} does not contain any headers" >&2
+    fi
+done
+
+touch "${outfile}"
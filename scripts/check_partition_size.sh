@@ -0,0 +1,85 @@
+#!/bin/bash -eu
+
+# Copyright 2022 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Script to compare the total size of the files installed to a partition by Soong modules with the
+# size limit of the partition, and to report the largest modules when the total is close to it.
+# Inputs:
+#  Arguments:
+#   -p ${partition}: name of the partition (required)
+#   -i ${file}: file listing a module name and one of its installed files per line (required)
+#   -l ${bytes}: size limit of the partition (required)
+#   -m ${percent}: margin below the limit, as a percentage of it, within which the largest
+#      modules are reported (required)
+#   -e: fail instead of warning when the total size is within the margin
+#   -o ${file}: report file written when the check passes (required)
+
+OPTSTRING=p:i:l:m:eo:
+
+usage() {
+    cat <<EOF2
+Usage: check_partition_size.sh -p partition -i list-file -l limit -m margin [-e] -o out-file
+EOF2
+    exit 1
+}
+
+enforce=
+while getopts $OPTSTRING opt; do
+    case "$opt" in
+        p) partition="${OPTARG}" ;;
+        i) infile="${OPTARG}" ;;
+        l) limit="${OPTARG}" ;;
+        m) margin="${OPTARG}" ;;
+        e) enforce=true ;;
+        o) outfile="${OPTARG}" ;;
+        ?) usage ;;
+        *) echo "'${opt}' '${OPTARG}'"
+    esac
+done
+
+for arg in partition infile limit margin outfile; do
+    if [ -z "${!arg:-}" ]; then
+        echo "missing required argument for ${arg}"
+        usage
+    fi
+done
+
+rm -f "${outfile}"
+
+# Sum the sizes of the installed files of each module, largest module first.
+sizes=$(while read -r module file; do
+    echo "${module} $(stat -L -c %s "${file}")"
+done < "${infile}" | awk '{ s[$1] += $2 } END { for (m in s) print s[m], m }' | \
+    LC_ALL=C sort -k1,1nr -k2,2)
+total=$(echo "${sizes}" | awk '{ t += $1 } END { print t + 0 }')
+threshold=$(( limit - limit * margin / 100 ))
+
+report() {
+    echo "${partition}: ${total} bytes installed by Soong modules, limit is ${limit} bytes"
+    echo "Largest modules:"
+    echo "${sizes}" | head -n 20 | awk 'NF { printf "    %12d %s\n", $1, $2 }'
+}
+
+if [ "${total}" -ge "${threshold}" ]; then
+    if [ -n "${enforce}" ]; then
+        echo "error: partition ${partition} is within ${margin}% of its size limit" >&2
+        report >&2
+        exit 1
+    fi
+    echo "warning: partition ${partition} is within ${margin}% of its size limit" >&2
+    report >&2
+fi
+
+report > "${outfile}"
@@ -0,0 +1,85 @@
+#!/bin/bash -eu
+
+# Copyright 2022 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Script to verify that a prebuilt shared library exports all the symbols exported by the shared
+# library built from source that it replaces
+# Inputs:
+#  Environment:
+#   CLANG_BIN: path to the clang bin directory
+#  Arguments:
+#   -i ${file}: prebuilt shared library (required)
+#   -s ${file}: shared library built from source (required)
+#   -o ${file}: timestamp file written when the check passes (required)
+
+OPTSTRING=i:s:o:
+
+usage() {
+    cat <<EOF
+Usage: check_prebuilt_abi.sh -i prebuilt-file -s source-file -o out-file
+EOF
+    exit 1
+}
+
+while getopts $OPTSTRING opt; do
+    case "$opt" in
+        i) infile="${OPTARG}" ;;
+        s) sourcefile="${OPTARG}" ;;
+        o) outfile="${OPTARG}" ;;
+        ?) usage ;;
+        *) echo "'${opt}' '${OPTARG}'"
+    esac
+done
+
+if [ -z "${infile:-}" ]; then
+    echo "-i argument is required"
+    usage
+fi
+
+if [ -z "${sourcefile:-}" ]; then
+    echo "-s argument is required"
+    usage
+fi
+
+if [ -z "${outfile:-}" ]; then
+    echo "-o argument is required"
+    usage
+fi
+
+if [ -z "${CLANG_BIN:-}" ]; then
+    echo "CLANG_BIN environment variable must be set"
+    usage
+fi
+
+rm -f "${outfile}"
+
+# Symbols are compared with their versions, as a prebuilt that drops a symbol version breaks the
+# libraries linked against the source library just like one that drops the symbol.
+"${CLANG_BIN}/llvm-nm" -D --defined-only -P "${infile}" | cut -f1 -d" " | \
+    LC_ALL=C sort -u > "${outfile}.prebuilt"
+"${CLANG_BIN}/llvm-nm" -D --defined-only -P "${sourcefile}" | cut -f1 -d" " | \
+    LC_ALL=C sort -u > "${outfile}.source"
+
+missing=$(LC_ALL=C comm -13 "${outfile}.prebuilt" "${outfile}.source")
+rm -f "${outfile}.prebuilt" "${outfile}.source"
+
+if [ -n "${missing}" ]; then
+    echo "error: prebuilt ${infile} does not export symbols exported by ${sourcefile}:" >&2
+    echo "${missing}" | sed -e 's/^/    /' >&2
+    echo "Update the prebuilt, or set SOONG_SKIP_PREBUILT_ABI_CHECK=true to skip the check." >&2
+    exit 1
+fi
+
+touch "${outfile}"
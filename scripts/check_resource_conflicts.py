@@ -0,0 +1,102 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for checking that static Android libraries do not define the same
+resources, which would make the merged resources depend on the order of the
+libraries."""
+
+from __future__ import print_function
+
+import argparse
+import sys
+
+
+def parse_args(args):
+    """Parse commandline arguments."""
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        '--lib',
+        dest='libs',
+        action='append',
+        nargs='+',
+        default=[],
+        metavar=('NAME', 'R_TXT'),
+        help='a library, followed by its R.txt file and the R.txt files of '
+        'its static library dependencies')
+    parser.add_argument(
+        '--output', required=True, help='timestamp file to write on success')
+    return parser.parse_args(args)
+
+
+def read_resources(r_txt):
+    """Returns the set of "type/name" resources listed in an R.txt file."""
+    resources = set()
+    with open(r_txt) as f:
+        for line in f:
+            fields = line.split()
+            # Lines are of the form "int string app_name 0x7f010000" or
+            # "int[] styleable Foo { 0x7f010000 }".
+            if len(fields) >= 3:
+                resources.add(fields[1] + '/' + fields[2])
+    return resources
+
+
+def own_resources(r_txt, dep_r_txts):
+    """Returns the resources defined by a library itself rather than by its
+    static library dependencies."""
+    resources = read_resources(r_txt)
+    for dep_r_txt in dep_r_txts:
+        resources -= read_resources(dep_r_txt)
+    return resources
+
+
+def find_conflicts(libs):
+    """Returns a list of (resource, [library names]) for each resource that is
+    defined by more than one of the libraries."""
+    definers = {}
+    for name, resources in libs:
+        for resource in resources:
+            definers.setdefault(resource, []).append(name)
+    return [(resource, names)
+            for resource, names in sorted(definers.items())
+            if len(names) > 1]
+
+
+def main():
+    """Program entry point."""
+    args = parse_args(sys.argv[1:])
+
+    libs = []
+    for lib in args.libs:
+        if len(lib) < 2:
+            sys.exit('error: --lib requires a name and an R.txt file')
+        libs.append((lib[0], own_resources(lib[1], lib[2:])))
+
+    conflicts = find_conflicts(libs)
+    if conflicts:
+        for resource, names in conflicts:
+            print('error: resource %s is defined by %s' %
+                  (resource, ', '.join(names)), file=sys.stderr)
+        print('error: list the libraries in resource_merge_order to choose '
+              'which definitions take precedence', file=sys.stderr)
+        sys.exit(1)
+
+    with open(args.output, 'w'):
+        pass
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,80 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_resource_conflicts.py."""
+
+import os
+import shutil
+import sys
+import tempfile
+import unittest
+
+import check_resource_conflicts as crc
+
+sys.dont_write_bytecode = True
+
+
+class CheckResourceConflictsTest(unittest.TestCase):
+
+    def setUp(self):
+        self.tmpdir = tempfile.mkdtemp()
+
+    def tearDown(self):
+        shutil.rmtree(self.tmpdir)
+
+    def write_r_txt(self, name, contents):
+        path = os.path.join(self.tmpdir, name)
+        with open(path, 'w') as f:
+            f.write(contents)
+        return path
+
+    def test_read_resources(self):
+        r_txt = self.write_r_txt(
+            'R.txt', 'int string app_name 0x7f010000\n'
+            'int[] styleable Foo { 0x7f020000 }\n'
+            'int styleable Foo_bar 0\n')
+        self.assertEqual(
+            crc.read_resources(r_txt),
+            {'string/app_name', 'styleable/Foo', 'styleable/Foo_bar'})
+
+    def test_own_resources(self):
+        lib = self.write_r_txt(
+            'lib.txt', 'int string a 0x7f010000\nint string b 0x7f010001\n')
+        dep = self.write_r_txt('dep.txt', 'int string b 0x7f010000\n')
+        self.assertEqual(crc.own_resources(lib, [dep]), {'string/a'})
+
+    def test_no_conflicts(self):
+        libs = [('liba', {'string/a'}), ('libb', {'string/b'})]
+        self.assertEqual(crc.find_conflicts(libs), [])
+
+    def test_conflicts(self):
+        libs = [('liba', {'string/a', 'string/c'}),
+                ('libb', {'string/b', 'string/c'})]
+        self.assertEqual(
+            crc.find_conflicts(libs), [('string/c', ['liba', 'libb'])])
+
+    def test_parse_args(self):
+        args = crc.parse_args([
+            '--lib', 'liba', 'a/R.txt', '--lib', 'libb', 'b/R.txt', 'c/R.txt',
+            '--output', 'out'
+        ])
+        self.assertEqual(args.libs,
+                         [['liba', 'a/R.txt'], ['libb', 'b/R.txt', 'c/R.txt']])
+        self.assertEqual(args.output, 'out')
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
@@ -0,0 +1,148 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for checking the properties that a sysprop_library with
+exported_to_vendor: true exposes to vendor.
+
+Vendor code only sees the Public properties of a library owned by Platform,
+which form its vendor surface, while its Internal properties stay on the
+platform surface.  The vendor surface must not give access to an Internal
+property through another api_name, and read-only properties, whose names start
+with "ro.", must be exported as Readonly."""
+
+from __future__ import print_function
+
+import argparse
+import re
+import sys
+
+_TOKEN_RE = re.compile(r'\s+|#[^\n]*|"(?:[^"\\]|\\.)*"|[{}:;,]|[^\s{}:;,"#]+')
+
+
+def parse_args(args):
+    """Parse commandline arguments."""
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        'srcs', nargs='+', metavar='SYSPROP', help='the .sysprop files')
+    return parser.parse_args(args)
+
+
+def tokenize(text):
+    """Returns the tokens of a text format protobuf, without whitespace and
+    comments."""
+    tokens = []
+    pos = 0
+    while pos < len(text):
+        match = _TOKEN_RE.match(text, pos)
+        if not match:
+            raise ValueError('unexpected character %r' % text[pos])
+        token = match.group(0)
+        if not token.isspace() and not token.startswith('#'):
+            tokens.append(token)
+        pos = match.end()
+    return tokens
+
+
+def parse_message(tokens, pos=0, nested=False):
+    """Parses the fields of a text format protobuf message starting at
+    tokens[pos] into a dict of lists of values, which are strings for scalar
+    fields and dicts for message fields.  Returns the dict and the position
+    after the message."""
+    fields = {}
+    while pos < len(tokens):
+        name = tokens[pos]
+        if name == '}':
+            if not nested:
+                raise ValueError('unexpected "}"')
+            return fields, pos + 1
+        pos += 1
+        if pos < len(tokens) and tokens[pos] == ':':
+            pos += 1
+        if pos >= len(tokens):
+            raise ValueError('missing value of %s' % name)
+        if tokens[pos] == '{':
+            value, pos = parse_message(tokens, pos + 1, nested=True)
+        else:
+            value = tokens[pos]
+            if value.startswith('"'):
+                value = value[1:-1]
+            pos += 1
+        fields.setdefault(name, []).append(value)
+        if pos < len(tokens) and tokens[pos] in (';', ','):
+            pos += 1
+    if nested:
+        raise ValueError('missing "}"')
+    return fields, pos
+
+
+def read_props(sysprop):
+    """Returns the properties defined by a .sysprop file as a list of
+    (api_name, prop_name, scope, access) tuples.  The scope and access default
+    to Public and Readonly like in the protobuf."""
+    with open(sysprop) as f:
+        fields, _ = parse_message(tokenize(f.read()))
+    module = fields.get('module', [''])[0]
+    props = []
+    for prop in fields.get('prop', []):
+        api_name = prop.get('api_name', [''])[0]
+        prop_name = prop.get('prop_name', [module + '.' + api_name])[0]
+        scope = prop.get('scope', ['Public'])[0]
+        access = prop.get('access', ['Readonly'])[0]
+        props.append((api_name, prop_name, scope, access))
+    return props
+
+
+def check_vendor_surface(props):
+    """Returns the errors for the properties exposed to vendor."""
+    errors = []
+    internal = {prop_name: api_name
+                for api_name, prop_name, scope, _ in props
+                if scope != 'Public'}
+    for api_name, prop_name, scope, access in props:
+        if scope != 'Public':
+            continue
+        if prop_name in internal:
+            errors.append(
+                '%s exports %s to vendor, which is the Internal property %s' %
+                (api_name, prop_name, internal[prop_name]))
+        if prop_name.startswith('ro.') and access != 'Readonly':
+            errors.append(
+                '%s exports the read-only property %s to vendor with %s '
+                'access, it must be Readonly' % (api_name, prop_name, access))
+    return errors
+
+
+def main():
+    """Program entry point."""
+    args = parse_args(sys.argv[1:])
+
+    errors = []
+    for src in args.srcs:
+        try:
+            props = read_props(src)
+        except ValueError as e:
+            sys.exit('error: failed to parse %s: %s' % (src, e))
+        errors.extend(
+            '%s: %s' % (src, error) for error in check_vendor_surface(props))
+
+    if errors:
+        for error in errors:
+            print('error: %s' % error, file=sys.stderr)
+        sys.exit(1)
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,116 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_sysprop_vendor_surface.py."""
+
+import os
+import shutil
+import sys
+import tempfile
+import unittest
+
+import check_sysprop_vendor_surface as csvs
+
+sys.dont_write_bytecode = True
+
+SYSPROP = '''
+# Properties of the platform.
+owner: Platform
+module: "android.sysprop.PlatformProperties"
+
+prop {
+    api_name: "test_int"
+    type: Integer
+    prop_name: "ro.platform.test_int"
+    scope: Public
+    access: Readonly
+}
+prop {
+    api_name: "internal_string"
+    type: String
+    scope: Internal
+    access: ReadWrite
+    prop_name: "platform.internal_string"
+}
+prop {
+    api_name: "default_name"
+    type: Boolean
+}
+'''
+
+
+class CheckSyspropVendorSurfaceTest(unittest.TestCase):
+
+    def setUp(self):
+        self.tmpdir = tempfile.mkdtemp()
+
+    def tearDown(self):
+        shutil.rmtree(self.tmpdir)
+
+    def write_file(self, name, contents):
+        path = os.path.join(self.tmpdir, name)
+        with open(path, 'w') as f:
+            f.write(contents)
+        return path
+
+    def test_read_props(self):
+        sysprop = self.write_file('Platform.sysprop', SYSPROP)
+        self.assertEqual(csvs.read_props(sysprop), [
+            ('test_int', 'ro.platform.test_int', 'Public', 'Readonly'),
+            ('internal_string', 'platform.internal_string', 'Internal',
+             'ReadWrite'),
+            ('default_name', 'android.sysprop.PlatformProperties.default_name',
+             'Public', 'Readonly'),
+        ])
+
+    def test_parse_errors(self):
+        with self.assertRaises(ValueError):
+            csvs.parse_message(csvs.tokenize('prop { api_name: "a"'))
+        with self.assertRaises(ValueError):
+            csvs.parse_message(csvs.tokenize('}'))
+
+    def test_internal_props_stay_on_platform_surface(self):
+        sysprop = self.write_file('Platform.sysprop', SYSPROP)
+        self.assertEqual(csvs.check_vendor_surface(csvs.read_props(sysprop)),
+                         [])
+
+    def test_internal_prop_exported_to_vendor(self):
+        props = [
+            ('internal_name', 'platform.name', 'Internal', 'ReadWrite'),
+            ('public_name', 'platform.name', 'Public', 'Readonly'),
+        ]
+        self.assertEqual(csvs.check_vendor_surface(props), [
+            'public_name exports platform.name to vendor, which is the '
+            'Internal property internal_name'
+        ])
+
+    def test_read_only_prop_must_be_readonly(self):
+        props = [
+            ('public_name', 'ro.platform.name', 'Public', 'ReadWrite'),
+            ('internal_name', 'ro.platform.other', 'Internal', 'ReadWrite'),
+        ]
+        self.assertEqual(csvs.check_vendor_surface(props), [
+            'public_name exports the read-only property ro.platform.name to '
+            'vendor with ReadWrite access, it must be Readonly'
+        ])
+
+    def test_parse_args(self):
+        args = csvs.parse_args(['a.sysprop', 'b.sysprop'])
+        self.assertEqual(args.srcs, ['a.sysprop', 'b.sysprop'])
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
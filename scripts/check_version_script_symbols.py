@@ -0,0 +1,263 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for verifying that the dynamic symbol table of a shared library
+matches the global symbols declared in its linker version scripts.
+
+A symbol exported by the library that the version script doesn't make global
+is reported as unexpected, and a symbol listed by name in a global section that
+is not exported by the library is reported as missing. Like the linker, a
+pattern that names a symbol exactly takes precedence over a glob pattern ('*',
+'?' and '[...]'), so a symbol matched by a global glob is still local if a
+local section names it. Glob patterns never cause missing symbols to be
+reported. Patterns in extern "C++" blocks are matched against the demangled
+symbol names."""
+
+from __future__ import print_function
+
+import argparse
+import fnmatch
+import re
+import subprocess
+import sys
+
+COMMENT_RE = re.compile(r'/\*.*?\*/|#[^\n]*', re.DOTALL)
+TOKEN_RE = re.compile(r'"[^"]*"|[{};]|[^\s{};"]+')
+GLOB_CHARS = ('*', '?', '[')
+# A line of the output of llvm-nm -P, "name type value [size]". Demangled names
+# may contain spaces.
+NM_LINE_RE = re.compile(r'^(.+) [A-Za-z?] [0-9a-fA-F]+(?: [0-9a-fA-F]+)?$')
+
+
+class Pattern(object):
+    """A symbol name or glob pattern from a version script."""
+
+    def __init__(self, pattern, cxx=False, quoted=False):
+        self.pattern = pattern
+        self.cxx = cxx
+        # Quoted patterns are always matched literally.
+        self.glob = not quoted and any(c in pattern for c in GLOB_CHARS)
+
+    def matches(self, symbol, demangled):
+        name = demangled if self.cxx else symbol
+        if self.glob:
+            return fnmatch.fnmatchcase(name, self.pattern)
+        return name == self.pattern
+
+    def __eq__(self, other):
+        return (self.pattern, self.cxx, self.glob) == (other.pattern, other.cxx,
+                                                       other.glob)
+
+    def __repr__(self):
+        return 'Pattern(%r, cxx=%r, glob=%r)' % (self.pattern, self.cxx,
+                                                 self.glob)
+
+
+class VersionScript(object):
+    """The global patterns and version node names of a version script."""
+
+    def __init__(self):
+        self.versions = []
+        self.globals = []
+        self.locals = []
+
+    def merge(self, other):
+        """Adds the versions and patterns of another version script, as if
+        both were passed to the linker."""
+        self.versions.extend(other.versions)
+        self.globals.extend(other.globals)
+        self.locals.extend(other.locals)
+
+    def is_global(self, symbol, demangled):
+        """Returns true if the version script makes the symbol global.  A
+        symbol named exactly by a pattern gets the binding of that section,
+        otherwise it is global if it matches a global glob pattern.  Unlike
+        the linker, which leaves the binding of a symbol matched by no
+        pattern unchanged, such symbols are considered local."""
+        for patterns, binding in ((self.globals, True), (self.locals, False)):
+            if any(not p.glob and p.matches(symbol, demangled)
+                   for p in patterns):
+                return binding
+        return any(p.glob and p.matches(symbol, demangled)
+                   for p in self.globals)
+
+
+def parse_version_script(text):
+    """Parses the text of a version script."""
+    script = VersionScript()
+    tokens = TOKEN_RE.findall(COMMENT_RE.sub(' ', text))
+
+    depth = 0
+    section = script.globals
+    extern_lang = None
+    pending_extern = False
+    entry = []
+    quoted = False
+
+    i = 0
+    while i < len(tokens):
+        token = tokens[i]
+        i += 1
+        if token == '{':
+            if pending_extern:
+                pending_extern = False
+            elif depth == 0:
+                if entry:
+                    script.versions.append(' '.join(entry))
+                section = script.globals
+            entry = []
+            quoted = False
+            depth += 1
+        elif token == '}':
+            if depth == 2:
+                extern_lang = None
+            depth -= 1
+            entry = []
+            quoted = False
+        elif token == ';':
+            # Entries after the closing brace of a version node at depth 0 are
+            # the names of the nodes it depends on, which are ignored.
+            if entry and depth > 0:
+                section.append(
+                    Pattern(' '.join(entry),
+                            cxx=extern_lang == 'C++',
+                            quoted=quoted))
+            entry = []
+            quoted = False
+        elif depth == 1 and not entry and token in ('global:', 'local:'):
+            section = script.globals if token == 'global:' else script.locals
+        elif (depth == 1 and not entry and token in ('global', 'local') and
+              i < len(tokens) and tokens[i] == ':'):
+            section = script.globals if token == 'global' else script.locals
+            i += 1
+        elif depth == 1 and not entry and token == 'extern':
+            if i < len(tokens):
+                extern_lang = tokens[i].strip('"')
+                i += 1
+            pending_extern = True
+        elif token.startswith('"'):
+            entry.append(token[1:-1])
+            quoted = True
+        else:
+            entry.append(token)
+
+    return script
+
+
+def check_symbols(script, symbols, demangled_symbols=None):
+    """Returns the unexpected and missing symbols of a library that exports
+    symbols, in the order of symbols and of the version script respectively.
+
+    demangled_symbols contains the demangled names of symbols, in the same
+    order, and is only used to match the patterns of extern "C++" blocks."""
+    if demangled_symbols is None:
+        demangled_symbols = symbols
+    pairs = [(s, d)
+             for s, d in zip(symbols, demangled_symbols)
+             if s not in script.versions]
+
+    unexpected = []
+    for symbol, demangled in pairs:
+        if not script.is_global(symbol, demangled):
+            unexpected.append(symbol)
+
+    missing = []
+    for pattern in script.globals:
+        if pattern.glob or pattern.pattern in missing:
+            continue
+        if not any(pattern.matches(s, d) for s, d in pairs):
+            missing.append(pattern.pattern)
+
+    return unexpected, missing
+
+
+def parse_nm_output(output):
+    """Returns the symbol names from the output of llvm-nm -P, without their
+    versions."""
+    symbols = []
+    for line in output.splitlines():
+        match = NM_LINE_RE.match(line)
+        if match:
+            symbols.append(match.group(1).split('@')[0])
+    return symbols
+
+
+def dynamic_symbols(llvm_nm, library, demangle):
+    """Returns the defined dynamic symbols of library, in the order of its
+    dynamic symbol table so that the mangled and demangled lists pair up."""
+    cmd = [llvm_nm, '-D', '--defined-only', '--no-sort', '-P']
+    if demangle:
+        cmd.append('-C')
+    output = subprocess.check_output(cmd + [library])
+    return parse_nm_output(output.decode('utf-8'))
+
+
+def parse_args(args):
+    """Parse commandline arguments."""
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        '--llvm-nm', required=True, help='the path to llvm-nm')
+    parser.add_argument(
+        '--version-script',
+        dest='version_scripts',
+        action='append',
+        required=True,
+        help='a version script the library was linked with, may be repeated')
+    parser.add_argument(
+        '--output',
+        required=True,
+        help='the timestamp file to write when the check passes')
+    parser.add_argument('library', help='the shared library to check')
+    return parser.parse_args(args)
+
+
+def main(argv):
+    args = parse_args(argv)
+
+    script = VersionScript()
+    for version_script in args.version_scripts:
+        with open(version_script) as f:
+            script.merge(parse_version_script(f.read()))
+
+    symbols = dynamic_symbols(args.llvm_nm, args.library, False)
+    demangled_symbols = dynamic_symbols(args.llvm_nm, args.library, True)
+    if len(symbols) != len(demangled_symbols):
+        print('error: llvm-nm listed %d symbols of %s but %d demangled '
+              'symbols' % (len(symbols), args.library, len(demangled_symbols)),
+              file=sys.stderr)
+        return 1
+    unexpected, missing = check_symbols(script, symbols, demangled_symbols)
+
+    if unexpected or missing:
+        print('error: the symbols exported by %s do not match %s:' %
+              (args.library, ' '.join(args.version_scripts)),
+              file=sys.stderr)
+        for symbol in unexpected:
+            print('+ %s (exported but not in the version script)' % symbol,
+                  file=sys.stderr)
+        for symbol in missing:
+            print('- %s (in the version script but not exported)' % symbol,
+                  file=sys.stderr)
+        return 1
+
+    with open(args.output, 'w'):
+        pass
+
+    return 0
+
+
+if __name__ == '__main__':
+    sys.exit(main(sys.argv[1:]))
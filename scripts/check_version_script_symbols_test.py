@@ -0,0 +1,247 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_version_script_symbols.py."""
+
+import os
+import shutil
+import stat
+import sys
+import tempfile
+import unittest
+
+import check_version_script_symbols as cvs
+
+sys.dont_write_bytecode = True
+
+
+class ParseVersionScriptTest(unittest.TestCase):
+
+    def test_sections(self):
+        script = cvs.parse_version_script('''
+LIBFOO_1 { # introduced=29
+  global:
+    foo;
+    bar_*; /* all of the bar functions */
+  local:
+    *;
+};
+
+LIBFOO_2 {
+    baz;
+} LIBFOO_1;
+''')
+        self.assertEqual(script.versions, ['LIBFOO_1', 'LIBFOO_2'])
+        self.assertEqual(script.globals, [
+            cvs.Pattern('foo'),
+            cvs.Pattern('bar_*'),
+            cvs.Pattern('baz'),
+        ])
+        self.assertEqual(script.locals, [cvs.Pattern('*')])
+
+    def test_anonymous(self):
+        script = cvs.parse_version_script('{ global: foo; local: *; };')
+        self.assertEqual(script.versions, [])
+        self.assertEqual(script.globals, [cvs.Pattern('foo')])
+
+    def test_extern_cxx(self):
+        script = cvs.parse_version_script('''
+LIBFOO {
+  global:
+    extern "C++" {
+      foo::*;
+      "bar(int, char)";
+    };
+    baz;
+  local:
+    *;
+};
+''')
+        self.assertEqual(script.globals, [
+            cvs.Pattern('foo::*', cxx=True),
+            cvs.Pattern('bar(int, char)', cxx=True, quoted=True),
+            cvs.Pattern('baz'),
+        ])
+        self.assertFalse(script.globals[1].glob)
+
+
+class CheckSymbolsTest(unittest.TestCase):
+
+    def setUp(self):
+        self.script = cvs.parse_version_script('''
+LIBFOO {
+  global:
+    foo;
+    bar;
+  local:
+    *;
+};
+''')
+
+    def test_passing(self):
+        self.assertEqual(
+            cvs.check_symbols(self.script, ['bar', 'foo', 'LIBFOO']), ([], []))
+
+    def test_unexpected(self):
+        self.assertEqual(
+            cvs.check_symbols(self.script, ['bar', 'foo', 'internal']),
+            (['internal'], []))
+
+    def test_missing(self):
+        self.assertEqual(
+            cvs.check_symbols(self.script, ['foo']), ([], ['bar']))
+
+    def test_glob(self):
+        script = cvs.parse_version_script('''
+LIBFOO {
+  global:
+    foo_*;
+    bar;
+  local:
+    *;
+};
+''')
+        self.assertEqual(
+            cvs.check_symbols(script, ['foo_open', 'foo_close', 'bar']),
+            ([], []))
+        self.assertEqual(
+            cvs.check_symbols(script, ['bar', 'baz']), (['baz'], []))
+
+    def test_local_name_overrides_global_glob(self):
+        script = cvs.parse_version_script('''
+LIBFOO {
+  global:
+    foo_*;
+  local:
+    foo_internal;
+    *;
+};
+''')
+        self.assertEqual(
+            cvs.check_symbols(script, ['foo_open']), ([], []))
+        self.assertEqual(
+            cvs.check_symbols(script, ['foo_open', 'foo_internal']),
+            (['foo_internal'], []))
+
+    def test_global_name_overrides_local_glob(self):
+        script = cvs.parse_version_script('''
+LIBFOO {
+  global:
+    foo_internal;
+  local:
+    foo_*;
+};
+''')
+        self.assertEqual(
+            cvs.check_symbols(script, ['foo_internal', 'foo_open']),
+            (['foo_open'], []))
+
+    def test_extern_cxx(self):
+        script = cvs.parse_version_script('''
+LIBFOO {
+  global:
+    extern "C++" {
+      foo::*;
+    };
+  local:
+    *;
+};
+''')
+        self.assertEqual(
+            cvs.check_symbols(script, ['_ZN3foo3getEv', '_Z3barv'],
+                              ['foo::get()', 'bar()']), (['_Z3barv'], []))
+
+
+class ParseNmOutputTest(unittest.TestCase):
+
+    def test_versions(self):
+        output = ('LIBFOO A 0 0\n'
+                  'foo@@LIBFOO T 1000 10\n'
+                  'bar T 1010 10\n')
+        self.assertEqual(
+            cvs.parse_nm_output(output), ['LIBFOO', 'foo', 'bar'])
+
+    def test_demangled(self):
+        output = 'foo::bar(int, char) T 1000 10\n'
+        self.assertEqual(cvs.parse_nm_output(output), ['foo::bar(int, char)'])
+
+
+class MainTest(unittest.TestCase):
+
+    # A fake llvm-nm that lists the symbols in the order of the symbol table,
+    # where sorting the demangled names would put them in a different order
+    # than the mangled ones.
+    FAKE_NM = '''#!/bin/sh
+case " $* " in *" --no-sort "*) ;; *) exit 1 ;; esac
+case " $* " in
+  *" -C "*) printf 'foo::get() T 1000 10\\nbar() T 1010 10\\n' ;;
+  *) printf '_ZN3foo3getEv T 1000 10\\n_Z3barv T 1010 10\\n' ;;
+esac
+'''
+
+    def setUp(self):
+        self.tmpdir = tempfile.mkdtemp()
+
+    def tearDown(self):
+        shutil.rmtree(self.tmpdir)
+
+    def write_file(self, name, contents):
+        path = os.path.join(self.tmpdir, name)
+        with open(path, 'w') as f:
+            f.write(contents)
+        return path
+
+    def run_check(self, version_script):
+        nm = self.write_file('llvm-nm', self.FAKE_NM)
+        os.chmod(nm, os.stat(nm).st_mode | stat.S_IEXEC)
+        script = self.write_file('libfoo.map.txt', version_script)
+        output = os.path.join(self.tmpdir, 'libfoo.so.timestamp')
+        ret = cvs.main([
+            '--llvm-nm', nm, '--version-script', script, '--output', output,
+            os.path.join(self.tmpdir, 'libfoo.so')
+        ])
+        return ret, os.path.exists(output)
+
+    def test_demangled_names_pair_up(self):
+        self.assertEqual(
+            self.run_check('''
+LIBFOO {
+  global:
+    extern "C++" {
+      foo::*;
+    };
+    _Z3barv;
+  local:
+    *;
+};
+'''), (0, True))
+
+    def test_unexpected_symbol_fails(self):
+        self.assertEqual(
+            self.run_check('''
+LIBFOO {
+  global:
+    extern "C++" {
+      foo::*;
+    };
+  local:
+    *;
+};
+'''), (1, False))
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
@@ -0,0 +1,146 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for generating a linker version script from the exported headers of
+a library. Every function or variable whose declaration is annotated with the
+export macro is made global, everything else is made local.
+
+Only symbols with C linkage are supported, as the names in the version script
+are matched against the unmangled names found in the declarations."""
+
+from __future__ import print_function
+
+import argparse
+import re
+import sys
+
+COMMENT_RE = re.compile(r'//[^\n]*|/\*.*?\*/', re.DOTALL)
+STRING_RE = re.compile(r'"(?:\\.|[^"\\])*"')
+IDENTIFIER_RE = re.compile(r'[A-Za-z_][A-Za-z0-9_]*')
+# The name of a declared function pointer variable, e.g. "void (*name)(int)".
+FUNCTION_POINTER_NAME_RE = re.compile(r'\(\s*\*\s*([A-Za-z_][A-Za-z0-9_]*)\s*\)')
+# The name of a declared function, which is the identifier just before the
+# opening parenthesis of the parameter list.
+FUNCTION_NAME_RE = re.compile(r'([A-Za-z_][A-Za-z0-9_]*)\s*\(')
+
+
+def parse_args(args):
+    """Parse commandline arguments."""
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        '--export-macro',
+        required=True,
+        help='the macro that annotates the exported declarations')
+    parser.add_argument(
+        '--output', required=True, help='the version script to write')
+    parser.add_argument(
+        'headers',
+        nargs='*',
+        help='the headers to scan, or @file to read them from a file')
+    return parser.parse_args(args)
+
+
+def strip_comments_and_preprocessor(text):
+    """Returns the text with the comments and the preprocessor directives,
+    including the definition of the export macro itself, removed."""
+    text = COMMENT_RE.sub(' ', text)
+    text = text.replace('\\\n', ' ')
+    lines = [l for l in text.split('\n') if not l.lstrip().startswith('#')]
+    return '\n'.join(lines)
+
+
+def declaration_name(declaration):
+    """Returns the name of the function or variable declared by declaration,
+    or None if it cannot be determined."""
+    match = FUNCTION_POINTER_NAME_RE.search(declaration)
+    if match:
+        return match.group(1)
+    match = FUNCTION_NAME_RE.search(declaration)
+    if match:
+        return match.group(1)
+    # A variable, possibly with an initializer or an array size.
+    declaration = re.split(r'[=\[]', declaration, 1)[0]
+    names = IDENTIFIER_RE.findall(declaration)
+    if names:
+        return names[-1]
+    return None
+
+
+def exported_symbols(text, export_macro):
+    """Returns the names of the declarations in the header text that are
+    annotated with export_macro."""
+    macro_re = re.compile(r'\b%s\b' % re.escape(export_macro))
+    text = strip_comments_and_preprocessor(text)
+    # Remove the string literals, e.g. the "C" of extern "C" blocks. The braces
+    # of those blocks, of function bodies and of type definitions separate the
+    # declarations just like semicolons.
+    text = STRING_RE.sub(' ', text)
+    symbols = []
+    for declaration in re.split(r'[;{}]', text):
+        if not macro_re.search(declaration):
+            continue
+        declaration = macro_re.sub(' ', declaration)
+        # Type definitions, e.g. "struct EXPORT foo", have no symbol.
+        if re.match(r'\s*(typedef|struct|union|enum|class)\b', declaration):
+            continue
+        name = declaration_name(declaration)
+        if name:
+            symbols.append(name)
+    return symbols
+
+
+def version_script(symbols):
+    """Returns a version script that makes symbols global and everything
+    else local."""
+    lines = ['{']
+    if symbols:
+        lines.append('  global:')
+        lines.extend('    %s;' % s for s in sorted(set(symbols)))
+    lines.append('  local:')
+    lines.append('    *;')
+    lines.append('};')
+    return '\n'.join(lines) + '\n'
+
+
+def expand_response_files(args):
+    """Replaces the @file arguments with the whitespace separated arguments
+    contained in the file."""
+    expanded = []
+    for arg in args:
+        if arg.startswith('@'):
+            with open(arg[1:]) as f:
+                expanded.extend(f.read().split())
+        else:
+            expanded.append(arg)
+    return expanded
+
+
+def main(argv):
+    args = parse_args(argv)
+
+    symbols = []
+    for header in expand_response_files(args.headers):
+        with open(header) as f:
+            symbols.extend(exported_symbols(f.read(), args.export_macro))
+
+    with open(args.output, 'w') as f:
+        f.write(version_script(symbols))
+
+    return 0
+
+
+if __name__ == '__main__':
+    sys.exit(main(sys.argv[1:]))
@@ -0,0 +1,91 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for gen_version_script_from_headers.py."""
+
+import sys
+import unittest
+
+import gen_version_script_from_headers as gvs
+
+sys.dont_write_bytecode = True
+
+
+class ExportedSymbolsTest(unittest.TestCase):
+
+    def test_functions_and_variables(self):
+        header = '''
+#pragma once
+#define MY_EXPORT __attribute__((visibility("default")))
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+// MY_EXPORT int commented_out(void);
+MY_EXPORT int foo(int a, int b);
+MY_EXPORT const char*
+    multi_line(void);
+int MY_EXPORT after_type(void);
+MY_EXPORT extern int counter;
+MY_EXPORT extern const char names[4];
+MY_EXPORT extern void (*handler)(int);
+int not_exported(void);
+typedef struct MY_EXPORT my_struct { int x; } my_struct_t;
+
+static inline int inline_helper(void) { return 0; }
+
+#ifdef __cplusplus
+}
+#endif
+'''
+        self.assertEqual(
+            gvs.exported_symbols(header, 'MY_EXPORT'), [
+                'foo',
+                'multi_line',
+                'after_type',
+                'counter',
+                'names',
+                'handler',
+            ])
+
+    def test_macro_is_matched_as_a_word(self):
+        header = 'MY_EXPORTS int foo(void);\nMY_EXPORT int bar(void);\n'
+        self.assertEqual(gvs.exported_symbols(header, 'MY_EXPORT'), ['bar'])
+
+
+class VersionScriptTest(unittest.TestCase):
+
+    def test_version_script(self):
+        self.assertEqual(
+            gvs.version_script(['foo', 'bar', 'foo']), '{\n'
+            '  global:\n'
+            '    bar;\n'
+            '    foo;\n'
+            '  local:\n'
+            '    *;\n'
+            '};\n')
+
+    def test_no_symbols(self):
+        self.assertEqual(
+            gvs.version_script([]), '{\n'
+            '  local:\n'
+            '    *;\n'
+            '};\n')
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
@@ -52,6 +52,12 @@ class SetValue(str):
     cur[key] = val
 
 
+class SetIntValue(str):
+  def apply(self, obj, val):
+    cur, key = ensure_path(obj, self)
+    cur[key] = int(val)
+
+
 class Replace(str):
   def apply(self, obj, val):
     cur, key = follow_path(obj, self)
@@ -87,6 +93,10 @@ def main():
                       help='set value of the key specified by path. If path doesn\'t exist, creates new one.',
                       metavar=('path', 'value'),
                       nargs=2, dest='patch', default=[], action='append')
+  parser.add_argument("-i", "--int_value", type=SetIntValue,
+                      help='set value of the key specified by path to an integer. If path doesn\'t exist, creates new one.',
+                      metavar=('path', 'value'),
+                      nargs=2, dest='patch', action='append')
   parser.add_argument("-s", "--replace", type=Replace,
                       help='replace value of the key specified by path. If path doesn\'t exist, no op.',
                       metavar=('path', 'value'),
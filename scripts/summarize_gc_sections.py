@@ -0,0 +1,189 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for summarizing the sections removed by the linker's garbage
+collection of unused sections, as reported by --print-gc-sections, so that the
+owners of dead code can be found."""
+
+from __future__ import print_function
+
+import argparse
+import json
+import re
+import struct
+import sys
+
+# lld reports a removed section as
+#   removing unused section path/to/foo.o:(.text.foo)
+# or, for a member of an archive, as
+#   removing unused section path/to/libfoo.a(foo.o):(.text.foo)
+REMOVED_SECTION_RE = re.compile(
+    r'removing unused section (?P<file>[^(:]+)(?:\((?P<member>[^)]+)\))?'
+    r':\((?P<section>[^)]*)\)')
+
+
+def parse_args(args):
+    """Parse commandline arguments."""
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        '--modules',
+        required=True,
+        help='file containing a "name report" line for each module')
+    parser.add_argument(
+        '--output',
+        required=True,
+        help='JSON file to write the summary of removed sections to')
+    return parser.parse_args(args)
+
+
+def parse_report(lines):
+    """Returns a list of (file, archive member or None, section) for each
+    removed section in the lines of a --print-gc-sections report."""
+    removed = []
+    for line in lines:
+        match = REMOVED_SECTION_RE.search(line)
+        if match:
+            removed.append((match.group('file'), match.group('member'),
+                            match.group('section')))
+    return removed
+
+
+def elf_section_sizes(data):
+    """Returns a dict mapping the section names of the ELF object in data to
+    their sizes."""
+    if data[:4] != b'\x7fELF':
+        return {}
+    is_64 = data[4:5] == b'\x02'
+    endian = '<' if data[5:6] == b'\x01' else '>'
+    if is_64:
+        shoff, = struct.unpack_from(endian + 'Q', data, 0x28)
+        shentsize, shnum, shstrndx = struct.unpack_from(endian + 'HHH', data,
+                                                        0x3a)
+        header_format = endian + 'IIQQQQ'
+    else:
+        shoff, = struct.unpack_from(endian + 'I', data, 0x20)
+        shentsize, shnum, shstrndx = struct.unpack_from(endian + 'HHH', data,
+                                                        0x2e)
+        header_format = endian + 'IIIIII'
+
+    headers = []
+    for i in range(shnum):
+        # name, type, flags, addr, offset, size
+        headers.append(
+            struct.unpack_from(header_format, data, shoff + i * shentsize))
+    if shstrndx >= len(headers):
+        return {}
+    strtab_offset = headers[shstrndx][4]
+
+    sizes = {}
+    for name_offset, _, _, _, _, size in headers:
+        start = strtab_offset + name_offset
+        end = data.index(b'\x00', start)
+        name = data[start:end].decode('utf-8', 'replace')
+        sizes[name] = sizes.get(name, 0) + size
+    return sizes
+
+
+def archive_members(data):
+    """Returns a dict mapping the member names of the ar archive in data to
+    their contents."""
+    members = {}
+    if data[:8] != b'!<arch>\n':
+        return members
+    long_names = b''
+    offset = 8
+    while offset + 60 <= len(data):
+        header = data[offset:offset + 60]
+        name = header[:16].decode('utf-8', 'replace').rstrip()
+        size = int(header[48:58].decode('ascii').strip())
+        contents = data[offset + 60:offset + 60 + size]
+        if name == '//':
+            long_names = contents
+        elif name.startswith('/') and name[1:].isdigit():
+            start = int(name[1:])
+            end = long_names.index(b'/\n', start)
+            members[long_names[start:end].decode('utf-8', 'replace')] = contents
+        elif name != '/':
+            members[name.rstrip('/')] = contents
+        offset += 60 + size + (size % 2)
+    return members
+
+
+class SectionSizes(object):
+    """Looks up the sizes of sections in object files and archive members,
+    caching the parsed files."""
+
+    def __init__(self):
+        self.cache = {}
+
+    def size(self, path, member, section):
+        """Returns the size of the section in the object file at path, or in
+        the member of the archive at path, or 0 if the section can't be found.
+        Raises IOError if the file can't be read."""
+        key = (path, member)
+        if key not in self.cache:
+            self.cache[key] = self.read_sizes(path, member)
+        return self.cache[key].get(section, 0)
+
+    @staticmethod
+    def read_sizes(path, member):
+        # The files are inputs of the rule, failing to read one is an error
+        # rather than a section of 0 bytes.
+        with open(path, 'rb') as f:
+            data = f.read()
+        if member:
+            data = archive_members(data).get(member, b'')
+        return elf_section_sizes(data)
+
+
+def summarize(modules, sizes):
+    """Returns a dict mapping each module name to the number of sections and
+    bytes that were removed when linking it.  modules is a list of (name,
+    report lines)."""
+    summary = {}
+    for name, lines in modules:
+        removed = parse_report(lines)
+        summary[name] = {
+            'removed_sections':
+                len(removed),
+            'removed_bytes':
+                sum(sizes.size(path, member, section)
+                    for path, member, section in removed),
+        }
+    return summary
+
+
+def main():
+    """Program entry point."""
+    args = parse_args(sys.argv[1:])
+
+    modules = []
+    with open(args.modules) as f:
+        for line in f:
+            fields = line.split()
+            if len(fields) == 2:
+                with open(fields[1]) as report:
+                    modules.append((fields[0], report.readlines()))
+
+    summary = summarize(modules, SectionSizes())
+
+    with open(args.output, 'w') as f:
+        json.dump(summary, f, indent=2, sort_keys=True)
+        f.write('\n')
+
+
+if __name__ == '__main__':
+    main()
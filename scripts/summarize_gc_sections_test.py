@@ -0,0 +1,147 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for summarize_gc_sections.py."""
+
+import os
+import shutil
+import struct
+import sys
+import tempfile
+import unittest
+
+import summarize_gc_sections as sgs
+
+sys.dont_write_bytecode = True
+
+
+def make_elf64(sections):
+    """Returns a little endian ELF64 object containing only section headers
+    for the given list of (name, size)."""
+    names = [''] + [name for name, _ in sections] + ['.shstrtab']
+    strtab = b''
+    name_offsets = []
+    for name in names:
+        name_offsets.append(len(strtab))
+        strtab += name.encode() + b'\x00'
+
+    shoff = 64 + len(strtab)
+    header = b'\x7fELF\x02\x01\x01' + b'\x00' * 9
+    header += struct.pack('<HHIQQQIHHHHHH', 1, 183, 1, 0, 0, shoff, 0, 64, 0,
+                          0, 64, len(names), len(names) - 1)
+
+    section_headers = b''
+    sizes = [0] + [size for _, size in sections] + [len(strtab)]
+    for i, size in enumerate(sizes):
+        offset = 64 if i == len(names) - 1 else 0
+        section_headers += struct.pack('<IIQQQQIIQQ', name_offsets[i], 1, 0,
+                                       0, offset, size, 0, 0, 1, 0)
+    return header + strtab + section_headers
+
+
+def make_archive(members):
+    """Returns an ar archive containing the given list of (name, contents)."""
+    data = b'!<arch>\n'
+    for name, contents in members:
+        data += ('%-16s%-12s%-6s%-6s%-8s%-10d`\n' %
+                 (name + '/', 0, 0, 0, 644, len(contents))).encode()
+        data += contents
+        if len(contents) % 2:
+            data += b'\n'
+    return data
+
+
+class SummarizeGcSectionsTest(unittest.TestCase):
+
+    def setUp(self):
+        self.tmpdir = tempfile.mkdtemp()
+
+    def tearDown(self):
+        shutil.rmtree(self.tmpdir)
+
+    def write_file(self, name, contents):
+        path = os.path.join(self.tmpdir, name)
+        with open(path, 'wb') as f:
+            f.write(contents)
+        return path
+
+    def test_parse_report(self):
+        lines = [
+            'ld.lld: removing unused section out/foo.o:(.text._Z3foov)\n',
+            'ld.lld: removing unused section out/libbar.a(bar.o):(.rodata.x)\n',
+            'ld.lld: warning: something else\n',
+        ]
+        self.assertEqual(
+            sgs.parse_report(lines), [
+                ('out/foo.o', None, '.text._Z3foov'),
+                ('out/libbar.a', 'bar.o', '.rodata.x'),
+            ])
+
+    def test_elf_section_sizes(self):
+        sizes = sgs.elf_section_sizes(
+            make_elf64([('.text.a', 16), ('.text.b', 32)]))
+        self.assertEqual(sizes['.text.a'], 16)
+        self.assertEqual(sizes['.text.b'], 32)
+        self.assertEqual(sgs.elf_section_sizes(b'not an elf file'), {})
+
+    def test_archive_members(self):
+        archive = make_archive([('a.o', b'abc'), ('b.o', b'de')])
+        self.assertEqual(
+            sgs.archive_members(archive), {
+                'a.o': b'abc',
+                'b.o': b'de',
+            })
+
+    def test_summarize(self):
+        foo = self.write_file('foo.o',
+                              make_elf64([('.text.a', 16), ('.text.b', 32)]))
+        libbar = self.write_file(
+            'libbar.a',
+            make_archive([('bar.o', make_elf64([('.rodata.x', 100)]))]))
+        modules = [
+            ('bin:android_arm64', [
+                'ld.lld: removing unused section %s:(.text.a)\n' % foo,
+                'ld.lld: removing unused section %s:(.text.b)\n' % foo,
+                'ld.lld: removing unused section %s(bar.o):(.rodata.x)\n' %
+                libbar,
+            ]),
+            ('libbaz:android_arm64_shared', []),
+        ]
+        self.assertEqual(
+            sgs.summarize(modules, sgs.SectionSizes()), {
+                'bin:android_arm64': {
+                    'removed_sections': 3,
+                    'removed_bytes': 148,
+                },
+                'libbaz:android_arm64_shared': {
+                    'removed_sections': 0,
+                    'removed_bytes': 0,
+                },
+            })
+
+    def test_summarize_missing_file(self):
+        missing = os.path.join(self.tmpdir, 'missing.o')
+        modules = [
+            ('bin:android_arm64', [
+                'ld.lld: removing unused section %s:(.text.a)\n' % missing,
+            ]),
+        ]
+        with self.assertRaises(IOError):
+            sgs.summarize(modules, sgs.SectionSizes())
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
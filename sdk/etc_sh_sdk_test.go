@@ -0,0 +1,170 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"testing"
+
+	"android/soong/android"
+	"android/soong/etc"
+	"android/soong/sh"
+)
+
+var prepareForSdkTestWithEtcAndSh = android.GroupFixturePreparers(
+	prepareForSdkTest,
+	etc.PrepareForTestWithPrebuiltEtc,
+	sh.PrepareForTestWithShBuildComponents,
+	android.FixtureMergeMockFs(android.MockFS{
+		"myconfig.xml": nil,
+		"myscript.sh":  nil,
+	}),
+)
+
+// prebuilt_etc and sh_binary have no prebuilt counterparts so the snapshot modules have the same
+// name as, and cannot coexist with, their source modules.
+func expectSnapshotConflictsWithSource(name string) []snapshotBuildInfoChecker {
+	handler := android.FixtureExpectsAtLeastOneErrorMatchingPattern(`module "` + name + `" already defined`)
+	return []snapshotBuildInfoChecker{
+		snapshotTestErrorHandler(checkSnapshotWithSourcePreferred, handler),
+		snapshotTestErrorHandler(checkSnapshotPreferredWithSource, handler),
+	}
+}
+
+func TestModuleExportsSnapshotWithEtcAndSh(t *testing.T) {
+	result := prepareForSdkTestWithEtcAndSh.RunTestWithBp(t, `
+		module_exports {
+			name: "myexports",
+			prebuilt_etcs: ["myconfig"],
+			sh_binaries: ["myscript"],
+		}
+
+		prebuilt_etc {
+			name: "myconfig",
+			src: "myconfig.xml",
+			filename_from_src: true,
+			sub_dir: "myexports",
+		}
+
+		sh_binary {
+			name: "myscript",
+			src: "myscript.sh",
+			filename: "run-myscript",
+			sub_dir: "tools",
+		}
+	`)
+
+	checkers := []snapshotBuildInfoChecker{
+		checkUnversionedAndroidBpContents(`
+// This is auto-generated. DO NOT EDIT.
+
+prebuilt_etc {
+    name: "myconfig",
+    visibility: ["//visibility:public"],
+    compile_multilib: "64",
+    src: "etc/myconfig/myconfig.xml",
+    filename: "myconfig.xml",
+    sub_dir: "myexports",
+}
+
+sh_binary {
+    name: "myscript",
+    visibility: ["//visibility:public"],
+    compile_multilib: "64",
+    src: "bin/myscript/myscript.sh",
+    filename: "run-myscript",
+    sub_dir: "tools",
+}
+`),
+		checkVersionedAndroidBpContents(`
+// This is auto-generated. DO NOT EDIT.
+
+prebuilt_etc {
+    name: "myexports_myconfig@current",
+    sdk_member_name: "myconfig",
+    visibility: ["//visibility:public"],
+    installable: false,
+    compile_multilib: "64",
+    src: "etc/myconfig/myconfig.xml",
+    filename: "myconfig.xml",
+    sub_dir: "myexports",
+}
+
+sh_binary {
+    name: "myexports_myscript@current",
+    sdk_member_name: "myscript",
+    visibility: ["//visibility:public"],
+    installable: false,
+    compile_multilib: "64",
+    src: "bin/myscript/myscript.sh",
+    filename: "run-myscript",
+    sub_dir: "tools",
+}
+
+module_exports_snapshot {
+    name: "myexports@current",
+    visibility: ["//visibility:public"],
+    compile_multilib: "64",
+    prebuilt_etcs: ["myexports_myconfig@current"],
+    sh_binaries: ["myexports_myscript@current"],
+}
+`),
+		checkAllCopyRules(`
+myconfig.xml -> etc/myconfig/myconfig.xml
+myscript.sh -> bin/myscript/myscript.sh
+`),
+	}
+	checkers = append(checkers, expectSnapshotConflictsWithSource("myconfig")...)
+
+	CheckSnapshot(t, result, "myexports", "", checkers...)
+}
+
+func TestModuleExportsSnapshotWithHostAndDeviceSh(t *testing.T) {
+	result := prepareForSdkTestWithEtcAndSh.RunTestWithBp(t, `
+		module_exports {
+			name: "myexports",
+			host_supported: true,
+			sh_binaries: ["myscript"],
+		}
+
+		sh_binary {
+			name: "myscript",
+			host_supported: true,
+			src: "myscript.sh",
+		}
+	`)
+
+	checkers := []snapshotBuildInfoChecker{
+		// The script is the same for all os types so it is only copied once and placed in the
+		// arch-common part of the module.
+		checkUnversionedAndroidBpContents(`
+// This is auto-generated. DO NOT EDIT.
+
+sh_binary {
+    name: "myscript",
+    visibility: ["//visibility:public"],
+    host_supported: true,
+    compile_multilib: "64",
+    src: "bin/myscript/myscript.sh",
+    filename: "myscript",
+}
+`),
+		checkAllCopyRules(`
+myscript.sh -> bin/myscript/myscript.sh
+`),
+	}
+	checkers = append(checkers, expectSnapshotConflictsWithSource("myscript")...)
+
+	CheckSnapshot(t, result, "myexports", "", checkers...)
+}
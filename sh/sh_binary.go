@@ -159,6 +159,7 @@ type TestProperties struct {
 type ShBinary struct {
 	android.ModuleBase
 	android.BazelModuleBase
+	android.SdkBase
 
 	properties shBinaryProperties
 
@@ -204,6 +205,11 @@ func (s *ShBinary) Installable() bool {
 	return s.properties.Installable == nil || proptools.Bool(s.properties.Installable)
 }
 
+// EverInstallable returns true if the module is ever installable.
+func (s *ShBinary) EverInstallable() bool {
+	return s.Installable()
+}
+
 func (s *ShBinary) Symlinks() []string {
 	return s.properties.Symlinks
 }
@@ -243,6 +249,19 @@ func (s *ShBinary) generateAndroidBuildActions(ctx android.ModuleContext) {
 	if s.properties.Src == nil {
 		ctx.PropertyErrorf("src", "missing prebuilt source file")
 	}
+	if err := android.ValidateRelativeInstallPath(s.SubDir()); err != nil {
+		ctx.PropertyErrorf("sub_dir", "%s", err)
+	}
+	if ctx.Host() {
+		// The init.rc files and VINTF fragments are only installed with device modules, so
+		// don't let them be silently dropped from the host variant.
+		if len(s.InitRc()) > 0 {
+			ctx.PropertyErrorf("init_rc", "not supported for host modules, set it in target.android instead")
+		}
+		if len(s.VintfFragments()) > 0 {
+			ctx.PropertyErrorf("vintf_fragments", "not supported for host modules, set it in target.android instead")
+		}
+	}
 
 	s.sourceFilePath = android.PathForModuleSrc(ctx, proptools.String(s.properties.Src))
 	filename := proptools.String(s.properties.Filename)
@@ -476,6 +495,7 @@ func (s *ShTest) AndroidMkEntries() []android.AndroidMkEntries {
 func InitShBinaryModule(s *ShBinary) {
 	s.AddProperties(&s.properties)
 	android.InitBazelModule(s)
+	android.InitSdkAwareModule(s)
 }
 
 // sh_binary is for a shell script or batch file to be installed as an
@@ -0,0 +1,138 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sh
+
+import (
+	"path/filepath"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// Contains support for adding sh_binary modules to a module_exports.
+
+func init() {
+	android.RegisterSdkMemberType(shBinaryMemberType)
+}
+
+var shBinaryMemberType = &shBinarySdkMemberType{
+	SdkMemberTypeBase: android.SdkMemberTypeBase{
+		PropertyName: "sh_binaries",
+
+		// An sh_binary has no prebuilt counterpart that can coexist with the source module so the
+		// snapshot simply contains another sh_binary module that replaces the source one.
+		UseSourceModuleTypeInSnapshot: true,
+	},
+}
+
+type shBinarySdkMemberType struct {
+	android.SdkMemberTypeBase
+}
+
+func (mt *shBinarySdkMemberType) AddDependencies(ctx android.SdkDependencyContext, dependencyTag blueprint.DependencyTag, names []string) {
+	// sh_binary modules only have a variant for the primary architecture of each os.
+	targets := ctx.MultiTargets()
+	if len(targets) == 0 {
+		return
+	}
+	variations := targets[0].Variations()
+	if ctx.Device() {
+		variations = append(variations,
+			blueprint.Variation{Mutator: "image", Variation: android.CoreVariation})
+	}
+	ctx.AddFarVariationDependencies(variations, dependencyTag, names...)
+}
+
+func (mt *shBinarySdkMemberType) IsInstance(module android.Module) bool {
+	_, ok := module.(*ShBinary)
+	return ok
+}
+
+func (mt *shBinarySdkMemberType) AddPrebuiltModule(ctx android.SdkMemberContext, member android.SdkMember) android.BpModule {
+	return ctx.SnapshotBuilder().AddPrebuiltModule(member, "sh_binary")
+}
+
+func (mt *shBinarySdkMemberType) CreateVariantPropertiesStruct() android.SdkMemberProperties {
+	return &shBinarySdkMemberProperties{}
+}
+
+var _ android.SdkMemberType = (*shBinarySdkMemberType)(nil)
+
+// shBinarySdkMemberProperties is the set of properties that need to be added to the sh_binary
+// module in the snapshot.
+//
+// The exported (capitalized) fields will be examined and may be changed during common value
+// extraction. The unexported fields will be left untouched.
+type shBinarySdkMemberProperties struct {
+	android.SdkMemberPropertiesBase
+
+	// archType is not exported as if set (to a non default value) it is always arch specific.
+	// This is "" for common properties.
+	archType string
+
+	// The source file of the script.
+	Src android.Path `android:"arch_variant"`
+
+	// The name of the installed file.
+	Filename string `android:"arch_variant"`
+
+	// The subdirectory of bin/ into which the file is installed.
+	Sub_dir string `android:"arch_variant"`
+
+	// Set to false if the module is not installable, nil otherwise.
+	Installable *bool
+}
+
+func (p *shBinarySdkMemberProperties) PopulateFromVariant(ctx android.SdkMemberContext, variant android.Module) {
+	s := variant.(*ShBinary)
+
+	p.archType = s.Target().Arch.ArchType.String()
+	p.Src = s.sourceFilePath
+	p.Filename = s.OutputFile().Base()
+	p.Sub_dir = s.SubDir()
+	if !s.Installable() {
+		p.Installable = s.properties.Installable
+	}
+}
+
+func (p *shBinarySdkMemberProperties) AddToPropertySet(ctx android.SdkMemberContext, propertySet android.BpPropertySet) {
+	if p.Src != nil {
+		// Files that are common to all os types are placed at the top level of the snapshot.
+		osPrefix := ""
+		if p.Os != android.CommonOS {
+			osPrefix = p.OsPrefix()
+		}
+		dest := filepath.Join(osPrefix, p.archType, "bin", ctx.Name(), p.Src.Base())
+		ctx.SnapshotBuilder().CopyToSnapshot(p.Src, dest)
+		propertySet.AddProperty("src", dest)
+	}
+
+	// Always set the filename as otherwise the versioned module in the snapshot would install the
+	// script using its own name.
+	if p.Filename != "" {
+		propertySet.AddProperty("filename", p.Filename)
+	}
+
+	if p.Sub_dir != "" {
+		propertySet.AddProperty("sub_dir", p.Sub_dir)
+	}
+
+	if p.Installable != nil {
+		propertySet.AddProperty("installable", *p.Installable)
+	}
+}
+
+var _ android.SdkMemberProperties = (*shBinarySdkMemberProperties)(nil)
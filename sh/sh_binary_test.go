@@ -65,6 +65,18 @@ func TestShTestSubDir(t *testing.T) {
 	android.AssertStringPathRelativeToTopEquals(t, "LOCAL_MODULE_PATH[0]", result.Config, expectedPath, actualPath)
 }
 
+func TestShBinaryInvalidSubDir(t *testing.T) {
+	prepareForShTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(`sub_dir: invalid relative install path "foo/../../bin": Path is outside directory: ../bin`)).
+		RunTestWithBp(t, `
+			sh_binary {
+				name: "foo",
+				src: "test.sh",
+				sub_dir: "foo/../../bin",
+			}
+		`)
+}
+
 func TestShTest(t *testing.T) {
 	result := android.GroupFixturePreparers(
 		prepareForShTest,
@@ -94,6 +106,35 @@ func TestShTest(t *testing.T) {
 	android.AssertDeepEquals(t, "LOCAL_TEST_DATA", expectedData, actualData)
 }
 
+func TestShTestPerTestcaseDirectory(t *testing.T) {
+	result := prepareForShTest.RunTestWithBp(t, `
+		sh_test {
+			name: "foo",
+			src: "test.sh",
+			test_suites: ["general-tests"],
+			per_testcase_directory: true,
+			data: ["testdata/data1"],
+		}
+
+		sh_test {
+			name: "foo_default",
+			src: "test.sh",
+			test_suites: ["general-tests"],
+			data: ["testdata/data1"],
+		}
+	`)
+
+	entries := func(name string) android.AndroidMkEntries {
+		mod := result.ModuleForTests(name, "android_arm64_armv8-a").Module()
+		return android.AndroidMkEntriesForTest(t, result.TestContext, mod)[0]
+	}
+
+	android.AssertDeepEquals(t, "per testcase data", []string{"foo/testdata/data1"},
+		android.AndroidMkTestDataSuitePaths(entries("foo"), "foo"))
+	android.AssertDeepEquals(t, "default data", []string{"testdata/data1"},
+		android.AndroidMkTestDataSuitePaths(entries("foo_default"), "foo_default"))
+}
+
 func TestShTest_dataModules(t *testing.T) {
 	ctx, config := testShBinary(t, `
 		sh_test {
@@ -255,3 +296,94 @@ func TestShTestHost_dataDeviceModulesAutogenTradefedConfig(t *testing.T) {
 		t.Errorf("foo extraConfings %v does not contain %q", autogen.Args["extraConfigs"], expectedBinAutogenConfig)
 	}
 }
+
+func TestShBinaryInitRcAndVintfFragments(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForShTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"foo.rc":  nil,
+			"foo.xml": nil,
+		}),
+	).RunTestWithBp(t, `
+		sh_binary {
+			name: "foo",
+			src: "test.sh",
+			init_rc: ["foo.rc"],
+			vintf_fragments: ["foo.xml"],
+		}
+
+		sh_binary {
+			name: "bar",
+			src: "test.sh",
+			vendor: true,
+			init_rc: ["foo.rc"],
+			vintf_fragments: ["foo.xml"],
+		}
+
+		sh_binary {
+			name: "baz",
+			src: "test.sh",
+			host_supported: true,
+			target: {
+				android: {
+					init_rc: ["foo.rc"],
+					vintf_fragments: ["foo.xml"],
+				},
+			},
+		}
+	`)
+
+	for _, tc := range []struct {
+		name      string
+		partition string
+	}{
+		{"foo", "system"},
+		{"bar", "vendor"},
+		{"baz", "system"},
+	} {
+		mod := result.ModuleForTests(tc.name, "android_arm64_armv8-a").Module().(*ShBinary)
+
+		entries := android.AndroidMkEntriesForTest(t, result.TestContext, mod)[0]
+		android.AssertStringPathsRelativeToTopEquals(t, tc.name+" LOCAL_FULL_INIT_RC", result.Config,
+			[]string{"foo.rc"}, entries.EntryMap["LOCAL_FULL_INIT_RC"])
+		android.AssertStringPathsRelativeToTopEquals(t, tc.name+" LOCAL_FULL_VINTF_FRAGMENTS", result.Config,
+			[]string{"foo.xml"}, entries.EntryMap["LOCAL_FULL_VINTF_FRAGMENTS"])
+
+		packaged := make(map[string]string)
+		for _, spec := range mod.PackagingSpecs() {
+			packaged[spec.RelPathInPackage()] = spec.Partition()
+		}
+		for _, file := range []string{"etc/init/foo.rc", "etc/vintf/manifest/foo.xml"} {
+			android.AssertStringEquals(t, tc.name+" partition of "+file, tc.partition, packaged[file])
+		}
+	}
+
+	buildOS := result.Config.BuildOS.String()
+	hostEntries := android.AndroidMkEntriesForTest(t, result.TestContext,
+		result.ModuleForTests("baz", buildOS+"_x86_64").Module())[0]
+	android.AssertDeepEquals(t, "host LOCAL_FULL_INIT_RC", []string(nil), hostEntries.EntryMap["LOCAL_FULL_INIT_RC"])
+	android.AssertDeepEquals(t, "host LOCAL_FULL_VINTF_FRAGMENTS", []string(nil),
+		hostEntries.EntryMap["LOCAL_FULL_VINTF_FRAGMENTS"])
+}
+
+func TestShBinaryHostInitRcAndVintfFragments(t *testing.T) {
+	for _, property := range []string{"init_rc", "vintf_fragments"} {
+		t.Run(property, func(t *testing.T) {
+			android.GroupFixturePreparers(
+				prepareForShTest,
+				android.FixtureMergeMockFs(android.MockFS{
+					"foo.rc": nil,
+				}),
+			).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+				property+`: not supported for host modules, set it in target.android instead`,
+			)).RunTestWithBp(t, `
+				sh_binary {
+					name: "foo",
+					src: "test.sh",
+					host_supported: true,
+					`+property+`: ["foo.rc"],
+				}
+			`)
+		})
+	}
+}
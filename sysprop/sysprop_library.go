@@ -157,6 +157,12 @@ type syspropLibraryProperties struct {
 	// Make this module available when building for product
 	Product_available *bool
 
+	// If set to true, the C++ implementation library is also built for vendor, exposing only the
+	// Public scope of the properties.  Only allowed for sysprop_library owned by Platform and
+	// installed in system.  The build fails if a Public property refers to the same system
+	// property as an Internal one, or exports a read-only "ro." property without Readonly access.
+	Exported_to_vendor *bool
+
 	// list of .sysprop files which defines the properties.
 	Srcs []string `android:"path"`
 
@@ -322,6 +328,13 @@ func (m *syspropLibrary) GenerateAndroidBuildActions(ctx android.ModuleContext)
 
 	m.checkApiFileTimeStamp = android.PathForModuleOut(ctx, "check_api.timestamp")
 
+	// 3. checks the properties exposed to vendor, which can only access the Public ones.
+	if proptools.Bool(m.properties.Exported_to_vendor) {
+		rule.Command().
+			BuiltTool("check_sysprop_vendor_surface").
+			Inputs(android.PathsForModuleSrc(ctx, m.properties.Srcs))
+	}
+
 	rule.Command().
 		Text("touch").
 		Output(m.checkApiFileTimeStamp)
@@ -464,6 +477,16 @@ func syspropLibraryHook(ctx android.LoadHookContext, m *syspropLibrary) {
 			"Unknown value %s: must be one of Platform, Vendor or Odm", m.Owner())
 	}
 
+	if proptools.Bool(m.properties.Exported_to_vendor) {
+		if !isOwnerPlatform || !installedInSystem {
+			ctx.PropertyErrorf("exported_to_vendor",
+				"only sysprop_library owned by Platform and installed in system can be exported to vendor")
+		}
+		if m.properties.Vendor_available != nil && !*m.properties.Vendor_available {
+			ctx.PropertyErrorf("vendor_available", "must not be false when exported_to_vendor is true")
+		}
+	}
+
 	// Generate a C++ implementation library.
 	// cc_library can receive *.sysprop files as their srcs, generating sources itself.
 	ccProps := ccLibraryProperties{}
@@ -478,6 +501,9 @@ func syspropLibraryHook(ctx android.LoadHookContext, m *syspropLibrary) {
 	ccProps.Target.Host.Static_libs = []string{"libbase", "liblog"}
 	ccProps.Recovery_available = m.properties.Recovery_available
 	ccProps.Vendor_available = m.properties.Vendor_available
+	if proptools.Bool(m.properties.Exported_to_vendor) {
+		ccProps.Vendor_available = proptools.BoolPtr(true)
+	}
 	ccProps.Product_available = m.properties.Product_available
 	ccProps.Ramdisk_available = m.properties.Ramdisk_available
 	ccProps.Host_supported = m.properties.Host_supported
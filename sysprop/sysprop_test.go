@@ -32,6 +32,11 @@ func TestMain(m *testing.M) {
 
 func test(t *testing.T, bp string) *android.TestResult {
 	t.Helper()
+	return testWithErrorHandler(t, bp, android.FixtureExpectsNoErrors)
+}
+
+func testWithErrorHandler(t *testing.T, bp string, errorHandler android.FixtureErrorHandler) *android.TestResult {
+	t.Helper()
 
 	bp += `
 		cc_library {
@@ -127,7 +132,7 @@ func test(t *testing.T, bp string) *android.TestResult {
 		}),
 		mockFS.AddToFixture(),
 		android.FixtureWithRootAndroidBp(bp),
-	).RunTest(t)
+	).ExtendWithErrorHandler(errorHandler).RunTest(t)
 
 	return result
 }
@@ -368,3 +373,54 @@ func TestMinSdkVersionIsForwarded(t *testing.T) {
 	propFromJava := javaModule.MinSdkVersionString()
 	android.AssertStringEquals(t, "min_sdk_version forwarding to java module", "30", propFromJava)
 }
+
+func TestSyspropLibraryExportedToVendor(t *testing.T) {
+	result := test(t, `
+		sysprop_library {
+			name: "sysprop-platform",
+			srcs: ["android/sysprop/PlatformProperties.sysprop"],
+			api_packages: ["android.sysprop"],
+			property_owner: "Platform",
+			exported_to_vendor: true,
+		}
+	`)
+
+	// Both the platform and the vendor variants of the C++ implementation library are created.
+	result.ModuleForTests("libsysprop-platform", "android_arm64_armv8-a_shared")
+	result.ModuleForTests("libsysprop-platform", "android_vendor.29_arm64_armv8-a_shared")
+	result.ModuleForTests("sysprop-platform", "android_common")
+
+	// The vendor surface is checked by the API check, which runs once for all variants.
+	checkApi := result.ModuleForTests("sysprop-platform_sysprop_library", "").Output("check_api.timestamp")
+	android.AssertStringListContains(t, "check api inputs", checkApi.Implicits.Strings(),
+		"android/sysprop/PlatformProperties.sysprop")
+	android.AssertStringListContains(t, "check api tools",
+		android.StringPathsRelativeToTop(result.Config.SoongOutDir(), checkApi.RuleParams.CommandDeps),
+		"out/soong/host/linux-x86/bin/check_sysprop_vendor_surface")
+}
+
+func TestSyspropLibraryExportedToVendorErrors(t *testing.T) {
+	testWithErrorHandler(t, `
+		sysprop_library {
+			name: "sysprop-vendor",
+			srcs: ["com/android/VendorProperties.sysprop"],
+			api_packages: ["com.android"],
+			property_owner: "Vendor",
+			vendor: true,
+			exported_to_vendor: true,
+		}
+	`, android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`exported_to_vendor: only sysprop_library owned by Platform and installed in system can be exported to vendor`))
+
+	testWithErrorHandler(t, `
+		sysprop_library {
+			name: "sysprop-platform",
+			srcs: ["android/sysprop/PlatformProperties.sysprop"],
+			api_packages: ["android.sysprop"],
+			property_owner: "Platform",
+			vendor_available: false,
+			exported_to_vendor: true,
+		}
+	`, android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`vendor_available: must not be false when exported_to_vendor is true`))
+}
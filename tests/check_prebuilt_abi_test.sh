@@ -0,0 +1,80 @@
+#!/bin/bash -eu
+
+set -o pipefail
+
+# How to run: bash path-to-script/check_prebuilt_abi_test.sh
+# Tests of build/soong/scripts/check_prebuilt_abi.sh, using a fake llvm-nm that prints the symbols
+# listed in the "libraries" it is given.
+
+SCRIPT="$(readlink -f "$(dirname "$0")"/../scripts/check_prebuilt_abi.sh)"
+
+function fail {
+  echo -e "\e[91;1mFAILED:\e[0m" $*
+  exit 1
+}
+
+TEST_DIR=$(mktemp -t -d check_prebuilt_abi.XXXXX)
+trap 'rm -rf "$TEST_DIR"' EXIT
+
+function setup {
+  rm -rf "$TEST_DIR"/*
+  mkdir -p "$TEST_DIR/clang/bin"
+  cat > "$TEST_DIR/clang/bin/llvm-nm" <<'EOS'
+#!/bin/bash -eu
+# Prints the lines of the last argument in the format of llvm-nm -P.
+while read -r symbol; do
+  echo "${symbol} T 0 0"
+done < "${@: -1}"
+EOS
+  chmod +x "$TEST_DIR/clang/bin/llvm-nm"
+  export CLANG_BIN="$TEST_DIR/clang/bin"
+}
+
+function run_check {
+  "$SCRIPT" -i "$TEST_DIR/prebuilt.so" -s "$TEST_DIR/source.so" -o "$TEST_DIR/check.timestamp" \
+    2> "$TEST_DIR/stderr"
+}
+
+function test_prebuilt_exports_all_symbols {
+  setup
+  printf 'foo@@LIBFOO\nbar@@LIBFOO\nextra@@LIBFOO\n' > "$TEST_DIR/prebuilt.so"
+  printf 'bar@@LIBFOO\nfoo@@LIBFOO\n' > "$TEST_DIR/source.so"
+
+  run_check || fail "the check failed for a prebuilt exporting all symbols: $(<"$TEST_DIR/stderr")"
+  [[ -e "$TEST_DIR/check.timestamp" ]] || fail "the timestamp file was not written"
+}
+
+function test_prebuilt_missing_symbol {
+  setup
+  printf 'foo@@LIBFOO\n' > "$TEST_DIR/prebuilt.so"
+  printf 'foo@@LIBFOO\nbar@@LIBFOO\n' > "$TEST_DIR/source.so"
+
+  if run_check; then
+    fail "the check passed for a prebuilt missing a symbol"
+  fi
+  [[ ! -e "$TEST_DIR/check.timestamp" ]] || fail "the timestamp file was written"
+  grep -q "does not export symbols exported by $TEST_DIR/source.so" "$TEST_DIR/stderr" || \
+    fail "missing error message: $(<"$TEST_DIR/stderr")"
+  grep -q "^    bar@@LIBFOO$" "$TEST_DIR/stderr" || \
+    fail "the missing symbol is not listed: $(<"$TEST_DIR/stderr")"
+  if grep -q "foo@@LIBFOO" "$TEST_DIR/stderr"; then
+    fail "an exported symbol is listed as missing: $(<"$TEST_DIR/stderr")"
+  fi
+}
+
+function test_prebuilt_missing_symbol_version {
+  setup
+  printf 'foo\n' > "$TEST_DIR/prebuilt.so"
+  printf 'foo@@LIBFOO\n' > "$TEST_DIR/source.so"
+
+  if run_check; then
+    fail "the check passed for a prebuilt dropping a symbol version"
+  fi
+  grep -q "^    foo@@LIBFOO$" "$TEST_DIR/stderr" || \
+    fail "the missing symbol version is not listed: $(<"$TEST_DIR/stderr")"
+}
+
+test_prebuilt_exports_all_symbols
+test_prebuilt_missing_symbol
+test_prebuilt_missing_symbol_version
+echo "Succeeded"
@@ -226,6 +226,20 @@ func AutoGenPythonBinaryHostTestConfig(ctx android.ModuleContext, testConfigProp
 	return path
 }
 
+// AutoGenPythonBinaryHostTestShardConfig generates the test config of a shard of a python test from
+// the same template as AutoGenPythonBinaryHostTestConfig, with the configs that select the tests of
+// the shard.
+func AutoGenPythonBinaryHostTestShardConfig(ctx android.ModuleContext, output android.WritablePath,
+	testConfigTemplateProp *string, config []Config) {
+
+	templatePath := getTestConfigTemplate(ctx, testConfigTemplateProp)
+	if templatePath.Valid() {
+		autogenTemplate(ctx, output, templatePath.String(), config, "")
+	} else {
+		autogenTemplate(ctx, output, "${PythonBinaryHostTestConfigTemplate}", config, "")
+	}
+}
+
 func AutoGenRustTestConfig(ctx android.ModuleContext, testConfigProp *string,
 	testConfigTemplateProp *string, testSuites []string, config []Config, autoGenConfig *bool, testInstallBase string) android.Path {
 	path, autogenPath := testConfigPath(ctx, testConfigProp, testSuites, autoGenConfig, testConfigTemplateProp)
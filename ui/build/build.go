@@ -261,6 +261,12 @@ func Build(ctx Context, config Config) {
 		what = what &^ RunKati
 	}
 
+	if config.ModuleQuery() != "" {
+		// Module queries are answered from the module index written by soong_build, nothing
+		// needs to be built.
+		what = what &^ (RunKati | RunKatiNinja | RunNinja | RunBazel | RunBuildTests)
+	}
+
 	if config.StartGoma() {
 		startGoma(ctx, config)
 	}
@@ -294,6 +300,11 @@ func Build(ctx Context, config Config) {
 		runSoong(ctx, config)
 	}
 
+	if config.ModuleQuery() != "" {
+		runModuleQuery(ctx, config)
+		return
+	}
+
 	if what&RunKati != 0 {
 		genKatiSuffix(ctx, config)
 		runKatiCleanSpec(ctx, config)
@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -46,7 +47,7 @@ type Config struct{ *configImpl }
 
 type configImpl struct {
 	// Some targets that are implemented in soong_build
-	// (bp2build, json-module-graph) are not here and have their own bits below.
+	// (bp2build, json-module-graph, module-deps-graph) are not here and have their own bits below.
 	arguments     []string
 	goma          bool
 	environ       *Environment
@@ -60,6 +61,7 @@ type configImpl struct {
 	checkbuild      bool
 	dist            bool
 	jsonModuleGraph bool
+	moduleDepsGraph bool
 	bp2build        bool
 	queryview       bool
 	reportMkMetrics bool // Collect and report mk2bp migration progress metrics.
@@ -71,6 +73,11 @@ type configImpl struct {
 	skipNinja       bool
 	skipSoongTests  bool
 
+	// moduleQuery is the module query subcommand, e.g. modules-under, and moduleQueryArgs are
+	// its arguments.
+	moduleQuery     string
+	moduleQueryArgs []string
+
 	// From the product config
 	katiArgs        []string
 	ninjaArgs       []string
@@ -429,6 +436,26 @@ func NewConfig(ctx Context, args ...string) Config {
 
 	ret.environ.Set("BUILD_DATETIME_FILE", buildDateTimeFile)
 
+	// Pass on various build environment metadata to Kati and Soong.
+	if _, ok := ret.environ.Get("BUILD_USERNAME"); !ok {
+		username := "unknown"
+		if u, err := user.Current(); err == nil {
+			username = u.Username
+		} else {
+			ctx.Println("Failed to get current user:", err)
+		}
+		ret.environ.Set("BUILD_USERNAME", username)
+	}
+
+	if _, ok := ret.environ.Get("BUILD_HOSTNAME"); !ok {
+		hostname, err := os.Hostname()
+		if err != nil {
+			ctx.Println("Failed to read hostname:", err)
+			hostname = "unknown"
+		}
+		ret.environ.Set("BUILD_HOSTNAME", hostname)
+	}
+
 	if ret.UseRBE() {
 		for k, v := range getRBEVars(ctx, Config{ret}) {
 			ret.environ.Set(k, v)
@@ -773,12 +800,18 @@ func (c *configImpl) parseArgs(ctx Context, args []string) {
 			c.dist = true
 		} else if arg == "json-module-graph" {
 			c.jsonModuleGraph = true
+		} else if arg == "module-deps-graph" {
+			c.moduleDepsGraph = true
 		} else if arg == "bp2build" {
 			c.bp2build = true
 		} else if arg == "queryview" {
 			c.queryview = true
 		} else if arg == "soong_docs" {
 			c.soongDocs = true
+		} else if isModuleQuery(arg) && c.moduleQuery == "" {
+			c.moduleQuery = arg
+		} else if c.moduleQuery != "" {
+			c.moduleQueryArgs = append(c.moduleQueryArgs, arg)
 		} else {
 			if arg == "checkbuild" {
 				c.checkbuild = true
@@ -852,7 +885,7 @@ func (c *configImpl) SoongBuildInvocationNeeded() bool {
 		return true
 	}
 
-	if !c.JsonModuleGraph() && !c.Bp2Build() && !c.Queryview() && !c.SoongDocs() {
+	if !c.JsonModuleGraph() && !c.ModuleDepsGraph() && c.ModuleQuery() == "" && !c.Bp2Build() && !c.Queryview() && !c.SoongDocs() {
 		// Command line was empty, the default Ninja target is built
 		return true
 	}
@@ -947,6 +980,17 @@ func (c *configImpl) ModuleActionsFile() string {
 	return shared.JoinPath(c.SoongOutDir(), "module-actions.json")
 }
 
+func (c *configImpl) ModuleDepsGraphFile() string {
+	return shared.JoinPath(c.SoongOutDir(), "module-deps-graph.json")
+}
+
+// ModuleDepsGraphRoots returns the comma separated list of modules whose dependencies are written
+// to the module dependency graph, from MODULE_DEPS_GRAPH_ROOTS.
+func (c *configImpl) ModuleDepsGraphRoots() string {
+	roots, _ := c.environ.Get("MODULE_DEPS_GRAPH_ROOTS")
+	return strings.Join(strings.Fields(strings.ReplaceAll(roots, ",", " ")), ",")
+}
+
 func (c *configImpl) TempDir() string {
 	return shared.TempDirForOutDir(c.SoongOutDir())
 }
@@ -976,6 +1020,10 @@ func (c *configImpl) JsonModuleGraph() bool {
 	return c.jsonModuleGraph
 }
 
+func (c *configImpl) ModuleDepsGraph() bool {
+	return c.moduleDepsGraph
+}
+
 func (c *configImpl) Bp2Build() bool {
 	return c.bp2build
 }
@@ -988,6 +1036,22 @@ func (c *configImpl) SoongDocs() bool {
 	return c.soongDocs
 }
 
+// ModuleQuery returns the module query subcommand that was passed instead of build goals, e.g.
+// modules-under or outputs-of, or an empty string if there was none.
+func (c *configImpl) ModuleQuery() string {
+	return c.moduleQuery
+}
+
+// ModuleQueryArgs returns the arguments of the module query subcommand.
+func (c *configImpl) ModuleQueryArgs() []string {
+	return c.moduleQueryArgs
+}
+
+// ModuleIndexFile returns the path to the module index that soong_build writes for module queries.
+func (c *configImpl) ModuleIndexFile() string {
+	return shared.JoinPath(c.SoongOutDir(), "module_index.json")
+}
+
 func (c *configImpl) IsVerbose() bool {
 	return c.verbose
 }
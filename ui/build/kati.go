@@ -19,7 +19,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/user"
 	"path/filepath"
 	"strings"
 
@@ -160,24 +159,14 @@ func runKati(ctx Context, config Config, extraSuffix string, args []string, envF
 	// Apply the caller's function closure to mutate the environment variables.
 	envFunc(cmd.Environment)
 
-	// Pass on various build environment metadata to Kati.
-	if _, ok := cmd.Environment.Get("BUILD_USERNAME"); !ok {
-		username := "unknown"
-		if u, err := user.Current(); err == nil {
-			username = u.Username
-		} else {
-			ctx.Println("Failed to get current user:", err)
-		}
-		cmd.Environment.Set("BUILD_USERNAME", username)
-	}
-
-	if _, ok := cmd.Environment.Get("BUILD_HOSTNAME"); !ok {
-		hostname, err := os.Hostname()
-		if err != nil {
-			ctx.Println("Failed to read hostname:", err)
-			hostname = "unknown"
+	// Pass on various build environment metadata to Kati, even when envFunc
+	// restricted the environment.
+	for _, key := range []string{"BUILD_USERNAME", "BUILD_HOSTNAME"} {
+		if _, ok := cmd.Environment.Get(key); !ok {
+			if value, ok := config.Environment().Get(key); ok {
+				cmd.Environment.Set(key, value)
+			}
 		}
-		cmd.Environment.Set("BUILD_HOSTNAME", hostname)
 	}
 
 	cmd.StartOrFatal()
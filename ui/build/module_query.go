@@ -0,0 +1,147 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// modulesUnderQuery lists the modules defined in or below the given directories.
+	modulesUnderQuery = "modules-under"
+	// outputsOfQuery lists the outputs and installed files of each variant of the given modules.
+	outputsOfQuery = "outputs-of"
+)
+
+func isModuleQuery(arg string) bool {
+	return arg == modulesUnderQuery || arg == outputsOfQuery
+}
+
+// moduleIndexEntry is an entry of out/soong/module_index.json, which is written by soong_build
+// when it is run with --module_index_file, see WriteModuleIndex in
+// build/soong/android/module_index.go.
+type moduleIndexEntry struct {
+	Name     string
+	Type     string
+	Dir      string
+	Variant  string
+	Outputs  []string
+	Installs []string
+}
+
+func loadModuleIndex(file string) ([]moduleIndexEntry, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var entries []moduleIndexEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s did not parse correctly: %s", file, err)
+	}
+	return entries, nil
+}
+
+// runModuleQuery prints the answer to the module query passed to soong_ui, e.g.
+// `m modules-under vendor/foo`, using the module index written by soong_build.
+func runModuleQuery(ctx Context, config Config) {
+	entries, err := loadModuleIndex(config.ModuleIndexFile())
+	if err != nil {
+		ctx.Fatalf("Failed to load the module index: %s", err)
+	}
+
+	lines, err := queryModuleIndex(entries, config.ModuleQuery(), config.ModuleQueryArgs())
+	if err != nil {
+		ctx.Fatalln(err)
+	}
+	for _, line := range lines {
+		fmt.Fprintln(ctx.Writer, line)
+	}
+}
+
+// queryModuleIndex returns the lines that answer the module query.
+func queryModuleIndex(entries []moduleIndexEntry, query string, args []string) ([]string, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s requires at least one argument", query)
+	}
+
+	switch query {
+	case modulesUnderQuery:
+		return modulesUnder(entries, args), nil
+	case outputsOfQuery:
+		return outputsOf(entries, args)
+	default:
+		return nil, fmt.Errorf("unknown module query %q", query)
+	}
+}
+
+// modulesUnder returns the sorted names of the modules that are defined in or below any of dirs.
+func modulesUnder(entries []moduleIndexEntry, dirs []string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, entry := range entries {
+		if seen[entry.Name] || !inAnyDir(entry.Dir, dirs) {
+			continue
+		}
+		seen[entry.Name] = true
+		names = append(names, entry.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func inAnyDir(dir string, dirs []string) bool {
+	for _, d := range dirs {
+		d = filepath.Clean(d)
+		if d == "." || dir == d || strings.HasPrefix(dir, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// outputsOf returns the outputs and installed files of each variant of the modules, or an error if
+// a module is not in the index.
+func outputsOf(entries []moduleIndexEntry, modules []string) ([]string, error) {
+	var lines []string
+	for _, module := range modules {
+		found := false
+		for _, entry := range entries {
+			if entry.Name != module {
+				continue
+			}
+			found = true
+			header := entry.Name + " (" + entry.Dir + ")"
+			if entry.Variant != "" {
+				header += " " + entry.Variant
+			}
+			lines = append(lines, header+":")
+			for _, output := range entry.Outputs {
+				lines = append(lines, "  output: "+output)
+			}
+			for _, install := range entry.Installs {
+				lines = append(lines, "  install: "+install)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("module %q is not in the module index", module)
+		}
+	}
+	return lines, nil
+}
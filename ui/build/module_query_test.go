@@ -0,0 +1,238 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"android/soong/ui/logger"
+)
+
+func TestConfigParseArgsModuleQuery(t *testing.T) {
+	ctx := testContext()
+
+	testCases := []struct {
+		args []string
+
+		query     string
+		queryArgs []string
+		remaining []string
+	}{
+		{
+			args: []string{"droid"},
+
+			remaining: []string{"droid"},
+		},
+		{
+			args: []string{"modules-under", "vendor/foo", "vendor/bar"},
+
+			query:     "modules-under",
+			queryArgs: []string{"vendor/foo", "vendor/bar"},
+		},
+		{
+			args: []string{"-j8", "outputs-of", "libfoo", "A=b"},
+
+			query:     "outputs-of",
+			queryArgs: []string{"libfoo"},
+		},
+		{
+			args: []string{"outputs-of", "modules-under"},
+
+			query:     "outputs-of",
+			queryArgs: []string{"modules-under"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			defer logger.Recover(func(err error) {
+				t.Fatal(err)
+			})
+
+			e := Environment(nil)
+			c := &configImpl{
+				environ: &e,
+			}
+			c.parseArgs(ctx, tc.args)
+
+			if c.moduleQuery != tc.query {
+				t.Errorf("for args=%q, module query:\nwant: %q\n got: %q\n", tc.args, tc.query, c.moduleQuery)
+			}
+			if !reflect.DeepEqual(c.moduleQueryArgs, tc.queryArgs) {
+				t.Errorf("for args=%q, module query arguments:\nwant: %q\n got: %q\n",
+					tc.args, tc.queryArgs, c.moduleQueryArgs)
+			}
+			if !reflect.DeepEqual(c.arguments, tc.remaining) {
+				t.Errorf("for args=%q, remaining arguments:\nwant: %q\n got: %q\n",
+					tc.args, tc.remaining, c.arguments)
+			}
+		})
+	}
+}
+
+var testModuleIndex = []moduleIndexEntry{
+	{
+		Name:    "bar",
+		Type:    "cc_binary",
+		Dir:     "vendor/foobar",
+		Variant: "android_arm64_armv8-a",
+		Outputs: []string{"out/soong/.intermediates/vendor/foobar/bar/android_arm64_armv8-a/bar"},
+	},
+	{
+		Name:     "libfoo",
+		Type:     "cc_library_shared",
+		Dir:      "vendor/foo",
+		Variant:  "android_arm64_armv8-a_shared",
+		Outputs:  []string{"out/soong/.intermediates/vendor/foo/libfoo/android_arm64_armv8-a_shared/libfoo.so"},
+		Installs: []string{"out/target/product/generic/system/lib64/libfoo.so"},
+	},
+	{
+		Name:     "libfoo",
+		Type:     "cc_library_shared",
+		Dir:      "vendor/foo",
+		Variant:  "android_arm_armv7-a-neon_shared",
+		Outputs:  []string{"out/soong/.intermediates/vendor/foo/libfoo/android_arm_armv7-a-neon_shared/libfoo.so"},
+		Installs: []string{"out/target/product/generic/system/lib/libfoo.so"},
+	},
+	{
+		Name: "prebuilt_etc",
+		Type: "prebuilt_etc",
+		Dir:  "vendor/foo/etc",
+	},
+}
+
+func TestQueryModuleIndex(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+		args  []string
+
+		expected []string
+		err      string
+	}{
+		{
+			name:     "modules under directory",
+			query:    "modules-under",
+			args:     []string{"vendor/foo"},
+			expected: []string{"libfoo", "prebuilt_etc"},
+		},
+		{
+			name:     "modules under directories",
+			query:    "modules-under",
+			args:     []string{"vendor/foo/etc/", "vendor/foobar"},
+			expected: []string{"bar", "prebuilt_etc"},
+		},
+		{
+			name:  "modules under empty directory",
+			query: "modules-under",
+			args:  []string{"vendor/baz"},
+		},
+		{
+			name:     "modules under top",
+			query:    "modules-under",
+			args:     []string{"."},
+			expected: []string{"bar", "libfoo", "prebuilt_etc"},
+		},
+		{
+			name:  "outputs of module",
+			query: "outputs-of",
+			args:  []string{"libfoo"},
+			expected: []string{
+				"libfoo (vendor/foo) android_arm64_armv8-a_shared:",
+				"  output: out/soong/.intermediates/vendor/foo/libfoo/android_arm64_armv8-a_shared/libfoo.so",
+				"  install: out/target/product/generic/system/lib64/libfoo.so",
+				"libfoo (vendor/foo) android_arm_armv7-a-neon_shared:",
+				"  output: out/soong/.intermediates/vendor/foo/libfoo/android_arm_armv7-a-neon_shared/libfoo.so",
+				"  install: out/target/product/generic/system/lib/libfoo.so",
+			},
+		},
+		{
+			name:  "outputs of module without variants",
+			query: "outputs-of",
+			args:  []string{"prebuilt_etc"},
+			expected: []string{
+				"prebuilt_etc (vendor/foo/etc):",
+			},
+		},
+		{
+			name:  "outputs of unknown module",
+			query: "outputs-of",
+			args:  []string{"libbar"},
+			err:   `module "libbar" is not in the module index`,
+		},
+		{
+			name:  "missing arguments",
+			query: "modules-under",
+			err:   "modules-under requires at least one argument",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lines, err := queryModuleIndex(testModuleIndex, tc.query, tc.args)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(lines, tc.expected) {
+				t.Errorf("incorrect result\nwant: %q\n got: %q", tc.expected, lines)
+			}
+		})
+	}
+}
+
+func TestLoadModuleIndex(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "module_index.json")
+	data := `[
+  {
+    "Name": "libfoo",
+    "Type": "cc_library_shared",
+    "Dir": "vendor/foo",
+    "Variant": "android_arm64_armv8-a_shared",
+    "Outputs": [
+      "out/soong/.intermediates/vendor/foo/libfoo/android_arm64_armv8-a_shared/libfoo.so"
+    ],
+    "Installs": [
+      "out/target/product/generic/system/lib64/libfoo.so"
+    ]
+  },
+  {
+    "Name": "prebuilt_etc",
+    "Type": "prebuilt_etc",
+    "Dir": "vendor/foo/etc"
+  }
+]`
+	if err := ioutil.WriteFile(file, []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := loadModuleIndex(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []moduleIndexEntry{testModuleIndex[1], testModuleIndex[3]}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("incorrect module index\nwant: %#v\n got: %#v", expected, entries)
+	}
+}
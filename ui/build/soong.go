@@ -42,6 +42,8 @@ const (
 	soongBuildTag      = "build"
 	bp2buildTag        = "bp2build"
 	jsonModuleGraphTag = "modulegraph"
+	moduleDepsGraphTag = "moduledepsgraph"
+	moduleIndexTag     = "moduleindex"
 	queryviewTag       = "queryview"
 	soongDocsTag       = "soong_docs"
 
@@ -235,6 +237,8 @@ func bootstrapGlobFileList(config Config) []string {
 		config.NamedGlobFile(soongBuildTag),
 		config.NamedGlobFile(bp2buildTag),
 		config.NamedGlobFile(jsonModuleGraphTag),
+		config.NamedGlobFile(moduleDepsGraphTag),
+		config.NamedGlobFile(moduleIndexTag),
 		config.NamedGlobFile(queryviewTag),
 		config.NamedGlobFile(soongDocsTag),
 	}
@@ -289,6 +293,27 @@ func bootstrapBlueprint(ctx Context, config Config) {
 		fmt.Sprintf("generating the Soong module graph at %s", config.ModuleGraphFile()),
 	)
 
+	moduleDepsGraphInvocation := primaryBuilderInvocation(
+		config,
+		moduleDepsGraphTag,
+		config.ModuleDepsGraphFile(),
+		[]string{
+			"--module_deps_graph_file", config.ModuleDepsGraphFile(),
+			"--module_deps_graph_roots", config.ModuleDepsGraphRoots(),
+		},
+		fmt.Sprintf("generating the Soong module dependency graph at %s", config.ModuleDepsGraphFile()),
+	)
+
+	moduleIndexInvocation := primaryBuilderInvocation(
+		config,
+		moduleIndexTag,
+		config.ModuleIndexFile(),
+		[]string{
+			"--module_index_file", config.ModuleIndexFile(),
+		},
+		fmt.Sprintf("generating the Soong module index at %s", config.ModuleIndexFile()),
+	)
+
 	queryviewDir := filepath.Join(config.SoongOutDir(), "queryview")
 	queryviewInvocation := primaryBuilderInvocation(
 		config,
@@ -314,6 +339,8 @@ func bootstrapBlueprint(ctx Context, config Config) {
 		config.NamedGlobFile(soongBuildTag),
 		config.NamedGlobFile(bp2buildTag),
 		config.NamedGlobFile(jsonModuleGraphTag),
+		config.NamedGlobFile(moduleDepsGraphTag),
+		config.NamedGlobFile(moduleIndexTag),
 		config.NamedGlobFile(queryviewTag),
 		config.NamedGlobFile(soongDocsTag),
 	}
@@ -346,6 +373,8 @@ func bootstrapBlueprint(ctx Context, config Config) {
 			mainSoongBuildInvocation,
 			bp2buildInvocation,
 			jsonModuleGraphInvocation,
+			moduleDepsGraphInvocation,
+			moduleIndexInvocation,
 			queryviewInvocation,
 			soongDocsInvocation},
 	}
@@ -419,6 +448,14 @@ func runSoong(ctx Context, config Config) {
 			checkEnvironmentFile(soongBuildEnv, config.UsedEnvFile(jsonModuleGraphTag))
 		}
 
+		if config.ModuleDepsGraph() {
+			checkEnvironmentFile(soongBuildEnv, config.UsedEnvFile(moduleDepsGraphTag))
+		}
+
+		if config.ModuleQuery() != "" {
+			checkEnvironmentFile(soongBuildEnv, config.UsedEnvFile(moduleIndexTag))
+		}
+
 		if config.Queryview() {
 			checkEnvironmentFile(soongBuildEnv, config.UsedEnvFile(queryviewTag))
 		}
@@ -473,6 +510,17 @@ func runSoong(ctx Context, config Config) {
 		targets = append(targets, config.ModuleGraphFile())
 	}
 
+	if config.ModuleDepsGraph() {
+		if config.ModuleDepsGraphRoots() == "" {
+			ctx.Fatalln("module-deps-graph requires MODULE_DEPS_GRAPH_ROOTS to list the root modules")
+		}
+		targets = append(targets, config.ModuleDepsGraphFile())
+	}
+
+	if config.ModuleQuery() != "" {
+		targets = append(targets, config.ModuleIndexFile())
+	}
+
 	if config.Bp2Build() {
 		targets = append(targets, config.Bp2BuildMarkerFile())
 	}